@@ -0,0 +1,330 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"shared/apierror"
+	"shared/client"
+	"shared/pagination"
+)
+
+// Admin roles, stored in the admin_role column of the User table
+const (
+	RoleSuperAdmin  = "SuperAdmin"
+	RoleCoordinator = "Coordinator"
+	RoleClinician   = "Clinician"
+	RoleVolunteer   = "Volunteer"
+)
+
+// Permissions carried in the admin JWT and enforced by each microservice's middleware
+const (
+	PermissionViewSeniors     = "seniors:read"     // Senior roster and participation (days since last response)
+	PermissionViewClinical    = "clinical:read"    // FES and self-assessment results, scores and risk levels
+	PermissionSendReminders   = "reminders:send"   // Assessment reminder emails
+	PermissionManageAdmins    = "admins:manage"    // Invite, deactivate, reset and re-role admin accounts
+	PermissionManageReferrals = "referrals:manage" // Clinical referral queue for high-risk seniors
+)
+
+// contextKey is used to store values on the request context
+type contextKey string
+
+// ClaimsContextKey holds the validated JWT claims set by the authentication middleware
+const ClaimsContextKey contextKey = "claims"
+
+// RolePermissions maps each admin role to the permissions it is granted
+var RolePermissions = map[string][]string{
+	RoleSuperAdmin:  {PermissionViewSeniors, PermissionViewClinical, PermissionSendReminders, PermissionManageAdmins, PermissionManageReferrals},
+	RoleCoordinator: {PermissionViewSeniors, PermissionViewClinical, PermissionSendReminders, PermissionManageReferrals},
+	RoleClinician:   {PermissionViewSeniors, PermissionViewClinical, PermissionManageReferrals},
+	RoleVolunteer:   {PermissionViewSeniors, PermissionSendReminders},
+}
+
+// Admin account statuses, stored in the status column of the User table
+const (
+	StatusInvited     = "Invited"
+	StatusActive      = "Active"
+	StatusDeactivated = "Deactivated"
+)
+
+// AdminAccount represents an admin as listed on the account management page
+type AdminAccount struct {
+	UserID      int      `json:"user_id"`
+	Name        string   `json:"name"`
+	Email       string   `json:"email"`
+	AdminRole   string   `json:"admin_role"`
+	Status      string   `json:"status"`
+	Permissions []string `json:"permissions"`
+}
+
+// adminListOptions are the sorts of the list of admin accounts, which have no date or senior to filter on
+var adminListOptions = pagination.Options{
+	Sort: map[string]string{
+		"user_id":    "user_id",
+		"name":       "name",
+		"email":      "email",
+		"admin_role": "admin_role",
+		"status":     "status",
+	},
+	DefaultSort: "user_id",
+	IDColumn:    "user_id",
+}
+
+// ListAdmins returns a page of the admin accounts with their role and status, see the pagination package for the query parameters
+func (h *Handler) ListAdmins(w http.ResponseWriter, r *http.Request) {
+	page, err := pagination.Parse(r, adminListOptions)
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidField, err.Error())
+		return
+	}
+
+	accounts, total, err := h.store.Admins(r.Context(), page)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying admin accounts", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+	for i := range accounts {
+		accounts[i].Permissions = RolePermissions[accounts[i].AdminRole]
+	}
+
+	page.WriteHeaders(w, total, len(accounts))
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(accounts); err != nil {
+		slog.ErrorContext(r.Context(), "Error encoding response", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+	}
+}
+
+// InviteAdminRequest is the body of an admin invitation
+type InviteAdminRequest struct {
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+	AdminRole string `json:"admin_role"`
+}
+
+// InviteAdmin creates an admin profile in the Invited state and asks the
+// authentication microservice to email the invitee a one-time setup token
+func (h *Handler) InviteAdmin(w http.ResponseWriter, r *http.Request) {
+	var req InviteAdminRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidBody, "Invalid request body")
+		return
+	}
+	req.Email = strings.TrimSpace(req.Email)
+	if req.Name == "" || req.Email == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "name and email are required")
+		return
+	}
+	if _, ok := RolePermissions[req.AdminRole]; !ok {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidField, "Invalid admin_role")
+		return
+	}
+
+	adminID, err := h.store.CreateAdmin(r.Context(), req.Name, req.Email, req.AdminRole, StatusInvited)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error inserting admin profile", "error", err)
+		apierror.Write(w, r, http.StatusConflict, apierror.AlreadyExists, "Failed to create admin, the email may already be in use")
+		return
+	}
+
+	// Credentials live in the authentication database under the same ID
+	if err := h.clients.Auth.InviteAdmin(client.FromRequest(r), adminID, req.Email); err != nil {
+		slog.ErrorContext(r.Context(), "Error creating admin credentials", "email", req.Email, "error", err)
+		if err := h.store.DeleteAdmin(r.Context(), adminID); err != nil {
+			slog.ErrorContext(r.Context(), "Error removing orphaned admin profile", "admin_id", adminID, "error", err)
+		}
+		apierror.Write(w, r, http.StatusBadGateway, apierror.UpstreamFailed, "Failed to send admin invitation")
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Admin invited", "admin_id", adminID, "admin_role", req.AdminRole)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(AdminAccount{
+		UserID:      adminID,
+		Name:        req.Name,
+		Email:       req.Email,
+		AdminRole:   req.AdminRole,
+		Status:      StatusInvited,
+		Permissions: RolePermissions[req.AdminRole],
+	})
+}
+
+// adminIDRequest is the body shared by the single-admin management endpoints
+type adminIDRequest struct {
+	AdminID   int    `json:"admin_id"`
+	AdminRole string `json:"admin_role,omitempty"`
+}
+
+// DeactivateAdmin blocks an admin from logging in and revokes their stored tokens
+func (h *Handler) DeactivateAdmin(w http.ResponseWriter, r *http.Request) {
+	var req adminIDRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.AdminID == 0 {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "admin_id is required")
+		return
+	}
+
+	if ok, err := h.keepsASuperAdmin(r.Context(), req.AdminID); err != nil {
+		slog.ErrorContext(r.Context(), "Error checking remaining super admins", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	} else if !ok {
+		apierror.Write(w, r, http.StatusConflict, apierror.LastSuperAdmin, "Cannot deactivate the last active super admin")
+		return
+	}
+
+	if err := h.clients.Auth.DeactivateAdmin(client.FromRequest(r), req.AdminID); err != nil {
+		slog.ErrorContext(r.Context(), "Error deactivating admin credentials", "admin_id", req.AdminID, "error", err)
+		apierror.Write(w, r, http.StatusBadGateway, apierror.UpstreamFailed, "Failed to deactivate admin")
+		return
+	}
+
+	if !h.updateAdminStatus(w, r, req.AdminID, StatusDeactivated) {
+		return
+	}
+	slog.InfoContext(r.Context(), "Admin deactivated", "admin_id", req.AdminID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Admin deactivated successfully"})
+}
+
+// ResetAdmin clears an admin's password and emails them a fresh setup token,
+// reactivating the account if it had been deactivated
+func (h *Handler) ResetAdmin(w http.ResponseWriter, r *http.Request) {
+	var req adminIDRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.AdminID == 0 {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "admin_id is required")
+		return
+	}
+
+	if err := h.clients.Auth.ResetAdmin(client.FromRequest(r), req.AdminID); err != nil {
+		slog.ErrorContext(r.Context(), "Error resetting admin credentials", "admin_id", req.AdminID, "error", err)
+		apierror.Write(w, r, http.StatusBadGateway, apierror.UpstreamFailed, "Failed to reset admin")
+		return
+	}
+
+	if !h.updateAdminStatus(w, r, req.AdminID, StatusInvited) {
+		return
+	}
+	slog.InfoContext(r.Context(), "Admin reset", "admin_id", req.AdminID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Admin reset successfully, a new invitation has been sent"})
+}
+
+// ResetAdminMFA removes two-factor authentication from an admin who lost their device.
+// The admin logs in with their password next time and can enrol a new device.
+func (h *Handler) ResetAdminMFA(w http.ResponseWriter, r *http.Request) {
+	var req adminIDRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.AdminID == 0 {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "admin_id is required")
+		return
+	}
+
+	if err := h.clients.Auth.ResetAdminMFA(client.FromRequest(r), req.AdminID); err != nil {
+		slog.ErrorContext(r.Context(), "Error resetting two-factor", "admin_id", req.AdminID, "error", err)
+		apierror.Write(w, r, http.StatusBadGateway, apierror.UpstreamFailed, "Failed to reset two-factor authentication")
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Admin two-factor authentication reset", "admin_id", req.AdminID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Two-factor authentication reset successfully"})
+}
+
+// UpdateAdminRole changes the role, and therefore the permissions, of an admin.
+// The change applies from the admin's next login.
+func (h *Handler) UpdateAdminRole(w http.ResponseWriter, r *http.Request) {
+	var req adminIDRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.AdminID == 0 {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "admin_id is required")
+		return
+	}
+	if _, ok := RolePermissions[req.AdminRole]; !ok {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidField, "Invalid admin_role")
+		return
+	}
+
+	if req.AdminRole != RoleSuperAdmin {
+		if ok, err := h.keepsASuperAdmin(r.Context(), req.AdminID); err != nil {
+			slog.ErrorContext(r.Context(), "Error checking remaining super admins", "error", err)
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+			return
+		} else if !ok {
+			apierror.Write(w, r, http.StatusConflict, apierror.LastSuperAdmin, "Cannot demote the last active super admin")
+			return
+		}
+	}
+
+	found, err := h.store.SetAdminRole(r.Context(), req.AdminID, req.AdminRole)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error updating admin role", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+	if !found {
+		apierror.Write(w, r, http.StatusNotFound, apierror.NotFound, "Admin not found")
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Admin role changed", "admin_id", req.AdminID, "admin_role", req.AdminRole)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Admin role updated successfully"})
+}
+
+// keepsASuperAdmin reports whether at least one other active super admin
+// remains if the given admin loses that role
+func (h *Handler) keepsASuperAdmin(ctx context.Context, adminID int) (bool, error) {
+	others, err := h.store.ActiveSuperAdminsExcept(ctx, adminID)
+	if err != nil {
+		return false, err
+	}
+	if others > 0 {
+		return true, nil
+	}
+
+	// Only the target itself could be the last super admin
+	target, err := h.store.Admin(ctx, adminID)
+	if err == ErrNotFound {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	return !(target.AdminRole == RoleSuperAdmin && target.Status == StatusActive), nil
+}
+
+// updateAdminStatus sets the account status, writing an error response and returning false on failure
+func (h *Handler) updateAdminStatus(w http.ResponseWriter, r *http.Request, adminID int, status string) bool {
+	found, err := h.store.SetAdminStatus(r.Context(), adminID, status)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error updating admin status", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return false
+	}
+	if !found {
+		apierror.Write(w, r, http.StatusNotFound, apierror.NotFound, "Admin not found")
+		return false
+	}
+	return true
+}
+
+// ActivateAdmin marks an invited admin as active once they have set a password.
+// Called by the authentication microservice when an invitation is accepted.
+func (h *Handler) ActivateAdmin(w http.ResponseWriter, r *http.Request) {
+	var req adminIDRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.AdminID == 0 {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "admin_id is required")
+		return
+	}
+
+	if err := h.store.ActivateAdmin(r.Context(), req.AdminID); err != nil {
+		slog.ErrorContext(r.Context(), "Error activating admin", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Admin activated successfully"})
+}
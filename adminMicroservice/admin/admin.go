@@ -1,610 +1,237 @@
 package admin
 
 import (
-	"database/sql"
+	"adminMicroservice/client/auth"
+	"adminMicroservice/client/fes"
+	"adminMicroservice/client/selfassessment"
+	"adminMicroservice/client/user"
+	"adminMicroservice/config"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/smtp"
-	"os"
+	"net/url"
+	"strconv"
 	"strings"
-	"time"
 
-	_ "github.com/go-sql-driver/mysql"
-	"github.com/joho/godotenv"
+	"shared/apierror"
+	"shared/client"
 )
 
-var db *sql.DB
-
-func init() {
-	// Load environment variables
-	err := godotenv.Load(".env")
-	if err != nil {
-		log.Fatalf("Error loading .env file: %v", err)
-	}
-
-	// Initialize database connection
-	dbConnection := os.Getenv("ADMIN_DB_CONNECTION")
-	if dbConnection == "" {
-		log.Fatalf("ADMIN_DB_CONNECTION environment variable is not set")
-	}
-
-	log.Println("Initializing database connection...")
-	db, err = sql.Open("mysql", dbConnection)
-	if err != nil {
-		log.Fatalf("Error connecting to database: %v", err)
-	}
-
-	// Test the database connection
-	err = db.Ping()
-	if err != nil {
-		log.Fatalf("Database connection test failed: %v", err)
-	}
-	log.Println("Database connection successful.")
+// UserClient reads seniors from the user microservice
+type UserClient interface {
+	AllUsers(ctx context.Context) ([]user.User, error)
+	UserPage(ctx context.Context, params url.Values) ([]user.User, client.Page, error)
+	ReminderCaregivers(ctx context.Context, seniorEmail string) ([]string, error)
+	CombinedRisks(ctx context.Context) ([]user.CombinedRisk, error)
 }
 
-// Admin represents the structure of a admin record
-type Admin struct {
-	UserID int    `json:"user_id"`
-	Name   string `json:"name" `
-	Email  string `json:"email"`
-	Role   string `json:"role"`
+// FESClient reads results and analytics from the FES microservice
+type FESClient interface {
+	LatestResponses(ctx context.Context) ([]fes.Response, error)
+	ResponsePage(ctx context.Context, params url.Values) ([]fes.Response, client.Page, error)
+	ResponseDetailPage(ctx context.Context, params url.Values) ([]fes.ResponseDetail, client.Page, error)
+	LastResponseDays(ctx context.Context) ([]fes.LastResponseDay, error)
+	LatestRisk(ctx context.Context) ([]fes.RiskLevel, error)
+	Trends(ctx context.Context, userID, days int) (fes.UserTrends, error)
+	DecliningTrends(ctx context.Context, days int) ([]fes.UserTrends, error)
+	RiskDistribution(ctx context.Context, from, to string) ([]fes.RiskPeriod, error)
+	ItemBreakdown(ctx context.Context, from, to string) ([]fes.ItemBreakdown, error)
+	UserScoreTotals(ctx context.Context, from, to string) ([]fes.UserScoreTotal, error)
 }
 
-// GetAdminByID handles retrieving a admin record from the database by adminID
-func GetAdminByID(w http.ResponseWriter, r *http.Request) {
-	// Extract userID from query parameters
-	adminID := r.URL.Query().Get("adminID")
-	if adminID == "" {
-		http.Error(w, "adminID is required", http.StatusBadRequest)
-		return
-	}
-
-	// Prepare the query to fetch admin details by adminID
-	var admin Admin
-	err := db.QueryRow(`
-		SELECT user_id, name, email, role
-		FROM User
-		WHERE user_id = ?`, adminID).Scan(
-		&admin.UserID, &admin.Name, &admin.Email, &admin.Role,
-	)
-	if err == sql.ErrNoRows {
-		http.Error(w, "Admin not found", http.StatusNotFound)
-		return
-	} else if err != nil {
-		log.Printf("Error querying admin record: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
-	}
-
-	// Respond with the admin data as JSON
-	w.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(admin)
-	if err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
-	}
+// SelfAssessmentClient reads results and analytics from the self-assessment microservice
+type SelfAssessmentClient interface {
+	LatestSessionScores(ctx context.Context) ([]selfassessment.SessionScore, error)
+	SessionScorePage(ctx context.Context, params url.Values) ([]selfassessment.SessionScore, client.Page, error)
+	TestTimePage(ctx context.Context, params url.Values) ([]selfassessment.TestTime, client.Page, error)
+	UserRisks(ctx context.Context) ([]selfassessment.UserRisk, error)
+	LastResponseDays(ctx context.Context) ([]selfassessment.LastResponseDay, error)
+	Trends(ctx context.Context, userID, days int) (selfassessment.UserTrends, error)
+	DecliningTrends(ctx context.Context, days int) ([]selfassessment.UserTrends, error)
+	RiskDistribution(ctx context.Context, from, to string) ([]selfassessment.RiskPeriod, error)
+	UserScoreTotals(ctx context.Context, from, to string) ([]selfassessment.UserScoreTotal, error)
 }
 
-// Struct representing basic user information, for Dashboard
-type UserNameAge struct {
-	UserID int    `json:"user_id"`
-	Name   string `json:"name"`
-	Email  string `json:"email"`
-	Age    string `json:"age"`
+// AuthClient manages admin credentials in the authentication microservice
+type AuthClient interface {
+	InviteAdmin(ctx context.Context, adminID int, email string) error
+	DeactivateAdmin(ctx context.Context, adminID int) error
+	ResetAdmin(ctx context.Context, adminID int) error
+	ResetAdminMFA(ctx context.Context, adminID int) error
 }
 
-func CallUserMicroservice(w http.ResponseWriter, r *http.Request) {
-	apiURL := "http://18.143.103.158:5100/api/v1/user/getAllUser"
-
-	//Extract the Authorization header from the incoming request
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		http.Error(w, "Authorization header missing", http.StatusUnauthorized)
-		return
-	}
-
-	// Create an HTTP GET request
-	req, err := http.NewRequest("GET", apiURL, nil)
-	if err != nil {
-		log.Printf("Error creating request: %v", err)
-		http.Error(w, "Failed to create request", http.StatusInternalServerError)
-		return
-	}
-
-	// Set the Authorization header
-	req.Header.Set("Authorization", authHeader)
-
-	// Perform the request to the downstream microservice
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Printf("Error making request: %v", err)
-		http.Error(w, "Failed to contact user microservice", http.StatusInternalServerError)
-		return
-	}
-	defer resp.Body.Close()
-
-	// Check the status code of the response
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		http.Error(w, fmt.Sprintf("Microservice error: %s", string(body)), resp.StatusCode)
-		return
-	}
-
-	// Parse the response body
-	var users []UserNameAge
-	err = json.NewDecoder(resp.Body).Decode(&users)
-	if err != nil {
-		log.Printf("Error decoding response: %v", err)
-		http.Error(w, "Failed to parse response from microservice", http.StatusInternalServerError)
-		return
-	}
+// Clients are the microservices the admin dashboard reads from
+type Clients struct {
+	User           UserClient
+	FES            FESClient
+	SelfAssessment SelfAssessmentClient
+	Auth           AuthClient
+}
 
-	//Respond to the original client with the data
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	err = json.NewEncoder(w).Encode(users)
-	if err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "Failed to send response to client", http.StatusInternalServerError)
+// NewClients returns the clients of the microservices at their configured URLs
+func NewClients() Clients {
+	return Clients{
+		User:           user.New(),
+		FES:            fes.New(),
+		SelfAssessment: selfassessment.New(),
+		Auth:           auth.New(),
 	}
 }
 
-// Struct representing the User Response attributes
-type UserResponse struct {
-	ResponseID   uint16    `json:"response_id"`                      // Unique ID for the response
-	UserID       uint16    `json:"user_id"`                          // Associated user ID
-	TotalScore   uint16    `json:"total_score"`                      // Total score across all questions
-	ResponseDate time.Time `json:"response_date" db:"response_date"` // Date of response submission
+// Handler serves the admin endpoints from its store and the other microservices
+type Handler struct {
+	store   AdminStore
+	clients Clients
 }
 
-func CallFESForUserResponse(w http.ResponseWriter, r *http.Request) {
-	apiURL := "http://18.143.103.158:5300/api/v1/fes/getAllResponses"
-
-	// Extract the Authorization header from the incoming request
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		http.Error(w, "Authorization header missing", http.StatusUnauthorized)
-		return
-	}
-
-	// Create an HTTP GET request
-	req, err := http.NewRequest("GET", apiURL, nil)
-	if err != nil {
-		log.Printf("Error creating request: %v", err)
-		http.Error(w, "Failed to create request", http.StatusInternalServerError)
-		return
-	}
-
-	// Set the Authorization header
-	req.Header.Set("Authorization", authHeader)
-
-	// Perform the request to the downstream microservice
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Printf("Error making request: %v", err)
-		http.Error(w, "Failed to contact response microservice", http.StatusInternalServerError)
-		return
-	}
-	defer resp.Body.Close()
-
-	// Check the status code of the response
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		http.Error(w, fmt.Sprintf("Microservice error: %s", string(body)), resp.StatusCode)
-		return
-	}
-
-	// Parse the response body
-	var responses []UserResponse
-	err = json.NewDecoder(resp.Body).Decode(&responses)
-	if err != nil {
-		log.Printf("Error decoding response: %v", err)
-		http.Error(w, "Failed to parse response from microservice", http.StatusInternalServerError)
-		return
-	}
-
-	// Respond to the original client with the data
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	err = json.NewEncoder(w).Encode(responses)
-	if err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "Failed to send response to client", http.StatusInternalServerError)
-	}
+// NewHandler returns the admin handlers, reading and writing store and calling the other microservices with clients
+func NewHandler(store AdminStore, clients Clients) *Handler {
+	return &Handler{store: store, clients: clients}
 }
 
-// Struct for Individual Question Information
-type UserResponseDetails struct {
-	ResponseID    int `json:"response_id"`    // Unique ID of the response
-	QuestionID    int `json:"question_id"`    // Associated question ID
-	ResponseScore int `json:"response_score"` // User's response score
+// Admin represents the structure of a admin record
+type Admin struct {
+	UserID      int      `json:"user_id"`
+	Name        string   `json:"name" `
+	Email       string   `json:"email"`
+	Role        string   `json:"role"`
+	AdminRole   string   `json:"admin_role"`
+	Status      string   `json:"status"`
+	Permissions []string `json:"permissions"`
 }
 
-func CallFESForUserResponseDetails(w http.ResponseWriter, r *http.Request) {
-	apiURL := "http://18.143.103.158:5300/api/v1/fes/getAllIndividualRes" // URL to call `getAllFESIndividualRes` endpoint
-
-	// Extract the Authorization header from the incoming request
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		http.Error(w, "Authorization header missing", http.StatusUnauthorized)
+// GetAdminByID handles retrieving a admin record from the database by adminID
+func (h *Handler) GetAdminByID(w http.ResponseWriter, r *http.Request) {
+	// Extract userID from query parameters
+	adminIDStr := r.URL.Query().Get("adminID")
+	if adminIDStr == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "adminID is required")
 		return
 	}
-
-	// Create an HTTP GET request
-	req, err := http.NewRequest("GET", apiURL, nil)
+	adminID, err := strconv.Atoi(adminIDStr)
 	if err != nil {
-		log.Printf("Error creating request: %v", err)
-		http.Error(w, "Failed to create request", http.StatusInternalServerError)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidField, "Invalid adminID")
 		return
 	}
 
-	// Set the Authorization header
-	req.Header.Set("Authorization", authHeader)
-
-	// Perform the request to the downstream microservice
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Printf("Error making request: %v", err)
-		http.Error(w, "Failed to contact response microservice", http.StatusInternalServerError)
+	// Fetch the admin details by adminID
+	admin, err := h.store.Admin(r.Context(), adminID)
+	if err == ErrNotFound {
+		apierror.Write(w, r, http.StatusNotFound, apierror.NotFound, "Admin not found")
 		return
-	}
-	defer resp.Body.Close()
-
-	// Check the status code of the response
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		http.Error(w, fmt.Sprintf("Microservice error: %s", string(body)), resp.StatusCode)
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying admin record", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
 		return
 	}
 
-	// Parse the response body
-	var responseDetails []UserResponseDetails
-	err = json.NewDecoder(resp.Body).Decode(&responseDetails)
-	if err != nil {
-		log.Printf("Error decoding response: %v", err)
-		http.Error(w, "Failed to parse response from microservice", http.StatusInternalServerError)
-		return
-	}
+	admin.Permissions = RolePermissions[admin.AdminRole]
 
-	// Respond to the original client with the data
+	// Respond with the admin data as JSON
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	err = json.NewEncoder(w).Encode(responseDetails)
-	if err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "Failed to send response to client", http.StatusInternalServerError)
-	}
-}
-
-// Struct to hold user_id, avg score and session_date
-type TestSessionUser struct {
-	SessionID   int       `json:"session_id"`   // Unique ID for the session
-	UserID      int       `json:"user_id"`      // Associated user ID
-	SessionDate time.Time `json:"session_date"` // Date and time of the session
-	TotalScore    int16     `json:"total_score"`    // Average score for the session
-}
-
-// Function to call selfAssessMicro, get all user with their dates and score
-func CallFAForAllUserTotalScore(w http.ResponseWriter, r *http.Request) {
-	apiURL := "http://18.143.103.158:5250/api/v1/selfAssessment/getAllTotalScore"
-
-	// Extract the Authorization header from the incoming request
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		http.Error(w, "Authorization header missing", http.StatusUnauthorized)
-		return
-	}
-
-	// Create an HTTP GET request
-	req, err := http.NewRequest("GET", apiURL, nil)
-	if err != nil {
-		log.Printf("Error creating request: %v", err)
-		http.Error(w, "Failed to create request", http.StatusInternalServerError)
-		return
-	}
-
-	// Set the Authorization header
-	req.Header.Set("Authorization", authHeader)
-
-	// Perform the request to the downstream microservice
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Printf("Error making request: %v", err)
-		http.Error(w, "Failed to contact Test Session microservice", http.StatusInternalServerError)
-		return
-	}
-	defer resp.Body.Close()
-
-	// Check the status code of the response
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		http.Error(w, fmt.Sprintf("Microservice error: %s", string(body)), resp.StatusCode)
-		return
-	}
-
-	// Parse the response body
-	var sessionResults []TestSessionUser
-	err = json.NewDecoder(resp.Body).Decode(&sessionResults)
+	err = json.NewEncoder(w).Encode(admin)
 	if err != nil {
-		log.Printf("Error decoding response: %v", err)
-		http.Error(w, "Failed to parse response from microservice", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error encoding response", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
 		return
 	}
+}
 
-	// Respond to the original client with the data
+// writeJSON responds with v encoded as JSON
+func writeJSON(w http.ResponseWriter, v interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	err = json.NewEncoder(w).Encode(sessionResults)
-	if err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "Failed to send response to client", http.StatusInternalServerError)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("Error encoding response", "error", err)
 	}
 }
 
-// Struct to represent avg_time with test name Result
-type FATestWithAvgTime struct {
-	ResultID    uint      `json:"result_id"`
-	TestName    string    `json:"test_name"`
-	UserID      uint      `json:"user_id"`
-	TimeTaken   float64   `json:"time_taken"`
-	SessionDate time.Time `json:"session_date"`
-}
-
-// Function to fetch the test name, as well as the userID and time taken FROM SELF ASSESS Microservice
-func CallFAForAllUserTime(w http.ResponseWriter, r *http.Request) {
-	apiURL := "http://18.143.103.158:5250/api/v1/selfAssessment/getAllAvgTime"
-
-	// Extract the Authorization header from the incoming request
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		http.Error(w, "Authorization header missing", http.StatusUnauthorized)
-		return
-	}
-
-	// Create an HTTP GET request
-	req, err := http.NewRequest("GET", apiURL, nil)
-	if err != nil {
-		log.Printf("Error creating request: %v", err)
-		http.Error(w, "Failed to create request", http.StatusInternalServerError)
-		return
-	}
-
-	// Set the Authorization header
-	req.Header.Set("Authorization", authHeader)
-
-	// Perform the request to the downstream microservice
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// Function to call userMicroservice, get a page of the ID, name, email and age of all users
+func (h *Handler) CallUserMicroservice(w http.ResponseWriter, r *http.Request) {
+	users, page, err := h.clients.User.UserPage(client.FromRequest(r), r.URL.Query())
 	if err != nil {
-		log.Printf("Error making request: %v", err)
-		http.Error(w, "Failed to contact Test Result microservice", http.StatusInternalServerError)
+		client.WriteError(w, r, err)
 		return
 	}
-	defer resp.Body.Close()
-
-	// Check the status code of the response
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		http.Error(w, fmt.Sprintf("Microservice error: %s", string(body)), resp.StatusCode)
-		return
-	}
-
-	// Parse the response body
-	var testResults []FATestWithAvgTime
-	err = json.NewDecoder(resp.Body).Decode(&testResults)
-	if err != nil {
-		log.Printf("Error decoding response: %v", err)
-		http.Error(w, "Failed to parse response from microservice", http.StatusInternalServerError)
-		return
-	}
-
-	// Respond to the original client with the data
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	err = json.NewEncoder(w).Encode(testResults)
-	if err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "Failed to send response to client", http.StatusInternalServerError)
-	}
-}
-
-// Struct to hold user_id and overall_risk_level
-type UserRisk struct {
-	UserID           int    `json:"user_id"`
-	OverallRiskLevel string `json:"overall_risk_level"`
+	page.SetHeaders(w)
+	writeJSON(w, users)
 }
 
-// Function to call selfAssessmentMicroservice and get all user risks
-func CallFAForAllUserRisk(w http.ResponseWriter, r *http.Request) {
-	apiURL := "http://18.143.103.158:5250/api/v1/selfAssessment/getAllUserRisk"
-
-	// Extract the Authorization header from the incoming request
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		http.Error(w, "Authorization header missing", http.StatusUnauthorized)
-		return
-	}
-
-	// Create an HTTP GET request
-	req, err := http.NewRequest("GET", apiURL, nil)
+// Function to call FESMicroservice, get a page of all users' FES responses
+func (h *Handler) CallFESForUserResponse(w http.ResponseWriter, r *http.Request) {
+	responses, page, err := h.clients.FES.ResponsePage(client.FromRequest(r), r.URL.Query())
 	if err != nil {
-		log.Printf("Error creating request: %v", err)
-		http.Error(w, "Failed to create request", http.StatusInternalServerError)
+		client.WriteError(w, r, err)
 		return
 	}
+	page.SetHeaders(w)
+	writeJSON(w, responses)
+}
 
-	// Set the Authorization header
-	req.Header.Set("Authorization", authHeader)
-
-	// Perform the request to the downstream microservice
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// Function to call FESMicroservice, get a page of the answers to every question of all FES responses
+func (h *Handler) CallFESForUserResponseDetails(w http.ResponseWriter, r *http.Request) {
+	responseDetails, page, err := h.clients.FES.ResponseDetailPage(client.FromRequest(r), r.URL.Query())
 	if err != nil {
-		log.Printf("Error making request: %v", err)
-		http.Error(w, "Failed to contact risk assessment microservice", http.StatusInternalServerError)
-		return
-	}
-	defer resp.Body.Close()
-
-	// Check the status code of the response
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		http.Error(w, fmt.Sprintf("Microservice error: %s", string(body)), resp.StatusCode)
+		client.WriteError(w, r, err)
 		return
 	}
+	page.SetHeaders(w)
+	writeJSON(w, responseDetails)
+}
 
-	// Parse the response body
-	var riskResults []UserRisk
-	err = json.NewDecoder(resp.Body).Decode(&riskResults)
+// Function to call selfAssessMicro, get a page of all user with their dates and score
+func (h *Handler) CallFAForAllUserTotalScore(w http.ResponseWriter, r *http.Request) {
+	sessionResults, page, err := h.clients.SelfAssessment.SessionScorePage(client.FromRequest(r), r.URL.Query())
 	if err != nil {
-		log.Printf("Error decoding response: %v", err)
-		http.Error(w, "Failed to parse response from microservice", http.StatusInternalServerError)
+		client.WriteError(w, r, err)
 		return
 	}
-
-	// Respond to the original client with the data
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	err = json.NewEncoder(w).Encode(riskResults)
-	if err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "Failed to send response to client", http.StatusInternalServerError)
-	}
-}
-
-// Define a struct to hold the response data for last res day
-type UserLastResDetails struct {
-	UserID            int `json:"user_id"`
-	DaysSinceResponse int `json:"days_since_last_fesres"`
+	page.SetHeaders(w)
+	writeJSON(w, sessionResults)
 }
 
-func CallFESLastResDayForAllUsers(w http.ResponseWriter, r *http.Request) {
-	apiURL := "http://18.143.103.158:5300/api/v1/fes/getAllFESLastResDay"
-
-	// Extract the Authorization header from the incoming request
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		http.Error(w, "Authorization header missing", http.StatusUnauthorized)
-		return
-	}
-
-	// Create an HTTP GET request
-	req, err := http.NewRequest("GET", apiURL, nil)
+// Function to fetch a page of the test name, as well as the userID and time taken FROM SELF ASSESS Microservice
+func (h *Handler) CallFAForAllUserTime(w http.ResponseWriter, r *http.Request) {
+	testResults, page, err := h.clients.SelfAssessment.TestTimePage(client.FromRequest(r), r.URL.Query())
 	if err != nil {
-		log.Printf("Error creating request: %v", err)
-		http.Error(w, "Failed to create request", http.StatusInternalServerError)
+		client.WriteError(w, r, err)
 		return
 	}
+	page.SetHeaders(w)
+	writeJSON(w, testResults)
+}
 
-	// Set the Authorization header
-	req.Header.Set("Authorization", authHeader)
-
-	// Perform the request to the downstream microservice
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// Function to call selfAssessmentMicroservice and get all user risks
+func (h *Handler) CallFAForAllUserRisk(w http.ResponseWriter, r *http.Request) {
+	riskResults, err := h.clients.SelfAssessment.UserRisks(client.FromRequest(r))
 	if err != nil {
-		log.Printf("Error making request: %v", err)
-		http.Error(w, "Failed to contact FES last response microservice", http.StatusInternalServerError)
-		return
-	}
-	defer resp.Body.Close()
-
-	// Check the status code of the response
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		http.Error(w, fmt.Sprintf("Microservice error: %s", string(body)), resp.StatusCode)
+		client.WriteError(w, r, err)
 		return
 	}
+	writeJSON(w, riskResults)
+}
 
-	// Parse the response body
-	var userLastResDetails []UserLastResDetails
-	err = json.NewDecoder(resp.Body).Decode(&userLastResDetails)
+// Function to call FESMicroservice, get the days since each user last completed the FES
+func (h *Handler) CallFESLastResDayForAllUsers(w http.ResponseWriter, r *http.Request) {
+	userLastResDetails, err := h.clients.FES.LastResponseDays(client.FromRequest(r))
 	if err != nil {
-		log.Printf("Error decoding response: %v", err)
-		http.Error(w, "Failed to parse response from microservice", http.StatusInternalServerError)
+		client.WriteError(w, r, err)
 		return
 	}
-
-	// Respond to the original client with the data
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	err = json.NewEncoder(w).Encode(userLastResDetails)
-	if err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "Failed to send response to client", http.StatusInternalServerError)
-	}
-}
-
-// struct to hold the user ID and days since last response
-type FallAssesLastRes struct {
-	UserID            int `json:"user_id"`
-	DaysSinceResponse int `json:"days_since_last_fares"`
+	writeJSON(w, userLastResDetails)
 }
 
 // CallFallAssesLastResDayForAllUsers is the client code to fetch from the microservice
-func CallFALastResDayForAllUsers(w http.ResponseWriter, r *http.Request) {
-	apiURL := "http://18.143.103.158:5250/api/v1/selfAssessment/getAllLastResDay"
-
-	// Extract the Authorization header from the incoming request
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		http.Error(w, "Authorization header missing", http.StatusUnauthorized)
-		return
-	}
-
-	// Create an HTTP GET request
-	req, err := http.NewRequest("GET", apiURL, nil)
-	if err != nil {
-		log.Printf("Error creating request: %v", err)
-		http.Error(w, "Failed to create request", http.StatusInternalServerError)
-		return
-	}
-
-	// Set the Authorization header
-	req.Header.Set("Authorization", authHeader)
-
-	// Perform the request to the downstream microservice
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Printf("Error making request: %v", err)
-		http.Error(w, "Failed to contact fall assessment response microservice", http.StatusInternalServerError)
-		return
-	}
-	defer resp.Body.Close()
-
-	// Check the status code of the response
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		http.Error(w, fmt.Sprintf("Microservice error: %s", string(body)), resp.StatusCode)
-		return
-	}
-
-	// Parse the response body
-	var fallAssesLastResDetails []FallAssesLastRes
-	err = json.NewDecoder(resp.Body).Decode(&fallAssesLastResDetails)
+func (h *Handler) CallFALastResDayForAllUsers(w http.ResponseWriter, r *http.Request) {
+	fallAssesLastResDetails, err := h.clients.SelfAssessment.LastResponseDays(client.FromRequest(r))
 	if err != nil {
-		log.Printf("Error decoding response: %v", err)
-		http.Error(w, "Failed to parse response from microservice", http.StatusInternalServerError)
+		client.WriteError(w, r, err)
 		return
 	}
-
-	// Respond to the original client with the data
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	err = json.NewEncoder(w).Encode(fallAssesLastResDetails)
-	if err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "Failed to send response to client", http.StatusInternalServerError)
-	}
+	writeJSON(w, fallAssesLastResDetails)
 }
 
 type EmailRequestReminder struct {
@@ -618,11 +245,10 @@ type EmailRequestReminder struct {
 
 // SendEmail sends an email with reminder details
 func SendEmail(to, userName, selectedTestsSummary, selectedTestsTitle string) error {
-	// SMTP configuration from .env
-	smtpHost := "smtp.gmail.com"
-	smtpPort := "587"
-	smtpUser := os.Getenv("SMTP_USER")
-	smtpPassword := os.Getenv("SMTP_PASSWORD")
+	// SMTP configuration
+	mail := config.Current().SMTP
+	smtpHost, smtpPort := mail.Host, mail.Port
+	smtpUser, smtpPassword := mail.User, mail.Password
 
 	// Email content (HTML)
 	from := "FallSafe <" + smtpUser + ">"
@@ -698,26 +324,26 @@ func SendEmail(to, userName, selectedTestsSummary, selectedTestsTitle string) er
 	// Send email
 	err := smtp.SendMail(smtpHost+":"+smtpPort, auth, smtpUser, []string{to}, []byte(message))
 	if err != nil {
-		log.Printf("Error sending email: %v", err)
+		slog.Error("Error sending email", "error", err)
 		return err
 	}
-	log.Println("Email sent successfully to:", to)
+	slog.Info("Email sent", "email", to)
 	return nil
 }
 
 // SendEmailHandler processes incoming email requests
-func SendEmailHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) SendEmailHandler(w http.ResponseWriter, r *http.Request) {
 	var req EmailRequestReminder
 
 	// Decode JSON request body
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidBody, "Invalid request body")
 		return
 	}
 
 	// Check that there are selected tests
 	if len(req.SelectedTests) == 0 {
-		http.Error(w, "No selected tests provided", http.StatusBadRequest)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "No selected tests provided")
 		return
 	}
 
@@ -725,7 +351,7 @@ func SendEmailHandler(w http.ResponseWriter, r *http.Request) {
 	var selectedTestsSummary string
 	var selectedTestsTitle string
 	for _, test := range req.SelectedTests {
-		
+
 		// Check if test days is "not taken" and modify the message accordingly
 		// Inside the loop where we build the summary:
 		if strings.Contains(strings.ToLower(strings.TrimSpace(test.LastCompletedDays)), "not taken") {
@@ -744,77 +370,43 @@ func SendEmailHandler(w http.ResponseWriter, r *http.Request) {
 	// Send a single email with all selected tests in the body
 	err := SendEmail(req.Email, req.UserName, selectedTestsSummary, selectedTestsTitle)
 	if err != nil {
-		http.Error(w, "Failed to send email", http.StatusInternalServerError)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Failed to send email")
 		return
 	}
 
+	// Copy the reminder to caregivers the senior has allowed to see reminders
+	caregiverEmails, err := h.clients.User.ReminderCaregivers(r.Context(), req.Email)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error fetching caregivers for reminder", "error", err)
+	}
+	for _, caregiverEmail := range caregiverEmails {
+		if err := SendEmail(caregiverEmail, req.UserName, selectedTestsSummary, selectedTestsTitle); err != nil {
+			slog.ErrorContext(r.Context(), "Error copying reminder to caregiver", "error", err)
+		}
+	}
+
 	// Send success response
 	response := map[string]string{"message": "Email sent successfully"}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-// Struct to represent User Risk Level with user_id and risk_level
-type UserRiskLevel struct {
-	UserID    int    `json:"user_id"`    // User ID
-	RiskLevel string `json:"risk_level"` // Risk level (low, moderate, high)
-}
-
 // Function to fetch user risk levels from the UserResponse API
-func CallFESUserRiskLevel(w http.ResponseWriter, r *http.Request) {
-	apiURL := "http://18.143.103.158:5300/api/v1/fes/getAllFESLatestRisk" // Replace with actual API URL
-
-	// Extract the Authorization header from the incoming request
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		http.Error(w, "Authorization header missing", http.StatusUnauthorized)
-		return
-	}
-
-	// Create an HTTP GET request
-	req, err := http.NewRequest("GET", apiURL, nil)
-	if err != nil {
-		log.Printf("Error creating request: %v", err)
-		http.Error(w, "Failed to create request", http.StatusInternalServerError)
-		return
-	}
-
-	// Set the Authorization header
-	req.Header.Set("Authorization", authHeader)
-
-	// Perform the request to the downstream microservice
-	client := &http.Client{}
-	resp, err := client.Do(req)
+func (h *Handler) CallFESUserRiskLevel(w http.ResponseWriter, r *http.Request) {
+	userRiskLevels, err := h.clients.FES.LatestRisk(client.FromRequest(r))
 	if err != nil {
-		log.Printf("Error making request: %v", err)
-		http.Error(w, "Failed to contact User Risk Level microservice", http.StatusInternalServerError)
-		return
-	}
-	defer resp.Body.Close()
-
-	// Check the status code of the response
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		http.Error(w, fmt.Sprintf("Microservice error: %s", string(body)), resp.StatusCode)
+		client.WriteError(w, r, err)
 		return
 	}
+	writeJSON(w, userRiskLevels)
+}
 
-	// Parse the response body into UserRiskLevel struct
-	var userRiskLevels []UserRiskLevel
-	err = json.NewDecoder(resp.Body).Decode(&userRiskLevels)
+// Function to call userMicroservice, get every senior's combined fall risk
+func (h *Handler) CallUserForCombinedRisk(w http.ResponseWriter, r *http.Request) {
+	risks, err := h.clients.User.CombinedRisks(client.FromRequest(r))
 	if err != nil {
-		log.Printf("Error decoding response: %v", err)
-		http.Error(w, "Failed to parse response from microservice", http.StatusInternalServerError)
+		client.WriteError(w, r, err)
 		return
 	}
-
-	// Respond to the original client with the user risk levels data
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	err = json.NewEncoder(w).Encode(userRiskLevels)
-	if err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "Failed to send response to client", http.StatusInternalServerError)
-	}
+	writeJSON(w, risks)
 }
-
@@ -0,0 +1,278 @@
+package admin
+
+import (
+	"adminMicroservice/client/fes"
+	"adminMicroservice/client/selfassessment"
+	"adminMicroservice/client/user"
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"shared/apierror"
+	"shared/client"
+)
+
+// Analytics sources, used as the keys of the errors field
+const (
+	sourceFESRiskDistribution = "fes_risk_distribution"
+	sourceFARiskDistribution  = "fa_risk_distribution"
+	sourceFESItems            = "fes_items"
+	sourceFESScoreTotals      = "fes_score_totals"
+	sourceFAScoreTotals       = "fa_score_totals"
+)
+
+// analyticsDateLayout is the format of the from and to query parameters
+const analyticsDateLayout = "2006-01-02"
+
+// ageBands are the cohorts scores are averaged over, as the lowest age of each band in descending order
+var ageBands = []struct {
+	Label  string
+	MinAge int
+}{
+	{"80+", 80},
+	{"70-79", 70},
+	{"60-69", 60},
+	{"Under 60", 0},
+}
+
+// unknownAgeBand holds seniors whose age is missing or not a number
+const unknownAgeBand = "Unknown"
+
+// RiskPeriodRate is the spread of risk levels in one month, with the share of all seniors who took part
+type RiskPeriodRate struct {
+	Period            string  `json:"period"` // YYYY-MM
+	Participants      int     `json:"participants"`
+	ParticipationRate float64 `json:"participation_rate"` // Participants as a percentage of all seniors
+	Low               int     `json:"low"`
+	Moderate          int     `json:"moderate"`
+	High              int     `json:"high"`
+}
+
+// RiskDistribution is the monthly spread of risk levels from each assessment
+type RiskDistribution struct {
+	FES            []RiskPeriodRate `json:"fes"`
+	SelfAssessment []RiskPeriodRate `json:"self_assessment"`
+}
+
+// MetricAverage is the average of every score for a metric within an age band
+type MetricAverage struct {
+	Metric  string  `json:"metric"`
+	Count   int     `json:"count"`
+	Average float64 `json:"average"`
+}
+
+// AgeBandScores is the average of each FES and self-assessment metric for the seniors in an age band
+type AgeBandScores struct {
+	AgeBand  string          `json:"age_band"`
+	Seniors  int             `json:"seniors"`
+	Averages []MetricAverage `json:"averages"`
+}
+
+// AnalyticsResponse is the cohort view of the FES and self-assessment results between From and To,
+// with an entry in Errors for every source that failed
+type AnalyticsResponse struct {
+	From             string              `json:"from"`
+	To               string              `json:"to"`
+	TotalSeniors     int                 `json:"total_seniors"`
+	RiskDistribution RiskDistribution    `json:"risk_distribution"`
+	ScoresByAgeBand  []AgeBandScores     `json:"scores_by_age_band"`
+	FESItems         []fes.ItemBreakdown `json:"fes_items"`
+	Errors           map[string]string   `json:"errors"`
+}
+
+// GetAnalytics returns cohort analytics between from and to (YYYY-MM-DD, inclusive, default the last 12 months):
+// the monthly spread of risk levels and participation, average scores by age band and test, and how every FES
+// question was answered. The figures are computed in SQL by each service and combined here.
+// A source that fails is reported in errors instead of failing the request.
+func (h *Handler) GetAnalytics(w http.ResponseWriter, r *http.Request) {
+	from, to, ok := analyticsRange(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(client.FromRequest(r), dashboardTimeout)
+	defer cancel()
+
+	var (
+		users           []user.User
+		fesDistribution []fes.RiskPeriod
+		faDistribution  []selfassessment.RiskPeriod
+		fesItems        []fes.ItemBreakdown
+		fesTotals       []fes.UserScoreTotal
+		faTotals        []selfassessment.UserScoreTotal
+	)
+	fetches := map[string]func() error{
+		sourceUsers:               func() (err error) { users, err = h.clients.User.AllUsers(ctx); return },
+		sourceFESRiskDistribution: func() (err error) { fesDistribution, err = h.clients.FES.RiskDistribution(ctx, from, to); return },
+		sourceFARiskDistribution: func() (err error) {
+			faDistribution, err = h.clients.SelfAssessment.RiskDistribution(ctx, from, to)
+			return
+		},
+		sourceFESItems:       func() (err error) { fesItems, err = h.clients.FES.ItemBreakdown(ctx, from, to); return },
+		sourceFESScoreTotals: func() (err error) { fesTotals, err = h.clients.FES.UserScoreTotals(ctx, from, to); return },
+		sourceFAScoreTotals:  func() (err error) { faTotals, err = h.clients.SelfAssessment.UserScoreTotals(ctx, from, to); return },
+	}
+	errs := fanOut(ctx, fetches)
+
+	response := AnalyticsResponse{
+		From:         from,
+		To:           to,
+		TotalSeniors: len(users),
+		RiskDistribution: RiskDistribution{
+			FES:            []RiskPeriodRate{},
+			SelfAssessment: []RiskPeriodRate{},
+		},
+		FESItems: []fes.ItemBreakdown{},
+		Errors:   errs,
+	}
+	for _, period := range fesDistribution {
+		response.RiskDistribution.FES = append(response.RiskDistribution.FES,
+			newRiskPeriodRate(period.Period, period.Participants, period.Low, period.Moderate, period.High, len(users)))
+	}
+	for _, period := range faDistribution {
+		response.RiskDistribution.SelfAssessment = append(response.RiskDistribution.SelfAssessment,
+			newRiskPeriodRate(period.Period, period.Participants, period.Low, period.Moderate, period.High, len(users)))
+	}
+	if fesItems != nil {
+		response.FESItems = fesItems
+	}
+
+	// Totals are summed per band and metric so each average weighs every score equally
+	totals := make([]scoreTotal, 0, len(fesTotals)+len(faTotals))
+	for _, total := range fesTotals {
+		totals = append(totals, scoreTotal{total.UserID, total.Metric, total.Count, total.Sum})
+	}
+	for _, total := range faTotals {
+		totals = append(totals, scoreTotal{total.UserID, total.Metric, total.Count, total.Sum})
+	}
+	response.ScoresByAgeBand = scoresByAgeBand(users, totals)
+
+	if len(errs) == len(fetches) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	writeJSON(w, response)
+}
+
+// newRiskPeriodRate adds the participation rate to a month's risk levels
+func newRiskPeriodRate(period string, participants, low, moderate, high, totalSeniors int) RiskPeriodRate {
+	rate := RiskPeriodRate{Period: period, Participants: participants, Low: low, Moderate: moderate, High: high}
+	if totalSeniors > 0 {
+		rate.ParticipationRate = math.Round(float64(participants)/float64(totalSeniors)*1000) / 10
+	}
+	return rate
+}
+
+// scoreTotal is the count and sum of one senior's scores for a metric, from either assessment
+type scoreTotal struct {
+	UserID int
+	Metric string
+	Count  int
+	Sum    float64
+}
+
+// ageBand returns the label of the band an age falls in
+func ageBand(age string) string {
+	years, err := strconv.Atoi(age)
+	if err != nil || years < 0 {
+		return unknownAgeBand
+	}
+	for _, band := range ageBands {
+		if years >= band.MinAge {
+			return band.Label
+		}
+	}
+	return unknownAgeBand
+}
+
+// scoresByAgeBand averages each metric over the seniors in every age band, oldest band first.
+// Seniors with scores but no profile are counted as unknown age.
+func scoresByAgeBand(users []user.User, totals []scoreTotal) []AgeBandScores {
+	bandOf := map[int]string{}
+	seniors := map[string]int{}
+	for _, u := range users {
+		bandOf[u.UserID] = ageBand(u.Age)
+		seniors[bandOf[u.UserID]]++
+	}
+
+	type sum struct {
+		count int
+		total float64
+	}
+	sums := map[string]map[string]*sum{}
+	for _, total := range totals {
+		band, ok := bandOf[total.UserID]
+		if !ok {
+			band = unknownAgeBand
+		}
+		if sums[band] == nil {
+			sums[band] = map[string]*sum{}
+		}
+		if sums[band][total.Metric] == nil {
+			sums[band][total.Metric] = &sum{}
+		}
+		sums[band][total.Metric].count += total.Count
+		sums[band][total.Metric].total += total.Sum
+	}
+
+	labels := make([]string, 0, len(ageBands)+1)
+	for _, band := range ageBands {
+		labels = append(labels, band.Label)
+	}
+	labels = append(labels, unknownAgeBand)
+
+	scores := []AgeBandScores{}
+	for _, label := range labels {
+		if seniors[label] == 0 && len(sums[label]) == 0 {
+			continue
+		}
+		band := AgeBandScores{AgeBand: label, Seniors: seniors[label], Averages: []MetricAverage{}}
+		for metric, s := range sums[label] {
+			if s.count == 0 {
+				continue
+			}
+			band.Averages = append(band.Averages, MetricAverage{
+				Metric:  metric,
+				Count:   s.count,
+				Average: math.Round(s.total/float64(s.count)*100) / 100,
+			})
+		}
+		sort.Slice(band.Averages, func(i, j int) bool { return band.Averages[i].Metric < band.Averages[j].Metric })
+		scores = append(scores, band)
+	}
+	return scores
+}
+
+// analyticsRange reads the from and to dates (YYYY-MM-DD, both inclusive), defaulting to the last 12 months,
+// writing an error response and returning false if a date is invalid
+func analyticsRange(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	to := time.Now()
+	if value := r.URL.Query().Get("to"); value != "" {
+		parsed, err := time.Parse(analyticsDateLayout, value)
+		if err != nil {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidField, "Invalid to date, expected YYYY-MM-DD")
+			return "", "", false
+		}
+		to = parsed
+	}
+	from := to.AddDate(-1, 0, 0)
+	if value := r.URL.Query().Get("from"); value != "" {
+		parsed, err := time.Parse(analyticsDateLayout, value)
+		if err != nil {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidField, "Invalid from date, expected YYYY-MM-DD")
+			return "", "", false
+		}
+		from = parsed
+	}
+	if from.After(to) {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidField, "from must not be after to")
+		return "", "", false
+	}
+	return from.Format(analyticsDateLayout), to.Format(analyticsDateLayout), true
+}
@@ -0,0 +1,226 @@
+package admin
+
+import (
+	"adminMicroservice/client/fes"
+	"adminMicroservice/client/selfassessment"
+	"adminMicroservice/client/user"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"shared/client"
+)
+
+// dashboardTimeout bounds the whole fan-out, so one slow service cannot hold up the roster
+const dashboardTimeout = 15 * time.Second
+
+// Dashboard sources, used as the keys of the errors field
+const (
+	sourceUsers           = "users"
+	sourceFESRisk         = "fes_risk"
+	sourceFARisk          = "fa_risk"
+	sourceFESLastResponse = "fes_last_response"
+	sourceFALastResponse  = "fa_last_response"
+	sourceFESScores       = "fes_scores"
+	sourceFAScores        = "fa_scores"
+	sourceCombinedRisk    = "combined_risk"
+)
+
+// DashboardSenior is one senior's row on the admin dashboard.
+// Fields are null when the senior has no data or the source could not be fetched.
+type DashboardSenior struct {
+	UserID           int                `json:"user_id"`
+	Name             string             `json:"name"`
+	Email            string             `json:"email"`
+	Age              string             `json:"age"`
+	FESRiskLevel     *string            `json:"fes_risk_level"`
+	FARiskLevel      *string            `json:"fa_risk_level"`
+	DaysSinceLastFES *int               `json:"days_since_last_fes"`
+	DaysSinceLastFA  *int               `json:"days_since_last_fa"`
+	LatestFESScore   *uint16            `json:"latest_fes_score"`
+	LatestFESDate    *time.Time         `json:"latest_fes_date"`
+	LatestFAScore    *int16             `json:"latest_fa_score"`
+	LatestFADate     *time.Time         `json:"latest_fa_date"`
+	CombinedRisk     *user.CombinedRisk `json:"combined_risk"`
+}
+
+// DashboardResponse is the combined roster, with an entry in Errors for every source that failed
+type DashboardResponse struct {
+	Seniors []*DashboardSenior `json:"seniors"`
+	Errors  map[string]string  `json:"errors"`
+}
+
+// GetDashboard fetches every dashboard source concurrently and joins them per senior.
+// A source that fails leaves its fields null and is reported in errors instead of failing the request.
+func (h *Handler) GetDashboard(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(client.FromRequest(r), dashboardTimeout)
+	defer cancel()
+
+	var (
+		users           []user.User
+		fesRisk         []fes.RiskLevel
+		faRisk          []selfassessment.UserRisk
+		fesLastResponse []fes.LastResponseDay
+		faLastResponse  []selfassessment.LastResponseDay
+		fesScores       []fes.Response
+		faScores        []selfassessment.SessionScore
+		combinedRisk    []user.CombinedRisk
+	)
+	fetches := map[string]func() error{
+		sourceUsers:           func() (err error) { users, err = h.clients.User.AllUsers(ctx); return },
+		sourceFESRisk:         func() (err error) { fesRisk, err = h.clients.FES.LatestRisk(ctx); return },
+		sourceFARisk:          func() (err error) { faRisk, err = h.clients.SelfAssessment.UserRisks(ctx); return },
+		sourceFESLastResponse: func() (err error) { fesLastResponse, err = h.clients.FES.LastResponseDays(ctx); return },
+		sourceFALastResponse:  func() (err error) { faLastResponse, err = h.clients.SelfAssessment.LastResponseDays(ctx); return },
+		sourceFESScores:       func() (err error) { fesScores, err = h.clients.FES.LatestResponses(ctx); return },
+		sourceFAScores:        func() (err error) { faScores, err = h.clients.SelfAssessment.LatestSessionScores(ctx); return },
+		sourceCombinedRisk:    func() (err error) { combinedRisk, err = h.clients.User.CombinedRisks(ctx); return },
+	}
+	errs := fanOut(ctx, fetches)
+
+	if len(errs) == len(fetches) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(DashboardResponse{Seniors: []*DashboardSenior{}, Errors: errs})
+		return
+	}
+
+	// Start the roster from the user microservice. If it is down, fall back to every
+	// senior the other sources mention so the clinical data is still shown.
+	rows := newRoster()
+	for _, u := range users {
+		senior := rows.get(u.UserID)
+		senior.Name, senior.Email, senior.Age = u.Name, u.Email, u.Age
+	}
+	_, usersFailed := errs[sourceUsers]
+	lookup := func(userID int) *DashboardSenior {
+		if usersFailed {
+			return rows.get(userID)
+		}
+		return rows.seniors[userID]
+	}
+
+	for _, risk := range fesRisk {
+		if senior := lookup(risk.UserID); senior != nil {
+			level := risk.RiskLevel
+			senior.FESRiskLevel = &level
+		}
+	}
+	for _, risk := range faRisk {
+		if senior := lookup(risk.UserID); senior != nil {
+			level := risk.OverallRiskLevel
+			senior.FARiskLevel = &level
+		}
+	}
+	for _, last := range fesLastResponse {
+		if senior := lookup(last.UserID); senior != nil {
+			days := last.DaysSinceResponse
+			senior.DaysSinceLastFES = &days
+		}
+	}
+	for _, last := range faLastResponse {
+		if senior := lookup(last.UserID); senior != nil {
+			days := last.DaysSinceResponse
+			senior.DaysSinceLastFA = &days
+		}
+	}
+	for _, response := range fesScores {
+		if senior := lookup(int(response.UserID)); senior != nil {
+			score, date := response.TotalScore, response.ResponseDate
+			senior.LatestFESScore, senior.LatestFESDate = &score, &date
+		}
+	}
+	for _, session := range faScores {
+		if senior := lookup(session.UserID); senior != nil {
+			score, date := session.TotalScore, session.SessionDate
+			senior.LatestFAScore, senior.LatestFADate = &score, &date
+		}
+	}
+	for _, risk := range combinedRisk {
+		if senior := lookup(risk.UserID); senior != nil {
+			senior.CombinedRisk = &risk
+		}
+	}
+
+	seniors := rows.list()
+	if usersFailed {
+		sort.Slice(seniors, func(i, j int) bool { return seniors[i].UserID < seniors[j].UserID })
+	}
+	writeJSON(w, DashboardResponse{Seniors: seniors, Errors: errs})
+}
+
+// fanOut runs the fetches concurrently and returns a description of each failure, keyed by source.
+// Failures are logged with the request ID ctx carries.
+func fanOut(ctx context.Context, fetches map[string]func() error) map[string]string {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	errs := map[string]string{}
+
+	for source, fetch := range fetches {
+		wg.Add(1)
+		go func(source string, fetch func() error) {
+			defer wg.Done()
+			if err := fetch(); err != nil {
+				slog.ErrorContext(ctx, "Error fetching dashboard source", "source", source, "error", err)
+				mu.Lock()
+				errs[source] = describeSourceError(err)
+				mu.Unlock()
+			}
+		}(source, fetch)
+	}
+
+	wg.Wait()
+	return errs
+}
+
+// describeSourceError turns a client error into a short message for the dashboard
+func describeSourceError(err error) string {
+	switch status := client.StatusCode(err); {
+	case status == http.StatusForbidden:
+		return "Not permitted for your admin role"
+	case status != 0:
+		return fmt.Sprintf("Service returned %d", status)
+	case errors.Is(err, client.ErrCircuitOpen):
+		return "Service temporarily unavailable"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "Service timed out"
+	default:
+		return "Service unavailable"
+	}
+}
+
+// roster keeps dashboard rows in the order seniors were first seen
+type roster struct {
+	seniors map[int]*DashboardSenior
+	order   []int
+}
+
+func newRoster() *roster {
+	return &roster{seniors: map[int]*DashboardSenior{}}
+}
+
+// get returns a senior's row, adding it if the senior has not been seen yet
+func (r *roster) get(userID int) *DashboardSenior {
+	senior, ok := r.seniors[userID]
+	if !ok {
+		senior = &DashboardSenior{UserID: userID}
+		r.seniors[userID] = senior
+		r.order = append(r.order, userID)
+	}
+	return senior
+}
+
+// list returns the rows in order
+func (r *roster) list() []*DashboardSenior {
+	seniors := make([]*DashboardSenior, 0, len(r.order))
+	for _, userID := range r.order {
+		seniors = append(seniors, r.seniors[userID])
+	}
+	return seniors
+}
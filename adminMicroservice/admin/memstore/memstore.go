@@ -0,0 +1,472 @@
+// Package memstore keeps the admin accounts and referrals in memory, for testing the admin handlers
+// with httptest without a database:
+//
+//	store := memstore.New()
+//	clinicianID := store.AddAdmin("Dr Lim", "lim@example.com", admin.RoleClinician, admin.StatusActive)
+//	handler := admin.NewHandler(store, admin.Clients{Auth: &memstore.AuthRecorder{}})
+//	handler.OpenReferral(recorder, request)
+package memstore
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"adminMicroservice/admin"
+
+	"shared/pagination"
+)
+
+// Store is an admin.AdminStore held in memory. It enforces the unique emails and the one unclosed
+// case per senior the database does, and measures SLA timers with its own clock.
+type Store struct {
+	mu        sync.Mutex
+	admins    []admin.Admin
+	referrals []admin.Referral
+	notes     []note
+
+	// Now is the clock cases and notes are dated with, time.Now unless a test replaces it
+	Now func() time.Time
+}
+
+type note struct {
+	referralID int
+	admin.ReferralNote
+}
+
+// referralStatuses are the referral statuses in the order MySQL sorts the ENUM column
+var referralStatuses = []string{admin.ReferralOpen, admin.ReferralContacted, admin.ReferralScheduled, admin.ReferralAssessed, admin.ReferralClosed}
+
+// assigneeRoles are the roles that may be assigned new cases, in order of preference
+var assigneeRoles = []string{admin.RoleClinician, admin.RoleCoordinator, admin.RoleSuperAdmin}
+
+var _ admin.AdminStore = (*Store)(nil)
+
+// New returns an empty store
+func New() *Store {
+	return &Store{Now: time.Now}
+}
+
+// AddAdmin adds an admin account, returning its ID. It is for arranging the accounts a test reads,
+// and panics if the email is already in use.
+func (s *Store) AddAdmin(name, email, adminRole, status string) int {
+	adminID, err := s.CreateAdmin(context.Background(), name, email, adminRole, status)
+	if err != nil {
+		panic(err)
+	}
+	return adminID
+}
+
+func (s *Store) Admin(ctx context.Context, adminID int) (admin.Admin, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found := s.admin(adminID)
+	if found == nil {
+		return admin.Admin{}, admin.ErrNotFound
+	}
+	return *found, nil
+}
+
+// admin returns the account with the ID, or nil if there is none. The caller holds the lock.
+func (s *Store) admin(adminID int) *admin.Admin {
+	for i := range s.admins {
+		if s.admins[i].UserID == adminID {
+			return &s.admins[i]
+		}
+	}
+	return nil
+}
+
+func (s *Store) Admins(ctx context.Context, page *pagination.Query) ([]admin.AdminAccount, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sorted := slices.Clone(s.admins)
+	slices.SortStableFunc(sorted, func(a, b admin.Admin) int {
+		var c int
+		switch page.Sort {
+		case "name":
+			c = strings.Compare(a.Name, b.Name)
+		case "email":
+			c = strings.Compare(a.Email, b.Email)
+		case "admin_role":
+			c = strings.Compare(a.AdminRole, b.AdminRole)
+		case "status":
+			c = strings.Compare(a.Status, b.Status)
+		}
+		return direction(page, cmp.Or(c, cmp.Compare(a.UserID, b.UserID)))
+	})
+
+	accounts := []admin.AdminAccount{}
+	for _, found := range pagination.Page(page, sorted) {
+		accounts = append(accounts, admin.AdminAccount{
+			UserID:    found.UserID,
+			Name:      found.Name,
+			Email:     found.Email,
+			AdminRole: found.AdminRole,
+			Status:    found.Status,
+		})
+	}
+	return accounts, len(sorted), nil
+}
+
+func (s *Store) CreateAdmin(ctx context.Context, name, email, adminRole, status string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, found := range s.admins {
+		if strings.EqualFold(found.Email, email) {
+			return 0, fmt.Errorf("email %s is already in use", email)
+		}
+	}
+	adminID := 1
+	if len(s.admins) > 0 {
+		adminID = s.admins[len(s.admins)-1].UserID + 1
+	}
+	s.admins = append(s.admins, admin.Admin{UserID: adminID, Name: name, Email: email, Role: "Admin", AdminRole: adminRole, Status: status})
+	return adminID, nil
+}
+
+func (s *Store) DeleteAdmin(ctx context.Context, adminID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.admins = slices.DeleteFunc(s.admins, func(found admin.Admin) bool { return found.UserID == adminID })
+	// References to the admin are set to NULL, as the foreign keys do
+	for i := range s.referrals {
+		if id := s.referrals[i].AssignedAdminID; id != nil && *id == adminID {
+			s.referrals[i].AssignedAdminID = nil
+		}
+	}
+	for i := range s.notes {
+		if id := s.notes[i].AdminID; id != nil && *id == adminID {
+			s.notes[i].AdminID = nil
+		}
+	}
+	return nil
+}
+
+func (s *Store) SetAdminStatus(ctx context.Context, adminID int, status string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found := s.admin(adminID)
+	if found == nil {
+		return false, nil
+	}
+	found.Status = status
+	return true, nil
+}
+
+func (s *Store) ActivateAdmin(ctx context.Context, adminID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if found := s.admin(adminID); found != nil && found.Status == admin.StatusInvited {
+		found.Status = admin.StatusActive
+	}
+	return nil
+}
+
+func (s *Store) SetAdminRole(ctx context.Context, adminID int, adminRole string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found := s.admin(adminID)
+	if found == nil {
+		return false, nil
+	}
+	found.AdminRole = adminRole
+	return true, nil
+}
+
+func (s *Store) ActiveSuperAdminsExcept(ctx context.Context, adminID int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	others := 0
+	for _, found := range s.admins {
+		if found.AdminRole == admin.RoleSuperAdmin && found.Status == admin.StatusActive && found.UserID != adminID {
+			others++
+		}
+	}
+	return others, nil
+}
+
+func (s *Store) ReferralAssignee(ctx context.Context) (*int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	type candidate struct {
+		adminID, rank, cases int
+	}
+	var candidates []candidate
+	for _, found := range s.admins {
+		rank := slices.Index(assigneeRoles, found.AdminRole)
+		if found.Status != admin.StatusActive || rank < 0 {
+			continue
+		}
+		cases := 0
+		for _, referral := range s.referrals {
+			if referral.AssignedAdminID != nil && *referral.AssignedAdminID == found.UserID && referral.Status != admin.ReferralClosed {
+				cases++
+			}
+		}
+		candidates = append(candidates, candidate{found.UserID, rank, cases})
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	best := slices.MinFunc(candidates, func(a, b candidate) int {
+		return cmp.Or(cmp.Compare(a.rank, b.rank), cmp.Compare(a.cases, b.cases), cmp.Compare(a.adminID, b.adminID))
+	})
+	return &best.adminID, nil
+}
+
+func (s *Store) OpenReferral(ctx context.Context, opened admin.NewReferral) (int, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, found := range s.referrals {
+		if found.SeniorUserID == opened.SeniorUserID && found.Status != admin.ReferralClosed {
+			return found.ReferralID, false, nil
+		}
+	}
+	if opened.AssignedAdminID != nil && s.admin(*opened.AssignedAdminID) == nil {
+		return 0, false, fmt.Errorf("admin %d does not exist", *opened.AssignedAdminID)
+	}
+
+	now := s.now()
+	referral := admin.Referral{
+		ReferralID:      len(s.referrals) + 1,
+		SeniorUserID:    opened.SeniorUserID,
+		Source:          opened.Source,
+		Reason:          opened.Reason,
+		Status:          admin.ReferralOpen,
+		AssignedAdminID: opened.AssignedAdminID,
+		OpenedAt:        now,
+		StatusChangedAt: now,
+	}
+	s.referrals = append(s.referrals, referral)
+	return referral.ReferralID, true, nil
+}
+
+func (s *Store) Referrals(ctx context.Context, page *pagination.Query, filter admin.ReferralFilter) ([]*admin.Referral, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var matching []*admin.Referral
+	for _, found := range s.referrals {
+		switch {
+		case !page.Matches(found.OpenedAt, found.SeniorUserID):
+		case filter.Status != "" && found.Status != filter.Status:
+		case filter.Status == "" && found.Status == admin.ReferralClosed:
+		case filter.AssignedTo != nil && (found.AssignedAdminID == nil || *found.AssignedAdminID != *filter.AssignedTo):
+		default:
+			matching = append(matching, s.withTimer(found))
+		}
+	}
+	slices.SortStableFunc(matching, func(a, b *admin.Referral) int {
+		var c int
+		switch page.Sort {
+		case "sla":
+			c = cmp.Compare(slaOrLast(a), slaOrLast(b))
+		case "opened_at":
+			c = a.OpenedAt.Compare(b.OpenedAt)
+		case "status":
+			c = cmp.Compare(slices.Index(referralStatuses, a.Status), slices.Index(referralStatuses, b.Status))
+		}
+		return direction(page, cmp.Or(c, cmp.Compare(a.ReferralID, b.ReferralID)))
+	})
+	return pagination.Page(page, matching), len(matching), nil
+}
+
+// slaOrLast returns the seconds left on a case's timer, sorting closed cases after every open one as the SQL does
+func slaOrLast(referral *admin.Referral) int64 {
+	if referral.SLARemaining == nil {
+		return math.MaxInt32
+	}
+	return *referral.SLARemaining
+}
+
+func (s *Store) Referral(ctx context.Context, referralID int) (*admin.Referral, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found := s.referral(referralID)
+	if found == nil {
+		return nil, admin.ErrNotFound
+	}
+	return s.withTimer(*found), nil
+}
+
+// referral returns the case with the ID, or nil if there is none. The caller holds the lock.
+func (s *Store) referral(referralID int) *admin.Referral {
+	for i := range s.referrals {
+		if s.referrals[i].ReferralID == referralID {
+			return &s.referrals[i]
+		}
+	}
+	return nil
+}
+
+// withTimer returns a copy of a case with the assignee's name and its SLA timer. The caller holds the lock.
+func (s *Store) withTimer(referral admin.Referral) *admin.Referral {
+	if referral.AssignedAdminID != nil {
+		if assignee := s.admin(*referral.AssignedAdminID); assignee != nil {
+			referral.AssignedAdminName = assignee.Name
+		}
+	}
+	referral.SetSLA(int64(s.now().Sub(referral.StatusChangedAt).Seconds()))
+	return &referral
+}
+
+func (s *Store) ReferralNotes(ctx context.Context, referralID int) ([]admin.ReferralNote, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	notes := []admin.ReferralNote{}
+	for _, found := range s.notes {
+		if found.referralID != referralID {
+			continue
+		}
+		found.AdminName = "System"
+		if found.AdminID != nil {
+			if author := s.admin(*found.AdminID); author != nil {
+				found.AdminName = author.Name
+			}
+		}
+		notes = append(notes, found.ReferralNote)
+	}
+	return notes, nil
+}
+
+func (s *Store) SetReferralStatus(ctx context.Context, referralID int, from, to string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found := s.referral(referralID)
+	if found == nil || found.Status != from {
+		return false, nil
+	}
+	if to != admin.ReferralClosed {
+		for _, other := range s.referrals {
+			if other.ReferralID != referralID && other.SeniorUserID == found.SeniorUserID && other.Status != admin.ReferralClosed {
+				return false, fmt.Errorf("senior %d already has an unclosed referral", found.SeniorUserID)
+			}
+		}
+	}
+
+	now := s.now()
+	found.Status = to
+	found.StatusChangedAt = now
+	found.ClosedAt = nil
+	if to == admin.ReferralClosed {
+		found.ClosedAt = &now
+	}
+	return true, nil
+}
+
+func (s *Store) AssignReferral(ctx context.Context, referralID, adminID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.admin(adminID) == nil {
+		return fmt.Errorf("admin %d does not exist", adminID)
+	}
+	if found := s.referral(referralID); found != nil {
+		found.AssignedAdminID = &adminID
+	}
+	return nil
+}
+
+func (s *Store) AddReferralNote(ctx context.Context, referralID int, adminID *int, status, text string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.referral(referralID) == nil {
+		return fmt.Errorf("referral %d does not exist", referralID)
+	}
+	if adminID != nil && s.admin(*adminID) == nil {
+		return fmt.Errorf("admin %d does not exist", *adminID)
+	}
+	s.notes = append(s.notes, note{
+		referralID: referralID,
+		ReferralNote: admin.ReferralNote{
+			NoteID:    len(s.notes) + 1,
+			AdminID:   adminID,
+			Status:    status,
+			Note:      text,
+			CreatedAt: s.now(),
+		},
+	})
+	return nil
+}
+
+// now returns the time on the store's clock, to the second as the TIMESTAMP columns keep it
+func (s *Store) now() time.Time {
+	return s.Now().UTC().Truncate(time.Second)
+}
+
+// direction applies the sort direction of the page to a comparison
+func direction(page *pagination.Query, c int) int {
+	if page.Descending {
+		return -c
+	}
+	return c
+}
+
+// AuthCall is a call made to an AuthRecorder
+type AuthCall struct {
+	Method  string
+	AdminID int
+	Email   string
+}
+
+// AuthRecorder is an admin.AuthClient that keeps the calls made to it, failing them with Err if it is set
+type AuthRecorder struct {
+	mu    sync.Mutex
+	calls []AuthCall
+
+	Err error
+}
+
+var _ admin.AuthClient = (*AuthRecorder)(nil)
+
+func (a *AuthRecorder) InviteAdmin(ctx context.Context, adminID int, email string) error {
+	return a.record(AuthCall{Method: "InviteAdmin", AdminID: adminID, Email: email})
+}
+
+func (a *AuthRecorder) DeactivateAdmin(ctx context.Context, adminID int) error {
+	return a.record(AuthCall{Method: "DeactivateAdmin", AdminID: adminID})
+}
+
+func (a *AuthRecorder) ResetAdmin(ctx context.Context, adminID int) error {
+	return a.record(AuthCall{Method: "ResetAdmin", AdminID: adminID})
+}
+
+func (a *AuthRecorder) ResetAdminMFA(ctx context.Context, adminID int) error {
+	return a.record(AuthCall{Method: "ResetAdminMFA", AdminID: adminID})
+}
+
+// record keeps a call and returns the error it fails with
+func (a *AuthRecorder) record(call AuthCall) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.calls = append(a.calls, call)
+	return a.Err
+}
+
+// Calls returns the calls made so far
+func (a *AuthRecorder) Calls() []AuthCall {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return slices.Clone(a.calls)
+}
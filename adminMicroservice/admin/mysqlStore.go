@@ -0,0 +1,331 @@
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"shared/observability"
+	"shared/pagination"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// sqlDateLayout is the format of dates cast to text by MySQL
+const sqlDateLayout = "2006-01-02 15:04:05"
+
+// OpenDB connects to the database named by a DSN and checks the connection
+func OpenDB(dsn string) (*sql.DB, error) {
+	if dsn == "" {
+		return nil, fmt.Errorf("ADMIN_DB_CONNECTION environment variable is not set")
+	}
+
+	slog.Debug("Initializing database connection")
+	db, err := observability.OpenDB("mysql", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to database: %v", err)
+	}
+
+	// Test the database connection
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("database connection test failed: %v", err)
+	}
+	slog.Info("Database connection successful")
+	return db, nil
+}
+
+// MySQLStore is the AdminStore kept in the admin database
+type MySQLStore struct {
+	db *sql.DB
+}
+
+// NewMySQLStore returns a store reading and writing the given database
+func NewMySQLStore(db *sql.DB) *MySQLStore {
+	return &MySQLStore{db: db}
+}
+
+func (s *MySQLStore) Admin(ctx context.Context, adminID int) (Admin, error) {
+	var admin Admin
+	err := s.db.QueryRowContext(ctx, `
+		SELECT user_id, name, email, role, admin_role, status
+		FROM User
+		WHERE user_id = ?`, adminID).Scan(
+		&admin.UserID, &admin.Name, &admin.Email, &admin.Role, &admin.AdminRole, &admin.Status,
+	)
+	if err == sql.ErrNoRows {
+		return admin, ErrNotFound
+	}
+	return admin, err
+}
+
+func (s *MySQLStore) Admins(ctx context.Context, page *pagination.Query) ([]AdminAccount, int, error) {
+	var total int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM User`).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("error counting admin accounts: %v", err)
+	}
+
+	clauses, args := page.Page()
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT user_id, name, email, admin_role, status`+page.KeyColumns()+`
+		FROM User`+clauses, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	accounts := []AdminAccount{}
+	for rows.Next() {
+		var account AdminAccount
+		if err := rows.Scan(append([]interface{}{&account.UserID, &account.Name, &account.Email, &account.AdminRole, &account.Status}, page.Key()...)...); err != nil {
+			return nil, 0, err
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, total, rows.Err()
+}
+
+func (s *MySQLStore) CreateAdmin(ctx context.Context, name, email, adminRole, status string) (int, error) {
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO User (name, email, role, admin_role, status)
+		VALUES (?, ?, 'Admin', ?, ?)`,
+		name, email, adminRole, status)
+	if err != nil {
+		return 0, err
+	}
+	adminID, err := result.LastInsertId()
+	return int(adminID), err
+}
+
+func (s *MySQLStore) DeleteAdmin(ctx context.Context, adminID int) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM User WHERE user_id = ?`, adminID)
+	return err
+}
+
+func (s *MySQLStore) SetAdminStatus(ctx context.Context, adminID int, status string) (bool, error) {
+	return s.updateAdmin(ctx, `UPDATE User SET status = ? WHERE user_id = ?`, status, adminID)
+}
+
+func (s *MySQLStore) ActivateAdmin(ctx context.Context, adminID int) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE User SET status = ? WHERE user_id = ? AND status = ?`, StatusActive, adminID, StatusInvited)
+	return err
+}
+
+func (s *MySQLStore) SetAdminRole(ctx context.Context, adminID int, adminRole string) (bool, error) {
+	return s.updateAdmin(ctx, `UPDATE User SET admin_role = ? WHERE user_id = ?`, adminRole, adminID)
+}
+
+// updateAdmin runs an update of one admin, reporting whether the admin exists.
+// MySQL does not count a row as affected if the update leaves it unchanged, so that case is looked up.
+func (s *MySQLStore) updateAdmin(ctx context.Context, query string, value interface{}, adminID int) (bool, error) {
+	result, err := s.db.ExecContext(ctx, query, value, adminID)
+	if err != nil {
+		return false, err
+	}
+	if affected, _ := result.RowsAffected(); affected > 0 {
+		return true, nil
+	}
+
+	var exists bool
+	err = s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM User WHERE user_id = ?)`, adminID).Scan(&exists)
+	return exists, err
+}
+
+func (s *MySQLStore) ActiveSuperAdminsExcept(ctx context.Context, adminID int) (int, error) {
+	var others int
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM User
+		WHERE admin_role = ? AND status = ? AND user_id <> ?`,
+		RoleSuperAdmin, StatusActive, adminID).Scan(&others)
+	return others, err
+}
+
+func (s *MySQLStore) ReferralAssignee(ctx context.Context) (*int, error) {
+	var adminID int
+	err := s.db.QueryRowContext(ctx, `
+		SELECT u.user_id
+		FROM User u
+		LEFT JOIN Referral rf ON rf.assigned_admin_id = u.user_id AND rf.status <> ?
+		WHERE u.status = ? AND u.admin_role IN (?, ?, ?)
+		GROUP BY u.user_id, u.admin_role
+		ORDER BY FIELD(u.admin_role, ?, ?, ?), COUNT(rf.referral_id), u.user_id
+		LIMIT 1`,
+		ReferralClosed, StatusActive, RoleClinician, RoleCoordinator, RoleSuperAdmin,
+		RoleClinician, RoleCoordinator, RoleSuperAdmin).Scan(&adminID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &adminID, nil
+}
+
+func (s *MySQLStore) OpenReferral(ctx context.Context, referral NewReferral) (int, bool, error) {
+	// The unique key on open_senior_user_id turns a second open case into a no-op
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO Referral (senior_user_id, source, reason, assigned_admin_id)
+		VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE referral_id = referral_id`,
+		referral.SeniorUserID, referral.Source, referral.Reason, referral.AssignedAdminID)
+	if err != nil {
+		return 0, false, err
+	}
+
+	var referralID int
+	err = s.db.QueryRowContext(ctx, `SELECT referral_id FROM Referral WHERE open_senior_user_id = ?`, referral.SeniorUserID).Scan(&referralID)
+	if err != nil {
+		return 0, false, fmt.Errorf("error finding open referral: %v", err)
+	}
+	created, _ := result.RowsAffected()
+	return referralID, created == 1, nil
+}
+
+func (s *MySQLStore) Referrals(ctx context.Context, page *pagination.Query, filter ReferralFilter) ([]*Referral, int, error) {
+	if filter.Status != "" {
+		page.Filter("rf.status = ?", filter.Status)
+	} else {
+		page.Filter("rf.status <> ?", ReferralClosed)
+	}
+	if filter.AssignedTo != nil {
+		page.Filter("rf.assigned_admin_id = ?", *filter.AssignedTo)
+	}
+	where, args := page.Where()
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM Referral rf`+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("error counting referrals: %v", err)
+	}
+
+	clauses, pageArgs := page.Page()
+	referrals, err := s.referrals(ctx, page, clauses, pageArgs...)
+	return referrals, total, err
+}
+
+func (s *MySQLStore) Referral(ctx context.Context, referralID int) (*Referral, error) {
+	referrals, err := s.referrals(ctx, nil, " WHERE rf.referral_id = ?", referralID)
+	if err != nil {
+		return nil, err
+	}
+	if len(referrals) == 0 {
+		return nil, ErrNotFound
+	}
+	return referrals[0], nil
+}
+
+// referrals returns the referrals selected by the clauses that follow the FROM clause, with their SLA timers.
+// When they select a page, page's key is read along with them.
+func (s *MySQLStore) referrals(ctx context.Context, page *pagination.Query, clauses string, args ...interface{}) ([]*Referral, error) {
+	var keyColumns string
+	if page != nil {
+		keyColumns = page.KeyColumns()
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT rf.referral_id, rf.senior_user_id, rf.source, rf.reason, rf.status,
+		       rf.assigned_admin_id, COALESCE(u.name, ''),
+		       CAST(rf.opened_at AS CHAR), CAST(rf.status_changed_at AS CHAR), CAST(rf.closed_at AS CHAR),
+		       TIMESTAMPDIFF(SECOND, rf.status_changed_at, CURRENT_TIMESTAMP)`+keyColumns+`
+		FROM Referral rf
+		LEFT JOIN User u ON u.user_id = rf.assigned_admin_id`+clauses, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	referrals := []*Referral{}
+	for rows.Next() {
+		referral := &Referral{}
+		var assignedAdminID sql.NullInt64
+		var openedAt, statusChangedAt string
+		var closedAt sql.NullString
+		var elapsed int64
+		dest := []interface{}{
+			&referral.ReferralID, &referral.SeniorUserID, &referral.Source, &referral.Reason, &referral.Status,
+			&assignedAdminID, &referral.AssignedAdminName,
+			&openedAt, &statusChangedAt, &closedAt, &elapsed,
+		}
+		if page != nil {
+			dest = append(dest, page.Key()...)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		if assignedAdminID.Valid {
+			id := int(assignedAdminID.Int64)
+			referral.AssignedAdminID = &id
+		}
+		referral.OpenedAt, _ = time.Parse(sqlDateLayout, openedAt)
+		referral.StatusChangedAt, _ = time.Parse(sqlDateLayout, statusChangedAt)
+		if closedAt.Valid {
+			closed, err := time.Parse(sqlDateLayout, closedAt.String)
+			if err == nil {
+				referral.ClosedAt = &closed
+			}
+		}
+
+		// The elapsed time comes from the database so it is not skewed by the clocks differing
+		referral.SetSLA(elapsed)
+		referrals = append(referrals, referral)
+	}
+	return referrals, rows.Err()
+}
+
+func (s *MySQLStore) ReferralNotes(ctx context.Context, referralID int) ([]ReferralNote, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT n.note_id, n.admin_id, COALESCE(u.name, 'System'), n.status, n.note, CAST(n.created_at AS CHAR)
+		FROM ReferralNote n
+		LEFT JOIN User u ON u.user_id = n.admin_id
+		WHERE n.referral_id = ?
+		ORDER BY n.note_id`, referralID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notes := []ReferralNote{}
+	for rows.Next() {
+		var note ReferralNote
+		var adminID sql.NullInt64
+		var createdAt string
+		if err := rows.Scan(&note.NoteID, &adminID, &note.AdminName, &note.Status, &note.Note, &createdAt); err != nil {
+			return nil, err
+		}
+		if adminID.Valid {
+			id := int(adminID.Int64)
+			note.AdminID = &id
+		}
+		note.CreatedAt, _ = time.Parse(sqlDateLayout, createdAt)
+		notes = append(notes, note)
+	}
+	return notes, rows.Err()
+}
+
+func (s *MySQLStore) SetReferralStatus(ctx context.Context, referralID int, from, to string) (bool, error) {
+	// Guarded on the current status so a concurrent update cannot be overwritten
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE Referral
+		SET status = ?, status_changed_at = CURRENT_TIMESTAMP,
+			closed_at = IF(? = ?, CURRENT_TIMESTAMP, NULL)
+		WHERE referral_id = ? AND status = ?`,
+		to, to, ReferralClosed, referralID, from)
+	if err != nil {
+		return false, err
+	}
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+func (s *MySQLStore) AssignReferral(ctx context.Context, referralID, adminID int) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE Referral SET assigned_admin_id = ? WHERE referral_id = ?`, adminID, referralID)
+	return err
+}
+
+func (s *MySQLStore) AddReferralNote(ctx context.Context, referralID int, adminID *int, status, note string) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO ReferralNote (referral_id, admin_id, status, note)
+		VALUES (?, ?, ?, ?)`, referralID, adminID, status, note)
+	return err
+}
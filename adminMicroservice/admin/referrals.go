@@ -0,0 +1,436 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"shared/apierror"
+	"shared/client"
+	"shared/pagination"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Referral statuses, in the order a case moves through them
+const (
+	ReferralOpen      = "Open"
+	ReferralContacted = "Contacted"
+	ReferralScheduled = "Scheduled"
+	ReferralAssessed  = "Assessed"
+	ReferralClosed    = "Closed"
+)
+
+// referralOrder is the position of each status, a case may only move forward
+var referralOrder = map[string]int{
+	ReferralOpen:      0,
+	ReferralContacted: 1,
+	ReferralScheduled: 2,
+	ReferralAssessed:  3,
+	ReferralClosed:    4,
+}
+
+// ReferralSLA is how long a case may stay in each status before it is overdue
+var ReferralSLA = map[string]time.Duration{
+	ReferralOpen:      48 * time.Hour,      // Senior contacted within two days
+	ReferralContacted: 7 * 24 * time.Hour,  // Assessment scheduled within a week
+	ReferralScheduled: 14 * 24 * time.Hour, // Assessment done within two weeks
+	ReferralAssessed:  7 * 24 * time.Hour,  // Case closed within a week of the assessment
+}
+
+// Referral is a clinical referral case for a high-risk senior
+type Referral struct {
+	ReferralID        int            `json:"referral_id"`
+	SeniorUserID      int            `json:"senior_user_id"`
+	SeniorName        string         `json:"senior_name"`
+	Source            string         `json:"source"`
+	Reason            string         `json:"reason"`
+	Status            string         `json:"status"`
+	AssignedAdminID   *int           `json:"assigned_admin_id"`
+	AssignedAdminName string         `json:"assigned_admin_name"`
+	OpenedAt          time.Time      `json:"opened_at"`
+	StatusChangedAt   time.Time      `json:"status_changed_at"`
+	ClosedAt          *time.Time     `json:"closed_at"`
+	SLADueAt          *time.Time     `json:"sla_due_at"`
+	SLARemaining      *int64         `json:"sla_remaining_seconds"` // Negative once overdue
+	Overdue           bool           `json:"overdue"`
+	Notes             []ReferralNote `json:"notes,omitempty"`
+}
+
+// ReferralNote is a note on a referral case, written when its status changes or on its own
+type ReferralNote struct {
+	NoteID    int       `json:"note_id"`
+	AdminID   *int      `json:"admin_id"` // Null for notes written by the system
+	AdminName string    `json:"admin_name"`
+	Status    string    `json:"status"`
+	Note      string    `json:"note"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OpenReferralRequest is sent by the user microservice when a senior's risk turns high
+type OpenReferralRequest struct {
+	UserID int    `json:"user_id"`
+	Source string `json:"source"`
+	Reason string `json:"reason"`
+}
+
+// OpenReferral opens a referral case for a senior and assigns it to the least busy clinician.
+// A senior has at most one case that is not closed, so repeated calls return the existing case.
+// Called by the user microservice.
+func (h *Handler) OpenReferral(w http.ResponseWriter, r *http.Request) {
+	var req OpenReferralRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID == 0 || req.Reason == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "user_id and reason are required")
+		return
+	}
+	if req.Source != "FES" && req.Source != "SelfAssessment" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidField, "source must be FES or SelfAssessment")
+		return
+	}
+
+	assignee, err := h.store.ReferralAssignee(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error choosing referral assignee", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	referralID, created, err := h.store.OpenReferral(r.Context(), NewReferral{
+		SeniorUserID:    req.UserID,
+		Source:          req.Source,
+		Reason:          req.Reason,
+		AssignedAdminID: assignee,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error opening referral", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+		h.addReferralNote(r.Context(), referralID, nil, ReferralOpen, "Referral opened: "+req.Reason)
+		slog.InfoContext(r.Context(), "Referral opened", "referral_id", referralID, "user_id", req.UserID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]int{"referral_id": referralID})
+}
+
+// referralListOptions are the sorts and filters of the referral queue. The user is the senior referred.
+var referralListOptions = pagination.Options{
+	Sort: map[string]string{
+		"sla":         slaRemainingColumn(),
+		"opened_at":   "rf.opened_at",
+		"status":      "rf.status",
+		"referral_id": "rf.referral_id",
+	},
+	DefaultSort: "sla",
+	IDColumn:    "rf.referral_id",
+	DateColumn:  "rf.opened_at",
+	UserColumn:  "rf.senior_user_id",
+}
+
+// slaRemainingColumn is the SQL for the seconds left before a case is overdue, matching Referral.SLARemaining.
+// Closed cases have no SLA and sort after every open one.
+func slaRemainingColumn() string {
+	statuses := make([]string, 0, len(ReferralSLA))
+	for status := range ReferralSLA {
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return referralOrder[statuses[i]] < referralOrder[statuses[j]] })
+
+	var sla strings.Builder
+	for _, status := range statuses {
+		fmt.Fprintf(&sla, " WHEN '%s' THEN %d", status, int64(ReferralSLA[status].Seconds()))
+	}
+	return fmt.Sprintf("COALESCE(CASE rf.status%s END - TIMESTAMPDIFF(SECOND, rf.status_changed_at, CURRENT_TIMESTAMP), %d)",
+		sla.String(), math.MaxInt32)
+}
+
+// ListReferrals returns a page of the referral queue, most urgent first unless sorted otherwise.
+// Closed cases are left out unless asked for with status=Closed, and mine=true limits the queue to the caller's cases.
+// See the pagination package for the other query parameters.
+func (h *Handler) ListReferrals(w http.ResponseWriter, r *http.Request) {
+	page, err := pagination.Parse(r, referralListOptions)
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidField, err.Error())
+		return
+	}
+
+	var filter ReferralFilter
+	if status := r.URL.Query().Get("status"); status != "" {
+		if _, ok := referralOrder[status]; !ok {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidField, "Invalid status")
+			return
+		}
+		filter.Status = status
+	}
+	if r.URL.Query().Get("mine") == "true" {
+		adminID, ok := claimedAdminID(r)
+		if !ok {
+			apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+			return
+		}
+		filter.AssignedTo = &adminID
+	}
+
+	referrals, total, err := h.store.Referrals(r.Context(), page, filter)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying referrals", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+	h.addSeniorNames(r, referrals)
+
+	page.WriteHeaders(w, total, len(referrals))
+	writeJSON(w, referrals)
+}
+
+// GetReferral returns a referral case with its notes
+func (h *Handler) GetReferral(w http.ResponseWriter, r *http.Request) {
+	referralID, err := strconv.Atoi(r.URL.Query().Get("referral_id"))
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "referral_id is required")
+		return
+	}
+
+	referral, err := h.store.Referral(r.Context(), referralID)
+	if err == ErrNotFound {
+		apierror.Write(w, r, http.StatusNotFound, apierror.NotFound, "Referral not found")
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying referral", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	referral.Notes, err = h.store.ReferralNotes(r.Context(), referralID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying referral notes", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+	h.addSeniorNames(r, []*Referral{referral})
+	writeJSON(w, referral)
+}
+
+// referralUpdateRequest is the body of the referral update endpoints
+type referralUpdateRequest struct {
+	ReferralID int    `json:"referral_id"`
+	Status     string `json:"status,omitempty"`
+	AdminID    int    `json:"admin_id,omitempty"`
+	Note       string `json:"note,omitempty"`
+}
+
+// UpdateReferralStatus moves a case forward to a later status, with an optional note.
+// Closed is final, and any other status may be skipped.
+func (h *Handler) UpdateReferralStatus(w http.ResponseWriter, r *http.Request) {
+	var req referralUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ReferralID == 0 {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "referral_id is required")
+		return
+	}
+	next, ok := referralOrder[req.Status]
+	if !ok {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidField, "Invalid status")
+		return
+	}
+
+	current, err := h.referralStatus(r.Context(), req.ReferralID)
+	if err == ErrNotFound {
+		apierror.Write(w, r, http.StatusNotFound, apierror.NotFound, "Referral not found")
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying referral status", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+	if next <= referralOrder[current] {
+		apierror.Write(w, r, http.StatusConflict, apierror.InvalidTransition, fmt.Sprintf("Cannot move a %s referral to %s", current, req.Status))
+		return
+	}
+
+	// Guarded on the current status so a concurrent update cannot be overwritten
+	updated, err := h.store.SetReferralStatus(r.Context(), req.ReferralID, current, req.Status)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error updating referral status", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+	if !updated {
+		apierror.Write(w, r, http.StatusConflict, apierror.EditConflict, "Referral was updated by someone else, please reload")
+		return
+	}
+
+	note := fmt.Sprintf("Status changed from %s to %s", current, req.Status)
+	if req.Note != "" {
+		note += ": " + req.Note
+	}
+	h.addReferralNote(r.Context(), req.ReferralID, noteAuthor(r), req.Status, note)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Referral status updated successfully"})
+}
+
+// AssignReferral assigns a case to an active admin who may work referrals
+func (h *Handler) AssignReferral(w http.ResponseWriter, r *http.Request) {
+	var req referralUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ReferralID == 0 || req.AdminID == 0 {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "referral_id and admin_id are required")
+		return
+	}
+
+	assignee, err := h.store.Admin(r.Context(), req.AdminID)
+	if err == ErrNotFound {
+		apierror.Write(w, r, http.StatusNotFound, apierror.NotFound, "Admin not found")
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying assignee", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+	if assignee.Status != StatusActive || !hasPermission(assignee.AdminRole, PermissionManageReferrals) {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidField, "Admin cannot be assigned referrals")
+		return
+	}
+
+	current, err := h.referralStatus(r.Context(), req.ReferralID)
+	if err == ErrNotFound {
+		apierror.Write(w, r, http.StatusNotFound, apierror.NotFound, "Referral not found")
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying referral status", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+	if current == ReferralClosed {
+		apierror.Write(w, r, http.StatusConflict, apierror.InvalidTransition, "Referral is closed")
+		return
+	}
+
+	if err := h.store.AssignReferral(r.Context(), req.ReferralID, req.AdminID); err != nil {
+		slog.ErrorContext(r.Context(), "Error assigning referral", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	h.addReferralNote(r.Context(), req.ReferralID, noteAuthor(r), current, "Assigned to "+assignee.Name)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Referral assigned successfully"})
+}
+
+// AddReferralNote adds a note to a case without changing its status
+func (h *Handler) AddReferralNote(w http.ResponseWriter, r *http.Request) {
+	var req referralUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ReferralID == 0 || strings.TrimSpace(req.Note) == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "referral_id and note are required")
+		return
+	}
+
+	current, err := h.referralStatus(r.Context(), req.ReferralID)
+	if err == ErrNotFound {
+		apierror.Write(w, r, http.StatusNotFound, apierror.NotFound, "Referral not found")
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying referral status", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	if err := h.addReferralNote(r.Context(), req.ReferralID, noteAuthor(r), current, req.Note); err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Note added successfully"})
+}
+
+// addReferralNote records a note on a case, logging a failure. adminID is nil for notes written by the system.
+func (h *Handler) addReferralNote(ctx context.Context, referralID int, adminID *int, status, note string) error {
+	err := h.store.AddReferralNote(ctx, referralID, adminID, status, note)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error adding note to referral", "referral_id", referralID, "error", err)
+	}
+	return err
+}
+
+// referralStatus returns the status of a case, or ErrNotFound if there is none
+func (h *Handler) referralStatus(ctx context.Context, referralID int) (string, error) {
+	referral, err := h.store.Referral(ctx, referralID)
+	if err != nil {
+		return "", err
+	}
+	return referral.Status, nil
+}
+
+// SetSLA fills in the SLA timer of a case that entered its status elapsed seconds ago.
+// Closed cases have no timer.
+func (rf *Referral) SetSLA(elapsed int64) {
+	if sla, ok := ReferralSLA[rf.Status]; ok {
+		remaining := int64(sla.Seconds()) - elapsed
+		due := rf.StatusChangedAt.Add(sla)
+		rf.SLADueAt, rf.SLARemaining = &due, &remaining
+		rf.Overdue = remaining < 0
+	}
+}
+
+// addSeniorNames fills in senior names from the user microservice.
+// The queue is still useful without them, so a failure is only logged.
+func (h *Handler) addSeniorNames(r *http.Request, referrals []*Referral) {
+	if len(referrals) == 0 {
+		return
+	}
+	users, err := h.clients.User.AllUsers(client.FromRequest(r))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error fetching senior names for referrals", "error", err)
+		return
+	}
+	names := make(map[int]string, len(users))
+	for _, u := range users {
+		names[u.UserID] = u.Name
+	}
+	for _, referral := range referrals {
+		referral.SeniorName = names[referral.SeniorUserID]
+	}
+}
+
+// hasPermission reports whether an admin role grants the permission
+func hasPermission(adminRole, permission string) bool {
+	for _, granted := range RolePermissions[adminRole] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// claimedAdminID returns the admin ID from the caller's token
+func claimedAdminID(r *http.Request) (int, bool) {
+	claims, ok := r.Context().Value(ClaimsContextKey).(jwt.MapClaims)
+	if !ok {
+		return 0, false
+	}
+	id, ok := claims["user_id"].(float64)
+	return int(id), ok
+}
+
+// noteAuthor returns the caller's admin ID for a referral note, or nil if the token has none
+func noteAuthor(r *http.Request) *int {
+	if adminID, ok := claimedAdminID(r); ok {
+		return &adminID
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package admin
+
+import (
+	"context"
+	"errors"
+
+	"shared/pagination"
+)
+
+// ErrNotFound is returned by a store when the requested row does not exist
+var ErrNotFound = errors.New("not found")
+
+// AdminStore is the admin account and referral data the handlers read and write.
+// MySQLStore keeps it in the admin database, and the memstore package keeps it in memory for handler tests.
+type AdminStore interface {
+	// Admin returns an admin account, or ErrNotFound if there is none
+	Admin(ctx context.Context, adminID int) (Admin, error)
+	// Admins returns a page of the admin accounts, and the number there are
+	Admins(ctx context.Context, page *pagination.Query) ([]AdminAccount, int, error)
+	// CreateAdmin adds an admin account, returning its ID. It fails if the email is already in use.
+	CreateAdmin(ctx context.Context, name, email, adminRole, status string) (int, error)
+	// DeleteAdmin removes an admin account
+	DeleteAdmin(ctx context.Context, adminID int) error
+	// SetAdminStatus changes an admin's status, reporting whether the admin exists
+	SetAdminStatus(ctx context.Context, adminID int, status string) (bool, error)
+	// ActivateAdmin marks an invited admin as active, leaving admins in any other status as they are
+	ActivateAdmin(ctx context.Context, adminID int) error
+	// SetAdminRole changes an admin's role, reporting whether the admin exists
+	SetAdminRole(ctx context.Context, adminID int, adminRole string) (bool, error)
+	// ActiveSuperAdminsExcept counts the active super admins other than the given admin
+	ActiveSuperAdminsExcept(ctx context.Context, adminID int) (int, error)
+
+	// ReferralAssignee returns the active clinician with the fewest unclosed referrals,
+	// falling back to coordinators and super admins, or nil if there is no one to assign
+	ReferralAssignee(ctx context.Context) (*int, error)
+	// OpenReferral opens a case for a senior unless they already have one that is not closed,
+	// returning the ID of the senior's unclosed case and whether it was opened by this call
+	OpenReferral(ctx context.Context, referral NewReferral) (int, bool, error)
+	// Referrals returns a page of the cases matching the filter with their SLA timers, and the number matching
+	Referrals(ctx context.Context, page *pagination.Query, filter ReferralFilter) ([]*Referral, int, error)
+	// Referral returns a case with its SLA timer and without its notes, or ErrNotFound if there is none
+	Referral(ctx context.Context, referralID int) (*Referral, error)
+	// ReferralNotes returns a case's notes, oldest first
+	ReferralNotes(ctx context.Context, referralID int) ([]ReferralNote, error)
+	// SetReferralStatus moves a case from one status to another, reporting false if it was no longer in the first.
+	// Moving a case to Closed closes it, and the SLA timer restarts.
+	SetReferralStatus(ctx context.Context, referralID int, from, to string) (bool, error)
+	// AssignReferral assigns a case to an admin
+	AssignReferral(ctx context.Context, referralID, adminID int) error
+	// AddReferralNote records a note on a case. adminID is nil for notes written by the system.
+	AddReferralNote(ctx context.Context, referralID int, adminID *int, status, note string) error
+}
+
+// NewReferral is a case to open for a high-risk senior, with who to assign it to if anyone
+type NewReferral struct {
+	SeniorUserID    int
+	Source          string
+	Reason          string
+	AssignedAdminID *int
+}
+
+// ReferralFilter narrows the referral queue. Closed cases are left out unless Status is Closed.
+type ReferralFilter struct {
+	Status     string // Only cases in this status, if set
+	AssignedTo *int   // Only cases assigned to this admin, if set
+}
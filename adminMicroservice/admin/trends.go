@@ -0,0 +1,161 @@
+package admin
+
+import (
+	"adminMicroservice/client/fes"
+	"adminMicroservice/client/selfassessment"
+	"adminMicroservice/client/user"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"shared/apierror"
+	"shared/client"
+	"shared/trend"
+)
+
+// Trend sources, used as the keys of the errors field
+const (
+	sourceFESTrends = "fes_trends"
+	sourceFATrends  = "fa_trends"
+)
+
+// SeniorTrends is a senior's trajectories across the FES and self-assessment,
+// with a summary of every significantly worsening metric
+type SeniorTrends struct {
+	UserID    int               `json:"user_id"`
+	Name      string            `json:"name,omitempty"`
+	Series    []trend.Series    `json:"series"`
+	Declining []string          `json:"declining"`
+	Errors    map[string]string `json:"errors,omitempty"`
+}
+
+// addSeries appends series and notes the ones that are significantly worsening
+func (s *SeniorTrends) addSeries(series []trend.Series) {
+	for _, one := range series {
+		s.Series = append(s.Series, one)
+		if one.Change != nil && one.Change.Significant && one.Change.Worsening {
+			s.Declining = append(s.Declining, one.Change.Summary)
+		}
+	}
+}
+
+// GetSeniorTrends returns one senior's FES and self-assessment trajectories over the last days (default 180).
+// A source that fails is reported in errors instead of failing the request.
+func (h *Handler) GetSeniorTrends(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidField, "Invalid user_id")
+		return
+	}
+	days, ok := trendDays(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(client.FromRequest(r), dashboardTimeout)
+	defer cancel()
+
+	var fesTrends fes.UserTrends
+	var faTrends selfassessment.UserTrends
+	errs := fanOut(ctx, map[string]func() error{
+		sourceFESTrends: func() (err error) { fesTrends, err = h.clients.FES.Trends(ctx, userID, days); return },
+		sourceFATrends:  func() (err error) { faTrends, err = h.clients.SelfAssessment.Trends(ctx, userID, days); return },
+	})
+	if len(errs) == 2 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(SeniorTrends{UserID: userID, Series: []trend.Series{}, Declining: []string{}, Errors: errs})
+		return
+	}
+
+	trends := SeniorTrends{UserID: userID, Series: []trend.Series{}, Declining: []string{}, Errors: errs}
+	trends.addSeries(fesTrends.Series)
+	trends.addSeries(faTrends.Series)
+	writeJSON(w, trends)
+}
+
+// DecliningResponse lists seniors with a significantly worsening metric, with an entry in Errors for every source that failed
+type DecliningResponse struct {
+	Seniors []*SeniorTrends   `json:"seniors"`
+	Errors  map[string]string `json:"errors"`
+}
+
+// GetDecliningSeniors returns every senior with a significantly worsening FES or self-assessment
+// metric over the last days (default 180), most worsening metrics first, so decline is caught
+// before it becomes high risk
+func (h *Handler) GetDecliningSeniors(w http.ResponseWriter, r *http.Request) {
+	days, ok := trendDays(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(client.FromRequest(r), dashboardTimeout)
+	defer cancel()
+
+	var (
+		users     []user.User
+		fesTrends []fes.UserTrends
+		faTrends  []selfassessment.UserTrends
+	)
+	fetches := map[string]func() error{
+		sourceUsers:     func() (err error) { users, err = h.clients.User.AllUsers(ctx); return },
+		sourceFESTrends: func() (err error) { fesTrends, err = h.clients.FES.DecliningTrends(ctx, days); return },
+		sourceFATrends:  func() (err error) { faTrends, err = h.clients.SelfAssessment.DecliningTrends(ctx, days); return },
+	}
+	errs := fanOut(ctx, fetches)
+	_, fesFailed := errs[sourceFESTrends]
+	_, faFailed := errs[sourceFATrends]
+	if fesFailed && faFailed {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(DecliningResponse{Seniors: []*SeniorTrends{}, Errors: errs})
+		return
+	}
+
+	bySenior := map[int]*SeniorTrends{}
+	seniorFor := func(userID int) *SeniorTrends {
+		if _, seen := bySenior[userID]; !seen {
+			bySenior[userID] = &SeniorTrends{UserID: userID, Series: []trend.Series{}, Declining: []string{}}
+		}
+		return bySenior[userID]
+	}
+	for _, userTrends := range fesTrends {
+		seniorFor(userTrends.UserID).addSeries(userTrends.Series)
+	}
+	for _, userTrends := range faTrends {
+		seniorFor(userTrends.UserID).addSeries(userTrends.Series)
+	}
+	for _, u := range users {
+		if senior, ok := bySenior[u.UserID]; ok {
+			senior.Name = u.Name
+		}
+	}
+
+	seniors := make([]*SeniorTrends, 0, len(bySenior))
+	for _, senior := range bySenior {
+		seniors = append(seniors, senior)
+	}
+	sort.Slice(seniors, func(i, j int) bool {
+		if len(seniors[i].Declining) != len(seniors[j].Declining) {
+			return len(seniors[i].Declining) > len(seniors[j].Declining)
+		}
+		return seniors[i].UserID < seniors[j].UserID
+	})
+	writeJSON(w, DecliningResponse{Seniors: seniors, Errors: errs})
+}
+
+// trendDays reads the days query parameter, writing an error response and returning false if it is invalid
+func trendDays(w http.ResponseWriter, r *http.Request) (int, bool) {
+	value := r.URL.Query().Get("days")
+	if value == "" {
+		return trend.DefaultDays, true
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 1 {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidField, "Invalid days")
+		return 0, false
+	}
+	return days, true
+}
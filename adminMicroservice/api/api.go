@@ -0,0 +1,12 @@
+// Package api holds the OpenAPI description of the service's /api/v1 endpoints, the contract its
+// callers rely on. Within v1 the contract only grows: endpoints, optional fields and error codes may
+// be added, while removing or changing them needs a new version under /api/v2.
+package api
+
+import _ "embed"
+
+// Spec is the OpenAPI 3 document, which the integration harness checks against the routes and
+// every request and response of its flows
+//
+//go:embed openapi.yaml
+var Spec []byte
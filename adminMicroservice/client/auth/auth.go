@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"context"
+
+	"shared/client"
+	"shared/config"
+)
+
+// Client calls the admin credential endpoints of the authentication microservice.
+// The caller's token must be forwarded, as the authentication microservice checks it too.
+type Client struct {
+	*client.Client
+}
+
+// New returns a client for the authentication microservice
+func New() *Client {
+	return &Client{client.New(config.AuthService)}
+}
+
+// adminRequest is the body of the admin credential endpoints
+type adminRequest struct {
+	AdminID int    `json:"admin_id"`
+	Email   string `json:"email,omitempty"`
+}
+
+// InviteAdmin creates credentials for a new admin and emails them a setup token
+func (c *Client) InviteAdmin(ctx context.Context, adminID int, email string) error {
+	return c.Post(ctx, "/api/v1/authentication/admin/invite", adminRequest{AdminID: adminID, Email: email}, nil)
+}
+
+// DeactivateAdmin blocks an admin from logging in and revokes their stored tokens
+func (c *Client) DeactivateAdmin(ctx context.Context, adminID int) error {
+	return c.Post(ctx, "/api/v1/authentication/admin/deactivate", adminRequest{AdminID: adminID}, nil)
+}
+
+// ResetAdmin clears an admin's password and emails them a fresh setup token
+func (c *Client) ResetAdmin(ctx context.Context, adminID int) error {
+	return c.Post(ctx, "/api/v1/authentication/admin/reset", adminRequest{AdminID: adminID}, nil)
+}
+
+// ResetAdminMFA removes an admin's two-factor authentication
+func (c *Client) ResetAdminMFA(ctx context.Context, adminID int) error {
+	return c.Post(ctx, "/api/v1/authentication/admin/2fa/reset", adminRequest{AdminID: adminID}, nil)
+}
+
+// AdminTokenActive reports whether an admin's token is still live: stored, unexpired and
+// belonging to an active admin. Tokens are removed when their admin is deactivated or reset.
+func (c *Client) AdminTokenActive(ctx context.Context, token string) (bool, error) {
+	var session struct {
+		Active bool `json:"active"`
+	}
+	err := c.Post(client.AsService(ctx), "/api/v1/authentication/admin/checkToken", map[string]string{"token": token}, &session)
+	return session.Active, err
+}
@@ -0,0 +1,180 @@
+package fes
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"shared/client"
+	"shared/config"
+	"shared/trend"
+)
+
+// Response is a completed Falls Efficacy Scale questionnaire
+type Response struct {
+	ResponseID      uint16           `json:"response_id"`                // Unique ID for the response
+	UserID          uint16           `json:"user_id"`                    // Associated user ID
+	TotalScore      uint16           `json:"total_score"`                // Total score across all questions
+	ResponseDate    time.Time        `json:"response_date"`              // Date of response submission
+	ResponseDetails []ResponseDetail `json:"response_details,omitempty"` // Question responses, when requested per user
+}
+
+// ResponseDetail is the answer to a single FES question
+type ResponseDetail struct {
+	ResponseID    int `json:"response_id,omitempty"` // Response the answer belongs to, when listing all answers
+	QuestionID    int `json:"question_id"`           // Associated question ID
+	ResponseScore int `json:"response_score"`        // User's response score
+}
+
+// LastResponseDay is the number of days since a senior last completed the FES
+type LastResponseDay struct {
+	UserID            int `json:"user_id"`
+	DaysSinceResponse int `json:"days_since_last_fesres"`
+}
+
+// RiskLevel is a senior's fall risk level from their latest FES response
+type RiskLevel struct {
+	UserID    int    `json:"user_id"`    // User ID
+	RiskLevel string `json:"risk_level"` // Risk level (low, moderate, high)
+}
+
+// LastAssessment is a senior's most recent FES response
+type LastAssessment struct {
+	ResponseID   uint16    `json:"response_id"`
+	UserID       uint16    `json:"user_id"`
+	TotalScore   uint16    `json:"total_score"`
+	ResponseDate time.Time `json:"response_date"`
+}
+
+// UserTrends is one senior's trend series
+type UserTrends struct {
+	UserID int            `json:"user_id"`
+	Series []trend.Series `json:"series"`
+}
+
+// RiskPeriod is the spread of FES risk levels in one month
+type RiskPeriod struct {
+	Period       string `json:"period"` // YYYY-MM
+	Participants int    `json:"participants"`
+	Low          int    `json:"low"`
+	Moderate     int    `json:"moderate"`
+	High         int    `json:"high"`
+}
+
+// ItemBreakdown summarises the answers to one FES question
+type ItemBreakdown struct {
+	QuestionID   int     `json:"question_id"`
+	QuestionText string  `json:"question_text"`
+	Responses    int     `json:"responses"`
+	AverageScore float64 `json:"average_score"`
+	ScoreCounts  [4]int  `json:"score_counts"` // Number of answers scoring 1, 2, 3 and 4
+}
+
+// UserScoreTotal is the count and sum of one senior's scores for a metric
+type UserScoreTotal struct {
+	UserID int     `json:"user_id"`
+	Metric string  `json:"metric"`
+	Count  int     `json:"count"`
+	Sum    float64 `json:"sum"`
+}
+
+// Client calls the falls efficacy scale microservice
+type Client struct {
+	*client.Client
+}
+
+// New returns a client for the falls efficacy scale microservice
+func New() *Client {
+	return &Client{client.New(config.FallsEfficacyService)}
+}
+
+// LatestResponses returns each senior's most recent FES response, without the individual answers
+func (c *Client) LatestResponses(ctx context.Context) ([]Response, error) {
+	var responses []Response
+	err := c.Get(ctx, "/api/v1/fes/getAllFESLatestScore", &responses)
+	return responses, err
+}
+
+// ResponsePage returns one page of the FES responses, with the list query parameters of the pagination contract
+func (c *Client) ResponsePage(ctx context.Context, params url.Values) ([]Response, client.Page, error) {
+	var responses []Response
+	page, err := c.GetPage(ctx, "/api/v1/fes/getAllResponses", params, &responses)
+	return responses, page, err
+}
+
+// ResponseDetails returns the individual answers of every FES response, fetching every page
+func (c *Client) ResponseDetails(ctx context.Context) ([]ResponseDetail, error) {
+	return client.GetAll[ResponseDetail](ctx, c.Client, "/api/v1/fes/getAllIndividualRes", nil)
+}
+
+// ResponseDetailPage returns one page of the individual answers, with the list query parameters of the pagination contract
+func (c *Client) ResponseDetailPage(ctx context.Context, params url.Values) ([]ResponseDetail, client.Page, error) {
+	var details []ResponseDetail
+	page, err := c.GetPage(ctx, "/api/v1/fes/getAllIndividualRes", params, &details)
+	return details, page, err
+}
+
+// LastResponseDays returns how long ago each senior last completed the FES
+func (c *Client) LastResponseDays(ctx context.Context) ([]LastResponseDay, error) {
+	var days []LastResponseDay
+	err := c.Get(ctx, "/api/v1/fes/getAllFESLastResDay", &days)
+	return days, err
+}
+
+// LatestRisk returns each senior's risk level from their latest FES response
+func (c *Client) LatestRisk(ctx context.Context) ([]RiskLevel, error) {
+	var levels []RiskLevel
+	err := c.Get(ctx, "/api/v1/fes/getAllFESLatestRisk", &levels)
+	return levels, err
+}
+
+// Results returns a senior's FES responses with their individual answers
+func (c *Client) Results(ctx context.Context, userID int) ([]Response, error) {
+	var responses []Response
+	err := c.Get(ctx, fmt.Sprintf("/api/v1/fes/getFESResults?user_id=%d", userID), &responses)
+	return responses, err
+}
+
+// LastAssessment returns a senior's most recent FES response.
+// A senior who has never completed the FES gets a 404 Error.
+func (c *Client) LastAssessment(ctx context.Context, userID int) (LastAssessment, error) {
+	var assessment LastAssessment
+	err := c.Get(ctx, fmt.Sprintf("/api/v1/fes/getLastAssessment?user_id=%d", userID), &assessment)
+	return assessment, err
+}
+
+// Trends returns the trajectory of a senior's FES total over the last days
+func (c *Client) Trends(ctx context.Context, userID, days int) (UserTrends, error) {
+	var trends UserTrends
+	err := c.Get(ctx, fmt.Sprintf("/api/v1/fes/trends?user_id=%d&days=%d", userID, days), &trends)
+	return trends, err
+}
+
+// DecliningTrends returns every senior whose FES total significantly worsened over the last days
+func (c *Client) DecliningTrends(ctx context.Context, days int) ([]UserTrends, error) {
+	var trends []UserTrends
+	err := c.Get(ctx, fmt.Sprintf("/api/v1/fes/trends/declining?days=%d", days), &trends)
+	return trends, err
+}
+
+// RiskDistribution returns the spread of FES risk levels per month between from and to (YYYY-MM-DD, inclusive)
+func (c *Client) RiskDistribution(ctx context.Context, from, to string) ([]RiskPeriod, error) {
+	var periods []RiskPeriod
+	err := c.Get(ctx, fmt.Sprintf("/api/v1/fes/analytics/riskDistribution?from=%s&to=%s", from, to), &periods)
+	return periods, err
+}
+
+// ItemBreakdown returns how every FES question was answered between from and to
+func (c *Client) ItemBreakdown(ctx context.Context, from, to string) ([]ItemBreakdown, error) {
+	var items []ItemBreakdown
+	err := c.Get(ctx, fmt.Sprintf("/api/v1/fes/analytics/items?from=%s&to=%s", from, to), &items)
+	return items, err
+}
+
+// UserScoreTotals returns the count and sum of each senior's FES totals between from and to
+func (c *Client) UserScoreTotals(ctx context.Context, from, to string) ([]UserScoreTotal, error) {
+	var totals []UserScoreTotal
+	err := c.Get(ctx, fmt.Sprintf("/api/v1/fes/analytics/userScoreTotals?from=%s&to=%s", from, to), &totals)
+	return totals, err
+}
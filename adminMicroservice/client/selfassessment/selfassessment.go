@@ -0,0 +1,170 @@
+package selfassessment
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"shared/client"
+	"shared/config"
+	"shared/trend"
+)
+
+// SessionScore is the total score of one self-assessment session
+type SessionScore struct {
+	SessionID   int       `json:"session_id"`   // Unique ID for the session
+	UserID      int       `json:"user_id"`      // Associated user ID
+	SessionDate time.Time `json:"session_date"` // Date and time of the session
+	TotalScore  int16     `json:"total_score"`  // Total score for the session
+}
+
+// TestTime is the time a senior took to complete one test
+type TestTime struct {
+	ResultID    uint      `json:"result_id"`
+	TestName    string    `json:"test_name"`
+	UserID      uint      `json:"user_id"`
+	TimeTaken   float64   `json:"time_taken"`
+	SessionDate time.Time `json:"session_date"`
+}
+
+// UserRisk is a senior's overall risk level from their latest session
+type UserRisk struct {
+	UserID           int    `json:"user_id"`
+	OverallRiskLevel string `json:"overall_risk_level"`
+}
+
+// LastResponseDay is the number of days since a senior last completed a self-assessment
+type LastResponseDay struct {
+	UserID            int `json:"user_id"`
+	DaysSinceResponse int `json:"days_since_last_fares"`
+}
+
+// TestResult is the result of one test within a session
+type TestResult struct {
+	ResultID         int       `json:"result_id"`
+	TestID           int       `json:"test_id"`
+	TestName         string    `json:"test_name"`
+	TimeTaken        float64   `json:"time_taken"`
+	AbruptPercentage int       `json:"abrupt_percentage"`
+	RiskLevel        string    `json:"risk_level"`
+	TestDate         time.Time `json:"test_date"`
+}
+
+// Session is a self-assessment session with its test results
+type Session struct {
+	SessionID    int          `json:"session_id"`
+	UserID       int          `json:"user_id"`
+	SessionDate  time.Time    `json:"session_date"`
+	TotalScore   *int         `json:"total_score"`   // Null until the session is scored
+	SessionNotes *string      `json:"session_notes"` // Null when there are no notes
+	TestResults  []TestResult `json:"test_results"`
+}
+
+// UserTrends is one senior's trend series
+type UserTrends struct {
+	UserID int            `json:"user_id"`
+	Series []trend.Series `json:"series"`
+}
+
+// RiskPeriod is the spread of self-assessment risk levels in one month
+type RiskPeriod struct {
+	Period       string `json:"period"` // YYYY-MM
+	Participants int    `json:"participants"`
+	Low          int    `json:"low"`
+	Moderate     int    `json:"moderate"`
+	High         int    `json:"high"`
+}
+
+// UserScoreTotal is the count and sum of one senior's scores for a metric
+type UserScoreTotal struct {
+	UserID int     `json:"user_id"`
+	Metric string  `json:"metric"`
+	Count  int     `json:"count"`
+	Sum    float64 `json:"sum"`
+}
+
+// Client calls the self-assessment microservice
+type Client struct {
+	*client.Client
+}
+
+// New returns a client for the self-assessment microservice
+func New() *Client {
+	return &Client{client.New(config.SelfAssessmentService)}
+}
+
+// LatestSessionScores returns each senior's most recent scored session
+func (c *Client) LatestSessionScores(ctx context.Context) ([]SessionScore, error) {
+	var scores []SessionScore
+	err := c.Get(ctx, "/api/v1/selfAssessment/getAllLatestScore", &scores)
+	return scores, err
+}
+
+// SessionScorePage returns one page of the session scores, with the list query parameters of the pagination contract
+func (c *Client) SessionScorePage(ctx context.Context, params url.Values) ([]SessionScore, client.Page, error) {
+	var scores []SessionScore
+	page, err := c.GetPage(ctx, "/api/v1/selfAssessment/getAllTotalScore", params, &scores)
+	return scores, page, err
+}
+
+// TestTimes returns the time taken on every test by every senior, fetching every page
+func (c *Client) TestTimes(ctx context.Context) ([]TestTime, error) {
+	return client.GetAll[TestTime](ctx, c.Client, "/api/v1/selfAssessment/getAllAvgTime", nil)
+}
+
+// TestTimePage returns one page of the test times, with the list query parameters of the pagination contract
+func (c *Client) TestTimePage(ctx context.Context, params url.Values) ([]TestTime, client.Page, error) {
+	var times []TestTime
+	page, err := c.GetPage(ctx, "/api/v1/selfAssessment/getAllAvgTime", params, &times)
+	return times, page, err
+}
+
+// UserRisks returns each senior's overall risk level from their latest session
+func (c *Client) UserRisks(ctx context.Context) ([]UserRisk, error) {
+	var risks []UserRisk
+	err := c.Get(ctx, "/api/v1/selfAssessment/getAllUserRisk", &risks)
+	return risks, err
+}
+
+// LastResponseDays returns how long ago each senior last completed a self-assessment
+func (c *Client) LastResponseDays(ctx context.Context) ([]LastResponseDay, error) {
+	var days []LastResponseDay
+	err := c.Get(ctx, "/api/v1/selfAssessment/getAllLastResDay", &days)
+	return days, err
+}
+
+// Sessions returns a senior's sessions with their test results
+func (c *Client) Sessions(ctx context.Context, userID int) ([]Session, error) {
+	var sessions []Session
+	err := c.Get(ctx, fmt.Sprintf("/api/v1/selfAssessment/getUserResults?user_id=%d", userID), &sessions)
+	return sessions, err
+}
+
+// Trends returns the trajectories of a senior's session score and of each test's time and abrupt movement
+func (c *Client) Trends(ctx context.Context, userID, days int) (UserTrends, error) {
+	var trends UserTrends
+	err := c.Get(ctx, fmt.Sprintf("/api/v1/selfAssessment/trends?user_id=%d&days=%d", userID, days), &trends)
+	return trends, err
+}
+
+// DecliningTrends returns every senior with a significantly worsening self-assessment metric, with only those series
+func (c *Client) DecliningTrends(ctx context.Context, days int) ([]UserTrends, error) {
+	var trends []UserTrends
+	err := c.Get(ctx, fmt.Sprintf("/api/v1/selfAssessment/trends/declining?days=%d", days), &trends)
+	return trends, err
+}
+
+// RiskDistribution returns the spread of self-assessment risk levels per month between from and to (YYYY-MM-DD, inclusive)
+func (c *Client) RiskDistribution(ctx context.Context, from, to string) ([]RiskPeriod, error) {
+	var periods []RiskPeriod
+	err := c.Get(ctx, fmt.Sprintf("/api/v1/selfAssessment/analytics/riskDistribution?from=%s&to=%s", from, to), &periods)
+	return periods, err
+}
+
+// UserScoreTotals returns the count and sum of each senior's session scores and test metrics between from and to
+func (c *Client) UserScoreTotals(ctx context.Context, from, to string) ([]UserScoreTotal, error) {
+	var totals []UserScoreTotal
+	err := c.Get(ctx, fmt.Sprintf("/api/v1/selfAssessment/analytics/userScoreTotals?from=%s&to=%s", from, to), &totals)
+	return totals, err
+}
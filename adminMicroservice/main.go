@@ -1,118 +1,88 @@
 package main
 
 import (
-	"log"
-	"net/http"
+	"context"
+	"fmt"
+	"log/slog"
 	"os"
-	"strings"
 
 	"adminMicroservice/admin"
-
-	"github.com/golang-jwt/jwt/v4"
-	"github.com/gorilla/handlers"
-	"github.com/gorilla/mux"
+	"adminMicroservice/config"
+	"adminMicroservice/migrations"
+	"adminMicroservice/server"
+
+	"shared/client"
+	sharedconfig "shared/config"
+	"shared/graceful"
+	"shared/logging"
+	"shared/migrate"
+	"shared/observability"
+	"shared/settings"
+	"shared/tracing"
 )
 
-// JWT Authentication Middleware with Role Check for multiple roles
-func authenticateMiddleware(allowedRoles []string) mux.MiddlewareFunc {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Get the Authorization header
-			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return
-			}
-
-			// Extract token
-			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-
-			// Get the JWT secret from the environment variable
-			secretKey := os.Getenv("JWT_SECRET")
-			if secretKey == "" {
-				log.Println("JWT_SECRET is not set in the environment")
-				http.Error(w, "Internal server error", http.StatusInternalServerError)
-				return
-			}
-
-			// Parse and validate the token
-			token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, http.ErrAbortHandler
-				}
-				return []byte(secretKey), nil
-			})
-
-			if err != nil || !token.Valid {
-				log.Printf("Invalid JWT token: %v", err)
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return
-			}
-
-			// Extract the claims from the token
-			claims, ok := token.Claims.(jwt.MapClaims)
-			if !ok {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return
-			}
-
-			// Check if the role matches any of the allowed roles
-			role, ok := claims["role"].(string)
-			if !ok {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return
-			}
-
-			// Check if the user's role is in the allowed roles
-			roleAllowed := false
-			for _, allowedRole := range allowedRoles {
-				if role == allowedRole {
-					roleAllowed = true
-					break
-				}
-			}
+func main() {
+	// Read the configuration from the flags, the environment, secret files and the config file
+	cfg, err := config.Load(os.Args[1:])
+	if cfg.PrintConfig {
+		settings.Print(os.Stdout, cfg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 
-			if !roleAllowed {
-				http.Error(w, "Forbidden", http.StatusForbidden)
-				return
-			}
+	// Log as JSON from here on, at the level set by LOG_LEVEL
+	logging.Setup(sharedconfig.AdminService, cfg.LogLevel)
+	if err != nil {
+		slog.Error("Error loading configuration", "error", err)
+		os.Exit(1)
+	}
 
-			// Proceed to the next handler if role matches
-			next.ServeHTTP(w, r)
-		})
+	// Trace requests across the services, exporting the spans if an OTLP collector is configured
+	shutdownTracing, err := tracing.Setup(context.Background(), sharedconfig.AdminService)
+	if err != nil {
+		slog.Error("Error setting up tracing", "error", err)
+		os.Exit(1)
 	}
-}
 
-func main() {
-	// Initialize the router
-	router := mux.NewRouter()
+	// Run the migrate subcommand, or bring the schema up to date before serving
+	if len(cfg.Args) > 0 && cfg.Args[0] == "migrate" {
+		if err := migrate.Command(cfg.DBConnection, migrations.Files, cfg.Args[1:]); err != nil {
+			slog.Error("Error running migrations", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if err := migrate.OnStart(cfg.DBConnection, migrations.Files, cfg.MigrateOnStart); err != nil {
+		slog.Error("Error applying schema migrations", "error", err)
+		os.Exit(1)
+	}
 
-	//Admin management endpoint
-	router.HandleFunc("/api/v1/admin/getAdmin", admin.GetAdminByID).Methods("GET")
+	db, err := admin.OpenDB(cfg.DBConnection)
+	if err != nil {
+		slog.Error("Error opening admin database", "error", err)
+		os.Exit(1)
+	}
 
-	// JWT Authentication Logic
-	authenticated := router.NewRoute().Subrouter()
+	// The clients are created after the configuration is loaded, which may set the service URLs
+	h := admin.NewHandler(admin.NewMySQLStore(db), admin.NewClients())
 
-	//Protected admin endpoints
-	authenticated.HandleFunc("/api/v1/admin/getAllElderlyUser", admin.CallUserMicroservice).Methods("GET").Handler(authenticateMiddleware([]string{"Admin"})(http.HandlerFunc(admin.CallUserMicroservice)))
-	authenticated.HandleFunc("/api/v1/admin/getAllElderlyFESResponse", admin.CallFESForUserResponse).Methods("GET").Handler(authenticateMiddleware([]string{"Admin"})(http.HandlerFunc(admin.CallFESForUserResponse)))
-	authenticated.HandleFunc("/api/v1/admin/getAllElderlyFESResDetails", admin.CallFESForUserResponseDetails).Methods("GET").Handler(authenticateMiddleware([]string{"Admin"})(http.HandlerFunc(admin.CallFESForUserResponseDetails)))
-	authenticated.HandleFunc("/api/v1/admin/getAllFATotalScore", admin.CallFAForAllUserTotalScore).Methods("GET").Handler(authenticateMiddleware([]string{"Admin"})(http.HandlerFunc(admin.CallFAForAllUserTotalScore)))
-	authenticated.HandleFunc("/api/v1/admin/getAllFATime", admin.CallFAForAllUserTime).Methods("GET").Handler(authenticateMiddleware([]string{"Admin"})(http.HandlerFunc(admin.CallFAForAllUserTime)))
-	authenticated.HandleFunc("/api/v1/admin/getAllFAUserRisk", admin.CallFAForAllUserRisk).Methods("GET").Handler(authenticateMiddleware([]string{"Admin"})(http.HandlerFunc(admin.CallFAForAllUserRisk)))
-	authenticated.HandleFunc("/api/v1/admin/getAllLastResFES", admin.CallFESLastResDayForAllUsers).Methods("GET").Handler(authenticateMiddleware([]string{"Admin"})(http.HandlerFunc(admin.CallFESLastResDayForAllUsers)))
-	authenticated.HandleFunc("/api/v1/admin/getAllLastResFA", admin.CallFALastResDayForAllUsers).Methods("GET").Handler(authenticateMiddleware([]string{"Admin"})(http.HandlerFunc(admin.CallFALastResDayForAllUsers)))
-	authenticated.HandleFunc("/api/v1/admin/sendEmailAssesRemind", admin.SendEmailHandler).Methods("POST").Handler(authenticateMiddleware([]string{"Admin"})(http.HandlerFunc(admin.SendEmailHandler)))
-	authenticated.HandleFunc("/api/v1/admin/getAllFESUserRisk", admin.CallFESUserRiskLevel).Methods("GET").Handler(authenticateMiddleware([]string{"Admin"})(http.HandlerFunc(admin.CallFESUserRiskLevel)))
+	// Ready while the database and the services called downstream are reachable
+	health := observability.NewHealth()
+	health.AddCheck("database", observability.PingDB(db))
+	for _, service := range []string{sharedconfig.AuthService, sharedconfig.FallsEfficacyService, sharedconfig.SelfAssessmentService, sharedconfig.UserService} {
+		health.AddCheck(service, client.New(service).Ping)
+	}
 
-	// Add CORS support
-	corsHandler := handlers.CORS(
-		handlers.AllowedOrigins([]string{"http://18.143.103.158:5200"}),         // Update for allowed origins
-		handlers.AllowedMethods([]string{"GET", "POST", "PUT", "OPTIONS"}), // Update for allowed HTTP methods
-		handlers.AllowedHeaders([]string{"Content-Type", "Authorization"}), // Include Authorization header
-	)(router)
+	// Route the endpoints through their middleware
+	router := server.NewRouter(h, health)
 
-	// Start the server
-	log.Println("Admin Microservice is running on port 5200...")
-	log.Fatal(http.ListenAndServe(":5200", corsHandler))
+	// Serve until SIGTERM, then drain the requests in flight, close the database and flush the spans
+	slog.Info("Admin Microservice is running", "port", cfg.Port)
+	if err := graceful.Serve(fmt.Sprintf(":%d", cfg.Port), router, graceful.CloseFunc(db.Close), shutdownTracing); err != nil {
+		slog.Error("Server stopped", "error", err)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,3 @@
+-- Drops the tables of FallSafe_AdminDB
+
+DROP TABLE IF EXISTS User;
@@ -0,0 +1,11 @@
+-- Creates the tables of FallSafe_AdminDB
+
+-- Create the User table
+-- PURPOSE: Stores admin profile details
+CREATE TABLE User (
+    user_id SMALLINT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT,  -- Unique ID for the admin
+    name VARCHAR(100) NOT NULL,                                      -- Admin's name
+    email VARCHAR(100) NOT NULL UNIQUE,                              -- Admin's email address
+    role ENUM('Admin') DEFAULT 'Admin',                              -- Role fixed as Admin
+    INDEX idx_email (email)                                          -- Index for email lookups
+);
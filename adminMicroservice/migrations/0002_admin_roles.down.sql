@@ -0,0 +1,4 @@
+-- Removes admin roles and account status
+
+ALTER TABLE User DROP COLUMN status;
+ALTER TABLE User DROP COLUMN admin_role;
@@ -0,0 +1,7 @@
+-- Gives admins a role within the team and an account status
+
+ALTER TABLE User ADD COLUMN admin_role ENUM('SuperAdmin', 'Coordinator', 'Clinician', 'Volunteer') NOT NULL DEFAULT 'Coordinator' AFTER `role`; -- Role within the admin team, determines permissions
+ALTER TABLE User ADD COLUMN status ENUM('Invited', 'Active', 'Deactivated') NOT NULL DEFAULT 'Active' AFTER admin_role; -- Account lifecycle
+
+-- Admins from before roles could do everything, so they keep that as super admins
+UPDATE User SET admin_role = 'SuperAdmin';
@@ -0,0 +1,4 @@
+-- Removes the referral cases
+
+DROP TABLE IF EXISTS ReferralNote;
+DROP TABLE IF EXISTS Referral;
@@ -0,0 +1,32 @@
+-- Adds the clinical referral cases of high-risk seniors
+
+-- Create the Referral table
+-- PURPOSE: Tracks clinical referral cases opened when a senior's risk turns high
+CREATE TABLE Referral (
+    referral_id INT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT,   -- Unique ID for the case
+    senior_user_id SMALLINT UNSIGNED NOT NULL,                       -- Senior in the user database
+    source ENUM('FES', 'SelfAssessment') NOT NULL,                   -- Assessment whose risk turned high
+    reason VARCHAR(255) NOT NULL,                                    -- Why the case was opened
+    status ENUM('Open', 'Contacted', 'Scheduled', 'Assessed', 'Closed') NOT NULL DEFAULT 'Open', -- Case lifecycle
+    assigned_admin_id SMALLINT UNSIGNED DEFAULT NULL,                -- Clinician or admin working the case
+    opened_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,                   -- When the case was opened
+    status_changed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,           -- When the case entered its status, starts the SLA timer
+    closed_at TIMESTAMP NULL DEFAULT NULL,                           -- When the case was closed
+    open_senior_user_id SMALLINT UNSIGNED AS (IF(status = 'Closed', NULL, senior_user_id)) STORED, -- Set while the case is not closed
+    UNIQUE KEY uq_open_senior (open_senior_user_id),                 -- At most one unclosed case per senior
+    INDEX idx_status (status, status_changed_at),                    -- Index for the referral queue
+    FOREIGN KEY (assigned_admin_id) REFERENCES User(user_id) ON DELETE SET NULL
+);
+
+-- Create the ReferralNote table
+-- PURPOSE: Records notes and status changes on referral cases
+CREATE TABLE ReferralNote (
+    note_id INT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT,       -- Unique ID for the note
+    referral_id INT UNSIGNED NOT NULL,                               -- Case the note belongs to
+    admin_id SMALLINT UNSIGNED DEFAULT NULL,                         -- Admin who wrote the note, NULL for the system
+    status ENUM('Open', 'Contacted', 'Scheduled', 'Assessed', 'Closed') NOT NULL, -- Case status when the note was written
+    note TEXT NOT NULL,                                              -- Note text
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,                  -- When the note was written
+    FOREIGN KEY (referral_id) REFERENCES Referral(referral_id) ON DELETE CASCADE,
+    FOREIGN KEY (admin_id) REFERENCES User(user_id) ON DELETE SET NULL
+);
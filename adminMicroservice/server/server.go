@@ -0,0 +1,225 @@
+// Package server routes the admin microservice's endpoints, so main and the integration
+// harness serve the same handlers behind the same middleware
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"adminMicroservice/admin"
+	"adminMicroservice/client/auth"
+	"adminMicroservice/config"
+
+	"shared/apierror"
+	"shared/client"
+	sharedconfig "shared/config"
+	"shared/logging"
+	"shared/observability"
+	"shared/pagination"
+	"shared/tracing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/gorilla/handlers"
+	"github.com/gorilla/mux"
+)
+
+// adminSessions reports whether an admin's token is still live. The authentication microservice
+// removes an admin's tokens when they are deactivated or reset.
+type adminSessions interface {
+	AdminTokenActive(ctx context.Context, token string) (bool, error)
+}
+
+// authenticator checks tokens for the routes, asking sessions about admin tokens on every request
+type authenticator struct {
+	sessions adminSessions
+}
+
+// JWT Authentication Middleware with Role Check for multiple roles
+func (a authenticator) authenticateMiddleware(allowedRoles []string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Get the Authorization header
+			authHeader := r.Header.Get("Authorization")
+			if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+				return
+			}
+
+			// Extract token
+			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+
+			// Get the JWT secret from the configuration
+			secretKey := config.Current().JWTSecret
+			if secretKey == "" {
+				slog.ErrorContext(r.Context(), "JWT_SECRET is not set")
+				apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+				return
+			}
+
+			// Parse and validate the token
+			token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+					return nil, http.ErrAbortHandler
+				}
+				return []byte(secretKey), nil
+			})
+
+			if err != nil || !token.Valid {
+				slog.WarnContext(r.Context(), "Invalid JWT token", "error", err)
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+				return
+			}
+
+			// Extract the claims from the token
+			claims, ok := token.Claims.(jwt.MapClaims)
+			if !ok {
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+				return
+			}
+
+			// Check if the role matches any of the allowed roles
+			role, ok := claims["role"].(string)
+			if !ok {
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+				return
+			}
+
+			// Check if the user's role is in the allowed roles
+			roleAllowed := false
+			for _, allowedRole := range allowedRoles {
+				if role == allowedRole {
+					roleAllowed = true
+					break
+				}
+			}
+
+			if !roleAllowed {
+				apierror.Write(w, r, http.StatusForbidden, apierror.Forbidden, "Forbidden")
+				return
+			}
+
+			// Admin tokens stop working as soon as they are revoked, rather than when they expire
+			if role == "Admin" {
+				active, err := a.sessions.AdminTokenActive(r.Context(), tokenString)
+				if err != nil {
+					client.WriteError(w, r, err)
+					return
+				}
+				if !active {
+					slog.WarnContext(r.Context(), "Admin token revoked")
+					apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+					return
+				}
+			}
+
+			// Proceed to the next handler if role matches, keeping the claims for permission checks
+			ctx := context.WithValue(r.Context(), admin.ClaimsContextKey, claims)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Permission Middleware for admin tokens, must run after authenticateMiddleware.
+// Senior tokens carry no permissions and are only gated by their role.
+func requirePermission(permission string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := r.Context().Value(admin.ClaimsContextKey).(jwt.MapClaims)
+			if !ok {
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+				return
+			}
+
+			if role, _ := claims["role"].(string); role != "Admin" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Check if the admin's role grants the permission
+			permissions, _ := claims["permissions"].([]interface{})
+			for _, granted := range permissions {
+				if granted == permission {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			slog.WarnContext(r.Context(), "Admin token lacks permission", "permission", permission)
+			apierror.Write(w, r, http.StatusForbidden, apierror.Forbidden, "Forbidden")
+		})
+	}
+}
+
+// NewRouter returns the admin endpoints of Routes, with CORS for the frontend and request logging
+func NewRouter(h *admin.Handler, health *observability.Health) http.Handler {
+	// Add CORS support, inside the middleware that tags every request with an ID and logs it
+	return logging.Middleware(handlers.CORS(
+		handlers.AllowedOrigins(sharedconfig.AllowedOrigins()),
+		handlers.AllowedMethods([]string{"GET", "POST", "PUT", "OPTIONS"}),                                                   // Update for allowed HTTP methods
+		handlers.AllowedHeaders([]string{"Content-Type", "Authorization"}),                                                   // Include Authorization header
+		handlers.ExposedHeaders([]string{pagination.TotalCountHeader, pagination.NextCursorHeader, logging.RequestIDHeader}), // Let the frontend page through lists and report request IDs
+	)(Routes(h, health)))
+}
+
+// Routes returns the admin endpoints behind their authentication middleware, alongside the probe and
+// metrics endpoints of health. Every /api/v1 route is described in the service's OpenAPI spec, in the
+// api package, which the integration harness checks against these routes.
+func Routes(h *admin.Handler, health *observability.Health) *mux.Router {
+	// Initialize the router, tracing and timing every request by its route
+	router := mux.NewRouter()
+	router.Use(tracing.Middleware(sharedconfig.AdminService), observability.Middleware)
+
+	// Unknown routes and methods get the same JSON errors as the endpoints
+	router.NotFoundHandler = apierror.NotFoundHandler()
+	router.MethodNotAllowedHandler = apierror.MethodNotAllowedHandler()
+
+	// Liveness, readiness and metrics, served without authentication
+	health.RegisterRoutes(router)
+
+	// Tokens are checked here, asking the authentication microservice about admin tokens on every request
+	authenticateMiddleware := authenticator{sessions: auth.New()}.authenticateMiddleware
+
+	// JWT Authentication Logic
+	authenticated := router.NewRoute().Subrouter()
+
+	//Admin management endpoint
+	authenticated.Handle("/api/v1/admin/getAdmin", authenticateMiddleware([]string{"Service"})(http.HandlerFunc(h.GetAdminByID))).Methods("GET")        // Called by authentication microservice
+	authenticated.Handle("/api/v1/admin/activateAdmin", authenticateMiddleware([]string{"Service"})(http.HandlerFunc(h.ActivateAdmin))).Methods("POST") // Called by authentication microservice
+	authenticated.Handle("/api/v1/admin/referrals/open", authenticateMiddleware([]string{"Service"})(http.HandlerFunc(h.OpenReferral))).Methods("POST") // Called by user microservice
+
+	//Protected admin endpoints
+	authenticated.Handle("/api/v1/admin/getAllElderlyUser", authenticateMiddleware([]string{"Admin"})(requirePermission(admin.PermissionViewSeniors)(http.HandlerFunc(h.CallUserMicroservice)))).Methods("GET")
+	authenticated.Handle("/api/v1/admin/getAllElderlyFESResponse", authenticateMiddleware([]string{"Admin"})(requirePermission(admin.PermissionViewClinical)(http.HandlerFunc(h.CallFESForUserResponse)))).Methods("GET")
+	authenticated.Handle("/api/v1/admin/getAllElderlyFESResDetails", authenticateMiddleware([]string{"Admin"})(requirePermission(admin.PermissionViewClinical)(http.HandlerFunc(h.CallFESForUserResponseDetails)))).Methods("GET")
+	authenticated.Handle("/api/v1/admin/getAllFATotalScore", authenticateMiddleware([]string{"Admin"})(requirePermission(admin.PermissionViewClinical)(http.HandlerFunc(h.CallFAForAllUserTotalScore)))).Methods("GET")
+	authenticated.Handle("/api/v1/admin/getAllFATime", authenticateMiddleware([]string{"Admin"})(requirePermission(admin.PermissionViewClinical)(http.HandlerFunc(h.CallFAForAllUserTime)))).Methods("GET")
+	authenticated.Handle("/api/v1/admin/getAllFAUserRisk", authenticateMiddleware([]string{"Admin"})(requirePermission(admin.PermissionViewClinical)(http.HandlerFunc(h.CallFAForAllUserRisk)))).Methods("GET")
+	authenticated.Handle("/api/v1/admin/getAllLastResFES", authenticateMiddleware([]string{"Admin"})(requirePermission(admin.PermissionViewSeniors)(http.HandlerFunc(h.CallFESLastResDayForAllUsers)))).Methods("GET")
+	authenticated.Handle("/api/v1/admin/getAllLastResFA", authenticateMiddleware([]string{"Admin"})(requirePermission(admin.PermissionViewSeniors)(http.HandlerFunc(h.CallFALastResDayForAllUsers)))).Methods("GET")
+	authenticated.Handle("/api/v1/admin/sendEmailAssesRemind", authenticateMiddleware([]string{"Admin"})(requirePermission(admin.PermissionSendReminders)(http.HandlerFunc(h.SendEmailHandler)))).Methods("POST")
+	authenticated.Handle("/api/v1/admin/dashboard", authenticateMiddleware([]string{"Admin"})(requirePermission(admin.PermissionViewSeniors)(http.HandlerFunc(h.GetDashboard)))).Methods("GET")
+	authenticated.Handle("/api/v1/admin/getAllFESUserRisk", authenticateMiddleware([]string{"Admin"})(requirePermission(admin.PermissionViewClinical)(http.HandlerFunc(h.CallFESUserRiskLevel)))).Methods("GET")
+	authenticated.Handle("/api/v1/admin/getAllCombinedRisk", authenticateMiddleware([]string{"Admin"})(requirePermission(admin.PermissionViewClinical)(http.HandlerFunc(h.CallUserForCombinedRisk)))).Methods("GET")
+	authenticated.Handle("/api/v1/admin/trends", authenticateMiddleware([]string{"Admin"})(requirePermission(admin.PermissionViewClinical)(http.HandlerFunc(h.GetSeniorTrends)))).Methods("GET")
+	authenticated.Handle("/api/v1/admin/trends/declining", authenticateMiddleware([]string{"Admin"})(requirePermission(admin.PermissionViewClinical)(http.HandlerFunc(h.GetDecliningSeniors)))).Methods("GET")
+	authenticated.Handle("/api/v1/admin/analytics", authenticateMiddleware([]string{"Admin"})(requirePermission(admin.PermissionViewClinical)(http.HandlerFunc(h.GetAnalytics)))).Methods("GET")
+
+	// Admin account management, super admins only
+	authenticated.Handle("/api/v1/admin/admins", authenticateMiddleware([]string{"Admin"})(requirePermission(admin.PermissionManageAdmins)(http.HandlerFunc(h.ListAdmins)))).Methods("GET")
+	authenticated.Handle("/api/v1/admin/admins/invite", authenticateMiddleware([]string{"Admin"})(requirePermission(admin.PermissionManageAdmins)(http.HandlerFunc(h.InviteAdmin)))).Methods("POST")
+	authenticated.Handle("/api/v1/admin/admins/deactivate", authenticateMiddleware([]string{"Admin"})(requirePermission(admin.PermissionManageAdmins)(http.HandlerFunc(h.DeactivateAdmin)))).Methods("POST")
+	authenticated.Handle("/api/v1/admin/admins/reset", authenticateMiddleware([]string{"Admin"})(requirePermission(admin.PermissionManageAdmins)(http.HandlerFunc(h.ResetAdmin)))).Methods("POST")
+	authenticated.Handle("/api/v1/admin/admins/reset-2fa", authenticateMiddleware([]string{"Admin"})(requirePermission(admin.PermissionManageAdmins)(http.HandlerFunc(h.ResetAdminMFA)))).Methods("POST")
+	authenticated.Handle("/api/v1/admin/admins/role", authenticateMiddleware([]string{"Admin"})(requirePermission(admin.PermissionManageAdmins)(http.HandlerFunc(h.UpdateAdminRole)))).Methods("POST")
+
+	// Clinical referral queue for high-risk seniors
+	authenticated.Handle("/api/v1/admin/referrals", authenticateMiddleware([]string{"Admin"})(requirePermission(admin.PermissionManageReferrals)(http.HandlerFunc(h.ListReferrals)))).Methods("GET")
+	authenticated.Handle("/api/v1/admin/referrals/get", authenticateMiddleware([]string{"Admin"})(requirePermission(admin.PermissionManageReferrals)(http.HandlerFunc(h.GetReferral)))).Methods("GET")
+	authenticated.Handle("/api/v1/admin/referrals/status", authenticateMiddleware([]string{"Admin"})(requirePermission(admin.PermissionManageReferrals)(http.HandlerFunc(h.UpdateReferralStatus)))).Methods("POST")
+	authenticated.Handle("/api/v1/admin/referrals/assign", authenticateMiddleware([]string{"Admin"})(requirePermission(admin.PermissionManageReferrals)(http.HandlerFunc(h.AssignReferral)))).Methods("POST")
+	authenticated.Handle("/api/v1/admin/referrals/note", authenticateMiddleware([]string{"Admin"})(requirePermission(admin.PermissionManageReferrals)(http.HandlerFunc(h.AddReferralNote)))).Methods("POST")
+
+	return router
+}
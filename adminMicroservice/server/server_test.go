@@ -0,0 +1,160 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"adminMicroservice/admin"
+	"adminMicroservice/admin/memstore"
+	"adminMicroservice/config"
+
+	"shared/observability"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// activeSessions treats every admin token as live
+type activeSessions struct{}
+
+func (activeSessions) AdminTokenActive(context.Context, string) (bool, error) { return true, nil }
+
+// adminToken signs an admin token the way the authentication microservice does, with the
+// permissions of adminRole
+func adminToken(t *testing.T, adminRole string) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id":     1,
+		"role":        "Admin",
+		"admin_role":  adminRole,
+		"permissions": admin.RolePermissions[adminRole],
+		"exp":         time.Now().Add(time.Hour).Unix(),
+	})
+	signed, err := token.SignedString([]byte(config.Current().JWTSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestRequirePermission(t *testing.T) {
+	config.Current().JWTSecret = "test-secret"
+	authenticateMiddleware := authenticator{sessions: activeSessions{}}.authenticateMiddleware
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+
+	tests := []struct {
+		adminRole  string
+		permission string
+		want       int
+	}{
+		{admin.RoleVolunteer, admin.PermissionViewClinical, http.StatusForbidden},
+		{admin.RoleVolunteer, admin.PermissionManageReferrals, http.StatusForbidden},
+		{admin.RoleVolunteer, admin.PermissionSendReminders, http.StatusOK},
+		{admin.RoleVolunteer, admin.PermissionViewSeniors, http.StatusOK},
+		{admin.RoleClinician, admin.PermissionSendReminders, http.StatusForbidden},
+		{admin.RoleClinician, admin.PermissionManageAdmins, http.StatusForbidden},
+		{admin.RoleClinician, admin.PermissionViewClinical, http.StatusOK},
+		{admin.RoleCoordinator, admin.PermissionManageAdmins, http.StatusForbidden},
+		{admin.RoleSuperAdmin, admin.PermissionManageAdmins, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.adminRole+" "+tt.permission, func(t *testing.T) {
+			handler := authenticateMiddleware([]string{"Admin"})(requirePermission(tt.permission)(ok))
+			r := httptest.NewRequest("GET", "/api/v1/admin/dashboard", nil)
+			r.Header.Set("Authorization", "Bearer "+adminToken(t, tt.adminRole))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestReferrals(t *testing.T) {
+	config.Current().JWTSecret = "test-secret"
+	store := memstore.New()
+	clinicianID := store.AddAdmin("Dr Lim", "lim@example.com", admin.RoleClinician, admin.StatusActive)
+	h := admin.NewHandler(store, admin.Clients{Auth: &memstore.AuthRecorder{}})
+	routes := Routes(h, observability.NewHealth())
+
+	// The user microservice opens referrals with its service token
+	open := func(t *testing.T, claims jwt.MapClaims) *httptest.ResponseRecorder {
+		t.Helper()
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(config.Current().JWTSecret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := httptest.NewRequest("POST", "/api/v1/admin/referrals/open", strings.NewReader(`{"user_id":7,"source":"FES","reason":"FES score of 55/64 is high concern about falling"}`))
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, r)
+		return w
+	}
+	referralID := func(t *testing.T, w *httptest.ResponseRecorder) int {
+		t.Helper()
+		var opened struct {
+			ReferralID int `json:"referral_id"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &opened); err != nil {
+			t.Fatalf("referrals/open = %d %s", w.Code, w.Body)
+		}
+		return opened.ReferralID
+	}
+
+	w := open(t, jwt.MapClaims{"role": "Service"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("referrals/open = %d %s, want %d", w.Code, w.Body, http.StatusCreated)
+	}
+	id := referralID(t, w)
+	if w := open(t, jwt.MapClaims{"role": "Service"}); w.Code != http.StatusOK || referralID(t, w) != id {
+		t.Errorf("reopening = %d %s, want %d with referral %d", w.Code, w.Body, http.StatusOK, id)
+	}
+	if w := open(t, jwt.MapClaims{"user_id": 7, "role": "User"}); w.Code != http.StatusForbidden {
+		t.Errorf("senior token: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	referral, err := store.Referral(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if referral.Status != admin.ReferralOpen || referral.AssignedAdminID == nil || *referral.AssignedAdminID != clinicianID {
+		t.Errorf("referral = %+v, want an open case assigned to clinician %d", referral, clinicianID)
+	}
+
+	// Clinicians move the case forward, never back
+	updateStatus := authenticator{sessions: activeSessions{}}.authenticateMiddleware([]string{"Admin"})(requirePermission(admin.PermissionManageReferrals)(http.HandlerFunc(h.UpdateReferralStatus)))
+	tests := []struct {
+		status string
+		want   int
+	}{
+		{admin.ReferralContacted, http.StatusOK},
+		{admin.ReferralOpen, http.StatusConflict},
+		{admin.ReferralContacted, http.StatusConflict},
+		{admin.ReferralAssessed, http.StatusOK},
+		{"Lost", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/api/v1/admin/referrals/status", strings.NewReader(fmt.Sprintf(`{"referral_id":%d,"status":%q}`, id, tt.status)))
+		r.Header.Set("Authorization", "Bearer "+adminToken(t, admin.RoleClinician))
+		w := httptest.NewRecorder()
+		updateStatus.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("moving to %s: status = %d %s, want %d", tt.status, w.Code, w.Body, tt.want)
+		}
+	}
+
+	notes, err := store.ReferralNotes(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != 3 {
+		t.Errorf("notes = %+v, want the opening and the two status changes", notes)
+	}
+}
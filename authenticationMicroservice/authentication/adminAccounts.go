@@ -0,0 +1,313 @@
+package authentication
+
+import (
+	"authenticationMicroservice/config"
+	"authenticationMicroservice/throttle"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/smtp"
+	"time"
+
+	"shared/apierror"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const adminInviteTTL = 72 * time.Hour // How long an emailed admin setup token stays valid
+
+// adminCredentialRequest is the body sent by the admin microservice when managing credentials
+type adminCredentialRequest struct {
+	AdminID int    `json:"admin_id"`
+	Email   string `json:"email"`
+}
+
+// InviteAdminCredentials creates the credential row for a newly invited admin and emails a setup token.
+// The admin profile is created by the admin microservice, which passes its ID so both rows line up.
+func (h *Handler) InviteAdminCredentials(w http.ResponseWriter, r *http.Request) {
+	var req adminCredentialRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.AdminID == 0 || req.Email == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "admin_id and email are required")
+		return
+	}
+
+	token, tokenHash, err := generateInviteToken()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error generating invite token", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	err = h.store.CreateAdminInvite(r.Context(), req.AdminID, req.Email, tokenHash, adminInviteTTL)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error creating admin credentials", "error", err)
+		apierror.Write(w, r, http.StatusConflict, apierror.AlreadyExists, "Failed to create admin credentials")
+		return
+	}
+
+	if err := h.clients.Mail.SendAdminInvite(req.Email, token); err != nil {
+		slog.ErrorContext(r.Context(), "Error sending admin invite email", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Failed to send email")
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Admin credentials created, invitation sent", "admin_id", req.AdminID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"message": "Admin invitation sent successfully"}`))
+}
+
+// ResetAdminCredentials clears an admin's password, reactivates the account and emails a new setup token
+func (h *Handler) ResetAdminCredentials(w http.ResponseWriter, r *http.Request) {
+	var req adminCredentialRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.AdminID == 0 {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "admin_id is required")
+		return
+	}
+
+	email, err := h.store.AdminEmail(r.Context(), req.AdminID)
+	if errors.Is(err, ErrNotFound) {
+		apierror.Write(w, r, http.StatusNotFound, apierror.NotFound, "Admin not found")
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "Database error", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	token, tokenHash, err := generateInviteToken()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error generating invite token", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	err = h.store.ResetAdmin(r.Context(), req.AdminID, tokenHash, adminInviteTTL)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error resetting admin credentials", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+	h.revokeAdminTokens(r.Context(), req.AdminID)
+
+	if err := h.clients.Mail.SendAdminInvite(email, token); err != nil {
+		slog.ErrorContext(r.Context(), "Error sending admin reset email", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Failed to send email")
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Admin credentials reset", "admin_id", req.AdminID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"message": "Admin credentials reset successfully"}`))
+}
+
+// DeactivateAdminCredentials stops an admin from logging in and revokes their stored tokens
+func (h *Handler) DeactivateAdminCredentials(w http.ResponseWriter, r *http.Request) {
+	var req adminCredentialRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.AdminID == 0 {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "admin_id is required")
+		return
+	}
+
+	found, err := h.store.DeactivateAdmin(r.Context(), req.AdminID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error deactivating admin credentials", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+	if !found {
+		apierror.Write(w, r, http.StatusNotFound, apierror.NotFound, "Admin not found")
+		return
+	}
+	h.revokeAdminTokens(r.Context(), req.AdminID)
+
+	slog.InfoContext(r.Context(), "Admin credentials deactivated", "admin_id", req.AdminID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"message": "Admin deactivated successfully"}`))
+}
+
+// adminTokenRequest is the body sent by the other microservices when checking an admin's token
+type adminTokenRequest struct {
+	Token string `json:"token"`
+}
+
+// CheckAdminToken tells another microservice whether an admin's token is still live, so that
+// deactivating or resetting an admin takes effect before their token expires
+func (h *Handler) CheckAdminToken(w http.ResponseWriter, r *http.Request) {
+	var req adminTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "token is required")
+		return
+	}
+
+	active, err := h.AdminTokenActive(r.Context(), req.Token)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error checking admin token", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"active": active})
+}
+
+// AdminTokenActive reports whether an admin's token is stored, unexpired and belongs to an active admin
+func (h *Handler) AdminTokenActive(ctx context.Context, token string) (bool, error) {
+	return h.store.AdminTokenActive(ctx, token)
+}
+
+// AcceptAdminInviteRequest is the body sent by an invited admin choosing their password
+type AcceptAdminInviteRequest struct {
+	Email    string `json:"email"`
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// AcceptAdminInvite lets an invited or reset admin set their password with the emailed token
+func (h *Handler) AcceptAdminInvite(w http.ResponseWriter, r *http.Request) {
+	var req AcceptAdminInviteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidBody, "Invalid input")
+		return
+	}
+	if len(req.Password) < 8 {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidField, "Password must be at least 8 characters")
+		return
+	}
+
+	// Refuse the attempt while the client address is locked out
+	ipKey := throttle.IPKey("admin-invite", h.throttle.ClientIP(r))
+	remaining, err := h.throttle.Check(r.Context(), ipKey)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error checking invite throttle", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	} else if remaining > 0 {
+		throttle.RejectLocked(w, r, remaining)
+		return
+	}
+
+	invite, err := h.store.AdminInvite(r.Context(), req.Email)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		slog.ErrorContext(r.Context(), "Database error", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	providedHash := hashInviteToken(req.Token)
+	if errors.Is(err, ErrNotFound) || !invite.Active || invite.TokenHash == "" ||
+		subtle.ConstantTimeCompare([]byte(invite.TokenHash), []byte(providedHash)) != 1 {
+		if err := h.throttle.RecordFailure(r.Context(), ipKey, throttle.IPPolicy); err != nil {
+			slog.ErrorContext(r.Context(), "Error recording invite failure", "error", err)
+		}
+		apierror.Write(w, r, http.StatusUnauthorized, apierror.InvalidToken, "Invalid or expired invitation")
+		return
+	}
+	if invite.ExpiresIn <= 0 {
+		apierror.Write(w, r, http.StatusUnauthorized, apierror.InvalidToken, "Invalid or expired invitation")
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error hashing password", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Password hashing failed")
+		return
+	}
+
+	err = h.store.SetAdminPassword(r.Context(), invite.AdminID, string(hashedPassword))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error saving admin password", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	// Mark the profile active; a failure here only affects the status shown to super admins
+	if err := h.clients.Admin.ActivateAdmin(r.Context(), invite.AdminID); err != nil {
+		slog.ErrorContext(r.Context(), "Error activating admin profile", "admin_id", invite.AdminID, "error", err)
+	}
+
+	slog.InfoContext(r.Context(), "Admin accepted invitation", "admin_id", invite.AdminID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"message": "Password set successfully"}`))
+}
+
+// generateInviteToken returns a random setup token and the hash stored in its place
+func generateInviteToken() (string, string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	token := hex.EncodeToString(raw)
+	return token, hashInviteToken(token), nil
+}
+
+// hashInviteToken hashes a setup token for storage and comparison
+func hashInviteToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// revokeAdminTokens removes the stored login tokens of an admin
+func (h *Handler) revokeAdminTokens(ctx context.Context, adminID int) {
+	if err := h.store.RevokeAdminTokens(ctx, adminID); err != nil {
+		slog.ErrorContext(ctx, "Error revoking tokens", "admin_id", adminID, "error", err)
+	}
+}
+
+// sendAdminInviteEmail emails the setup token an admin uses to choose their password
+func sendAdminInviteEmail(to, token string) error {
+	// SMTP configuration
+	mail := config.Current().SMTP
+	smtpHost, smtpPort := mail.Host, mail.Port
+	smtpUser, smtpPassword := mail.User, mail.Password
+
+	// Email content (HTML)
+	from := "FallSafe <" + smtpUser + ">"
+	subject := "Your FallSafe Admin Account"
+	body := fmt.Sprintf(`
+	<!DOCTYPE html>
+	<html lang="en">
+	<head>
+		<meta charset="UTF-8">
+		<title>FallSafe Admin Account</title>
+	</head>
+	<body style="font-family: Arial, sans-serif; color: rgb(0, 51, 153);">
+		<h1>FallSafe Admin Account</h1>
+		<p>An administrator account has been set up for you on FallSafe.</p>
+		<p>Use the following setup token on the admin portal to choose your password. It expires in %d hours.</p>
+		<p style="font-size: 16px; font-weight: bold; word-break: break-all;">%s</p>
+		<p>If you were not expecting this email, please ignore it.</p>
+		<p>Best regards,</p>
+		<p>The FallSafe Team</p>
+	</body>
+	</html>
+	`, int(adminInviteTTL.Hours()), token)
+
+	// Combine headers and body
+	message := fmt.Sprintf(
+		"From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/html; charset=UTF-8\r\n\r\n%s",
+		from, to, subject, body)
+
+	// Authentication
+	auth := smtp.PlainAuth("", smtpUser, smtpPassword, smtpHost)
+
+	// Send email
+	err := smtp.SendMail(smtpHost+":"+smtpPort, auth, smtpUser, []string{to}, []byte(message))
+	if err != nil {
+		slog.Error("Error sending email", "error", err)
+		return err
+	}
+	slog.Info("Admin invite email sent successfully")
+	return nil
+}
@@ -0,0 +1,389 @@
+package authentication
+
+import (
+	"authenticationMicroservice/throttle"
+	"authenticationMicroservice/totp"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"shared/apierror"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	mfaIssuer           = "FallSafe Admin" // Name shown in authenticator apps
+	mfaChallengeTTL     = 5 * time.Minute  // Time allowed between the password and the second factor
+	mfaChallengePurpose = "admin_mfa"      // Purpose claim that stops challenge tokens being used as access tokens
+	recoveryCodeCount   = 10               // Recovery codes issued at enrolment
+)
+
+// recoveryCodeAlphabet avoids characters that are easily confused when read aloud or typed
+const recoveryCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// MFAChallengeResponse is returned instead of a token when an admin still needs to present a second factor
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+}
+
+// respondWithMFAChallenge issues a short-lived token proving the password step succeeded.
+// It carries no role, so no microservice accepts it as an access token.
+func (h *Handler) respondWithMFAChallenge(w http.ResponseWriter, r *http.Request, adminID int) {
+	claims := jwt.MapClaims{
+		"admin_id": adminID,
+		"purpose":  mfaChallengePurpose,
+		"exp":      time.Now().Add(mfaChallengeTTL).Unix(),
+		"iat":      time.Now().Unix(),
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(h.jwtSecret))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error generating MFA challenge", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Admin passed password check, awaiting second factor", "admin_id", adminID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(MFAChallengeResponse{MFARequired: true, MFAToken: token})
+}
+
+// AdminMFALoginRequest is the second step of an admin login
+type AdminMFALoginRequest struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// AuthenticateAdminMFA completes an admin login with an authenticator code or a recovery code
+func (h *Handler) AuthenticateAdminMFA(w http.ResponseWriter, r *http.Request) {
+	var req AdminMFALoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidBody, "Invalid input")
+		return
+	}
+
+	adminID, err := h.parseMFAChallenge(req.MFAToken)
+	if err != nil {
+		slog.WarnContext(r.Context(), "Invalid MFA challenge", "error", err)
+		apierror.Write(w, r, http.StatusUnauthorized, apierror.InvalidToken, "Login session expired, please log in again")
+		return
+	}
+
+	// Second factor guesses are throttled separately from passwords
+	accountKey := throttle.AccountKey("admin-mfa", fmt.Sprintf("%d", adminID))
+	ipKey := throttle.IPKey("login", h.throttle.ClientIP(r))
+	remaining, err := h.throttle.Check(r.Context(), accountKey, ipKey)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error checking MFA throttle", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	} else if remaining > 0 {
+		throttle.RejectLocked(w, r, remaining)
+		return
+	}
+
+	var verified bool
+	if req.RecoveryCode != "" {
+		verified, err = h.useRecoveryCode(r.Context(), adminID, req.RecoveryCode)
+	} else {
+		verified, err = h.verifyAdminTOTP(r.Context(), adminID, req.Code, true)
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error verifying second factor", "admin_id", adminID, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+	if !verified {
+		h.recordLoginFailure(r.Context(), accountKey, ipKey)
+		apierror.Write(w, r, http.StatusUnauthorized, apierror.InvalidCode, "Invalid authentication code")
+		return
+	}
+
+	if err := h.throttle.Reset(r.Context(), accountKey); err != nil {
+		slog.ErrorContext(r.Context(), "Error resetting MFA throttle", "error", err)
+	}
+	h.respondWithAdminToken(w, r, adminID)
+}
+
+// EnrollAdminMFA starts enrolment for the calling admin, returning the secret and the
+// provisioning URI to show as a QR code. Two-factor is not enforced until VerifyAdminMFAEnrollment.
+func (h *Handler) EnrollAdminMFA(w http.ResponseWriter, r *http.Request) {
+	adminID, email, ok := claimedAdmin(r)
+	if !ok {
+		apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+		return
+	}
+
+	enabled, err := h.store.TOTPEnabled(r.Context(), adminID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Database error", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+	if enabled {
+		apierror.Write(w, r, http.StatusConflict, apierror.AlreadyExists, "Two-factor authentication is already enabled")
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error generating TOTP secret", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	err = h.store.SetTOTPSecret(r.Context(), adminID, secret)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error saving TOTP secret", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"secret":           secret,
+		"provisioning_uri": totp.ProvisioningURI(mfaIssuer, email, secret),
+	})
+}
+
+// VerifyAdminMFAEnrollment confirms the authenticator app works, turns two-factor on
+// and returns the recovery codes, which are only ever shown this once
+func (h *Handler) VerifyAdminMFAEnrollment(w http.ResponseWriter, r *http.Request) {
+	adminID, _, ok := claimedAdmin(r)
+	if !ok {
+		apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+		return
+	}
+
+	var req AdminMFALoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "code is required")
+		return
+	}
+
+	verified, err := h.verifyAdminTOTP(r.Context(), adminID, req.Code, false)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error verifying TOTP enrolment", "admin_id", adminID, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+	if !verified {
+		apierror.Write(w, r, http.StatusUnauthorized, apierror.InvalidCode, "Invalid authentication code")
+		return
+	}
+
+	if err := h.store.EnableTOTP(r.Context(), adminID); err != nil {
+		slog.ErrorContext(r.Context(), "Error enabling TOTP", "admin_id", adminID, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	codes, err := h.replaceRecoveryCodes(r.Context(), adminID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error generating recovery codes", "admin_id", adminID, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Admin enabled two-factor authentication", "admin_id", adminID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+// RegenerateRecoveryCodes replaces the calling admin's recovery codes after checking a current code
+func (h *Handler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	adminID, _, ok := claimedAdmin(r)
+	if !ok {
+		apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+		return
+	}
+
+	var req AdminMFALoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "code is required")
+		return
+	}
+
+	verified, err := h.verifyAdminTOTP(r.Context(), adminID, req.Code, true)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error verifying TOTP", "admin_id", adminID, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+	if !verified {
+		apierror.Write(w, r, http.StatusUnauthorized, apierror.InvalidCode, "Invalid authentication code")
+		return
+	}
+
+	codes, err := h.replaceRecoveryCodes(r.Context(), adminID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error generating recovery codes", "admin_id", adminID, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"recovery_codes": codes})
+}
+
+// ResetAdminMFA removes two-factor authentication from an admin who lost their device,
+// so they can log in with their password and enrol again. Called by the admin microservice.
+func (h *Handler) ResetAdminMFA(w http.ResponseWriter, r *http.Request) {
+	var req adminCredentialRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.AdminID == 0 {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "admin_id is required")
+		return
+	}
+
+	found, err := h.store.ResetTOTP(r.Context(), req.AdminID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error resetting two-factor", "admin_id", req.AdminID, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+	if !found {
+		apierror.Write(w, r, http.StatusNotFound, apierror.NotFound, "Admin not found")
+		return
+	}
+	if err := h.store.ReplaceRecoveryCodes(r.Context(), req.AdminID, nil); err != nil {
+		slog.ErrorContext(r.Context(), "Error deleting recovery codes", "admin_id", req.AdminID, "error", err)
+	}
+	h.revokeAdminTokens(r.Context(), req.AdminID)
+
+	slog.InfoContext(r.Context(), "Admin two-factor authentication reset", "admin_id", req.AdminID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"message": "Two-factor authentication reset successfully"}`))
+}
+
+// claimedAdmin returns the admin ID and email from the caller's token
+func claimedAdmin(r *http.Request) (int, string, bool) {
+	claims, ok := r.Context().Value(ClaimsContextKey).(jwt.MapClaims)
+	if !ok {
+		return 0, "", false
+	}
+	id, ok := claims["user_id"].(float64)
+	email, _ := claims["email"].(string)
+	return int(id), email, ok
+}
+
+// parseMFAChallenge validates a challenge token and returns the admin it was issued to
+func (h *Handler) parseMFAChallenge(tokenString string) (int, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method")
+		}
+		return []byte(h.jwtSecret), nil
+	})
+	if err != nil || !token.Valid {
+		return 0, fmt.Errorf("invalid challenge token: %v", err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != mfaChallengePurpose {
+		return 0, fmt.Errorf("token is not an MFA challenge")
+	}
+	adminID, ok := claims["admin_id"].(float64)
+	if !ok {
+		return 0, fmt.Errorf("challenge token has no admin_id")
+	}
+	return int(adminID), nil
+}
+
+// verifyAdminTOTP checks a code against the admin's secret. Each time step is accepted at most
+// once so an observed code cannot be replayed. requireEnabled is false only during enrolment.
+func (h *Handler) verifyAdminTOTP(ctx context.Context, adminID int, code string, requireEnabled bool) (bool, error) {
+	return h.store.UseTOTPStep(ctx, adminID, requireEnabled, func(secret string) (int64, bool) {
+		return totp.Validate(secret, code, time.Now())
+	})
+}
+
+// useRecoveryCode consumes one of the admin's unused recovery codes if the given code matches
+func (h *Handler) useRecoveryCode(ctx context.Context, adminID int, code string) (bool, error) {
+	code = normaliseRecoveryCode(code)
+
+	codes, err := h.store.UnusedRecoveryCodes(ctx, adminID)
+	if err != nil {
+		return false, err
+	}
+
+	matchedID := 0
+	for _, unused := range codes {
+		if bcrypt.CompareHashAndPassword([]byte(unused.CodeHash), []byte(code)) == nil {
+			matchedID = unused.CodeID
+			break
+		}
+	}
+	if matchedID == 0 {
+		return false, nil
+	}
+
+	// Mark the code used, failing if a concurrent login used it first
+	used, err := h.store.UseRecoveryCode(ctx, matchedID)
+	if err != nil {
+		return false, err
+	}
+	if used {
+		slog.InfoContext(ctx, "Admin logged in with a recovery code", "admin_id", adminID)
+	}
+	return used, nil
+}
+
+// replaceRecoveryCodes discards the admin's recovery codes and stores a fresh set, returning them in plain text
+func (h *Handler) replaceRecoveryCodes(ctx context.Context, adminID int) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		hashed, err := bcrypt.GenerateFromPassword([]byte(normaliseRecoveryCode(code)), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, string(hashed))
+	}
+
+	if err := h.store.ReplaceRecoveryCodes(ctx, adminID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// generateRecoveryCode returns a random code formatted as XXXXX-XXXXX
+func generateRecoveryCode() (string, error) {
+	var b strings.Builder
+	for i := 0; i < 10; i++ {
+		if i == 5 {
+			b.WriteByte('-')
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(recoveryCodeAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(recoveryCodeAlphabet[n.Int64()])
+	}
+	return b.String(), nil
+}
+
+// normaliseRecoveryCode ignores case, spaces and dashes so codes can be typed loosely
+func normaliseRecoveryCode(code string) string {
+	code = strings.ToUpper(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
@@ -1,52 +1,93 @@
 package authentication
 
 import (
-	"database/sql"
+	"authenticationMicroservice/client/admin"
+	"authenticationMicroservice/client/user"
+	"authenticationMicroservice/registration"
+	"authenticationMicroservice/throttle"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"shared/apierror"
+
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/joho/godotenv"
 	"golang.org/x/crypto/bcrypt"
 )
 
-var db *sql.DB
-var jwtSecret string // JWT secret key loaded from environment variables
+// UserClient reads the senior profiles put into tokens and checks caregiver invitations
+type UserClient interface {
+	User(ctx context.Context, userID int) (user.User, error)
+	VerifyCaregiverInvite(ctx context.Context, email, token string) (bool, error)
+}
 
-func init() {
-	// Load environment variables
-	err := godotenv.Load(".env")
-	if err != nil {
-		log.Fatalf("Error loading .env file: %v", err)
-	}
+// AdminClient reads the admin profiles put into tokens and activates admins who accept their invitation
+type AdminClient interface {
+	Admin(ctx context.Context, adminID int) (admin.Admin, error)
+	ActivateAdmin(ctx context.Context, adminID int) error
+}
 
-	// Initialize database connection
-	log.Println("Initializing database connection...")
-	db, err = sql.Open("mysql", os.Getenv("AUTH_DB_CONNECTION"))
-	if err != nil {
-		log.Fatalf("Error connecting to database: %v", err)
-	}
+// Mailer sends the emails of admin invitations and passwordless logins
+type Mailer interface {
+	SendAdminInvite(to, token string) error
+	SendLoginCode(to, code, linkToken string) error
+}
 
-	// Test the database connection
-	err = db.Ping()
-	if err != nil {
-		log.Fatalf("Database connection test failed: %v", err)
-	}
-	log.Println("Database connection successful.")
+// Clients are the microservices holding the profiles put into tokens, and the mailer
+type Clients struct {
+	User  UserClient
+	Admin AdminClient
+	Mail  Mailer
+}
 
-	// Load JWT secret
-	jwtSecret = os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		log.Fatalf("JWT_SECRET not set in .env")
+// NewClients returns the clients of the microservices at their configured URLs and the SMTP mailer
+func NewClients() Clients {
+	return Clients{
+		User:  user.New(),
+		Admin: admin.New(),
+		Mail:  SMTPMailer{},
 	}
 }
 
+// SMTPMailer sends emails through the SMTP account in the .env file
+type SMTPMailer struct{}
+
+// SendAdminInvite emails the setup token an admin uses to choose their password
+func (SMTPMailer) SendAdminInvite(to, token string) error {
+	return sendAdminInviteEmail(to, token)
+}
+
+// SendLoginCode emails the one-time code and/or magic link, whichever is not empty
+func (SMTPMailer) SendLoginCode(to, code, linkToken string) error {
+	return sendLoginCodeEmail(to, code, linkToken)
+}
+
+// Handler serves the login and credential endpoints from its store
+type Handler struct {
+	store         CredentialStore
+	clients       Clients
+	throttle      *throttle.Throttle
+	registrations *registration.Handler
+	jwtSecret     string // Key the issued tokens are signed with
+}
+
+// NewHandler returns the authentication handlers, reading and writing store, calling the other microservices
+// with clients, locking out accounts and addresses with throttle, finishing pending registrations with
+// registrations and signing tokens with jwtSecret
+func NewHandler(store CredentialStore, clients Clients, throttle *throttle.Throttle, registrations *registration.Handler, jwtSecret string) *Handler {
+	return &Handler{store: store, clients: clients, throttle: throttle, registrations: registrations, jwtSecret: jwtSecret}
+}
+
+// contextKey is used to store values on the request context
+type contextKey string
+
+// ClaimsContextKey holds the validated JWT claims set by the authentication middleware
+const ClaimsContextKey contextKey = "claims"
+
 // LoginRequest represents the structure of a login request
 type LoginRequest struct {
 	Email    string `json:"email"`
@@ -58,245 +99,283 @@ type LoginResponse struct {
 	Token string `json:"token"`
 }
 
-func AuthenticateUser(w http.ResponseWriter, r *http.Request) {
-	log.Println("Handling /login request...")
+func (h *Handler) AuthenticateUser(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Handling /login request")
 
 	// Parse the incoming request
 	var loginRequest LoginRequest
 	err := json.NewDecoder(r.Body).Decode(&loginRequest)
 	if err != nil {
-		log.Printf("Error parsing request body: %v", err)
-		http.Error(w, "Invalid input", http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "Error parsing request body", "error", err)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidBody, "Invalid input")
+		return
+	}
+	slog.DebugContext(r.Context(), "Parsed login request")
+
+	// Deployments may switch seniors to passwordless login only
+	if !LoginMethodEnabled(LoginMethodPassword) {
+		apierror.Write(w, r, http.StatusForbidden, apierror.LoginMethodDisabled, "Password login is disabled")
+		return
+	}
+
+	// Refuse the attempt while the account or client address is locked out
+	accountKey := throttle.AccountKey("user", loginRequest.Email)
+	ipKey := throttle.IPKey("login", h.throttle.ClientIP(r))
+	remaining, err := h.throttle.Check(r.Context(), accountKey, ipKey)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error checking login throttle", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	} else if remaining > 0 {
+		slog.WarnContext(r.Context(), "Login attempt rejected while locked out", "remaining", remaining.String())
+		throttle.RejectLocked(w, r, remaining)
 		return
 	}
-	log.Printf("Parsed login request: %+v", loginRequest)
 
 	// Fetch user details from the `User` table
-	var hashedPassword string
-	var userID int
-	log.Println("Fetching user details from the User table...")
-	err = db.QueryRow("SELECT user_id, password FROM User WHERE email = ?", loginRequest.Email).Scan(&userID, &hashedPassword)
-	if err == sql.ErrNoRows {
-		log.Println("Email not found.")
-		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
+	slog.DebugContext(r.Context(), "Fetching user details from the User table")
+	credentials, err := h.store.User(r.Context(), loginRequest.Email)
+	if errors.Is(err, ErrNotFound) || (err == nil && credentials.PasswordHash == "") {
+		// Emails that never finished registering have no password
+		slog.WarnContext(r.Context(), "Email not found")
+		h.recordLoginFailure(r.Context(), accountKey, ipKey)
+		apierror.Write(w, r, http.StatusUnauthorized, apierror.InvalidCredentials, "Invalid email or password")
 		return
 	} else if err != nil {
-		log.Printf("Database error: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Database error", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
 		return
 	}
 
 	// Verify the password
-	log.Println("Verifying password...")
-	err = bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(loginRequest.Password))
+	slog.DebugContext(r.Context(), "Verifying password")
+	err = bcrypt.CompareHashAndPassword([]byte(credentials.PasswordHash), []byte(loginRequest.Password))
 	if err != nil {
-		log.Println("Invalid password.")
-		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
+		slog.WarnContext(r.Context(), "Invalid password")
+		h.recordLoginFailure(r.Context(), accountKey, ipKey)
+		apierror.Write(w, r, http.StatusUnauthorized, apierror.InvalidCredentials, "Invalid email or password")
 		return
 	}
 
+	// Clear the account's failure history now that the password is correct
+	if err := h.throttle.Reset(r.Context(), accountKey); err != nil {
+		slog.ErrorContext(r.Context(), "Error resetting login throttle", "error", err)
+	}
+
+	if !h.ensureRegistered(w, r, credentials.UserID, credentials.RegistrationStatus) {
+		return
+	}
+	h.respondWithUserToken(w, r, credentials.UserID)
+}
+
+// ensureRegistered finishes a registration whose profile is still pending before a senior logs in,
+// writing a retry response and returning false if the profile still cannot be created
+func (h *Handler) ensureRegistered(w http.ResponseWriter, r *http.Request, userID int, registrationStatus string) bool {
+	if registrationStatus == registration.StatusRegistered {
+		return true
+	}
+
+	registered, err := h.registrations.CompleteRegistration(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error completing pending registration", "user_id", userID, "error", err)
+	}
+	if !registered {
+		w.Header().Set("Retry-After", "60")
+		apierror.Write(w, r, http.StatusServiceUnavailable, apierror.AccountPending, "Your account is still being set up, please try again shortly")
+		return false
+	}
+	return true
+}
+
+// respondWithUserToken issues and stores a senior's JWT, then writes it as the login response
+func (h *Handler) respondWithUserToken(w http.ResponseWriter, r *http.Request, userID int) {
 	// Generate JWT token
-	log.Println("Generating JWT token...")
-	token, expiryTime, err := generateJWT(userID)
+	slog.DebugContext(r.Context(), "Generating JWT token")
+	token, expiryTime, err := h.generateJWT(r.Context(), userID)
 	if err != nil {
-		log.Printf("Error generating JWT token: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error generating JWT token", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
 		return
 	}
 
 	// Store the token in the `UserAuthentication` table
-	log.Println("Storing token in the UserAuthentication table...")
-	_, err = db.Exec(`
-		INSERT INTO UserAuthentication (user_id, auth_token, token_expiry)
-		VALUES (?, ?, ?)
-		ON DUPLICATE KEY UPDATE
-		auth_token = VALUES(auth_token), token_expiry = VALUES(token_expiry)`,
-		userID, token, expiryTime)
+	slog.DebugContext(r.Context(), "Storing token in the UserAuthentication table")
+	err = h.store.SaveUserToken(r.Context(), userID, token, expiryTime)
 	if err != nil {
-		log.Printf("Error storing token in the database: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error storing token in the database", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
 		return
 	}
 
 	// Respond with the token
-	log.Println("Login successful. Returning token...")
+	slog.InfoContext(r.Context(), "Login successful")
 	response := LoginResponse{Token: token}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
 
-func AuthenticateAdmin(w http.ResponseWriter, r *http.Request) {
-	log.Println("Handling /admin/login request...")
+func (h *Handler) AuthenticateAdmin(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Handling /admin/login request")
 
 	// Parse the incoming request
 	var loginRequest LoginRequest
 	err := json.NewDecoder(r.Body).Decode(&loginRequest)
 	if err != nil {
-		log.Printf("Error parsing request body: %v", err)
-		http.Error(w, "Invalid input", http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "Error parsing request body", "error", err)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidBody, "Invalid input")
+		return
+	}
+	slog.DebugContext(r.Context(), "Parsed login request")
+
+	// Refuse the attempt while the account or client address is locked out
+	accountKey := throttle.AccountKey("admin", loginRequest.Email)
+	ipKey := throttle.IPKey("login", h.throttle.ClientIP(r))
+	remaining, err := h.throttle.Check(r.Context(), accountKey, ipKey)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error checking login throttle", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	} else if remaining > 0 {
+		slog.WarnContext(r.Context(), "Login attempt rejected while locked out", "remaining", remaining.String())
+		throttle.RejectLocked(w, r, remaining)
 		return
 	}
-	log.Printf("Parsed login request: %+v", loginRequest)
 
 	// Fetch Admin details from the `Admin` table
-	var hashedPassword string
-	var adminID int
-	log.Println("Fetching admin details from the Admin table...")
-	err = db.QueryRow("SELECT admin_id, password FROM Admin WHERE email = ?", loginRequest.Email).Scan(&adminID, &hashedPassword)
-	if err == sql.ErrNoRows {
-		log.Println("Email not found.")
-		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
+	slog.DebugContext(r.Context(), "Fetching admin details from the Admin table")
+	credentials, err := h.store.Admin(r.Context(), loginRequest.Email)
+	if errors.Is(err, ErrNotFound) || (err == nil && (!credentials.Active || credentials.PasswordHash == "")) {
+		// Deactivated admins and invitations not yet accepted cannot log in
+		slog.WarnContext(r.Context(), "Email not found or admin account not active")
+		h.recordLoginFailure(r.Context(), accountKey, ipKey)
+		apierror.Write(w, r, http.StatusUnauthorized, apierror.InvalidCredentials, "Invalid email or password")
 		return
 	} else if err != nil {
-		log.Printf("Database error: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Database error", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
 		return
 	}
 
 	// Verify the password
-	log.Println("Verifying password...")
-	err = bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(loginRequest.Password))
+	slog.DebugContext(r.Context(), "Verifying password")
+	err = bcrypt.CompareHashAndPassword([]byte(credentials.PasswordHash), []byte(loginRequest.Password))
 	if err != nil {
-		log.Println("Invalid password.")
-		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
+		slog.WarnContext(r.Context(), "Invalid password")
+		h.recordLoginFailure(r.Context(), accountKey, ipKey)
+		apierror.Write(w, r, http.StatusUnauthorized, apierror.InvalidCredentials, "Invalid email or password")
+		return
+	}
+
+	// Clear the account's failure history now that the password is correct
+	if err := h.throttle.Reset(r.Context(), accountKey); err != nil {
+		slog.ErrorContext(r.Context(), "Error resetting login throttle", "error", err)
+	}
+
+	// Admins enrolled in two-factor authentication must still present a code
+	if credentials.TOTPEnabled {
+		h.respondWithMFAChallenge(w, r, credentials.AdminID)
 		return
 	}
 
+	h.respondWithAdminToken(w, r, credentials.AdminID)
+}
+
+// respondWithAdminToken issues and stores an admin's JWT, then writes it as the login response
+func (h *Handler) respondWithAdminToken(w http.ResponseWriter, r *http.Request, adminID int) {
 	// Generate Admin JWT Token
-	log.Println("Generating JWT token...")
-	token, expiryTime, err := generateAdminJWT(adminID)
+	slog.DebugContext(r.Context(), "Generating JWT token")
+	token, expiryTime, err := h.generateAdminJWT(r.Context(), adminID)
 	if err != nil {
-		log.Printf("Error generating JWT token: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error generating JWT token", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
 		return
 	}
 
 	// Store the token in the `AdminAuthentication` table
-	log.Println("Storing token in the AdminAuthentication table...")
-	_, err = db.Exec(`
-		INSERT INTO AdminAuthentication (admin_id, auth_token, token_expiry)
-		VALUES (?, ?, ?)
-		ON DUPLICATE KEY UPDATE
-		auth_token = VALUES(auth_token), token_expiry = VALUES(token_expiry)`,
-		adminID, token, expiryTime)
+	slog.DebugContext(r.Context(), "Storing token in the AdminAuthentication table")
+	err = h.store.SaveAdminToken(r.Context(), adminID, token, expiryTime)
 	if err != nil {
-		log.Printf("Error storing token in the database: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error storing token in the database", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
 		return
 	}
 
 	// Respond with the token
-	log.Println("Login successful. Returning token...")
+	slog.InfoContext(r.Context(), "Login successful")
 	response := LoginResponse{Token: token}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
 
-// User structure to hold the response from the GetUserByID endpoint
-type User struct {
-	ID          int    `json:"user_id"`
-	Name        string `json:"name"`
-	Email       string `json:"email"`
-	PhoneNumber string `json:"phone_number"`
-	Address     string `json:"address"`
-	Age         string `json:"age"`
+// recordLoginFailure counts a failed login against both the account and the client address
+func (h *Handler) recordLoginFailure(ctx context.Context, accountKey, ipKey string) {
+	if err := h.throttle.RecordFailure(ctx, accountKey, throttle.AccountPolicy); err != nil {
+		slog.ErrorContext(ctx, "Error recording account login failure", "error", err)
+	}
+	if err := h.throttle.RecordFailure(ctx, ipKey, throttle.IPPolicy); err != nil {
+		slog.ErrorContext(ctx, "Error recording client login failure", "error", err)
+	}
 }
 
 // generateJWT now fetches user details and includes all values in the JWT
-func generateJWT(userID int) (string, time.Time, error) {
+func (h *Handler) generateJWT(ctx context.Context, userID int) (string, time.Time, error) {
 	expiryTime := time.Now().Add(24 * time.Hour)
 
-	// Fetch user details from the API
-	url := fmt.Sprintf("http://18.143.103.158:5100/api/v1/user/getUser?userID=%d", userID)
-	resp, err := http.Get(url)
+	// Fetch user details from the user microservice
+	profile, err := h.clients.User.User(ctx, userID)
 	if err != nil {
-		log.Printf("Error calling GetUserByID API: %v", err)
-		return "", expiryTime, err
-	}
-	defer resp.Body.Close()
-
-	// Check if the response status is OK
-	if resp.StatusCode != http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		log.Printf("Failed to fetch user details: %s", body)
-		return "", expiryTime, fmt.Errorf("failed to fetch user details, status code: %d", resp.StatusCode)
-	}
-
-	// Parse the response body
-	var user User
-	err = json.NewDecoder(resp.Body).Decode(&user)
-	if err != nil {
-		log.Printf("Error decoding user response: %v", err)
+		slog.ErrorContext(ctx, "Error fetching user details", "error", err)
 		return "", expiryTime, err
 	}
 
 	// Generate JWT claims including all user fields
 	claims := jwt.MapClaims{
-		"user_id":      user.ID,
-		"name":         user.Name,
-		"email":        user.Email,
-		"phone_number": user.PhoneNumber,
-		"address":      user.Address,
-		"age":          user.Age,
+		"user_id":      profile.UserID,
+		"name":         profile.Name,
+		"email":        profile.Email,
+		"phone_number": profile.PhoneNumber,
+		"address":      profile.Address,
+		"age":          profile.Age,
 		"role":         "User",
 		"exp":          expiryTime.Unix(),
 		"iat":          time.Now().Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString([]byte(jwtSecret))
+	signedToken, err := token.SignedString([]byte(h.jwtSecret))
 	return signedToken, expiryTime, err
 }
 
-// Struct to hold Admin response from getAdminById in Admin endpoint
-type Admin struct {
-	UserID int    `json:"user_id"`
-	Name   string `json:"name" `
-	Email  string `json:"email"`
-	Role   string `json:"role"`
-}
-
 // generatesJWTtoken for ADMIN, includes all value inside
-func generateAdminJWT(adminID int) (string, time.Time, error) {
+func (h *Handler) generateAdminJWT(ctx context.Context, adminID int) (string, time.Time, error) {
 	expiryTime := time.Now().Add(24 * time.Hour)
 
-	// Fetch user details from the API
-	url := fmt.Sprintf("http://18.143.103.158:5200/api/v1/admin/getAdmin?adminID=%d", adminID)
-	resp, err := http.Get(url)
+	// Fetch admin details from the admin microservice
+	profile, err := h.clients.Admin.Admin(ctx, adminID)
 	if err != nil {
-		log.Printf("Error calling GetAdminByID API: %v", err)
+		slog.ErrorContext(ctx, "Error fetching admin details", "error", err)
 		return "", expiryTime, err
 	}
-	defer resp.Body.Close()
 
-	// Check if the response status is OK
-	if resp.StatusCode != http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		log.Printf("Failed to fetch admin details: %s", body)
-		return "", expiryTime, fmt.Errorf("failed to fetch admin details, status code: %d", resp.StatusCode)
-	}
-
-	// Parse the response body
-	var admin Admin
-	err = json.NewDecoder(resp.Body).Decode(&admin)
-	if err != nil {
-		log.Printf("Error decoding admin response: %v", err)
-		return "", expiryTime, err
+	if profile.Status == "Deactivated" {
+		return "", expiryTime, fmt.Errorf("admin %d is deactivated", adminID)
 	}
 
 	// Generate JWT claims including all admin fields
 	claims := jwt.MapClaims{
-		"user_id": admin.UserID,
-		"name":    admin.Name,
-		"email":   admin.Email,
-		"role":    admin.Role, // Include role in the JWT claims
-		"exp":     expiryTime.Unix(),
-		"iat":     time.Now().Unix(),
+		"user_id":     profile.UserID,
+		"name":        profile.Name,
+		"email":       profile.Email,
+		"role":        profile.Role,        // Include role in the JWT claims
+		"admin_role":  profile.AdminRole,   // Finer-grained role within the admin team
+		"permissions": profile.Permissions, // Enforced by each microservice's permission middleware
+		"exp":         expiryTime.Unix(),
+		"iat":         time.Now().Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString([]byte(jwtSecret))
+	signedToken, err := token.SignedString([]byte(h.jwtSecret))
 	return signedToken, expiryTime, err
 }
@@ -0,0 +1,164 @@
+package authentication
+
+import (
+	"authenticationMicroservice/throttle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"shared/apierror"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// RegisterCaregiverRequest is the body sent by an invited caregiver creating their account
+type RegisterCaregiverRequest struct {
+	Email       string `json:"email"`
+	Name        string `json:"name"`
+	Password    string `json:"password"`
+	InviteToken string `json:"invite_token"`
+}
+
+// RegisterCaregiver creates a caregiver account for an email a senior has invited.
+// The invitation itself stays pending until the caregiver accepts it on the user microservice.
+func (h *Handler) RegisterCaregiver(w http.ResponseWriter, r *http.Request) {
+	var req RegisterCaregiverRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidBody, "Invalid input")
+		return
+	}
+	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
+	if req.Email == "" || req.Name == "" || req.InviteToken == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "email, name and invite_token are required")
+		return
+	}
+	if len(req.Password) < 8 {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidField, "Password must be at least 8 characters")
+		return
+	}
+
+	// Refuse the attempt while the client address is locked out
+	ipKey := throttle.IPKey("caregiver-register", h.throttle.ClientIP(r))
+	remaining, err := h.throttle.Check(r.Context(), ipKey)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error checking caregiver registration throttle", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	} else if remaining > 0 {
+		throttle.RejectLocked(w, r, remaining)
+		return
+	}
+
+	// Only emails a senior has invited may register as caregivers
+	valid, err := h.clients.User.VerifyCaregiverInvite(r.Context(), req.Email, req.InviteToken)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error verifying caregiver invitation", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Failed to verify invitation")
+		return
+	}
+	if !valid {
+		if err := h.throttle.RecordFailure(r.Context(), ipKey, throttle.IPPolicy); err != nil {
+			slog.ErrorContext(r.Context(), "Error recording caregiver registration failure", "error", err)
+		}
+		apierror.Write(w, r, http.StatusUnauthorized, apierror.InvalidToken, "Invalid invitation")
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error hashing password", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Password hashing failed")
+		return
+	}
+
+	err = h.store.CreateCaregiver(r.Context(), req.Email, req.Name, string(hashedPassword))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error creating caregiver account", "error", err)
+		apierror.Write(w, r, http.StatusConflict, apierror.AlreadyExists, "Caregiver account already exists")
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Caregiver account created")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write([]byte(`{"message": "Caregiver registered successfully"}`))
+}
+
+// AuthenticateCaregiver logs a caregiver in and issues a token with the Caregiver role
+func (h *Handler) AuthenticateCaregiver(w http.ResponseWriter, r *http.Request) {
+	var loginRequest LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&loginRequest); err != nil {
+		slog.WarnContext(r.Context(), "Error parsing request body", "error", err)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidBody, "Invalid input")
+		return
+	}
+
+	// Refuse the attempt while the account or client address is locked out
+	accountKey := throttle.AccountKey("caregiver", loginRequest.Email)
+	ipKey := throttle.IPKey("login", h.throttle.ClientIP(r))
+	remaining, err := h.throttle.Check(r.Context(), accountKey, ipKey)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error checking login throttle", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	} else if remaining > 0 {
+		slog.WarnContext(r.Context(), "Login attempt rejected while locked out", "remaining", remaining.String())
+		throttle.RejectLocked(w, r, remaining)
+		return
+	}
+
+	caregiver, err := h.store.Caregiver(r.Context(), strings.ToLower(strings.TrimSpace(loginRequest.Email)))
+	if errors.Is(err, ErrNotFound) {
+		h.recordLoginFailure(r.Context(), accountKey, ipKey)
+		apierror.Write(w, r, http.StatusUnauthorized, apierror.InvalidCredentials, "Invalid email or password")
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "Database error", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(caregiver.PasswordHash), []byte(loginRequest.Password)); err != nil {
+		h.recordLoginFailure(r.Context(), accountKey, ipKey)
+		apierror.Write(w, r, http.StatusUnauthorized, apierror.InvalidCredentials, "Invalid email or password")
+		return
+	}
+
+	// Clear the account's failure history now that the password is correct
+	if err := h.throttle.Reset(r.Context(), accountKey); err != nil {
+		slog.ErrorContext(r.Context(), "Error resetting login throttle", "error", err)
+	}
+
+	token, err := h.generateCaregiverJWT(caregiver.CaregiverID, caregiver.Name, caregiver.Email)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error generating JWT token", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(LoginResponse{Token: token})
+}
+
+// generateCaregiverJWT issues a token whose user_id is the caregiver's ID.
+// Which seniors the caregiver can see is checked per request against the senior's consent.
+func (h *Handler) generateCaregiverJWT(caregiverID int, name, email string) (string, error) {
+	expiryTime := time.Now().Add(24 * time.Hour)
+
+	claims := jwt.MapClaims{
+		"user_id": caregiverID,
+		"name":    name,
+		"email":   email,
+		"role":    "Caregiver",
+		"exp":     expiryTime.Unix(),
+		"iat":     time.Now().Unix(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(h.jwtSecret))
+}
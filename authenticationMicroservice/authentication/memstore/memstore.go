@@ -0,0 +1,1019 @@
+// Package memstore keeps the authentication database in memory, for testing the authentication,
+// registration and throttle handlers with httptest without a database:
+//
+//	store := memstore.New()
+//	userID := store.AddUser("ahkow@example.com", "password123")
+//	directory := &memstore.Directory{}
+//	directory.AddUser(user.User{UserID: userID, Name: "Tan Ah Kow", Email: "ahkow@example.com"})
+//	mail := &memstore.MailRecorder{}
+//	loginThrottle := throttle.New(store, nil)
+//	registrations := registration.NewHandler(store, registration.Clients{User: directory, Mail: mail}, loginThrottle)
+//	handler := authentication.NewHandler(store, authentication.Clients{User: directory, Admin: directory, Mail: mail}, loginThrottle, registrations, "secret")
+//	handler.AuthenticateUser(recorder, request)
+package memstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"authenticationMicroservice/authentication"
+	"authenticationMicroservice/client/admin"
+	"authenticationMicroservice/client/user"
+	"authenticationMicroservice/registration"
+	"authenticationMicroservice/throttle"
+
+	"shared/client"
+	"shared/config"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Outbox entry statuses, as stored by the registration package
+const (
+	outboxPending     = "Pending"
+	outboxCompleted   = "Completed"
+	outboxCompensated = "Compensated"
+)
+
+// Store is the authentication database held in memory. It is an authentication.CredentialStore,
+// a registration.Store and a throttle.Store at once, so a registration can be followed by a login.
+// It enforces the unique emails the database does, and measures code ages, invitation expiries
+// and lockouts with its own clock, to the second like the database.
+type Store struct {
+	mu            sync.Mutex
+	users         []userRow
+	outbox        []outboxRow
+	loginCodes    map[int]loginCodeRow
+	userTokens    map[int]string
+	admins        []adminRow
+	adminTokens   map[int]adminTokenRow
+	recoveryCodes []recoveryCodeRow
+	caregivers    []authentication.CaregiverCredentials
+	throttles     map[string]throttleRow
+
+	// Now is the clock codes, invitations and lockouts are dated with, time.Now unless a test replaces it
+	Now func() time.Time
+}
+
+type userRow struct {
+	userID               int
+	email                string
+	passwordHash         string
+	verificationCode     string
+	verificationAttempts int
+	registrationStatus   string
+	createdAt            time.Time
+}
+
+type outboxRow struct {
+	outboxID      int
+	userID        int
+	payload       string
+	status        string
+	attempts      int
+	nextAttemptAt time.Time
+	lastError     string
+}
+
+type loginCodeRow struct {
+	codeHash      string
+	linkTokenHash string
+	attempts      int
+	createdAt     time.Time
+}
+
+type adminRow struct {
+	adminID      int
+	email        string
+	passwordHash string
+	active       bool
+	inviteToken  string
+	inviteExpiry time.Time // Zero when there is no setup token
+	totpSecret   string
+	totpEnabled  bool
+	totpLastStep *int64
+}
+
+type adminTokenRow struct {
+	token  string
+	expiry time.Time
+}
+
+type recoveryCodeRow struct {
+	authentication.RecoveryCode
+	adminID int
+	used    bool
+}
+
+type throttleRow struct {
+	failures     int
+	lockedUntil  time.Time // Zero when the key is not locked
+	lastFailedAt time.Time
+}
+
+var (
+	_ authentication.CredentialStore = (*Store)(nil)
+	_ registration.Store             = (*Store)(nil)
+	_ throttle.Store                 = (*Store)(nil)
+)
+
+// New returns an empty store
+func New() *Store {
+	return &Store{
+		loginCodes:  map[int]loginCodeRow{},
+		userTokens:  map[int]string{},
+		adminTokens: map[int]adminTokenRow{},
+		throttles:   map[string]throttleRow{},
+		Now:         time.Now,
+	}
+}
+
+// AddUser adds a fully registered senior with the password, returning their user ID. It is for
+// arranging the accounts a test logs in with, and panics if the email is already in use.
+func (s *Store) AddUser(email, password string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.user(email) != nil {
+		panic(fmt.Sprintf("memstore: user %s already exists", email))
+	}
+	userID := len(s.users) + 1
+	s.users = append(s.users, userRow{
+		userID:             userID,
+		email:              email,
+		passwordHash:       hash(password),
+		registrationStatus: registration.StatusRegistered,
+		createdAt:          s.now(),
+	})
+	return userID
+}
+
+// AddAdmin adds an active admin with the password. It is for arranging the accounts a test
+// logs in with, and panics if the ID or email is already in use.
+func (s *Store) AddAdmin(adminID int, email, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.admin(adminID) != nil || s.adminByEmail(email) != nil {
+		panic(fmt.Sprintf("memstore: admin %d or %s already exists", adminID, email))
+	}
+	s.admins = append(s.admins, adminRow{adminID: adminID, email: email, passwordHash: hash(password), active: true})
+}
+
+// UserToken returns the token last issued to a senior, and false if none was
+func (s *Store) UserToken(userID int) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token, ok := s.userTokens[userID]
+	return token, ok
+}
+
+// AdminToken returns the token last issued to an admin, and false if none was or it was revoked
+func (s *Store) AdminToken(adminID int) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	found, ok := s.adminTokens[adminID]
+	return found.token, ok
+}
+
+// hash hashes a password as the handlers do, at the lowest cost to keep tests fast
+func hash(password string) string {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		panic(err)
+	}
+	return string(hashed)
+}
+
+// now returns the store's clock truncated to the second, as the database stores times
+func (s *Store) now() time.Time {
+	return s.Now().UTC().Truncate(time.Second)
+}
+
+// user returns the senior with the email, or nil. The caller holds the lock.
+func (s *Store) user(email string) *userRow {
+	for i := range s.users {
+		if s.users[i].email == email {
+			return &s.users[i]
+		}
+	}
+	return nil
+}
+
+// userByID returns the senior with the ID, or nil. The caller holds the lock.
+func (s *Store) userByID(userID int) *userRow {
+	for i := range s.users {
+		if s.users[i].userID == userID {
+			return &s.users[i]
+		}
+	}
+	return nil
+}
+
+// admin returns the admin with the ID, or nil. The caller holds the lock.
+func (s *Store) admin(adminID int) *adminRow {
+	for i := range s.admins {
+		if s.admins[i].adminID == adminID {
+			return &s.admins[i]
+		}
+	}
+	return nil
+}
+
+// adminByEmail returns the admin with the email, or nil. The caller holds the lock.
+func (s *Store) adminByEmail(email string) *adminRow {
+	for i := range s.admins {
+		if s.admins[i].email == email {
+			return &s.admins[i]
+		}
+	}
+	return nil
+}
+
+// outboxEntry returns the outbox entry with the ID, or nil. The caller holds the lock.
+func (s *Store) outboxEntry(outboxID int) *outboxRow {
+	for i := range s.outbox {
+		if s.outbox[i].outboxID == outboxID {
+			return &s.outbox[i]
+		}
+	}
+	return nil
+}
+
+func (s *Store) User(ctx context.Context, email string) (authentication.UserCredentials, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found := s.user(email)
+	if found == nil {
+		return authentication.UserCredentials{}, authentication.ErrNotFound
+	}
+	return authentication.UserCredentials{
+		UserID:             found.userID,
+		PasswordHash:       found.passwordHash,
+		RegistrationStatus: found.registrationStatus,
+	}, nil
+}
+
+func (s *Store) SaveUserToken(ctx context.Context, userID int, token string, expiry time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.userTokens[userID] = token
+	return nil
+}
+
+func (s *Store) LoginCodeAge(ctx context.Context, userID int) (time.Duration, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	code, ok := s.loginCodes[userID]
+	if !ok {
+		return 0, false, nil
+	}
+	return s.now().Sub(code.createdAt), true, nil
+}
+
+func (s *Store) SaveLoginCode(ctx context.Context, userID int, codeHash, linkTokenHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if linkTokenHash != "" {
+		for otherID, code := range s.loginCodes {
+			if otherID != userID && code.linkTokenHash == linkTokenHash {
+				return fmt.Errorf("duplicate link token")
+			}
+		}
+	}
+	s.loginCodes[userID] = loginCodeRow{codeHash: codeHash, linkTokenHash: linkTokenHash, createdAt: s.now()}
+	return nil
+}
+
+func (s *Store) LoginCode(ctx context.Context, email string) (authentication.LoginCode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found := s.user(email)
+	if found == nil {
+		return authentication.LoginCode{}, authentication.ErrNotFound
+	}
+	code, ok := s.loginCodes[found.userID]
+	if !ok || code.codeHash == "" {
+		return authentication.LoginCode{}, authentication.ErrNotFound
+	}
+	return authentication.LoginCode{
+		UserID:   found.userID,
+		CodeHash: code.codeHash,
+		Attempts: code.attempts,
+		Age:      s.now().Sub(code.createdAt),
+	}, nil
+}
+
+func (s *Store) RecordLoginCodeAttempt(ctx context.Context, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if code, ok := s.loginCodes[userID]; ok {
+		code.attempts++
+		s.loginCodes[userID] = code
+	}
+	return nil
+}
+
+func (s *Store) MagicLink(ctx context.Context, linkTokenHash string) (int, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for userID, code := range s.loginCodes {
+		if code.linkTokenHash != "" && code.linkTokenHash == linkTokenHash {
+			return userID, s.now().Sub(code.createdAt), nil
+		}
+	}
+	return 0, 0, authentication.ErrNotFound
+}
+
+func (s *Store) ConsumeLoginCode(ctx context.Context, userID int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.loginCodes[userID]
+	delete(s.loginCodes, userID)
+	return ok, nil
+}
+
+func (s *Store) Admin(ctx context.Context, email string) (authentication.AdminCredentials, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found := s.adminByEmail(email)
+	if found == nil {
+		return authentication.AdminCredentials{}, authentication.ErrNotFound
+	}
+	return authentication.AdminCredentials{
+		AdminID:      found.adminID,
+		PasswordHash: found.passwordHash,
+		Active:       found.active,
+		TOTPEnabled:  found.totpEnabled,
+	}, nil
+}
+
+func (s *Store) AdminEmail(ctx context.Context, adminID int) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found := s.admin(adminID)
+	if found == nil {
+		return "", authentication.ErrNotFound
+	}
+	return found.email, nil
+}
+
+func (s *Store) SaveAdminToken(ctx context.Context, adminID int, token string, expiry time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.adminTokens[adminID] = adminTokenRow{token: token, expiry: expiry}
+	return nil
+}
+
+func (s *Store) RevokeAdminTokens(ctx context.Context, adminID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.adminTokens, adminID)
+	return nil
+}
+
+func (s *Store) AdminTokenActive(ctx context.Context, token string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for adminID, found := range s.adminTokens {
+		if found.token == token && found.expiry.After(s.now()) {
+			owner := s.admin(adminID)
+			return owner != nil && owner.active, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *Store) CreateAdminInvite(ctx context.Context, adminID int, email, tokenHash string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.admin(adminID) != nil || s.adminByEmail(email) != nil {
+		return fmt.Errorf("duplicate admin %d or email %s", adminID, email)
+	}
+	s.admins = append(s.admins, adminRow{
+		adminID:      adminID,
+		email:        email,
+		active:       true,
+		inviteToken:  tokenHash,
+		inviteExpiry: s.now().Add(ttl),
+	})
+	return nil
+}
+
+func (s *Store) ResetAdmin(ctx context.Context, adminID int, tokenHash string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if found := s.admin(adminID); found != nil {
+		found.passwordHash = ""
+		found.active = true
+		found.inviteToken = tokenHash
+		found.inviteExpiry = s.now().Add(ttl)
+	}
+	return nil
+}
+
+func (s *Store) DeactivateAdmin(ctx context.Context, adminID int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found := s.admin(adminID)
+	if found == nil {
+		return false, nil
+	}
+	found.active = false
+	found.inviteToken = ""
+	found.inviteExpiry = time.Time{}
+	return true, nil
+}
+
+func (s *Store) AdminInvite(ctx context.Context, email string) (authentication.AdminInvite, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found := s.adminByEmail(email)
+	if found == nil {
+		return authentication.AdminInvite{}, authentication.ErrNotFound
+	}
+	invite := authentication.AdminInvite{AdminID: found.adminID, TokenHash: found.inviteToken, Active: found.active}
+	if !found.inviteExpiry.IsZero() {
+		invite.ExpiresIn = found.inviteExpiry.Sub(s.now())
+	}
+	return invite, nil
+}
+
+func (s *Store) SetAdminPassword(ctx context.Context, adminID int, passwordHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if found := s.admin(adminID); found != nil {
+		found.passwordHash = passwordHash
+		found.inviteToken = ""
+		found.inviteExpiry = time.Time{}
+	}
+	return nil
+}
+
+func (s *Store) TOTPEnabled(ctx context.Context, adminID int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found := s.admin(adminID)
+	if found == nil {
+		return false, authentication.ErrNotFound
+	}
+	return found.totpEnabled, nil
+}
+
+func (s *Store) SetTOTPSecret(ctx context.Context, adminID int, secret string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if found := s.admin(adminID); found != nil {
+		found.totpSecret = secret
+		found.totpLastStep = nil
+	}
+	return nil
+}
+
+func (s *Store) EnableTOTP(ctx context.Context, adminID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if found := s.admin(adminID); found != nil {
+		found.totpEnabled = true
+	}
+	return nil
+}
+
+func (s *Store) ResetTOTP(ctx context.Context, adminID int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found := s.admin(adminID)
+	if found == nil {
+		return false, nil
+	}
+	found.totpSecret = ""
+	found.totpEnabled = false
+	found.totpLastStep = nil
+	return true, nil
+}
+
+func (s *Store) UseTOTPStep(ctx context.Context, adminID int, requireEnabled bool, validate func(secret string) (int64, bool)) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found := s.admin(adminID)
+	if found == nil || found.totpSecret == "" || (requireEnabled && !found.totpEnabled) {
+		return false, nil
+	}
+	step, ok := validate(found.totpSecret)
+	if !ok || (found.totpLastStep != nil && step <= *found.totpLastStep) {
+		return false, nil
+	}
+	found.totpLastStep = &step
+	return true, nil
+}
+
+func (s *Store) UnusedRecoveryCodes(ctx context.Context, adminID int) ([]authentication.RecoveryCode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var codes []authentication.RecoveryCode
+	for _, code := range s.recoveryCodes {
+		if code.adminID == adminID && !code.used {
+			codes = append(codes, code.RecoveryCode)
+		}
+	}
+	return codes, nil
+}
+
+func (s *Store) UseRecoveryCode(ctx context.Context, codeID int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.recoveryCodes {
+		if s.recoveryCodes[i].CodeID == codeID && !s.recoveryCodes[i].used {
+			s.recoveryCodes[i].used = true
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *Store) ReplaceRecoveryCodes(ctx context.Context, adminID int, codeHashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(codeHashes) > 0 && s.admin(adminID) == nil {
+		return fmt.Errorf("admin %d does not exist", adminID)
+	}
+	nextID := 1
+	for _, code := range s.recoveryCodes {
+		nextID = max(nextID, code.CodeID+1)
+	}
+	s.recoveryCodes = slices.DeleteFunc(s.recoveryCodes, func(code recoveryCodeRow) bool { return code.adminID == adminID })
+	for _, codeHash := range codeHashes {
+		s.recoveryCodes = append(s.recoveryCodes, recoveryCodeRow{
+			RecoveryCode: authentication.RecoveryCode{CodeID: nextID, CodeHash: codeHash},
+			adminID:      adminID,
+		})
+		nextID++
+	}
+	return nil
+}
+
+func (s *Store) CreateCaregiver(ctx context.Context, email, name, passwordHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, caregiver := range s.caregivers {
+		if caregiver.Email == email {
+			return fmt.Errorf("duplicate caregiver email %s", email)
+		}
+	}
+	s.caregivers = append(s.caregivers, authentication.CaregiverCredentials{
+		CaregiverID:  len(s.caregivers) + 1,
+		Name:         name,
+		Email:        email,
+		PasswordHash: passwordHash,
+	})
+	return nil
+}
+
+func (s *Store) Caregiver(ctx context.Context, email string) (authentication.CaregiverCredentials, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, caregiver := range s.caregivers {
+		if caregiver.Email == email {
+			return caregiver, nil
+		}
+	}
+	return authentication.CaregiverCredentials{}, authentication.ErrNotFound
+}
+
+func (s *Store) VerificationCodeAge(ctx context.Context, email string) (time.Duration, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found := s.user(email)
+	if found == nil || found.verificationCode == "" {
+		return 0, false, nil
+	}
+	return s.now().Sub(found.createdAt), true, nil
+}
+
+func (s *Store) SaveVerificationCode(ctx context.Context, email, codeHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if found := s.user(email); found != nil {
+		found.verificationCode = codeHash
+		found.verificationAttempts = 0
+		found.createdAt = s.now()
+		return nil
+	}
+	s.users = append(s.users, userRow{
+		userID:             len(s.users) + 1,
+		email:              email,
+		verificationCode:   codeHash,
+		registrationStatus: registration.StatusVerifying,
+		createdAt:          s.now(),
+	})
+	return nil
+}
+
+func (s *Store) VerificationCode(ctx context.Context, email string) (registration.VerificationCode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found := s.user(email)
+	if found == nil || found.verificationCode == "" {
+		return registration.VerificationCode{}, registration.ErrNotFound
+	}
+	return registration.VerificationCode{
+		CodeHash: found.verificationCode,
+		Attempts: found.verificationAttempts,
+		Age:      s.now().Sub(found.createdAt),
+	}, nil
+}
+
+func (s *Store) RecordVerificationAttempt(ctx context.Context, email string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if found := s.user(email); found != nil {
+		found.verificationAttempts++
+	}
+	return nil
+}
+
+func (s *Store) SaveRegistration(ctx context.Context, email, passwordHash string, profile registration.ProfilePayload) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found := s.user(email)
+	if found == nil {
+		return 0, fmt.Errorf("failed to lock user row: %v", registration.ErrNotFound)
+	}
+	found.passwordHash = passwordHash
+	found.verificationCode = ""
+	found.verificationAttempts = 0
+	found.registrationStatus = registration.StatusProfilePending
+
+	profile.UserID = found.userID
+	payload, err := json.Marshal(profile)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal profile: %v", err)
+	}
+
+	// One entry per user, requeued when the senior registers again
+	entry := outboxRow{userID: found.userID, payload: string(payload), status: outboxPending, nextAttemptAt: s.now()}
+	for i := range s.outbox {
+		if s.outbox[i].userID == found.userID {
+			entry.outboxID = s.outbox[i].outboxID
+			s.outbox[i] = entry
+			return found.userID, nil
+		}
+	}
+	entry.outboxID = len(s.outbox) + 1
+	s.outbox = append(s.outbox, entry)
+	return found.userID, nil
+}
+
+func (s *Store) RegistrationStatus(ctx context.Context, userID int) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found := s.userByID(userID)
+	if found == nil {
+		return "", registration.ErrNotFound
+	}
+	return found.registrationStatus, nil
+}
+
+func (s *Store) PendingOutboxEntry(ctx context.Context, userID int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, entry := range s.outbox {
+		if entry.userID == userID && entry.status == outboxPending {
+			return entry.outboxID, nil
+		}
+	}
+	return 0, registration.ErrNotFound
+}
+
+func (s *Store) DueOutboxEntries(ctx context.Context, limit int) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	var due []outboxRow
+	for _, entry := range s.outbox {
+		if entry.status == outboxPending && !entry.nextAttemptAt.After(now) {
+			due = append(due, entry)
+		}
+	}
+	slices.SortStableFunc(due, func(a, b outboxRow) int { return a.nextAttemptAt.Compare(b.nextAttemptAt) })
+
+	var outboxIDs []int
+	for _, entry := range due[:min(limit, len(due))] {
+		outboxIDs = append(outboxIDs, entry.outboxID)
+	}
+	return outboxIDs, nil
+}
+
+func (s *Store) ClaimOutboxEntry(ctx context.Context, outboxID int, lease time.Duration, force bool) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	entry := s.outboxEntry(outboxID)
+	if entry == nil || entry.status != outboxPending || (!force && entry.nextAttemptAt.After(now)) {
+		return false, nil
+	}
+	entry.nextAttemptAt = now.Add(lease.Truncate(time.Second))
+	return true, nil
+}
+
+func (s *Store) OutboxEntry(ctx context.Context, outboxID int) (registration.OutboxEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.outboxEntry(outboxID)
+	if entry == nil {
+		return registration.OutboxEntry{}, registration.ErrNotFound
+	}
+	return registration.OutboxEntry{Payload: entry.payload, Attempts: entry.attempts}, nil
+}
+
+func (s *Store) RetryOutboxEntry(ctx context.Context, outboxID, attempts int, backoff time.Duration, lastError string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry := s.outboxEntry(outboxID); entry != nil {
+		entry.attempts = attempts
+		entry.nextAttemptAt = s.now().Add(backoff.Truncate(time.Second))
+		entry.lastError = lastError
+	}
+	return nil
+}
+
+func (s *Store) CompleteOutboxEntry(ctx context.Context, outboxID, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry := s.outboxEntry(outboxID); entry != nil {
+		entry.status = outboxCompleted
+		entry.lastError = ""
+	}
+	if found := s.userByID(userID); found != nil {
+		found.registrationStatus = registration.StatusRegistered
+	}
+	return nil
+}
+
+func (s *Store) CompensateOutboxEntry(ctx context.Context, outboxID, userID int, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry := s.outboxEntry(outboxID); entry != nil {
+		entry.status = outboxCompensated
+		entry.lastError = reason
+	}
+	if found := s.userByID(userID); found != nil && found.registrationStatus == registration.StatusProfilePending {
+		found.passwordHash = ""
+		found.verificationCode = ""
+		found.verificationAttempts = 0
+		found.registrationStatus = registration.StatusVerifying
+	}
+	return nil
+}
+
+func (s *Store) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	row, ok := s.throttles[key]
+	if !ok || row.lockedUntil.IsZero() {
+		return 0, nil
+	}
+	return max(row.lockedUntil.Sub(s.now()), 0), nil
+}
+
+func (s *Store) RecordFailure(ctx context.Context, key string, window time.Duration, lockout func(failures int) time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	row := s.throttles[key]
+
+	// Forget failures that fall outside the window
+	if !row.lastFailedAt.IsZero() && now.Sub(row.lastFailedAt) > window {
+		row.failures = 0
+	}
+	row.failures++
+
+	row.lockedUntil = time.Time{}
+	if locked := lockout(row.failures); locked > 0 {
+		row.lockedUntil = now.Add(locked.Truncate(time.Second))
+	}
+	row.lastFailedAt = now
+	s.throttles[key] = row
+	return nil
+}
+
+func (s *Store) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.throttles, key)
+	return nil
+}
+
+// Directory is the user and admin microservices for the handlers under test. It is an
+// authentication.UserClient, an authentication.AdminClient and a registration.ProfileCreator,
+// serving the profiles and caregiver invitations added to it and keeping the profiles created
+// and admins activated. Unknown profiles are answered with a 404 like the microservices.
+type Directory struct {
+	mu        sync.Mutex
+	users     []user.User
+	admins    []admin.Admin
+	invites   map[string]string
+	profiles  []user.Profile
+	activated []int
+
+	// ProfileErr, if set, fails CreateProfile as the user microservice being unavailable would
+	ProfileErr error
+}
+
+var (
+	_ authentication.UserClient   = (*Directory)(nil)
+	_ authentication.AdminClient  = (*Directory)(nil)
+	_ registration.ProfileCreator = (*Directory)(nil)
+)
+
+// AddUser adds a senior's profile
+func (d *Directory) AddUser(profile user.User) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.users = append(d.users, profile)
+}
+
+// AddAdmin adds an admin's profile
+func (d *Directory) AddAdmin(profile admin.Admin) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.admins = append(d.admins, profile)
+}
+
+// AddCaregiverInvite records a senior's invitation of the caregiver with the email
+func (d *Directory) AddCaregiverInvite(email, token string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.invites == nil {
+		d.invites = map[string]string{}
+	}
+	d.invites[email] = token
+}
+
+func (d *Directory) User(ctx context.Context, userID int) (user.User, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, profile := range d.users {
+		if profile.UserID == userID {
+			return profile, nil
+		}
+	}
+	return user.User{}, &client.Error{Service: config.UserService, StatusCode: http.StatusNotFound, Body: "User not found"}
+}
+
+func (d *Directory) VerifyCaregiverInvite(ctx context.Context, email, token string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	invited, ok := d.invites[email]
+	return ok && invited == token, nil
+}
+
+func (d *Directory) CreateProfile(ctx context.Context, profile user.Profile) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.ProfileErr != nil {
+		return d.ProfileErr
+	}
+	d.profiles = append(d.profiles, profile)
+	d.users = append(d.users, user.User{
+		UserID:      profile.UserID,
+		Name:        profile.Name,
+		Email:       profile.Email,
+		PhoneNumber: profile.PhoneNumber,
+		Address:     profile.Address,
+		Age:         profile.Age,
+	})
+	return nil
+}
+
+func (d *Directory) Admin(ctx context.Context, adminID int) (admin.Admin, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, profile := range d.admins {
+		if profile.UserID == adminID {
+			return profile, nil
+		}
+	}
+	return admin.Admin{}, &client.Error{Service: config.AdminService, StatusCode: http.StatusNotFound, Body: "Admin not found"}
+}
+
+func (d *Directory) ActivateAdmin(ctx context.Context, adminID int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.activated = append(d.activated, adminID)
+	return nil
+}
+
+// Profiles returns the profiles created so far
+func (d *Directory) Profiles() []user.Profile {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return slices.Clone(d.profiles)
+}
+
+// Activated returns the IDs of the admins activated so far
+func (d *Directory) Activated() []int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return slices.Clone(d.activated)
+}
+
+// Email is an email sent with a MailRecorder. Code and Token are empty when the email has none.
+type Email struct {
+	To    string
+	Code  string
+	Token string
+}
+
+// MailRecorder is an authentication.Mailer and a registration.Mailer that keeps the emails sent
+// with it instead of sending them, failing them with Err if it is set
+type MailRecorder struct {
+	mu     sync.Mutex
+	emails []Email
+
+	Err error
+}
+
+var (
+	_ authentication.Mailer = (*MailRecorder)(nil)
+	_ registration.Mailer   = (*MailRecorder)(nil)
+)
+
+func (m *MailRecorder) SendVerificationCode(to, code string) error {
+	return m.record(Email{To: to, Code: code})
+}
+
+func (m *MailRecorder) SendAdminInvite(to, token string) error {
+	return m.record(Email{To: to, Token: token})
+}
+
+func (m *MailRecorder) SendLoginCode(to, code, linkToken string) error {
+	return m.record(Email{To: to, Code: code, Token: linkToken})
+}
+
+// record keeps an email and returns the error sending it fails with
+func (m *MailRecorder) record(email Email) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Err != nil {
+		return m.Err
+	}
+	m.emails = append(m.emails, email)
+	return nil
+}
+
+// Emails returns the emails sent so far
+func (m *MailRecorder) Emails() []Email {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.emails)
+}
@@ -0,0 +1,394 @@
+package authentication
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"authenticationMicroservice/totp"
+
+	"shared/observability"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// OpenDB connects to the database named by a DSN and checks the connection
+func OpenDB(dsn string) (*sql.DB, error) {
+	if dsn == "" {
+		return nil, fmt.Errorf("AUTH_DB_CONNECTION environment variable is not set")
+	}
+
+	slog.Debug("Initializing database connection")
+	db, err := observability.OpenDB("mysql", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to database: %v", err)
+	}
+
+	// Test the database connection
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("database connection test failed: %v", err)
+	}
+	slog.Info("Database connection successful")
+	return db, nil
+}
+
+// MySQLStore is the CredentialStore kept in the authentication database
+type MySQLStore struct {
+	db *sql.DB
+}
+
+// NewMySQLStore returns a store reading and writing the given database
+func NewMySQLStore(db *sql.DB) *MySQLStore {
+	return &MySQLStore{db: db}
+}
+
+func (s *MySQLStore) User(ctx context.Context, email string) (UserCredentials, error) {
+	var user UserCredentials
+	var hashedPassword sql.NullString
+	err := s.db.QueryRowContext(ctx, "SELECT user_id, password, registration_status FROM User WHERE email = ?", email).Scan(&user.UserID, &hashedPassword, &user.RegistrationStatus)
+	if err == sql.ErrNoRows {
+		return UserCredentials{}, ErrNotFound
+	} else if err != nil {
+		return UserCredentials{}, err
+	}
+	user.PasswordHash = hashedPassword.String
+	return user, nil
+}
+
+func (s *MySQLStore) SaveUserToken(ctx context.Context, userID int, token string, expiry time.Time) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO UserAuthentication (user_id, auth_token, token_expiry)
+		VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+		auth_token = VALUES(auth_token), token_expiry = VALUES(token_expiry)`,
+		userID, token, expiry)
+	return err
+}
+
+func (s *MySQLStore) LoginCodeAge(ctx context.Context, userID int) (time.Duration, bool, error) {
+	var secondsSinceLastCode sql.NullInt64
+	err := s.db.QueryRowContext(ctx, `
+		SELECT TIMESTAMPDIFF(SECOND, created_at, NOW())
+		FROM LoginCode
+		WHERE user_id = ?`, userID).Scan(&secondsSinceLastCode)
+	if err == sql.ErrNoRows || (err == nil && !secondsSinceLastCode.Valid) {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, err
+	}
+	return time.Duration(secondsSinceLastCode.Int64) * time.Second, true, nil
+}
+
+func (s *MySQLStore) SaveLoginCode(ctx context.Context, userID int, codeHash, linkTokenHash string) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO LoginCode (user_id, code_hash, link_token_hash, attempts, created_at)
+		VALUES (?, NULLIF(?, ''), NULLIF(?, ''), 0, NOW())
+		ON DUPLICATE KEY UPDATE
+		code_hash = VALUES(code_hash), link_token_hash = VALUES(link_token_hash), attempts = 0, created_at = VALUES(created_at)`,
+		userID, codeHash, linkTokenHash)
+	return err
+}
+
+func (s *MySQLStore) LoginCode(ctx context.Context, email string) (LoginCode, error) {
+	var code LoginCode
+	var codeHash sql.NullString
+	var codeAgeSeconds int64
+	err := s.db.QueryRowContext(ctx, `
+		SELECT lc.user_id, lc.code_hash, lc.attempts, TIMESTAMPDIFF(SECOND, lc.created_at, NOW())
+		FROM LoginCode lc
+		JOIN User u ON u.user_id = lc.user_id
+		WHERE u.email = ?`, email).Scan(&code.UserID, &codeHash, &code.Attempts, &codeAgeSeconds)
+	if err == sql.ErrNoRows || (err == nil && !codeHash.Valid) {
+		return LoginCode{}, ErrNotFound
+	} else if err != nil {
+		return LoginCode{}, err
+	}
+	code.CodeHash = codeHash.String
+	code.Age = time.Duration(codeAgeSeconds) * time.Second
+	return code, nil
+}
+
+func (s *MySQLStore) RecordLoginCodeAttempt(ctx context.Context, userID int) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE LoginCode SET attempts = attempts + 1 WHERE user_id = ?`, userID)
+	return err
+}
+
+func (s *MySQLStore) MagicLink(ctx context.Context, linkTokenHash string) (int, time.Duration, error) {
+	var userID int
+	var linkAgeSeconds int64
+	err := s.db.QueryRowContext(ctx, `
+		SELECT user_id, TIMESTAMPDIFF(SECOND, created_at, NOW())
+		FROM LoginCode
+		WHERE link_token_hash = ?`, linkTokenHash).Scan(&userID, &linkAgeSeconds)
+	if err == sql.ErrNoRows {
+		return 0, 0, ErrNotFound
+	} else if err != nil {
+		return 0, 0, err
+	}
+	return userID, time.Duration(linkAgeSeconds) * time.Second, nil
+}
+
+func (s *MySQLStore) ConsumeLoginCode(ctx context.Context, userID int) (bool, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM LoginCode WHERE user_id = ?`, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+func (s *MySQLStore) Admin(ctx context.Context, email string) (AdminCredentials, error) {
+	var admin AdminCredentials
+	var hashedPassword sql.NullString
+	err := s.db.QueryRowContext(ctx, "SELECT admin_id, password, active, totp_enabled FROM Admin WHERE email = ?", email).Scan(&admin.AdminID, &hashedPassword, &admin.Active, &admin.TOTPEnabled)
+	if err == sql.ErrNoRows {
+		return AdminCredentials{}, ErrNotFound
+	} else if err != nil {
+		return AdminCredentials{}, err
+	}
+	admin.PasswordHash = hashedPassword.String
+	return admin, nil
+}
+
+func (s *MySQLStore) AdminEmail(ctx context.Context, adminID int) (string, error) {
+	var email string
+	err := s.db.QueryRowContext(ctx, `SELECT email FROM Admin WHERE admin_id = ?`, adminID).Scan(&email)
+	if err == sql.ErrNoRows {
+		return "", ErrNotFound
+	}
+	return email, err
+}
+
+func (s *MySQLStore) SaveAdminToken(ctx context.Context, adminID int, token string, expiry time.Time) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO AdminAuthentication (admin_id, auth_token, token_expiry)
+		VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+		auth_token = VALUES(auth_token), token_expiry = VALUES(token_expiry)`,
+		adminID, token, expiry)
+	return err
+}
+
+func (s *MySQLStore) RevokeAdminTokens(ctx context.Context, adminID int) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM AdminAuthentication WHERE admin_id = ?`, adminID)
+	return err
+}
+
+func (s *MySQLStore) AdminTokenActive(ctx context.Context, token string) (bool, error) {
+	var active bool
+	err := s.db.QueryRowContext(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM AdminAuthentication t
+			JOIN Admin a ON a.admin_id = t.admin_id
+			WHERE t.auth_token = ? AND t.token_expiry > NOW() AND a.active
+		)`, token).Scan(&active)
+	return active, err
+}
+
+func (s *MySQLStore) CreateAdminInvite(ctx context.Context, adminID int, email, tokenHash string, ttl time.Duration) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO Admin (admin_id, email, password, active, invite_token, invite_expiry)
+		VALUES (?, ?, NULL, TRUE, ?, NOW() + INTERVAL ? SECOND)`,
+		adminID, email, tokenHash, int(ttl.Seconds()))
+	return err
+}
+
+func (s *MySQLStore) ResetAdmin(ctx context.Context, adminID int, tokenHash string, ttl time.Duration) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE Admin
+		SET password = NULL, active = TRUE, invite_token = ?, invite_expiry = NOW() + INTERVAL ? SECOND
+		WHERE admin_id = ?`,
+		tokenHash, int(ttl.Seconds()), adminID)
+	return err
+}
+
+func (s *MySQLStore) DeactivateAdmin(ctx context.Context, adminID int) (bool, error) {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE Admin
+		SET active = FALSE, invite_token = NULL, invite_expiry = NULL
+		WHERE admin_id = ?`, adminID)
+	if err != nil {
+		return false, err
+	}
+	return s.adminUpdated(ctx, result, adminID)
+}
+
+// adminUpdated reports whether an update matched the admin. RowsAffected leaves out rows the
+// update did not change, so the admin's existence is checked when it is zero.
+func (s *MySQLStore) adminUpdated(ctx context.Context, result sql.Result, adminID int) (bool, error) {
+	if affected, _ := result.RowsAffected(); affected > 0 {
+		return true, nil
+	}
+	var exists bool
+	err := s.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM Admin WHERE admin_id = ?)`, adminID).Scan(&exists)
+	return exists, err
+}
+
+func (s *MySQLStore) AdminInvite(ctx context.Context, email string) (AdminInvite, error) {
+	var invite AdminInvite
+	var storedHash sql.NullString
+	var secondsToExpiry sql.NullInt64
+	err := s.db.QueryRowContext(ctx, `
+		SELECT admin_id, invite_token, TIMESTAMPDIFF(SECOND, NOW(), invite_expiry), active
+		FROM Admin
+		WHERE email = ?`, email).Scan(&invite.AdminID, &storedHash, &secondsToExpiry, &invite.Active)
+	if err == sql.ErrNoRows {
+		return AdminInvite{}, ErrNotFound
+	} else if err != nil {
+		return AdminInvite{}, err
+	}
+	invite.TokenHash = storedHash.String
+	invite.ExpiresIn = time.Duration(secondsToExpiry.Int64) * time.Second
+	return invite, nil
+}
+
+func (s *MySQLStore) SetAdminPassword(ctx context.Context, adminID int, passwordHash string) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE Admin
+		SET password = ?, invite_token = NULL, invite_expiry = NULL
+		WHERE admin_id = ?`, passwordHash, adminID)
+	return err
+}
+
+func (s *MySQLStore) TOTPEnabled(ctx context.Context, adminID int) (bool, error) {
+	var enabled bool
+	err := s.db.QueryRowContext(ctx, `SELECT totp_enabled FROM Admin WHERE admin_id = ?`, adminID).Scan(&enabled)
+	if err == sql.ErrNoRows {
+		return false, ErrNotFound
+	}
+	return enabled, err
+}
+
+func (s *MySQLStore) SetTOTPSecret(ctx context.Context, adminID int, secret string) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE Admin SET totp_secret = ?, totp_last_step = NULL WHERE admin_id = ?`, secret, adminID)
+	return err
+}
+
+func (s *MySQLStore) EnableTOTP(ctx context.Context, adminID int) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE Admin SET totp_enabled = TRUE WHERE admin_id = ?`, adminID)
+	return err
+}
+
+func (s *MySQLStore) ResetTOTP(ctx context.Context, adminID int) (bool, error) {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE Admin
+		SET totp_secret = NULL, totp_enabled = FALSE, totp_last_step = NULL
+		WHERE admin_id = ?`, adminID)
+	if err != nil {
+		return false, err
+	}
+	return s.adminUpdated(ctx, result, adminID)
+}
+
+func (s *MySQLStore) UseTOTPStep(ctx context.Context, adminID int, requireEnabled bool, validate func(secret string) (int64, bool)) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var secret sql.NullString
+	var enabled bool
+	var lastStep sql.NullInt64
+	err = tx.QueryRowContext(ctx, `
+		SELECT totp_secret, totp_enabled, totp_last_step
+		FROM Admin
+		WHERE admin_id = ?
+		FOR UPDATE`, adminID).Scan(&secret, &enabled, &lastStep)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if !secret.Valid || (requireEnabled && !enabled) {
+		return false, nil
+	}
+
+	var last *int64
+	if lastStep.Valid {
+		last = &lastStep.Int64
+	}
+	step, ok := validate(secret.String)
+	if !ok || !totp.Fresh(step, last) {
+		return false, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE Admin SET totp_last_step = ? WHERE admin_id = ?`, step, adminID); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func (s *MySQLStore) UnusedRecoveryCodes(ctx context.Context, adminID int) ([]RecoveryCode, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT code_id, code_hash
+		FROM AdminRecoveryCode
+		WHERE admin_id = ? AND used_at IS NULL`, adminID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var codes []RecoveryCode
+	for rows.Next() {
+		var code RecoveryCode
+		if err := rows.Scan(&code.CodeID, &code.CodeHash); err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, rows.Err()
+}
+
+func (s *MySQLStore) UseRecoveryCode(ctx context.Context, codeID int) (bool, error) {
+	// Fails if a concurrent login used the code first
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE AdminRecoveryCode
+		SET used_at = NOW()
+		WHERE code_id = ? AND used_at IS NULL`, codeID)
+	if err != nil {
+		return false, err
+	}
+	affected, _ := result.RowsAffected()
+	return affected == 1, nil
+}
+
+func (s *MySQLStore) ReplaceRecoveryCodes(ctx context.Context, adminID int, codeHashes []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM AdminRecoveryCode WHERE admin_id = ?`, adminID); err != nil {
+		return err
+	}
+	for _, codeHash := range codeHashes {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO AdminRecoveryCode (admin_id, code_hash) VALUES (?, ?)`, adminID, codeHash); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *MySQLStore) CreateCaregiver(ctx context.Context, email, name, passwordHash string) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO Caregiver (email, name, password)
+		VALUES (?, ?, ?)`, email, name, passwordHash)
+	return err
+}
+
+func (s *MySQLStore) Caregiver(ctx context.Context, email string) (CaregiverCredentials, error) {
+	var caregiver CaregiverCredentials
+	err := s.db.QueryRowContext(ctx, `
+		SELECT caregiver_id, name, email, password
+		FROM Caregiver
+		WHERE email = ?`, email).Scan(&caregiver.CaregiverID, &caregiver.Name, &caregiver.Email, &caregiver.PasswordHash)
+	if err == sql.ErrNoRows {
+		return CaregiverCredentials{}, ErrNotFound
+	}
+	return caregiver, err
+}
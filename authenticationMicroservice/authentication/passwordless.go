@@ -0,0 +1,400 @@
+package authentication
+
+import (
+	"authenticationMicroservice/config"
+	"authenticationMicroservice/registration"
+	"authenticationMicroservice/throttle"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"time"
+
+	"shared/apierror"
+	sharedconfig "shared/config"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Login methods a deployment can enable through LOGIN_METHODS
+const (
+	LoginMethodPassword  = "password"   // Email and password
+	LoginMethodCode      = "code"       // Emailed one-time code
+	LoginMethodMagicLink = "magic_link" // Emailed single-use link
+)
+
+const (
+	loginCodeTTL         = 10 * time.Minute // How long an emailed code or link stays valid
+	loginCodeResendDelay = 1 * time.Minute  // Minimum gap between two emails for the same address
+	maxLoginCodeAttempts = 5                // Wrong guesses allowed before a code is discarded
+)
+
+// magicLinkPage is the frontend page that exchanges a magic link token for a JWT
+const magicLinkPage = "/login.html"
+
+// LoginMethodEnabled reports whether the deployment allows the given login method, in the
+// LOGIN_METHODS setting, which keeps existing deployments on password login only by default
+func LoginMethodEnabled(method string) bool {
+	for _, enabled := range config.Current().LoginMethods {
+		if enabled == method {
+			return true
+		}
+	}
+	return false
+}
+
+// GetLoginMethods tells the frontend which login options to show
+func GetLoginMethods(w http.ResponseWriter, r *http.Request) {
+	methods := []string{}
+	for _, method := range []string{LoginMethodPassword, LoginMethodCode, LoginMethodMagicLink} {
+		if LoginMethodEnabled(method) {
+			methods = append(methods, method)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]string{"methods": methods})
+}
+
+// LoginCodeRequest is the body for requesting or redeeming a passwordless login
+type LoginCodeRequest struct {
+	Email string `json:"email"`
+	Code  string `json:"code"`
+	Token string `json:"token"`
+}
+
+// RequestLoginCode emails a registered senior a one-time code and/or magic link, depending on
+// which passwordless methods are enabled. Unknown emails, accounts still being set up and repeated requests
+// within the cooldown get the same response, so accounts cannot be probed.
+func (h *Handler) RequestLoginCode(w http.ResponseWriter, r *http.Request) {
+	sendCode := LoginMethodEnabled(LoginMethodCode)
+	sendLink := LoginMethodEnabled(LoginMethodMagicLink)
+	if !sendCode && !sendLink {
+		apierror.Write(w, r, http.StatusForbidden, apierror.LoginMethodDisabled, "Passwordless login is disabled")
+		return
+	}
+
+	var req LoginCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "email is required")
+		return
+	}
+
+	// Refuse the request while the account or client address is locked out
+	accountKey := throttle.AccountKey("user", req.Email)
+	ipKey := throttle.IPKey("login", h.throttle.ClientIP(r))
+	remaining, err := h.throttle.Check(r.Context(), accountKey, ipKey)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error checking login throttle", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	} else if remaining > 0 {
+		throttle.RejectLocked(w, r, remaining)
+		return
+	}
+
+	// Only fully registered seniors can log in
+	credentials, err := h.store.User(r.Context(), req.Email)
+	if errors.Is(err, ErrNotFound) || (err == nil && credentials.PasswordHash == "") {
+		slog.WarnContext(r.Context(), "Login code requested for an unregistered email")
+		respondLoginCodeSent(w)
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "Database error", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	// A registration whose profile is still pending is finished first. If it cannot be, no code is
+	// sent, with the same response as an unknown email.
+	userID := credentials.UserID
+	if credentials.RegistrationStatus != registration.StatusRegistered {
+		registered, err := h.registrations.CompleteRegistration(r.Context(), userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error completing pending registration", "user_id", userID, "error", err)
+		}
+		if !registered {
+			slog.WarnContext(r.Context(), "Login code requested before the account was set up", "user_id", userID)
+			respondLoginCodeSent(w)
+			return
+		}
+	}
+
+	// Enforce a cooldown between emails sent to the same address, silently so it does not
+	// reveal that the email is registered
+	elapsed, pending, err := h.store.LoginCodeAge(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error checking last login code", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+	if pending && elapsed < loginCodeResendDelay {
+		slog.WarnContext(r.Context(), "Login code requested again within the cooldown", "user_id", userID, "elapsed", elapsed.String())
+		respondLoginCodeSent(w)
+		return
+	}
+
+	var code, codeHash, linkToken, linkTokenHash string
+	if sendCode {
+		code, codeHash, err = generateLoginCode()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error generating login code", "error", err)
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+			return
+		}
+	}
+	if sendLink {
+		linkToken, linkTokenHash, err = generateMagicLinkToken()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error generating magic link", "error", err)
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+			return
+		}
+	}
+
+	// A new request replaces any earlier code or link for the same senior
+	err = h.store.SaveLoginCode(r.Context(), userID, codeHash, linkTokenHash)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error storing login code", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	if err := h.clients.Mail.SendLoginCode(req.Email, code, linkToken); err != nil {
+		slog.ErrorContext(r.Context(), "Error sending login code email", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Failed to send email")
+		return
+	}
+
+	respondLoginCodeSent(w)
+}
+
+// VerifyLoginCode exchanges an emailed one-time code for the normal JWT
+func (h *Handler) VerifyLoginCode(w http.ResponseWriter, r *http.Request) {
+	if !LoginMethodEnabled(LoginMethodCode) {
+		apierror.Write(w, r, http.StatusForbidden, apierror.LoginMethodDisabled, "Code login is disabled")
+		return
+	}
+
+	var req LoginCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" || req.Code == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "email and code are required")
+		return
+	}
+
+	// Refuse the attempt while the account or client address is locked out
+	accountKey := throttle.AccountKey("user", req.Email)
+	ipKey := throttle.IPKey("login", h.throttle.ClientIP(r))
+	remaining, err := h.throttle.Check(r.Context(), accountKey, ipKey)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error checking login throttle", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	} else if remaining > 0 {
+		throttle.RejectLocked(w, r, remaining)
+		return
+	}
+
+	loginCode, err := h.store.LoginCode(r.Context(), req.Email)
+	if errors.Is(err, ErrNotFound) {
+		h.recordLoginFailure(r.Context(), accountKey, ipKey)
+		apierror.Write(w, r, http.StatusUnauthorized, apierror.InvalidCode, "Invalid or expired code")
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "Database error", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	if loginCode.Age > loginCodeTTL || loginCode.Attempts >= maxLoginCodeAttempts {
+		apierror.Write(w, r, http.StatusUnauthorized, apierror.InvalidCode, "Invalid or expired code, please request a new one")
+		return
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(loginCode.CodeHash), []byte(req.Code)) != nil {
+		if err := h.store.RecordLoginCodeAttempt(r.Context(), loginCode.UserID); err != nil {
+			slog.ErrorContext(r.Context(), "Error recording login code attempt", "error", err)
+		}
+		h.recordLoginFailure(r.Context(), accountKey, ipKey)
+		apierror.Write(w, r, http.StatusUnauthorized, apierror.InvalidCode, "Invalid or expired code")
+		return
+	}
+
+	if !h.consumeLoginCode(w, r, loginCode.UserID) {
+		return
+	}
+	if err := h.throttle.Reset(r.Context(), accountKey); err != nil {
+		slog.ErrorContext(r.Context(), "Error resetting login throttle", "error", err)
+	}
+	h.respondWithUserToken(w, r, loginCode.UserID)
+}
+
+// VerifyMagicLink exchanges the token from an emailed magic link for the normal JWT
+func (h *Handler) VerifyMagicLink(w http.ResponseWriter, r *http.Request) {
+	if !LoginMethodEnabled(LoginMethodMagicLink) {
+		apierror.Write(w, r, http.StatusForbidden, apierror.LoginMethodDisabled, "Magic link login is disabled")
+		return
+	}
+
+	var req LoginCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "token is required")
+		return
+	}
+
+	// Magic link tokens are unguessable, so only the client address is throttled
+	ipKey := throttle.IPKey("login", h.throttle.ClientIP(r))
+	remaining, err := h.throttle.Check(r.Context(), ipKey)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error checking login throttle", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	} else if remaining > 0 {
+		throttle.RejectLocked(w, r, remaining)
+		return
+	}
+
+	userID, linkAge, err := h.store.MagicLink(r.Context(), hashMagicLinkToken(req.Token))
+	if errors.Is(err, ErrNotFound) {
+		if err := h.throttle.RecordFailure(r.Context(), ipKey, throttle.IPPolicy); err != nil {
+			slog.ErrorContext(r.Context(), "Error recording magic link failure", "error", err)
+		}
+		apierror.Write(w, r, http.StatusUnauthorized, apierror.InvalidToken, "Invalid or expired link")
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "Database error", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	if linkAge > loginCodeTTL {
+		apierror.Write(w, r, http.StatusUnauthorized, apierror.InvalidToken, "Invalid or expired link, please request a new one")
+		return
+	}
+
+	if !h.consumeLoginCode(w, r, userID) {
+		return
+	}
+	h.respondWithUserToken(w, r, userID)
+}
+
+// consumeLoginCode deletes the senior's pending code and link so neither can be used twice.
+// It returns false if another request consumed them first.
+func (h *Handler) consumeLoginCode(w http.ResponseWriter, r *http.Request, userID int) bool {
+	consumed, err := h.store.ConsumeLoginCode(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error consuming login code", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return false
+	}
+	if !consumed {
+		apierror.Write(w, r, http.StatusUnauthorized, apierror.InvalidCode, "Invalid or expired code")
+		return false
+	}
+	return true
+}
+
+// respondLoginCodeSent writes the response shared by known and unknown emails
+func respondLoginCodeSent(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"message": "If the email is registered, a login code has been sent"}`))
+}
+
+// generateLoginCode returns a random 6-digit code and the hash stored in its place
+func generateLoginCode() (string, string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", "", err
+	}
+	code := fmt.Sprintf("%06d", n.Int64())
+	hashed, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+	if err != nil {
+		return "", "", err
+	}
+	return code, string(hashed), nil
+}
+
+// generateMagicLinkToken returns a random link token and the hash stored in its place
+func generateMagicLinkToken() (string, string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	token := hex.EncodeToString(raw)
+	return token, hashMagicLinkToken(token), nil
+}
+
+// hashMagicLinkToken hashes a link token for storage and lookup
+func hashMagicLinkToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// sendLoginCodeEmail emails the one-time code and/or magic link, whichever were generated
+func sendLoginCodeEmail(to, code, linkToken string) error {
+	// SMTP configuration
+	mail := config.Current().SMTP
+	smtpHost, smtpPort := mail.Host, mail.Port
+	smtpUser, smtpPassword := mail.User, mail.Password
+
+	var content string
+	if code != "" {
+		content += fmt.Sprintf(`<p>Enter this code on the FallSafe login page:</p>
+		<p style="font-size: 20px; font-weight: bold;">%s</p>`, code)
+	}
+	if linkToken != "" {
+		magicLinkURL := config.Current().MagicLinkURL
+		if magicLinkURL == "" {
+			magicLinkURL = sharedconfig.FrontendURL() + magicLinkPage
+		}
+		link := magicLinkURL + "?magic_token=" + url.QueryEscape(linkToken)
+		content += fmt.Sprintf(`<p>Or simply click the button below to log in:</p>
+		<p><a href="%s" style="background-color: rgb(0, 51, 153); color: #ffffff; padding: 10px 20px; border-radius: 5px; text-decoration: none;">Log in to FallSafe</a></p>`, link)
+	}
+
+	// Email content (HTML)
+	from := "FallSafe <" + smtpUser + ">"
+	subject := "Your FallSafe Login"
+	body := fmt.Sprintf(`
+	<!DOCTYPE html>
+	<html lang="en">
+	<head>
+		<meta charset="UTF-8">
+		<title>FallSafe Login</title>
+	</head>
+	<body style="font-family: Arial, sans-serif; color: rgb(0, 51, 153);">
+		<h1>FallSafe Login</h1>
+		%s
+		<p>This can only be used once and expires in %d minutes.</p>
+		<p>If you did not try to log in, please ignore this email.</p>
+		<p>Best regards,</p>
+		<p>The FallSafe Team</p>
+	</body>
+	</html>
+	`, content, int(loginCodeTTL.Minutes()))
+
+	// Combine headers and body
+	message := fmt.Sprintf(
+		"From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/html; charset=UTF-8\r\n\r\n%s",
+		from, to, subject, body)
+
+	// Authentication
+	auth := smtp.PlainAuth("", smtpUser, smtpPassword, smtpHost)
+
+	// Send email
+	err := smtp.SendMail(smtpHost+":"+smtpPort, auth, smtpUser, []string{to}, []byte(message))
+	if err != nil {
+		slog.Error("Error sending email", "error", err)
+		return err
+	}
+	slog.Info("Login code email sent successfully")
+	return nil
+}
@@ -0,0 +1,124 @@
+package authentication
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned by a store when the requested row does not exist
+var ErrNotFound = errors.New("not found")
+
+// CredentialStore is the senior, admin and caregiver credentials the authentication handlers read and write.
+// MySQLStore keeps them in the authentication database, and the memstore package keeps them in memory for handler tests.
+type CredentialStore interface {
+	// User returns the credentials of the senior with the email, or ErrNotFound if there is none
+	User(ctx context.Context, email string) (UserCredentials, error)
+	// SaveUserToken stores a senior's latest token, replacing the one before
+	SaveUserToken(ctx context.Context, userID int, token string, expiry time.Time) error
+
+	// LoginCodeAge returns how long ago the senior's pending login code or link was sent, and false if there is none
+	LoginCodeAge(ctx context.Context, userID int) (time.Duration, bool, error)
+	// SaveLoginCode replaces the senior's pending code and link, either of which may be empty
+	SaveLoginCode(ctx context.Context, userID int, codeHash, linkTokenHash string) error
+	// LoginCode returns the pending login code of the senior with the email, or ErrNotFound if there is none
+	LoginCode(ctx context.Context, email string) (LoginCode, error)
+	// RecordLoginCodeAttempt counts a wrong guess against the senior's login code
+	RecordLoginCodeAttempt(ctx context.Context, userID int) error
+	// MagicLink returns the senior a pending link was sent to and how long ago, or ErrNotFound if there is none
+	MagicLink(ctx context.Context, linkTokenHash string) (int, time.Duration, error)
+	// ConsumeLoginCode deletes the senior's pending code and link, reporting whether there were any
+	ConsumeLoginCode(ctx context.Context, userID int) (bool, error)
+
+	// Admin returns the credentials of the admin with the email, or ErrNotFound if there is none
+	Admin(ctx context.Context, email string) (AdminCredentials, error)
+	// AdminEmail returns an admin's email, or ErrNotFound if there is no such admin
+	AdminEmail(ctx context.Context, adminID int) (string, error)
+	// SaveAdminToken stores an admin's latest token, replacing the one before
+	SaveAdminToken(ctx context.Context, adminID int, token string, expiry time.Time) error
+	// RevokeAdminTokens removes the stored tokens of an admin
+	RevokeAdminTokens(ctx context.Context, adminID int) error
+	// AdminTokenActive reports whether a token is stored, unexpired and belongs to an active admin
+	AdminTokenActive(ctx context.Context, token string) (bool, error)
+	// CreateAdminInvite adds an active admin with no password and a setup token valid for ttl,
+	// failing if the ID or email is already taken
+	CreateAdminInvite(ctx context.Context, adminID int, email, tokenHash string, ttl time.Duration) error
+	// ResetAdmin clears an admin's password, reactivates them and gives them a setup token valid for ttl
+	ResetAdmin(ctx context.Context, adminID int, tokenHash string, ttl time.Duration) error
+	// DeactivateAdmin stops an admin logging in and discards their setup token, reporting whether the admin exists
+	DeactivateAdmin(ctx context.Context, adminID int) (bool, error)
+	// AdminInvite returns the setup token of the admin with the email, or ErrNotFound if there is no such admin
+	AdminInvite(ctx context.Context, email string) (AdminInvite, error)
+	// SetAdminPassword sets an admin's password and discards their setup token
+	SetAdminPassword(ctx context.Context, adminID int, passwordHash string) error
+
+	// TOTPEnabled reports whether an admin has finished two-factor enrolment
+	TOTPEnabled(ctx context.Context, adminID int) (bool, error)
+	// SetTOTPSecret starts enrolment with a new secret, forgetting the last step used
+	SetTOTPSecret(ctx context.Context, adminID int, secret string) error
+	// EnableTOTP makes the second factor required at the admin's login
+	EnableTOTP(ctx context.Context, adminID int) error
+	// ResetTOTP removes an admin's secret and turns two-factor off, reporting whether the admin exists
+	ResetTOTP(ctx context.Context, adminID int) (bool, error)
+	// UseTOTPStep locks the admin's two-factor state and passes their secret to validate. A step validate
+	// accepts is recorded if it is later than the last one used, and true returned. An admin with no secret,
+	// or who has not finished enrolment while requireEnabled is set, is never verified.
+	UseTOTPStep(ctx context.Context, adminID int, requireEnabled bool, validate func(secret string) (int64, bool)) (bool, error)
+	// UnusedRecoveryCodes returns the admin's recovery codes that have not been used
+	UnusedRecoveryCodes(ctx context.Context, adminID int) ([]RecoveryCode, error)
+	// UseRecoveryCode marks a recovery code used, reporting false if it was already used
+	UseRecoveryCode(ctx context.Context, codeID int) (bool, error)
+	// ReplaceRecoveryCodes discards the admin's recovery codes and stores the given hashes in their place
+	ReplaceRecoveryCodes(ctx context.Context, adminID int, codeHashes []string) error
+
+	// CreateCaregiver adds a caregiver account, failing if the email is already registered
+	CreateCaregiver(ctx context.Context, email, name, passwordHash string) error
+	// Caregiver returns the credentials of the caregiver with the email, or ErrNotFound if there is none
+	Caregiver(ctx context.Context, email string) (CaregiverCredentials, error)
+}
+
+// UserCredentials is a senior's login details. PasswordHash is empty until registration sets a password.
+type UserCredentials struct {
+	UserID             int
+	PasswordHash       string
+	RegistrationStatus string
+}
+
+// LoginCode is a senior's pending passwordless login code
+type LoginCode struct {
+	UserID   int
+	CodeHash string
+	Attempts int
+	Age      time.Duration // How long ago the code was sent
+}
+
+// AdminCredentials is an admin's login details. PasswordHash is empty until an invitation is accepted.
+type AdminCredentials struct {
+	AdminID      int
+	PasswordHash string
+	Active       bool
+	TOTPEnabled  bool
+}
+
+// AdminInvite is an admin's setup token. TokenHash is empty when there is none, and ExpiresIn is
+// zero or less once it has expired.
+type AdminInvite struct {
+	AdminID   int
+	TokenHash string
+	ExpiresIn time.Duration
+	Active    bool
+}
+
+// RecoveryCode is a hashed single-use recovery code
+type RecoveryCode struct {
+	CodeID   int
+	CodeHash string
+}
+
+// CaregiverCredentials is a caregiver's login details
+type CaregiverCredentials struct {
+	CaregiverID  int
+	Name         string
+	Email        string
+	PasswordHash string
+}
@@ -0,0 +1,49 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+
+	"shared/client"
+	"shared/config"
+)
+
+// Admin is an admin's profile, with the permissions of their admin role
+type Admin struct {
+	UserID      int      `json:"user_id"`
+	Name        string   `json:"name"`
+	Email       string   `json:"email"`
+	Role        string   `json:"role"`
+	AdminRole   string   `json:"admin_role"`
+	Status      string   `json:"status"`
+	Permissions []string `json:"permissions"`
+}
+
+// Client calls the admin microservice
+type Client struct {
+	*client.Client
+}
+
+// New returns a client for the admin microservice
+func New() *Client {
+	return &Client{client.New(config.AdminService)}
+}
+
+// Admin returns an admin's profile
+func (c *Client) Admin(ctx context.Context, adminID int) (Admin, error) {
+	var admin Admin
+	err := c.Get(client.AsService(ctx), fmt.Sprintf("/api/v1/admin/getAdmin?adminID=%d", adminID), &admin)
+	return admin, err
+}
+
+// ActivateAdmin marks an invited admin's profile as active once they have set their password
+func (c *Client) ActivateAdmin(ctx context.Context, adminID int) error {
+	return c.Post(client.AsService(ctx), "/api/v1/admin/activateAdmin", map[string]int{"admin_id": adminID}, nil)
+}
+
+// OpenReferral opens a clinical referral case for a senior whose risk has turned high.
+// The admin microservice keeps one unclosed case per senior, so repeated calls are safe.
+func (c *Client) OpenReferral(ctx context.Context, userID int, source, reason string) error {
+	body := map[string]interface{}{"user_id": userID, "source": source, "reason": reason}
+	return c.Post(client.AsService(ctx), "/api/v1/admin/referrals/open", body, nil)
+}
@@ -0,0 +1,23 @@
+package config
+
+import "shared/settings"
+
+// Config is the authentication microservice's configuration
+type Config struct {
+	settings.Common
+	Port           int    `env:"PORT" default:"5050" usage:"port the service listens on"`
+	DBConnection   string `env:"AUTH_DB_CONNECTION" required:"true" secret:"true" usage:"MySQL DSN of the authentication database"`
+	MigrateOnStart bool   `env:"MIGRATE_ON_START" default:"true" usage:"apply pending schema migrations on start"`
+	SMTP           SMTP
+	LoginMethods   []string `env:"LOGIN_METHODS" default:"password" usage:"comma-separated login methods seniors may use: password, code and magic_link"`
+	MagicLinkURL   string   `env:"MAGIC_LINK_URL" usage:"page magic links open, defaulting to the frontend's login page"`
+	TrustedProxies []string `env:"TRUSTED_PROXIES" usage:"comma-separated addresses and CIDR ranges of the proxies, such as the gateway, whose X-Forwarded-For is trusted"`
+}
+
+// SMTP is the mail server emails are sent through
+type SMTP struct {
+	Host     string `env:"SMTP_HOST" default:"smtp.gmail.com" usage:"mail server host"`
+	Port     string `env:"SMTP_PORT" default:"587" usage:"mail server submission port"`
+	User     string `env:"SMTP_USER" required:"true" usage:"mail account emails are sent from"`
+	Password string `env:"SMTP_PASSWORD" required:"true" secret:"true" usage:"mail account password"`
+}
@@ -2,34 +2,114 @@ package main
 
 import (
 	"authenticationMicroservice/authentication"
+	"authenticationMicroservice/config"
+	"authenticationMicroservice/migrations"
 	"authenticationMicroservice/registration"
-	"log"
-	"net/http"
+	"authenticationMicroservice/server"
+	"authenticationMicroservice/throttle"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
 
-	"github.com/gorilla/handlers"
-	"github.com/gorilla/mux"
+	"shared/client"
+	sharedconfig "shared/config"
+	"shared/graceful"
+	"shared/logging"
+	"shared/migrate"
+	"shared/observability"
+	"shared/settings"
+	"shared/tracing"
 )
 
 func main() {
-	// Initialize the router
-	router := mux.NewRouter()
-
-	// Registration endpoints
-	router.HandleFunc("/api/v1/authentication/send-verification", registration.SendVerificationCode).Methods("POST")
-	router.HandleFunc("/api/v1/authentication/register-user", registration.RegisterUser).Methods("POST")
-
-	// Authentication endpoint
-	router.HandleFunc("/api/v1/authentication/user/login", authentication.AuthenticateUser).Methods("POST")
-	router.HandleFunc("/api/v1/authentication/admin/login", authentication.AuthenticateAdmin).Methods("POST")
-
-	// Add CORS support
-	corsHandler := handlers.CORS(
-		handlers.AllowedOrigins([]string{"http://18.143.103.158:5050"}),  // Add allowed origins here
-		handlers.AllowedMethods([]string{"GET", "POST", "OPTIONS"}), // Add allowed HTTP methods
-		handlers.AllowedHeaders([]string{"Content-Type"}),           // Add allowed headers
-	)(router)
-
-	// Start the server
-	log.Println("Authentication Microservice is running on port 5050...")
-	log.Fatal(http.ListenAndServe(":5050", corsHandler))
+	// Read the configuration from the flags, the environment, secret files and the config file
+	cfg, err := config.Load(os.Args[1:])
+	if cfg.PrintConfig {
+		settings.Print(os.Stdout, cfg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Log as JSON from here on, at the level set by LOG_LEVEL
+	logging.Setup(sharedconfig.AuthService, cfg.LogLevel)
+	if err != nil {
+		slog.Error("Error loading configuration", "error", err)
+		os.Exit(1)
+	}
+
+	// Trace requests across the services, exporting the spans if an OTLP collector is configured
+	shutdownTracing, err := tracing.Setup(context.Background(), sharedconfig.AuthService)
+	if err != nil {
+		slog.Error("Error setting up tracing", "error", err)
+		os.Exit(1)
+	}
+
+	// Run the migrate subcommand, or bring the schema up to date before serving
+	if len(cfg.Args) > 0 && cfg.Args[0] == "migrate" {
+		if err := migrate.Command(cfg.DBConnection, migrations.Files, cfg.Args[1:]); err != nil {
+			slog.Error("Error running migrations", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if err := migrate.OnStart(cfg.DBConnection, migrations.Files, cfg.MigrateOnStart); err != nil {
+		slog.Error("Error applying schema migrations", "error", err)
+		os.Exit(1)
+	}
+
+	db, err := authentication.OpenDB(cfg.DBConnection)
+	if err != nil {
+		slog.Error("Error opening authentication database", "error", err)
+		os.Exit(1)
+	}
+
+	// Client addresses are read from X-Forwarded-For only when the gateway or another trusted proxy sent it
+	trustedProxies, err := throttle.ParseProxies(cfg.TrustedProxies)
+	if err != nil {
+		slog.Error("Error reading TRUSTED_PROXIES", "error", err)
+		os.Exit(1)
+	}
+
+	// The clients are created after the configuration is loaded, which may set the service URLs
+	loginThrottle := throttle.New(throttle.NewMySQLStore(db), trustedProxies)
+	registrations := registration.NewHandler(registration.NewMySQLStore(db), registration.NewClients(), loginThrottle)
+	h := authentication.NewHandler(authentication.NewMySQLStore(db), authentication.NewClients(), loginThrottle, registrations, cfg.JWTSecret)
+
+	// Ready while the database and the services called downstream are reachable
+	health := observability.NewHealth()
+	health.AddCheck("database", observability.PingDB(db))
+	for _, service := range []string{sharedconfig.AdminService, sharedconfig.UserService} {
+		health.AddCheck(service, client.New(service).Ping)
+	}
+
+	// Route the endpoints through their middleware
+	router := server.NewRouter(h, registrations, health)
+
+	// Finish registrations whose profile could not be created straight away, until shutdown
+	outboxCtx, stopOutbox := context.WithCancel(context.Background())
+	outboxStopped := make(chan struct{})
+	go func() {
+		registrations.RunOutboxWorker(outboxCtx)
+		close(outboxStopped)
+	}()
+	stopOutboxWorker := func(ctx context.Context) error {
+		stopOutbox()
+		select {
+		case <-outboxStopped:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	// Serve until SIGTERM, then drain the requests in flight, stop the outbox worker, close the database and flush the spans
+	slog.Info("Authentication Microservice is running", "port", cfg.Port)
+	if err := graceful.Serve(fmt.Sprintf(":%d", cfg.Port), router, stopOutboxWorker, graceful.CloseFunc(db.Close), shutdownTracing); err != nil {
+		slog.Error("Server stopped", "error", err)
+		os.Exit(1)
+	}
 }
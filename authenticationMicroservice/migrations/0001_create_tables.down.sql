@@ -0,0 +1,6 @@
+-- Drops the tables of FallSafe_AuthenticationDB
+
+DROP TABLE IF EXISTS AdminAuthentication;
+DROP TABLE IF EXISTS UserAuthentication;
+DROP TABLE IF EXISTS Admin;
+DROP TABLE IF EXISTS User;
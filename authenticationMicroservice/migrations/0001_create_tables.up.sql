@@ -0,0 +1,45 @@
+-- Creates the tables of FallSafe_AuthenticationDB
+
+-- Create the User table
+-- PURPOSE: Stores user and admin registration details
+CREATE TABLE User (
+    user_id SMALLINT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT,  -- Unique ID for the user
+    email VARCHAR(100) NOT NULL UNIQUE,                              -- Email address
+    password VARCHAR(500) DEFAULT NULL,                              -- Password (hashed)
+    verification_code VARCHAR(6) DEFAULT NULL,                       -- Verification code for email verification
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,                   -- Account creation timestamp
+    INDEX idx_email (email)                                          -- Index for email lookups
+);
+
+-- Create the Admin table
+-- PURPOSE: Stores admin and admin registration details
+CREATE TABLE Admin (
+    admin_id SMALLINT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT,  -- Unique ID for the admin
+    email VARCHAR(100) NOT NULL UNIQUE,                              -- Email address
+    password VARCHAR(500) DEFAULT NULL,                              -- Password (hashed)
+    verification_code VARCHAR(6) DEFAULT NULL,                       -- Verification code for email verification
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,                   -- Account creation timestamp
+    INDEX idx_email (email)                                          -- Index for email lookups
+);
+
+-- Create the UserAuthentication table
+-- PURPOSE: Stores Userauthentication tokens and expiry information
+CREATE TABLE UserAuthentication (
+    auth_id SMALLINT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT,  -- Unique ID for Userauthentication record
+    user_id SMALLINT UNSIGNED NOT NULL,                             -- Associated user ID
+    auth_token VARCHAR(500) NOT NULL UNIQUE,                        -- UserAuthentication token
+    token_expiry TIMESTAMP NOT NULL,                                -- Expiry timestamp of the token
+    INDEX idx_user_id (user_id),                                    -- Index to optimise lookups by user_id
+    INDEX idx_token_expiry (token_expiry)                           -- Index to optimise expiry checks
+);
+
+-- Create the AdminAuthentication table
+-- PURPOSE: Stores adminauthentication tokens and expiry information
+CREATE TABLE AdminAuthentication (
+    auth_id SMALLINT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT,  -- Unique ID for adminauthentication record
+    admin_id SMALLINT UNSIGNED NOT NULL,                             -- Associated admin ID
+    auth_token VARCHAR(500) NOT NULL UNIQUE,                        -- adminAuthentication token
+    token_expiry TIMESTAMP NOT NULL,                                -- Expiry timestamp of the token
+    INDEX idx_admin_id (admin_id),                                    -- Index to optimise lookups by admin_id
+    INDEX idx_token_expiry (token_expiry)                           -- Index to optimise expiry checks
+);
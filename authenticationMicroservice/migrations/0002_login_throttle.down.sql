@@ -0,0 +1,7 @@
+-- Drops the login throttle and goes back to plain verification codes. Hashed codes do not fit the
+-- plain column and are cleared.
+
+DROP TABLE IF EXISTS LoginThrottle;
+ALTER TABLE User DROP COLUMN verification_attempts;
+UPDATE User SET verification_code = NULL WHERE verification_code IS NOT NULL;
+ALTER TABLE User MODIFY verification_code VARCHAR(6) DEFAULT NULL;
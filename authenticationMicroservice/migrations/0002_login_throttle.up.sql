@@ -0,0 +1,17 @@
+-- Hashes the verification codes, counts wrong guesses against them and throttles failed logins
+
+-- A bcrypt hash of the code does not fit the 6 characters of the plain code
+ALTER TABLE User MODIFY verification_code VARCHAR(100) DEFAULT NULL;           -- Verification code for email verification (hashed)
+ALTER TABLE User ADD COLUMN verification_attempts TINYINT UNSIGNED NOT NULL DEFAULT 0 AFTER verification_code; -- Wrong guesses against the current code
+
+-- Codes sent before they were hashed can no longer be checked, so they are cleared and sent again
+UPDATE User SET verification_code = NULL WHERE verification_code IS NOT NULL;
+
+-- Create the LoginThrottle table
+-- PURPOSE: Tracks failed login and verification attempts per account and client address
+CREATE TABLE LoginThrottle (
+    throttle_key VARCHAR(150) NOT NULL PRIMARY KEY,                  -- e.g. account:user:<email> or ip:login:<address>
+    failed_attempts SMALLINT UNSIGNED NOT NULL DEFAULT 0,            -- Failures within the current window
+    locked_until TIMESTAMP NULL DEFAULT NULL,                        -- Attempts are refused until this time
+    last_failed_at TIMESTAMP NULL DEFAULT NULL                       -- Time of the most recent failure
+);
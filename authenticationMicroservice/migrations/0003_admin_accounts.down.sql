@@ -0,0 +1,5 @@
+-- Removes admin deactivation and invitations
+
+ALTER TABLE Admin DROP COLUMN invite_expiry;
+ALTER TABLE Admin DROP COLUMN invite_token;
+ALTER TABLE Admin DROP COLUMN active;
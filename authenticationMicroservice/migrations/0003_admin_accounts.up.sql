@@ -0,0 +1,5 @@
+-- Lets admins be deactivated and invited with a setup link
+
+ALTER TABLE Admin ADD COLUMN active BOOLEAN NOT NULL DEFAULT TRUE AFTER verification_code;   -- Deactivated admins cannot log in
+ALTER TABLE Admin ADD COLUMN invite_token VARCHAR(64) DEFAULT NULL AFTER active;             -- Setup token for invitations and resets (SHA-256)
+ALTER TABLE Admin ADD COLUMN invite_expiry TIMESTAMP NULL DEFAULT NULL AFTER invite_token;   -- Expiry of the setup token
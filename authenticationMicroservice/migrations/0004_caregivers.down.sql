@@ -0,0 +1,3 @@
+-- Removes caregiver accounts
+
+DROP TABLE IF EXISTS Caregiver;
@@ -0,0 +1,12 @@
+-- Adds caregiver accounts
+
+-- Create the Caregiver table
+-- PURPOSE: Stores family member and caregiver login details
+CREATE TABLE Caregiver (
+    caregiver_id SMALLINT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT, -- Unique ID for the caregiver
+    email VARCHAR(100) NOT NULL UNIQUE,                              -- Email address the senior invited
+    name VARCHAR(100) NOT NULL,                                      -- Caregiver's name
+    password VARCHAR(500) NOT NULL,                                  -- Password (hashed)
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,                  -- Account creation timestamp
+    INDEX idx_email (email)                                          -- Index for email lookups
+);
@@ -0,0 +1,3 @@
+-- Removes passwordless login
+
+DROP TABLE IF EXISTS LoginCode;
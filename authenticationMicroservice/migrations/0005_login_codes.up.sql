@@ -0,0 +1,11 @@
+-- Adds passwordless login by emailed code or magic link
+
+-- Create the LoginCode table
+-- PURPOSE: Stores pending passwordless login codes and magic links, one per user, deleted once used
+CREATE TABLE LoginCode (
+    user_id SMALLINT UNSIGNED NOT NULL PRIMARY KEY,                  -- Associated user ID
+    code_hash VARCHAR(100) DEFAULT NULL,                             -- One-time code (hashed)
+    link_token_hash VARCHAR(64) DEFAULT NULL UNIQUE,                 -- Magic link token (SHA-256)
+    attempts TINYINT UNSIGNED NOT NULL DEFAULT 0,                    -- Wrong codes entered
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP                   -- When the code was sent
+);
@@ -0,0 +1,6 @@
+-- Removes two-factor authentication for admins
+
+DROP TABLE IF EXISTS AdminRecoveryCode;
+ALTER TABLE Admin DROP COLUMN totp_last_step;
+ALTER TABLE Admin DROP COLUMN totp_enabled;
+ALTER TABLE Admin DROP COLUMN totp_secret;
@@ -0,0 +1,16 @@
+-- Adds TOTP two-factor authentication and recovery codes for admins
+
+ALTER TABLE Admin ADD COLUMN totp_secret VARCHAR(64) DEFAULT NULL AFTER invite_expiry;          -- Base32 TOTP secret, set at enrolment
+ALTER TABLE Admin ADD COLUMN totp_enabled BOOLEAN NOT NULL DEFAULT FALSE AFTER totp_secret;     -- Second factor required at login once verified
+ALTER TABLE Admin ADD COLUMN totp_last_step BIGINT DEFAULT NULL AFTER totp_enabled;             -- Last accepted TOTP time step, prevents code replay
+
+-- Create the AdminRecoveryCode table
+-- PURPOSE: Stores single-use recovery codes for admins who lose their authenticator device
+CREATE TABLE AdminRecoveryCode (
+    code_id INT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT,        -- Unique ID for the recovery code
+    admin_id SMALLINT UNSIGNED NOT NULL,                             -- Associated admin ID
+    code_hash VARCHAR(100) NOT NULL,                                 -- Recovery code (hashed)
+    used_at TIMESTAMP NULL DEFAULT NULL,                             -- When the code was used, NULL if unused
+    INDEX idx_admin_id (admin_id),                                   -- Index for lookups by admin
+    FOREIGN KEY (admin_id) REFERENCES Admin(admin_id) ON DELETE CASCADE
+);
@@ -0,0 +1,4 @@
+-- Removes the registration outbox and the registration status of users
+
+DROP TABLE IF EXISTS RegistrationOutbox;
+ALTER TABLE User DROP COLUMN registration_status;
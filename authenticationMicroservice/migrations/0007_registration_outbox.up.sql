@@ -0,0 +1,19 @@
+-- Makes registration a workflow that creates the profile through an outbox. Users registered
+-- before it existed are Registered by the column's default.
+
+ALTER TABLE User ADD COLUMN registration_status ENUM('Verifying', 'ProfilePending', 'Registered') NOT NULL DEFAULT 'Registered' AFTER verification_attempts; -- Login is allowed once Registered
+
+-- Create the RegistrationOutbox table
+-- PURPOSE: Queues profile creation in the user microservice so failed registrations are retried or rolled back
+CREATE TABLE RegistrationOutbox (
+    outbox_id INT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT,      -- Unique ID for the outbox entry
+    user_id SMALLINT UNSIGNED NOT NULL UNIQUE,                       -- Registering user, one entry per user
+    payload JSON NOT NULL,                                           -- Profile to create (no password)
+    status ENUM('Pending', 'Completed', 'Compensated') NOT NULL DEFAULT 'Pending', -- Delivery state
+    attempts TINYINT UNSIGNED NOT NULL DEFAULT 0,                    -- Failed delivery attempts
+    next_attempt_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,             -- Earliest time of the next attempt, also the claim lease
+    last_error VARCHAR(500) DEFAULT NULL,                            -- Most recent delivery error
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,                  -- When the registration was queued
+    INDEX idx_status_next_attempt (status, next_attempt_at),         -- Index for the worker's poll
+    FOREIGN KEY (user_id) REFERENCES User(user_id) ON DELETE CASCADE
+);
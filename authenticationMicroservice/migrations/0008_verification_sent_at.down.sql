@@ -0,0 +1,3 @@
+-- Removes the time the verification code was sent
+
+ALTER TABLE User DROP COLUMN verification_sent_at;
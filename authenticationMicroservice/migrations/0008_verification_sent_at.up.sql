@@ -0,0 +1,7 @@
+-- Records when the verification code was sent in its own column, leaving created_at as the time
+-- the account was created
+
+ALTER TABLE User ADD COLUMN verification_sent_at TIMESTAMP NULL DEFAULT NULL AFTER verification_attempts; -- When the current verification code was sent
+
+-- Sending a code used to overwrite created_at, so for a code still pending it holds the time it was sent
+UPDATE User SET verification_sent_at = created_at WHERE verification_code IS NOT NULL;
@@ -0,0 +1,239 @@
+package registration
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// MySQLStore is the Store kept in the authentication database
+type MySQLStore struct {
+	db *sql.DB
+}
+
+// NewMySQLStore returns a store reading and writing the given database
+func NewMySQLStore(db *sql.DB) *MySQLStore {
+	return &MySQLStore{db: db}
+}
+
+func (s *MySQLStore) VerificationCodeAge(ctx context.Context, email string) (time.Duration, bool, error) {
+	var secondsSinceLastCode sql.NullInt64
+	err := s.db.QueryRowContext(ctx, `
+		SELECT TIMESTAMPDIFF(SECOND, verification_sent_at, NOW())
+		FROM User
+		WHERE email = ? AND verification_code IS NOT NULL`, email).Scan(&secondsSinceLastCode)
+	if err == sql.ErrNoRows || (err == nil && !secondsSinceLastCode.Valid) {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, err
+	}
+	return time.Duration(secondsSinceLastCode.Int64) * time.Second, true, nil
+}
+
+func (s *MySQLStore) SaveVerificationCode(ctx context.Context, email, codeHash string) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO User (email, verification_code, verification_attempts, verification_sent_at, registration_status)
+		VALUES (?, ?, 0, NOW(), ?)
+		ON DUPLICATE KEY UPDATE
+		verification_code = VALUES(verification_code), verification_attempts = 0, verification_sent_at = VALUES(verification_sent_at)
+	`, email, codeHash, StatusVerifying)
+	return err
+}
+
+func (s *MySQLStore) VerificationCode(ctx context.Context, email string) (VerificationCode, error) {
+	var codeHash sql.NullString
+	var code VerificationCode
+	var codeAgeSeconds sql.NullInt64
+	err := s.db.QueryRowContext(ctx, `
+		SELECT verification_code, verification_attempts, TIMESTAMPDIFF(SECOND, verification_sent_at, NOW())
+		FROM User
+		WHERE email = ?`, email).Scan(&codeHash, &code.Attempts, &codeAgeSeconds)
+	if err == sql.ErrNoRows || (err == nil && (!codeHash.Valid || !codeAgeSeconds.Valid)) {
+		return VerificationCode{}, ErrNotFound
+	} else if err != nil {
+		return VerificationCode{}, err
+	}
+	code.CodeHash = codeHash.String
+	code.Age = time.Duration(codeAgeSeconds.Int64) * time.Second
+	return code, nil
+}
+
+func (s *MySQLStore) RecordVerificationAttempt(ctx context.Context, email string) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE User SET verification_attempts = verification_attempts + 1 WHERE email = ?`, email)
+	return err
+}
+
+func (s *MySQLStore) SaveRegistration(ctx context.Context, email, passwordHash string, profile ProfilePayload) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to start registration transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var userID int
+	if err := tx.QueryRowContext(ctx, `SELECT user_id FROM User WHERE email = ? FOR UPDATE`, email).Scan(&userID); err != nil {
+		return 0, fmt.Errorf("failed to lock user row: %v", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE User
+		SET password = ?, verification_code = NULL, verification_attempts = 0, registration_status = ?
+		WHERE user_id = ?`,
+		passwordHash, StatusProfilePending, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update user data: %v", err)
+	}
+
+	profile.UserID = userID
+	if err := enqueueProfile(ctx, tx, profile); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit registration: %v", err)
+	}
+	return userID, nil
+}
+
+// enqueueProfile records the profile to create in the same transaction that sets the password,
+// so a password is never saved without the work needed to finish the registration
+func enqueueProfile(ctx context.Context, tx *sql.Tx, profile ProfilePayload) error {
+	payload, err := json.Marshal(profile)
+	if err != nil {
+		return fmt.Errorf("failed to marshal profile: %v", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO RegistrationOutbox (user_id, payload, status, attempts, next_attempt_at, last_error)
+		VALUES (?, ?, ?, 0, NOW(), NULL)
+		ON DUPLICATE KEY UPDATE
+		payload = VALUES(payload), status = VALUES(status), attempts = 0, next_attempt_at = VALUES(next_attempt_at), last_error = NULL`,
+		profile.UserID, string(payload), outboxPending)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue profile: %v", err)
+	}
+	return nil
+}
+
+func (s *MySQLStore) RegistrationStatus(ctx context.Context, userID int) (string, error) {
+	var status string
+	err := s.db.QueryRowContext(ctx, `SELECT registration_status FROM User WHERE user_id = ?`, userID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return "", ErrNotFound
+	}
+	return status, err
+}
+
+func (s *MySQLStore) PendingOutboxEntry(ctx context.Context, userID int) (int, error) {
+	var outboxID int
+	err := s.db.QueryRowContext(ctx, `
+		SELECT outbox_id
+		FROM RegistrationOutbox
+		WHERE user_id = ? AND status = ?`, userID, outboxPending).Scan(&outboxID)
+	if err == sql.ErrNoRows {
+		return 0, ErrNotFound
+	}
+	return outboxID, err
+}
+
+func (s *MySQLStore) DueOutboxEntries(ctx context.Context, limit int) ([]int, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT outbox_id
+		FROM RegistrationOutbox
+		WHERE status = ? AND next_attempt_at <= NOW()
+		ORDER BY next_attempt_at
+		LIMIT ?`, outboxPending, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []int
+	for rows.Next() {
+		var outboxID int
+		if err := rows.Scan(&outboxID); err == nil {
+			due = append(due, outboxID)
+		}
+	}
+	return due, rows.Err()
+}
+
+func (s *MySQLStore) ClaimOutboxEntry(ctx context.Context, outboxID int, lease time.Duration, force bool) (bool, error) {
+	query := `
+		UPDATE RegistrationOutbox
+		SET next_attempt_at = NOW() + INTERVAL ? SECOND
+		WHERE outbox_id = ? AND status = ?`
+	if !force {
+		query += ` AND next_attempt_at <= NOW()`
+	}
+
+	result, err := s.db.ExecContext(ctx, query, int(lease.Seconds()), outboxID, outboxPending)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+func (s *MySQLStore) OutboxEntry(ctx context.Context, outboxID int) (OutboxEntry, error) {
+	var entry OutboxEntry
+	err := s.db.QueryRowContext(ctx, `
+		SELECT payload, attempts
+		FROM RegistrationOutbox
+		WHERE outbox_id = ?`, outboxID).Scan(&entry.Payload, &entry.Attempts)
+	if err == sql.ErrNoRows {
+		return OutboxEntry{}, ErrNotFound
+	}
+	return entry, err
+}
+
+func (s *MySQLStore) RetryOutboxEntry(ctx context.Context, outboxID, attempts int, backoff time.Duration, lastError string) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE RegistrationOutbox
+		SET attempts = ?, next_attempt_at = NOW() + INTERVAL ? SECOND, last_error = ?
+		WHERE outbox_id = ?`,
+		attempts, int(backoff.Seconds()), lastError, outboxID)
+	return err
+}
+
+func (s *MySQLStore) CompleteOutboxEntry(ctx context.Context, outboxID, userID int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `UPDATE RegistrationOutbox SET status = ?, last_error = NULL WHERE outbox_id = ?`, outboxCompleted, outboxID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE User SET registration_status = ? WHERE user_id = ?`, StatusRegistered, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *MySQLStore) CompensateOutboxEntry(ctx context.Context, outboxID, userID int, reason string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE RegistrationOutbox
+		SET status = ?, last_error = ?
+		WHERE outbox_id = ?`, outboxCompensated, reason, outboxID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE User
+		SET password = NULL, verification_code = NULL, verification_attempts = 0, registration_status = ?
+		WHERE user_id = ? AND registration_status = ?`, StatusVerifying, userID, StatusProfilePending)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
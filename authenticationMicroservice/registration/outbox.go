@@ -0,0 +1,187 @@
+package registration
+
+import (
+	"authenticationMicroservice/client/user"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"shared/client"
+)
+
+// Registration statuses, stored in the registration_status column of the User table
+const (
+	StatusVerifying      = "Verifying"      // Email verification code sent, no password yet
+	StatusProfilePending = "ProfilePending" // Password set, profile not yet created in the user microservice
+	StatusRegistered     = "Registered"     // Fully registered, can log in
+)
+
+// Outbox entry statuses
+const (
+	outboxPending     = "Pending"
+	outboxCompleted   = "Completed"
+	outboxCompensated = "Compensated"
+)
+
+const (
+	outboxPollInterval = 15 * time.Second // How often the worker looks for due entries
+	outboxLease        = 1 * time.Minute  // How long a claimed entry is hidden from other replicas
+	outboxBaseBackoff  = 30 * time.Second // Delay before the first retry, doubled on every failure
+	outboxMaxBackoff   = 1 * time.Hour    // Upper bound for the retry delay
+	maxOutboxAttempts  = 8                // Failed attempts before the registration is rolled back
+	outboxBatchSize    = 50               // Due entries dispatched per poll
+)
+
+// ProfilePayload is the profile sent to the user microservice, kept in the outbox until it is delivered
+type ProfilePayload struct {
+	UserID      int    `json:"user_id"`
+	Email       string `json:"email"`
+	Name        string `json:"name"`
+	PhoneNumber string `json:"phone_number"`
+	Address     string `json:"address"`
+	Age         string `json:"age"`
+}
+
+// permanentError marks a failure that retrying cannot fix, such as the email belonging to another profile
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+// CompleteRegistration tries to finish a pending registration straight away, ignoring any retry delay.
+// It returns true once the senior is fully registered.
+func (h *Handler) CompleteRegistration(ctx context.Context, userID int) (bool, error) {
+	outboxID, err := h.store.PendingOutboxEntry(ctx, userID)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if err := h.dispatchOutboxEntry(ctx, outboxID, true); err != nil {
+		return false, err
+	}
+
+	status, err := h.store.RegistrationStatus(ctx, userID)
+	return status == StatusRegistered, err
+}
+
+// RunOutboxWorker delivers pending profiles in the background until stop is cancelled, finishing the
+// batch it is delivering first. Every replica runs one; leases stop two replicas working on the same
+// entry at once.
+func (h *Handler) RunOutboxWorker(stop context.Context) {
+	slog.Info("Registration outbox worker started")
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+
+	ctx := context.WithoutCancel(stop)
+	for {
+		select {
+		case <-stop.Done():
+			slog.Info("Registration outbox worker stopped")
+			return
+		case <-ticker.C:
+		}
+
+		due, err := h.store.DueOutboxEntries(ctx, outboxBatchSize)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error polling registration outbox", "error", err)
+			continue
+		}
+
+		for _, outboxID := range due {
+			if err := h.dispatchOutboxEntry(ctx, outboxID, false); err != nil {
+				slog.ErrorContext(ctx, "Error dispatching registration outbox entry", "outbox_id", outboxID, "error", err)
+			}
+		}
+	}
+}
+
+// dispatchOutboxEntry claims an entry and tries to create its profile, recording the outcome.
+// Delivery failures are not returned as errors; they are scheduled for retry or compensated.
+// Forced claims skip the retry delay so a senior waiting on the registration page or logging in
+// does not wait for the backoff.
+func (h *Handler) dispatchOutboxEntry(ctx context.Context, outboxID int, force bool) error {
+	claimed, err := h.store.ClaimOutboxEntry(ctx, outboxID, outboxLease, force)
+	if err != nil || !claimed {
+		return err
+	}
+
+	entry, err := h.store.OutboxEntry(ctx, outboxID)
+	if err != nil {
+		return err
+	}
+
+	var profile ProfilePayload
+	if err := json.Unmarshal([]byte(entry.Payload), &profile); err != nil {
+		return h.compensateRegistration(ctx, outboxID, profile.UserID, fmt.Sprintf("invalid payload: %v", err))
+	}
+
+	deliveryErr := h.callUserMicroservice(ctx, profile)
+	if deliveryErr == nil {
+		return h.markRegistrationComplete(ctx, outboxID, profile.UserID)
+	}
+
+	attempts := entry.Attempts + 1
+	slog.WarnContext(ctx, "Creating profile failed", "user_id", profile.UserID, "attempt", attempts, "max_attempts", maxOutboxAttempts, "error", deliveryErr)
+	if _, permanent := deliveryErr.(permanentError); permanent || attempts >= maxOutboxAttempts {
+		return h.compensateRegistration(ctx, outboxID, profile.UserID, deliveryErr.Error())
+	}
+
+	return h.store.RetryOutboxEntry(ctx, outboxID, attempts, outboxBackoff(attempts), truncateError(deliveryErr.Error()))
+}
+
+// markRegistrationComplete closes the outbox entry and lets the senior log in
+func (h *Handler) markRegistrationComplete(ctx context.Context, outboxID, userID int) error {
+	if err := h.store.CompleteOutboxEntry(ctx, outboxID, userID); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "Registration completed", "user_id", userID)
+	return nil
+}
+
+// compensateRegistration undoes the password step of a registration that cannot be completed,
+// returning the email to the state where the senior can request a new code and sign up again
+func (h *Handler) compensateRegistration(ctx context.Context, outboxID, userID int, reason string) error {
+	if err := h.store.CompensateOutboxEntry(ctx, outboxID, userID, truncateError(reason)); err != nil {
+		return err
+	}
+	slog.WarnContext(ctx, "Registration rolled back", "user_id", userID, "reason", reason)
+	return nil
+}
+
+// outboxBackoff doubles the base delay for every failed attempt, capped at the maximum
+func outboxBackoff(attempts int) time.Duration {
+	backoff := outboxBaseBackoff
+	for i := 1; i < attempts && backoff < outboxMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > outboxMaxBackoff {
+		return outboxMaxBackoff
+	}
+	return backoff
+}
+
+// truncateError keeps stored error messages within the last_error column
+func truncateError(message string) string {
+	if len(message) > 500 {
+		return message[:500]
+	}
+	return message
+}
+
+// callUserMicroservice asks the user microservice to create the profile. The call is idempotent,
+// so it is safe to repeat after a timeout where the profile was in fact created.
+func (h *Handler) callUserMicroservice(ctx context.Context, profile ProfilePayload) error {
+	err := h.clients.User.CreateProfile(ctx, user.Profile(profile))
+	if status := client.StatusCode(err); status >= 400 && status < 500 {
+		return permanentError{err}
+	}
+	return err
+}
@@ -1,19 +1,22 @@
 package registration
 
 import (
-	"bytes"
-	"database/sql"
+	"authenticationMicroservice/client/user"
+	"authenticationMicroservice/config"
+	"authenticationMicroservice/throttle"
+	"context"
+	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
-	"math/rand"
+	"log/slog"
+	"math/big"
 	"net/http"
 	"net/smtp"
-	"os"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
-	"github.com/joho/godotenv"
+	"shared/apierror"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -25,74 +28,140 @@ type User struct {
 	CreatedAt        string `json:"created_at"`
 }
 
-// DB connection details
-var db *sql.DB
+const (
+	verificationCodeTTL     = 10 * time.Minute // How long an emailed code stays valid
+	verificationResendDelay = 1 * time.Minute  // Minimum gap between two codes for the same email
+	maxVerificationAttempts = 5                // Wrong guesses allowed before a code is discarded
+)
 
-func init() {
-	// Load environment variables
-	err := godotenv.Load(".env")
-	if err != nil {
-		log.Fatalf("Error loading .env file: %v", err)
-	}
+// ProfileCreator creates profiles in the user microservice
+type ProfileCreator interface {
+	CreateProfile(ctx context.Context, profile user.Profile) error
+}
 
-	// Initialize database connection
-	log.Println("Initializing database connection...")
-	db, err = sql.Open("mysql", os.Getenv("AUTH_DB_CONNECTION"))
-	if err != nil {
-		log.Fatalf("Error connecting to database: %v", err)
-	}
+// Mailer sends the verification code a senior registers with
+type Mailer interface {
+	SendVerificationCode(to, code string) error
+}
 
-	// Test the database connection
-	err = db.Ping()
-	if err != nil {
-		log.Fatalf("Database connection test failed: %v", err)
+// Clients are the services a registration is completed with
+type Clients struct {
+	User ProfileCreator
+	Mail Mailer
+}
+
+// NewClients returns the user microservice client at its configured URL and the SMTP mailer
+func NewClients() Clients {
+	return Clients{
+		User: user.New(),
+		Mail: SMTPMailer{},
 	}
-	log.Println("Database connection successful.")
+}
+
+// Handler serves the registration endpoints and completes registrations from its store
+type Handler struct {
+	store    Store
+	clients  Clients
+	throttle *throttle.Throttle
+}
+
+// NewHandler returns the registration handlers, reading and writing store, calling the other services
+// with clients and locking out client addresses that guess verification codes with throttle
+func NewHandler(store Store, clients Clients, throttle *throttle.Throttle) *Handler {
+	return &Handler{store: store, clients: clients, throttle: throttle}
+}
+
+// SMTPMailer sends emails through the SMTP account in the .env file
+type SMTPMailer struct{}
+
+// SendVerificationCode emails the code a senior enters to finish registering
+func (SMTPMailer) SendVerificationCode(to, code string) error {
+	return sendEmail(to, code)
 }
 
 // SendVerificationCode handles sending the verification code and storing it in the database.
-func SendVerificationCode(w http.ResponseWriter, r *http.Request) {
-	log.Println("Handling /send-verification request...")
+func (h *Handler) SendVerificationCode(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Handling /send-verification request")
 	var user User
 
 	// Parse the incoming request
 	err := json.NewDecoder(r.Body).Decode(&user)
 	if err != nil {
-		log.Printf("Error parsing request body: %v", err)
-		http.Error(w, "Invalid input", http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "Error parsing request body", "error", err)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidBody, "Invalid input")
 		return
 	}
-	log.Printf("Parsed request: %+v", user)
+	slog.DebugContext(r.Context(), "Parsed request")
+
+	// Refuse the request while the client address is locked out
+	ipKey := throttle.IPKey("send-verification", h.throttle.ClientIP(r))
+	remaining, err := h.throttle.Check(r.Context(), ipKey)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error checking verification throttle", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Database error")
+		return
+	} else if remaining > 0 {
+		slog.WarnContext(r.Context(), "Verification code request rejected while locked out", "remaining", remaining.String())
+		throttle.RejectLocked(w, r, remaining)
+		return
+	}
+
+	// Enforce a cooldown between codes sent to the same email
+	elapsed, pending, err := h.store.VerificationCodeAge(r.Context(), user.Email)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error checking last verification code", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Database error")
+		return
+	}
+	if pending {
+		if elapsed < verificationResendDelay {
+			slog.WarnContext(r.Context(), "Verification code requested again too soon", "email", user.Email, "elapsed", elapsed.String())
+			throttle.RejectLocked(w, r, verificationResendDelay-elapsed)
+			return
+		}
+	}
+
+	// Every code sent counts against the client address, so one client cannot email codes to any
+	// number of addresses
+	if err := h.throttle.RecordFailure(r.Context(), ipKey, throttle.IPPolicy); err != nil {
+		slog.ErrorContext(r.Context(), "Error recording verification code request", "error", err)
+	}
 
 	// Generate a random 6-digit verification code
-	rand.Seed(time.Now().UnixNano())
-	verificationCode := fmt.Sprintf("%06d", rand.Intn(1000000))
-	log.Printf("Generated verification code: %s", verificationCode)
+	verificationCode, err := generateVerificationCode()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error generating verification code", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Failed to generate verification code")
+		return
+	}
+
+	// Only a hash of the code is kept so a database leak does not expose live codes
+	hashedCode, err := bcrypt.GenerateFromPassword([]byte(verificationCode), bcrypt.DefaultCost)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error hashing verification code", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Failed to generate verification code")
+		return
+	}
 
 	// Insert or update email and verification code in the database
-	log.Println("Inserting or updating verification code in the database...")
-	_, err = db.Exec(`
-		INSERT INTO User (email, verification_code, created_at)
-		VALUES (?, ?, ?)
-		ON DUPLICATE KEY UPDATE
-		verification_code = VALUES(verification_code), created_at = VALUES(created_at)
-	`, user.Email, verificationCode, time.Now().Format("2006-01-02 15:04:05"))
+	slog.DebugContext(r.Context(), "Inserting or updating verification code in the database")
+	err = h.store.SaveVerificationCode(r.Context(), user.Email, string(hashedCode))
 	if err != nil {
-		log.Printf("Error inserting or updating database: %v", err)
-		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error inserting or updating database", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Database error")
 		return
 	}
-	log.Println("Verification code inserted or updated in the database.")
+	slog.InfoContext(r.Context(), "Verification code inserted or updated in the database")
 
 	// Send the verification code via email
-	log.Printf("Sending verification code to %s...", user.Email)
-	err = sendEmail(user.Email, verificationCode)
+	slog.InfoContext(r.Context(), "Sending verification code", "email", user.Email)
+	err = h.clients.Mail.SendVerificationCode(user.Email, verificationCode)
 	if err != nil {
-		log.Printf("Error sending email: %v", err)
-		http.Error(w, "Failed to send email", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error sending email", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Failed to send email")
 		return
 	}
-	log.Println("Verification code sent successfully.")
+	slog.InfoContext(r.Context(), "Verification code sent successfully")
 
 	// Respond with success
 	w.Header().Set("Content-Type", "application/json")
@@ -100,13 +169,21 @@ func SendVerificationCode(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(`{"message": "Verification code sent successfully"}`))
 }
 
+// generateVerificationCode returns a uniformly random 6-digit code from a cryptographic source
+func generateVerificationCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
 // sendEmail sends an email containing the verification code.
 func sendEmail(to, code string) error {
-	// SMTP configuration from .env
-	smtpHost := "smtp.gmail.com"
-	smtpPort := "587"
-	smtpUser := os.Getenv("SMTP_USER")
-	smtpPassword := os.Getenv("SMTP_PASSWORD")
+	// SMTP configuration
+	mail := config.Current().SMTP
+	smtpHost, smtpPort := mail.Host, mail.Port
+	smtpUser, smtpPassword := mail.User, mail.Password
 
 	// Email content (HTML)
 	from := "FallSafe <" + smtpUser + ">"
@@ -188,140 +265,133 @@ func sendEmail(to, code string) error {
 	// Send email
 	err := smtp.SendMail(smtpHost+":"+smtpPort, auth, smtpUser, []string{to}, []byte(message))
 	if err != nil {
-		log.Printf("Error sending email: %v", err)
+		slog.Error("Error sending email", "error", err)
 		return err
 	}
-	log.Println("Email sent successfully.")
+	slog.Info("Email sent successfully")
 	return nil
 }
 
 // RegisterUser registers a new user.
-func RegisterUser(w http.ResponseWriter, r *http.Request) {
-	log.Println("Handling /register-user request...")
+func (h *Handler) RegisterUser(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Handling /register-user request")
 
 	var user struct {
-		Email           string `json:"email"`
+		Email            string `json:"email"`
 		VerificationCode string `json:"verification_code"`
-		Name            string `json:"name"`
-		Password        string `json:"password"`
-		PhoneNumber     string `json:"phone_number"`
-		Address         string `json:"address"`
-		Age             uint8   `json:"age"`
+		Name             string `json:"name"`
+		Password         string `json:"password"`
+		PhoneNumber      string `json:"phone_number"`
+		Address          string `json:"address"`
+		Age              uint8  `json:"age"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		log.Printf("Error parsing request body: %v", err)
-		http.Error(w, "Invalid input", http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "Error parsing request body", "error", err)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidBody, "Invalid input")
 		return
 	}
 
-	log.Printf("Parsed request: %+v", user)
+	slog.DebugContext(r.Context(), "Parsed request")
+
+	// Refuse the attempt while the client address is locked out
+	ipKey := throttle.IPKey("register", h.throttle.ClientIP(r))
+	remaining, err := h.throttle.Check(r.Context(), ipKey)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error checking registration throttle", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Database error")
+		return
+	} else if remaining > 0 {
+		slog.WarnContext(r.Context(), "Registration attempt rejected while locked out", "remaining", remaining.String())
+		throttle.RejectLocked(w, r, remaining)
+		return
+	}
 
 	// Verify the provided verification code and timestamp
-	log.Printf("Checking verification code for email: %s", user.Email)
-	var dbVerificationCode, dbCreatedAt string
-	err := db.QueryRow("SELECT verification_code, created_at FROM User WHERE email = ?", user.Email).Scan(&dbVerificationCode, &dbCreatedAt)
-	if err == sql.ErrNoRows {
-		log.Printf("No verification code found for email: %s", user.Email)
-		http.Error(w, "Invalid verification code or email", http.StatusUnauthorized)
+	slog.DebugContext(r.Context(), "Checking verification code", "email", user.Email)
+	code, err := h.store.VerificationCode(r.Context(), user.Email)
+	if errors.Is(err, ErrNotFound) {
+		slog.WarnContext(r.Context(), "No verification code found", "email", user.Email)
+		h.recordVerificationFailure(r.Context(), ipKey)
+		apierror.Write(w, r, http.StatusUnauthorized, apierror.InvalidCode, "Invalid verification code or email")
 		return
 	} else if err != nil {
-		log.Printf("Database error while fetching verification code for email %s: %v", user.Email, err)
-		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Database error while fetching verification code", "email", user.Email, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Database error")
 		return
 	}
-	
-	log.Printf("Fetched verification code: %s, created at: %s for email: %s", dbVerificationCode, dbCreatedAt, user.Email)
-	
-	createdAt, err := time.Parse("2006-01-02 15:04:05", dbCreatedAt)
-	if err != nil {
-		log.Printf("Error parsing timestamp %s for email %s: %v", dbCreatedAt, user.Email, err)
-		http.Error(w, "Verification code expired", http.StatusUnauthorized)
+
+	if code.Age > verificationCodeTTL {
+		slog.WarnContext(r.Context(), "Verification code expired", "email", user.Email, "age", code.Age.String())
+		apierror.Write(w, r, http.StatusUnauthorized, apierror.InvalidCode, "Verification code expired")
 		return
 	}
-	
-	if time.Since(createdAt) > 10*time.Minute {
-		log.Printf("Verification code for email %s expired. Created at: %s", user.Email, dbCreatedAt)
-		http.Error(w, "Verification code expired", http.StatusUnauthorized)
+
+	if code.Attempts >= maxVerificationAttempts {
+		slog.WarnContext(r.Context(), "Verification code has used all its attempts", "email", user.Email, "max_attempts", maxVerificationAttempts)
+		apierror.Write(w, r, http.StatusUnauthorized, apierror.InvalidCode, "Too many incorrect attempts, please request a new verification code")
 		return
 	}
-	
-	if dbVerificationCode != user.VerificationCode {
-		log.Printf("Verification code mismatch for email %s. Expected: %s, Provided: %s", user.Email, dbVerificationCode, user.VerificationCode)
-		http.Error(w, "Invalid verification code", http.StatusUnauthorized)
+
+	if bcrypt.CompareHashAndPassword([]byte(code.CodeHash), []byte(user.VerificationCode)) != nil {
+		slog.WarnContext(r.Context(), "Verification code mismatch", "email", user.Email, "attempt", code.Attempts+1, "max_attempts", maxVerificationAttempts)
+		err = h.store.RecordVerificationAttempt(r.Context(), user.Email)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error recording verification attempt", "email", user.Email, "error", err)
+		}
+		h.recordVerificationFailure(r.Context(), ipKey)
+		apierror.Write(w, r, http.StatusUnauthorized, apierror.InvalidCode, "Invalid verification code")
 		return
 	}
-	
+
 	// Hash the password
-	log.Printf("Hashing password for user: %s", user.Email)
+	slog.DebugContext(r.Context(), "Hashing password", "email", user.Email)
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
-		log.Printf("Error hashing password for email %s: %v", user.Email, err)
-		http.Error(w, "Password hashing failed", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error hashing password", "email", user.Email, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Password hashing failed")
 		return
 	}
-	
-	// Update user data in the database
-	log.Printf("Updating user data for email: %s", user.Email)
-	_, err = db.Exec(`UPDATE User SET password = ? WHERE email = ?`,
-		string(hashedPassword), user.Email)
+
+	// Set the password and queue the profile in one transaction, so a failure
+	// creating the profile is retried rather than leaving a half-registered account
+	slog.InfoContext(r.Context(), "Updating user data", "email", user.Email)
+	userID, err := h.store.SaveRegistration(r.Context(), user.Email, string(hashedPassword), ProfilePayload{
+		Email:       user.Email,
+		Name:        user.Name,
+		PhoneNumber: user.PhoneNumber,
+		Address:     user.Address,
+		Age:         fmt.Sprintf("%d", user.Age), // Convert age to string
+	})
 	if err != nil {
-		log.Printf("Error updating user data for email %s: %v", user.Email, err)
-		http.Error(w, "Failed to update user data", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error saving registration", "email", user.Email, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Database error")
 		return
 	}
-	
-	log.Printf("User data updated successfully for email: %s", user.Email)
-	
-	// Call the user microservice
-	log.Printf("Calling user microservice for email: %s", user.Email)
-	err = callUserMicroservice(user)
+
+	// Try to create the profile now; if the user microservice is unavailable the outbox worker retries
+	slog.InfoContext(r.Context(), "Creating profile in user microservice", "email", user.Email)
+	registered, err := h.CompleteRegistration(r.Context(), userID)
 	if err != nil {
-		log.Printf("Error calling user microservice for email %s: %v", user.Email, err)
-		http.Error(w, "Microservice error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error completing registration", "email", user.Email, "error", err)
+	}
+	if !registered {
+		slog.InfoContext(r.Context(), "Registration accepted, profile creation pending", "email", user.Email)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"message": "Registration received, your account will be ready shortly"}`))
 		return
 	}
-	
-	log.Printf("User registered successfully for email: %s", user.Email)
+
+	slog.InfoContext(r.Context(), "User registered", "email", user.Email)
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(`{"message": "User registered successfully"}`))
 }
 
-// callUserMicroservice sends a request to userMicroservice to create a new user record
-func callUserMicroservice(user struct {
-	Email           string `json:"email"`
-	VerificationCode string `json:"verification_code"`
-	Name            string `json:"name"`
-	Password        string `json:"password"`
-	PhoneNumber     string `json:"phone_number"`
-	Address         string `json:"address"`
-	Age             uint8   `json:"age"`
-}) error {
-	userMicroserviceURL := "http://18.143.103.158:5100/api/v1/user/create"
-	payload := map[string]interface{}{
-		"email":            user.Email,
-		"verification_code": user.VerificationCode,
-		"name":             user.Name,
-		"password":         user.Password,
-		"phone_number":     user.PhoneNumber,
-		"address":          user.Address,
-		"age":              fmt.Sprintf("%d", user.Age), // Convert age to string
+// recordVerificationFailure counts a wrong or unknown verification code against the client address
+func (h *Handler) recordVerificationFailure(ctx context.Context, ipKey string) {
+	if err := h.throttle.RecordFailure(ctx, ipKey, throttle.IPPolicy); err != nil {
+		slog.ErrorContext(ctx, "Error recording verification failure", "error", err)
 	}
-
-	payloadBytes, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("Failed to marshal payload: %v", err)
-	}
-
-	resp, err := http.Post(userMicroserviceURL, "application/json", bytes.NewBuffer(payloadBytes))
-	if err != nil {
-		return fmt.Errorf("Failed to send request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusCreated {
-		return fmt.Errorf("Unexpected status code: %d", resp.StatusCode)
-	}
-
-	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,59 @@
+package registration
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned by a store when the requested row does not exist
+var ErrNotFound = errors.New("not found")
+
+// Store is the verification codes, registrations and profile outbox the registration handlers read and write.
+// MySQLStore keeps them in the authentication database, and the memstore package keeps them in memory for handler tests.
+type Store interface {
+	// VerificationCodeAge returns how long ago the email's pending verification code was sent,
+	// and false if it has none
+	VerificationCodeAge(ctx context.Context, email string) (time.Duration, bool, error)
+	// SaveVerificationCode replaces the email's verification code and its attempts, adding the email if it is new
+	SaveVerificationCode(ctx context.Context, email, codeHash string) error
+	// VerificationCode returns the email's pending verification code, or ErrNotFound if it has none
+	VerificationCode(ctx context.Context, email string) (VerificationCode, error)
+	// RecordVerificationAttempt counts a wrong guess against the email's verification code
+	RecordVerificationAttempt(ctx context.Context, email string) error
+
+	// SaveRegistration sets the email's password, clears its verification code and queues the profile
+	// in one transaction, filling in the profile's UserID. It returns the user ID.
+	SaveRegistration(ctx context.Context, email, passwordHash string, profile ProfilePayload) (int, error)
+	// RegistrationStatus returns the registration status of a user
+	RegistrationStatus(ctx context.Context, userID int) (string, error)
+
+	// PendingOutboxEntry returns the ID of the user's pending outbox entry, or ErrNotFound if there is none
+	PendingOutboxEntry(ctx context.Context, userID int) (int, error)
+	// DueOutboxEntries returns the IDs of up to limit pending entries whose next attempt is due, earliest first
+	DueOutboxEntries(ctx context.Context, limit int) ([]int, error)
+	// ClaimOutboxEntry hides a pending entry from other claims for the lease, reporting whether it was claimed.
+	// Unless force is set, only an entry whose next attempt is due can be claimed.
+	ClaimOutboxEntry(ctx context.Context, outboxID int, lease time.Duration, force bool) (bool, error)
+	// OutboxEntry returns an entry's payload and failed attempts
+	OutboxEntry(ctx context.Context, outboxID int) (OutboxEntry, error)
+	// RetryOutboxEntry records a failed attempt and schedules the next one after the backoff
+	RetryOutboxEntry(ctx context.Context, outboxID, attempts int, backoff time.Duration, lastError string) error
+	// CompleteOutboxEntry closes the entry and marks the user registered
+	CompleteOutboxEntry(ctx context.Context, outboxID, userID int) error
+	// CompensateOutboxEntry closes the entry and returns a user whose profile is still pending to verification
+	CompensateOutboxEntry(ctx context.Context, outboxID, userID int, reason string) error
+}
+
+// VerificationCode is an email's pending verification code
+type VerificationCode struct {
+	CodeHash string
+	Attempts int
+	Age      time.Duration // How long ago the code was sent
+}
+
+// OutboxEntry is a queued profile, with the JSON ProfilePayload it was queued with
+type OutboxEntry struct {
+	Payload  string
+	Attempts int
+}
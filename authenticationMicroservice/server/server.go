@@ -0,0 +1,215 @@
+// Package server routes the authentication microservice's endpoints, so main and the integration
+// harness serve the same handlers behind the same middleware
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"authenticationMicroservice/authentication"
+	"authenticationMicroservice/config"
+	"authenticationMicroservice/registration"
+
+	"shared/apierror"
+	sharedconfig "shared/config"
+	"shared/logging"
+	"shared/observability"
+	"shared/tracing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/handlers"
+	"github.com/gorilla/mux"
+)
+
+// adminSessions reports whether an admin's token is still live. The authentication microservice
+// removes an admin's tokens when they are deactivated or reset.
+type adminSessions interface {
+	AdminTokenActive(ctx context.Context, token string) (bool, error)
+}
+
+// authenticator checks tokens for the routes, asking sessions about admin tokens on every request
+type authenticator struct {
+	sessions adminSessions
+}
+
+// JWT Authentication Middleware with Role Check for multiple roles
+func (a authenticator) authenticateMiddleware(allowedRoles []string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Get the Authorization header
+			authHeader := r.Header.Get("Authorization")
+			if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+				return
+			}
+
+			// Extract token
+			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+
+			// Get the JWT secret from the configuration
+			secretKey := config.Current().JWTSecret
+			if secretKey == "" {
+				slog.ErrorContext(r.Context(), "JWT_SECRET is not set")
+				apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+				return
+			}
+
+			// Parse and validate the token
+			token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+					return nil, http.ErrAbortHandler
+				}
+				return []byte(secretKey), nil
+			})
+
+			if err != nil || !token.Valid {
+				slog.WarnContext(r.Context(), "Invalid JWT token", "error", err)
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+				return
+			}
+
+			// Extract the claims from the token
+			claims, ok := token.Claims.(jwt.MapClaims)
+			if !ok {
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+				return
+			}
+
+			// Check if the role matches any of the allowed roles
+			role, ok := claims["role"].(string)
+			if !ok {
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+				return
+			}
+
+			// Check if the user's role is in the allowed roles
+			roleAllowed := false
+			for _, allowedRole := range allowedRoles {
+				if role == allowedRole {
+					roleAllowed = true
+					break
+				}
+			}
+
+			if !roleAllowed {
+				apierror.Write(w, r, http.StatusForbidden, apierror.Forbidden, "Forbidden")
+				return
+			}
+
+			// Admin tokens stop working as soon as they are revoked, rather than when they expire
+			if role == "Admin" {
+				active, err := a.sessions.AdminTokenActive(r.Context(), tokenString)
+				if err != nil {
+					slog.ErrorContext(r.Context(), "Error checking admin token", "error", err)
+					apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+					return
+				}
+				if !active {
+					slog.WarnContext(r.Context(), "Admin token revoked")
+					apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+					return
+				}
+			}
+
+			// Proceed to the next handler if role matches, keeping the claims for permission checks
+			ctx := context.WithValue(r.Context(), authentication.ClaimsContextKey, claims)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Permission Middleware for admin tokens, must run after authenticateMiddleware.
+// Senior tokens carry no permissions and are only gated by their role.
+func requirePermission(permission string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := r.Context().Value(authentication.ClaimsContextKey).(jwt.MapClaims)
+			if !ok {
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+				return
+			}
+
+			if role, _ := claims["role"].(string); role != "Admin" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Check if the admin's role grants the permission
+			permissions, _ := claims["permissions"].([]interface{})
+			for _, granted := range permissions {
+				if granted == permission {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			slog.WarnContext(r.Context(), "Admin token lacks permission", "permission", permission)
+			apierror.Write(w, r, http.StatusForbidden, apierror.Forbidden, "Forbidden")
+		})
+	}
+}
+
+// NewRouter returns the authentication endpoints of Routes, with CORS for the frontend and request logging
+func NewRouter(h *authentication.Handler, registrations *registration.Handler, health *observability.Health) http.Handler {
+	// Add CORS support, inside the middleware that tags every request with an ID and logs it
+	return logging.Middleware(handlers.CORS(
+		handlers.AllowedOrigins(sharedconfig.AllowedOrigins()),
+		handlers.AllowedMethods([]string{"GET", "POST", "OPTIONS"}),        // Add allowed HTTP methods
+		handlers.AllowedHeaders([]string{"Content-Type", "Authorization"}), // Authorization is needed for two-factor enrolment
+		handlers.ExposedHeaders([]string{logging.RequestIDHeader}),         // Let the frontend report request IDs
+	)(Routes(h, registrations, health)))
+}
+
+// Routes returns the authentication endpoints behind their authentication middleware, alongside the probe and
+// metrics endpoints of health. Every /api/v1 route is described in the service's OpenAPI spec, in the
+// api package, which the integration harness checks against these routes.
+func Routes(h *authentication.Handler, registrations *registration.Handler, health *observability.Health) *mux.Router {
+	// Initialize the router, tracing and timing every request by its route
+	router := mux.NewRouter()
+	router.Use(tracing.Middleware(sharedconfig.AuthService), observability.Middleware)
+
+	// Unknown routes and methods get the same JSON errors as the endpoints
+	router.NotFoundHandler = apierror.NotFoundHandler()
+	router.MethodNotAllowedHandler = apierror.MethodNotAllowedHandler()
+
+	// Liveness, readiness and metrics, served without authentication
+	health.RegisterRoutes(router)
+
+	// Tokens are checked here, with admin tokens looked up in the store on every request
+	authenticateMiddleware := authenticator{sessions: h}.authenticateMiddleware
+
+	// Registration endpoints
+	router.HandleFunc("/api/v1/authentication/send-verification", registrations.SendVerificationCode).Methods("POST")
+	router.HandleFunc("/api/v1/authentication/register-user", registrations.RegisterUser).Methods("POST")
+
+	// Authentication endpoint
+	router.HandleFunc("/api/v1/authentication/user/login", h.AuthenticateUser).Methods("POST")
+	router.HandleFunc("/api/v1/authentication/user/login-methods", authentication.GetLoginMethods).Methods("GET")
+	router.HandleFunc("/api/v1/authentication/user/login/request-code", h.RequestLoginCode).Methods("POST")
+	router.HandleFunc("/api/v1/authentication/user/login/verify-code", h.VerifyLoginCode).Methods("POST")
+	router.HandleFunc("/api/v1/authentication/user/login/magic-link", h.VerifyMagicLink).Methods("POST")
+	router.HandleFunc("/api/v1/authentication/admin/login", h.AuthenticateAdmin).Methods("POST")
+	router.HandleFunc("/api/v1/authentication/admin/login/mfa", h.AuthenticateAdminMFA).Methods("POST")
+	router.HandleFunc("/api/v1/authentication/admin/accept-invite", h.AcceptAdminInvite).Methods("POST")
+
+	// Caregiver endpoints, registration requires an invitation from a senior
+	router.HandleFunc("/api/v1/authentication/caregiver/register", h.RegisterCaregiver).Methods("POST")
+	router.HandleFunc("/api/v1/authentication/caregiver/login", h.AuthenticateCaregiver).Methods("POST")
+
+	// Admin credential management, called by the admin microservice on behalf of a super admin
+	authenticated := router.NewRoute().Subrouter()
+	authenticated.Handle("/api/v1/authentication/admin/invite", authenticateMiddleware([]string{"Admin"})(requirePermission("admins:manage")(http.HandlerFunc(h.InviteAdminCredentials)))).Methods("POST")
+	authenticated.Handle("/api/v1/authentication/admin/reset", authenticateMiddleware([]string{"Admin"})(requirePermission("admins:manage")(http.HandlerFunc(h.ResetAdminCredentials)))).Methods("POST")
+	authenticated.Handle("/api/v1/authentication/admin/deactivate", authenticateMiddleware([]string{"Admin"})(requirePermission("admins:manage")(http.HandlerFunc(h.DeactivateAdminCredentials)))).Methods("POST")
+	authenticated.Handle("/api/v1/authentication/admin/checkToken", authenticateMiddleware([]string{"Service"})(http.HandlerFunc(h.CheckAdminToken))).Methods("POST") // Called by every microservice that accepts admin tokens
+	authenticated.Handle("/api/v1/authentication/admin/2fa/reset", authenticateMiddleware([]string{"Admin"})(requirePermission("admins:manage")(http.HandlerFunc(h.ResetAdminMFA)))).Methods("POST")
+
+	// Two-factor enrolment for the calling admin
+	authenticated.Handle("/api/v1/authentication/admin/2fa/enroll", authenticateMiddleware([]string{"Admin"})(http.HandlerFunc(h.EnrollAdminMFA))).Methods("POST")
+	authenticated.Handle("/api/v1/authentication/admin/2fa/verify", authenticateMiddleware([]string{"Admin"})(http.HandlerFunc(h.VerifyAdminMFAEnrollment))).Methods("POST")
+	authenticated.Handle("/api/v1/authentication/admin/2fa/recovery-codes", authenticateMiddleware([]string{"Admin"})(http.HandlerFunc(h.RegenerateRecoveryCodes))).Methods("POST")
+
+	return router
+}
@@ -0,0 +1,248 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"authenticationMicroservice/authentication"
+	"authenticationMicroservice/authentication/memstore"
+	"authenticationMicroservice/client/user"
+	"authenticationMicroservice/config"
+	"authenticationMicroservice/registration"
+	"authenticationMicroservice/throttle"
+
+	"shared/observability"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// testService serves the authentication routes from an in-memory store
+type testService struct {
+	store     *memstore.Store
+	directory *memstore.Directory
+	mail      *memstore.MailRecorder
+	routes    http.Handler
+}
+
+func newTestService(t *testing.T) *testService {
+	t.Helper()
+	config.Current().JWTSecret = "test-secret"
+	config.Current().LoginMethods = []string{authentication.LoginMethodPassword, authentication.LoginMethodCode}
+
+	s := &testService{store: memstore.New(), directory: &memstore.Directory{}, mail: &memstore.MailRecorder{}}
+	loginThrottle := throttle.New(s.store, nil)
+	registrations := registration.NewHandler(s.store, registration.Clients{User: s.directory, Mail: s.mail}, loginThrottle)
+	handler := authentication.NewHandler(s.store, authentication.Clients{User: s.directory, Admin: s.directory, Mail: s.mail}, loginThrottle, registrations, config.Current().JWTSecret)
+	s.routes = Routes(handler, registrations, observability.NewHealth())
+	return s
+}
+
+// serve sends a request to the routes, with a token for claims unless claims is nil
+func (s *testService) serve(t *testing.T, method, path string, claims jwt.MapClaims, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if claims != nil {
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(config.Current().JWTSecret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.routes.ServeHTTP(w, r)
+	return w
+}
+
+// login logs a senior in with their password
+func (s *testService) login(t *testing.T, email, password string) *httptest.ResponseRecorder {
+	t.Helper()
+	return s.serve(t, "POST", "/api/v1/authentication/user/login", nil, fmt.Sprintf(`{"email":%q,"password":%q}`, email, password))
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	s := newTestService(t)
+
+	if w := s.serve(t, "POST", "/api/v1/authentication/send-verification", nil, `{"email":"ahkow@example.com"}`); w.Code != http.StatusOK {
+		t.Fatalf("send-verification = %d %s", w.Code, w.Body)
+	}
+	emails := s.mail.Emails()
+	if len(emails) != 1 || emails[0].To != "ahkow@example.com" || emails[0].Code == "" {
+		t.Fatalf("emails = %+v, want a verification code for ahkow@example.com", emails)
+	}
+
+	register := func(code string) *httptest.ResponseRecorder {
+		return s.serve(t, "POST", "/api/v1/authentication/register-user", nil,
+			fmt.Sprintf(`{"email":"ahkow@example.com","verification_code":%q,"name":"Tan Ah Kow","password":"password123","age":72}`, code))
+	}
+	if w := register("000000" + emails[0].Code); w.Code != http.StatusUnauthorized {
+		t.Errorf("wrong code: status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if w := register(emails[0].Code); w.Code != http.StatusOK {
+		t.Fatalf("register-user = %d %s", w.Code, w.Body)
+	}
+	if profiles := s.directory.Profiles(); len(profiles) != 1 || profiles[0].Email != "ahkow@example.com" {
+		t.Errorf("profiles = %+v, want the senior's profile created", profiles)
+	}
+
+	w := s.login(t, "ahkow@example.com", "password123")
+	var response authentication.LoginResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("login = %d %s", w.Code, w.Body)
+	}
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(response.Token, claims, func(*jwt.Token) (interface{}, error) { return []byte(config.Current().JWTSecret), nil }); err != nil {
+		t.Fatal(err)
+	}
+	if claims["role"] != "User" || claims["email"] != "ahkow@example.com" {
+		t.Errorf("token claims = %v, want a senior token for ahkow@example.com", claims)
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	s := newTestService(t)
+	userID := s.store.AddUser("ahkow@example.com", "password123")
+	s.directory.AddUser(user.User{UserID: userID, Name: "Tan Ah Kow", Email: "ahkow@example.com"})
+
+	for i := 0; i < throttle.AccountPolicy.MaxFailures; i++ {
+		if w := s.login(t, "ahkow@example.com", "wrong"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("failure %d: status = %d, want %d", i+1, w.Code, http.StatusUnauthorized)
+		}
+	}
+
+	// The account is locked even for the right password, with the wait in Retry-After
+	if w := s.login(t, "ahkow@example.com", "wrong"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("last failure: status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	w := s.login(t, "ahkow@example.com", "password123")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("locked out: status = %d with Retry-After %q, want %d with a wait", w.Code, w.Header().Get("Retry-After"), http.StatusTooManyRequests)
+	}
+
+	// Other accounts are unaffected
+	otherID := s.store.AddUser("mei@example.com", "password456")
+	s.directory.AddUser(user.User{UserID: otherID, Name: "Lim Mei", Email: "mei@example.com"})
+	if w := s.login(t, "mei@example.com", "password456"); w.Code != http.StatusOK {
+		t.Errorf("other account: status = %d %s, want %d", w.Code, w.Body, http.StatusOK)
+	}
+}
+
+func TestSendVerificationLockout(t *testing.T) {
+	s := newTestService(t)
+
+	// Codes to different emails from one client address count towards its lockout
+	sent := 0
+	for ; sent <= throttle.IPPolicy.MaxFailures+1; sent++ {
+		w := s.serve(t, "POST", "/api/v1/authentication/send-verification", nil, fmt.Sprintf(`{"email":"senior%d@example.com"}`, sent))
+		if w.Code == http.StatusTooManyRequests {
+			if w.Header().Get("Retry-After") == "" {
+				t.Error("lockout without Retry-After")
+			}
+			break
+		}
+		if w.Code != http.StatusOK {
+			t.Fatalf("code %d: status = %d %s, want %d", sent+1, w.Code, w.Body, http.StatusOK)
+		}
+	}
+	if want := throttle.IPPolicy.MaxFailures + 1; sent != want {
+		t.Errorf("client address locked after %d codes, want %d", sent, want)
+	}
+	if emails := s.mail.Emails(); len(emails) != sent {
+		t.Errorf("%d emails sent, want %d", len(emails), sent)
+	}
+}
+
+func TestRequestLoginCodeAnswersTheSame(t *testing.T) {
+	s := newTestService(t)
+	userID := s.store.AddUser("ahkow@example.com", "password123")
+	s.directory.AddUser(user.User{UserID: userID, Name: "Tan Ah Kow", Email: "ahkow@example.com"})
+
+	// An account whose profile cannot be created yet stays pending
+	ctx := context.Background()
+	s.directory.ProfileErr = errors.New("user microservice unavailable")
+	if err := s.store.SaveVerificationCode(ctx, "pending@example.com", "code-hash"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.store.SaveRegistration(ctx, "pending@example.com", "hash", registration.ProfilePayload{Email: "pending@example.com", Name: "Lim Mei"}); err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(t *testing.T, email string) *httptest.ResponseRecorder {
+		t.Helper()
+		return s.serve(t, "POST", "/api/v1/authentication/user/login/request-code", nil, fmt.Sprintf(`{"email":%q}`, email))
+	}
+	unknown := request(t, "nobody@example.com")
+	if unknown.Code != http.StatusOK {
+		t.Fatalf("unknown email: status = %d %s, want %d", unknown.Code, unknown.Body, http.StatusOK)
+	}
+
+	tests := []struct {
+		name  string
+		email string
+	}{
+		{"registered", "ahkow@example.com"},
+		{"registered within the cooldown", "ahkow@example.com"},
+		{"still being set up", "pending@example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := request(t, tt.email)
+			if w.Code != unknown.Code || w.Body.String() != unknown.Body.String() || w.Header().Get("Retry-After") != "" {
+				t.Errorf("response = %d %s (Retry-After %q), want the unknown email's %d %s", w.Code, w.Body, w.Header().Get("Retry-After"), unknown.Code, unknown.Body)
+			}
+		})
+	}
+
+	// Only the first request for the registered senior was emailed
+	if emails := s.mail.Emails(); len(emails) != 1 || emails[0].To != "ahkow@example.com" || emails[0].Code == "" {
+		t.Errorf("emails = %+v, want one login code for ahkow@example.com", emails)
+	}
+}
+
+func TestCheckAdminToken(t *testing.T) {
+	s := newTestService(t)
+	s.store.AddAdmin(1, "admin@example.com", "password123")
+	s.store.SaveAdminToken(context.Background(), 1, "live-token", time.Now().Add(time.Hour))
+
+	check := func(t *testing.T, claims jwt.MapClaims, token string) *httptest.ResponseRecorder {
+		t.Helper()
+		return s.serve(t, "POST", "/api/v1/authentication/admin/checkToken", claims, fmt.Sprintf(`{"token":%q}`, token))
+	}
+	active := func(t *testing.T, token string) bool {
+		t.Helper()
+		w := check(t, jwt.MapClaims{"role": "Service"}, token)
+		var session struct {
+			Active bool `json:"active"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &session); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("checkToken = %d %s", w.Code, w.Body)
+		}
+		return session.Active
+	}
+
+	if !active(t, "live-token") {
+		t.Error("stored token reported inactive")
+	}
+	if active(t, "other-token") {
+		t.Error("unknown token reported active")
+	}
+	s.store.RevokeAdminTokens(context.Background(), 1)
+	if active(t, "live-token") {
+		t.Error("revoked token reported active")
+	}
+
+	// Only other microservices ask
+	if w := check(t, nil, "live-token"); w.Code != http.StatusUnauthorized {
+		t.Errorf("no token: status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if w := check(t, jwt.MapClaims{"user_id": 7, "role": "User"}, "live-token"); w.Code != http.StatusForbidden {
+		t.Errorf("senior token: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
@@ -0,0 +1,85 @@
+package throttle
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// MySQLStore is the Store kept in the LoginThrottle table
+type MySQLStore struct {
+	db *sql.DB
+}
+
+// NewMySQLStore returns a store reading and writing the given database
+func NewMySQLStore(db *sql.DB) *MySQLStore {
+	return &MySQLStore{db: db}
+}
+
+func (s *MySQLStore) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	// Compare against the database clock so replicas agree on when a lockout ends
+	var remainingSeconds sql.NullInt64
+	err := s.db.QueryRowContext(ctx, `
+		SELECT TIMESTAMPDIFF(SECOND, NOW(), locked_until)
+		FROM LoginThrottle
+		WHERE throttle_key = ?`, key).Scan(&remainingSeconds)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to read throttle state: %v", err)
+	}
+
+	if !remainingSeconds.Valid || remainingSeconds.Int64 <= 0 {
+		return 0, nil
+	}
+	return time.Duration(remainingSeconds.Int64) * time.Second, nil
+}
+
+func (s *MySQLStore) RecordFailure(ctx context.Context, key string, window time.Duration, lockout func(failures int) time.Duration) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start throttle transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var failures int
+	var secondsSinceLastFailure sql.NullInt64
+	err = tx.QueryRowContext(ctx, `
+		SELECT failed_attempts, TIMESTAMPDIFF(SECOND, last_failed_at, NOW())
+		FROM LoginThrottle
+		WHERE throttle_key = ?
+		FOR UPDATE`, key).Scan(&failures, &secondsSinceLastFailure)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to read throttle state: %v", err)
+	}
+
+	// Forget failures that fall outside the window
+	if secondsSinceLastFailure.Valid && time.Duration(secondsSinceLastFailure.Int64)*time.Second > window {
+		failures = 0
+	}
+	failures++
+
+	// locked_until stays NULL until the failures earn a lockout
+	lockoutSeconds := int64(lockout(failures) / time.Second)
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO LoginThrottle (throttle_key, failed_attempts, locked_until, last_failed_at)
+		VALUES (?, ?, CASE WHEN ? > 0 THEN NOW() + INTERVAL ? SECOND END, NOW())
+		ON DUPLICATE KEY UPDATE
+		failed_attempts = VALUES(failed_attempts), locked_until = VALUES(locked_until), last_failed_at = VALUES(last_failed_at)`,
+		key, failures, lockoutSeconds, lockoutSeconds)
+	if err != nil {
+		return fmt.Errorf("failed to record throttle failure: %v", err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *MySQLStore) Reset(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM LoginThrottle WHERE throttle_key = ?`, key)
+	if err != nil {
+		return fmt.Errorf("failed to reset throttle state: %v", err)
+	}
+	return nil
+}
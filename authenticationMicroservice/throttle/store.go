@@ -0,0 +1,18 @@
+package throttle
+
+import (
+	"context"
+	"time"
+)
+
+// Store keeps the failure count and lockout of every throttle key.
+// MySQLStore keeps them in the authentication database, and the memstore package keeps them in memory for handler tests.
+type Store interface {
+	// LockedFor returns how long the key is still locked out, or zero if it is not locked
+	LockedFor(ctx context.Context, key string) (time.Duration, error)
+	// RecordFailure counts a failure against the key, forgetting earlier failures older than window,
+	// and locks the key for the duration lockout returns for the new failure count (none if zero)
+	RecordFailure(ctx context.Context, key string, window time.Duration, lockout func(failures int) time.Duration) error
+	// Reset clears the failure history of the key
+	Reset(ctx context.Context, key string) error
+}
@@ -0,0 +1,190 @@
+package throttle
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"shared/apierror"
+)
+
+// Throttle locks out keys that fail too often, keeping their failure history in its store
+type Throttle struct {
+	store          Store
+	trustedProxies []*net.IPNet
+}
+
+// New returns a throttle keeping its state in store. X-Forwarded-For is only read from requests
+// sent by trustedProxies, such as the gateway, see ParseProxies.
+func New(store Store, trustedProxies []*net.IPNet) *Throttle {
+	return &Throttle{store: store, trustedProxies: trustedProxies}
+}
+
+// ParseProxies parses the TRUSTED_PROXIES setting, a list of addresses and CIDR ranges
+func ParseProxies(proxies []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q", proxy)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// Policy describes how many failures a key may accumulate before it is locked,
+// and how the lockout grows with every failure after that.
+type Policy struct {
+	MaxFailures int           // Failures allowed before the first lockout
+	BaseLockout time.Duration // Lockout applied on the first failure past MaxFailures
+	MaxLockout  time.Duration // Upper bound for the exponential lockout
+	Window      time.Duration // Failures older than this are forgotten
+}
+
+// AccountPolicy applies to a single email address, per login type
+var AccountPolicy = Policy{
+	MaxFailures: 5,
+	BaseLockout: 1 * time.Minute,
+	MaxLockout:  24 * time.Hour,
+	Window:      24 * time.Hour,
+}
+
+// IPPolicy applies to a single client address across all accounts
+var IPPolicy = Policy{
+	MaxFailures: 20,
+	BaseLockout: 1 * time.Minute,
+	MaxLockout:  24 * time.Hour,
+	Window:      1 * time.Hour,
+}
+
+// AccountKey builds the throttle key for an email address under a given login type
+func AccountKey(kind, email string) string {
+	return fmt.Sprintf("account:%s:%s", kind, strings.ToLower(strings.TrimSpace(email)))
+}
+
+// IPKey builds the throttle key for a client address under a given login type
+func IPKey(kind, ip string) string {
+	return fmt.Sprintf("ip:%s:%s", kind, ip)
+}
+
+// ClientIP returns the originating address of the request. A client can send any
+// X-Forwarded-For it likes, so the header is only read when the request came from a trusted
+// proxy, and then from the right: each trusted proxy appends the address it was reached from,
+// and the first entry no trusted proxy could have added is the client.
+func (t *Throttle) ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !t.trusted(host) {
+		return host
+	}
+
+	var forwarded []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		forwarded = append(forwarded, strings.Split(header, ",")...)
+	}
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		entry := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(entry) == nil {
+			// Anything before an entry that is not an address cannot be relied on
+			break
+		}
+		host = entry
+		if !t.trusted(entry) {
+			break
+		}
+	}
+	return host
+}
+
+// trusted reports whether an address belongs to one of the trusted proxies
+func (t *Throttle) trusted(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, network := range t.trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// LockedFor returns how long the given key is still locked out, or zero if it is not locked
+func (t *Throttle) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	return t.store.LockedFor(ctx, key)
+}
+
+// Check returns the longest remaining lockout across all of the given keys
+func (t *Throttle) Check(ctx context.Context, keys ...string) (time.Duration, error) {
+	var longest time.Duration
+	for _, key := range keys {
+		remaining, err := t.LockedFor(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		if remaining > longest {
+			longest = remaining
+		}
+	}
+	return longest, nil
+}
+
+// RecordFailure counts a failed attempt against the key and applies an
+// exponential lockout once the policy's failure allowance is used up
+func (t *Throttle) RecordFailure(ctx context.Context, key string, policy Policy) error {
+	return t.store.RecordFailure(ctx, key, policy.Window, func(failures int) time.Duration {
+		if failures <= policy.MaxFailures {
+			return 0
+		}
+		lockout := lockoutFor(failures-policy.MaxFailures, policy)
+		slog.WarnContext(ctx, "Throttle key locked", "key", key, "lockout", lockout.String(), "failures", failures)
+		return lockout
+	})
+}
+
+// Reset clears the failure history of the given keys after a successful attempt
+func (t *Throttle) Reset(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		if err := t.store.Reset(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lockoutFor doubles the base lockout for every failure past the allowance, capped at the maximum
+func lockoutFor(excess int, policy Policy) time.Duration {
+	lockout := float64(policy.BaseLockout) * math.Pow(2, float64(excess-1))
+	if lockout > float64(policy.MaxLockout) {
+		return policy.MaxLockout
+	}
+	return time.Duration(lockout)
+}
+
+// RejectLocked writes a 429 response advertising when the caller may try again
+func RejectLocked(w http.ResponseWriter, r *http.Request, remaining time.Duration) {
+	retryAfter := int(math.Ceil(remaining.Seconds()))
+	w.Header().Set("Retry-After", fmt.Sprintf("%d", retryAfter))
+	apierror.Write(w, r, http.StatusTooManyRequests, apierror.RateLimited, "Too many attempts, please try again later")
+}
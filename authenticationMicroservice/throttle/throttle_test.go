@@ -0,0 +1,63 @@
+package throttle
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	proxies, err := ParseProxies([]string{"10.0.0.0/8", "192.168.1.5", "fd00::/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	throttle := New(nil, proxies)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{"direct client", "203.0.113.7:5123", nil, "203.0.113.7"},
+		{"client spoofing the header", "203.0.113.7:5123", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"through the gateway", "10.1.2.3:40000", []string{"203.0.113.7"}, "203.0.113.7"},
+		{"through a proxy by address", "192.168.1.5:40000", []string{"203.0.113.7"}, "203.0.113.7"},
+		{"spoofed entry before the client", "10.1.2.3:40000", []string{"198.51.100.1, 203.0.113.7"}, "203.0.113.7"},
+		{"through two proxies", "10.1.2.3:40000", []string{"198.51.100.1, 203.0.113.7, 10.9.9.9"}, "203.0.113.7"},
+		{"headers sent twice", "10.1.2.3:40000", []string{"198.51.100.1", "203.0.113.7"}, "203.0.113.7"},
+		{"garbage before the client", "10.1.2.3:40000", []string{"not-an-address, 203.0.113.7"}, "203.0.113.7"},
+		{"garbage from the proxy", "10.1.2.3:40000", []string{"not-an-address"}, "10.1.2.3"},
+		{"only proxies", "10.1.2.3:40000", []string{"10.4.5.6"}, "10.4.5.6"},
+		{"proxy without the header", "10.1.2.3:40000", nil, "10.1.2.3"},
+		{"IPv6 proxy", "[fd00::1]:40000", []string{"2001:db8::7"}, "2001:db8::7"},
+		{"untrusted address next to a proxy", "192.168.1.6:40000", []string{"198.51.100.1"}, "192.168.1.6"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/api/v1/authentication/login", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if got := throttle.ClientIP(r); got != tt.want {
+				t.Errorf("ClientIP = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// Without trusted proxies the header is never read
+	r := httptest.NewRequest("POST", "/api/v1/authentication/login", nil)
+	r.RemoteAddr = "10.1.2.3:40000"
+	r.Header.Set("X-Forwarded-For", "203.0.113.7")
+	if got := New(nil, nil).ClientIP(r); got != "10.1.2.3" {
+		t.Errorf("ClientIP with no trusted proxies = %s, want 10.1.2.3", got)
+	}
+}
+
+func TestParseProxies(t *testing.T) {
+	for _, invalid := range []string{"gateway", "10.0.0.0/33", "10.0.0.256"} {
+		if _, err := ParseProxies([]string{invalid}); err == nil {
+			t.Errorf("ParseProxies(%q) accepted it", invalid)
+		}
+	}
+}
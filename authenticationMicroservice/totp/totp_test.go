@@ -0,0 +1,158 @@
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key of the RFC 6238 test vectors, "12345678901234567890", in base32
+var rfcSecret = secretEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestRFC6238Vectors(t *testing.T) {
+	// RFC 6238 appendix B lists 8-digit codes for SHA-1; the 6-digit codes are their last 6 digits
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, v := range vectors {
+		now := time.Unix(v.unix, 0)
+		want := v.code[len(v.code)-Digits:]
+		got, err := Code(rfcSecret, Step(now))
+		if err != nil {
+			t.Fatalf("Code at %d returned %v", v.unix, err)
+		}
+		if got != want {
+			t.Errorf("Code at %d = %s, want %s", v.unix, got, want)
+		}
+		if step, ok := Validate(rfcSecret, want, now); !ok || step != Step(now) {
+			t.Errorf("Validate(%s) at %d = %d, %v, want step %d", want, v.unix, step, ok, Step(now))
+		}
+	}
+
+	// Secrets are accepted in lower case, as some apps show them
+	if got, err := Code(strings.ToLower(rfcSecret), 1); err != nil || got != "287082" {
+		t.Errorf("Code with a lower-case secret = %s, %v, want 287082", got, err)
+	}
+}
+
+func TestCodeWindow(t *testing.T) {
+	now := time.Unix(1111111111, 0) // 1 second into its step
+	step := Step(now)
+	code, _ := Code(rfcSecret, step)
+
+	// A code is good for the whole of its 30-second step
+	start := time.Unix(step*int64(Period.Seconds()), 0)
+	for _, at := range []time.Time{start, start.Add(Period - time.Second)} {
+		if matched, ok := Validate(rfcSecret, code, at); !ok || matched != step {
+			t.Errorf("code rejected at %v, within its step", at)
+		}
+	}
+	if Step(start.Add(Period)) != step+1 || Step(start.Add(-time.Second)) != step-1 {
+		t.Errorf("steps do not change every %v", Period)
+	}
+}
+
+func TestSkew(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	current := Step(now)
+
+	// One step either side of now is accepted, for clocks that drift
+	for offset := int64(-Skew); offset <= Skew; offset++ {
+		code, _ := Code(rfcSecret, current+offset)
+		if step, ok := Validate(rfcSecret, code, now); !ok || step != current+offset {
+			t.Errorf("code %d steps from now = %d, %v, want step %d", offset, step, ok, current+offset)
+		}
+	}
+
+	// Two steps away is too far
+	for _, offset := range []int64{-Skew - 1, Skew + 1} {
+		code, _ := Code(rfcSecret, current+offset)
+		if _, ok := Validate(rfcSecret, code, now); ok {
+			t.Errorf("code %d steps from now was accepted", offset)
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	now := time.Unix(2000000000, 0)
+	current := Step(now)
+	code, _ := Code(rfcSecret, current)
+
+	// The first use is fresh; the same code again matches the same step, which is no longer fresh
+	step, ok := Validate(rfcSecret, code, now)
+	if !ok || !Fresh(step, nil) {
+		t.Fatalf("first use rejected")
+	}
+	last := step
+	if replayed, ok := Validate(rfcSecret, code, now.Add(10*time.Second)); !ok || Fresh(replayed, &last) {
+		t.Errorf("replayed code accepted")
+	}
+
+	// An older code still within the skew is refused once a newer one was used
+	previous, _ := Code(rfcSecret, current-1)
+	if older, ok := Validate(rfcSecret, previous, now); !ok || Fresh(older, &last) {
+		t.Errorf("code from before the last one used accepted")
+	}
+
+	// The next step's code is fresh
+	next, _ := Code(rfcSecret, current+1)
+	if newer, ok := Validate(rfcSecret, next, now.Add(Period)); !ok || !Fresh(newer, &last) {
+		t.Errorf("code from after the last one used rejected")
+	}
+}
+
+func TestValidateRejects(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	code, _ := Code(rfcSecret, Step(now))
+
+	// Spaces typed into the code are ignored
+	if _, ok := Validate(rfcSecret, " "+code[:3]+" "+code[3:]+" ", now); !ok {
+		t.Errorf("code with spaces rejected")
+	}
+
+	wrong := []string{"", code[:Digits-1], code + "0", "000000", "abcdef"}
+	for _, candidate := range wrong {
+		if candidate == code {
+			continue
+		}
+		if _, ok := Validate(rfcSecret, candidate, now); ok {
+			t.Errorf("Validate(%q) accepted", candidate)
+		}
+	}
+	if _, ok := Validate("not base32!", code, now); ok {
+		t.Errorf("code accepted for an invalid secret")
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil || len(raw) != 20 {
+		t.Errorf("secret %q decodes to %d bytes, %v, want 20", secret, len(raw), err)
+	}
+	if other, _ := GenerateSecret(); other == secret {
+		t.Errorf("two secrets are the same")
+	}
+
+	uri, err := url.Parse(ProvisioningURI("FallSafe", "admin@example.com", secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := uri.Query()
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || query.Get("secret") != secret || query.Get("digits") != "6" || query.Get("period") != "30" {
+		t.Errorf("provisioning URI = %s", uri)
+	}
+}
@@ -0,0 +1,485 @@
+-- **************************************************
+-- Demo data for local development and demonstrations
+-- Run once after initialiseDatabase.sql, when every service has started
+-- and applied its migrations. Reference data (the FES questions and the
+-- self-assessment tests) is added by the migrations, not here.
+-- **************************************************
+
+-- **************************************************
+-- DATABASE: FallSafe_AuthenticationDB
+-- Add dummy data
+-- **************************************************
+USE FallSafe_AuthenticationDB;
+
+-- Insert dummy data into the User table
+INSERT INTO User (email, password, verification_code)
+VALUES
+    -- Ages 60–69
+    ('jeffreyleetino@gmail.com', '$2a$10$.kXKDW80biUED2npeuui7uZf3wgj0uyVOzC/7XshKWJbtH/jzQnpi', '234567'),
+    ('ben.lim@example.com', '$2a$10$.kXKDW80biUED2npeuui7uZf3wgj0uyVOzC/7XshKWJbtH/jzQnpi', '345678'),
+    ('clara.ng@example.com', '$2a$10$.kXKDW80biUED2npeuui7uZf3wgj0uyVOzC/7XshKWJbtH/jzQnpi', '456789'),
+    ('daniel.teo@example.com', '$2a$10$.kXKDW80biUED2npeuui7uZf3wgj0uyVOzC/7XshKWJbtH/jzQnpi', '567890'),
+    ('eve.wong@example.com', '$2a$10$.kXKDW80biUED2npeuui7uZf3wgj0uyVOzC/7XshKWJbtH/jzQnpi', '678901'),
+
+    -- Ages 70–79
+    ('frank.lee@example.com', '$2a$10$.kXKDW80biUED2npeuui7uZf3wgj0uyVOzC/7XshKWJbtH/jzQnpi', '789012'),
+    ('grace.ho@example.com', '$2a$10$.kXKDW80biUED2npeuui7uZf3wgj0uyVOzC/7XshKWJbtH/jzQnpi', '890123'),
+    ('hank.low@example.com', '$2a$10$.kXKDW80biUED2npeuui7uZf3wgj0uyVOzC/7XshKWJbtH/jzQnpi', '901234'),
+    ('irene.tan@example.com', '$2a$10$.kXKDW80biUED2npeuui7uZf3wgj0uyVOzC/7XshKWJbtH/jzQnpi', '012345'),
+    ('jackie.goh@example.com', '$2a$10$.kXKDW80biUED2npeuui7uZf3wgj0uyVOzC/7XshKWJbtH/jzQnpi', '123457'),
+    ('karen.tay@example.com', '$2a$10$.kXKDW80biUED2npeuui7uZf3wgj0uyVOzC/7XshKWJbtH/jzQnpi', '234568'),
+
+    -- Ages 80–95
+    ('leo.chua@example.com', '$2a$10$.kXKDW80biUED2npeuui7uZf3wgj0uyVOzC/7XshKWJbtH/jzQnpi', '345679'),
+    ('mia.chen@example.com', '$2a$10$.kXKDW80biUED2npeuui7uZf3wgj0uyVOzC/7XshKWJbtH/jzQnpi', '456780'),
+    ('nathan.chew@example.com', '$2a$10$.kXKDW80biUED2npeuui7uZf3wgj0uyVOzC/7XshKWJbtH/jzQnpi', '567891'),
+    ('olivia.koh@example.com', '$2a$10$.kXKDW80biUED2npeuui7uZf3wgj0uyVOzC/7XshKWJbtH/jzQnpi', '678902'),
+    ('paul.loo@example.com', '$2a$10$.kXKDW80biUED2npeuui7uZf3wgj0uyVOzC/7XshKWJbtH/jzQnpi', '789013');
+    
+
+-- Insert dummy data into the Admin table
+INSERT INTO Admin (email, password, verification_code)
+VALUES
+    ('admin1@example.com', '$2a$10$.kXKDW80biUED2npeuui7uZf3wgj0uyVOzC/7XshKWJbtH/jzQnpi', '111222');
+
+-- Insert dummy data into the UserAuthentication table
+INSERT INTO UserAuthentication (user_id, auth_token, token_expiry)
+VALUES
+    (1, 'authTokenUser1', '2025-01-16 10:00:00');
+
+-- Insert dummy data into the AdminAuthentication table
+INSERT INTO AdminAuthentication (admin_id, auth_token, token_expiry)
+VALUES
+    (1, 'authTokenAdmin1', '2025-01-16 10:30:00');
+
+
+-- **************************************************
+-- DATABASE: FallSafe_UserDB
+-- Add dummy data
+-- **************************************************
+USE FallSafe_UserDB;
+
+-- Insert dummy data into the User table
+INSERT INTO User (name, email, age, address, phone_number) VALUES
+-- Ages 60–69 (5 users)
+('Jeffrey Lee', 'jeffreyleetino@gmail.com', 61, '11 Maple Lane', '9012345601'),
+('Ben Lim', 'ben.lim@example.com', 65, '22 Oak Avenue', '9123456702'),
+('Clara Ng', 'clara.ng@example.com', 68, '33 Pine Street', '9234567803'),
+('Daniel Teo', 'daniel.teo@example.com', 63, '44 Birch Road', '9345678904'),
+('Eve Wong', 'eve.wong@example.com', 66, '55 Elm Crescent', '9456789005'),
+
+-- Ages 70–79 (6 users)
+('Frank Lee', 'frank.lee@example.com', 71, '66 Cedar Drive', '9567890106'),
+('Grace Ho', 'grace.ho@example.com', 74, '77 Aspen Way', '9678901207'),
+('Hank Low', 'hank.low@example.com', 75, '88 Fir Circle', '9789012308'),
+('Irene Tan', 'irene.tan@example.com', 78, '99 Palm Boulevard', '9890123409'),
+('Jackie Goh', 'jackie.goh@example.com', 70, '101 Redwood Plaza', '9901234500'),
+('Karen Tay', 'karen.tay@example.com', 73, '112 Poplar Lane', '9012345600'),
+
+-- Ages 80–95 (5 users)
+('Leo Chua', 'leo.chua@example.com', 81, '123 Willow Terrace', '9123456701'),
+('Mia Chen', 'mia.chen@example.com', 85, '134 Cypress Avenue', '9234567802'),
+('Nathan Chew', 'nathan.chew@example.com', 89, '145 Spruce Path', '9345678903'),
+('Olivia Koh', 'olivia.koh@example.com', 92, '156 Alder Court', '9456789004'),
+('Paul Loo', 'paul.loo@example.com', 94, '167 Magnolia Lane', '9567890105');
+
+-- **************************************************
+-- DATABASE: FallSafe_FallSafeDB
+-- Add dummy data
+-- **************************************************
+USE FallSafe_FallSafeDB;
+
+-- Insert dummy data into the DeviceRequest table
+INSERT INTO DeviceRequest (user_id, request_date, delivery_status) VALUES
+(1, '2025-01-10 09:00:00', 'Pending');
+
+-- **************************************************
+-- DATABASE: FallSafe_FallsEfficacyScaleDB
+-- Add dummy data
+-- **************************************************
+USE FallSafe_FallsEfficacyScaleDB;
+
+-- Insert dummy data into UserResponse table
+INSERT INTO UserResponse (user_id, total_score, response_date) VALUES
+-- (6 months ago)
+(1, 25, DATE_SUB(DATE_SUB(CURRENT_DATE, INTERVAL 6 MONTH), INTERVAL 7 DAY)),
+(2, 28, DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH)),
+(3, 35, DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH)),
+(4, 30, DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH)),
+(5, 25, DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH)),
+(6, 40, DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH)),
+(7, 45, DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH)),
+(8, 42, DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH)),
+(9, 46, DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH)),
+(10, 48, DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH)),
+(11, 55, DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH)),
+(12, 58, DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH)),
+(13, 60, DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH)),
+(14, 62, DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH)),
+(15, 63, DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH)),
+(16, 64, DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH)),
+
+-- (12 months ago)
+(1, 41, DATE_SUB(DATE_SUB(CURRENT_DATE, INTERVAL 12 MONTH), INTERVAL 7 DAY)),
+(2, 28, DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH)),
+(3, 36, DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH)),
+(4, 37, DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH)),
+(5, 30, DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH)),
+(6, 46, DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH)),
+(7, 43, DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH)),
+(8, 44, DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH)),
+(9, 53, DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH)),
+(10, 48, DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH)),
+(11, 58, DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH)),
+(12, 60, DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH)),
+(13, 56, DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH)),
+(14, 62, DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH)),
+(15, 62, DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH)),
+(16, 64, DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH)),
+
+
+-- (18 months ago)
+(1, 48, DATE_SUB(DATE_SUB(CURRENT_DATE, INTERVAL 18 MONTH), INTERVAL 7 DAY)),
+
+
+-- (24 months ago)
+(1, 53, DATE_SUB(DATE_SUB(CURRENT_DATE, INTERVAL 24 MONTH), INTERVAL 7 DAY));
+
+-- Insert dummy data into UserResponseDetails table
+INSERT INTO UserResponseDetails (response_id, question_id, response_score) VALUES
+-- User 1
+(1, 1, 2), (1, 2, 2), (1, 3, 2), (1, 4, 2), (1, 5, 2), (1, 6, 2),
+(1, 7, 1), (1, 8, 1), (1, 9, 1), (1, 10, 2), (1, 11, 1), (1, 12, 1),
+(1, 13, 1), (1, 14, 1), (1, 15, 2), (1, 16, 1),
+-- User 2
+(2, 1, 1), (2, 2, 1), (2, 3, 1), (2, 4, 2), (2, 5, 1), (2, 6, 1),
+(2, 7, 2), (2, 8, 1), (2, 9, 1), (2, 10, 2), (2, 11, 1), (2, 12, 1),
+(2, 13, 2), (2, 14, 1), (2, 15, 1), (2, 16, 2),
+-- User 3
+(3, 1, 3), (3, 2, 2), (3, 3, 2), (3, 4, 3), (3, 5, 2), (3, 6, 2),
+(3, 7, 3), (3, 8, 2), (3, 9, 2), (3, 10, 3), (3, 11, 2), (3, 12, 2),
+(3, 13, 3), (3, 14, 2), (3, 15, 1), (3, 16, 3),
+-- User 4
+(4, 1, 2), (4, 2, 1), (4, 3, 2), (4, 4, 3), (4, 5, 1), (4, 6, 2),
+(4, 7, 3), (4, 8, 2), (4, 9, 2), (4, 10, 3), (4, 11, 2), (4, 12, 2),
+(4, 13, 3), (4, 14, 2), (4, 15, 1), (4, 16, 3),
+-- User 5
+(5, 1, 1), (5, 2, 1), (5, 3, 2), (5, 4, 3), (5, 5, 1), (5, 6, 1),
+(5, 7, 2), (5, 8, 1), (5, 9, 1), (5, 10, 2), (5, 11, 1), (5, 12, 1),
+(5, 13, 2), (5, 14, 1), (5, 15, 1), (5, 16, 2),
+-- User 6
+(6, 1, 3), (6, 2, 3), (6, 3, 3), (6, 4, 3), (6, 5, 2), (6, 6, 2),
+(6, 7, 3), (6, 8, 2), (6, 9, 2), (6, 10, 3), (6, 11, 3), (6, 12, 3),
+(6, 13, 3), (6, 14, 2), (6, 15, 2), (6, 16, 3),
+-- User 7
+(7, 1, 3), (7, 2, 3), (7, 3, 3), (7, 4, 3), (7, 5, 3), (7, 6, 3),
+(7, 7, 3), (7, 8, 3), (7, 9, 3), (7, 10, 3), (7, 11, 3), (7, 12, 3),
+(7, 13, 3), (7, 14, 3), (7, 15, 3), (7, 16, 3),
+-- User 8
+(8, 1, 3), (8, 2, 2), (8, 3, 3), (8, 4, 3), (8, 5, 2), (8, 6, 2),
+(8, 7, 3), (8, 8, 3), (8, 9, 2), (8, 10, 3), (8, 11, 2), (8, 12, 2),
+(8, 13, 3), (8, 14, 3), (8, 15, 2), (8, 16, 3),
+-- User 9
+(9, 1, 3), (9, 2, 2), (9, 3, 3), (9, 4, 3), (9, 5, 3), (9, 6, 3),
+(9, 7, 3), (9, 8, 3), (9, 9, 3), (9, 10, 3), (9, 11, 3), (9, 12, 3),
+(9, 13, 3), (9, 14, 3), (9, 15, 3), (9, 16, 3),
+-- User 10
+(10, 1, 3), (10, 2, 3), (10, 3, 3), (10, 4, 3), (10, 5, 3), (10, 6, 3),
+(10, 7, 3), (10, 8, 3), (10, 9, 3), (10, 10, 3), (10, 11, 3), (10, 12, 3),
+(10, 13, 3), (10, 14, 3), (10, 15, 3), (10, 16, 3),
+-- User 11
+(11, 1, 4), (11, 2, 4), (11, 3, 4), (11, 4, 4), (11, 5, 3), (11, 6, 3),
+(11, 7, 4), (11, 8, 4), (11, 9, 3), (11, 10, 4), (11, 11, 3), (11, 12, 4),
+(11, 13, 4), (11, 14, 3), (11, 15, 3), (11, 16, 4),
+-- User 12
+(12, 1, 4), (12, 2, 4), (12, 3, 4), (12, 4, 4), (12, 5, 4), (12, 6, 4),
+(12, 7, 4), (12, 8, 4), (12, 9, 4), (12, 10, 4), (12, 11, 4), (12, 12, 4),
+(12, 13, 4), (12, 14, 4), (12, 15, 4), (12, 16, 4),
+-- User 13
+(13, 1, 4), (13, 2, 4), (13, 3, 3), (13, 4, 4), (13, 5, 4), (13, 6, 4),
+(13, 7, 4), (13, 8, 4), (13, 9, 4), (13, 10, 4), (13, 11, 3), (13, 12, 4),
+(13, 13, 4), (13, 14, 4), (13, 15, 4), (13, 16, 3),
+-- User 14
+(14, 1, 4), (14, 2, 3), (14, 3, 4), (14, 4, 4), (14, 5, 4), (14, 6, 4),
+(14, 7, 4), (14, 8, 4), (14, 9, 4), (14, 10, 4), (14, 11, 4), (14, 12, 4),
+(14, 13, 4), (14, 14, 3), (14, 15, 4), (14, 16, 4),
+-- User 15
+(15, 1, 4), (15, 2, 3), (15, 3, 4), (15, 4, 4), (15, 5, 4), (15, 6, 4),
+(15, 7, 4), (15, 8, 4), (15, 9, 4), (15, 10, 4), (15, 11, 4), (15, 12, 4),
+(15, 13, 4), (15, 14, 4), (15, 15, 4), (15, 16, 4),
+-- User 16
+(16, 1, 4), (16, 2, 4), (16, 3, 4), (16, 4, 4), (16, 5, 4), (16, 6, 4),
+(16, 7, 4), (16, 8, 4), (16, 9, 4), (16, 10, 4), (16, 11, 4), (16, 12, 4),
+(16, 13, 4), (16, 14, 4), (16, 15, 4), (16, 16, 4),
+-- February results
+
+-- User 1
+(17, 1, 2), (17, 2, 4), (17, 3, 2), (17, 4, 3), (17, 5, 4), (17, 6, 2),
+(17, 7, 2), (17, 8, 2), (17, 9, 3), (17, 10, 3), (17, 11, 3), (17, 12, 4),
+(17, 13, 1), (17, 14, 2), (17, 15, 1), (17, 16, 2),
+-- User 2
+(18, 1, 2), (18, 2, 1), (18, 3, 1), (18, 4, 2), (18, 5, 2), (18, 6, 1),
+(18, 7, 2), (18, 8, 2), (18, 9, 1), (18, 10, 2), (18, 11, 3), (18, 12, 1),
+(18, 13, 2), (18, 14, 3), (18, 15, 1), (18, 16, 2),
+-- User 3
+(19, 1, 1), (19, 2, 2), (19, 3, 3), (19, 4, 3), (19, 5, 2), (19, 6, 2),
+(19, 7, 3), (19, 8, 2), (19, 9, 3), (19, 10, 3), (19, 11, 2), (19, 12, 2),
+(19, 13, 3), (19, 14, 2), (19, 15, 1), (19, 16, 2),
+-- User 4
+(20, 1, 3), (20, 2, 1), (20, 3, 2), (20, 4, 3), (20, 5, 1), (20, 6, 2),
+(20, 7, 3), (20, 8, 1), (20, 9, 2), (20, 10, 3), (20, 11, 3), (20, 12, 2),
+(20, 13, 3), (20, 14, 2), (20, 15, 3), (20, 16, 3),
+-- User 5
+(21, 1, 2), (21, 2, 1), (21, 3, 3), (21, 4, 3), (21, 5, 1), (21, 6, 1),
+(21, 7, 2), (21, 8, 1), (21, 9, 2), (21, 10, 2), (21, 11, 4), (21, 12, 1),
+(21, 13, 3), (21, 14, 1), (21, 15, 2), (21, 16, 1),
+-- User 6
+(22, 1, 3), (22, 2, 3), (22, 3, 3), (22, 4, 3), (22, 5, 2), (22, 6, 2),
+(22, 7, 3), (22, 8, 3), (22, 9, 4), (22, 10, 3), (22, 11, 4), (22, 12, 3),
+(22, 13, 3), (22, 14, 2), (22, 15, 2), (22, 16, 3),
+-- User 7
+(23, 1, 3), (23, 2, 3), (23, 3, 3), (23, 4, 3), (23, 5, 2), (23, 6, 2),
+(23, 7, 3), (23, 8, 3), (23, 9, 3), (23, 10, 3), (23, 11, 3), (23, 12, 3),
+(23, 13, 3), (23, 14, 2), (23, 15, 2), (23, 16, 2),
+-- User 8
+(24, 1, 2), (24, 2, 2), (24, 3, 3), (24, 4, 1), (24, 5, 2), (24, 6, 3),
+(24, 7, 3), (24, 8, 3), (24, 9, 4), (24, 10, 3), (24, 11, 4), (24, 12, 3),
+(24, 13, 4), (24, 14, 3), (24, 15, 2), (24, 16, 2),
+-- User 9
+(25, 1, 3), (25, 2, 2), (25, 3, 3), (25, 4, 3), (25, 5, 3), (25, 6, 3),
+(25, 7, 4), (25, 8, 4), (25, 9, 4), (25, 10, 3), (25, 11, 4), (25, 12, 3),
+(25, 13, 4), (25, 14, 4), (25, 15, 3), (25, 16, 3),
+-- User 10
+(26, 1, 3), (26, 2, 3), (26, 3, 3), (26, 4, 2), (26, 5, 3), (26, 6, 3),
+(26, 7, 3), (26, 8, 3), (26, 9, 4), (26, 10, 3), (26, 11, 4), (26, 12, 3),
+(26, 13, 3), (26, 14, 3), (26, 15, 3), (26, 16, 2),
+-- User 11
+(27, 1, 4), (27, 2, 4), (27, 3, 4), (27, 4, 4), (27, 5, 3), (27, 6, 4),
+(27, 7, 4), (27, 8, 4), (27, 9, 3), (27, 10, 4), (27, 11, 3), (27, 12, 4),
+(27, 13, 4), (27, 14, 3), (27, 15, 3), (27, 16, 3),
+-- User 12
+(28, 1, 3), (28, 2, 4), (28, 3, 3), (28, 4, 4), (28, 5, 4), (28, 6, 4),
+(28, 7, 4), (28, 8, 4), (28, 9, 4), (28, 10, 4), (28, 11, 4), (28, 12, 3),
+(28, 13, 4), (28, 14, 4), (28, 15, 4), (28, 16, 3),
+-- User 13
+(29, 1, 3), (29, 2, 4), (29, 3, 4), (29, 4, 4), (29, 5, 4), (29, 6, 3),
+(29, 7, 4), (29, 8, 4), (29, 9, 3), (29, 10, 4), (29, 11, 3), (29, 12, 4),
+(29, 13, 3), (29, 14, 3), (29, 15, 3), (29, 16, 3),
+-- User 14
+(30, 1, 3), (30, 2, 3), (30, 3, 4), (30, 4, 4), (30, 5, 4), (30, 6, 4),
+(30, 7, 3), (30, 8, 4), (30, 9, 4), (30, 10, 4), (30, 11, 4), (30, 12, 4),
+(30, 13, 4), (30, 14, 4), (30, 15, 4), (30, 16, 4),
+-- User 15
+(31, 1, 4), (31, 2, 4), (31, 3, 3), (31, 4, 3), (31, 5, 4), (31, 6, 4),
+(31, 7, 4), (31, 8, 4), (31, 9, 4), (31, 10, 4), (31, 11, 4), (31, 12, 4),
+(31, 13, 4), (31, 14, 4), (31, 15, 4), (31, 16, 4),
+-- User 16
+(32, 1, 4), (32, 2, 4), (32, 3, 4), (32, 4, 4), (32, 5, 4), (32, 6, 4),
+(32, 7, 4), (32, 8, 4), (32, 9, 4), (32, 10, 4), (32, 11, 4), (32, 12, 4),
+(32, 13, 4), (32, 14, 4), (32, 15, 4), (32, 16, 4),
+-- User 1, Response ID 33
+(33, 1, 3), (33, 2, 4), (33, 3, 3), (33, 4, 4), (33, 5, 2), (33, 6, 3),
+(33, 7, 4), (33, 8, 3), (33, 9, 4), (33, 10, 3), (33, 11, 4), (33, 12, 2),
+(33, 13, 2), (33, 14, 3), (33, 15, 2), (33, 16, 4),
+-- User 1, Response ID 34
+(34, 1, 4), (34, 2, 4), (34, 3, 3), (34, 4, 4), (34, 5, 3), (34, 6, 4),
+(34, 7, 4), (34, 8, 3), (34, 9, 4), (34, 10, 3), (34, 11, 4), (34, 12, 3),
+(34, 13, 3), (34, 14, 3), (34, 15, 2), (34, 16, 4);
+
+
+
+-- **************************************************
+-- DATABASE: FallSafe_SelfAssessmentDB
+-- Add dummy data
+-- **************************************************
+USE FallSafe_SelfAssessmentDB;
+
+-- Insert TestSession data for 5 users (5 sessions each, every 6 months)
+INSERT INTO TestSession (user_id, session_date, total_score, session_notes) VALUES
+(1, DATE_SUB(DATE_SUB(CURRENT_DATE, INTERVAL 6 MONTH), INTERVAL 7 DAY), '88', 'Routine assessment 6 months, 7 days ago'),
+(1, DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH), '57', 'Routine assessment 1 year ago'),
+(1, DATE_ADD(CURRENT_DATE, INTERVAL -18 MONTH), '40', 'Routine assessment 1.5 years ago'),
+(1, DATE_ADD(CURRENT_DATE, INTERVAL -24 MONTH), '35', 'Routine assessment 2 years ago'),
+(2, DATE_ADD(CURRENT_DATE, INTERVAL -30 MONTH), '27', 'Routine assessment 2.5 years ago'),
+
+(2, DATE_ADD(CURRENT_DATE, INTERVAL -30 MONTH), '80', 'Routine assessment 2.5 years ago'),
+(2, DATE_ADD(CURRENT_DATE, INTERVAL -24 MONTH), '84', 'Routine assessment 2 years ago'),
+(2, DATE_ADD(CURRENT_DATE, INTERVAL -18 MONTH), '87', 'Routine assessment 1.5 years ago'),
+(2, DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH), '93', 'Routine assessment 1 year ago'),
+(2, DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH), '98', 'Routine assessment 6 months ago'),
+
+(3, DATE_ADD(CURRENT_DATE, INTERVAL -30 MONTH), '79', 'Routine assessment 2.5 years ago'),
+(3, DATE_ADD(CURRENT_DATE, INTERVAL -24 MONTH), '70', 'Routine assessment 2 years ago'),
+(3, DATE_ADD(CURRENT_DATE, INTERVAL -18 MONTH), '63', 'Routine assessment 1.5 years ago'),
+(3, DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH), '53', 'Routine assessment 1 year ago'),
+(3, DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH), '42', 'Routine assessment 6 months ago'),
+
+(4, DATE_ADD(CURRENT_DATE, INTERVAL -30 MONTH), '51', 'Routine assessment 2.5 years ago'),
+(4, DATE_ADD(CURRENT_DATE, INTERVAL -24 MONTH), '59', 'Routine assessment 2 years ago'),
+(4, DATE_ADD(CURRENT_DATE, INTERVAL -18 MONTH), '66', 'Routine assessment 1.5 years ago'),
+(4, DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH), '75', 'Routine assessment 1 year ago'),
+(4, DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH), '86', 'Routine assessment 6 months ago'),
+
+(5, DATE_ADD(CURRENT_DATE, INTERVAL -30 MONTH), '70', 'Routine assessment 2.5 years ago'),
+(5, DATE_ADD(CURRENT_DATE, INTERVAL -24 MONTH), '57', 'Routine assessment 2 years ago'),
+(5, DATE_ADD(CURRENT_DATE, INTERVAL -18 MONTH), '79', 'Routine assessment 1.5 years ago'),
+(5, DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH), '63', 'Routine assessment 1 year ago'),
+(5, DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH), '67', 'Routine assessment 6 months ago');
+
+
+
+
+-- Insert UserTestResult data for all users
+
+-- User 1: Gradual improvement in performance
+INSERT INTO UserTestResult (user_id, session_id, test_id, time_taken, abrupt_percentage, risk_level, test_date) VALUES
+
+(1, 5, 1, 15.000, 10, 'low', DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH)),
+(1, 5, 2, 28.500, 20, 'low', DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH)),
+(1, 5, 3, 28.000, 5, 'low', DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH)),
+(1, 5, 4, 42.000, 10, 'low', DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH)),
+
+(1, 4, 1, 20.000, 40, 'low', DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH)),
+(1, 4, 2, 36.000, 40, 'low', DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH)),
+(1, 4, 3, 35.000, 60, 'low', DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH)),
+(1, 4, 4, 22.500, 40, 'low', DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH)),
+
+(1, 3, 1, 20.000, 20, 'low', DATE_ADD(CURRENT_DATE, INTERVAL -18 MONTH)),
+(1, 3, 2, 17.000, 30, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -18 MONTH)),
+(1, 3, 3, 12.000, 10, 'low', DATE_ADD(CURRENT_DATE, INTERVAL -18 MONTH)),
+(1, 3, 4, 25.000, 40, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -18 MONTH)),
+
+(1, 2, 1, 23.000, 25, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -24 MONTH)),
+(1, 2, 2, 18.500, 35, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -24 MONTH)),
+(1, 2, 3, 13.000, 15, 'low', DATE_ADD(CURRENT_DATE, INTERVAL -24 MONTH)),
+(1, 2, 4, 27.500, 45, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -24 MONTH)),
+
+(1, 1, 1, 25.500, 30, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -30 MONTH)), 
+(1, 1, 2, 20.000, 40, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -30 MONTH)), 
+(1, 1, 3, 15.000, 20, 'low', DATE_ADD(CURRENT_DATE, INTERVAL -30 MONTH)),   
+(1, 1, 4, 30.000, 50, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -30 MONTH));
+
+
+-- User 2: Gradual decline in performance
+INSERT INTO UserTestResult (user_id, session_id, test_id, time_taken, abrupt_percentage, risk_level, test_date) VALUES
+
+(2, 10, 1, 10.000, 10, 'high', DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH)),
+(2, 10, 2, 18.000, 15, 'high', DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH)),
+(2, 10, 3, 10.000, 15, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH)),
+(2, 10, 4, 43.000, 10, 'high', DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH)),
+
+(2, 9, 1, 28.000, 50, 'high', DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH)),
+(2, 9, 2, 25.000, 55, 'high', DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH)),
+(2, 9, 3, 18.000, 30, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH)),
+(2, 9, 4, 35.000, 60, 'high', DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH)),
+
+(2, 8, 1, 25.000, 40, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -18 MONTH)),
+(2, 8, 2, 22.000, 45, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -18 MONTH)),
+(2, 8, 3, 16.000, 25, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -18 MONTH)),
+(2, 8, 4, 30.000, 55, 'high', DATE_ADD(CURRENT_DATE, INTERVAL -18 MONTH)),
+
+(2, 7, 1, 22.000, 30, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -24 MONTH)),
+(2, 7, 2, 20.000, 35, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -24 MONTH)),
+(2, 7, 3, 14.000, 20, 'low', DATE_ADD(CURRENT_DATE, INTERVAL -24 MONTH)),
+(2, 7, 4, 28.000, 50, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -24 MONTH)),
+
+(2, 6, 1, 20.000, 20, 'low', DATE_ADD(CURRENT_DATE, INTERVAL -30 MONTH)), -- Test 1
+(2, 6, 2, 18.000, 25, 'low', DATE_ADD(CURRENT_DATE, INTERVAL -30 MONTH)), -- Test 2
+(2, 6, 3, 12.000, 15, 'low', DATE_ADD(CURRENT_DATE, INTERVAL -30 MONTH)), -- Test 3 (Good performance)
+(2, 6, 4, 25.000, 40, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -30 MONTH));
+
+
+
+-- User 3: Strong improvement over time
+INSERT INTO UserTestResult (user_id, session_id, test_id, time_taken, abrupt_percentage, risk_level, test_date) VALUES
+
+(3, 15, 1, 50.000, 40, 'low', DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH)),
+(3, 15, 2, 40.000, 45, 'low', DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH)),
+(3, 15, 3, 55.000, 34, 'low', DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH)),
+(3, 15, 4, 20.000, 5, 'low', DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH)),
+
+(3, 14, 1, 18.000, 20, 'low', DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH)),
+(3, 14, 2, 16.000, 25, 'low', DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH)),
+(3, 14, 3, 10.000, 10, 'low', DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH)),
+(3, 14, 4, 25.000, 35, 'low', DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH)),
+
+(3, 13, 1, 22.000, 30, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -18 MONTH)),
+(3, 13, 2, 20.000, 35, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -18 MONTH)),
+(3, 13, 3, 12.000, 15, 'low', DATE_ADD(CURRENT_DATE, INTERVAL -18 MONTH)),
+(3, 13, 4, 28.000, 50, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -18 MONTH)),
+
+(3, 12, 1, 25.000, 40, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -24 MONTH)),
+(3, 12, 2, 22.000, 45, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -24 MONTH)),
+(3, 12, 3, 15.000, 25, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -24 MONTH)),
+(3, 12, 4, 30.000, 55, 'high', DATE_ADD(CURRENT_DATE, INTERVAL -24 MONTH)),
+
+(3, 11, 1, 28.000, 50, 'high', DATE_ADD(CURRENT_DATE, INTERVAL -30 MONTH)), -- Test 1
+(3, 11, 2, 25.000, 55, 'high', DATE_ADD(CURRENT_DATE, INTERVAL -30 MONTH)), -- Test 2
+(3, 11, 3, 18.000, 35, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -30 MONTH)), -- Test 3 (Good performance)
+(3, 11, 4, 32.000, 60, 'high', DATE_ADD(CURRENT_DATE, INTERVAL -30 MONTH));
+
+-- User 4: Gradual decline in performance
+INSERT INTO UserTestResult (user_id, session_id, test_id, time_taken, abrupt_percentage, risk_level, test_date) VALUES
+
+(4, 20, 1, 10.000, 60, 'high', DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH)),
+(4, 20, 2, 18.000, 65, 'high', DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH)),
+(4, 20, 3, 10.000, 30, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH)),
+(4, 20, 4, 40.000, 70, 'high', DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH)),
+
+(4, 19, 1, 25.000, 50, 'high', DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH)),
+(4, 19, 2, 23.000, 55, 'high', DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH)),
+(4, 19, 3, 18.000, 25, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH)),
+(4, 19, 4, 32.000, 60, 'high', DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH)),
+
+(4, 18, 1, 22.000, 35, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -18 MONTH)),
+(4, 18, 2, 20.000, 40, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -18 MONTH)),
+(4, 18, 3, 16.000, 20, 'low', DATE_ADD(CURRENT_DATE, INTERVAL -18 MONTH)),
+(4, 18, 4, 28.000, 45, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -18 MONTH)),
+
+(4, 17, 1, 20.000, 25, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -24 MONTH)),
+(4, 17, 2, 18.000, 30, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -24 MONTH)),
+(4, 17, 3, 14.000, 15, 'low', DATE_ADD(CURRENT_DATE, INTERVAL -24 MONTH)),
+(4, 17, 4, 25.000, 35, 'low', DATE_ADD(CURRENT_DATE, INTERVAL -24 MONTH)),
+
+(4, 16, 1, 18.000, 20, 'low', DATE_ADD(CURRENT_DATE, INTERVAL -30 MONTH)), -- Test 1
+(4, 16, 2, 15.000, 25, 'low', DATE_ADD(CURRENT_DATE, INTERVAL -30 MONTH)), -- Test 2
+(4, 16, 3, 12.000, 10, 'low', DATE_ADD(CURRENT_DATE, INTERVAL -30 MONTH)), -- Test 3 (Good performance)
+(4, 16, 4, 22.000, 30, 'low', DATE_ADD(CURRENT_DATE, INTERVAL -30 MONTH));
+
+-- User 5: Fluctuates over time
+INSERT INTO UserTestResult (user_id, session_id, test_id, time_taken, abrupt_percentage, risk_level, test_date) VALUES
+
+(5, 25, 1, 34.000, 20, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH)),
+(5, 25, 2, 30.000, 20, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH)),
+(5, 25, 3, 30.000, 25, 'low', DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH)),
+(5, 25, 4, 20.000, 10, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -6 MONTH)),
+
+(5, 24, 1, 22.000, 30, 'low', DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH)),
+(5, 24, 2, 30.000, 35, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH)),
+(5, 24, 3, 23.000, 20, 'low', DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH)),
+(5, 24, 4, 28.000, 45, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -12 MONTH)),
+
+(5, 23, 1, 28.000, 55, 'high', DATE_ADD(CURRENT_DATE, INTERVAL -18 MONTH)),
+(5, 23, 2, 25.000, 60, 'high', DATE_ADD(CURRENT_DATE, INTERVAL -18 MONTH)),
+(5, 23, 3, 18.000, 35, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -18 MONTH)),
+(5, 23, 4, 32.000, 65, 'high', DATE_ADD(CURRENT_DATE, INTERVAL -18 MONTH)),
+
+(5, 22, 1, 20.000, 30, 'low', DATE_ADD(CURRENT_DATE, INTERVAL -24 MONTH)),
+(5, 22, 2, 18.000, 35, 'low', DATE_ADD(CURRENT_DATE, INTERVAL -24 MONTH)),
+(5, 22, 3, 12.000, 15, 'low', DATE_ADD(CURRENT_DATE, INTERVAL -24 MONTH)),
+(5, 22, 4, 25.000, 40, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -24 MONTH)),
+
+(5, 21, 1, 25.000, 40, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -30 MONTH)), -- Test 1
+(5, 21, 2, 22.000, 45, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -30 MONTH)), -- Test 2
+(5, 21, 3, 15.000, 25, 'moderate', DATE_ADD(CURRENT_DATE, INTERVAL -30 MONTH)), -- Test 3 (Good performance)
+(5, 21, 4, 30.000, 55, 'high', DATE_ADD(CURRENT_DATE, INTERVAL -30 MONTH));
+
+-- **************************************************
+-- DATABASE: FallSafe_AdminDB
+-- Add dummy data
+-- **************************************************
+USE FallSafe_AdminDB;
+
+-- Insert dummy data into the User table
+INSERT INTO User (name, email, role, admin_role) VALUES
+('Eve Adams', 'admin1@example.com', 'Admin', 'SuperAdmin');
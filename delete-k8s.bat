@@ -12,6 +12,7 @@ kubectl delete -f k8s/fallsEfficacy-deployment.yaml --namespace=%NAMESPACE%
 kubectl delete -f k8s/openAI-deployment.yaml --namespace=%NAMESPACE%
 kubectl delete -f k8s/selfAssessment-deployment.yaml --namespace=%NAMESPACE%
 kubectl delete -f k8s/user-deployment.yaml --namespace=%NAMESPACE%
+kubectl delete -f k8s/gateway-deployment.yaml --namespace=%NAMESPACE%
 kubectl delete -f k8s/frontend-deployment.yaml --namespace=%NAMESPACE%
 timeout /t 3
 
@@ -49,6 +49,7 @@ kubectl apply -f k8s/fallsEfficacy-deployment.yaml --namespace=%NAMESPACE%
 kubectl apply -f k8s/openAI-deployment.yaml --namespace=%NAMESPACE%
 kubectl apply -f k8s/selfAssessment-deployment.yaml --namespace=%NAMESPACE%
 kubectl apply -f k8s/user-deployment.yaml --namespace=%NAMESPACE%
+kubectl apply -f k8s/gateway-deployment.yaml --namespace=%NAMESPACE%
 timeout /t 2
 
 :: Step 7: Deploy Frontend
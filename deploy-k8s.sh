@@ -61,6 +61,7 @@ kubectl apply -f k8s/fallsEfficacy-deployment.yaml --namespace="$NAMESPACE"
 kubectl apply -f k8s/openAI-deployment.yaml --namespace="$NAMESPACE"
 kubectl apply -f k8s/selfAssessment-deployment.yaml --namespace="$NAMESPACE"
 kubectl apply -f k8s/user-deployment.yaml --namespace="$NAMESPACE"
+kubectl apply -f k8s/gateway-deployment.yaml --namespace="$NAMESPACE"
 
 # Step 8: Deploy Frontend
 echo "🌐 Deploying Frontend..."
@@ -14,34 +14,25 @@ if %errorlevel% neq 0 (
 
 :: Build and tag Docker images one by one
 echo Building admin-microservice...
-cd adminMicroservice
-docker build --no-cache -t %DOCKER_USER%/admin-microservice:latest .
-cd ..
+docker build --no-cache -t %DOCKER_USER%/admin-microservice:latest -f adminMicroservice/Dockerfile .
 
 echo Building auth-microservice...
-cd authenticationMicroservice
-docker build --no-cache -t %DOCKER_USER%/auth-microservice:latest .
-cd ..
+docker build --no-cache -t %DOCKER_USER%/auth-microservice:latest -f authenticationMicroservice/Dockerfile .
 
 echo Building fallsEfficacyScale-microservice...
-cd fallsEfficacyScaleMicroservice
-docker build --no-cache -t %DOCKER_USER%/fallsefficacy-microservice:latest .
-cd ..
+docker build --no-cache -t %DOCKER_USER%/fallsefficacy-microservice:latest -f fallsEfficacyScaleMicroservice/Dockerfile .
 
 echo Building openAI-microservice...
-cd openAIMicroservice
-docker build --no-cache -t %DOCKER_USER%/openai-microservice:latest .
-cd ..
+docker build --no-cache -t %DOCKER_USER%/openai-microservice:latest -f openAIMicroservice/Dockerfile .
 
 echo Building selfAssessment-microservice...
-cd selfAssessmentMicroservice
-docker build --no-cache -t %DOCKER_USER%/selfassessment-microservice:latest .
-cd ..
+docker build --no-cache -t %DOCKER_USER%/selfassessment-microservice:latest -f selfAssessmentMicroservice/Dockerfile .
 
 echo Building user-microservice...
-cd userMicroservice
-docker build --no-cache -t %DOCKER_USER%/user-microservice:latest .
-cd ..
+docker build --no-cache -t %DOCKER_USER%/user-microservice:latest -f userMicroservice/Dockerfile .
+
+echo Building gateway-microservice...
+docker build --no-cache -t %DOCKER_USER%/gateway-microservice:latest -f gatewayMicroservice/Dockerfile .
 
 echo Building frontend...
 cd frontend
@@ -67,6 +58,9 @@ docker push %DOCKER_USER%/selfassessment-microservice:latest
 echo Pushing user-microservice...
 docker push %DOCKER_USER%/user-microservice:latest
 
+echo Pushing gateway-microservice...
+docker push %DOCKER_USER%/gateway-microservice:latest
+
 echo Pushing frontend...
 docker push %DOCKER_USER%/frontend:latest
 
@@ -1,50 +1,58 @@
 package FES
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
 	"strconv"
+	"sync"
 
 	//"fmt" -- temporary comment for testing
 	//"io/ioutil"
 	//"bytes"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
-	"github.com/joho/godotenv"
+	"fallsEfficacyScaleMicroservice/client/user"
+
+	"shared/apierror"
+	"shared/pagination"
+
+	"github.com/golang-jwt/jwt/v4"
 )
 
-var db *sql.DB
+// RiskRecorder sends saved scores to the combined risk model of the user microservice
+type RiskRecorder interface {
+	RecordRiskObservation(ctx context.Context, observation user.RiskObservation) error
+}
 
-func init() {
-	// Load environment variables
-	err := godotenv.Load(".env")
-	if err != nil {
-		log.Fatalf("Error loading .env file: %v", err)
-	}
+// Handler serves the FES endpoints from its store
+type Handler struct {
+	store        FESStore
+	risk         RiskRecorder
+	observations sync.WaitGroup // Risk observations sent after their response, waited for by Drain
+}
 
-	// Initialize database connection
-	dbConnection := os.Getenv("FES_DB_CONNECTION")
-	if dbConnection == "" {
-		log.Fatalf("FES_DB_CONNECTION environment variable is not set")
-	}
+// NewHandler returns the FES handlers, reading and writing store and sending new scores to risk
+func NewHandler(store FESStore, risk RiskRecorder) *Handler {
+	return &Handler{store: store, risk: risk}
+}
 
-	log.Println("Initializing database connection...")
-	db, err = sql.Open("mysql", dbConnection)
-	if err != nil {
-		log.Fatalf("Error connecting to database: %v", err)
-	}
+// contextKey is used to store values on the request context
+type contextKey string
 
-	// Test the database connection
-	err = db.Ping()
-	if err != nil {
-		log.Fatalf("Database connection test failed: %v", err)
+// ClaimsContextKey holds the validated JWT claims set by the authentication middleware
+const ClaimsContextKey contextKey = "claims"
+
+// claimedID returns the user_id claim of the caller's token
+func claimedID(r *http.Request) (int, bool) {
+	claims, ok := r.Context().Value(ClaimsContextKey).(jwt.MapClaims)
+	if !ok {
+		return 0, false
 	}
-	log.Println("Database connection successful.")
+	id, ok := claims["user_id"].(float64)
+	return int(id), ok
 }
 
 type Question struct {
@@ -52,32 +60,12 @@ type Question struct {
 	Text string `json:"text"`
 }
 
-func GetQuestions(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetQuestions(w http.ResponseWriter, r *http.Request) {
 	//fetch qns from db
-	rows, err := db.Query(`
-		SELECT question_id, question_text 
-		FROM FallsEfficacyScale
-	`)
+	questions, err := h.store.Questions(r.Context())
 	if err != nil {
-		log.Printf("Error fetching questions: %v", err)
-		http.Error(w, "Failed to fetch questions", http.StatusInternalServerError)
-		return
-	}
-	defer rows.Close()
-
-	var questions []Question
-	for rows.Next() {
-		var q Question
-		if err := rows.Scan(&q.ID, &q.Text); err != nil {
-			log.Printf("Error scanning question: %v", err)
-			http.Error(w, "Failed to parse questions", http.StatusInternalServerError)
-			return
-		}
-		questions = append(questions, q) // Append each question to the slice
-	}
-	if err := rows.Err(); err != nil {
-		log.Printf("Error iterating over rows: %v", err)
-		http.Error(w, "Failed to fetch questions", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error fetching questions", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Failed to fetch questions")
 		return
 	}
 
@@ -85,31 +73,27 @@ func GetQuestions(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	err = json.NewEncoder(w).Encode(questions)
 	if err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error encoding response", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
 		return
 	}
 }
 
-func SaveResponse(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) SaveResponse(w http.ResponseWriter, r *http.Request) {
 	//decode request body
 	var requestData struct {
-		UserID    int `json:"user_id"`
-		Responses []struct {
-			QuestionID int `json:"question_id"`
-			Score      int `json:"score"`
-		} `json:"responses"`
+		UserID    int      `json:"user_id"`
+		Responses []Answer `json:"responses"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
-		http.Error(w, "Invalid input", http.StatusBadRequest)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidBody, "Invalid input")
 		return
 	}
 
-	//begin a transaction
-	tx, err := db.Begin()
-	if err != nil {
-		log.Printf("Error starting transaction: %v", err)
-		http.Error(w, "Failed to save response", http.StatusInternalServerError)
+	//a senior's token is only good for saving their own responses
+	if tokenUserID, ok := claimedID(r); !ok || tokenUserID != requestData.UserID {
+		slog.WarnContext(r.Context(), "Senior token used for another senior", "user_id", requestData.UserID)
+		apierror.Write(w, r, http.StatusForbidden, apierror.Forbidden, "Forbidden")
 		return
 	}
 
@@ -119,183 +103,137 @@ func SaveResponse(w http.ResponseWriter, r *http.Request) {
 		totalScore += int(response.Score)
 	}
 
-	//insert data into UserResponse table
-	result, err := tx.Exec(`
-		INSERT INTO UserResponse (user_id, total_score)
-		VALUES (?, ?)`, requestData.UserID, totalScore)
+	//save the response and its answers in one transaction
+	responseID, err := h.store.SaveResponse(r.Context(), requestData.UserID, requestData.Responses)
 	if err != nil {
-		log.Printf("Error inserting into UserResponse: %v", err)
-		tx.Rollback()
-		http.Error(w, "Failed to save response", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error saving response", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Failed to save response")
 		return
 	}
 
-	//insert details into UserResponseDetails table
-	responseID, _ := result.LastInsertId()
-	for _, response := range requestData.Responses {
-		_, err := tx.Exec(`
-			INSERT INTO UserResponseDetails (response_id, question_id, response_score)
-			VALUES (?, ?, ?)`, responseID, response.QuestionID, response.Score)
-		if err != nil {
-			log.Printf("Error inserting into UserResponseDetails: %v", err)
-			tx.Rollback()
-			http.Error(w, "Failed to save response details", http.StatusInternalServerError)
-			return
-		}
-	}
-
-	//commit the transaction
-	err = tx.Commit()
-	if err != nil {
-		log.Printf("Error committing transaction: %v", err)
-		http.Error(w, "Failed to save response", http.StatusInternalServerError)
-		return
-	}
+	h.observations.Add(1)
+	go h.recordRiskObservation(context.WithoutCancel(r.Context()), requestData.UserID, responseID, totalScore)
 
 	//success message
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	_, err = w.Write([]byte(`{"message":"Response saved successfully"}`))
 	if err != nil {
-		log.Printf("Error writing success response: %v", err)
+		slog.ErrorContext(r.Context(), "Error writing success response", "error", err)
+	}
+}
+
+// recordRiskObservation sends a saved FES score to the combined risk model.
+// The response is already saved, so a failure is only logged. The context keeps the request ID but
+// not the request's cancellation, as this runs after the response is sent.
+func (h *Handler) recordRiskObservation(ctx context.Context, userID, responseID, totalScore int) {
+	defer h.observations.Done()
+	observation := user.RiskObservation{UserID: userID, Source: user.SourceFES, SourceID: responseID, Score: float64(totalScore)}
+	if err := h.risk.RecordRiskObservation(ctx, observation); err != nil {
+		slog.ErrorContext(ctx, "Error recording risk observation", "user_id", userID, "error", err)
+	}
+}
+
+// Drain waits for the risk observations still being sent after their responses, until the context
+// ends. Serve runs it once the requests in flight are done, so no new ones start.
+func (h *Handler) Drain(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		h.observations.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("risk observations were still being sent at shutdown: %w", ctx.Err())
 	}
 }
 
 // UserResponseDetail represents an individual response to a FallsEfficacyScale question
 type UserResponseDetail struct {
-    QuestionID    uint16 `json:"question_id"`
-    ResponseScore uint8  `json:"response_score"`
+	QuestionID    uint16 `json:"question_id"`
+	ResponseScore uint8  `json:"response_score"`
 }
 
 // UserResponse represents a Falls Efficacy Scale test result, including response details
 type UserResponse struct {
-    ResponseID      uint16               `json:"response_id"`
-    UserID         uint16               `json:"user_id"`
-    TotalScore     uint16               `json:"total_score"`
-    ResponseDate   time.Time            `json:"response_date"`
-    ResponseDetails []UserResponseDetail `json:"response_details"`
+	ResponseID      uint16               `json:"response_id"`
+	UserID          uint16               `json:"user_id"`
+	TotalScore      uint16               `json:"total_score"`
+	ResponseDate    time.Time            `json:"response_date"`
+	ResponseDetails []UserResponseDetail `json:"response_details,omitempty"` // Omitted from the list of every response
 }
 
+// responseListOptions are the sorts and filters of the list of user responses
+var responseListOptions = pagination.Options{
+	Sort: map[string]string{
+		"response_id":   "response_id",
+		"response_date": "response_date",
+		"total_score":   "total_score",
+		"user_id":       "user_id",
+	},
+	DefaultSort: "response_id",
+	IDColumn:    "response_id",
+	DateColumn:  "response_date",
+	UserColumn:  "user_id",
+}
 
-// Function for retrieving the whole list of User Responses
-func GetAllUserResponse(w http.ResponseWriter, r *http.Request) {
-	// Define a slice to store the list of user responses
-	var responseList []UserResponse
-
-	// Query to fetch all user responses
-	rows, err := db.Query(`
-		SELECT response_id, user_id, total_score, response_date
-		FROM UserResponse`)
+// Function for retrieving a page of the list of User Responses, see the pagination package for the query parameters
+func (h *Handler) GetAllUserResponse(w http.ResponseWriter, r *http.Request) {
+	page, err := pagination.Parse(r, responseListOptions)
 	if err != nil {
-		log.Printf("Error querying user responses: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidField, err.Error())
 		return
 	}
-	defer rows.Close()
-
-	// Iterate over the rows and scan the values into the responseList slice
-	for rows.Next() {
-		var response UserResponse
-		if err := rows.Scan(
-			&response.ResponseID,
-			&response.UserID,
-			&response.TotalScore,
-			&response.ResponseDate,
-		); err != nil {
-			log.Printf("Error scanning user response row: %v", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
-		responseList = append(responseList, response)
-	}
-
-	// Check if there was an error while iterating over the rows
-	if err := rows.Err(); err != nil {
-		log.Printf("Error iterating over rows: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+
+	// Fetch the page of user responses and the number matching the filters
+	responseList, total, err := h.store.Responses(r.Context(), page)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying user responses", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
 		return
 	}
 
-	// Respond with the list of user responses as JSON
+	// Respond with the page of user responses as JSON
+	page.WriteHeaders(w, total, len(responseList))
 	w.Header().Set("Content-Type", "application/json")
 	err = json.NewEncoder(w).Encode(responseList)
 	if err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error encoding response", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
 		return
 	}
 }
 
 // GetUserFESResults retrieves Falls Efficacy Scale test results for a given userID
-func GetUserFESResults(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetUserFESResults(w http.ResponseWriter, r *http.Request) {
 	// Extract userID from query parameters
 	userIDStr := r.URL.Query().Get("user_id")
 	if userIDStr == "" {
-		http.Error(w, "user_id is required", http.StatusBadRequest)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "user_id is required")
 		return
 	}
 
 	userID, err := strconv.Atoi(userIDStr)
 	if err != nil {
-		http.Error(w, "Invalid user_id", http.StatusBadRequest)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidField, "Invalid user_id")
 		return
 	}
 
-	// Query UserResponse table
-	rows, err := db.Query(
-		`SELECT response_id, user_id, total_score, response_date 
-		 FROM UserResponse  
-		 WHERE user_id = ?`, userID)
+	results, err := h.store.UserResponses(r.Context(), userID)
 	if err != nil {
-		log.Printf("Database query error: %v", err)
-		http.Error(w, "Failed to fetch user FES results", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Database query error", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Failed to fetch user FES results")
 		return
 	}
-	defer rows.Close()
-
-	var results []UserResponse
-
-	for rows.Next() {
-		var result UserResponse
-		if err := rows.Scan(&result.ResponseID, &result.UserID, &result.TotalScore, &result.ResponseDate); err != nil {
-			log.Printf("Error scanning row: %v", err)
-			http.Error(w, "Failed to parse user FES results", http.StatusInternalServerError)
-			return
-		}
-
-		// Fetch UserResponseDetails for this response_id
-		detailRows, err := db.Query(
-			`SELECT question_id, response_score 
-			 FROM UserResponseDetails 
-			 WHERE response_id = ?`, result.ResponseID)
-		if err != nil {
-			log.Printf("Database query error (UserResponseDetails): %v", err)
-			http.Error(w, "Failed to fetch response details", http.StatusInternalServerError)
-			return
-		}
-		defer detailRows.Close()
-
-		var details []UserResponseDetail
-		for detailRows.Next() {
-			var detail UserResponseDetail
-			if err := detailRows.Scan(&detail.QuestionID, &detail.ResponseScore); err != nil {
-				log.Printf("Error scanning detail row: %v", err)
-				http.Error(w, "Failed to parse response details", http.StatusInternalServerError)
-				return
-			}
-			details = append(details, detail)
-		}
-
-		result.ResponseDetails = details
-		results = append(results, result)
-	}
 
 	// Send response as JSON
 	w.Header().Set("Content-Type", "application/json")
 	err = json.NewEncoder(w).Encode(results)
 	if err != nil {
-		log.Printf("Error encoding JSON response: %v", err)
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error encoding JSON response", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Failed to encode response")
 		return
 	}
 }
@@ -307,242 +245,198 @@ type UserResponseDetails struct {
 	ResponseScore int `json:"response_score"` // User's response score
 }
 
-func GetAllFESIndividualRes(w http.ResponseWriter, r *http.Request) {
-	// Define a slice to store the list of individual responses
-	var responseDetailsList []UserResponseDetails
+// responseDetailListOptions are the sorts and filters of the list of individual responses.
+// The date and user come from the response each answer belongs to.
+var responseDetailListOptions = pagination.Options{
+	Sort: map[string]string{
+		"response_id":    "urd.response_id",
+		"question_id":    "urd.question_id",
+		"response_score": "urd.response_score",
+		"response_date":  "ur.response_date",
+	},
+	DefaultSort: "response_id",
+	IDColumn:    "urd.detail_id",
+	DateColumn:  "ur.response_date",
+	UserColumn:  "ur.user_id",
+}
 
-	// Query to fetch all individual response details
-	rows, err := db.Query(`
-		SELECT response_id, question_id, response_score
-		FROM UserResponseDetails`)
+// GetAllFESIndividualRes returns a page of the answers to every question of all responses,
+// see the pagination package for the query parameters
+func (h *Handler) GetAllFESIndividualRes(w http.ResponseWriter, r *http.Request) {
+	page, err := pagination.Parse(r, responseDetailListOptions)
 	if err != nil {
-		log.Printf("Error querying individual response details: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidField, err.Error())
 		return
 	}
-	defer rows.Close()
-
-	// Iterate over the rows and scan the values into the responseDetailsList slice
-	for rows.Next() {
-		var detail UserResponseDetails
-		if err := rows.Scan(
-			&detail.ResponseID,
-			&detail.QuestionID,
-			&detail.ResponseScore,
-		); err != nil {
-			log.Printf("Error scanning individual response detail row: %v", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
-		responseDetailsList = append(responseDetailsList, detail)
-	}
-
-	// Check if there was an error while iterating over the rows
-	if err := rows.Err(); err != nil {
-		log.Printf("Error iterating over rows: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+
+	// Fetch the page of individual response details and the number matching the filters
+	responseDetailsList, total, err := h.store.ResponseDetails(r.Context(), page)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying individual response details", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
 		return
 	}
 
-	// Respond with the list of individual response details as JSON
+	// Respond with the page of individual response details as JSON
+	page.WriteHeaders(w, total, len(responseDetailsList))
 	w.Header().Set("Content-Type", "application/json")
 	err = json.NewEncoder(w).Encode(responseDetailsList)
 	if err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error encoding response", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
 		return
 	}
 }
 
 // Define a struct to hold the response data
 type UserLastResDetails struct {
-	UserID           int    `json:"user_id"`
-	DaysSinceResponse int    `json:"days_since_last_fesres"`
+	UserID            int `json:"user_id"`
+	DaysSinceResponse int `json:"days_since_last_fesres"`
 }
-//Get the user_id with their days since last res
-func GetAllFESLatestResDate(w http.ResponseWriter, r *http.Request) {
+
+// Get the user_id with their days since last res
+func (h *Handler) GetAllFESLatestResDate(w http.ResponseWriter, r *http.Request) {
 	// Define a slice to store the list of user responses
-	var userResponseList []UserLastResDetails
-
-	// Query to fetch the user_id and last response date
-	rows, err := db.Query(`
-		SELECT user_id, MAX(response_date) AS last_response_date
-		FROM UserResponse
-		GROUP BY user_id;
-	`)
+	userResponseList := []UserLastResDetails{}
+
+	// Fetch each user's latest response
+	latest, err := h.store.LatestResponses(r.Context())
 	if err != nil {
-		log.Printf("Error querying user responses: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error querying user responses", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
 		return
 	}
-	defer rows.Close()
-
-	// Iterate over the rows and scan the values into the userResponseList slice
-	for rows.Next() {
-		var response UserLastResDetails
-		var lastResponseDate string
-		if err := rows.Scan(&response.UserID, &lastResponseDate); err != nil {
-			log.Printf("Error scanning user response row: %v", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
-
-		// Parse the last response date and calculate the number of days since that date
-		parsedDate, err := time.Parse("2006-01-02T15:04:05Z", lastResponseDate)
-		if err != nil {
-			log.Printf("Error parsing last response date: %v", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
 
+	for _, assessment := range latest {
 		// Calculate the difference in days
-		daysSinceResponse := int(time.Since(parsedDate).Hours() / 24)
-		response.DaysSinceResponse = daysSinceResponse
-
+		daysSinceResponse := int(time.Since(assessment.ResponseDate).Hours() / 24)
 
 		// Add the result to the response list
-		userResponseList = append(userResponseList, response)
-	}
-
-	// Check if there was an error while iterating over the rows
-	if err := rows.Err(); err != nil {
-		log.Printf("Error iterating over rows: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+		userResponseList = append(userResponseList, UserLastResDetails{UserID: int(assessment.UserID), DaysSinceResponse: daysSinceResponse})
 	}
 
 	// Respond with the list of user responses as JSON
 	w.Header().Set("Content-Type", "application/json")
 	err = json.NewEncoder(w).Encode(userResponseList)
 	if err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error encoding response", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
 		return
 	}
 }
 
-//User and its latest risk level from result
+// User and its latest risk level from result
 // Struct for User Response with Risk Level
 type UserLatestRiskLevel struct {
-	UserID    int    `json:"user_id"`  // User ID
+	UserID    int    `json:"user_id"`    // User ID
 	RiskLevel string `json:"risk_level"` // Risk level (low, moderate, high)
 }
-func GetLatestUserRiskLevel(w http.ResponseWriter, r *http.Request) {
+
+// riskLevel classifies an FES total score, which ranges from 16 to 64
+func riskLevel(totalScore int) string {
+	switch {
+	case totalScore >= 16 && totalScore <= 36:
+		return "low"
+	case totalScore >= 37 && totalScore <= 48:
+		return "moderate"
+	case totalScore >= 49 && totalScore <= 64:
+		return "high"
+	default:
+		return "invalid"
+	}
+}
+
+func (h *Handler) GetLatestUserRiskLevel(w http.ResponseWriter, r *http.Request) {
 	// Define a slice to store the list of user risk levels
-	var userRiskLevels []UserLatestRiskLevel
-
-	// Query to fetch the latest response for each user and calculate risk level
-	rows, err := db.Query(`
-		SELECT ur.user_id,
-               CASE 
-                   WHEN ur.total_score BETWEEN 16 AND 36 THEN 'low'
-                   WHEN ur.total_score BETWEEN 37 AND 48 THEN 'moderate'
-                   WHEN ur.total_score BETWEEN 49 AND 64 THEN 'high'
-                   ELSE 'invalid'
-               END AS risk_level
-        FROM UserResponse ur
-        JOIN (
-            SELECT user_id, MAX(response_date) AS latest_response_date
-            FROM UserResponse
-            GROUP BY user_id
-        ) latest ON ur.user_id = latest.user_id AND ur.response_date = latest.latest_response_date;
-	`)
+	userRiskLevels := []UserLatestRiskLevel{}
+
+	// Fetch the latest response for each user and calculate risk level
+	latest, err := h.store.LatestResponses(r.Context())
 	if err != nil {
-		log.Printf("Error querying user risk levels: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error querying user risk levels", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
 		return
 	}
-	defer rows.Close()
-
-	// Iterate over the rows and scan the values into the userRiskLevels slice
-	for rows.Next() {
-		var userRisk UserLatestRiskLevel
-		if err := rows.Scan(
-			&userRisk.UserID,
-			&userRisk.RiskLevel,
-		); err != nil {
-			log.Printf("Error scanning user risk level row: %v", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
-		userRiskLevels = append(userRiskLevels, userRisk)
-	}
-
-	// Check if there was an error while iterating over the rows
-	if err := rows.Err(); err != nil {
-		log.Printf("Error iterating over rows: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+
+	for _, assessment := range latest {
+		userRiskLevels = append(userRiskLevels, UserLatestRiskLevel{UserID: int(assessment.UserID), RiskLevel: riskLevel(int(assessment.TotalScore))})
 	}
 
 	// Respond with the list of user risk levels as JSON
 	w.Header().Set("Content-Type", "application/json")
 	err = json.NewEncoder(w).Encode(userRiskLevels)
 	if err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error encoding response", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
 		return
 	}
 }
-	
+
+// GetAllFESLatestScore returns each senior's most recent response, so the admin dashboard reads one
+// row per senior instead of every response
+func (h *Handler) GetAllFESLatestScore(w http.ResponseWriter, r *http.Request) {
+	latest, err := h.store.LatestResponses(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying latest responses", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+	if latest == nil {
+		latest = []LastAssessment{}
+	}
+
+	// Respond with the latest responses as JSON
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(latest)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error encoding response", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+}
+
 // LastAssessment represents the most recent Falls Efficacy Scale assessment for a user
 type LastAssessment struct {
-    ResponseID    uint16    `json:"response_id"`
-    UserID        uint16    `json:"user_id"`
-    TotalScore    uint16    `json:"total_score"`
-    ResponseDate  time.Time    `json:"response_date"`
+	ResponseID   uint16    `json:"response_id"`
+	UserID       uint16    `json:"user_id"`
+	TotalScore   uint16    `json:"total_score"`
+	ResponseDate time.Time `json:"response_date"`
 }
 
-func GetLastAssessment(w http.ResponseWriter, r *http.Request) {
-	fmt.Println("hi")
-    // Extract userID from query parameters
-    userIDStr := r.URL.Query().Get("user_id")
-    if userIDStr == "" {
-        http.Error(w, "user_id is required", http.StatusBadRequest)
-        return
-    }
-
-    userID, err := strconv.Atoi(userIDStr)
-    if err != nil {
-        http.Error(w, "Invalid user_id", http.StatusBadRequest)
-        return
-    }
-
-    // Query to get the most recent assessment for the user
-    var assessment LastAssessment
-    err = db.QueryRow(`
-        SELECT response_id, user_id, total_score, response_date
-        FROM UserResponse 
-        WHERE user_id = ? 
-        ORDER BY response_date DESC 
-        LIMIT 1`, userID).Scan(
-        &assessment.ResponseID,
-        &assessment.UserID,
-        &assessment.TotalScore,
-        &assessment.ResponseDate,
-    )
-
-    if err == sql.ErrNoRows {
-        w.Header().Set("Content-Type", "application/json")
-        w.WriteHeader(http.StatusNotFound)
-        json.NewEncoder(w).Encode(map[string]string{
-            "message": "No assessments found for this user",
-        })
-        return
-    }
-
-    if err != nil {
-        log.Printf("Database query error: %v", err)
-        http.Error(w, "Failed to fetch last assessment", http.StatusInternalServerError)
-        return
-    }
-
-    // Send response as JSON
-    w.Header().Set("Content-Type", "application/json")
-    err = json.NewEncoder(w).Encode(assessment)
-    if err != nil {
-        log.Printf("Error encoding JSON response: %v", err)
-        http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-        return
-    }
+func (h *Handler) GetLastAssessment(w http.ResponseWriter, r *http.Request) {
+	// Extract userID from query parameters
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "user_id is required")
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidField, "Invalid user_id")
+		return
+	}
+
+	// Fetch the most recent assessment for the user
+	assessment, err := h.store.LastAssessment(r.Context(), userID)
+
+	if err == ErrNotFound {
+		apierror.Write(w, r, http.StatusNotFound, apierror.NotFound, "No assessments found for this user")
+		return
+	}
+
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Database query error", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Failed to fetch last assessment")
+		return
+	}
+
+	// Send response as JSON
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(assessment)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error encoding JSON response", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Failed to encode response")
+		return
+	}
 }
@@ -0,0 +1,70 @@
+package FES_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"fallsEfficacyScaleMicroservice/FES"
+	"fallsEfficacyScaleMicroservice/FES/memstore"
+
+	"shared/pagination"
+)
+
+func TestGetAllUserResponsePages(t *testing.T) {
+	store := memstore.New()
+	day := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	for i, userID := range []int{1, 2, 1, 3, 1} {
+		scores := make([]int, 16)
+		for q := range scores {
+			scores[q] = 1 + i%4
+		}
+		if _, err := store.AddResponse(userID, day.AddDate(0, 0, i), scores...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	handler := FES.NewHandler(store, &memstore.RiskRecorder{})
+
+	// Senior 1's responses, newest first, two to a page
+	var got []int
+	query := "user_id=1&sort=-response_date&limit=2"
+	for {
+		w := httptest.NewRecorder()
+		handler.GetAllUserResponse(w, httptest.NewRequest(http.MethodGet, "/api/v1/fes/getAllResponses?"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("getAllResponses?%s = %d %s", query, w.Code, w.Body)
+		}
+		if total := w.Header().Get(pagination.TotalCountHeader); total != "3" {
+			t.Errorf("%s = %s, want 3", pagination.TotalCountHeader, total)
+		}
+
+		var page []FES.UserResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+			t.Fatal(err)
+		}
+		for _, response := range page {
+			got = append(got, int(response.TotalScore))
+		}
+
+		cursor := w.Header().Get(pagination.NextCursorHeader)
+		if cursor == "" {
+			break
+		}
+		query = "user_id=1&sort=-response_date&limit=2&cursor=" + url.QueryEscape(cursor)
+	}
+
+	// Senior 1's responses, the fifth, third and first, answered every question with 1, 3 and 1
+	if want := []int{16, 48, 16}; !reflect.DeepEqual(got, want) {
+		t.Errorf("total scores = %v, want %v", got, want)
+	}
+
+	w := httptest.NewRecorder()
+	handler.GetAllUserResponse(w, httptest.NewRequest(http.MethodGet, "/api/v1/fes/getAllResponses?sort=name", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("unknown sort: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
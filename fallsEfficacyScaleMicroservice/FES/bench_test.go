@@ -0,0 +1,126 @@
+package FES
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"fallsEfficacyScaleMicroservice/migrations"
+
+	"shared/dbtest"
+)
+
+// benchDBEnv names the MySQL server the benchmarks create their database on. They are skipped
+// without it.
+const benchDBEnv = "FES_BENCH_DB_CONNECTION"
+
+// questionsPerResponse is the number of questions on the Falls Efficacy Scale
+const questionsPerResponse = 16
+
+// BenchmarkUserResults measures GetUserFESResults for seniors with many responses, comparing the
+// set-based queries it uses with the query-per-response approach they replaced:
+//
+//	FES_BENCH_DB_CONNECTION='user:password@tcp(localhost:3306)/' go test -run '^$' -bench UserResults ./FES
+func BenchmarkUserResults(b *testing.B) {
+	db, err := OpenDB(dbtest.Database(b, benchDBEnv, migrations.Files))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+	// Reading results sends nothing to the combined risk model
+	fes := NewHandler(NewMySQLStore(db), nil)
+
+	for userID, responses := range []int{30, 300} {
+		userID++
+		if err := seedResponses(db, userID, responses); err != nil {
+			b.Fatalf("failed to seed %d responses: %v", responses, err)
+		}
+
+		b.Run(fmt.Sprintf("responses=%d/set-based", responses), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				recorder := httptest.NewRecorder()
+				request := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/fes/getFESResults?user_id=%d", userID), nil)
+				fes.GetUserFESResults(recorder, request)
+				if recorder.Code != http.StatusOK {
+					b.Fatalf("GetUserFESResults returned %d: %s", recorder.Code, recorder.Body.String())
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("responses=%d/query-per-response", responses), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if err := queryPerResponse(db, userID); err != nil {
+					b.Fatalf("query per response failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// seedResponses gives the user count responses of random answers, one a day up to today
+func seedResponses(db *sql.DB, userID, count int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i := 0; i < count; i++ {
+		scores := make([]int, questionsPerResponse)
+		total := 0
+		for q := range scores {
+			scores[q] = rand.Intn(4) + 1
+			total += scores[q]
+		}
+
+		result, err := tx.Exec(`INSERT INTO UserResponse (user_id, total_score, response_date) VALUES (?, ?, NOW() - INTERVAL ? DAY)`,
+			userID, total, count-i)
+		if err != nil {
+			return err
+		}
+		responseID, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		for q, score := range scores {
+			if _, err := tx.Exec(`INSERT INTO UserResponseDetails (response_id, question_id, response_score) VALUES (?, ?, ?)`,
+				responseID, q+1, score); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// queryPerResponse reads the user's responses the way GetUserFESResults used to:
+// one query for the responses, then one for the answers of each response. Each result set is
+// closed straight away, which flatters the old code, whose deferred closes held every one open.
+func queryPerResponse(db *sql.DB, userID int) error {
+	rows, err := db.Query(`SELECT response_id FROM UserResponse WHERE user_id = ?`, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var responseID int
+		if err := rows.Scan(&responseID); err != nil {
+			return err
+		}
+		detailRows, err := db.Query(`SELECT question_id, response_score FROM UserResponseDetails WHERE response_id = ?`, responseID)
+		if err != nil {
+			return err
+		}
+		for detailRows.Next() {
+			var questionID, score int
+			if err := detailRows.Scan(&questionID, &score); err != nil {
+				detailRows.Close()
+				return err
+			}
+		}
+		detailRows.Close()
+	}
+	return rows.Err()
+}
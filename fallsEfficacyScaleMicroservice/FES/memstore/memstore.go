@@ -0,0 +1,403 @@
+// Package memstore keeps the Falls Efficacy Scale data in memory, for testing the FES handlers with
+// httptest without a database:
+//
+//	store := memstore.New()
+//	store.AddQuestion("Getting dressed or undressed")
+//	risk := &memstore.RiskRecorder{}
+//	handler := FES.NewHandler(store, risk)
+//	handler.GetQuestions(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/questions", nil))
+package memstore
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"fallsEfficacyScaleMicroservice/FES"
+	"fallsEfficacyScaleMicroservice/client/user"
+
+	"shared/pagination"
+)
+
+// Store is an FES.FESStore held in memory. It enforces the score ranges the database checks.
+type Store struct {
+	mu         sync.Mutex
+	questions  []FES.Question
+	responses  []response
+	nextID     int
+	nextAnswer int
+
+	// Now is the clock new responses are dated with, time.Now unless a test replaces it
+	Now func() time.Time
+}
+
+type response struct {
+	id      int
+	userID  int
+	total   int
+	date    time.Time
+	answers []answer
+}
+
+type answer struct {
+	id         int
+	questionID int
+	score      int
+}
+
+var _ FES.FESStore = (*Store)(nil)
+
+// New returns an empty store
+func New() *Store {
+	return &Store{Now: time.Now}
+}
+
+// AddQuestion adds a question to the scale, returning its ID
+func (s *Store) AddQuestion(text string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := len(s.questions) + 1
+	s.questions = append(s.questions, FES.Question{ID: id, Text: text})
+	return id
+}
+
+// AddResponse adds a senior's response dated date, with scores for questions 1, 2 and so on,
+// returning its ID. It is for arranging the data a test reads.
+func (s *Store) AddResponse(userID int, date time.Time, scores ...int) (int, error) {
+	answers := make([]FES.Answer, len(scores))
+	for i, score := range scores {
+		answers[i] = FES.Answer{QuestionID: i + 1, Score: score}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.add(userID, date, answers)
+}
+
+// add stores a response, checking the scores as the database's CHECK constraints do
+func (s *Store) add(userID int, date time.Time, answers []FES.Answer) (int, error) {
+	total := 0
+	for _, a := range answers {
+		if a.Score < 1 || a.Score > 4 {
+			return 0, fmt.Errorf("response_score %d is not between 1 and 4", a.Score)
+		}
+		total += a.Score
+	}
+	if total < 16 || total > 64 {
+		return 0, fmt.Errorf("total_score %d is not between 16 and 64", total)
+	}
+
+	s.nextID++
+	r := response{id: s.nextID, userID: userID, total: total, date: date}
+	for _, a := range answers {
+		s.nextAnswer++
+		r.answers = append(r.answers, answer{id: s.nextAnswer, questionID: a.QuestionID, score: a.Score})
+	}
+	s.responses = append(s.responses, r)
+	return r.id, nil
+}
+
+func (s *Store) Questions(ctx context.Context) ([]FES.Question, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.questions), nil
+}
+
+func (s *Store) SaveResponse(ctx context.Context, userID int, answers []FES.Answer) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.add(userID, s.Now(), answers)
+}
+
+func (s *Store) Responses(ctx context.Context, page *pagination.Query) ([]FES.UserResponse, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var matching []response
+	for _, r := range s.responses {
+		if page.Matches(r.date, r.userID) {
+			matching = append(matching, r)
+		}
+	}
+	slices.SortStableFunc(matching, func(a, b response) int {
+		var c int
+		switch page.Sort {
+		case "response_date":
+			c = a.date.Compare(b.date)
+		case "total_score":
+			c = cmp.Compare(a.total, b.total)
+		case "user_id":
+			c = cmp.Compare(a.userID, b.userID)
+		}
+		return direction(page, cmp.Or(c, cmp.Compare(a.id, b.id)))
+	})
+
+	rows := []FES.UserResponse{}
+	for _, r := range pagination.Page(page, matching) {
+		rows = append(rows, r.userResponse(false))
+	}
+	return rows, len(matching), nil
+}
+
+func (s *Store) ResponseDetails(ctx context.Context, page *pagination.Query) ([]FES.UserResponseDetails, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	type row struct {
+		response response
+		answer   answer
+	}
+	var matching []row
+	for _, r := range s.responses {
+		if page.Matches(r.date, r.userID) {
+			for _, a := range r.answers {
+				matching = append(matching, row{response: r, answer: a})
+			}
+		}
+	}
+	slices.SortStableFunc(matching, func(a, b row) int {
+		var c int
+		switch page.Sort {
+		case "response_id":
+			c = cmp.Compare(a.response.id, b.response.id)
+		case "question_id":
+			c = cmp.Compare(a.answer.questionID, b.answer.questionID)
+		case "response_score":
+			c = cmp.Compare(a.answer.score, b.answer.score)
+		case "response_date":
+			c = a.response.date.Compare(b.response.date)
+		}
+		return direction(page, cmp.Or(c, cmp.Compare(a.answer.id, b.answer.id)))
+	})
+
+	rows := []FES.UserResponseDetails{}
+	for _, r := range pagination.Page(page, matching) {
+		rows = append(rows, FES.UserResponseDetails{ResponseID: r.response.id, QuestionID: r.answer.questionID, ResponseScore: r.answer.score})
+	}
+	return rows, len(matching), nil
+}
+
+func (s *Store) UserResponses(ctx context.Context, userID int) ([]FES.UserResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	results := []FES.UserResponse{}
+	for _, r := range s.responses {
+		if r.userID == userID {
+			results = append(results, r.userResponse(true))
+		}
+	}
+	return results, nil
+}
+
+func (s *Store) LatestResponses(ctx context.Context) ([]FES.LastAssessment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	latest := map[int]response{}
+	for _, r := range s.responses {
+		if current, ok := latest[r.userID]; !ok || newer(r, current) {
+			latest[r.userID] = r
+		}
+	}
+
+	var assessments []FES.LastAssessment
+	for _, r := range latest {
+		assessments = append(assessments, r.lastAssessment())
+	}
+	slices.SortFunc(assessments, func(a, b FES.LastAssessment) int { return cmp.Compare(a.UserID, b.UserID) })
+	return assessments, nil
+}
+
+func (s *Store) LastAssessment(ctx context.Context, userID int) (FES.LastAssessment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var last *response
+	for i, r := range s.responses {
+		if r.userID == userID && (last == nil || newer(r, *last)) {
+			last = &s.responses[i]
+		}
+	}
+	if last == nil {
+		return FES.LastAssessment{}, FES.ErrNotFound
+	}
+	return last.lastAssessment(), nil
+}
+
+func (s *Store) ScoreHistory(ctx context.Context, days int, userID *int) ([]FES.ScorePoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	since := s.Now().AddDate(0, 0, -days)
+	var points []FES.ScorePoint
+	for _, r := range s.responses {
+		if !r.date.Before(since) && (userID == nil || *userID == r.userID) {
+			points = append(points, FES.ScorePoint{UserID: r.userID, Score: float64(r.total), Date: r.date})
+		}
+	}
+	slices.SortStableFunc(points, func(a, b FES.ScorePoint) int {
+		return cmp.Or(cmp.Compare(a.UserID, b.UserID), a.Date.Compare(b.Date))
+	})
+	return points, nil
+}
+
+func (s *Store) RiskDistribution(ctx context.Context, from, before time.Time) ([]FES.RiskPeriod, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Each senior's latest response in each month
+	type key struct {
+		userID int
+		period string
+	}
+	latest := map[key]response{}
+	for _, r := range s.inRange(from, before) {
+		k := key{userID: r.userID, period: r.date.Format("2006-01")}
+		if current, ok := latest[k]; !ok || newer(r, current) {
+			latest[k] = r
+		}
+	}
+
+	byPeriod := map[string]*FES.RiskPeriod{}
+	for k, r := range latest {
+		period, ok := byPeriod[k.period]
+		if !ok {
+			period = &FES.RiskPeriod{Period: k.period}
+			byPeriod[k.period] = period
+		}
+		period.Participants++
+		switch {
+		case r.total <= 36:
+			period.Low++
+		case r.total <= 48:
+			period.Moderate++
+		default:
+			period.High++
+		}
+	}
+
+	periods := []FES.RiskPeriod{}
+	for _, period := range byPeriod {
+		periods = append(periods, *period)
+	}
+	slices.SortFunc(periods, func(a, b FES.RiskPeriod) int { return cmp.Compare(a.Period, b.Period) })
+	return periods, nil
+}
+
+func (s *Store) ItemBreakdown(ctx context.Context, from, before time.Time) ([]FES.ItemBreakdown, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := make([]FES.ItemBreakdown, len(s.questions))
+	index := map[int]int{}
+	for i, q := range s.questions {
+		items[i] = FES.ItemBreakdown{QuestionID: q.ID, QuestionText: q.Text}
+		index[q.ID] = i
+	}
+	for _, r := range s.inRange(from, before) {
+		for _, a := range r.answers {
+			i, ok := index[a.questionID]
+			if !ok {
+				continue
+			}
+			items[i].Responses++
+			items[i].AverageScore += float64(a.score)
+			items[i].ScoreCounts[a.score-1]++
+		}
+	}
+	for i := range items {
+		if items[i].Responses > 0 {
+			items[i].AverageScore /= float64(items[i].Responses)
+		}
+	}
+	return items, nil
+}
+
+func (s *Store) UserScoreTotals(ctx context.Context, from, before time.Time) ([]FES.UserScoreTotal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byUser := map[int]*FES.UserScoreTotal{}
+	for _, r := range s.inRange(from, before) {
+		total, ok := byUser[r.userID]
+		if !ok {
+			total = &FES.UserScoreTotal{UserID: r.userID}
+			byUser[r.userID] = total
+		}
+		total.Count++
+		total.Sum += float64(r.total)
+	}
+
+	totals := []FES.UserScoreTotal{}
+	for _, total := range byUser {
+		totals = append(totals, *total)
+	}
+	slices.SortFunc(totals, func(a, b FES.UserScoreTotal) int { return cmp.Compare(a.UserID, b.UserID) })
+	return totals, nil
+}
+
+// inRange returns the responses dated from from until before
+func (s *Store) inRange(from, before time.Time) []response {
+	var matching []response
+	for _, r := range s.responses {
+		if !r.date.Before(from) && r.date.Before(before) {
+			matching = append(matching, r)
+		}
+	}
+	return matching
+}
+
+// newer reports whether a is more recent than b, by date and then ID as the database orders them
+func newer(a, b response) bool {
+	return cmp.Or(a.date.Compare(b.date), cmp.Compare(a.id, b.id)) > 0
+}
+
+// direction applies the sort direction of the page to a comparison
+func direction(page *pagination.Query, c int) int {
+	if page.Descending {
+		return -c
+	}
+	return c
+}
+
+func (r response) userResponse(withAnswers bool) FES.UserResponse {
+	result := FES.UserResponse{ResponseID: uint16(r.id), UserID: uint16(r.userID), TotalScore: uint16(r.total), ResponseDate: r.date}
+	if withAnswers {
+		for _, a := range r.answers {
+			result.ResponseDetails = append(result.ResponseDetails, FES.UserResponseDetail{QuestionID: uint16(a.questionID), ResponseScore: uint8(a.score)})
+		}
+	}
+	return result
+}
+
+func (r response) lastAssessment() FES.LastAssessment {
+	return FES.LastAssessment{ResponseID: uint16(r.id), UserID: uint16(r.userID), TotalScore: uint16(r.total), ResponseDate: r.date}
+}
+
+// RiskRecorder is an FES.RiskRecorder that keeps the observations sent to it
+type RiskRecorder struct {
+	mu           sync.Mutex
+	observations []user.RiskObservation
+}
+
+var _ FES.RiskRecorder = (*RiskRecorder)(nil)
+
+func (r *RiskRecorder) RecordRiskObservation(ctx context.Context, observation user.RiskObservation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.observations = append(r.observations, observation)
+	return nil
+}
+
+// Observations returns the observations recorded so far. SaveResponse sends them in the background,
+// so a test may need to wait for one to arrive.
+func (r *RiskRecorder) Observations() []user.RiskObservation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.observations)
+}
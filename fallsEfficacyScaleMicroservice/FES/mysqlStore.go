@@ -0,0 +1,370 @@
+package FES
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"shared/observability"
+	"shared/pagination"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// OpenDB connects to the database named by a DSN and checks the connection
+func OpenDB(dsn string) (*sql.DB, error) {
+	if dsn == "" {
+		return nil, fmt.Errorf("database connection string is not set")
+	}
+
+	slog.Debug("Initializing database connection")
+	db, err := observability.OpenDB("mysql", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to database: %v", err)
+	}
+
+	// Test the database connection
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("database connection test failed: %v", err)
+	}
+	slog.Info("Database connection successful")
+	return db, nil
+}
+
+// MySQLStore is the FESStore kept in the FES database
+type MySQLStore struct {
+	db *sql.DB
+}
+
+// NewMySQLStore returns a store reading and writing the given database
+func NewMySQLStore(db *sql.DB) *MySQLStore {
+	return &MySQLStore{db: db}
+}
+
+func (s *MySQLStore) Questions(ctx context.Context) ([]Question, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT question_id, question_text
+		FROM FallsEfficacyScale
+		ORDER BY question_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	questions := []Question{}
+	for rows.Next() {
+		var q Question
+		if err := rows.Scan(&q.ID, &q.Text); err != nil {
+			return nil, err
+		}
+		questions = append(questions, q)
+	}
+	return questions, rows.Err()
+}
+
+func (s *MySQLStore) SaveResponse(ctx context.Context, userID int, answers []Answer) (int, error) {
+	var totalScore int
+	for _, answer := range answers {
+		totalScore += answer.Score
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO UserResponse (user_id, total_score)
+		VALUES (?, ?)`, userID, totalScore)
+	if err != nil {
+		return 0, fmt.Errorf("error inserting into UserResponse: %v", err)
+	}
+	responseID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, answer := range answers {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO UserResponseDetails (response_id, question_id, response_score)
+			VALUES (?, ?, ?)`, responseID, answer.QuestionID, answer.Score); err != nil {
+			return 0, fmt.Errorf("error inserting into UserResponseDetails: %v", err)
+		}
+	}
+	return int(responseID), tx.Commit()
+}
+
+func (s *MySQLStore) Responses(ctx context.Context, page *pagination.Query) ([]UserResponse, int, error) {
+	where, args := page.Where()
+
+	// Count every matching response, for the total across pages
+	var total int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM UserResponse`+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("error counting user responses: %v", err)
+	}
+
+	clauses, pageArgs := page.Page()
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT response_id, user_id, total_score, response_date`+page.KeyColumns()+`
+		FROM UserResponse`+clauses, pageArgs...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	responses := []UserResponse{}
+	for rows.Next() {
+		var response UserResponse
+		if err := rows.Scan(append([]interface{}{&response.ResponseID, &response.UserID, &response.TotalScore, &response.ResponseDate}, page.Key()...)...); err != nil {
+			return nil, 0, err
+		}
+		responses = append(responses, response)
+	}
+	return responses, total, rows.Err()
+}
+
+func (s *MySQLStore) ResponseDetails(ctx context.Context, page *pagination.Query) ([]UserResponseDetails, int, error) {
+	where, args := page.Where()
+	tables := `
+		FROM UserResponseDetails urd
+		JOIN UserResponse ur ON ur.response_id = urd.response_id`
+
+	// Count every matching answer, for the total across pages
+	var total int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*)`+tables+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("error counting individual response details: %v", err)
+	}
+
+	clauses, pageArgs := page.Page()
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT urd.response_id, urd.question_id, urd.response_score`+page.KeyColumns()+tables+clauses, pageArgs...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	details := []UserResponseDetails{}
+	for rows.Next() {
+		var detail UserResponseDetails
+		if err := rows.Scan(append([]interface{}{&detail.ResponseID, &detail.QuestionID, &detail.ResponseScore}, page.Key()...)...); err != nil {
+			return nil, 0, err
+		}
+		details = append(details, detail)
+	}
+	return details, total, rows.Err()
+}
+
+// UserResponses reads the responses and all their answers with one query each and groups them
+// in memory, so the number of queries does not grow with the number of responses.
+func (s *MySQLStore) UserResponses(ctx context.Context, userID int) ([]UserResponse, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT response_id, user_id, total_score, response_date
+		 FROM UserResponse
+		 WHERE user_id = ?
+		 ORDER BY response_id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []UserResponse{}
+	for rows.Next() {
+		var result UserResponse
+		if err := rows.Scan(&result.ResponseID, &result.UserID, &result.TotalScore, &result.ResponseDate); err != nil {
+			return nil, fmt.Errorf("error scanning row: %v", err)
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Fetch the UserResponseDetails of every one of the senior's responses
+	detailRows, err := s.db.QueryContext(ctx,
+		`SELECT urd.response_id, urd.question_id, urd.response_score
+		 FROM UserResponseDetails urd
+		 JOIN UserResponse ur ON ur.response_id = urd.response_id
+		 WHERE ur.user_id = ?
+		 ORDER BY urd.response_id, urd.detail_id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying response details: %v", err)
+	}
+	defer detailRows.Close()
+
+	// Group the details by response
+	detailsByResponse := map[uint16][]UserResponseDetail{}
+	for detailRows.Next() {
+		var responseID uint16
+		var detail UserResponseDetail
+		if err := detailRows.Scan(&responseID, &detail.QuestionID, &detail.ResponseScore); err != nil {
+			return nil, fmt.Errorf("error scanning detail row: %v", err)
+		}
+		detailsByResponse[responseID] = append(detailsByResponse[responseID], detail)
+	}
+	if err := detailRows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range results {
+		results[i].ResponseDetails = detailsByResponse[results[i].ResponseID]
+	}
+	return results, nil
+}
+
+func (s *MySQLStore) LatestResponses(ctx context.Context) ([]LastAssessment, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT response_id, user_id, total_score, response_date
+		FROM (
+			SELECT response_id, user_id, total_score, response_date,
+			       ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY response_date DESC, response_id DESC) AS latest
+			FROM UserResponse
+		) ranked
+		WHERE latest = 1
+		ORDER BY user_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var latest []LastAssessment
+	for rows.Next() {
+		var assessment LastAssessment
+		if err := rows.Scan(&assessment.ResponseID, &assessment.UserID, &assessment.TotalScore, &assessment.ResponseDate); err != nil {
+			return nil, err
+		}
+		latest = append(latest, assessment)
+	}
+	return latest, rows.Err()
+}
+
+func (s *MySQLStore) LastAssessment(ctx context.Context, userID int) (LastAssessment, error) {
+	var assessment LastAssessment
+	err := s.db.QueryRowContext(ctx, `
+		SELECT response_id, user_id, total_score, response_date
+		FROM UserResponse
+		WHERE user_id = ?
+		ORDER BY response_date DESC, response_id DESC
+		LIMIT 1`, userID).Scan(
+		&assessment.ResponseID,
+		&assessment.UserID,
+		&assessment.TotalScore,
+		&assessment.ResponseDate,
+	)
+	if err == sql.ErrNoRows {
+		return assessment, ErrNotFound
+	}
+	return assessment, err
+}
+
+func (s *MySQLStore) ScoreHistory(ctx context.Context, days int, userID *int) ([]ScorePoint, error) {
+	query := `
+		SELECT user_id, total_score, response_date
+		FROM UserResponse
+		WHERE response_date >= NOW() - INTERVAL ? DAY`
+	args := []interface{}{days}
+	if userID != nil {
+		query += ` AND user_id = ?`
+		args = append(args, *userID)
+	}
+	rows, err := s.db.QueryContext(ctx, query+` ORDER BY user_id, response_date`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []ScorePoint
+	for rows.Next() {
+		var point ScorePoint
+		if err := rows.Scan(&point.UserID, &point.Score, &point.Date); err != nil {
+			return nil, err
+		}
+		points = append(points, point)
+	}
+	return points, rows.Err()
+}
+
+func (s *MySQLStore) RiskDistribution(ctx context.Context, from, before time.Time) ([]RiskPeriod, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT period, COUNT(*),
+		       SUM(total_score BETWEEN 16 AND 36), SUM(total_score BETWEEN 37 AND 48), SUM(total_score BETWEEN 49 AND 64)
+		FROM (
+			SELECT DATE_FORMAT(response_date, '%Y-%m') AS period, total_score,
+			       ROW_NUMBER() OVER (PARTITION BY user_id, DATE_FORMAT(response_date, '%Y-%m') ORDER BY response_date DESC, response_id DESC) AS latest
+			FROM UserResponse
+			WHERE response_date >= ? AND response_date < ?
+		) monthly
+		WHERE latest = 1
+		GROUP BY period
+		ORDER BY period`, from.Format(analyticsDateLayout), before.Format(analyticsDateLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	periods := []RiskPeriod{}
+	for rows.Next() {
+		var period RiskPeriod
+		if err := rows.Scan(&period.Period, &period.Participants, &period.Low, &period.Moderate, &period.High); err != nil {
+			return nil, err
+		}
+		periods = append(periods, period)
+	}
+	return periods, rows.Err()
+}
+
+func (s *MySQLStore) ItemBreakdown(ctx context.Context, from, before time.Time) ([]ItemBreakdown, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT q.question_id, q.question_text, COUNT(urd.detail_id), COALESCE(AVG(urd.response_score), 0),
+		       COALESCE(SUM(urd.response_score = 1), 0), COALESCE(SUM(urd.response_score = 2), 0),
+		       COALESCE(SUM(urd.response_score = 3), 0), COALESCE(SUM(urd.response_score = 4), 0)
+		FROM FallsEfficacyScale q
+		LEFT JOIN UserResponseDetails urd ON urd.question_id = q.question_id
+		     AND urd.response_id IN (SELECT response_id FROM UserResponse WHERE response_date >= ? AND response_date < ?)
+		GROUP BY q.question_id, q.question_text
+		ORDER BY q.question_id`, from.Format(analyticsDateLayout), before.Format(analyticsDateLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []ItemBreakdown{}
+	for rows.Next() {
+		var item ItemBreakdown
+		if err := rows.Scan(
+			&item.QuestionID, &item.QuestionText, &item.Responses, &item.AverageScore,
+			&item.ScoreCounts[0], &item.ScoreCounts[1], &item.ScoreCounts[2], &item.ScoreCounts[3],
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (s *MySQLStore) UserScoreTotals(ctx context.Context, from, before time.Time) ([]UserScoreTotal, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT user_id, COUNT(*), SUM(total_score)
+		FROM UserResponse
+		WHERE response_date >= ? AND response_date < ?
+		GROUP BY user_id
+		ORDER BY user_id`, from.Format(analyticsDateLayout), before.Format(analyticsDateLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := []UserScoreTotal{}
+	for rows.Next() {
+		var total UserScoreTotal
+		if err := rows.Scan(&total.UserID, &total.Count, &total.Sum); err != nil {
+			return nil, err
+		}
+		totals = append(totals, total)
+	}
+	return totals, rows.Err()
+}
@@ -0,0 +1,53 @@
+package FES
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"shared/pagination"
+)
+
+// ErrNotFound is returned by a store when the requested row does not exist
+var ErrNotFound = errors.New("not found")
+
+// FESStore is the Falls Efficacy Scale data the handlers read and write. MySQLStore keeps it in the
+// FES database, and the memstore package keeps it in memory for handler tests.
+type FESStore interface {
+	// Questions returns the questions of the scale in order
+	Questions(ctx context.Context) ([]Question, error)
+	// SaveResponse stores a senior's answers with their total score, returning the new response ID
+	SaveResponse(ctx context.Context, userID int, answers []Answer) (int, error)
+	// Responses returns a page of the responses of every senior, without their answers, and the number matching the filters
+	Responses(ctx context.Context, page *pagination.Query) ([]UserResponse, int, error)
+	// ResponseDetails returns a page of the answers to every response, and the number matching the filters
+	ResponseDetails(ctx context.Context, page *pagination.Query) ([]UserResponseDetails, int, error)
+	// UserResponses returns a senior's responses with their answers, in the order they were saved
+	UserResponses(ctx context.Context, userID int) ([]UserResponse, error)
+	// LatestResponses returns the most recent response of every senior who has answered the scale
+	LatestResponses(ctx context.Context) ([]LastAssessment, error)
+	// LastAssessment returns a senior's most recent response, or ErrNotFound if they have none
+	LastAssessment(ctx context.Context, userID int) (LastAssessment, error)
+	// ScoreHistory returns the total scores of the last days, of one senior or of every senior when
+	// userID is nil, ordered by senior then date
+	ScoreHistory(ctx context.Context, days int, userID *int) ([]ScorePoint, error)
+	// RiskDistribution returns the spread of risk levels per month of the responses from from until before
+	RiskDistribution(ctx context.Context, from, before time.Time) ([]RiskPeriod, error)
+	// ItemBreakdown returns how every question was answered in the responses from from until before
+	ItemBreakdown(ctx context.Context, from, before time.Time) ([]ItemBreakdown, error)
+	// UserScoreTotals returns the count and sum of each senior's total scores from from until before, without a Metric
+	UserScoreTotals(ctx context.Context, from, before time.Time) ([]UserScoreTotal, error)
+}
+
+// Answer is a senior's score for one question of a response
+type Answer struct {
+	QuestionID int `json:"question_id"`
+	Score      int `json:"score"`
+}
+
+// ScorePoint is one total score of a senior, for trend analysis
+type ScorePoint struct {
+	UserID int
+	Score  float64
+	Date   time.Time
+}
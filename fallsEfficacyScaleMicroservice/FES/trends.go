@@ -0,0 +1,112 @@
+package FES
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"shared/apierror"
+	"shared/trend"
+)
+
+// fesTotalMetric is the name of the FES total trend
+const fesTotalMetric = "FES total"
+
+// UserTrends is one senior's trend series
+type UserTrends struct {
+	UserID int            `json:"user_id"`
+	Series []trend.Series `json:"series"`
+}
+
+// GetFESTrends returns the trajectory of a senior's FES total over the last days (default 180)
+func (h *Handler) GetFESTrends(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidField, "Invalid user_id")
+		return
+	}
+	days, ok := trendDays(w, r)
+	if !ok {
+		return
+	}
+
+	trends, err := h.fesTrends(r.Context(), days, &userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying FES trends", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Failed to fetch FES trends")
+		return
+	}
+
+	result := UserTrends{UserID: userID, Series: []trend.Series{trend.Analyze(fesTotalMetric, "points", trend.HigherIsWorse, nil)}}
+	if len(trends) > 0 {
+		result = trends[0]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// GetDecliningFESTrends returns every senior whose FES total has significantly worsened over the last days (default 180)
+func (h *Handler) GetDecliningFESTrends(w http.ResponseWriter, r *http.Request) {
+	days, ok := trendDays(w, r)
+	if !ok {
+		return
+	}
+
+	trends, err := h.fesTrends(r.Context(), days, nil)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying FES trends", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Failed to fetch FES trends")
+		return
+	}
+
+	declining := []UserTrends{}
+	for _, userTrends := range trends {
+		series := userTrends.Series[0]
+		if series.Change != nil && series.Change.Significant && series.Change.Worsening {
+			declining = append(declining, userTrends)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(declining)
+}
+
+// fesTrends analyses the FES totals of one senior, or of every senior when userID is nil
+func (h *Handler) fesTrends(ctx context.Context, days int, userID *int) ([]UserTrends, error) {
+	history, err := h.store.ScoreHistory(ctx, days, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var userIDs []int
+	points := map[int][]trend.Point{}
+	for _, point := range history {
+		if _, seen := points[point.UserID]; !seen {
+			userIDs = append(userIDs, point.UserID)
+		}
+		points[point.UserID] = append(points[point.UserID], trend.Point{Time: point.Date, Value: point.Score})
+	}
+
+	trends := make([]UserTrends, 0, len(userIDs))
+	for _, id := range userIDs {
+		series := trend.Analyze(fesTotalMetric, "points", trend.HigherIsWorse, points[id])
+		trends = append(trends, UserTrends{UserID: id, Series: []trend.Series{series}})
+	}
+	return trends, nil
+}
+
+// trendDays reads the days query parameter, writing an error response and returning false if it is invalid
+func trendDays(w http.ResponseWriter, r *http.Request) (int, bool) {
+	value := r.URL.Query().Get("days")
+	if value == "" {
+		return trend.DefaultDays, true
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 1 {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidField, "Invalid days")
+		return 0, false
+	}
+	return days, true
+}
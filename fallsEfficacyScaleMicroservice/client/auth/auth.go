@@ -0,0 +1,28 @@
+package auth
+
+import (
+	"context"
+
+	"shared/client"
+	"shared/config"
+)
+
+// Client calls the authentication microservice
+type Client struct {
+	*client.Client
+}
+
+// New returns a client for the authentication microservice
+func New() *Client {
+	return &Client{client.New(config.AuthService)}
+}
+
+// AdminTokenActive reports whether an admin's token is still live: stored, unexpired and
+// belonging to an active admin. Tokens are removed when their admin is deactivated or reset.
+func (c *Client) AdminTokenActive(ctx context.Context, token string) (bool, error) {
+	var session struct {
+		Active bool `json:"active"`
+	}
+	err := c.Post(client.AsService(ctx), "/api/v1/authentication/admin/checkToken", map[string]string{"token": token}, &session)
+	return session.Active, err
+}
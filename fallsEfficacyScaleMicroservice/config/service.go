@@ -0,0 +1,11 @@
+package config
+
+import "shared/settings"
+
+// Config is the Falls Efficacy Scale microservice's configuration
+type Config struct {
+	settings.Common
+	Port           int    `env:"PORT" default:"5300" usage:"port the service listens on"`
+	DBConnection   string `env:"FES_DB_CONNECTION" required:"true" secret:"true" usage:"MySQL DSN of the Falls Efficacy Scale database"`
+	MigrateOnStart bool   `env:"MIGRATE_ON_START" default:"true" usage:"apply pending schema migrations on start"`
+}
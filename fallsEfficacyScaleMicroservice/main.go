@@ -1,117 +1,89 @@
 package main
 
 import (
-	"log"
-	"net/http"
+	"context"
+	"fmt"
+	"log/slog"
 	"os"
-	"strings"
 
 	"fallsEfficacyScaleMicroservice/FES"
-
-	"github.com/golang-jwt/jwt/v4"
-	"github.com/gorilla/handlers"
-	"github.com/gorilla/mux"
+	"fallsEfficacyScaleMicroservice/client/user"
+	"fallsEfficacyScaleMicroservice/config"
+	"fallsEfficacyScaleMicroservice/migrations"
+	"fallsEfficacyScaleMicroservice/server"
+
+	"shared/client"
+	sharedconfig "shared/config"
+	"shared/graceful"
+	"shared/logging"
+	"shared/migrate"
+	"shared/observability"
+	"shared/settings"
+	"shared/tracing"
 )
 
-// JWT Authentication Middleware with Role Check for multiple roles
-func authenticateMiddleware(allowedRoles []string) mux.MiddlewareFunc {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Get the Authorization header
-			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return
-			}
-
-			// Extract token
-			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-
-			// Get the JWT secret from the environment variable
-			secretKey := os.Getenv("JWT_SECRET")
-			if secretKey == "" {
-				log.Println("JWT_SECRET is not set in the environment")
-				http.Error(w, "Internal server error", http.StatusInternalServerError)
-				return
-			}
-
-			// Parse and validate the token
-			token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, http.ErrAbortHandler
-				}
-				return []byte(secretKey), nil
-			})
-
-			if err != nil || !token.Valid {
-				log.Printf("Invalid JWT token: %v", err)
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return
-			}
-
-			// Extract the claims from the token
-			claims, ok := token.Claims.(jwt.MapClaims)
-			if !ok {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return
-			}
-
-			// Check if the role matches any of the allowed roles
-			role, ok := claims["role"].(string)
-			if !ok {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return
-			}
-
-			// Check if the user's role is in the allowed roles
-			roleAllowed := false
-			for _, allowedRole := range allowedRoles {
-				if role == allowedRole {
-					roleAllowed = true
-					break
-				}
-			}
-
-			if !roleAllowed {
-				http.Error(w, "Forbidden", http.StatusForbidden)
-				return
-			}
+func main() {
+	// Read the configuration from the flags, the environment, secret files and the config file
+	cfg, err := config.Load(os.Args[1:])
+	if cfg.PrintConfig {
+		settings.Print(os.Stdout, cfg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 
-			// Proceed to the next handler if role matches
-			next.ServeHTTP(w, r)
-		})
+	// Log as JSON from here on, at the level set by LOG_LEVEL
+	logging.Setup(sharedconfig.FallsEfficacyService, cfg.LogLevel)
+	if err != nil {
+		slog.Error("Error loading configuration", "error", err)
+		os.Exit(1)
 	}
-}
 
-func main() {
-	// Initialize the router
-	router := mux.NewRouter()
+	// Trace requests across the services, exporting the spans if an OTLP collector is configured
+	shutdownTracing, err := tracing.Setup(context.Background(), sharedconfig.FallsEfficacyService)
+	if err != nil {
+		slog.Error("Error setting up tracing", "error", err)
+		os.Exit(1)
+	}
 
-	// JWT Authentication Logic
-	authenticated := router.NewRoute().Subrouter()
+	// Run the migrate subcommand, or bring the schema up to date before serving
+	if len(cfg.Args) > 0 && cfg.Args[0] == "migrate" {
+		if err := migrate.Command(cfg.DBConnection, migrations.Files, cfg.Args[1:]); err != nil {
+			slog.Error("Error running migrations", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if err := migrate.OnStart(cfg.DBConnection, migrations.Files, cfg.MigrateOnStart); err != nil {
+		slog.Error("Error applying schema migrations", "error", err)
+		os.Exit(1)
+	}
 
-	// Protected APIs
-	authenticated.HandleFunc("/api/v1/questions", FES.GetQuestions).Methods("GET").Handler(authenticateMiddleware([]string{"User"})(http.HandlerFunc(FES.GetQuestions)))
-	authenticated.HandleFunc("/api/v1/saveResponses", FES.SaveResponse).Methods("POST").Handler(authenticateMiddleware([]string{"User"})(http.HandlerFunc(FES.SaveResponse)))
-	authenticated.HandleFunc("/api/v1/fes/getAllResponses", FES.GetAllUserResponse).Methods("GET").Handler(authenticateMiddleware([]string{"Admin"})(http.HandlerFunc(FES.GetAllUserResponse)))
-	authenticated.HandleFunc("/api/v1/fes/getAllIndividualRes", FES.GetAllFESIndividualRes).Methods("GET").Handler(authenticateMiddleware([]string{"Admin"})(http.HandlerFunc(FES.GetAllFESIndividualRes)))
-	authenticated.HandleFunc("/api/v1/fes/getFESResults", FES.GetUserFESResults).Methods("GET").Handler(authenticateMiddleware([]string{"User"})(http.HandlerFunc(FES.GetUserFESResults)))
-	authenticated.HandleFunc("/api/v1/fes/getAllFESLastResDay", FES.GetAllFESLatestResDate).Methods("GET").Handler(authenticateMiddleware([]string{"Admin"})(http.HandlerFunc(FES.GetAllFESLatestResDate)))
-	authenticated.HandleFunc("/api/v1/fes/getAllFESLatestRisk", FES.GetLatestUserRiskLevel).Methods("GET").Handler(authenticateMiddleware([]string{"Admin"})(http.HandlerFunc(FES.GetLatestUserRiskLevel)))
-	authenticated.HandleFunc("/api/v1/fes/getLastAssessment", FES.GetLastAssessment).Methods("GET").Handler(authenticateMiddleware([]string{"User"})(http.HandlerFunc(FES.GetLastAssessment)))
+	db, err := FES.OpenDB(cfg.DBConnection)
+	if err != nil {
+		slog.Error("Error opening FES database", "error", err)
+		os.Exit(1)
+	}
 
+	// Created after the configuration is loaded, which may set the service URLs
+	userClient := user.New()
+	fes := FES.NewHandler(FES.NewMySQLStore(db), userClient)
 
-	// Speech generation endpoint
-	//authenticated.HandleFunc("/api/v1/readQuestion", openAI.ReadQuestion).Methods("POST")
+	// Ready while the database and the user service are reachable
+	health := observability.NewHealth()
+	health.AddCheck("database", observability.PingDB(db))
+	health.AddCheck(sharedconfig.UserService, client.New(sharedconfig.UserService).Ping)
 
-	// Add CORS support
-	corsHandler := handlers.CORS(
-		handlers.AllowedOrigins([]string{"http://18.143.103.158:5300"}),         // Update for allowed origins
-		handlers.AllowedMethods([]string{"GET", "POST", "PUT", "OPTIONS"}), // Update for allowed HTTP methods
-		handlers.AllowedHeaders([]string{"Content-Type", "Authorization"}), // Include Authorization header
-	)(router)
+	// Route the endpoints through their middleware
+	router := server.NewRouter(fes, userClient, health)
 
-	// Start the server
-	log.Println("fallsEfficacyScale Microservice is running on port 5300...")
-	log.Fatal(http.ListenAndServe(":5300", corsHandler))
+	// Serve until SIGTERM, then drain the requests in flight and the risk observations they started,
+	// close the database and flush the spans
+	slog.Info("fallsEfficacyScale Microservice is running", "port", cfg.Port)
+	if err := graceful.Serve(fmt.Sprintf(":%d", cfg.Port), router, fes.Drain, graceful.CloseFunc(db.Close), shutdownTracing); err != nil {
+		slog.Error("Server stopped", "error", err)
+		os.Exit(1)
+	}
 }
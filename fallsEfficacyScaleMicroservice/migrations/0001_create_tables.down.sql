@@ -0,0 +1,5 @@
+-- Drops the tables of FallSafe_FallsEfficacyScaleDB
+
+DROP TABLE IF EXISTS UserResponseDetails;
+DROP TABLE IF EXISTS UserResponse;
+DROP TABLE IF EXISTS FallsEfficacyScale;
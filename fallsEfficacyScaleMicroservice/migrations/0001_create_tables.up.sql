@@ -0,0 +1,27 @@
+-- Creates the tables of FallSafe_FallsEfficacyScaleDB
+
+-- Create the FallsEfficacyScale table
+-- PURPOSE: Stores questions for the Falls Efficacy Scale
+CREATE TABLE FallsEfficacyScale (
+    question_id SMALLINT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT, -- Unique ID for the question
+    question_text TEXT NOT NULL                                       -- The question text
+);
+
+-- Create the UserResponse table
+-- PURPOSE: Tracks user responses to the FallsEfficacyScale
+CREATE TABLE UserResponse (
+    response_id SMALLINT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT, -- Unique ID for the response
+    user_id SMALLINT UNSIGNED NOT NULL,                                -- Associated user ID
+    total_score SMALLINT UNSIGNED CHECK (total_score BETWEEN 16 AND 64) NOT NULL,    -- Total score across all questions
+    response_date TIMESTAMP DEFAULT CURRENT_TIMESTAMP,                 -- Date of response submission
+    INDEX idx_user_response_date (user_id, response_date)              -- Composite index
+);
+
+-- Create the UserResponseDetails table
+-- PURPOSE: Tracks individual question responses for the FallsEfficacyScale
+CREATE TABLE UserResponseDetails (
+    detail_id SMALLINT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT,   -- Unique ID for the detail record
+    response_id SMALLINT UNSIGNED NOT NULL,                            -- Associated response ID
+    question_id SMALLINT UNSIGNED NOT NULL,                            -- Associated question ID
+    response_score TINYINT UNSIGNED CHECK (response_score BETWEEN 1 AND 4) NOT NULL -- User's response score
+);
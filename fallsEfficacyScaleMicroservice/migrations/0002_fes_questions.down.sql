@@ -0,0 +1,4 @@
+-- Removes the questions of the Falls Efficacy Scale, along with the answers given to them
+
+DELETE FROM UserResponseDetails WHERE question_id BETWEEN 1 AND 16;
+DELETE FROM FallsEfficacyScale WHERE question_id BETWEEN 1 AND 16;
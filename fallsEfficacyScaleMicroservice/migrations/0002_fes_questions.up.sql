@@ -0,0 +1,19 @@
+-- Adds the 16 questions of the Falls Efficacy Scale
+
+INSERT INTO FallsEfficacyScale (question_text) VALUES
+('Cleaning the house (example: sweeping, vacuuming, dusting)'),
+('Getting dressed or undressed'),
+('Preparing simple meals'),
+('Taking a bath or shower'),
+('Going to the shop'),
+('Getting in or out of a chair'),
+('Going up or down stairs'),
+('Walking around in the neighborhood'),
+('Reaching for something above your head or on the ground'),
+('Going to answer the telephone before it stops ringing'),
+('Walking on a slippery surface (e.g. wet or icy)'),
+('Visiting a friend or relative'),
+('Walking in a place with crowds'),
+('Walking on an uneven surface (e.g. rocky ground, poorly maintained pavement)'),
+('Walking up or down a slope'),
+('Going out to a social event (e.g. religious service, family gathering, or club meeting)');
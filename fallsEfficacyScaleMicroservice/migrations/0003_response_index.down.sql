@@ -0,0 +1,3 @@
+-- Removes the index of the answers by response
+
+DROP INDEX idx_response ON UserResponseDetails;
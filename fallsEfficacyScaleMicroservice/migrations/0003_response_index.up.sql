@@ -0,0 +1,3 @@
+-- Indexes the answers by response, as a senior's results read the answers of all their responses at once
+
+CREATE INDEX idx_response ON UserResponseDetails (response_id);  -- Index for looking up a response's answers
@@ -0,0 +1,10 @@
+// Package migrations holds the versioned schema migrations of the service's database, applied by
+// the migrate package. Add a schema change as the next numbered pair of up and down files.
+package migrations
+
+import "embed"
+
+// Files are the migration scripts, embedded in the service binary
+//
+//go:embed *.sql
+var Files embed.FS
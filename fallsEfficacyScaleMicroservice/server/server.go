@@ -0,0 +1,260 @@
+// Package server routes the Falls Efficacy Scale microservice's endpoints, so main and the integration
+// harness serve the same handlers behind the same middleware
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"fallsEfficacyScaleMicroservice/FES"
+	"fallsEfficacyScaleMicroservice/client/auth"
+	"fallsEfficacyScaleMicroservice/client/user"
+	"fallsEfficacyScaleMicroservice/config"
+
+	"shared/apierror"
+	"shared/client"
+	sharedconfig "shared/config"
+	"shared/logging"
+	"shared/observability"
+	"shared/pagination"
+	"shared/tracing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/gorilla/handlers"
+	"github.com/gorilla/mux"
+)
+
+// adminSessions reports whether an admin's token is still live. The authentication microservice
+// removes an admin's tokens when they are deactivated or reset.
+type adminSessions interface {
+	AdminTokenActive(ctx context.Context, token string) (bool, error)
+}
+
+// authenticator checks tokens for the routes, asking sessions about admin tokens on every request
+type authenticator struct {
+	sessions adminSessions
+}
+
+// JWT Authentication Middleware with Role Check for multiple roles
+func (a authenticator) authenticateMiddleware(allowedRoles []string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Get the Authorization header
+			authHeader := r.Header.Get("Authorization")
+			if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+				return
+			}
+
+			// Extract token
+			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+
+			// Get the JWT secret from the configuration
+			secretKey := config.Current().JWTSecret
+			if secretKey == "" {
+				slog.ErrorContext(r.Context(), "JWT_SECRET is not set")
+				apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+				return
+			}
+
+			// Parse and validate the token
+			token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+					return nil, http.ErrAbortHandler
+				}
+				return []byte(secretKey), nil
+			})
+
+			if err != nil || !token.Valid {
+				slog.WarnContext(r.Context(), "Invalid JWT token", "error", err)
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+				return
+			}
+
+			// Extract the claims from the token
+			claims, ok := token.Claims.(jwt.MapClaims)
+			if !ok {
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+				return
+			}
+
+			// Check if the role matches any of the allowed roles
+			role, ok := claims["role"].(string)
+			if !ok {
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+				return
+			}
+
+			// Check if the user's role is in the allowed roles
+			roleAllowed := false
+			for _, allowedRole := range allowedRoles {
+				if role == allowedRole {
+					roleAllowed = true
+					break
+				}
+			}
+
+			if !roleAllowed {
+				apierror.Write(w, r, http.StatusForbidden, apierror.Forbidden, "Forbidden")
+				return
+			}
+
+			// Admin tokens stop working as soon as they are revoked, rather than when they expire
+			if role == "Admin" {
+				active, err := a.sessions.AdminTokenActive(r.Context(), tokenString)
+				if err != nil {
+					client.WriteError(w, r, err)
+					return
+				}
+				if !active {
+					slog.WarnContext(r.Context(), "Admin token revoked")
+					apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+					return
+				}
+			}
+
+			// Proceed to the next handler if role matches, keeping the claims for permission checks
+			ctx := context.WithValue(r.Context(), FES.ClaimsContextKey, claims)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Permission Middleware for admin tokens, must run after authenticateMiddleware.
+// Senior tokens carry no permissions and are only gated by their role.
+func requirePermission(permission string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := r.Context().Value(FES.ClaimsContextKey).(jwt.MapClaims)
+			if !ok {
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+				return
+			}
+
+			if role, _ := claims["role"].(string); role != "Admin" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Check if the admin's role grants the permission
+			permissions, _ := claims["permissions"].([]interface{})
+			for _, granted := range permissions {
+				if granted == permission {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			slog.WarnContext(r.Context(), "Admin token lacks permission", "permission", permission)
+			apierror.Write(w, r, http.StatusForbidden, apierror.Forbidden, "Forbidden")
+		})
+	}
+}
+
+// Consent Middleware for senior and caregiver tokens, must run after authenticateMiddleware.
+// Seniors may only read their own data, and caregivers a senior's data if that senior granted them the scope.
+func requireSeniorConsent(userClient *user.Client, scope string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := r.Context().Value(FES.ClaimsContextKey).(jwt.MapClaims)
+			if !ok {
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+				return
+			}
+
+			role, _ := claims["role"].(string)
+			if role != "User" && role != "Caregiver" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			seniorID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+			if err != nil {
+				apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "user_id is required")
+				return
+			}
+
+			// A senior's token is only good for their own data
+			tokenUserID, _ := claims["user_id"].(float64)
+			if role == "User" {
+				if int(tokenUserID) != seniorID {
+					slog.WarnContext(r.Context(), "Senior token used for another senior", "user_id", seniorID)
+					apierror.Write(w, r, http.StatusForbidden, apierror.Forbidden, "Forbidden")
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Ask the user microservice, which owns the consent records
+			granted, err := userClient.CaregiverAccess(r.Context(), int(tokenUserID), seniorID, scope)
+			if err != nil {
+				client.WriteError(w, r, err)
+				return
+			}
+			if !granted {
+				apierror.Write(w, r, http.StatusForbidden, apierror.Forbidden, "Forbidden")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// NewRouter returns the Falls Efficacy Scale endpoints of Routes, with CORS for the frontend and request logging
+func NewRouter(fes *FES.Handler, userClient *user.Client, health *observability.Health) http.Handler {
+	// Add CORS support, inside the middleware that tags every request with an ID and logs it
+	return logging.Middleware(handlers.CORS(
+		handlers.AllowedOrigins(sharedconfig.AllowedOrigins()),
+		handlers.AllowedMethods([]string{"GET", "POST", "PUT", "OPTIONS"}),                                                   // Update for allowed HTTP methods
+		handlers.AllowedHeaders([]string{"Content-Type", "Authorization"}),                                                   // Include Authorization header
+		handlers.ExposedHeaders([]string{pagination.TotalCountHeader, pagination.NextCursorHeader, logging.RequestIDHeader}), // Let the frontend page through lists and report request IDs
+	)(Routes(fes, userClient, health)))
+}
+
+// Routes returns the Falls Efficacy Scale endpoints behind their authentication middleware, alongside the probe and
+// metrics endpoints of health. Every /api/v1 route is described in the service's OpenAPI spec, in the
+// api package, which the integration harness checks against these routes.
+func Routes(fes *FES.Handler, userClient *user.Client, health *observability.Health) *mux.Router {
+	// Initialize the router, tracing and timing every request by its route
+	router := mux.NewRouter()
+	router.Use(tracing.Middleware(sharedconfig.FallsEfficacyService), observability.Middleware)
+
+	// Unknown routes and methods get the same JSON errors as the endpoints
+	router.NotFoundHandler = apierror.NotFoundHandler()
+	router.MethodNotAllowedHandler = apierror.MethodNotAllowedHandler()
+
+	// Liveness, readiness and metrics, served without authentication
+	health.RegisterRoutes(router)
+
+	// Tokens are checked here, asking the authentication microservice about admin tokens on every request
+	authenticateMiddleware := authenticator{sessions: auth.New()}.authenticateMiddleware
+
+	// JWT Authentication Logic
+	authenticated := router.NewRoute().Subrouter()
+
+	// Protected APIs
+	authenticated.Handle("/api/v1/questions", authenticateMiddleware([]string{"User"})(http.HandlerFunc(fes.GetQuestions))).Methods("GET")
+	authenticated.Handle("/api/v1/saveResponses", authenticateMiddleware([]string{"User"})(http.HandlerFunc(fes.SaveResponse))).Methods("POST")
+	authenticated.Handle("/api/v1/fes/getAllResponses", authenticateMiddleware([]string{"Admin"})(requirePermission("clinical:read")(http.HandlerFunc(fes.GetAllUserResponse)))).Methods("GET")
+	authenticated.Handle("/api/v1/fes/getAllIndividualRes", authenticateMiddleware([]string{"Admin"})(requirePermission("clinical:read")(http.HandlerFunc(fes.GetAllFESIndividualRes)))).Methods("GET")
+	authenticated.Handle("/api/v1/fes/getFESResults", authenticateMiddleware([]string{"User", "Caregiver"})(requireSeniorConsent(userClient, "results")(http.HandlerFunc(fes.GetUserFESResults)))).Methods("GET")
+	authenticated.Handle("/api/v1/fes/getAllFESLastResDay", authenticateMiddleware([]string{"Admin"})(requirePermission("seniors:read")(http.HandlerFunc(fes.GetAllFESLatestResDate)))).Methods("GET")
+	authenticated.Handle("/api/v1/fes/getAllFESLatestScore", authenticateMiddleware([]string{"Admin"})(requirePermission("clinical:read")(http.HandlerFunc(fes.GetAllFESLatestScore)))).Methods("GET")
+	authenticated.Handle("/api/v1/fes/getAllFESLatestRisk", authenticateMiddleware([]string{"Admin"})(requirePermission("clinical:read")(http.HandlerFunc(fes.GetLatestUserRiskLevel)))).Methods("GET")
+	authenticated.Handle("/api/v1/fes/getLastAssessment", authenticateMiddleware([]string{"User", "Caregiver"})(requireSeniorConsent(userClient, "reminders")(http.HandlerFunc(fes.GetLastAssessment)))).Methods("GET")
+	authenticated.Handle("/api/v1/fes/trends", authenticateMiddleware([]string{"Admin"})(requirePermission("clinical:read")(http.HandlerFunc(fes.GetFESTrends)))).Methods("GET")
+	authenticated.Handle("/api/v1/fes/trends/declining", authenticateMiddleware([]string{"Admin"})(requirePermission("clinical:read")(http.HandlerFunc(fes.GetDecliningFESTrends)))).Methods("GET")
+	authenticated.Handle("/api/v1/fes/analytics/riskDistribution", authenticateMiddleware([]string{"Admin"})(requirePermission("clinical:read")(http.HandlerFunc(fes.GetFESRiskDistribution)))).Methods("GET")
+	authenticated.Handle("/api/v1/fes/analytics/items", authenticateMiddleware([]string{"Admin"})(requirePermission("clinical:read")(http.HandlerFunc(fes.GetFESItemBreakdown)))).Methods("GET")
+	authenticated.Handle("/api/v1/fes/analytics/userScoreTotals", authenticateMiddleware([]string{"Admin"})(requirePermission("clinical:read")(http.HandlerFunc(fes.GetFESUserScoreTotals)))).Methods("GET")
+
+	// Speech generation endpoint
+	//authenticated.HandleFunc("/api/v1/readQuestion", openAI.ReadQuestion).Methods("POST")
+
+	return router
+}
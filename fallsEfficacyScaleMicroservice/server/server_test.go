@@ -0,0 +1,173 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"fallsEfficacyScaleMicroservice/FES"
+	"fallsEfficacyScaleMicroservice/FES/memstore"
+	"fallsEfficacyScaleMicroservice/client/user"
+	"fallsEfficacyScaleMicroservice/config"
+
+	"shared/observability"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// testService serves the FES routes from an in-memory store
+type testService struct {
+	store  *memstore.Store
+	risk   *memstore.RiskRecorder
+	fes    *FES.Handler
+	routes http.Handler
+}
+
+func newTestService(t *testing.T) *testService {
+	t.Helper()
+	config.Current().JWTSecret = "test-secret"
+
+	s := &testService{store: memstore.New(), risk: &memstore.RiskRecorder{}}
+	for i := 1; i <= 16; i++ {
+		s.store.AddQuestion(fmt.Sprintf("Question %d", i))
+	}
+	s.fes = FES.NewHandler(s.store, s.risk)
+	s.routes = Routes(s.fes, user.New(), observability.NewHealth())
+	return s
+}
+
+// serve sends a request to the routes, with a token for claims unless claims is nil
+func (s *testService) serve(t *testing.T, method, path string, claims jwt.MapClaims, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if claims != nil {
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(config.Current().JWTSecret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.routes.ServeHTTP(w, r)
+	return w
+}
+
+// senior returns the claims of a senior's token
+func senior(userID int) jwt.MapClaims {
+	return jwt.MapClaims{"user_id": userID, "role": "User"}
+}
+
+// answers returns a saveResponses body answering every question with score
+func answers(userID, score int) string {
+	var responses []string
+	for i := 1; i <= 16; i++ {
+		responses = append(responses, fmt.Sprintf(`{"question_id":%d,"score":%d}`, i, score))
+	}
+	return fmt.Sprintf(`{"user_id":%d,"responses":[%s]}`, userID, strings.Join(responses, ","))
+}
+
+func TestSaveResponse(t *testing.T) {
+	s := newTestService(t)
+
+	if w := s.serve(t, "POST", "/api/v1/saveResponses", senior(7), answers(7, 2)); w.Code != http.StatusOK {
+		t.Fatalf("saveResponses = %d %s", w.Code, w.Body)
+	}
+
+	w := s.serve(t, "GET", "/api/v1/fes/getFESResults?user_id=7", senior(7), "")
+	var results []FES.UserResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("getFESResults = %d %s", w.Code, w.Body)
+	}
+	if len(results) != 1 || results[0].TotalScore != 32 || len(results[0].ResponseDetails) != 16 {
+		t.Errorf("getFESResults = %+v, want one response scoring 32 with 16 answers", results)
+	}
+
+	// The score reaches the combined risk model once the observations are drained
+	if err := s.fes.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := []user.RiskObservation{{UserID: 7, Source: user.SourceFES, SourceID: int(results[0].ResponseID), Score: 32}}
+	if got := s.risk.Observations(); !reflect.DeepEqual(got, want) {
+		t.Errorf("risk observations = %+v, want %+v", got, want)
+	}
+}
+
+func TestSeniorTokensOnlyReachTheirOwnResults(t *testing.T) {
+	s := newTestService(t)
+	if _, err := s.store.AddResponse(8, time.Now(), 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, method, path string
+		body               string
+	}{
+		{"save for another senior", "POST", "/api/v1/saveResponses", answers(8, 2)},
+		{"read another senior's results", "GET", "/api/v1/fes/getFESResults?user_id=8", ""},
+		{"read another senior's last assessment", "GET", "/api/v1/fes/getLastAssessment?user_id=8", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := s.serve(t, tt.method, tt.path, senior(7), tt.body); w.Code != http.StatusForbidden {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusForbidden)
+			}
+		})
+	}
+
+	// Nothing was saved for the other senior
+	if got, _ := s.store.UserResponses(context.Background(), 8); len(got) != 1 {
+		t.Errorf("senior 8 has %d responses, want 1", len(got))
+	}
+}
+
+func TestRoutesCheckTheRole(t *testing.T) {
+	s := newTestService(t)
+
+	tests := []struct {
+		name   string
+		path   string
+		claims jwt.MapClaims
+		want   int
+	}{
+		{"no token", "/api/v1/questions", nil, http.StatusUnauthorized},
+		{"caregiver on a senior route", "/api/v1/questions", jwt.MapClaims{"user_id": 3, "role": "Caregiver"}, http.StatusForbidden},
+		{"senior on an admin route", "/api/v1/fes/getAllResponses", senior(7), http.StatusForbidden},
+		{"service on an admin route", "/api/v1/fes/trends", jwt.MapClaims{"role": "Service"}, http.StatusForbidden},
+		{"senior", "/api/v1/questions", senior(7), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := s.serve(t, "GET", tt.path, tt.claims, ""); w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetLastAssessment(t *testing.T) {
+	s := newTestService(t)
+
+	if w := s.serve(t, "GET", "/api/v1/fes/getLastAssessment?user_id=7", senior(7), ""); w.Code != http.StatusNotFound {
+		t.Errorf("before any response: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	day := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	s.store.AddResponse(7, day, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2)
+	s.store.AddResponse(7, day.AddDate(0, 0, 7), 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3)
+
+	w := s.serve(t, "GET", "/api/v1/fes/getLastAssessment?user_id=7", senior(7), "")
+	var last FES.LastAssessment
+	if err := json.Unmarshal(w.Body.Bytes(), &last); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("getLastAssessment = %d %s", w.Code, w.Body)
+	}
+	if last.TotalScore != 48 || !last.ResponseDate.Equal(day.AddDate(0, 0, 7)) {
+		t.Errorf("getLastAssessment = %+v, want the response of %s scoring 48", last, day.AddDate(0, 0, 7))
+	}
+}
@@ -21,23 +21,17 @@ let dashboardData = {
 async function fetchUsersFromAPI() {
   try {
     console.log(token);
-    const response = await fetch(
-      `http://18.143.103.158:5200/api/v1/admin/getAllElderlyUser`,
+    return await fetchAllPages(
+      `http://18.143.103.158:5000/api/v1/admin/getAllElderlyUser`,
       {
         method: "GET",
         headers: {
           "Content-Type": "application/json",
           Authorization: `Bearer ${token}`,
         },
-      }
+      },
+      "Failed to fetch users"
     );
-
-    if (!response.ok) {
-      const errorDetails = await response.text();
-      throw new Error(`Error: ${errorDetails || "Failed to fetch users"}`);
-    }
-
-    return await response.json();
   } catch (error) {
     console.error("Error fetching users:", error.message);
     showCustomAlert("Error fetching user's list");
@@ -48,25 +42,17 @@ async function fetchUsersFromAPI() {
 // Function to fetch user responses from API
 async function fetchUserResponseFromAPI() {
   try {
-    const response = await fetch(
-      `http://18.143.103.158:5200/api/v1/admin/getAllElderlyFESResponse`,
+    return await fetchAllPages(
+      `http://18.143.103.158:5000/api/v1/admin/getAllElderlyFESResponse`,
       {
         method: "GET",
         headers: {
           "Content-Type": "application/json",
           Authorization: `Bearer ${token}`,
         },
-      }
+      },
+      "Failed to fetch user responses"
     );
-
-    if (!response.ok) {
-      const errorDetails = await response.text();
-      throw new Error(
-        `Error: ${errorDetails || "Failed to fetch user responses"}`
-      );
-    }
-
-    return await response.json();
   } catch (error) {
     console.error("Error fetching user responses:", error.message);
     showCustomAlert("Error fetching userResponse's list");
@@ -77,25 +63,17 @@ async function fetchUserResponseFromAPI() {
 //Function to fetch user response details from API
 async function fetchUserResponseDetailsFromAPI() {
   try {
-    const response = await fetch(
-      `http://18.143.103.158:5200/api/v1/admin/getAllElderlyFESResDetails`,
+    return await fetchAllPages(
+      `http://18.143.103.158:5000/api/v1/admin/getAllElderlyFESResDetails`,
       {
         method: "GET",
         headers: {
           "Content-Type": "application/json",
           Authorization: `Bearer ${token}`,
         },
-      }
+      },
+      "Failed to fetch user responses"
     );
-
-    if (!response.ok) {
-      const errorDetails = await response.text();
-      throw new Error(
-        `Error: ${errorDetails || "Failed to fetch user responses"}`
-      );
-    }
-
-    return await response.json();
   } catch (error) {
     console.error("Error fetching user response details:", error.message);
     showCustomAlert("Error fetching userResponse's list of details");
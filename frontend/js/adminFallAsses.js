@@ -112,23 +112,17 @@ function mergeUserRiskData(userDetailsList, riskLevelsList) {
 async function fetchUsersFromAPI() {
   try {
     console.log(token);
-    const response = await fetch(
-      `http://18.143.103.158:5200/api/v1/admin/getAllElderlyUser`,
+    return await fetchAllPages(
+      `http://18.143.103.158:5000/api/v1/admin/getAllElderlyUser`,
       {
         method: "GET",
         headers: {
           "Content-Type": "application/json",
           Authorization: `Bearer ${token}`,
         },
-      }
+      },
+      "Failed to fetch users"
     );
-
-    if (!response.ok) {
-      const errorDetails = await response.text();
-      throw new Error(`Error: ${errorDetails || "Failed to fetch users"}`);
-    }
-
-    return await response.json();
   } catch (error) {
     console.error("Error fetching users:", error.message);
     showCustomAlert("Error fetching user's list");
@@ -140,25 +134,17 @@ async function fetchUsersFromAPI() {
 async function fetchFATotalScoreFromAPI() {
   try {
     console.log(token);
-    const response = await fetch(
-      `http://18.143.103.158:5200/api/v1/admin/getAllFATotalScore`,
+    return await fetchAllPages(
+      `http://18.143.103.158:5000/api/v1/admin/getAllFATotalScore`,
       {
         method: "GET",
         headers: {
           "Content-Type": "application/json",
           Authorization: `Bearer ${token}`,
         },
-      }
+      },
+      "Failed to fetch average scores"
     );
-
-    if (!response.ok) {
-      const errorDetails = await response.text();
-      throw new Error(
-        `Error: ${errorDetails || "Failed to fetch average scores"}`
-      );
-    }
-
-    return await response.json();
   } catch (error) {
     console.error("Error fetching average scores:", error.message);
     showCustomAlert("Error fetching average scores");
@@ -169,25 +155,17 @@ async function fetchFATotalScoreFromAPI() {
 // Function to fetch all user test time taken
 async function fetchFAAvgTimeFromAPI() {
   try {
-    const response = await fetch(
-      `http://18.143.103.158:5200/api/v1/admin/getAllFATime`,
+    return await fetchAllPages(
+      `http://18.143.103.158:5000/api/v1/admin/getAllFATime`,
       {
         method: "GET",
         headers: {
           "Content-Type": "application/json",
           Authorization: `Bearer ${token}`,
         },
-      }
+      },
+      "Failed to fetch test results"
     );
-
-    if (!response.ok) {
-      const errorDetails = await response.text();
-      throw new Error(
-        `Error: ${errorDetails || "Failed to fetch test results"}`
-      );
-    }
-
-    return await response.json();
   } catch (error) {
     console.error("Error fetching test results:", error.message);
     showCustomAlert("Error fetching test results");
@@ -199,7 +177,7 @@ async function fetchFAAvgTimeFromAPI() {
 async function fetchAllUserRiskFromAPI() {
   try {
     const response = await fetch(
-      `http://18.143.103.158:5200//api/v1/admin/getAllFAUserRisk`,
+      `http://18.143.103.158:5000/api/v1/admin/getAllFAUserRisk`,
       {
         method: "GET",
         headers: {
@@ -210,7 +188,7 @@ async function fetchAllUserRiskFromAPI() {
     );
 
     if (!response.ok) {
-      const errorDetails = await response.text();
+      const errorDetails = await errorMessage(response);
       throw new Error(
         `Error: ${errorDetails || "Failed to fetch user risk levels"}`
       );
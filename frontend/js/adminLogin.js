@@ -20,7 +20,7 @@ document.addEventListener("DOMContentLoaded", function () {
     }
 
     // API endpoint for user login
-    const endpoint = `http://18.143.103.158:5050/api/v1/authentication/admin/login`;
+    const endpoint = `http://18.143.103.158:5000/api/v1/authentication/admin/login`;
 
     try {
       // Send a POST request to the login endpoint
@@ -34,14 +34,18 @@ document.addEventListener("DOMContentLoaded", function () {
 
       const data = await response.json(); // Parse the response JSON
 
-      if (response.ok) {
+      if (response.ok && data.mfa_required) {
+        // Two-factor is enabled, exchange the challenge for a token
+        await completeTwoFactorLogin(data.mfa_token);
+      } else if (response.ok) {
         // If login is successful, store the JWT token
         localStorage.setItem("token", data.token);
         showCustomAlert("Login successful!", "./adminHome.html");
       } else {
         // If login fails, show an error message
         showCustomAlert(
-          data.message || "Invalid email or password. Please try again."
+          (data.error && data.error.message) ||
+            "Invalid email or password. Please try again."
         );
       }
     } catch (error) {
@@ -50,6 +54,42 @@ document.addEventListener("DOMContentLoaded", function () {
     }
   });
 
+  // Ask for an authenticator or recovery code to finish logging in
+  async function completeTwoFactorLogin(mfaToken) {
+    const input = window.prompt(
+      "Enter the 6-digit code from your authenticator app, or one of your recovery codes:"
+    );
+    if (!input) {
+      showCustomAlert("Login cancelled.");
+      return;
+    }
+
+    // Recovery codes contain letters, authenticator codes are digits only
+    const value = input.trim();
+    const body = /^[0-9 ]+$/.test(value)
+      ? { mfa_token: mfaToken, code: value }
+      : { mfa_token: mfaToken, recovery_code: value };
+
+    const response = await fetch(
+      `http://18.143.103.158:5000/api/v1/authentication/admin/login/mfa`,
+      {
+        method: "POST",
+        headers: {
+          "Content-Type": "application/json",
+        },
+        body: JSON.stringify(body),
+      }
+    );
+
+    if (response.ok) {
+      const data = await response.json();
+      localStorage.setItem("token", data.token);
+      showCustomAlert("Login successful!", "./adminHome.html");
+    } else {
+      showCustomAlert("Invalid authentication code. Please log in again.");
+    }
+  }
+
   // Function to validate email format
   function validateEmail(email) {
     const emailRegex = /^[^\s@]+@[^\s@]+\.[^\s@]+$/;
@@ -91,23 +91,17 @@ async function initializeDashboard() {
 async function fetchUsersFromAPI() {
   try {
     console.log(token);
-    const response = await fetch(
-      `http://18.143.103.158:5200/api/v1/admin/getAllElderlyUser`,
+    return await fetchAllPages(
+      `http://18.143.103.158:5000/api/v1/admin/getAllElderlyUser`,
       {
         method: "GET",
         headers: {
           "Content-Type": "application/json",
           Authorization: `Bearer ${token}`,
         },
-      }
+      },
+      "Failed to fetch users"
     );
-
-    if (!response.ok) {
-      const errorDetails = await response.text();
-      throw new Error(`Error: ${errorDetails || "Failed to fetch users"}`);
-    }
-
-    return await response.json();
   } catch (error) {
     console.error("Error fetching users:", error.message);
     showCustomAlert("Error fetching user's list");
@@ -120,7 +114,7 @@ async function fetchFESLastResponseDate() {
   try {
     // API endpoint to get the user ID and days since the last response
     const response = await fetch(
-      `http://18.143.103.158:5200/api/v1/admin/getAllLastResFES`,
+      `http://18.143.103.158:5000/api/v1/admin/getAllLastResFES`,
       {
         method: "GET",
         headers: {
@@ -132,7 +126,7 @@ async function fetchFESLastResponseDate() {
 
     // Check if the response is successful
     if (!response.ok) {
-      const errorDetails = await response.text();
+      const errorDetails = await errorMessage(response);
       throw new Error(
         `Error: ${errorDetails || "Failed to fetch last response date"}`
       );
@@ -153,7 +147,7 @@ async function fetchFallAssessmentLastResponseDate() {
   try {
     // API endpoint to get the user ID and days since the last fall assessment response
     const response = await fetch(
-      `http://18.143.103.158:5200/api/v1/admin/getAllLastResFA`,
+      `http://18.143.103.158:5000/api/v1/admin/getAllLastResFA`,
       {
         method: "GET",
         headers: {
@@ -165,7 +159,7 @@ async function fetchFallAssessmentLastResponseDate() {
 
     // Check if the response is successful
     if (!response.ok) {
-      const errorDetails = await response.text();
+      const errorDetails = await errorMessage(response);
       throw new Error(
         `Error: ${
           errorDetails || "Failed to fetch last fall assessment response date"
@@ -190,7 +184,7 @@ async function fetchFallAssessmentLastResponseDate() {
 async function fetchLatestFAUserRiskFromAPI() {
   try {
     const response = await fetch(
-      `http://18.143.103.158:5250/api/v1/selfAssessment/getAllUserRisk`,
+      `http://18.143.103.158:5000/api/v1/selfAssessment/getAllUserRisk`,
       {
         method: "GET",
         headers: {
@@ -201,7 +195,7 @@ async function fetchLatestFAUserRiskFromAPI() {
     );
 
     if (!response.ok) {
-      const errorDetails = await response.text();
+      const errorDetails = await errorMessage(response);
       throw new Error(
         `Error: ${errorDetails || "Failed to fetch user risk levels"}`
       );
@@ -219,7 +213,7 @@ async function fetchLatestFAUserRiskFromAPI() {
 async function fetchLatestFESUserRiskFromAPI() {
   try {
     const response = await fetch(
-      `http://18.143.103.158:5200/api/v1/admin/getAllFESUserRisk`,
+      `http://18.143.103.158:5000/api/v1/admin/getAllFESUserRisk`,
       {
         method: "GET",
         headers: {
@@ -231,7 +225,7 @@ async function fetchLatestFESUserRiskFromAPI() {
 
     // Check if the response is successful (status code 200)
     if (!response.ok) {
-      const errorDetails = await response.text();
+      const errorDetails = await errorMessage(response);
       throw new Error(
         `Error: ${errorDetails || "Failed to fetch user risk levels"}`
       );
@@ -648,7 +642,7 @@ function confirmSendEmail(button) {
   console.log(requestBody);
 
   // Send the data to the backend
-  fetch(`http://18.143.103.158:5200/api/v1/admin/sendEmailAssesRemind`, {
+  fetch(`http://18.143.103.158:5000/api/v1/admin/sendEmailAssesRemind`, {
     method: "POST",
     headers: {
       "Content-Type": "application/json",
@@ -683,7 +677,7 @@ async function sendEmail(button) {
   }
   try {
     const response = await fetch(
-      `http://18.143.103.158:5200/api/v1/admin/sendEmailAssesRemind`,
+      `http://18.143.103.158:5000/api/v1/admin/sendEmailAssesRemind`,
       {
         method: "POST",
         headers: {
@@ -695,7 +689,7 @@ async function sendEmail(button) {
     );
 
     if (!response.ok) {
-      const errorDetails = await response.text();
+      const errorDetails = await errorMessage(response);
       throw new Error(`Failed to send email: ${errorDetails}`);
     }
 
@@ -20,7 +20,7 @@ document.addEventListener("DOMContentLoaded", function () {
     }
 
     // API endpoint for user login
-    const endpoint = `http://18.143.103.158:5050/api/v1/authentication/user/login`;
+    const endpoint = `http://18.143.103.158:5000/api/v1/authentication/user/login`;
 
     try {
       // Send a POST request to the login endpoint
@@ -41,7 +41,8 @@ document.addEventListener("DOMContentLoaded", function () {
       } else {
         // If login fails, show an error message
         showCustomAlert(
-          data.message || "Invalid email or password. Please try again."
+          (data.error && data.error.message) ||
+            "Invalid email or password. Please try again."
         );
       }
     } catch (error) {
@@ -50,6 +51,106 @@ document.addEventListener("DOMContentLoaded", function () {
     }
   });
 
+  const authBase = `http://18.143.103.158:5000/api/v1/authentication/user`;
+
+  // Store the JWT token and continue to the home page
+  function completeLogin(data) {
+    localStorage.setItem("token", data.token);
+    showCustomAlert("Login successful!", "./userHome.html");
+  }
+
+  // Show only the login options enabled for this deployment
+  fetch(`${authBase}/login-methods`)
+    .then((response) => response.json())
+    .then((data) => {
+      const methods = data.methods || [];
+      if (!methods.includes("password")) {
+        document.getElementById("passwordGroup").classList.add("d-none");
+        document.getElementById("password").required = false;
+        document.getElementById("passwordLoginBtn").classList.add("d-none");
+      }
+      if (methods.includes("code") || methods.includes("magic_link")) {
+        document.getElementById("emailCodeBtn").classList.remove("d-none");
+      }
+    })
+    .catch((error) => console.error("Error fetching login methods:", error));
+
+  // Request a one-time code and/or magic link by email
+  document.getElementById("emailCodeBtn").addEventListener("click", async () => {
+    const email = document.getElementById("email").value.trim();
+    if (!validateEmail(email)) {
+      showCustomAlert("Please enter a valid email address.");
+      return;
+    }
+
+    try {
+      const response = await fetch(`${authBase}/login/request-code`, {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify({ email }),
+      });
+
+      if (response.ok) {
+        document.getElementById("codeGroup").classList.remove("d-none");
+        showCustomAlert("Please check your email to log in.");
+      } else if (response.status === 429) {
+        showCustomAlert("Please wait a moment before requesting another code.");
+      } else {
+        showCustomAlert("Unable to send a login code. Please try again.");
+      }
+    } catch (error) {
+      console.error("Error requesting login code:", error);
+      showCustomAlert("An error occurred while sending the code. Please try again.");
+    }
+  });
+
+  // Exchange the emailed code for a token
+  document.getElementById("verifyCodeBtn").addEventListener("click", async () => {
+    const email = document.getElementById("email").value.trim();
+    const code = document.getElementById("loginCode").value.trim();
+
+    try {
+      const response = await fetch(`${authBase}/login/verify-code`, {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify({ email, code }),
+      });
+
+      if (response.ok) {
+        completeLogin(await response.json());
+      } else {
+        showCustomAlert("Invalid or expired code. Please try again.");
+      }
+    } catch (error) {
+      console.error("Error verifying login code:", error);
+      showCustomAlert("An error occurred while logging in. Please try again.");
+    }
+  });
+
+  // Log in straight away when arriving from an emailed magic link
+  const magicToken = new URLSearchParams(window.location.search).get("magic_token");
+  if (magicToken) {
+    // Remove the token from the address bar so it is not bookmarked or shared
+    window.history.replaceState({}, document.title, window.location.pathname);
+
+    fetch(`${authBase}/login/magic-link`, {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ token: magicToken }),
+    })
+      .then(async (response) => {
+        if (response.ok) {
+          completeLogin(await response.json());
+        } else {
+          showCustomAlert("This login link is invalid or has expired. Please request a new one.");
+        }
+      })
+      .catch((error) => {
+        console.error("Error during magic link login:", error);
+        showCustomAlert("An error occurred while logging in. Please try again.");
+      });
+  }
+
   // Function to validate email format
   function validateEmail(email) {
     const emailRegex = /^[^\s@]+@[^\s@]+\.[^\s@]+$/;
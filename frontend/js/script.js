@@ -99,3 +99,14 @@ function attachSignOutListener() {
     console.error("Error: Sign-out button not found!");
   }
 }
+
+// Read the message of an error response, which the services send as
+// {"error": {"code": "...", "message": "...", "request_id": "..."}}
+async function errorMessage(response) {
+  try {
+    const body = await response.json();
+    return (body.error && body.error.message) || "";
+  } catch (error) {
+    return "";
+  }
+}
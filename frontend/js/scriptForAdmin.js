@@ -59,4 +59,38 @@ document.addEventListener("DOMContentLoaded", () => {
       }, displayDuration);
     };
   });
-  
\ No newline at end of file
+  
+// Fetch every page of an admin list endpoint, following the X-Next-Cursor header.
+// Throws an Error with the error message, or fallbackMessage, if a page fails.
+async function fetchAllPages(url, options, fallbackMessage) {
+  const rows = [];
+  let cursor = "";
+  do {
+    const pageUrl = new URL(url);
+    pageUrl.searchParams.set("limit", "1000");
+    if (cursor) {
+      pageUrl.searchParams.set("cursor", cursor);
+    }
+
+    const response = await fetch(pageUrl, options);
+    if (!response.ok) {
+      const errorDetails = await errorMessage(response);
+      throw new Error(`Error: ${errorDetails || fallbackMessage}`);
+    }
+
+    rows.push(...(await response.json()));
+    cursor = response.headers.get("X-Next-Cursor");
+  } while (cursor);
+  return rows;
+}
+
+// Read the message of an error response, which the services send as
+// {"error": {"code": "...", "message": "...", "request_id": "..."}}
+async function errorMessage(response) {
+  try {
+    const body = await response.json();
+    return (body.error && body.error.message) || "";
+  } catch (error) {
+    return "";
+  }
+}
@@ -18,7 +18,7 @@ document.addEventListener("DOMContentLoaded", function () {
     sendCodeButton.disabled = true;
 
     // Construct the endpoint dynamically using window.location.origin
-    const endpoint = `http://18.143.103.158:5050/api/v1/authentication/send-verification`;
+    const endpoint = `http://18.143.103.158:5000/api/v1/authentication/send-verification`;
 
     // Send a POST request to the backend API
     fetch(endpoint, {
@@ -70,7 +70,7 @@ document.addEventListener("DOMContentLoaded", function () {
     const address = document.getElementById("address").value;
 
     const response = await fetch(
-      `http://18.143.103.158:5050/api/v1/authentication/register-user`,
+      `http://18.143.103.158:5000/api/v1/authentication/register-user`,
       {
         method: "POST",
         headers: {
@@ -92,7 +92,7 @@ document.addEventListener("DOMContentLoaded", function () {
     if (response.ok) {
       showCustomAlert(data.message, "login.html");
     } else {
-      showCustomAlert(`Error: ${data.message || "Failed to register"}`);
+      showCustomAlert(`Error: ${(data.error && data.error.message) || "Failed to register"}`);
     }
   });
 });
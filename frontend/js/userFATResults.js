@@ -11,7 +11,7 @@ document.addEventListener("DOMContentLoaded", async function () {
   try {
     const user_id = decodeToken(token).user_id;
     const testResults = await fetchData(
-      `http://18.143.103.158:5100/api/v1/user/getAUserTestResults?user_id=${user_id}`
+      `http://18.143.103.158:5000/api/v1/user/getAUserTestResults?user_id=${user_id}`
     );
 
     if (!testResults) {
@@ -108,7 +108,7 @@ function calculateOverallRisk(testResults) {
   console.log("Latest test selected:", latestTest);
 
   // Extract risk score from the latest test
-  const testRiskScore = latestTest.total_score?.Int64 ?? 0;
+  const testRiskScore = latestTest.total_score ?? 0;
 
   console.log("Test risk score:", testRiskScore);
 
@@ -163,7 +163,7 @@ function generateSATotalScoreChart(data) {
   const labels = data.map((session) =>
     new Date(session.session_date).toLocaleDateString()
   );
-  const totalScores = data.map((session) => session.total_score.Int64);
+  const totalScores = data.map((session) => session.total_score ?? 0);
 
   new Chart(document.getElementById("FATestTotalSAScore"), {
     type: "line",
@@ -356,7 +356,7 @@ function createFATGaugeChart(canvasId, title, value, level) {
 
 // 📊 Calculate FAT Risk Score
 function calculateFATRiskScore(session) {
-  const totalScore = session.total_score.Int64;
+  const totalScore = session.total_score ?? 0;
   const percentage = (totalScore / 100) * 100;
 
   return {
@@ -369,7 +369,7 @@ function generateTotalScoreChart(data) {
   const labels = data.map((session) =>
     new Date(session.session_date).toLocaleDateString()
   );
-  const totalScores = data.map((session) => session.total_score.Int64);
+  const totalScores = data.map((session) => session.total_score ?? 0);
 
   new Chart(document.getElementById("FATestTotalScore"), {
     type: "line",
@@ -11,7 +11,7 @@ document.addEventListener("DOMContentLoaded", async function () {
     const user_id = decodeToken(token).user_id;
     const [fesResults] = await Promise.all([
       fetchData(
-        `http://18.143.103.158:5100/api/v1/user/getAUserFESResults?user_id=${user_id}`
+        `http://18.143.103.158:5000/api/v1/user/getAUserFESResults?user_id=${user_id}`
       ),
     ]);
 
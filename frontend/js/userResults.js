@@ -12,10 +12,10 @@ document.addEventListener("DOMContentLoaded", async function () {
     const user_id = decodeToken(token).user_id;
     const [fesResults, testResults] = await Promise.all([
       fetchData(
-        `http://18.143.103.158:5100/api/v1/user/getAUserFESResults?user_id=${user_id}`
+        `http://18.143.103.158:5000/api/v1/user/getAUserFESResults?user_id=${user_id}`
       ),
       fetchData(
-        `http://18.143.103.158:5100/api/v1/user/getAUserTestResults?user_id=${user_id}`
+        `http://18.143.103.158:5000/api/v1/user/getAUserTestResults?user_id=${user_id}`
       ),
     ]);
 
@@ -149,7 +149,7 @@ function calculateOverallRisk(fesResults, testResults) {
   const fesScore = ((latestFES.total_score - 16) / 64) * 100;
 
   // Convert risk level into a score
-  const testRiskScore = latestTest.total_score.Int64; // Convert to percentage
+  const testRiskScore = latestTest.total_score ?? 0; // Convert to percentage
 
   // Calculate overall risk percentage (weighted average)
   const overallRiskPercentage = (fesScore + testRiskScore) / 2;
@@ -601,7 +601,7 @@ function generateSATotalScoreChart(data) {
   const labels = data.map((session) =>
     new Date(session.session_date).toLocaleDateString()
   );
-  const totalScores = data.map((session) => session.total_score.Int64);
+  const totalScores = data.map((session) => session.total_score ?? 0);
 
   new Chart(document.getElementById("FATestTotalSAScore"), {
     type: "line",
@@ -794,7 +794,7 @@ function createFATGaugeChart(canvasId, title, value, level) {
 
 // 📊 Calculate FAT Risk Score
 function calculateFATRiskScore(session) {
-  const totalScore = session.total_score.Int64;
+  const totalScore = session.total_score ?? 0;
   const percentage = (totalScore / 100) * 100;
 
   return {
@@ -814,7 +814,7 @@ function generateTotalScoreChart(data) {
   const labels = data.map((session) =>
     new Date(session.session_date).toLocaleDateString()
   );
-  const totalScores = data.map((session) => session.total_score.Int64);
+  const totalScores = data.map((session) => session.total_score ?? 0);
 
   new Chart(document.getElementById("FATestTotalScore"), {
     type: "line",
@@ -0,0 +1,20 @@
+package config
+
+import sharedconfig "shared/config"
+
+// loader reads the configuration
+var loader sharedconfig.Loader[Config, *Config]
+
+// Load reads the configuration from the command-line arguments, the environment, secret files and
+// the config file, and makes it the one Current returns. It reports every missing or invalid
+// setting at once, alongside the configuration read.
+func Load(args []string) (*Config, error) {
+	return loader.Load(args)
+}
+
+// Current returns the configuration Load read. Programs that do not call Load, such as the
+// integration harness, get the configuration read from the environment and the config file on first
+// use, without validation.
+func Current() *Config {
+	return loader.Current()
+}
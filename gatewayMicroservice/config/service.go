@@ -0,0 +1,17 @@
+package config
+
+import "shared/settings"
+
+// Config is the gateway's configuration
+type Config struct {
+	settings.Common
+	Port        int `env:"PORT" default:"5000" usage:"port the gateway listens on"`
+	MetricsPort int `env:"METRICS_PORT" default:"5001" usage:"port the gateway serves its metrics on, which must not be reachable from the internet"`
+	RateLimit   RateLimit
+}
+
+// RateLimit is how often each client may call the services through the gateway
+type RateLimit struct {
+	PerMinute int `env:"RATE_LIMIT_PER_MINUTE" default:"300" usage:"requests a client may make each minute on average, or 0 for no limit"`
+	Burst     int `env:"RATE_LIMIT_BURST" default:"60" usage:"requests a client may make at once"`
+}
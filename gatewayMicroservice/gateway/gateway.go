@@ -0,0 +1,185 @@
+// Package gateway fronts the FallSafe services on one port. It sends each /api/v1 route to the
+// service that serves it, checks tokens once for the routes that need them, limits how often each
+// client calls, and hides the routes only the services call each other on. The services still check
+// the token's role and permissions themselves, as they are reachable inside the cluster.
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+
+	"gatewayMicroservice/config"
+
+	"shared/apierror"
+	sharedconfig "shared/config"
+	"shared/logging"
+	"shared/observability"
+	"shared/tracing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// requestsRejected counts the requests the gateway answered itself rather than sending on
+var requestsRejected = promauto.With(observability.Registry).NewCounterVec(prometheus.CounterOpts{
+	Name: "gateway_requests_rejected_total",
+	Help: "Requests the gateway refused without calling a service, by reason.",
+}, []string{"reason"})
+
+// Gateway sends the requests for its routes to the services behind it
+type Gateway struct {
+	limiter *Limiter
+	proxies map[string]*httputil.ReverseProxy
+}
+
+// New returns a Gateway for the services of Routes, at the URLs their configuration names, limiting
+// each client's requests with limiter
+func New(limiter *Limiter) *Gateway {
+	g := &Gateway{limiter: limiter, proxies: map[string]*httputil.ReverseProxy{}}
+	for _, route := range Routes {
+		if _, ok := g.proxies[route.Service]; !ok {
+			g.proxies[route.Service] = newProxy(route.Service)
+		}
+	}
+	return g
+}
+
+// newProxy returns a reverse proxy to a service. WebSocket upgrades are passed through as they are.
+func newProxy(service string) *httputil.ReverseProxy {
+	target, err := url.Parse(sharedconfig.ServiceURL(service))
+	if err != nil {
+		panic(fmt.Sprintf("invalid URL for %s: %v", service, err))
+	}
+	return &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(target)
+			r.SetXForwarded()
+			r.Out.Header.Set(logging.RequestIDHeader, logging.RequestID(r.In.Context()))
+		},
+		Transport: tracing.Transport(http.DefaultTransport),
+		// The gateway answers CORS and tags the response with the request ID itself, and
+		// browsers reject the headers twice over
+		ModifyResponse: func(resp *http.Response) error {
+			for name := range resp.Header {
+				if strings.HasPrefix(name, "Access-Control-") {
+					resp.Header.Del(name)
+				}
+			}
+			resp.Header.Del(logging.RequestIDHeader)
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			if errors.Is(err, context.Canceled) {
+				slog.DebugContext(r.Context(), "Client went away before the service answered", "service", service)
+				return
+			}
+			slog.ErrorContext(r.Context(), "Error calling service", "service", service, "error", err)
+			if errors.Is(err, context.DeadlineExceeded) || isTimeout(err) {
+				apierror.Write(w, r, http.StatusGatewayTimeout, apierror.UpstreamTimeout, "Microservice timed out")
+				return
+			}
+			apierror.Write(w, r, http.StatusBadGateway, apierror.UpstreamFailed, "Failed to contact microservice")
+		},
+		ErrorLog: slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+}
+
+// Handler returns the handler of a route, which checks the caller may use it and sends it on
+func (g *Gateway) Handler(route Route) http.Handler {
+	proxy := g.proxies[route.Service]
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Internal routes are answered as if they did not exist, to keep them unknown outside
+		if route.Access == Internal {
+			requestsRejected.WithLabelValues("internal").Inc()
+			apierror.Write(w, r, http.StatusNotFound, apierror.NotFound, "Not found")
+			return
+		}
+
+		// Signed-in callers are limited by who they are, the others by their address, so that
+		// callers behind one address do not share a limit once signed in
+		client, authErr := clientAddress(r), error(nil)
+		if route.Access == Authenticated {
+			var user string
+			if user, authErr = authenticate(r, route.TokenParam); authErr == nil {
+				client = user
+			}
+		}
+		if ok, retryIn := g.limiter.Allow(client); !ok {
+			requestsRejected.WithLabelValues("rate_limited").Inc()
+			w.Header().Set("Retry-After", fmt.Sprintf("%d", int(math.Ceil(retryIn.Seconds()))))
+			apierror.Write(w, r, http.StatusTooManyRequests, apierror.RateLimited, "Too many requests, please try again later")
+			return
+		}
+		if authErr != nil {
+			requestsRejected.WithLabelValues("unauthorized").Inc()
+			slog.WarnContext(r.Context(), "Invalid JWT token", "error", authErr)
+			apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+			return
+		}
+
+		proxy.ServeHTTP(w, r)
+	})
+}
+
+// authenticate checks the request carries a valid token, in the Authorization header or the
+// tokenParam query parameter if there is one, and returns who it was issued to
+func authenticate(r *http.Request, tokenParam string) (string, error) {
+	var tokenString string
+	if tokenParam != "" {
+		tokenString = r.URL.Query().Get(tokenParam)
+	} else if authHeader := r.Header.Get("Authorization"); strings.HasPrefix(authHeader, "Bearer ") {
+		tokenString = strings.TrimPrefix(authHeader, "Bearer ")
+	}
+	if tokenString == "" {
+		return "", errors.New("no token")
+	}
+
+	secretKey := config.Current().JWTSecret
+	if secretKey == "" {
+		return "", errors.New("JWT_SECRET is not set")
+	}
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		return []byte(secretKey), nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if !token.Valid {
+		return "", errors.New("invalid token")
+	}
+
+	// Only sign-in tokens carry a role, not the short-lived ones of a login in progress
+	role, ok := claims["role"].(string)
+	if !ok {
+		return "", errors.New("token has no role")
+	}
+	return fmt.Sprintf("%s:%v", role, claims["user_id"]), nil
+}
+
+// clientAddress returns the address the request came from
+func clientAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// isTimeout reports whether the error is a network timeout
+func isTimeout(err error) bool {
+	var timeoutErr interface{ Timeout() bool }
+	return errors.As(err, &timeoutErr) && timeoutErr.Timeout()
+}
@@ -0,0 +1,162 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gatewayMicroservice/config"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// testGateway returns a Gateway sending every service's requests to a backend answering 200,
+// and counting the requests it was sent
+func testGateway(t *testing.T, limiter *Limiter) (*Gateway, *int) {
+	t.Helper()
+	config.Current().JWTSecret = "test-secret"
+
+	reached := new(int)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*reached++
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(backend.Close)
+	for _, route := range Routes {
+		t.Setenv(strings.ToUpper(strings.ReplaceAll(route.Service, "-", "_"))+"_URL", backend.URL)
+	}
+	return New(limiter), reached
+}
+
+// signed returns a token for claims, expiring in an hour unless they say otherwise
+func signed(t *testing.T, method jwt.SigningMethod, key interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+	if _, ok := claims["exp"]; !ok {
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+	}
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// seniorToken returns a valid token of senior 7
+func seniorToken(t *testing.T) string {
+	return signed(t, jwt.SigningMethodHS256, []byte("test-secret"), jwt.MapClaims{"user_id": 7, "role": "User"})
+}
+
+func TestHandlerAccess(t *testing.T) {
+	g, reached := testGateway(t, NewLimiter(0, 0))
+	public := Route{Path: "/api/v1/authentication/user/login", Service: Routes[0].Service, Access: Public}
+	authenticated := Route{Path: "/api/v1/user/risk", Service: Routes[0].Service, Access: Authenticated}
+	internal := Route{Path: "/api/v1/user/create", Service: Routes[0].Service, Access: Internal}
+	websocket := Route{Path: "/api/v1/selfAssessment/ws", Service: Routes[0].Service, Access: Authenticated, TokenParam: "token"}
+
+	tests := []struct {
+		name   string
+		route  Route
+		query  string
+		header string
+		want   int
+	}{
+		{"public without a token", public, "", "", http.StatusOK},
+		{"authenticated without a token", authenticated, "", "", http.StatusUnauthorized},
+		{"authenticated with a token", authenticated, "", "Bearer " + seniorToken(t), http.StatusOK},
+		{"authenticated without Bearer", authenticated, "", seniorToken(t), http.StatusUnauthorized},
+		{"internal without a token", internal, "", "", http.StatusNotFound},
+		{"internal with a token", internal, "", "Bearer " + seniorToken(t), http.StatusNotFound},
+		{"websocket with the token parameter", websocket, "token=" + seniorToken(t), "", http.StatusOK},
+		{"websocket with only the header", websocket, "", "Bearer " + seniorToken(t), http.StatusUnauthorized},
+		{"websocket with a bad token parameter", websocket, "token=nonsense", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := *reached
+			r := httptest.NewRequest("GET", tt.route.Path+"?"+tt.query, nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			g.Handler(tt.route).ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status = %d %s, want %d", w.Code, w.Body, tt.want)
+			}
+			if sent := *reached > before; sent != (tt.want == http.StatusOK) {
+				t.Errorf("sent to the service = %v, want %v", sent, !sent)
+			}
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	config.Current().JWTSecret = "test-secret"
+	secret := []byte("test-secret")
+
+	tests := []struct {
+		name  string
+		token string
+		want  string // Who the token was issued to, or empty if it is refused
+	}{
+		{"senior", signed(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"user_id": 7, "role": "User"}), "User:7"},
+		{"admin", signed(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"user_id": 1, "role": "Admin"}), "Admin:1"},
+		{"other HMAC algorithm", signed(t, jwt.SigningMethodHS512, secret, jwt.MapClaims{"user_id": 7, "role": "User"}), "User:7"},
+		{"expired", signed(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"user_id": 7, "role": "User", "exp": time.Now().Add(-time.Minute).Unix()}), ""},
+		{"unsigned", signed(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, jwt.MapClaims{"user_id": 7, "role": "User"}), ""},
+		{"wrong secret", signed(t, jwt.SigningMethodHS256, []byte("other-secret"), jwt.MapClaims{"user_id": 7, "role": "User"}), ""},
+		{"login in progress", signed(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"admin_id": 1, "mfa": true}), ""},
+		{"not a token", "nonsense", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/v1/user/risk", nil)
+			r.Header.Set("Authorization", "Bearer "+tt.token)
+			got, err := authenticate(r, "")
+			if tt.want == "" && err == nil {
+				t.Errorf("authenticate = %q, want the token refused", got)
+			} else if tt.want != "" && (err != nil || got != tt.want) {
+				t.Errorf("authenticate = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlerRateLimits(t *testing.T) {
+	g, reached := testGateway(t, NewLimiter(60, 2))
+	public := g.Handler(Route{Path: "/api/v1/authentication/user/login", Service: Routes[0].Service, Access: Public})
+	authenticated := g.Handler(Route{Path: "/api/v1/user/risk", Service: Routes[0].Service, Access: Authenticated})
+
+	serve := func(handler http.Handler, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = "203.0.113.9:4000"
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	// Callers without a valid token share their address's limit, even on authenticated routes
+	serve(public, "")
+	serve(authenticated, "nonsense")
+	w := serve(public, "")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("address over its limit: status = %d with Retry-After %q, want %d with 1", w.Code, w.Header().Get("Retry-After"), http.StatusTooManyRequests)
+	}
+	if *reached != 1 {
+		t.Errorf("%d requests sent to the service, want 1", *reached)
+	}
+
+	// A signed-in caller at the same address has a limit of their own
+	for i := 0; i < 2; i++ {
+		if w := serve(authenticated, seniorToken(t)); w.Code != http.StatusOK {
+			t.Errorf("signed-in request %d: status = %d, want %d", i+1, w.Code, http.StatusOK)
+		}
+	}
+	if w := serve(authenticated, seniorToken(t)); w.Code != http.StatusTooManyRequests {
+		t.Errorf("signed-in caller over their limit: status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+}
@@ -0,0 +1,81 @@
+package gateway
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often the limiter forgets the clients whose buckets have refilled
+const sweepInterval = time.Minute
+
+// Limiter limits the requests of each client with a token bucket, which holds up to burst requests
+// and refills at the average rate allowed
+type Limiter struct {
+	rate  float64 // Requests added to a bucket each second
+	burst float64 // Requests a full bucket holds
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+	now     func() time.Time
+}
+
+// bucket is the requests a client has left, as of when it was last updated
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// NewLimiter returns a Limiter allowing each client perMinute requests a minute on average, and up
+// to burst at once. A perMinute of 0 turns the limit off.
+func NewLimiter(perMinute, burst int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{
+		rate:    float64(perMinute) / 60,
+		burst:   float64(burst),
+		buckets: map[string]*bucket{},
+		swept:   time.Now(),
+		now:     time.Now,
+	}
+}
+
+// Allow takes a request from the client's bucket. When the bucket is empty it returns false and how
+// long until the client may try again.
+func (l *Limiter) Allow(client string) (bool, time.Duration) {
+	if l.rate <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if now.Sub(l.swept) >= sweepInterval {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: l.burst, updated: now}
+		l.buckets[client] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.updated).Seconds()*l.rate)
+	b.updated = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep forgets the buckets that would be full by now, which a new bucket replaces exactly
+func (l *Limiter) sweep(now time.Time) {
+	for client, b := range l.buckets {
+		if b.tokens+now.Sub(b.updated).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, client)
+		}
+	}
+	l.swept = now
+}
@@ -0,0 +1,90 @@
+package gateway
+
+import (
+	"testing"
+	"time"
+)
+
+// testLimiter returns a Limiter on a clock the test moves with advance
+func testLimiter(perMinute, burst int) (*Limiter, func(time.Duration)) {
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	l := NewLimiter(perMinute, burst)
+	l.now = func() time.Time { return now }
+	l.swept = now
+	return l, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestLimiterExhaustionAndRefill(t *testing.T) {
+	l, advance := testLimiter(60, 3)
+
+	// A full bucket allows the burst at once
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("client"); !ok {
+			t.Fatalf("request %d of the burst refused", i+1)
+		}
+	}
+	ok, retryIn := l.Allow("client")
+	if ok || retryIn != time.Second {
+		t.Errorf("empty bucket = %v, retry in %s, want refused for 1s", ok, retryIn)
+	}
+
+	// Other clients have buckets of their own
+	if ok, _ := l.Allow("other"); !ok {
+		t.Error("another client refused")
+	}
+
+	// The bucket refills at the average rate, one request a second
+	advance(500 * time.Millisecond)
+	if ok, retryIn := l.Allow("client"); ok || retryIn != 500*time.Millisecond {
+		t.Errorf("half refilled = %v, retry in %s, want refused for 500ms", ok, retryIn)
+	}
+	advance(500 * time.Millisecond)
+	if ok, _ := l.Allow("client"); !ok {
+		t.Error("refilled request refused")
+	}
+	if ok, _ := l.Allow("client"); ok {
+		t.Error("request past the refill allowed")
+	}
+
+	// A long wait refills no more than the burst
+	advance(time.Hour)
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("client"); !ok {
+			t.Fatalf("request %d after a long wait refused", i+1)
+		}
+	}
+	if ok, _ := l.Allow("client"); ok {
+		t.Error("request past the burst allowed after a long wait")
+	}
+}
+
+func TestLimiterSweepsFullBuckets(t *testing.T) {
+	l, advance := testLimiter(60, 2)
+	l.Allow("idle")
+
+	// Just before the sweep the busy client drains its bucket, while the idle one has refilled
+	advance(sweepInterval - 500*time.Millisecond)
+	l.Allow("busy")
+	l.Allow("busy")
+	advance(500 * time.Millisecond)
+	l.Allow("new")
+
+	if _, ok := l.buckets["idle"]; ok {
+		t.Error("full bucket kept after the sweep")
+	}
+	if _, ok := l.buckets["busy"]; !ok {
+		t.Error("drained bucket forgotten by the sweep")
+	}
+	if ok, retryIn := l.Allow("busy"); ok || retryIn != 500*time.Millisecond {
+		t.Errorf("busy client after the sweep = %v, retry in %s, want refused for 500ms", ok, retryIn)
+	}
+}
+
+func TestLimiterOff(t *testing.T) {
+	l, _ := testLimiter(0, 1)
+	for i := 0; i < 100; i++ {
+		if ok, _ := l.Allow("client"); !ok {
+			t.Fatalf("request %d refused with the limit off", i+1)
+		}
+	}
+}
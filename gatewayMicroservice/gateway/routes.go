@@ -0,0 +1,77 @@
+package gateway
+
+import "shared/config"
+
+// Access says who may call a route through the gateway
+type Access int
+
+const (
+	Authenticated Access = iota // Needs a valid token, whose role and permissions the service checks
+	Public                      // Open to anyone, such as the logins and registration
+	Internal                    // Only called by the other services, so hidden from the internet
+)
+
+// Route sends the requests for a path to a service. A path ending in / is a prefix, covering every
+// path under it that no other route names.
+type Route struct {
+	Path    string
+	Service string
+	Access  Access
+
+	// TokenParam is the query parameter the token is read from instead of the Authorization
+	// header, for WebSockets, which browsers open without headers
+	TokenParam string
+}
+
+// Routes are the /api/v1 routes the gateway serves. Each service's paths are authenticated unless
+// a route of their own says otherwise, so endpoints added to a service are never public by mistake.
+var Routes = []Route{
+	// Registration and the logins, which hand out the tokens
+	{Path: "/api/v1/authentication/", Service: config.AuthService, Access: Authenticated},
+	{Path: "/api/v1/authentication/send-verification", Service: config.AuthService, Access: Public},
+	{Path: "/api/v1/authentication/register-user", Service: config.AuthService, Access: Public},
+	{Path: "/api/v1/authentication/user/login", Service: config.AuthService, Access: Public},
+	{Path: "/api/v1/authentication/user/login-methods", Service: config.AuthService, Access: Public},
+	{Path: "/api/v1/authentication/user/login/request-code", Service: config.AuthService, Access: Public},
+	{Path: "/api/v1/authentication/user/login/verify-code", Service: config.AuthService, Access: Public},
+	{Path: "/api/v1/authentication/user/login/magic-link", Service: config.AuthService, Access: Public},
+	{Path: "/api/v1/authentication/admin/login", Service: config.AuthService, Access: Public},
+	{Path: "/api/v1/authentication/admin/login/mfa", Service: config.AuthService, Access: Public},
+	{Path: "/api/v1/authentication/admin/accept-invite", Service: config.AuthService, Access: Public},
+	{Path: "/api/v1/authentication/caregiver/register", Service: config.AuthService, Access: Public},
+	{Path: "/api/v1/authentication/caregiver/login", Service: config.AuthService, Access: Public},
+	{Path: "/api/v1/authentication/admin/invite", Service: config.AuthService, Access: Internal},
+	{Path: "/api/v1/authentication/admin/reset", Service: config.AuthService, Access: Internal},
+	{Path: "/api/v1/authentication/admin/deactivate", Service: config.AuthService, Access: Internal},
+	{Path: "/api/v1/authentication/admin/2fa/reset", Service: config.AuthService, Access: Internal},
+	{Path: "/api/v1/authentication/admin/checkToken", Service: config.AuthService, Access: Internal},
+
+	// Profiles, caregivers and insights
+	{Path: "/api/v1/user/", Service: config.UserService, Access: Authenticated},
+	{Path: "/api/v1/user/create", Service: config.UserService, Access: Internal},
+	{Path: "/api/v1/user/getUser", Service: config.UserService, Access: Internal},
+	{Path: "/api/v1/user/caregiver/verifyInvite", Service: config.UserService, Access: Internal},
+	{Path: "/api/v1/user/caregiver/checkAccess", Service: config.UserService, Access: Internal},
+	{Path: "/api/v1/user/caregiver/reminderRecipients", Service: config.UserService, Access: Internal},
+	{Path: "/api/v1/user/risk/observations", Service: config.UserService, Access: Internal},
+
+	// Falls Efficacy Scale assessments
+	{Path: "/api/v1/fes/", Service: config.FallsEfficacyService, Access: Authenticated},
+	{Path: "/api/v1/questions", Service: config.FallsEfficacyService, Access: Authenticated},
+	{Path: "/api/v1/saveResponses", Service: config.FallsEfficacyService, Access: Authenticated},
+
+	// Self-assessments with the FallSafe device
+	{Path: "/api/v1/selfAssessment/", Service: config.SelfAssessmentService, Access: Authenticated},
+	{Path: "/api/v1/selfAssessment/ws", Service: config.SelfAssessmentService, Access: Authenticated, TokenParam: "token"},
+
+	// The admin dashboard
+	{Path: "/api/v1/admin/", Service: config.AdminService, Access: Authenticated},
+	{Path: "/api/v1/admin/getAdmin", Service: config.AdminService, Access: Internal},
+	{Path: "/api/v1/admin/activateAdmin", Service: config.AdminService, Access: Internal},
+	{Path: "/api/v1/admin/referrals/open", Service: config.AdminService, Access: Internal},
+
+	// Responses, speech and translations from OpenAI
+	{Path: "/api/v1/generateResponse", Service: config.OpenAIService, Access: Authenticated},
+	{Path: "/api/v1/generateSpeech", Service: config.OpenAIService, Access: Authenticated},
+	{Path: "/api/v1/generateTranslation", Service: config.OpenAIService, Access: Authenticated},
+}
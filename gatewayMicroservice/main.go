@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"gatewayMicroservice/config"
+	"gatewayMicroservice/gateway"
+	"gatewayMicroservice/server"
+
+	sharedconfig "shared/config"
+	"shared/graceful"
+	"shared/logging"
+	"shared/observability"
+	"shared/settings"
+	"shared/tracing"
+)
+
+func main() {
+	// Read the configuration from the flags, the environment, secret files and the config file
+	cfg, err := config.Load(os.Args[1:])
+	if cfg.PrintConfig {
+		settings.Print(os.Stdout, cfg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Log as JSON from here on, at the level set by LOG_LEVEL
+	logging.Setup(sharedconfig.GatewayService, cfg.LogLevel)
+	if err != nil {
+		slog.Error("Error loading configuration", "error", err)
+		os.Exit(1)
+	}
+
+	// Trace requests across the services, exporting the spans if an OTLP collector is configured
+	shutdownTracing, err := tracing.Setup(context.Background(), sharedconfig.GatewayService)
+	if err != nil {
+		slog.Error("Error setting up tracing", "error", err)
+		os.Exit(1)
+	}
+
+	g := gateway.New(gateway.NewLimiter(cfg.RateLimit.PerMinute, cfg.RateLimit.Burst))
+
+	// Ready as soon as it serves, without checking the services, so that one of them being down
+	// does not take the others off the internet with it
+	health := observability.NewHealth()
+
+	// Route the endpoints through their middleware
+	router := server.NewRouter(g, health)
+
+	// Serve the metrics on their own port, which the k8s Service does not expose
+	metrics := graceful.ServeInBackground(fmt.Sprintf(":%d", cfg.MetricsPort), server.MetricsRouter())
+
+	// Serve until SIGTERM, then drain the requests in flight, stop serving the metrics, flush the spans
+	slog.Info("API Gateway is running", "port", cfg.Port, "metrics_port", cfg.MetricsPort)
+	if err := graceful.Serve(fmt.Sprintf(":%d", cfg.Port), router, metrics, shutdownTracing); err != nil {
+		slog.Error("Server stopped", "error", err)
+		os.Exit(1)
+	}
+}
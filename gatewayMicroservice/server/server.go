@@ -0,0 +1,70 @@
+// Package server routes the gateway's endpoints, so main and the integration harness serve the
+// same handlers behind the same middleware
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"gatewayMicroservice/gateway"
+
+	"shared/apierror"
+	sharedconfig "shared/config"
+	"shared/logging"
+	"shared/observability"
+	"shared/tracing"
+
+	"github.com/gorilla/handlers"
+	"github.com/gorilla/mux"
+)
+
+// NewRouter returns the gateway's routes, with CORS for the frontend and request logging. CORS is
+// answered here for every service, and the services' own CORS headers are dropped from their responses.
+func NewRouter(g *gateway.Gateway, health *observability.Health) http.Handler {
+	// Add CORS support, inside the middleware that tags every request with an ID and logs it
+	return logging.Middleware(handlers.CORS(
+		handlers.AllowedOrigins(sharedconfig.AllowedOrigins()),
+		handlers.AllowedMethods([]string{"GET", "POST", "PUT", "OPTIONS"}), // Update for allowed HTTP methods
+		handlers.AllowedHeaders([]string{"Content-Type", "Authorization"}), // Include Authorization header
+		handlers.ExposedHeaders([]string{logging.RequestIDHeader}),         // Let the frontend report request IDs
+	)(Routes(g, health)))
+}
+
+// Routes returns the gateway's routes to the services, alongside the probe endpoints of health. Each
+// route is traced and timed by its path, or by its prefix for a whole service. The metrics are served
+// by MetricsRouter instead, since this router faces the internet.
+func Routes(g *gateway.Gateway, health *observability.Health) *mux.Router {
+	// Initialize the router, tracing and timing every request by its route
+	router := mux.NewRouter()
+	router.Use(tracing.Middleware(sharedconfig.GatewayService), observability.Middleware)
+
+	// Unknown routes and methods get the same JSON errors as the endpoints
+	router.NotFoundHandler = apierror.NotFoundHandler()
+	router.MethodNotAllowedHandler = apierror.MethodNotAllowedHandler()
+
+	// Liveness and readiness, served without authentication
+	health.RegisterProbes(router)
+
+	// The router matches in order, so the paths come before the prefixes they fall under
+	for _, route := range gateway.Routes {
+		if !strings.HasSuffix(route.Path, "/") {
+			router.Path(route.Path).Handler(g.Handler(route))
+		}
+	}
+	for _, route := range gateway.Routes {
+		if strings.HasSuffix(route.Path, "/") {
+			router.PathPrefix(route.Path).Handler(g.Handler(route))
+		}
+	}
+
+	return router
+}
+
+// MetricsRouter returns the gateway's metrics endpoint, for Prometheus to scrape on the metrics port
+func MetricsRouter() *mux.Router {
+	router := mux.NewRouter()
+	router.NotFoundHandler = apierror.NotFoundHandler()
+	router.MethodNotAllowedHandler = apierror.MethodNotAllowedHandler()
+	observability.RegisterMetrics(router)
+	return router
+}
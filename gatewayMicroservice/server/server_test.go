@@ -0,0 +1,74 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gatewayMicroservice/config"
+	"gatewayMicroservice/gateway"
+
+	"shared/observability"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestRoutes(t *testing.T) {
+	config.Current().JWTSecret = "test-secret"
+
+	// Every service is a backend that answers with the path it was sent
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer backend.Close()
+	for _, route := range gateway.Routes {
+		t.Setenv(strings.ToUpper(strings.ReplaceAll(route.Service, "-", "_"))+"_URL", backend.URL)
+	}
+	routes := Routes(gateway.New(gateway.NewLimiter(0, 0)), observability.NewHealth())
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"user_id": 7, "role": "User", "exp": time.Now().Add(time.Hour).Unix()}).SignedString([]byte("test-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  bool
+		want   int
+	}{
+		{"public login", "POST", "/api/v1/authentication/user/login", false, http.StatusOK},
+		{"public registration", "POST", "/api/v1/authentication/register-user", false, http.StatusOK},
+		{"route under a prefix defaults to authenticated", "POST", "/api/v1/authentication/admin/2fa/enroll", false, http.StatusUnauthorized},
+		{"route under a prefix with a token", "POST", "/api/v1/authentication/admin/2fa/enroll", true, http.StatusOK},
+		{"new endpoint of a service", "GET", "/api/v1/user/notYetListed", false, http.StatusUnauthorized},
+		{"internal route", "POST", "/api/v1/user/create", true, http.StatusNotFound},
+		{"internal route without a token", "POST", "/api/v1/authentication/admin/checkToken", false, http.StatusNotFound},
+		{"websocket without its token parameter", "GET", "/api/v1/selfAssessment/ws", true, http.StatusUnauthorized},
+		{"unknown service", "GET", "/api/v1/unknown/route", true, http.StatusNotFound},
+		{"liveness probe", "GET", "/healthz", false, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.token {
+				r.Header.Set("Authorization", "Bearer "+token)
+			}
+			w := httptest.NewRecorder()
+			routes.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status = %d %s, want %d", w.Code, w.Body, tt.want)
+			}
+		})
+	}
+
+	// The WebSocket route reads its token from the query, as browsers cannot set the header
+	w := httptest.NewRecorder()
+	routes.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/selfAssessment/ws?token="+token, nil))
+	if w.Code != http.StatusOK || w.Body.String() != "/api/v1/selfAssessment/ws" {
+		t.Errorf("websocket with its token parameter = %d %s, want it sent to the service", w.Code, w.Body)
+	}
+}
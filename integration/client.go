@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// httpClient is used for every request the flows make
+var httpClient = &http.Client{Timeout: 15 * time.Second}
+
+// call sends a JSON request to url with the bearer token, if any, and decodes the JSON
+// response into out, if it is not nil. A status other than want is an error.
+func call(method, url, token string, body, out interface{}, want int) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != want {
+		return fmt.Errorf("%s %s returned %d, expected %d: %s", method, url, resp.StatusCode, want, strings.TrimSpace(string(data)))
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("%s %s returned invalid JSON: %v", method, url, err)
+		}
+	}
+	return nil
+}
+
+// list gets a page of a list endpoint with the bearer token, returning its rows and the cursor of
+// the next page, empty on the last page
+func list(url, token string) ([]json.RawMessage, string, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("GET %s returned %d, expected 200: %s", url, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	var rows []json.RawMessage
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, "", fmt.Errorf("GET %s returned invalid JSON: %v", url, err)
+	}
+	return rows, resp.Header.Get("X-Next-Cursor"), nil
+}
+
+// eventually retries check until it succeeds or the timeout passes, for effects the
+// services apply in the background, returning the last error
+func eventually(timeout time.Duration, check func() error) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := check()
+		if err == nil || time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// claimedID returns the user_id claim of a token the stack signed
+func (s *Stack) claimedID(token string) (int, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return []byte(s.JWTSecret), nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("invalid token: %v", err)
+	}
+	id, ok := claims["user_id"].(float64)
+	if !ok {
+		return 0, fmt.Errorf("token has no user_id claim")
+	}
+	return int(id), nil
+}
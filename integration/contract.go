@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gorilla/mux"
+)
+
+// apiPrefix is the prefix of the versioned routes each service's OpenAPI spec describes
+const apiPrefix = "/api/v1/"
+
+// Violations name the schema rule that was broken, rather than dumping the schema and value
+func init() {
+	openapi3.SchemaErrorDetailsDisabled = true
+}
+
+// contract is a service's OpenAPI spec, which the harness checks the service's routes and every
+// exchange with the service against
+type contract struct {
+	service string
+	doc     *openapi3.T
+	router  routers.Router
+	routes  *mux.Router
+
+	mu         sync.Mutex
+	violations []string
+}
+
+// newContract loads a service's spec, served at url, for checking against the routes the service
+// serves
+func newContract(service string, spec []byte, url string, routes *mux.Router) (*contract, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the %s spec: %v", service, err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("invalid %s spec: %v", service, err)
+	}
+
+	// The spec names the port the service listens on in production
+	doc.Servers = openapi3.Servers{{URL: url}}
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to route the %s spec: %v", service, err)
+	}
+	return &contract{service: service, doc: doc, router: router, routes: routes}, nil
+}
+
+// Middleware checks each request to a described route, and the response to it, against the spec.
+// Requests the service rejects may break the spec, since rejecting them is the service's job.
+// Requests for routes the spec does not describe are left to checkRoutes.
+func (c *contract) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, apiPrefix) || r.Method == http.MethodOptions || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		route, params, err := c.router.FindRoute(r)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// The body is read once for the check and again by the service
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		checked := r.Clone(r.Context())
+		checked.Body = io.NopCloser(bytes.NewReader(body))
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		input := &openapi3filter.RequestValidationInput{
+			Request:    checked,
+			PathParams: params,
+			Route:      route,
+			Options: &openapi3filter.Options{
+				AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+				MultiError:         true,
+			},
+		}
+		requestErr := openapi3filter.ValidateRequest(r.Context(), input)
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		operation := r.Method + " " + r.URL.Path
+		if requestErr != nil && recorder.status < http.StatusBadRequest {
+			c.violate("%s accepted a request the spec rejects: %v", operation, requestErr)
+		}
+		response := &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: input,
+			Status:                 recorder.status,
+			Header:                 recorder.Header(),
+			Body:                   io.NopCloser(bytes.NewReader(recorder.body.Bytes())),
+			Options:                input.Options,
+		}
+		if err := openapi3filter.ValidateResponse(r.Context(), response); err != nil {
+			c.violate("%s answered %d with a response the spec rejects: %v", operation, recorder.status, err)
+		}
+	})
+}
+
+// violate records a break from the spec
+func (c *contract) violate(format string, args ...interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.violations = append(c.violations, c.service+": "+fmt.Sprintf(format, args...))
+}
+
+// Violations returns the breaks from the spec recorded so far
+func (c *contract) Violations() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.violations...)
+}
+
+// checkRoutes compares the service's /api/v1 routes with the operations in its spec, returning
+// each route the spec misses and each operation the service does not serve. Routes that accept
+// any method, like the self-assessment WebSocket, match every operation on their path.
+func (c *contract) checkRoutes() ([]string, error) {
+	served := map[string]bool{}
+	anyMethod := map[string]bool{}
+	var problems []string
+	err := c.routes.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil || !strings.HasPrefix(path, apiPrefix) {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			anyMethod[path] = true
+			if c.doc.Paths.Value(path) == nil {
+				problems = append(problems, fmt.Sprintf("%s: %s is not in the spec", c.service, path))
+			}
+			return nil
+		}
+		for _, method := range methods {
+			served[method+" "+path] = true
+			item := c.doc.Paths.Value(path)
+			if item == nil || item.GetOperation(method) == nil {
+				problems = append(problems, fmt.Sprintf("%s: %s %s is not in the spec", c.service, method, path))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for path, item := range c.doc.Paths.Map() {
+		for method := range item.Operations() {
+			if !served[method+" "+path] && !anyMethod[path] {
+				problems = append(problems, fmt.Sprintf("%s: %s %s is in the spec but not served", c.service, method, path))
+			}
+		}
+	}
+	sort.Strings(problems)
+	return problems, nil
+}
+
+// responseRecorder passes a response through while keeping its status and body for checking
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
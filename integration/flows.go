@@ -0,0 +1,782 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"integration/standin"
+
+	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// flow is one journey through the services. Flows run in order and share a session,
+// so a flow can use the senior or admin an earlier one set up.
+type flow struct {
+	name string
+	run  func(s *Stack, session *session) error
+}
+
+// session is what the flows learn about the senior and admin they act as
+type session struct {
+	seniorEmail    string
+	seniorPassword string
+	seniorToken    string
+	seniorID       int
+	adminToken     string
+}
+
+// flows are the journeys the harness drives, from a senior registering to an admin reviewing them
+var flows = []flow{
+	{"register a senior", registerSenior},
+	{"log in as the senior", logInSenior},
+	{"complete a Falls Efficacy Scale assessment", completeFES},
+	{"complete a self-assessment with the FallSafe device", completeSelfAssessment},
+	{"get insights and the combined risk", getInsights},
+	{"trace the insights and a sensor reading through the services", traceRequests},
+	{"review the senior on the admin dashboard", reviewOnDashboard},
+	{"invite an admin", inviteAdmin},
+	{"page through the lists with cursors", pageLists},
+	{"reach the services through the gateway", useGateway},
+	{"probe and scrape every service", probeServices},
+	{"answer errors in the JSON error shape", checkErrors},
+	{"serve every /api/v1 route the OpenAPI specs describe", checkRoutes},
+	{"keep every exchange within the OpenAPI specs", checkExchanges},
+}
+
+// Timeouts for emails and for effects the services apply in the background
+const (
+	emailTimeout      = 5 * time.Second
+	backgroundTimeout = 10 * time.Second
+)
+
+var (
+	verificationCodePattern = regexp.MustCompile(`class="code">(\d{6})<`)
+	setupTokenPattern       = regexp.MustCompile(`word-break: break-all;">([^<]+)<`)
+)
+
+// registerSenior signs up with the code emailed by the authentication service, which creates
+// the profile in the user service
+func registerSenior(s *Stack, session *session) error {
+	session.seniorEmail = fmt.Sprintf("senior-%s@fallsafe.test", randomHex(4))
+	session.seniorPassword = "stand-in-" + randomHex(8)
+	auth := s.URL(AuthService)
+
+	if err := call("POST", auth+"/api/v1/authentication/send-verification", "", map[string]string{"email": session.seniorEmail}, nil, http.StatusOK); err != nil {
+		return err
+	}
+	email, err := s.SMTP.WaitFor(session.seniorEmail, "Your Verification Code", emailTimeout)
+	if err != nil {
+		return err
+	}
+	match := verificationCodePattern.FindStringSubmatch(email.Data)
+	if match == nil {
+		return fmt.Errorf("verification email has no code: %s", email.Data)
+	}
+
+	registration := map[string]interface{}{
+		"email":             session.seniorEmail,
+		"verification_code": match[1],
+		"name":              "Tan Ah Kow",
+		"password":          session.seniorPassword,
+		"phone_number":      "91234567",
+		"address":           "1 Stand-in Road",
+		"age":               78,
+	}
+	return call("POST", auth+"/api/v1/authentication/register-user", "", registration, nil, http.StatusOK)
+}
+
+// logInSenior logs in with the registered password and checks the profile exists
+func logInSenior(s *Stack, session *session) error {
+	var login struct {
+		Token string `json:"token"`
+	}
+	credentials := map[string]string{"email": session.seniorEmail, "password": session.seniorPassword}
+	if err := call("POST", s.URL(AuthService)+"/api/v1/authentication/user/login", "", credentials, &login, http.StatusOK); err != nil {
+		return err
+	}
+
+	id, err := s.claimedID(login.Token)
+	if err != nil {
+		return err
+	}
+	session.seniorToken, session.seniorID = login.Token, id
+
+	var profile struct {
+		Email string `json:"email"`
+	}
+	if err := call("GET", fmt.Sprintf("%s/api/v1/user/getUser?userID=%d", s.URL(UserService), id), session.seniorToken, nil, &profile, http.StatusOK); err != nil {
+		return err
+	}
+	if profile.Email != session.seniorEmail {
+		return fmt.Errorf("profile of user %d has email %q, expected %q", id, profile.Email, session.seniorEmail)
+	}
+	return nil
+}
+
+// completeFES answers every question with the highest concern and reads the result back
+func completeFES(s *Stack, session *session) error {
+	fes := s.URL(FallsEfficacyService)
+	var questions []struct {
+		ID int `json:"id"`
+	}
+	if err := call("GET", fes+"/api/v1/questions", session.seniorToken, nil, &questions, http.StatusOK); err != nil {
+		return err
+	}
+	if len(questions) == 0 {
+		return fmt.Errorf("no FES questions")
+	}
+
+	answers := make([]map[string]int, 0, len(questions))
+	for _, question := range questions {
+		answers = append(answers, map[string]int{"question_id": question.ID, "score": 4})
+	}
+	response := map[string]interface{}{"user_id": session.seniorID, "responses": answers}
+	if err := call("POST", fes+"/api/v1/saveResponses", session.seniorToken, response, nil, http.StatusOK); err != nil {
+		return err
+	}
+
+	var results []struct {
+		TotalScore int `json:"total_score"`
+	}
+	if err := call("GET", fmt.Sprintf("%s/api/v1/fes/getFESResults?user_id=%d", fes, session.seniorID), session.seniorToken, nil, &results, http.StatusOK); err != nil {
+		return err
+	}
+	if len(results) != 1 || results[0].TotalScore != 4*len(questions) {
+		return fmt.Errorf("FES results are %+v, expected one response scoring %d", results, 4*len(questions))
+	}
+	return nil
+}
+
+// completeSelfAssessment runs every test of a session, streaming the device's movements through
+// the MQTT broker to the self-assessment WebSocket, and saves the risk it calculates for each
+func completeSelfAssessment(s *Stack, session *session) error {
+	self := s.URL(SelfAssessmentService)
+	var started struct {
+		SessionID int `json:"sessionID"`
+	}
+	if err := call("POST", fmt.Sprintf("%s/api/v1/selfAssessment/startTest?userID=%d", self, session.seniorID), session.seniorToken, nil, &started, http.StatusOK); err != nil {
+		return err
+	}
+	var tests []struct {
+		TestID int `json:"test_id"`
+	}
+	if err := call("GET", self+"/api/v1/selfAssessment/getAllTests", session.seniorToken, nil, &tests, http.StatusOK); err != nil {
+		return err
+	}
+	if len(tests) == 0 {
+		return fmt.Errorf("no self-assessment tests")
+	}
+
+	// A session counts once every test has a result
+	ws := strings.Replace(self, "http://", "ws://", 1) + "/api/v1/selfAssessment/ws"
+	for _, test := range tests {
+		risk, err := captureMovements(s, ws)
+		if err != nil {
+			return err
+		}
+		if risk.RiskLevel != "high" {
+			return fmt.Errorf("device risk is %+v, expected high from half the movements being abrupt", risk)
+		}
+
+		data, _ := json.Marshal(risk)
+		result := map[string]interface{}{
+			"testSessionID": started.SessionID,
+			"userID":        session.seniorID,
+			"testID":        test.TestID,
+			"timeTaken":     12.5,
+			"websocketData": string(data),
+		}
+		if err := call("POST", self+"/api/v1/selfAssessment/saveTestResult", session.seniorToken, result, nil, http.StatusOK); err != nil {
+			return err
+		}
+	}
+
+	var sessions []struct {
+		SessionID   int               `json:"session_id"`
+		TestResults []json.RawMessage `json:"test_results"`
+	}
+	if err := call("GET", fmt.Sprintf("%s/api/v1/selfAssessment/getUserResults?user_id=%d", self, session.seniorID), session.seniorToken, nil, &sessions, http.StatusOK); err != nil {
+		return err
+	}
+	if len(sessions) != 1 || sessions[0].SessionID != started.SessionID || len(sessions[0].TestResults) != len(tests) {
+		return fmt.Errorf("self-assessment sessions are %+v, expected session %d with %d results", sessions, started.SessionID, len(tests))
+	}
+	return nil
+}
+
+// deviceRisk is the risk the self-assessment service calculates from the device's movements
+type deviceRisk struct {
+	AbruptPercentage float64 `json:"abrupt_percentage"`
+	RiskLevel        string  `json:"risk_level"`
+}
+
+// devicePublishTopic is the topic the FallSafe device publishes its movements to
+const devicePublishTopic = "esp32s3/pub"
+
+// captureMovements starts a capture over the WebSocket, publishes movements as the device
+// would, half of them abrupt, and returns the risk sent back when the capture stops
+func captureMovements(s *Stack, url string) (deviceRisk, error) {
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		return deviceRisk{}, fmt.Errorf("failed to open the self-assessment WebSocket: %v", err)
+	}
+	defer conn.Close()
+
+	// The service subscribes to the device topic when the WebSocket opens
+	err = eventually(backgroundTimeout, func() error {
+		if s.MQTT.Subscribers(devicePublishTopic) == 0 {
+			return fmt.Errorf("self-assessment service did not subscribe to %s", devicePublishTopic)
+		}
+		return nil
+	})
+	if err != nil {
+		return deviceRisk{}, err
+	}
+
+	if err := conn.WriteJSON(map[string]string{"command": "start"}); err != nil {
+		return deviceRisk{}, err
+	}
+	// Commands are not acknowledged, so give the service time to start capturing before the
+	// movements arrive and to receive them before the capture stops
+	time.Sleep(300 * time.Millisecond)
+	for i := 0; i < 10; i++ {
+		angle := 1.0
+		if i%2 == 0 {
+			angle = 12.0
+		}
+		reading := map[string]interface{}{
+			"timestamp": time.Now().UnixMilli(), "accelX": 0.1, "accelY": 0.2, "accelZ": 9.8,
+			"gyroX": 0.01, "gyroY": 0.02, "gyroZ": 0.03, "angleDifference": angle,
+		}
+		// The first reading carries the device's trace, as a traced sensor would send it
+		if i == 0 {
+			reading["traceparent"] = sensorTraceParent
+		}
+		movement, _ := json.Marshal(reading)
+		if err := s.MQTT.Publish(devicePublishTopic, movement); err != nil {
+			return deviceRisk{}, err
+		}
+	}
+	time.Sleep(300 * time.Millisecond)
+
+	if err := conn.WriteJSON(map[string]string{"command": "stop"}); err != nil {
+		return deviceRisk{}, err
+	}
+	conn.SetReadDeadline(time.Now().Add(backgroundTimeout))
+	var risk deviceRisk
+	if err := conn.ReadJSON(&risk); err != nil {
+		return deviceRisk{}, fmt.Errorf("no risk assessment from the self-assessment WebSocket: %v", err)
+	}
+	return risk, nil
+}
+
+// getInsights asks the user service for AI insights on the FES result, which it gets from the
+// FES and OpenAI services, and for the combined risk the assessments were reported to
+func getInsights(s *Stack, session *session) error {
+	user := s.URL(UserService)
+	var insights struct {
+		FESResults         []json.RawMessage `json:"fes_results"`
+		ActionableInsights struct {
+			Response string `json:"response"`
+		} `json:"actionable_insights"`
+	}
+	if err := call("GET", fmt.Sprintf("%s/api/v1/user/getAUserFESResults?user_id=%d", user, session.seniorID), session.seniorToken, nil, &insights, http.StatusOK); err != nil {
+		return err
+	}
+	if len(insights.FESResults) != 1 || insights.ActionableInsights.Response != standin.Reply {
+		return fmt.Errorf("FES insights are %+v, expected one result and the stand-in reply", insights)
+	}
+	if prompts := s.OpenAI.Prompts(); len(prompts) == 0 || !strings.Contains(prompts[len(prompts)-1], "total score of 64") {
+		return fmt.Errorf("OpenAI was not asked about the FES score, prompts: %q", prompts)
+	}
+
+	// Both assessments report their scores to the user service in the background
+	return eventually(backgroundTimeout, func() error {
+		var risk struct {
+			Tier    string `json:"tier"`
+			Factors []struct {
+				Name string `json:"name"`
+			} `json:"factors"`
+		}
+		if err := call("GET", user+"/api/v1/user/risk", session.seniorToken, nil, &risk, http.StatusOK); err != nil {
+			return err
+		}
+		if risk.Tier != "High" {
+			return fmt.Errorf("combined risk is %+v, expected High", risk)
+		}
+		return nil
+	})
+}
+
+// reviewOnDashboard logs in as the super admin and finds the senior on the dashboard, which
+// the admin service assembles from the user, FES and self-assessment services, and in the
+// referral queue the user service opened when the senior's risk turned high
+func reviewOnDashboard(s *Stack, session *session) error {
+	var login struct {
+		Token string `json:"token"`
+	}
+	credentials := map[string]string{"email": superAdminEmail, "password": superAdminPassword}
+	if err := call("POST", s.URL(AuthService)+"/api/v1/authentication/admin/login", "", credentials, &login, http.StatusOK); err != nil {
+		return err
+	}
+	session.adminToken = login.Token
+	admin := s.URL(AdminService)
+
+	var dashboard struct {
+		Seniors []struct {
+			UserID         int     `json:"user_id"`
+			Email          string  `json:"email"`
+			LatestFESScore *int    `json:"latest_fes_score"`
+			LatestFAScore  *int    `json:"latest_fa_score"`
+			FESRiskLevel   *string `json:"fes_risk_level"`
+		} `json:"seniors"`
+		Errors map[string]string `json:"errors"`
+	}
+	if err := call("GET", admin+"/api/v1/admin/dashboard", session.adminToken, nil, &dashboard, http.StatusOK); err != nil {
+		return err
+	}
+	if len(dashboard.Errors) > 0 {
+		return fmt.Errorf("dashboard could not reach every service: %v", dashboard.Errors)
+	}
+	found := false
+	for _, senior := range dashboard.Seniors {
+		if senior.UserID != session.seniorID {
+			continue
+		}
+		found = true
+		if senior.Email != session.seniorEmail || senior.LatestFESScore == nil || senior.LatestFAScore == nil {
+			return fmt.Errorf("dashboard row for senior %d is missing results: %+v", session.seniorID, senior)
+		}
+	}
+	if !found {
+		return fmt.Errorf("senior %d is not on the dashboard", session.seniorID)
+	}
+
+	return eventually(backgroundTimeout, func() error {
+		var referrals []struct {
+			SeniorUserID int    `json:"senior_user_id"`
+			Source       string `json:"source"`
+			Status       string `json:"status"`
+		}
+		if err := call("GET", admin+"/api/v1/admin/referrals", session.adminToken, nil, &referrals, http.StatusOK); err != nil {
+			return err
+		}
+		for _, referral := range referrals {
+			if referral.SeniorUserID == session.seniorID && referral.Status == "Open" {
+				return nil
+			}
+		}
+		return fmt.Errorf("no open referral for senior %d in %+v", session.seniorID, referrals)
+	})
+}
+
+// inviteAdmin invites a clinician through the admin service, accepts the setup token the
+// authentication service emails them and logs in as the new admin, then deactivates them and
+// checks their token stops working at once
+func inviteAdmin(s *Stack, session *session) error {
+	email := fmt.Sprintf("clinician-%s@fallsafe.test", randomHex(4))
+	invite := map[string]string{"name": "Stand-in Clinician", "email": email, "admin_role": "Clinician"}
+	var clinician struct {
+		UserID int `json:"user_id"`
+	}
+	if err := call("POST", s.URL(AdminService)+"/api/v1/admin/admins/invite", session.adminToken, invite, &clinician, http.StatusCreated); err != nil {
+		return err
+	}
+
+	message, err := s.SMTP.WaitFor(email, "Your FallSafe Admin Account", emailTimeout)
+	if err != nil {
+		return err
+	}
+	match := setupTokenPattern.FindStringSubmatch(message.Data)
+	if match == nil {
+		return fmt.Errorf("invitation email has no setup token: %s", message.Data)
+	}
+
+	auth := s.URL(AuthService)
+	password := "stand-in-" + randomHex(8)
+	accept := map[string]string{"email": email, "token": match[1], "password": password}
+	if err := call("POST", auth+"/api/v1/authentication/admin/accept-invite", "", accept, nil, http.StatusOK); err != nil {
+		return err
+	}
+
+	var login struct {
+		Token string `json:"token"`
+	}
+	credentials := map[string]string{"email": email, "password": password}
+	if err := call("POST", auth+"/api/v1/authentication/admin/login", "", credentials, &login, http.StatusOK); err != nil {
+		return err
+	}
+	clinical := []string{
+		s.URL(AdminService) + "/api/v1/admin/referrals",
+		fmt.Sprintf("%s/api/v1/fes/trends?user_id=%d", s.URL(FallsEfficacyService), session.seniorID),
+		fmt.Sprintf("%s/api/v1/selfAssessment/trends?user_id=%d", s.URL(SelfAssessmentService), session.seniorID),
+		s.URL(UserService) + "/api/v1/user/risk/all",
+	}
+	for _, url := range clinical {
+		if err := call("GET", url, login.Token, nil, nil, http.StatusOK); err != nil {
+			return err
+		}
+	}
+
+	deactivate := map[string]int{"admin_id": clinician.UserID}
+	if err := call("POST", s.URL(AdminService)+"/api/v1/admin/admins/deactivate", session.adminToken, deactivate, nil, http.StatusOK); err != nil {
+		return err
+	}
+	for _, url := range clinical {
+		if err := expectError("GET", url, login.Token, nil, http.StatusUnauthorized, "unauthorized"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pageLists follows the cursors of the list endpoints a page of two rows at a time, in both
+// directions and on columns with ties, and checks the pages add up to the whole list
+func pageLists(s *Stack, session *session) error {
+	lists := []string{
+		s.URL(AdminService) + "/api/v1/admin/admins?sort=name",
+		s.URL(AdminService) + "/api/v1/admin/admins?sort=-admin_role",
+		s.URL(FallsEfficacyService) + "/api/v1/fes/getAllIndividualRes?sort=response_score",
+		s.URL(FallsEfficacyService) + "/api/v1/fes/getAllIndividualRes?sort=-response_score",
+		s.URL(SelfAssessmentService) + "/api/v1/selfAssessment/getAllAvgTime?sort=-time_taken",
+		s.URL(SelfAssessmentService) + "/api/v1/selfAssessment/getAllAvgTime?sort=session_date",
+	}
+	for _, url := range lists {
+		whole, next, err := list(url+"&limit=1000", session.adminToken)
+		if err != nil {
+			return err
+		}
+		if next != "" {
+			return fmt.Errorf("%s has a next cursor after its only page", url)
+		}
+		if len(whole) < 2 {
+			return fmt.Errorf("%s has %d rows, too few to page through", url, len(whole))
+		}
+
+		var paged []json.RawMessage
+		for pageURL := url + "&limit=2"; ; {
+			page, next, err := list(pageURL, session.adminToken)
+			if err != nil {
+				return err
+			}
+			paged = append(paged, page...)
+			if next == "" {
+				break
+			}
+			if len(paged) > len(whole) {
+				return fmt.Errorf("%s returned more rows a page at a time than at once", url)
+			}
+			pageURL = url + "&limit=2&cursor=" + next
+		}
+		if len(paged) != len(whole) {
+			return fmt.Errorf("%s returned %d rows a page at a time, and %d at once", url, len(paged), len(whole))
+		}
+		for i := range whole {
+			if string(paged[i]) != string(whole[i]) {
+				return fmt.Errorf("%s row %d is %s a page at a time, and %s at once", url, i, paged[i], whole[i])
+			}
+		}
+	}
+
+	// A cursor continues only the sort it was made for
+	_, next, err := list(lists[0]+"&limit=1", session.adminToken)
+	if err != nil {
+		return err
+	}
+	return expectError("GET", s.URL(AdminService)+"/api/v1/admin/admins?sort=-name&limit=1&cursor="+next, session.adminToken, nil,
+		http.StatusBadRequest, "invalid_field")
+}
+
+// useGateway logs in as the senior through the gateway and calls the services as the frontend
+// does: once with the token and CORS, once without the token, on a route only the services call
+// each other on, and over the self-assessment WebSocket with the token in the query
+func useGateway(s *Stack, session *session) error {
+	gateway := s.URL(GatewayService)
+	var login struct {
+		Token string `json:"token"`
+	}
+	credentials := map[string]string{"email": session.seniorEmail, "password": session.seniorPassword}
+	if err := call("POST", gateway+"/api/v1/authentication/user/login", "", credentials, &login, http.StatusOK); err != nil {
+		return err
+	}
+
+	// The gateway answers CORS once, in place of the service's own headers
+	req, err := http.NewRequest("GET", gateway+"/api/v1/user/risk", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+login.Token)
+	req.Header.Set("Origin", s.URL(FrontendService))
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET /api/v1/user/risk through the gateway returned %d", resp.StatusCode)
+	}
+	for _, header := range []string{"Access-Control-Allow-Origin", "X-Request-ID"} {
+		if values := resp.Header.Values(header); len(values) != 1 {
+			return fmt.Errorf("the gateway answered with %s %q, expected one value", header, values)
+		}
+	}
+
+	// The request is traced from the gateway into the user service
+	var traceID trace.TraceID
+	for _, span := range s.Spans.Ended() {
+		if span.Name() == "GET /api/v1/user/" && span.SpanKind() == trace.SpanKindServer {
+			traceID = span.SpanContext().TraceID()
+		}
+	}
+	traced := false
+	for _, span := range s.Spans.Ended() {
+		if span.Name() == "GET /api/v1/user/risk" && span.SpanKind() == trace.SpanKindServer && span.SpanContext().TraceID() == traceID {
+			traced = true
+		}
+	}
+	if !traceID.IsValid() || !traced {
+		return fmt.Errorf("the trace of the request through the gateway does not reach the user service")
+	}
+
+	if err := expectError("GET", gateway+"/api/v1/user/risk", "", nil, http.StatusUnauthorized, "unauthorized"); err != nil {
+		return err
+	}
+	if err := expectError("GET", fmt.Sprintf("%s/api/v1/user/getUser?userID=%d", gateway, session.seniorID), login.Token, nil, http.StatusNotFound, "not_found"); err != nil {
+		return err
+	}
+	if err := expectError("GET", gateway+"/api/v1/admin/dashboard", login.Token, nil, http.StatusForbidden, "forbidden"); err != nil {
+		return err
+	}
+
+	// The senior's results need their token, and the metrics are not served on the public port
+	results := fmt.Sprintf("%s/api/v1/selfAssessment/getUserResults?user_id=%d", gateway, session.seniorID)
+	if err := call("GET", results, login.Token, nil, nil, http.StatusOK); err != nil {
+		return err
+	}
+	if err := expectError("GET", results, "", nil, http.StatusUnauthorized, "unauthorized"); err != nil {
+		return err
+	}
+	if err := expectError("GET", gateway+"/metrics", "", nil, http.StatusNotFound, "not_found"); err != nil {
+		return err
+	}
+
+	ws := strings.Replace(gateway, "http://", "ws://", 1) + "/api/v1/selfAssessment/ws"
+	if _, resp, err := websocket.DefaultDialer.Dial(ws, nil); err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		return fmt.Errorf("the gateway opened the self-assessment WebSocket without a token")
+	}
+	risk, err := captureMovements(s, ws+"?token="+login.Token)
+	if err != nil {
+		return err
+	}
+	if risk.RiskLevel != "high" {
+		return fmt.Errorf("device risk through the gateway is %+v, expected high", risk)
+	}
+	return nil
+}
+
+// sensorTraceParent is the trace context the stand-in device sends with its first reading
+const (
+	sensorTraceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	sensorTraceParent = "00-" + sensorTraceID + "-00f067aa0ba902b7-01"
+)
+
+// traceRequests follows the insights request through the user, FES and OpenAI services to the
+// OpenAI API by its trace, and checks the device's trace reached the self-assessment service
+func traceRequests(s *Stack, session *session) error {
+	spans := s.Spans.Ended()
+
+	var insights trace.TraceID
+	for _, span := range spans {
+		if span.Name() == "GET /api/v1/user/getAUserFESResults" && span.SpanKind() == trace.SpanKindServer {
+			insights = span.SpanContext().TraceID()
+		}
+	}
+	if !insights.IsValid() {
+		return fmt.Errorf("no span for the insights request among %d spans", len(spans))
+	}
+	want := map[string]trace.SpanKind{
+		"GET /api/v1/fes/getFESResults": trace.SpanKindServer,
+		"POST /api/v1/generateResponse": trace.SpanKindServer,
+		"openai chat/completions":       trace.SpanKindClient,
+		"POST /v1/chat/completions":     trace.SpanKindClient,
+	}
+	for _, span := range spans {
+		if span.SpanContext().TraceID() == insights && want[span.Name()] == span.SpanKind() {
+			delete(want, span.Name())
+		}
+	}
+	if len(want) > 0 {
+		return fmt.Errorf("the insights trace has no spans for %v", want)
+	}
+
+	for _, span := range spans {
+		if span.Name() == "receive "+devicePublishTopic && span.SpanContext().TraceID().String() == sensorTraceID {
+			if len(span.Links()) == 0 {
+				return fmt.Errorf("the reading's span is not linked to its capture session")
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("no span continues the device's trace %s", sensorTraceID)
+}
+
+// serviceMetrics are metrics each service must expose once the earlier flows have exercised it
+var serviceMetrics = map[string][]string{
+	AuthService:           {"http_request_duration_seconds", "db_query_duration_seconds"},
+	UserService:           {"http_request_duration_seconds", "db_query_duration_seconds"},
+	FallsEfficacyService:  {"http_request_duration_seconds", "db_query_duration_seconds"},
+	SelfAssessmentService: {"http_request_duration_seconds", "db_query_duration_seconds", "mqtt_messages_received_total", "websocket_sessions_active"},
+	AdminService:          {"http_request_duration_seconds", "db_query_duration_seconds"},
+	OpenAIService:         {"http_request_duration_seconds", "openai_request_duration_seconds", "openai_cost_dollars_total"},
+}
+
+// probeServices checks every service is live and ready, as the k8s probes do, and that
+// Prometheus can scrape the metrics the earlier flows produced
+func probeServices(s *Stack, session *session) error {
+	for service, metrics := range serviceMetrics {
+		var status struct {
+			Status string            `json:"status"`
+			Checks map[string]string `json:"checks"`
+		}
+		if err := call("GET", s.URL(service)+"/healthz", "", nil, nil, http.StatusOK); err != nil {
+			return err
+		}
+		if err := call("GET", s.URL(service)+"/readyz", "", nil, &status, http.StatusOK); err != nil {
+			return err
+		}
+		if status.Status != "ok" || len(status.Checks) == 0 {
+			return fmt.Errorf("%s readiness reported %+v", service, status)
+		}
+
+		resp, err := httpClient.Get(s.URL(service) + "/metrics")
+		if err != nil {
+			return err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		for _, metric := range metrics {
+			if !strings.Contains(string(body), "\n"+metric) {
+				return fmt.Errorf("%s does not expose %s", service, metric)
+			}
+		}
+		if service == OpenAIService && strings.Contains(string(body), `openai_cost_dollars_total{model="gpt-4o"} 0`+"\n") {
+			return fmt.Errorf("%s recorded no cost for the insights generated", service)
+		}
+	}
+	return nil
+}
+
+// checkErrors checks that every service answers unknown routes, the wrong method, a missing
+// token, a senior's token on another senior's results or an internal endpoint and an invalid body with the JSON error shape of shared/apierror, and that the
+// request ID in it matches the response's
+func checkErrors(s *Stack, session *session) error {
+	for service := range serviceMetrics {
+		if err := expectError("GET", s.URL(service)+"/api/v1/no-such-route", "", nil, http.StatusNotFound, "not_found"); err != nil {
+			return err
+		}
+	}
+	checks := []struct {
+		method, url, token string
+		body               io.Reader
+		status             int
+		code               string
+	}{
+		{"DELETE", s.URL(AuthService) + "/api/v1/authentication/user/login", "", nil, http.StatusMethodNotAllowed, "method_not_allowed"},
+		{"POST", s.URL(AuthService) + "/api/v1/authentication/user/login", "", strings.NewReader("not json"), http.StatusBadRequest, "invalid_body"},
+		{"GET", s.URL(FallsEfficacyService) + "/api/v1/questions", "", nil, http.StatusUnauthorized, "unauthorized"},
+		{"GET", s.URL(AdminService) + "/api/v1/admin/dashboard", session.seniorToken, nil, http.StatusForbidden, "forbidden"},
+		{"GET", fmt.Sprintf("%s/api/v1/fes/getFESResults?user_id=%d", s.URL(FallsEfficacyService), session.seniorID+1), session.seniorToken, nil, http.StatusForbidden, "forbidden"},
+		{"GET", fmt.Sprintf("%s/api/v1/selfAssessment/getUserResults?user_id=%d", s.URL(SelfAssessmentService), session.seniorID), "", nil, http.StatusUnauthorized, "unauthorized"},
+		{"GET", fmt.Sprintf("%s/api/v1/selfAssessment/getUserResults?user_id=%d", s.URL(SelfAssessmentService), session.seniorID+1), session.seniorToken, nil, http.StatusForbidden, "forbidden"},
+		{"POST", s.URL(FallsEfficacyService) + "/api/v1/saveResponses", session.seniorToken, strings.NewReader(fmt.Sprintf(`{"user_id":%d,"responses":[]}`, session.seniorID+1)), http.StatusForbidden, "forbidden"},
+		{"POST", fmt.Sprintf("%s/api/v1/selfAssessment/startTest?userID=%d", s.URL(SelfAssessmentService), session.seniorID+1), session.seniorToken, nil, http.StatusForbidden, "forbidden"},
+		{"POST", s.URL(SelfAssessmentService) + "/api/v1/selfAssessment/saveTestResult", session.seniorToken, strings.NewReader(fmt.Sprintf(`{"testSessionID":1,"userID":%d,"testID":1}`, session.seniorID+1)), http.StatusForbidden, "forbidden"},
+		{"POST", s.URL(UserService) + "/api/v1/user/risk/observations", "", nil, http.StatusUnauthorized, "unauthorized"},
+		{"POST", s.URL(UserService) + "/api/v1/user/risk/observations", session.seniorToken, nil, http.StatusForbidden, "forbidden"},
+		{"POST", s.URL(UserService) + "/api/v1/user/create", "", nil, http.StatusUnauthorized, "unauthorized"},
+		{"POST", s.URL(UserService) + "/api/v1/user/create", session.seniorToken, nil, http.StatusForbidden, "forbidden"},
+		{"GET", s.URL(UserService) + "/api/v1/user/caregiver/checkAccess?caregiver_id=1&senior_id=1&scope=results", "", nil, http.StatusUnauthorized, "unauthorized"},
+		{"GET", s.URL(UserService) + "/api/v1/user/caregiver/checkAccess?caregiver_id=1&senior_id=1&scope=results", session.seniorToken, nil, http.StatusForbidden, "forbidden"},
+		{"POST", s.URL(AdminService) + "/api/v1/admin/referrals/open", "", nil, http.StatusUnauthorized, "unauthorized"},
+		{"POST", s.URL(AdminService) + "/api/v1/admin/referrals/open", session.seniorToken, nil, http.StatusForbidden, "forbidden"},
+	}
+	for _, check := range checks {
+		if err := expectError(check.method, check.url, check.token, check.body, check.status, check.code); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expectError sends a request the service should refuse and checks the error it answers with
+func expectError(method, url, token string, body io.Reader, status int, code string) error {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var envelope struct {
+		Error struct {
+			Code      string `json:"code"`
+			Message   string `json:"message"`
+			RequestID string `json:"request_id"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return fmt.Errorf("%s %s returned an error that is not JSON: %v", method, url, err)
+	}
+	if resp.StatusCode != status || envelope.Error.Code != code || envelope.Error.Message == "" {
+		return fmt.Errorf("%s %s returned %d %+v, expected %d with code %s", method, url, resp.StatusCode, envelope.Error, status, code)
+	}
+	if envelope.Error.RequestID == "" || envelope.Error.RequestID != resp.Header.Get("X-Request-ID") {
+		return fmt.Errorf("%s %s returned request ID %q in the error and %q in the header", method, url, envelope.Error.RequestID, resp.Header.Get("X-Request-ID"))
+	}
+	return nil
+}
+
+// checkRoutes checks that each service serves exactly the /api/v1 operations its OpenAPI spec
+// describes
+func checkRoutes(s *Stack, session *session) error {
+	var problems []string
+	for _, c := range s.contracts {
+		missing, err := c.checkRoutes()
+		if err != nil {
+			return fmt.Errorf("failed to walk the %s routes: %v", c.service, err)
+		}
+		problems = append(problems, missing...)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("the routes and specs differ:\n      %s", strings.Join(problems, "\n      "))
+	}
+	return nil
+}
+
+// checkExchanges checks that every request the earlier flows made, and every response to it,
+// matched the services' OpenAPI specs
+func checkExchanges(s *Stack, session *session) error {
+	var violations []string
+	for _, c := range s.contracts {
+		violations = append(violations, c.Violations()...)
+	}
+	if len(violations) > 0 {
+		return fmt.Errorf("%d exchanges broke the specs:\n      %s", len(violations), strings.Join(violations, "\n      "))
+	}
+	return nil
+}
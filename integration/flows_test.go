@@ -0,0 +1,53 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// integrationEnv must be set to 1 for go test to boot the stack and run the flows, which takes
+// a while and listens on local ports
+const integrationEnv = "FALLSAFE_INTEGRATION"
+
+// TestFlows boots the stack and runs each flow as a subtest, in order. The flows build on the
+// earlier ones, so once one fails the rest are skipped.
+func TestFlows(t *testing.T) {
+	if os.Getenv(integrationEnv) != "1" {
+		t.Skipf("set %s=1 to run the flows against the stack", integrationEnv)
+	}
+
+	// The services log through slog, whose default handler writes to the standard logger, and the
+	// MySQL stand-in through logrus
+	if !testing.Verbose() {
+		log.SetOutput(io.Discard)
+		logrus.SetOutput(io.Discard)
+		t.Cleanup(func() {
+			log.SetOutput(os.Stderr)
+			logrus.SetOutput(os.Stderr)
+		})
+	}
+
+	stack, err := StartStack("../frontend")
+	if err != nil {
+		t.Fatalf("failed to start the stack: %v", err)
+	}
+	t.Cleanup(stack.Close)
+
+	session := &session{}
+	failed := false
+	for _, f := range flows {
+		t.Run(f.name, func(t *testing.T) {
+			if failed {
+				t.Skip("an earlier flow failed")
+			}
+			if err := f.run(stack, session); err != nil {
+				failed = true
+				t.Fatal(err)
+			}
+		})
+	}
+}
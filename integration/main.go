@@ -0,0 +1,83 @@
+// The integration harness boots every FallSafe service in this process, against an embedded
+// MySQL-compatible database, a local MQTT broker and stand-in SMTP and OpenAI servers, then
+// drives a senior and an admin through the services from registration to the admin dashboard,
+// checking every exchange against the services' OpenAPI specs.
+//
+//	go run .            run the flows and exit non-zero if any fail
+//	go run . -v         also print the services' logs
+//	go run . -serve     keep the stack running after the flows, to use it from the frontend
+//
+// The same flows run as subtests of TestFlows under go test when FALLSAFE_INTEGRATION=1.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func main() {
+	frontendDir := flag.String("frontend", "../frontend", "directory the frontend is served from")
+	verbose := flag.Bool("v", false, "print the services' logs")
+	keepServing := flag.Bool("serve", false, "keep the stack running after the flows until interrupted")
+	flag.Parse()
+
+	// The services log through slog, whose default handler writes to the standard logger, and the
+	// MySQL stand-in through logrus, which would bury the results
+	if !*verbose {
+		log.SetOutput(io.Discard)
+		logrus.SetOutput(io.Discard)
+	}
+
+	stack, err := StartStack(*frontendDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to start the stack: %v\n", err)
+		os.Exit(1)
+	}
+	defer stack.Close()
+
+	failed := runFlows(stack)
+
+	if *keepServing {
+		for _, service := range []string{FrontendService, GatewayService, AuthService, UserService, FallsEfficacyService, SelfAssessmentService, AdminService, OpenAIService} {
+			fmt.Printf("%-24s %s\n", service, stack.URL(service))
+		}
+		fmt.Printf("Admin login: %s / %s\n", superAdminEmail, superAdminPassword)
+		fmt.Println("Serving until interrupted")
+		interrupt := make(chan os.Signal, 1)
+		signal.Notify(interrupt, os.Interrupt)
+		<-interrupt
+	}
+
+	if failed {
+		stack.Close()
+		os.Exit(1)
+	}
+}
+
+// runFlows runs the flows in order, skipping the rest once one fails since they build on the
+// earlier ones, and reports whether any failed
+func runFlows(stack *Stack) bool {
+	session := &session{}
+	failed := false
+	for _, f := range flows {
+		if failed {
+			fmt.Printf("SKIP  %s\n", f.name)
+			continue
+		}
+		start := time.Now()
+		if err := f.run(stack, session); err != nil {
+			failed = true
+			fmt.Printf("FAIL  %s (%s)\n      %v\n", f.name, time.Since(start).Round(time.Millisecond), err)
+			continue
+		}
+		fmt.Printf("PASS  %s (%s)\n", f.name, time.Since(start).Round(time.Millisecond))
+	}
+	return failed
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	adminapi "adminMicroservice/api"
+	authapi "authenticationMicroservice/api"
+	fesapi "fallsEfficacyScaleMicroservice/api"
+	openaiapi "openAIMicroservice/api"
+	selfapi "selfAssessmentMicroservice/api"
+	templateapi "templateMicroservice/api"
+	userapi "userMicroservice/api"
+
+	"shared/apierror"
+	"shared/logging"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// errorCodes are the codes of the apierror package, with the status each is written with
+var errorCodes = map[apierror.Code]int{
+	apierror.InvalidBody:         http.StatusBadRequest,
+	apierror.MissingField:        http.StatusBadRequest,
+	apierror.InvalidField:        http.StatusBadRequest,
+	apierror.Unauthorized:        http.StatusUnauthorized,
+	apierror.InvalidCredentials:  http.StatusUnauthorized,
+	apierror.InvalidCode:         http.StatusUnauthorized,
+	apierror.InvalidToken:        http.StatusUnauthorized,
+	apierror.Forbidden:           http.StatusForbidden,
+	apierror.LoginMethodDisabled: http.StatusForbidden,
+	apierror.NotFound:            http.StatusNotFound,
+	apierror.MethodNotAllowed:    http.StatusMethodNotAllowed,
+	apierror.AlreadyExists:       http.StatusConflict,
+	apierror.EditConflict:        http.StatusConflict,
+	apierror.InvalidTransition:   http.StatusConflict,
+	apierror.LastSuperAdmin:      http.StatusConflict,
+	apierror.RateLimited:         http.StatusTooManyRequests,
+	apierror.Internal:            http.StatusInternalServerError,
+	apierror.UpstreamFailed:      http.StatusBadGateway,
+	apierror.Unavailable:         http.StatusServiceUnavailable,
+	apierror.AccountPending:      http.StatusServiceUnavailable,
+	apierror.UpstreamTimeout:     http.StatusGatewayTimeout,
+}
+
+// specs are the services' OpenAPI specs
+var specs = []struct {
+	service string
+	spec    []byte
+}{
+	{AdminService, adminapi.Spec},
+	{AuthService, authapi.Spec},
+	{FallsEfficacyService, fesapi.Spec},
+	{OpenAIService, openaiapi.Spec},
+	{SelfAssessmentService, selfapi.Spec},
+	{"template-service", templateapi.Spec},
+	{UserService, userapi.Spec},
+}
+
+// loadSpec loads and validates a service's spec
+func loadSpec(t *testing.T, service string, spec []byte) *openapi3.T {
+	t.Helper()
+	doc, err := openapi3.NewLoader().LoadFromData(spec)
+	if err != nil {
+		t.Fatalf("failed to load the %s spec: %v", service, err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		t.Fatalf("invalid %s spec: %v", service, err)
+	}
+	return doc
+}
+
+// TestSpecsAreValid checks each spec is valid OpenAPI 3, describes only versioned routes and
+// describes every error response with the shared Error schema. The aggregate endpoints answer 502
+// with the sources that did answer, so an error may also carry the operation's own 200 body.
+func TestSpecsAreValid(t *testing.T) {
+	for _, s := range specs {
+		t.Run(s.service, func(t *testing.T) {
+			doc := loadSpec(t, s.service, s.spec)
+			errorSchema := doc.Components.Schemas["Error"]
+			if errorSchema == nil || errorSchema.Value == nil {
+				t.Fatal("the spec has no Error schema")
+			}
+			if len(doc.Paths.Map()) == 0 {
+				t.Fatal("the spec describes no routes")
+			}
+
+			for path, item := range doc.Paths.Map() {
+				if len(path) < len(apiPrefix) || path[:len(apiPrefix)] != apiPrefix {
+					t.Errorf("%s is not under %s", path, apiPrefix)
+				}
+				for method, operation := range item.Operations() {
+					partial := jsonSchema(operation.Responses.Status(http.StatusOK))
+					for status, response := range operation.Responses.Map() {
+						code, err := strconv.Atoi(status)
+						if status != "default" && (err != nil || code < http.StatusBadRequest) {
+							continue
+						}
+						schema := jsonSchema(response)
+						if schema == nil || (schema != errorSchema.Value && (code != http.StatusBadGateway || schema != partial)) {
+							t.Errorf("%s %s answers %s without the Error schema", method, path, status)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+// jsonSchema returns the schema of a response's JSON body, or nil if it has none
+func jsonSchema(response *openapi3.ResponseRef) *openapi3.Schema {
+	if response == nil || response.Value == nil {
+		return nil
+	}
+	content := response.Value.Content.Get("application/json")
+	if content == nil || content.Schema == nil {
+		return nil
+	}
+	return content.Schema.Value
+}
+
+// TestErrorEnvelope checks that the errors the services write, with every code, match each spec's
+// Error schema, and carry the request's ID
+func TestErrorEnvelope(t *testing.T) {
+	for _, s := range specs {
+		t.Run(s.service, func(t *testing.T) {
+			errorSchema := loadSpec(t, s.service, s.spec).Components.Schemas["Error"].Value
+
+			// check serves a request through the logging middleware, as the services do, and checks
+			// the error it answers with
+			check := func(name string, handler http.Handler, status int) {
+				recorder := httptest.NewRecorder()
+				logging.Middleware(handler).ServeHTTP(recorder, httptest.NewRequest("POST", "/api/v1/anything", nil))
+
+				if recorder.Code != status || recorder.Header().Get("Content-Type") != "application/json" {
+					t.Errorf("%s was written as %d %s", name, recorder.Code, recorder.Header().Get("Content-Type"))
+				}
+				var body interface{}
+				if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+					t.Fatalf("%s was written as invalid JSON: %v", name, err)
+				}
+				if err := errorSchema.VisitJSON(body); err != nil {
+					t.Errorf("%s does not match the Error schema: %v", name, err)
+				}
+
+				detail := body.(map[string]interface{})["error"].(map[string]interface{})
+				if id := recorder.Header().Get(logging.RequestIDHeader); id == "" || detail["request_id"] != id {
+					t.Errorf("%s carries request ID %v, and the response %q", name, detail["request_id"], id)
+				}
+			}
+
+			for code, status := range errorCodes {
+				check(string(code), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					apierror.Write(w, r, status, code, "Something went wrong")
+				}), status)
+			}
+			check("an unknown route", apierror.NotFoundHandler(), http.StatusNotFound)
+			check("an unknown method", apierror.MethodNotAllowedHandler(), http.StatusMethodNotAllowed)
+
+			// A code the spec does not list breaks it, so the check above cannot pass by accident
+			recorder := httptest.NewRecorder()
+			apierror.Write(recorder, httptest.NewRequest("GET", "/", nil), http.StatusTeapot, "no_such_code", "Teapot")
+			var body interface{}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if errorSchema.VisitJSON(body) == nil {
+				t.Error("the Error schema accepts any code")
+			}
+		})
+	}
+}
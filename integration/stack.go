@@ -0,0 +1,376 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"adminMicroservice/admin"
+	adminapi "adminMicroservice/api"
+	adminmigrations "adminMicroservice/migrations"
+	adminserver "adminMicroservice/server"
+	authapi "authenticationMicroservice/api"
+	"authenticationMicroservice/authentication"
+	authmigrations "authenticationMicroservice/migrations"
+	"authenticationMicroservice/registration"
+	authserver "authenticationMicroservice/server"
+	"authenticationMicroservice/throttle"
+	"fallsEfficacyScaleMicroservice/FES"
+	fesapi "fallsEfficacyScaleMicroservice/api"
+	fesuser "fallsEfficacyScaleMicroservice/client/user"
+	fesmigrations "fallsEfficacyScaleMicroservice/migrations"
+	fesserver "fallsEfficacyScaleMicroservice/server"
+	gatewayconfig "gatewayMicroservice/config"
+	"gatewayMicroservice/gateway"
+	gatewayserver "gatewayMicroservice/server"
+	"integration/standin"
+	openaiapi "openAIMicroservice/api"
+	"openAIMicroservice/openAI"
+	openaiserver "openAIMicroservice/server"
+	selfapi "selfAssessmentMicroservice/api"
+	selfuser "selfAssessmentMicroservice/client/user"
+	selfmigrations "selfAssessmentMicroservice/migrations"
+	"selfAssessmentMicroservice/selfAssessment"
+	selfserver "selfAssessmentMicroservice/server"
+	userapi "userMicroservice/api"
+	usermigrations "userMicroservice/migrations"
+	"userMicroservice/profile"
+	userserver "userMicroservice/server"
+
+	"shared/client"
+	"shared/migrate"
+	"shared/observability"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Service names, matching the names in each service's config package
+const (
+	AdminService          = "admin-service"
+	AuthService           = "auth-service"
+	FallsEfficacyService  = "fallsefficacy-service"
+	OpenAIService         = "openai-service"
+	SelfAssessmentService = "selfassessment-service"
+	UserService           = "user-service"
+	FrontendService       = "frontend-service"
+	GatewayService        = "gateway-service"
+)
+
+// serviceEnv is the environment variable each service's URL is resolved from
+var serviceEnv = map[string]string{
+	AdminService:          "ADMIN_SERVICE_URL",
+	AuthService:           "AUTH_SERVICE_URL",
+	FallsEfficacyService:  "FALLSEFFICACY_SERVICE_URL",
+	OpenAIService:         "OPENAI_SERVICE_URL",
+	SelfAssessmentService: "SELFASSESSMENT_SERVICE_URL",
+	UserService:           "USER_SERVICE_URL",
+	FrontendService:       "FRONTEND_SERVICE_URL",
+	GatewayService:        "GATEWAY_SERVICE_URL",
+}
+
+// Databases owned by the services, named as in database/initialiseDatabase.sql
+const (
+	authDatabase  = "FallSafe_AuthenticationDB"
+	userDatabase  = "FallSafe_UserDB"
+	fesDatabase   = "FallSafe_FallsEfficacyScaleDB"
+	selfDatabase  = "FallSafe_SelfAssessmentDB"
+	adminDatabase = "FallSafe_AdminDB"
+)
+
+// The super admin seeded into the authentication and admin databases, as demoData.sql does
+const (
+	superAdminEmail    = "superadmin@fallsafe.test"
+	superAdminPassword = "stand-in-super-admin"
+)
+
+// Stack is the eight FallSafe services and the stand-ins they depend on, running in this process
+type Stack struct {
+	MySQL  *standin.MySQL
+	SMTP   *standin.SMTP
+	OpenAI *standin.OpenAI
+	MQTT   *standin.MQTT
+
+	// Spans keeps the spans every service ended, as the OTLP collector would receive them
+	Spans *tracetest.SpanRecorder
+
+	JWTSecret   string
+	urls        map[string]string
+	dbs         []*sql.DB
+	servers     []*http.Server
+	contracts   []*contract
+	stopWorkers context.CancelFunc
+	drains      []func(context.Context) error
+}
+
+// StartStack starts the stand-ins, points the services at them through the environment,
+// migrates the databases and serves every service on a free local port. The frontend is
+// served from frontendDir.
+func StartStack(frontendDir string) (*Stack, error) {
+	s := &Stack{JWTSecret: randomHex(32), urls: map[string]string{}}
+	if err := s.start(frontendDir); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// URL returns the base URL of a service
+func (s *Stack) URL(service string) string {
+	return s.urls[service]
+}
+
+// Close stops the services and the stand-ins
+func (s *Stack) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, server := range s.servers {
+		server.Shutdown(ctx)
+	}
+	if s.stopWorkers != nil {
+		s.stopWorkers()
+	}
+	selfAssessment.Shutdown(ctx)
+	for _, drain := range s.drains {
+		drain(ctx)
+	}
+	for _, db := range s.dbs {
+		db.Close()
+	}
+	if s.MQTT != nil {
+		s.MQTT.Close()
+	}
+	if s.OpenAI != nil {
+		s.OpenAI.Close()
+	}
+	if s.SMTP != nil {
+		s.SMTP.Close()
+	}
+	if s.MySQL != nil {
+		s.MySQL.Close()
+	}
+}
+
+func (s *Stack) start(frontendDir string) error {
+	// The services share this process's tracer provider, where each main would set up its own
+	s.Spans = tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(s.Spans)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var err error
+	if s.MySQL, err = standin.StartMySQL(authDatabase, userDatabase, fesDatabase, selfDatabase, adminDatabase); err != nil {
+		return err
+	}
+	if s.SMTP, err = standin.StartSMTP(); err != nil {
+		return err
+	}
+	if s.MQTT, err = standin.StartMQTT(); err != nil {
+		return err
+	}
+	openAIKey := "sk-stand-in-" + randomHex(8)
+	s.OpenAI = standin.StartOpenAI(openAIKey)
+
+	// Each service needs its listener before any client is created, since the clients
+	// read the other services' URLs when they are created
+	listeners := map[string]net.Listener{}
+	for service := range serviceEnv {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return fmt.Errorf("failed to listen for %s: %v", service, err)
+		}
+		listeners[service] = listener
+		s.urls[service] = "http://" + listener.Addr().String()
+	}
+	serve := func(service string, handler http.Handler) {
+		server := &http.Server{Handler: handler}
+		s.servers = append(s.servers, server)
+		go server.Serve(listeners[service])
+	}
+	// The services with an OpenAPI spec are served behind a check of every exchange against it
+	serveChecked := func(service string, spec []byte, routes *mux.Router, handler http.Handler) error {
+		c, err := newContract(service, spec, s.urls[service], routes)
+		if err != nil {
+			return err
+		}
+		s.contracts = append(s.contracts, c)
+		serve(service, c.Middleware(handler))
+		return nil
+	}
+
+	env := map[string]string{
+		"JWT_SECRET":        s.JWTSecret,
+		"FRONTEND_URL":      s.urls[FrontendService],
+		"ALLOWED_ORIGINS":   s.urls[FrontendService],
+		"SERVICES_CONFIG":   "",
+		"SMTP_HOST":         "127.0.0.1",
+		"SMTP_PORT":         s.SMTP.Port(),
+		"SMTP_USER":         "noreply@fallsafe.test",
+		"SMTP_PASSWORD":     "stand-in",
+		"OPENAI_API_URL":    s.OpenAI.URL(),
+		"OPENAI_APIKEY":     openAIKey,
+		"MQTT_BROKER_URL":   s.MQTT.URL(),
+		"AWS_IOT_CLIENT_ID": "fallsafe-selfassessment",
+	}
+	for service, name := range serviceEnv {
+		env[name] = s.urls[service]
+	}
+	for name, value := range env {
+		if err := os.Setenv(name, value); err != nil {
+			return err
+		}
+	}
+
+	// Each database is migrated as its service does on start
+	migrations := []struct {
+		database string
+		files    fs.FS
+	}{
+		{authDatabase, authmigrations.Files},
+		{userDatabase, usermigrations.Files},
+		{fesDatabase, fesmigrations.Files},
+		{selfDatabase, selfmigrations.Files},
+		{adminDatabase, adminmigrations.Files},
+	}
+	for _, m := range migrations {
+		if err := migrate.Command(s.MySQL.DSN(m.database), m.files, []string{"up"}); err != nil {
+			return fmt.Errorf("failed to migrate %s: %v", m.database, err)
+		}
+	}
+
+	authDB, err := s.open(authentication.OpenDB, authDatabase)
+	if err != nil {
+		return err
+	}
+	userDB, err := s.open(profile.OpenDB, userDatabase)
+	if err != nil {
+		return err
+	}
+	fesDB, err := s.open(FES.OpenDB, fesDatabase)
+	if err != nil {
+		return err
+	}
+	selfDB, err := s.open(selfAssessment.OpenDB, selfDatabase)
+	if err != nil {
+		return err
+	}
+	adminDB, err := s.open(admin.OpenDB, adminDatabase)
+	if err != nil {
+		return err
+	}
+	if err := seedSuperAdmin(authDB, adminDB); err != nil {
+		return err
+	}
+
+	// Readiness checks each service's dependencies as its main does
+	authHealth := observability.NewHealth()
+	authHealth.AddCheck("database", observability.PingDB(authDB))
+	for _, service := range []string{AdminService, UserService} {
+		authHealth.AddCheck(service, client.New(service).Ping)
+	}
+	userHealth := observability.NewHealth()
+	userHealth.AddCheck("database", observability.PingDB(userDB))
+	for _, service := range []string{AdminService, FallsEfficacyService, OpenAIService, SelfAssessmentService} {
+		userHealth.AddCheck(service, client.New(service).Ping)
+	}
+	fesHealth := observability.NewHealth()
+	fesHealth.AddCheck("database", observability.PingDB(fesDB))
+	fesHealth.AddCheck(UserService, client.New(UserService).Ping)
+	selfHealth := observability.NewHealth()
+	selfHealth.AddCheck("database", observability.PingDB(selfDB))
+	selfHealth.AddCheck("mqtt", selfAssessment.CheckBroker)
+	selfHealth.AddCheck(UserService, client.New(UserService).Ping)
+	adminHealth := observability.NewHealth()
+	adminHealth.AddCheck("database", observability.PingDB(adminDB))
+	for _, service := range []string{AuthService, FallsEfficacyService, SelfAssessmentService, UserService} {
+		adminHealth.AddCheck(service, client.New(service).Ping)
+	}
+	openAIHealth := observability.NewHealth()
+	openAIHealth.AddCheck("openai", openAI.CheckAPI)
+
+	// The handlers are built as each service's main builds them
+	loginThrottle := throttle.New(throttle.NewMySQLStore(authDB), nil)
+	registrations := registration.NewHandler(registration.NewMySQLStore(authDB), registration.NewClients(), loginThrottle)
+	auth := authentication.NewHandler(authentication.NewMySQLStore(authDB), authentication.NewClients(), loginThrottle, registrations, s.JWTSecret)
+	workers, stopWorkers := context.WithCancel(context.Background())
+	s.stopWorkers = stopWorkers
+	go registrations.RunOutboxWorker(workers)
+	users := profile.NewHandler(profile.NewMySQLStore(userDB), profile.NewClients())
+	fesUsers := fesuser.New()
+	fes := FES.NewHandler(FES.NewMySQLStore(fesDB), fesUsers)
+	selfUsers := selfuser.New()
+	sa := selfAssessment.NewHandler(selfAssessment.NewMySQLStore(selfDB), selfUsers)
+	s.drains = append(s.drains, fes.Drain, sa.Drain)
+	admins := admin.NewHandler(admin.NewMySQLStore(adminDB), admin.NewClients())
+	openAIHandler := openAI.NewHandler(openAI.OpenAIModel{})
+
+	services := []struct {
+		name    string
+		spec    []byte
+		routes  *mux.Router
+		handler http.Handler
+	}{
+		{AuthService, authapi.Spec, authserver.Routes(auth, registrations, authHealth), authserver.NewRouter(auth, registrations, authHealth)},
+		{UserService, userapi.Spec, userserver.Routes(users, userHealth), userserver.NewRouter(users, userHealth)},
+		{FallsEfficacyService, fesapi.Spec, fesserver.Routes(fes, fesUsers, fesHealth), fesserver.NewRouter(fes, fesUsers, fesHealth)},
+		{SelfAssessmentService, selfapi.Spec, selfserver.Routes(sa, selfUsers, selfHealth), selfserver.NewRouter(sa, selfUsers, selfHealth)},
+		{AdminService, adminapi.Spec, adminserver.Routes(admins, adminHealth), adminserver.NewRouter(admins, adminHealth)},
+		{OpenAIService, openaiapi.Spec, openaiserver.Routes(openAIHandler, openAIHealth), openaiserver.NewRouter(openAIHandler, openAIHealth)},
+	}
+	for _, service := range services {
+		if err := serveChecked(service.name, service.spec, service.routes, service.handler); err != nil {
+			return err
+		}
+	}
+
+	// The gateway fronts the services as it does in production
+	rateLimit := gatewayconfig.Current().RateLimit
+	gateways := gateway.New(gateway.NewLimiter(rateLimit.PerMinute, rateLimit.Burst))
+	serve(GatewayService, gatewayserver.NewRouter(gateways, observability.NewHealth()))
+
+	serve(FrontendService, http.FileServer(http.Dir(frontendDir)))
+	return nil
+}
+
+// open connects to a service database with the service's own OpenDB
+func (s *Stack) open(openDB func(dsn string) (*sql.DB, error), database string) (*sql.DB, error) {
+	db, err := openDB(s.MySQL.DSN(database))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", database, err)
+	}
+	s.dbs = append(s.dbs, db)
+	return db, nil
+}
+
+// seedSuperAdmin adds the super admin the admin flows log in as, with the same ID in the
+// authentication and admin databases
+func seedSuperAdmin(authDB, adminDB *sql.DB) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(superAdminPassword), bcrypt.MinCost)
+	if err != nil {
+		return err
+	}
+	if _, err := authDB.Exec(`INSERT INTO Admin (admin_id, email, password) VALUES (1, ?, ?)`, superAdminEmail, string(hash)); err != nil {
+		return fmt.Errorf("failed to seed super admin credentials: %v", err)
+	}
+	if _, err := adminDB.Exec(`INSERT INTO User (user_id, name, email, admin_role, status) VALUES (1, 'Stand-in Super Admin', ?, 'SuperAdmin', 'Active')`, superAdminEmail); err != nil {
+		return fmt.Errorf("failed to seed super admin profile: %v", err)
+	}
+	return nil
+}
+
+// randomHex returns n random bytes as hex
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,73 @@
+package standin
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+)
+
+// MQTT is a broker accepting any client over plain TCP, standing in for AWS IoT Core
+type MQTT struct {
+	server *mqtt.Server
+	addr   string
+}
+
+// StartMQTT starts a broker on a free local port
+func StartMQTT() (*MQTT, error) {
+	addr, err := freeAddress()
+	if err != nil {
+		return nil, err
+	}
+
+	server := mqtt.New(&mqtt.Options{
+		InlineClient: true,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if err := server.AddHook(new(auth.AllowHook), nil); err != nil {
+		return nil, fmt.Errorf("failed to allow MQTT clients: %v", err)
+	}
+	if err := server.AddListener(listeners.NewTCP(listeners.Config{ID: "tcp", Address: addr})); err != nil {
+		return nil, fmt.Errorf("failed to listen for MQTT: %v", err)
+	}
+	if err := server.Serve(); err != nil {
+		return nil, fmt.Errorf("failed to start MQTT broker: %v", err)
+	}
+
+	return &MQTT{server: server, addr: addr}, nil
+}
+
+// URL returns the broker address in the form MQTT_BROKER_URL takes
+func (m *MQTT) URL() string {
+	return "tcp://" + m.addr
+}
+
+// Publish sends a message to the topic's subscribers, as the FallSafe device does
+func (m *MQTT) Publish(topic string, payload []byte) error {
+	return m.server.Publish(topic, payload, false, 1)
+}
+
+// Subscribers returns the number of clients subscribed to the topic
+func (m *MQTT) Subscribers(topic string) int {
+	return len(m.server.Topics.Subscribers(topic).Subscriptions)
+}
+
+// Close stops the broker
+func (m *MQTT) Close() error {
+	return m.server.Close()
+}
+
+// freeAddress returns a local address with a port no one is listening on, for servers that
+// open their own listener
+func freeAddress() (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", fmt.Errorf("failed to find a free port: %v", err)
+	}
+	defer listener.Close()
+	return listener.Addr().String(), nil
+}
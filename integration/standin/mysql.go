@@ -0,0 +1,57 @@
+// Package standin runs local replacements for the services FallSafe depends on, so the whole
+// system can be started in one process without Docker, cloud accounts or network access:
+// an in-memory MySQL-compatible server, an SMTP server, an OpenAI API and an MQTT broker.
+package standin
+
+import (
+	"fmt"
+	"net"
+
+	sqle "github.com/dolthub/go-mysql-server"
+	"github.com/dolthub/go-mysql-server/memory"
+	"github.com/dolthub/go-mysql-server/server"
+	"github.com/dolthub/go-mysql-server/sql"
+)
+
+// MySQL is an in-memory server speaking the MySQL protocol, holding empty databases
+// for the services to migrate
+type MySQL struct {
+	server *server.Server
+	addr   string
+}
+
+// StartMySQL starts a server on a free local port with the named databases
+func StartMySQL(databases ...string) (*MySQL, error) {
+	dbs := make([]sql.Database, 0, len(databases))
+	for _, name := range databases {
+		db := memory.NewDatabase(name)
+		db.EnablePrimaryKeyIndexes()
+		dbs = append(dbs, db)
+	}
+	provider := memory.NewDBProvider(dbs...)
+	engine := sqle.NewDefault(provider)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for MySQL: %v", err)
+	}
+	config := server.Config{Protocol: "tcp", Address: listener.Addr().String(), Listener: listener}
+	s, err := server.NewServer(config, engine, sql.NewContext, memory.NewSessionBuilder(provider), nil)
+	if err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to create MySQL server: %v", err)
+	}
+	go s.Start()
+
+	return &MySQL{server: s, addr: listener.Addr().String()}, nil
+}
+
+// DSN returns the connection string of a database, in the form the services read from their .env files
+func (m *MySQL) DSN(database string) string {
+	return fmt.Sprintf("root@tcp(%s)/%s?parseTime=true", m.addr, database)
+}
+
+// Close stops the server, discarding its data
+func (m *MySQL) Close() error {
+	return m.server.Close()
+}
@@ -0,0 +1,104 @@
+package standin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// OpenAI answers the chat completion and speech endpoints of the OpenAI API with canned content,
+// keeping the prompts it is sent
+type OpenAI struct {
+	server *httptest.Server
+	apiKey string
+
+	mu      sync.Mutex
+	prompts []string
+}
+
+// Reply is the content of every chat completion
+const Reply = "1) Keep your walkways clear <br> 2) Stand up slowly <br> 3) Wear non-slip shoes <br> 4) Exercise your legs <br> 5) Light your home well"
+
+// Speech is the audio returned for every speech request
+var Speech = []byte("ID3 stand-in audio")
+
+// StartOpenAI starts the API on a free local port, accepting requests made with the key
+func StartOpenAI(apiKey string) *OpenAI {
+	o := &OpenAI{apiKey: apiKey}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/chat/completions", o.chatCompletions)
+	mux.HandleFunc("POST /v1/audio/speech", o.speech)
+	o.server = httptest.NewServer(o.authorize(mux))
+	return o
+}
+
+// URL returns the base URL of the API, without the /v1 prefix
+func (o *OpenAI) URL() string {
+	return o.server.URL
+}
+
+// Prompts returns the user messages of the chat completions requested so far
+func (o *OpenAI) Prompts() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]string(nil), o.prompts...)
+}
+
+// Close stops the API
+func (o *OpenAI) Close() {
+	o.server.Close()
+}
+
+// authorize rejects requests without the API key, as OpenAI does
+func (o *OpenAI) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+o.apiKey {
+			http.Error(w, `{"error":{"message":"Incorrect API key provided"}}`, http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (o *OpenAI) chatCompletions(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Messages []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || len(request.Messages) == 0 {
+		http.Error(w, `{"error":{"message":"messages is required"}}`, http.StatusBadRequest)
+		return
+	}
+
+	// Usage is counted in words, which is close enough to tokens for the cost metric to move
+	promptTokens := 0
+	o.mu.Lock()
+	for _, message := range request.Messages {
+		promptTokens += len(strings.Fields(message.Content))
+		if message.Role == "user" {
+			o.prompts = append(o.prompts, message.Content)
+		}
+	}
+	o.mu.Unlock()
+
+	content := Reply
+	if strings.Contains(strings.ToLower(request.Messages[0].Content), "translat") {
+		content = "stand-in translation"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"choices": []map[string]interface{}{
+			{"index": 0, "message": map[string]string{"role": "assistant", "content": content}},
+		},
+		"usage": map[string]int{"prompt_tokens": promptTokens, "completion_tokens": len(strings.Fields(content))},
+	})
+}
+
+func (o *OpenAI) speech(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "audio/mpeg")
+	w.Write(Speech)
+}
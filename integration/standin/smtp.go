@@ -0,0 +1,182 @@
+package standin
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Email is a message accepted by the SMTP server
+type Email struct {
+	From string
+	To   []string
+	Data string // Headers and body as sent
+}
+
+// SMTP is a mail server that accepts any login and keeps the messages it is sent
+type SMTP struct {
+	listener net.Listener
+
+	mu     sync.Mutex
+	emails []Email
+}
+
+// StartSMTP starts a server on a free local port. The services only log in over plain SMTP
+// to localhost, so the host to give them is 127.0.0.1.
+func StartSMTP() (*SMTP, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for SMTP: %v", err)
+	}
+
+	s := &SMTP{listener: listener}
+	go s.serve()
+	return s, nil
+}
+
+// Port returns the port the server listens on
+func (s *SMTP) Port() string {
+	_, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return port
+}
+
+// Emails returns the messages received so far
+func (s *SMTP) Emails() []Email {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Email(nil), s.emails...)
+}
+
+// WaitFor returns the latest message to the address that contains the text, waiting for it to arrive
+func (s *SMTP) WaitFor(to, contains string, timeout time.Duration) (Email, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		emails := s.Emails()
+		for i := len(emails) - 1; i >= 0; i-- {
+			if emails[i].sentTo(to) && strings.Contains(emails[i].Data, contains) {
+				return emails[i], nil
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return Email{}, fmt.Errorf("no email to %s containing %q after %s", to, contains, timeout)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// Close stops accepting connections
+func (s *SMTP) Close() error {
+	return s.listener.Close()
+}
+
+func (e Email) sentTo(address string) bool {
+	for _, to := range e.To {
+		if strings.EqualFold(to, address) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *SMTP) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+// handle runs one SMTP session, answering the commands net/smtp sends
+func (s *SMTP) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+
+	reply("220 localhost FallSafe stand-in SMTP")
+	var email Email
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(line)
+
+		switch {
+		case strings.HasPrefix(command, "EHLO"):
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "AUTH"):
+			reply("235 Authentication successful")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			email = Email{From: address(line[len("MAIL FROM:"):])}
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			email.To = append(email.To, address(line[len("RCPT TO:"):]))
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			data, err := readData(reader)
+			if err != nil {
+				return
+			}
+			email.Data = data
+			s.store(email)
+			email = Email{}
+			reply("250 OK")
+		case command == "RSET":
+			email = Email{}
+			reply("250 OK")
+		case command == "NOOP":
+			reply("250 OK")
+		case command == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func (s *SMTP) store(email Email) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.emails = append(s.emails, email)
+}
+
+// readData reads a message up to the line holding a single dot, undoing dot-stuffing
+func readData(reader *bufio.Reader) (string, error) {
+	var data strings.Builder
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		trimmed := strings.TrimRight(line, "\r\n")
+		if trimmed == "." {
+			return data.String(), nil
+		}
+		data.WriteString(strings.TrimPrefix(trimmed, "."))
+		data.WriteString("\n")
+	}
+}
+
+// address strips the angle brackets and parameters from a MAIL FROM or RCPT TO argument
+func address(arg string) string {
+	arg = strings.TrimSpace(arg)
+	if end := strings.Index(arg, ">"); strings.HasPrefix(arg, "<") && end > 0 {
+		return arg[1:end]
+	}
+	if fields := strings.Fields(arg); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
@@ -0,0 +1,19 @@
+package config
+
+import "shared/settings"
+
+// Config is the OpenAI microservice's configuration
+type Config struct {
+	settings.Common
+	Port   int `env:"PORT" default:"5150" usage:"port the service listens on"`
+	OpenAI OpenAI
+}
+
+// OpenAI is the OpenAI API and the models the service uses
+type OpenAI struct {
+	URL         string `env:"OPENAI_API_URL" default:"https://api.openai.com" usage:"OpenAI API address"`
+	APIKey      string `env:"OPENAI_APIKEY" required:"true" secret:"true" usage:"OpenAI API key"`
+	Model       string `env:"OPENAI_MODEL" default:"gpt-4o" usage:"chat model for responses and translations"`
+	SpeechModel string `env:"OPENAI_SPEECH_MODEL" default:"tts-1" usage:"text-to-speech model"`
+	Voice       string `env:"OPENAI_VOICE" default:"alloy" usage:"text-to-speech voice"`
+}
@@ -1,111 +1,61 @@
 package main
 
 import (
-	"log"
-	"net/http"
+	"context"
+	"fmt"
+	"log/slog"
+	"openAIMicroservice/config"
 	"openAIMicroservice/openAI"
-
+	"openAIMicroservice/server"
 	"os"
-	"strings"
 
-	"github.com/golang-jwt/jwt/v4"
-	"github.com/gorilla/handlers"
-	"github.com/gorilla/mux"
+	sharedconfig "shared/config"
+	"shared/graceful"
+	"shared/logging"
+	"shared/observability"
+	"shared/settings"
+	"shared/tracing"
 )
 
-// Authentication middleware to validate JWT
-// JWT Authentication Middleware with Role Check for multiple roles
-func authenticateMiddleware(allowedRoles []string) mux.MiddlewareFunc {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Get the Authorization header
-			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return
-			}
-
-			// Extract token
-			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-
-			// Get the JWT secret from the environment variable
-			secretKey := os.Getenv("JWT_SECRET")
-			if secretKey == "" {
-				log.Println("JWT_SECRET is not set in the environment")
-				http.Error(w, "Internal server error", http.StatusInternalServerError)
-				return
-			}
-
-			// Parse and validate the token
-			token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, http.ErrAbortHandler
-				}
-				return []byte(secretKey), nil
-			})
-
-			if err != nil || !token.Valid {
-				log.Printf("Invalid JWT token: %v", err)
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return
-			}
-
-			// Extract the claims from the token
-			claims, ok := token.Claims.(jwt.MapClaims)
-			if !ok {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return
-			}
-
-			// Check if the role matches any of the allowed roles
-			role, ok := claims["role"].(string)
-			if !ok {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return
-			}
-
-			// Check if the user's role is in the allowed roles
-			roleAllowed := false
-			for _, allowedRole := range allowedRoles {
-				if role == allowedRole {
-					roleAllowed = true
-					break
-				}
-			}
-
-			if !roleAllowed {
-				http.Error(w, "Forbidden", http.StatusForbidden)
-				return
-			}
-
-			// Proceed to the next handler if role matches
-			next.ServeHTTP(w, r)
-		})
+func main() {
+	// Read the configuration from the flags, the environment, secret files and the config file
+	cfg, err := config.Load(os.Args[1:])
+	if cfg.PrintConfig {
+		settings.Print(os.Stdout, cfg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
 	}
-}
-
 
-func main() {
-	// Initialize the router
-	router := mux.NewRouter()
+	// Log as JSON from here on, at the level set by LOG_LEVEL
+	logging.Setup(sharedconfig.OpenAIService, cfg.LogLevel)
+	if err != nil {
+		slog.Error("Error loading configuration", "error", err)
+		os.Exit(1)
+	}
 
-	// JWT Authentication Logic
-	authenticated := router.NewRoute().Subrouter()
+	// Trace requests across the services, exporting the spans if an OTLP collector is configured
+	shutdownTracing, err := tracing.Setup(context.Background(), sharedconfig.OpenAIService)
+	if err != nil {
+		slog.Error("Error setting up tracing", "error", err)
+		os.Exit(1)
+	}
 
-	// Speech generation endpoint
-	authenticated.HandleFunc("/api/v1/generateSpeech", openAI.GenerateSpeech).Handler(authenticateMiddleware([]string{"User"})(http.HandlerFunc(openAI.GenerateSpeech))).Methods("POST")
-	authenticated.HandleFunc("/api/v1/generateResponse", openAI.GenerateResponse).Handler(authenticateMiddleware([]string{"User"})(http.HandlerFunc(openAI.GenerateResponse))).Methods("POST")
-	authenticated.HandleFunc("/api/v1/generateTranslation", openAI.GenerateTranslation).Handler(authenticateMiddleware([]string{"User"})(http.HandlerFunc(openAI.GenerateTranslation))).Methods("POST")
+	h := openAI.NewHandler(openAI.OpenAIModel{})
 
+	// Ready while the OpenAI API is reachable
+	health := observability.NewHealth()
+	health.AddCheck("openai", openAI.CheckAPI)
 
-	// Add CORS support
-	corsHandler := handlers.CORS(
-		handlers.AllowedOrigins([]string{"http://18.143.103.158:5150"}), // Update for allowed origins
-		handlers.AllowedMethods([]string{"GET", "POST", "PUT", "OPTIONS"}), // Update for allowed HTTP methods
-		handlers.AllowedHeaders([]string{"Content-Type", "Authorization"}), // Include Authorization header
-	)(router)
+	// Route the endpoints through their middleware
+	router := server.NewRouter(h, health)
 
-	// Start the server
-	log.Println("OpenAI Microservice is running on port 5150...")
-	log.Fatal(http.ListenAndServe(":5150", corsHandler))
+	// Serve until SIGTERM, then drain the requests in flight, flush the spans
+	slog.Info("OpenAI Microservice is running", "port", cfg.Port)
+	if err := graceful.Serve(fmt.Sprintf(":%d", cfg.Port), router, shutdownTracing); err != nil {
+		slog.Error("Server stopped", "error", err)
+		os.Exit(1)
+	}
 }
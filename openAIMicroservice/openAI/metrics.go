@@ -0,0 +1,111 @@
+package openAI
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+	"unicode/utf8"
+
+	"shared/observability"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer records a span for each call to the OpenAI API
+var tracer = otel.Tracer("openAIMicroservice/openAI")
+
+// callDuration times the calls to the OpenAI API, from sending the request to reading the response
+var callDuration = promauto.With(observability.Registry).NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "openai_request_duration_seconds",
+	Help:    "Time taken by OpenAI API calls, by endpoint, model and outcome.",
+	Buckets: []float64{.25, .5, 1, 2.5, 5, 10, 20, 40, 60},
+}, []string{"endpoint", "model", "outcome"})
+
+// callCost estimates the spend on the OpenAI API from the usage of each successful call
+var callCost = promauto.With(observability.Registry).NewCounterVec(prometheus.CounterOpts{
+	Name: "openai_cost_dollars_total",
+	Help: "Estimated cost of OpenAI API calls in US dollars, by model.",
+}, []string{"model"})
+
+// modelPrice is what OpenAI charges a model, in US dollars per million units
+type modelPrice struct {
+	inputTokens  float64
+	outputTokens float64
+	characters   float64
+}
+
+// modelPrices are OpenAI's list prices for the models the service can be configured with. They
+// change from time to time, so the cost metric is an estimate to be read against the invoice.
+// Models missing from the list are counted at no cost.
+var modelPrices = map[string]modelPrice{
+	"gpt-4o":      {inputTokens: 2.50, outputTokens: 10.00},
+	"gpt-4o-mini": {inputTokens: 0.15, outputTokens: 0.60},
+	"tts-1":       {characters: 15.00},
+	"tts-1-hd":    {characters: 30.00},
+}
+
+// Usage is the token count a chat completion reports
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// startCall starts timing and tracing a call to an API endpoint. It returns the context to make the
+// call in and a function to call with the call's outcome once it has ended.
+func startCall(ctx context.Context, endpoint, model string) (context.Context, func(error)) {
+	ctx, span := tracer.Start(ctx, "openai "+endpoint,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("gen_ai.system", "openai"), attribute.String("gen_ai.request.model", model)),
+	)
+	start := time.Now()
+	return ctx, func(err error) {
+		outcome := "success"
+		if err != nil {
+			outcome = "error"
+			span.SetStatus(codes.Error, err.Error())
+		}
+		callDuration.WithLabelValues(endpoint, model, outcome).Observe(time.Since(start).Seconds())
+		span.End()
+	}
+}
+
+// recordChatCost adds the cost of a chat completion's tokens, and the tokens to the call's span
+func recordChatCost(ctx context.Context, model string, usage Usage) {
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.Int("gen_ai.usage.input_tokens", usage.PromptTokens),
+		attribute.Int("gen_ai.usage.output_tokens", usage.CompletionTokens),
+	)
+	price := modelPrices[model]
+	cost := (float64(usage.PromptTokens)*price.inputTokens + float64(usage.CompletionTokens)*price.outputTokens) / 1e6
+	callCost.WithLabelValues(model).Add(cost)
+}
+
+// recordSpeechCost adds the cost of speaking the input, which is charged by the character
+func recordSpeechCost(ctx context.Context, model, input string) {
+	characters := utf8.RuneCountInString(input)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("gen_ai.usage.input_characters", characters))
+	callCost.WithLabelValues(model).Add(float64(characters) * modelPrices[model].characters / 1e6)
+}
+
+// CheckAPI checks the OpenAI API accepts connections, for the readiness probe
+func CheckAPI(ctx context.Context) error {
+	base, err := url.Parse(apiURL(""))
+	if err != nil {
+		return fmt.Errorf("invalid OPENAI_API_URL: %v", err)
+	}
+	port := base.Port()
+	if port == "" {
+		port = "443"
+		if base.Scheme == "http" {
+			port = "80"
+		}
+	}
+	return observability.Dial(net.JoinHostPort(base.Hostname(), port))(ctx)
+}
@@ -2,14 +2,18 @@ package openAI
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
+	"strings"
 
-	"github.com/joho/godotenv"
+	"openAIMicroservice/config"
+
+	"shared/apierror"
+	"shared/tracing"
 )
 
 type TTSRequest struct {
@@ -22,98 +26,132 @@ type TTSResponse struct {
 	AudioURL string `json:"audio_url"`
 }
 
-func init() {
-	// Load environment variables
-	err := godotenv.Load(".env")
-	if err != nil {
-		log.Fatalf("Error loading .env file: %v", err)
-	}
+// Model generates the speech, responses and translations the handlers return, as part of the
+// request whose context it is given
+type Model interface {
+	Speech(ctx context.Context, inputText string) ([]byte, error)
+	Response(ctx context.Context, prompt string) (string, error)
+	Translation(ctx context.Context, targetLanguage, inputText string) (string, error)
+}
+
+// OpenAIModel calls the OpenAI API with the key and models in the configuration
+type OpenAIModel struct{}
+
+func (OpenAIModel) Speech(ctx context.Context, inputText string) ([]byte, error) {
+	return CallTTSModel(ctx, inputText)
+}
+
+func (OpenAIModel) Response(ctx context.Context, prompt string) (string, error) {
+	return CallGPT4oMini(ctx, prompt)
+}
+
+func (OpenAIModel) Translation(ctx context.Context, targetLanguage, inputText string) (string, error) {
+	return TranslateText(ctx, targetLanguage, inputText)
 }
 
-func CallTTSModel(inputText string) ([]byte, error) {
-	log.Println("Entering CallTTSModel...")
+// Handler serves the speech, response and translation endpoints with its model
+type Handler struct {
+	model Model
+}
+
+// NewHandler returns the OpenAI handlers, generating their output with model
+func NewHandler(model Model) *Handler {
+	return &Handler{model: model}
+}
+
+// apiURL returns the address of an OpenAI API endpoint, under the OPENAI_API_URL setting, which may name a local stand-in
+func apiURL(path string) string {
+	return strings.TrimRight(config.Current().OpenAI.URL, "/") + path
+}
 
-	apiKey := os.Getenv("OPENAI_APIKEY")
+func CallTTSModel(ctx context.Context, inputText string) (audio []byte, err error) {
+	slog.DebugContext(ctx, "Entering CallTTSModel")
+
+	apiKey := config.Current().OpenAI.APIKey
 	if apiKey == "" {
-		log.Println("OPENAI_APIKEY is not set.")
-		return nil, fmt.Errorf("OPENAI_APIKEY environment variable is not set")
+		slog.ErrorContext(ctx, "OPENAI_APIKEY is not set")
+		return nil, fmt.Errorf("OPENAI_APIKEY is not set")
 	}
 
-	log.Printf("Preparing request for TTS with input: %s\n", inputText)
+	slog.DebugContext(ctx, "Preparing request for TTS", "input", inputText)
 	ttsRequest := TTSRequest{
-		Model: "tts-1",
-		Voice: "alloy",
+		Model: config.Current().OpenAI.SpeechModel,
+		Voice: config.Current().OpenAI.Voice,
 		Input: inputText,
 	}
 
 	requestBody, err := json.Marshal(ttsRequest)
 	if err != nil {
-		log.Printf("Error marshaling request body: %v\n", err)
+		slog.ErrorContext(ctx, "Error marshaling request body", "error", err)
 		return nil, fmt.Errorf("error marshaling request body: %v", err)
 	}
-	log.Printf("Request body prepared: %s\n", string(requestBody))
+	slog.DebugContext(ctx, "Request body prepared", "body", string(requestBody))
+
+	ctx, finish := startCall(ctx, "audio/speech", ttsRequest.Model)
+	defer func() { finish(err) }()
 
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/audio/speech", bytes.NewBuffer(requestBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL("/v1/audio/speech"), bytes.NewBuffer(requestBody))
 	if err != nil {
-		log.Printf("Error creating request: %v\n", err)
+		slog.ErrorContext(ctx, "Error creating request", "error", err)
 		return nil, fmt.Errorf("error creating request: %v", err)
 	}
 
 	req.Header.Set("Authorization", "Bearer "+apiKey)
 	req.Header.Set("Content-Type", "application/json")
-	log.Println("Request headers set successfully.")
+	slog.InfoContext(ctx, "Request headers set successfully")
 
-	client := &http.Client{}
-	log.Println("Sending request to OpenAI API...")
+	client := &http.Client{Transport: tracing.Transport(http.DefaultTransport)}
+	slog.DebugContext(ctx, "Sending request to OpenAI API")
 	resp, err := client.Do(req)
 	if err != nil {
-		log.Printf("Error sending request: %v\n", err)
+		slog.ErrorContext(ctx, "Error sending request", "error", err)
 		return nil, fmt.Errorf("error sending request: %v", err)
 	}
 	defer resp.Body.Close()
 
-	log.Printf("Response received with status code: %d\n", resp.StatusCode)
+	slog.DebugContext(ctx, "Response received", "status", resp.StatusCode)
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := ioutil.ReadAll(resp.Body)
-		log.Printf("API returned error: %s\n", string(bodyBytes))
+		slog.ErrorContext(ctx, "API returned error", "body", string(bodyBytes))
 		return nil, fmt.Errorf("API returned error: %s", string(bodyBytes))
 	}
 
 	// Read the raw binary data from the response body
 	audioData, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		log.Printf("Error reading audio data: %v\n", err)
+		slog.ErrorContext(ctx, "Error reading audio data", "error", err)
 		return nil, fmt.Errorf("error reading audio data: %v", err)
 	}
 
-	log.Println("Audio data received successfully.")
+	slog.InfoContext(ctx, "Audio data received successfully")
+	recordSpeechCost(ctx, ttsRequest.Model, ttsRequest.Input)
 	return audioData, nil
 }
 
-func GenerateSpeech(w http.ResponseWriter, r *http.Request) {
-	log.Println("Entering GenerateSpeech...")
+func (h *Handler) GenerateSpeech(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Entering GenerateSpeech")
 
 	var requestData struct {
 		InputText string `json:"input_text"`
 	}
 
-	log.Println("Decoding incoming request body...")
+	slog.DebugContext(r.Context(), "Decoding incoming request body")
 	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
-		log.Printf("Error decoding request body: %v\n", err)
-		http.Error(w, "Invalid input", http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "Error decoding request body", "error", err)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidBody, "Invalid input")
 		return
 	}
-	log.Printf("Request data received: %+v\n", requestData)
+	slog.DebugContext(r.Context(), "Request data received", "request", requestData)
 
-	log.Println("Calling CallTTSModel...")
-	audioData, err := CallTTSModel(requestData.InputText)
+	slog.DebugContext(r.Context(), "Calling CallTTSModel")
+	audioData, err := h.model.Speech(r.Context(), requestData.InputText)
 	if err != nil {
-		log.Printf("Error generating speech: %v\n", err)
-		http.Error(w, "Failed to generate speech", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error generating speech", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Failed to generate speech")
 		return
 	}
 
-	log.Println("Sending audio data back to client...")
+	slog.DebugContext(r.Context(), "Sending audio data back to client")
 	w.Header().Set("Content-Type", "audio/mpeg")
 	w.Header().Set("Content-Disposition", "inline; filename=\"output.mp3\"")
 	w.WriteHeader(http.StatusOK)
@@ -121,10 +159,10 @@ func GenerateSpeech(w http.ResponseWriter, r *http.Request) {
 	// Write the raw binary audio data to the response
 	_, err = w.Write(audioData)
 	if err != nil {
-		log.Printf("Error writing audio data to response: %v\n", err)
-		http.Error(w, "Failed to send audio data", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error writing audio data to response", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Failed to send audio data")
 	}
-	log.Println("Exiting GenerateSpeech...")
+	slog.DebugContext(r.Context(), "Exiting GenerateSpeech")
 }
 
 type GPT4oRequest struct {
@@ -144,20 +182,21 @@ type GPT4oResponse struct {
 			Content string `json:"content"`
 		} `json:"message"`
 	} `json:"choices"`
+	Usage Usage `json:"usage"`
 }
 
-func CallGPT4oMini(prompt string) (string, error) {
-	log.Println("Entering CallGPT4oMini...")
+func CallGPT4oMini(ctx context.Context, prompt string) (result string, err error) {
+	slog.DebugContext(ctx, "Entering CallGPT4oMini")
 
-	apiKey := os.Getenv("OPENAI_APIKEY")
+	apiKey := config.Current().OpenAI.APIKey
 	if apiKey == "" {
-		log.Println("OPENAI_APIKEY is not set.")
-		return "", fmt.Errorf("OPENAI_APIKEY environment variable is not set")
+		slog.ErrorContext(ctx, "OPENAI_APIKEY is not set")
+		return "", fmt.Errorf("OPENAI_APIKEY is not set")
 	}
 
-	log.Printf("Preparing request for GPT-4o-mini with prompt: %s\n", prompt)
+	slog.DebugContext(ctx, "Preparing request for GPT-4o-mini", "prompt", prompt)
 	gptRequest := GPT4oRequest{
-		Model: "gpt-4o",
+		Model: config.Current().OpenAI.Model,
 		Messages: []Message{
 			{Role: "developer", Content: "You are a helpful assistant."},
 			{Role: "user", Content: prompt},
@@ -166,109 +205,113 @@ func CallGPT4oMini(prompt string) (string, error) {
 
 	requestBody, err := json.Marshal(gptRequest)
 	if err != nil {
-		log.Printf("Error marshaling request body: %v\n", err)
+		slog.ErrorContext(ctx, "Error marshaling request body", "error", err)
 		return "", fmt.Errorf("error marshaling request body: %v", err)
 	}
-	log.Printf("Request body prepared: %s\n", string(requestBody))
+	slog.DebugContext(ctx, "Request body prepared", "body", string(requestBody))
+
+	ctx, finish := startCall(ctx, "chat/completions", gptRequest.Model)
+	defer func() { finish(err) }()
 
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/chat/completions", bytes.NewBuffer(requestBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL("/v1/chat/completions"), bytes.NewBuffer(requestBody))
 	if err != nil {
-		log.Printf("Error creating request: %v\n", err)
+		slog.ErrorContext(ctx, "Error creating request", "error", err)
 		return "", fmt.Errorf("error creating request: %v", err)
 	}
 
 	req.Header.Set("Authorization", "Bearer "+apiKey)
 	req.Header.Set("Content-Type", "application/json")
-	log.Println("Request headers set successfully.")
+	slog.InfoContext(ctx, "Request headers set successfully")
 
-	client := &http.Client{}
-	log.Println("Sending request to OpenAI API...")
+	client := &http.Client{Transport: tracing.Transport(http.DefaultTransport)}
+	slog.DebugContext(ctx, "Sending request to OpenAI API")
 	resp, err := client.Do(req)
 	if err != nil {
-		log.Printf("Error sending request: %v\n", err)
+		slog.ErrorContext(ctx, "Error sending request", "error", err)
 		return "", fmt.Errorf("error sending request: %v", err)
 	}
 	defer resp.Body.Close()
 
-	log.Printf("Response received with status code: %d\n", resp.StatusCode)
+	slog.DebugContext(ctx, "Response received", "status", resp.StatusCode)
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := ioutil.ReadAll(resp.Body)
-		log.Printf("API returned error: %s\n", string(bodyBytes))
+		slog.ErrorContext(ctx, "API returned error", "body", string(bodyBytes))
 		return "", fmt.Errorf("API returned error: %s", string(bodyBytes))
 	}
 
 	responseData, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		log.Printf("Error reading response data: %v\n", err)
+		slog.ErrorContext(ctx, "Error reading response data", "error", err)
 		return "", fmt.Errorf("error reading response data: %v", err)
 	}
 
 	var gptResponse GPT4oResponse
 	if err := json.Unmarshal(responseData, &gptResponse); err != nil {
-		log.Printf("Error unmarshaling response data: %v\n", err)
+		slog.ErrorContext(ctx, "Error unmarshaling response data", "error", err)
 		return "", fmt.Errorf("error unmarshaling response data: %v", err)
 	}
 
-	log.Println("Response data unmarshaled successfully.")
+	slog.InfoContext(ctx, "Response data unmarshaled successfully")
+	recordChatCost(ctx, gptRequest.Model, gptResponse.Usage)
 	if len(gptResponse.Choices) == 0 || gptResponse.Choices[0].Message.Content == "" {
 		return "", fmt.Errorf("no content returned in the response")
 	}
 
-	result := gptResponse.Choices[0].Message.Content
-	log.Printf("GPT-4o-mini response: %s\n", result)
+	result = gptResponse.Choices[0].Message.Content
+	slog.DebugContext(ctx, "GPT-4o-mini response", "response", result)
 	return result, nil
 }
 
-func GenerateResponse(w http.ResponseWriter, r *http.Request) {
-	log.Println("Entering GenerateResponse...")
+func (h *Handler) GenerateResponse(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Entering GenerateResponse")
 
 	var requestData struct {
 		Prompt string `json:"prompt"`
 	}
 
-	log.Println("Decoding incoming request body...")
+	slog.DebugContext(r.Context(), "Decoding incoming request body")
 	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
-		log.Printf("Error decoding request body: %v\n", err)
-		http.Error(w, "Invalid input", http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "Error decoding request body", "error", err)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidBody, "Invalid input")
 		return
 	}
-	log.Printf("Request data received: %+v\n", requestData)
+	slog.DebugContext(r.Context(), "Request data received", "request", requestData)
 
-	log.Println("Calling CallGPT4oMini...")
-	response, err := CallGPT4oMini(requestData.Prompt)
+	slog.DebugContext(r.Context(), "Calling CallGPT4oMini")
+	response, err := h.model.Response(r.Context(), requestData.Prompt)
 	if err != nil {
-		log.Printf("Error generating response: %v\n", err)
-		http.Error(w, "Failed to generate response", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error generating response", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Failed to generate response")
 		return
 	}
 
-	log.Println("Sending response back to client...")
+	slog.DebugContext(r.Context(), "Sending response back to client")
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 
 	responseBody := map[string]string{"response": response}
 	if err := json.NewEncoder(w).Encode(responseBody); err != nil {
-		log.Printf("Error writing response to client: %v\n", err)
-		http.Error(w, "Failed to send response", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error writing response to client", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Failed to send response")
 	}
 
-	log.Println("Exiting GenerateResponse...")
+	slog.DebugContext(r.Context(), "Exiting GenerateResponse")
 }
 
-func TranslateText(targetLanguage, inputText string) (string, error) {
-	log.Println("Entering TranslateText...")
+func TranslateText(ctx context.Context, targetLanguage, inputText string) (result string, err error) {
+	slog.DebugContext(ctx, "Entering TranslateText")
 
-	apiKey := os.Getenv("OPENAI_APIKEY")
+	apiKey := config.Current().OpenAI.APIKey
 	if apiKey == "" {
-		log.Println("OPENAI_APIKEY is not set.")
-		return "", fmt.Errorf("OPENAI_APIKEY environment variable is not set")
+		slog.ErrorContext(ctx, "OPENAI_APIKEY is not set")
+		return "", fmt.Errorf("OPENAI_APIKEY is not set")
 	}
 
 	prompt := fmt.Sprintf("Only return the translated text in %s and nothing else: %s", targetLanguage, inputText)
 
-	log.Printf("Preparing request for GPT-4o-mini with prompt: %s\n", prompt)
+	slog.DebugContext(ctx, "Preparing request for GPT-4o-mini", "prompt", prompt)
 	gptRequest := GPT4oRequest{
-		Model: "gpt-4o",
+		Model: config.Current().OpenAI.Model,
 		Messages: []Message{
 			{Role: "developer", Content: "You are a helpful assistant."},
 			{Role: "user", Content: prompt},
@@ -277,92 +320,96 @@ func TranslateText(targetLanguage, inputText string) (string, error) {
 
 	requestBody, err := json.Marshal(gptRequest)
 	if err != nil {
-		log.Printf("Error marshaling request body: %v\n", err)
+		slog.ErrorContext(ctx, "Error marshaling request body", "error", err)
 		return "", fmt.Errorf("error marshaling request body: %v", err)
 	}
-	log.Printf("Request body prepared: %s\n", string(requestBody))
+	slog.DebugContext(ctx, "Request body prepared", "body", string(requestBody))
 
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/chat/completions", bytes.NewBuffer(requestBody))
+	ctx, finish := startCall(ctx, "chat/completions", gptRequest.Model)
+	defer func() { finish(err) }()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL("/v1/chat/completions"), bytes.NewBuffer(requestBody))
 	if err != nil {
-		log.Printf("Error creating request: %v\n", err)
+		slog.ErrorContext(ctx, "Error creating request", "error", err)
 		return "", fmt.Errorf("error creating request: %v", err)
 	}
 
 	req.Header.Set("Authorization", "Bearer "+apiKey)
 	req.Header.Set("Content-Type", "application/json")
-	log.Println("Request headers set successfully.")
+	slog.InfoContext(ctx, "Request headers set successfully")
 
-	client := &http.Client{}
-	log.Println("Sending request to OpenAI API...")
+	client := &http.Client{Transport: tracing.Transport(http.DefaultTransport)}
+	slog.DebugContext(ctx, "Sending request to OpenAI API")
 	resp, err := client.Do(req)
 	if err != nil {
-		log.Printf("Error sending request: %v\n", err)
+		slog.ErrorContext(ctx, "Error sending request", "error", err)
 		return "", fmt.Errorf("error sending request: %v", err)
 	}
 	defer resp.Body.Close()
 
-	log.Printf("Response received with status code: %d\n", resp.StatusCode)
+	slog.DebugContext(ctx, "Response received", "status", resp.StatusCode)
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := ioutil.ReadAll(resp.Body)
-		log.Printf("API returned error: %s\n", string(bodyBytes))
+		slog.ErrorContext(ctx, "API returned error", "body", string(bodyBytes))
 		return "", fmt.Errorf("API returned error: %s", string(bodyBytes))
 	}
 
 	responseData, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		log.Printf("Error reading response data: %v\n", err)
+		slog.ErrorContext(ctx, "Error reading response data", "error", err)
 		return "", fmt.Errorf("error reading response data: %v", err)
 	}
 
 	var gptResponse GPT4oResponse
 	if err := json.Unmarshal(responseData, &gptResponse); err != nil {
-		log.Printf("Error unmarshaling response data: %v\n", err)
+		slog.ErrorContext(ctx, "Error unmarshaling response data", "error", err)
 		return "", fmt.Errorf("error unmarshaling response data: %v", err)
 	}
 
-	log.Println("Response data unmarshaled successfully.")
+	slog.InfoContext(ctx, "Response data unmarshaled successfully")
+	recordChatCost(ctx, gptRequest.Model, gptResponse.Usage)
 	if len(gptResponse.Choices) == 0 || gptResponse.Choices[0].Message.Content == "" {
 		return "", fmt.Errorf("no content returned in the response")
 	}
 
-	result := gptResponse.Choices[0].Message.Content
-	log.Printf("GPT-4o-mini response: %s\n", result)
+	result = gptResponse.Choices[0].Message.Content
+	slog.DebugContext(ctx, "GPT-4o-mini response", "response", result)
 	return result, nil
 }
 
-func GenerateTranslation(w http.ResponseWriter, r *http.Request) {
-	log.Println("Entering GenerateTranslation...")
+func (h *Handler) GenerateTranslation(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Entering GenerateTranslation")
 
 	var requestData struct {
 		TargetLanguage string `json:"target_language"`
 		InputText      string `json:"input_text"`
 	}
 
-	log.Println("Decoding incoming request body...")
+	slog.DebugContext(r.Context(), "Decoding incoming request body")
 	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
-		log.Printf("Error decoding request body: %v\n", err)
-		http.Error(w, "Invalid input", http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "Error decoding request body", "error", err)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidBody, "Invalid input")
 		return
 	}
-	log.Printf("Request data received: %+v\n", requestData)
+	slog.DebugContext(r.Context(), "Request data received", "request", requestData)
 
-	log.Println("Calling TranslateText...")
-	response, err := TranslateText(requestData.TargetLanguage, requestData.InputText)
+	slog.DebugContext(r.Context(), "Calling TranslateText")
+	response, err := h.model.Translation(r.Context(), requestData.TargetLanguage, requestData.InputText)
 	if err != nil {
-		log.Printf("Error generating translation: %v\n", err)
-		http.Error(w, "Failed to generate translation", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error generating translation", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Failed to generate translation")
 		return
 	}
 
-	log.Println("Sending response back to client...")
+	slog.DebugContext(r.Context(), "Sending response back to client")
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 
 	responseBody := map[string]string{"translation": response}
 	if err := json.NewEncoder(w).Encode(responseBody); err != nil {
-		log.Printf("Error writing response to client: %v\n", err)
-		http.Error(w, "Failed to send response", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error writing response to client", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Failed to send response")
 	}
 
-	log.Println("Exiting GenerateTranslation...")
-}
\ No newline at end of file
+	slog.DebugContext(r.Context(), "Exiting GenerateTranslation")
+}
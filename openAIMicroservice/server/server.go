@@ -0,0 +1,130 @@
+// Package server routes the OpenAI microservice's endpoints, so main and the integration
+// harness serve the same handlers behind the same middleware
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"openAIMicroservice/config"
+	"openAIMicroservice/openAI"
+
+	"shared/apierror"
+	sharedconfig "shared/config"
+	"shared/logging"
+	"shared/observability"
+	"shared/tracing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/gorilla/handlers"
+	"github.com/gorilla/mux"
+)
+
+// Authentication middleware to validate JWT
+// JWT Authentication Middleware with Role Check for multiple roles
+func authenticateMiddleware(allowedRoles []string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Get the Authorization header
+			authHeader := r.Header.Get("Authorization")
+			if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+				return
+			}
+
+			// Extract token
+			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+
+			// Get the JWT secret from the configuration
+			secretKey := config.Current().JWTSecret
+			if secretKey == "" {
+				slog.ErrorContext(r.Context(), "JWT_SECRET is not set")
+				apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+				return
+			}
+
+			// Parse and validate the token
+			token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+					return nil, http.ErrAbortHandler
+				}
+				return []byte(secretKey), nil
+			})
+
+			if err != nil || !token.Valid {
+				slog.WarnContext(r.Context(), "Invalid JWT token", "error", err)
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+				return
+			}
+
+			// Extract the claims from the token
+			claims, ok := token.Claims.(jwt.MapClaims)
+			if !ok {
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+				return
+			}
+
+			// Check if the role matches any of the allowed roles
+			role, ok := claims["role"].(string)
+			if !ok {
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+				return
+			}
+
+			// Check if the user's role is in the allowed roles
+			roleAllowed := false
+			for _, allowedRole := range allowedRoles {
+				if role == allowedRole {
+					roleAllowed = true
+					break
+				}
+			}
+
+			if !roleAllowed {
+				apierror.Write(w, r, http.StatusForbidden, apierror.Forbidden, "Forbidden")
+				return
+			}
+
+			// Proceed to the next handler if role matches
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// NewRouter returns the OpenAI endpoints of Routes, with CORS for the frontend and request logging
+func NewRouter(h *openAI.Handler, health *observability.Health) http.Handler {
+	// Add CORS support, inside the middleware that tags every request with an ID and logs it
+	return logging.Middleware(handlers.CORS(
+		handlers.AllowedOrigins(sharedconfig.AllowedOrigins()),
+		handlers.AllowedMethods([]string{"GET", "POST", "PUT", "OPTIONS"}), // Update for allowed HTTP methods
+		handlers.AllowedHeaders([]string{"Content-Type", "Authorization"}), // Include Authorization header
+		handlers.ExposedHeaders([]string{logging.RequestIDHeader}),         // Let the frontend report request IDs
+	)(Routes(h, health)))
+}
+
+// Routes returns the OpenAI endpoints behind their authentication middleware, alongside the probe and
+// metrics endpoints of health. Every /api/v1 route is described in the service's OpenAPI spec, in the
+// api package, which the integration harness checks against these routes.
+func Routes(h *openAI.Handler, health *observability.Health) *mux.Router {
+	// Initialize the router, tracing and timing every request by its route
+	router := mux.NewRouter()
+	router.Use(tracing.Middleware(sharedconfig.OpenAIService), observability.Middleware)
+
+	// Unknown routes and methods get the same JSON errors as the endpoints
+	router.NotFoundHandler = apierror.NotFoundHandler()
+	router.MethodNotAllowedHandler = apierror.MethodNotAllowedHandler()
+
+	// Liveness, readiness and metrics, served without authentication
+	health.RegisterRoutes(router)
+
+	// JWT Authentication Logic
+	authenticated := router.NewRoute().Subrouter()
+
+	// Speech generation endpoint
+	authenticated.Handle("/api/v1/generateSpeech", authenticateMiddleware([]string{"User"})(http.HandlerFunc(h.GenerateSpeech))).Methods("POST")
+	authenticated.Handle("/api/v1/generateResponse", authenticateMiddleware([]string{"User", "Caregiver"})(http.HandlerFunc(h.GenerateResponse))).Methods("POST")
+	authenticated.Handle("/api/v1/generateTranslation", authenticateMiddleware([]string{"User"})(http.HandlerFunc(h.GenerateTranslation))).Methods("POST")
+
+	return router
+}
@@ -16,12 +16,8 @@ port_forward() {
 mkdir -p logs
 
 # Start port-forwarding for each service
-port_forward admin-service 5200 5200
-port_forward auth-service 5050 5050
-port_forward fallsefficacy-service 5300 5300
-port_forward openai-service 5150 5150
-port_forward selfassessment-service 5250 5250
-port_forward user-service 5100 5100
+# The frontend reaches every microservice through the gateway
+port_forward gateway-service 5000 5000
 port_forward frontend-service 8080 80
 
 echo "✅ All port-forwarding started in background. Logs saved in ./logs/"
@@ -6,6 +6,7 @@ echo Reapplying Kubernetes Deployments...
 kubectl apply -f k8s/admin-deployment.yaml
 kubectl apply -f k8s/auth-deployment.yaml
 kubectl apply -f k8s/fallsEfficacy-deployment.yaml
+kubectl apply -f k8s/gateway-deployment.yaml
 kubectl apply -f k8s/frontend-deployment.yaml
 kubectl apply -f k8s/microservices.secrets.yaml
 kubectl apply -f k8s/openAI-deployment.yaml
@@ -0,0 +1,136 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"shared/client"
+	"shared/config"
+)
+
+// User is a senior's profile. Listings of all seniors only include the ID, name, email and age.
+type User struct {
+	UserID      int    `json:"user_id"`
+	Name        string `json:"name"`
+	Email       string `json:"email"`
+	PhoneNumber string `json:"phone_number,omitempty"`
+	Address     string `json:"address,omitempty"`
+	Age         string `json:"age"`
+}
+
+// Profile is the profile created for a senior when they register
+type Profile struct {
+	UserID      int    `json:"user_id"`
+	Email       string `json:"email"`
+	Name        string `json:"name"`
+	PhoneNumber string `json:"phone_number"`
+	Address     string `json:"address"`
+	Age         string `json:"age"`
+}
+
+// Risk observation sources
+const (
+	SourceFES            = "FES"
+	SourceSelfAssessment = "SelfAssessment"
+)
+
+// RiskObservation is a new FES or self-assessment result for the combined risk model.
+// SourceID is the FES response or self-assessment session the score belongs to.
+type RiskObservation struct {
+	UserID   int     `json:"user_id"`
+	Source   string  `json:"source"`
+	SourceID int     `json:"source_id"`
+	Score    float64 `json:"score"`
+}
+
+// RiskFactor is one contribution to a senior's combined risk
+type RiskFactor struct {
+	Factor string `json:"factor"`
+	Detail string `json:"detail"`
+	Points int    `json:"points"`
+}
+
+// CombinedRisk is a senior's overall fall-risk tier and the factors it was built from
+type CombinedRisk struct {
+	UserID     int          `json:"user_id"`
+	Tier       string       `json:"tier"`
+	Points     int          `json:"points"`
+	Factors    []RiskFactor `json:"factors"`
+	ComputedAt time.Time    `json:"computed_at"`
+}
+
+// Client calls the user microservice
+type Client struct {
+	*client.Client
+}
+
+// New returns a client for the user microservice
+func New() *Client {
+	return &Client{client.New(config.UserService)}
+}
+
+// AllUsers returns the ID, name, email and age of every senior, fetching every page
+func (c *Client) AllUsers(ctx context.Context) ([]User, error) {
+	return client.GetAll[User](ctx, c.Client, "/api/v1/user/getAllUser", nil)
+}
+
+// UserPage returns one page of the seniors, with the list query parameters of the pagination contract
+func (c *Client) UserPage(ctx context.Context, params url.Values) ([]User, client.Page, error) {
+	var users []User
+	page, err := c.GetPage(ctx, "/api/v1/user/getAllUser", params, &users)
+	return users, page, err
+}
+
+// User returns a senior's full profile
+func (c *Client) User(ctx context.Context, userID int) (User, error) {
+	var user User
+	err := c.Get(ctx, fmt.Sprintf("/api/v1/user/getUser?userID=%d", userID), &user)
+	return user, err
+}
+
+// CreateProfile creates a senior's profile. The user microservice treats a repeated
+// call for the same user as a no-op, so callers may safely try again after a failure.
+func (c *Client) CreateProfile(ctx context.Context, profile Profile) error {
+	return c.Post(client.AsService(ctx), "/api/v1/user/create", profile, nil)
+}
+
+// ReminderCaregivers returns the emails of caregivers who consented to receive a senior's reminders
+func (c *Client) ReminderCaregivers(ctx context.Context, seniorEmail string) ([]string, error) {
+	var emails []string
+	err := c.Get(client.AsService(ctx), "/api/v1/user/caregiver/reminderRecipients?email="+url.QueryEscape(seniorEmail), &emails)
+	return emails, err
+}
+
+// VerifyCaregiverInvite reports whether an invitation token was sent to the email
+func (c *Client) VerifyCaregiverInvite(ctx context.Context, email, token string) (bool, error) {
+	err := c.Post(client.AsService(ctx), "/api/v1/user/caregiver/verifyInvite", map[string]string{"email": email, "token": token}, nil)
+	if client.StatusCode(err) == http.StatusNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// CaregiverAccess reports whether a senior has granted a caregiver the given consent scope
+func (c *Client) CaregiverAccess(ctx context.Context, caregiverID, seniorID int, scope string) (bool, error) {
+	var access struct {
+		Granted bool `json:"granted"`
+	}
+	path := fmt.Sprintf("/api/v1/user/caregiver/checkAccess?caregiver_id=%d&senior_id=%d&scope=%s", caregiverID, seniorID, url.QueryEscape(scope))
+	err := c.Get(client.AsService(ctx), path, &access)
+	return access.Granted, err
+}
+
+// RecordRiskObservation sends a new result to the user microservice, which recomputes the senior's combined risk
+func (c *Client) RecordRiskObservation(ctx context.Context, observation RiskObservation) error {
+	return c.Post(client.AsService(ctx), "/api/v1/user/risk/observations", observation, nil)
+}
+
+// CombinedRisks returns every senior's combined risk, highest first
+func (c *Client) CombinedRisks(ctx context.Context) ([]CombinedRisk, error) {
+	var risks []CombinedRisk
+	err := c.Get(ctx, "/api/v1/user/risk/all", &risks)
+	return risks, err
+}
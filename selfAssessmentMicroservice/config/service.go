@@ -0,0 +1,44 @@
+package config
+
+import "shared/settings"
+
+// Config is the self-assessment microservice's configuration
+type Config struct {
+	settings.Common
+	Port           int    `env:"PORT" default:"5250" usage:"port the service listens on"`
+	DBConnection   string `env:"SELF_DB_CONNECTION" required:"true" secret:"true" usage:"MySQL DSN of the self-assessment database"`
+	MigrateOnStart bool   `env:"MIGRATE_ON_START" default:"true" usage:"apply pending schema migrations on start"`
+	Broker         Broker
+}
+
+// Broker is the MQTT broker the FallSafe device publishes its readings to: AWS IoT Core over
+// TLS, or a plain MQTT broker when MQTT_BROKER_URL is set
+type Broker struct {
+	URL      string `env:"MQTT_BROKER_URL" usage:"plain MQTT broker to use instead of AWS IoT Core, such as tcp://localhost:1883"`
+	Endpoint string `env:"AWS_IOT_ENDPOINT" usage:"AWS IoT Core endpoint"`
+	ClientID string `env:"AWS_IOT_CLIENT_ID" usage:"MQTT client ID registered with AWS IoT Core"`
+	CertFile string `env:"AWS_IOT_CERT_FILE" usage:"device certificate for AWS IoT Core"`
+	KeyFile  string `env:"AWS_IOT_KEY_FILE" usage:"private key of the device certificate"`
+	CAFile   string `env:"AWS_IOT_CA_FILE" usage:"CA certificate AWS IoT Core is verified with"`
+}
+
+// Missing lists the broker settings that are not set: the client ID, and either MQTT_BROKER_URL
+// or the AWS IoT endpoint and certificates
+func (b Broker) Missing() []string {
+	settings := []struct{ name, value string }{{"AWS_IOT_CLIENT_ID", b.ClientID}}
+	if b.URL == "" {
+		settings = append(settings, []struct{ name, value string }{
+			{"AWS_IOT_ENDPOINT", b.Endpoint},
+			{"AWS_IOT_CERT_FILE", b.CertFile},
+			{"AWS_IOT_KEY_FILE", b.KeyFile},
+			{"AWS_IOT_CA_FILE", b.CAFile},
+		}...)
+	}
+	var missing []string
+	for _, setting := range settings {
+		if setting.value == "" {
+			missing = append(missing, setting.name)
+		}
+	}
+	return missing
+}
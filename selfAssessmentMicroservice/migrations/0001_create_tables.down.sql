@@ -0,0 +1,5 @@
+-- Drops the tables of FallSafe_SelfAssessmentDB
+
+DROP TABLE IF EXISTS UserTestResult;
+DROP TABLE IF EXISTS TestSession;
+DROP TABLE IF EXISTS Test;
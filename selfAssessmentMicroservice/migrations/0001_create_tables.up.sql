@@ -0,0 +1,44 @@
+-- Creates the tables of FallSafe_SelfAssessmentDB
+
+-- Create the Test table
+-- PURPOSE: Stores information about different fall risk tests
+CREATE TABLE Test (
+    test_id SMALLINT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT,    -- Unique ID for the test
+    test_name VARCHAR(100) NOT NULL,                                  -- Name of the test
+    description TEXT,                                                 -- Test description
+    risk_metric VARCHAR(100),                                         -- Risk metric for the test
+    video_url VARCHAR(255),                                           -- Video URL explaining the test
+    step_1 TEXT NOT NULL,                                             -- Mandatory first step
+    step_2 TEXT NULL,                                                 -- Optional second step
+    step_3 TEXT NULL,                                                 -- Optional third step
+    step_4 TEXT NULL,                                                 -- Optional fourth step
+    step_5 TEXT NULL,                                                 -- Optional fifth step
+    enabled BOOLEAN DEFAULT TRUE                                      -- Whether the test is enabled
+);
+
+-- Create the TestSession table
+-- PURPOSE: Tracks test sessions where users complete all required tests
+CREATE TABLE TestSession (
+    session_id SMALLINT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT,  -- Unique ID for the session
+    user_id SMALLINT UNSIGNED NOT NULL,                                -- Associated user ID
+    session_date TIMESTAMP DEFAULT CURRENT_TIMESTAMP,                  -- Date and time of the session
+    total_score SMALLINT UNSIGNED NULL,
+    session_notes TEXT NULL,                                           -- Optional notes about the session
+    INDEX idx_user_session (user_id, session_date)                    -- Composite index for user ID and session date
+);
+
+-- Create the UserTestResult table
+-- PURPOSE: Tracks results of self-assessment tests completed by users
+CREATE TABLE UserTestResult (
+    result_id SMALLINT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT,  -- Unique ID for the result
+    user_id SMALLINT UNSIGNED NOT NULL,                               -- Associated user ID
+    session_id SMALLINT UNSIGNED NOT NULL,                            -- Associated session ID
+    test_id SMALLINT UNSIGNED NOT NULL,                               -- Associated test ID
+    time_taken DECIMAL(10, 3) NOT NULL CHECK (time_taken >= 0),        -- Time taken to complete the test in seconds
+    abrupt_percentage TINYINT UNSIGNED NOT NULL CHECK (abrupt_percentage BETWEEN 0 AND 100), -- Abrupt percentage (0-100)
+    risk_level ENUM('low', 'moderate', 'high') NOT NULL,              -- Risk level (low, moderate, high)
+    test_date TIMESTAMP DEFAULT CURRENT_TIMESTAMP,                    -- Date of test completion
+    INDEX idx_user_test_date (user_id, test_date),                    -- Composite index for user ID and test date
+    FOREIGN KEY (test_id) REFERENCES Test(test_id) ON DELETE CASCADE,  -- Foreign key constraint to Test table
+    FOREIGN KEY (session_id) REFERENCES TestSession(session_id) ON DELETE CASCADE -- Foreign key to TestSession
+);
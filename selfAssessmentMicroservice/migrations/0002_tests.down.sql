@@ -0,0 +1,3 @@
+-- Removes the self-assessment tests. The results of every test are deleted with it.
+
+DELETE FROM Test;
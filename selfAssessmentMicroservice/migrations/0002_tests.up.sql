@@ -0,0 +1,55 @@
+-- Adds the self-assessment tests
+
+-- Insert data for the Timed Up and Go Test
+INSERT INTO Test (test_name, description, risk_metric, video_url, step_1, step_2, step_3, step_4, step_5) VALUES
+(
+    'Timed Up and Go Test',
+    'Measures mobility and balance by timing how quickly you stand, walk, and sit.',
+    'Taking more than 12 seconds to complete indicates an increased risk of falls.',
+    'https://fallsafe.s3.ap-southeast-1.amazonaws.com/Self+Assessment+Video/TUG+Video+Demo.mp4',
+    'Start seated on a chair with your back straight',
+    'Stand up from the chair when ready.',
+    'Walk 3 meters forward, covering a short distance.',
+    'Turn around and walk back to the chair.',
+    'Sit back down on the chair to complete the test.'
+);
+
+-- Insert data for the Five Times Sit to Stand Test
+INSERT INTO Test (test_name, description, risk_metric, video_url, step_1, step_2, step_3, step_4) VALUES
+(
+    'Five Times Sit to Stand Test',
+    'Tests strength and balance by timing repeated sit-to-stand movements.',
+    'Taking more than 14 seconds to complete indicates an increased risk of falls.',
+    'https://fallsafe.s3.ap-southeast-1.amazonaws.com/Self+Assessment+Video/5+times+Stand+and+Sit+Video+Demo.mp4',
+    'Start seated on a chair with your arms crossed over your chest.',
+    'Stand up fully, then sit back down as quickly as possible.',
+    'Repeat this movement five times without stopping.',
+    'Stop the test after sitting down the fifth time.'
+);
+
+-- Insert data for the Dynamic Gait Index (DGI)
+INSERT INTO Test (test_name, description, risk_metric, video_url, step_1, step_2, step_3, step_4) VALUES
+(
+    'Dynamic Gait Index (DGI)',
+    'Evaluates balance and coordination during different walking tasks.',
+    'Taking more than 20 seconds to complete indicates an increased risk of falls.',
+    'https://fallsafe.s3.ap-southeast-1.amazonaws.com/Self+Assessment+Video/Dynamic+Gait+Test+Video+Demo.mp4',
+    'Walk 6 steps forward at a normal pace.',
+    'Turn your head to the right and walk 6 steps slowly in the same direction.',
+    'Turn around, face forward, and walk quickly back to your starting point.',
+    'Stop the test after returning to your starting position'
+);
+
+-- Insert data for the 4 Stage Balance Test
+INSERT INTO Test (test_name, description, risk_metric, video_url, step_1, step_2, step_3, step_4, step_5) VALUES
+(
+    '4 Stage Balance Test',
+    'Tests static balance by holding various standing positions.',
+    'Inability to balance for 10 seconds in any position indicates a higher risk of falls.',
+    'https://fallsafe.s3.ap-southeast-1.amazonaws.com/Self+Assessment+Video/4+Stage+Balance+Test+Video+Demo.mp4',
+    'Stand with feet together and hold for 10 seconds.',
+    'Right foot takes half a step forward, hold for 10 seconds.',
+    'Right foot directly in front of left foot, hold for 10 seconds.',
+    'Stand on one foot (your choice) for 10 seconds.',
+    'Stop if balance is lost or all steps are completed.'
+);
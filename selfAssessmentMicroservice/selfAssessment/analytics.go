@@ -0,0 +1,104 @@
+package selfAssessment
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"shared/apierror"
+)
+
+// analyticsDateLayout is the format of the from and to query parameters
+const analyticsDateLayout = "2006-01-02"
+
+// RiskPeriod is the spread of self-assessment risk levels in one month, counting each senior's latest scored session in that month
+type RiskPeriod struct {
+	Period       string `json:"period"` // YYYY-MM
+	Participants int    `json:"participants"`
+	Low          int    `json:"low"`
+	Moderate     int    `json:"moderate"`
+	High         int    `json:"high"`
+}
+
+// UserScoreTotal is the count and sum of one senior's scores for a metric,
+// for averages across cohorts the self-assessment database cannot see, such as age bands
+type UserScoreTotal struct {
+	UserID int     `json:"user_id"`
+	Metric string  `json:"metric"`
+	Count  int     `json:"count"`
+	Sum    float64 `json:"sum"`
+}
+
+// GetRiskDistribution returns the spread of risk levels per month within the date range,
+// using the same bands as the admin risk levels
+func (h *Handler) GetRiskDistribution(w http.ResponseWriter, r *http.Request) {
+	from, before, ok := analyticsRange(w, r)
+	if !ok {
+		return
+	}
+
+	periods, err := h.store.RiskDistribution(r.Context(), from, before)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying self-assessment risk distribution", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Failed to fetch risk distribution")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(periods)
+}
+
+// GetUserScoreTotals returns the count and sum of each senior's session scores,
+// and of their time and abrupt movement on each test, within the date range
+func (h *Handler) GetUserScoreTotals(w http.ResponseWriter, r *http.Request) {
+	from, before, ok := analyticsRange(w, r)
+	if !ok {
+		return
+	}
+
+	totals, err := h.store.UserScoreTotals(r.Context(), from, before)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying self-assessment score totals", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Failed to fetch score totals")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(totals)
+}
+
+// analyticsRange reads the from and to dates (YYYY-MM-DD, both inclusive), defaulting to the last 12 months.
+// It returns the bounds as the start of from and the start of the day after to, writing an error response
+// and returning false if a date is invalid.
+func analyticsRange(w http.ResponseWriter, r *http.Request) (time.Time, time.Time, bool) {
+	to := time.Now()
+	if value := r.URL.Query().Get("to"); value != "" {
+		parsed, err := time.Parse(analyticsDateLayout, value)
+		if err != nil {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidField, "Invalid to date, expected YYYY-MM-DD")
+			return time.Time{}, time.Time{}, false
+		}
+		to = parsed
+	}
+	from := to.AddDate(-1, 0, 0)
+	if value := r.URL.Query().Get("from"); value != "" {
+		parsed, err := time.Parse(analyticsDateLayout, value)
+		if err != nil {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidField, "Invalid from date, expected YYYY-MM-DD")
+			return time.Time{}, time.Time{}, false
+		}
+		from = parsed
+	}
+	if from.After(to) {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidField, "from must not be after to")
+		return time.Time{}, time.Time{}, false
+	}
+	return startOfDay(from), startOfDay(to).AddDate(0, 0, 1), true
+}
+
+// startOfDay returns the start of the date of t
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
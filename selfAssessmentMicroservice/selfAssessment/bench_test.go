@@ -0,0 +1,147 @@
+package selfAssessment
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"selfAssessmentMicroservice/migrations"
+
+	"shared/dbtest"
+)
+
+// benchDBEnv names the MySQL server the benchmarks create their database on. They are skipped
+// without it.
+const benchDBEnv = "SELF_BENCH_DB_CONNECTION"
+
+// riskLevels are the values of the risk_level column
+var riskLevels = []string{"low", "moderate", "high"}
+
+// BenchmarkTestSessions measures GetTestSessions for seniors with many sessions, comparing the
+// set-based queries it uses with the query-per-session approach they replaced:
+//
+//	SELF_BENCH_DB_CONNECTION='user:password@tcp(localhost:3306)/' go test -run '^$' -bench TestSessions ./selfAssessment
+func BenchmarkTestSessions(b *testing.B) {
+	db, err := OpenDB(dbtest.Database(b, benchDBEnv, migrations.Files))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+	// Reading sessions sends nothing to the combined risk model
+	sa := NewHandler(NewMySQLStore(db), nil)
+
+	for userID, sessions := range []int{30, 300} {
+		userID++
+		if err := seedSessions(db, userID, sessions); err != nil {
+			b.Fatalf("failed to seed %d sessions: %v", sessions, err)
+		}
+
+		b.Run(fmt.Sprintf("sessions=%d/set-based", sessions), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				recorder := httptest.NewRecorder()
+				request := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/selfAssessment/getUserResults?user_id=%d", userID), nil)
+				sa.GetTestSessions(recorder, request)
+				if recorder.Code != http.StatusOK {
+					b.Fatalf("GetTestSessions returned %d: %s", recorder.Code, recorder.Body.String())
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("sessions=%d/query-per-session", sessions), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if err := queryPerSession(db, userID); err != nil {
+					b.Fatalf("query per session failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// seedSessions gives the user count complete sessions of random results, one a day up to today
+func seedSessions(db *sql.DB, userID, count int) error {
+	var testIDs []int
+	rows, err := db.Query(`SELECT test_id FROM Test ORDER BY test_id LIMIT ?`, testsPerSession)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var testID int
+		if err := rows.Scan(&testID); err != nil {
+			rows.Close()
+			return err
+		}
+		testIDs = append(testIDs, testID)
+	}
+	rows.Close()
+	if len(testIDs) < testsPerSession {
+		return fmt.Errorf("need %d tests in the Test table, found %d", testsPerSession, len(testIDs))
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i := 0; i < count; i++ {
+		daysAgo := count - i
+		result, err := tx.Exec(`INSERT INTO TestSession (user_id, session_date, total_score) VALUES (?, NOW() - INTERVAL ? DAY, ?)`,
+			userID, daysAgo, rand.Intn(101))
+		if err != nil {
+			return err
+		}
+		sessionID, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		for _, testID := range testIDs {
+			if _, err := tx.Exec(`
+				INSERT INTO UserTestResult (user_id, session_id, test_id, time_taken, abrupt_percentage, risk_level, test_date)
+				VALUES (?, ?, ?, ?, ?, ?, NOW() - INTERVAL ? DAY)`,
+				userID, sessionID, testID, 5+rand.Float64()*25, rand.Intn(101), riskLevels[rand.Intn(len(riskLevels))], daysAgo); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// queryPerSession reads the user's sessions the way GetTestSessions used to:
+// one query for the sessions, then one for the results of each session. Each result set is
+// closed straight away, which flatters the old code, whose deferred closes held every one open.
+func queryPerSession(db *sql.DB, userID int) error {
+	rows, err := db.Query(`SELECT session_id FROM TestSession WHERE user_id = ? ORDER BY session_date DESC`, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sessionID int
+		if err := rows.Scan(&sessionID); err != nil {
+			return err
+		}
+		testRows, err := db.Query(`
+			SELECT utr.result_id, utr.test_id, t.test_name, utr.time_taken, utr.abrupt_percentage, utr.risk_level, CAST(utr.test_date AS CHAR)
+			FROM UserTestResult utr
+			JOIN Test t ON utr.test_id = t.test_id
+			WHERE utr.session_id = ?
+			ORDER BY utr.test_date DESC`, sessionID)
+		if err != nil {
+			return err
+		}
+		for testRows.Next() {
+			var resultID, testID, abrupt int
+			var testName, riskLevel, testDate string
+			var timeTaken float64
+			if err := testRows.Scan(&resultID, &testID, &testName, &timeTaken, &abrupt, &riskLevel, &testDate); err != nil {
+				testRows.Close()
+				return err
+			}
+		}
+		testRows.Close()
+	}
+	return rows.Err()
+}
@@ -0,0 +1,550 @@
+// Package memstore keeps the self-assessment data in memory, for testing the self-assessment handlers
+// with httptest without a database:
+//
+//	store := memstore.New()
+//	store.AddTest("Timed Up and Go Test")
+//	risk := &memstore.RiskRecorder{}
+//	handler := selfAssessment.NewHandler(store, risk)
+//	sessionID, _ := handler.StartTest(ctx, userID)
+//	handler.SaveUserTestResult(ctx, int(sessionID), userID, testID, 9.5, `{"abrupt_percentage": 10, "risk_level": "low"}`)
+package memstore
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"selfAssessmentMicroservice/client/user"
+	"selfAssessmentMicroservice/selfAssessment"
+
+	"shared/pagination"
+)
+
+// Store is a selfAssessment.SessionStore held in memory. It enforces the ranges and references the
+// database checks, and rounds scores and percentages to whole numbers as their columns do.
+type Store struct {
+	mu       sync.Mutex
+	tests    []selfAssessment.Test
+	sessions []session
+	results  []result
+
+	// Now is the clock new sessions and results are dated with, time.Now unless a test replaces it
+	Now func() time.Time
+}
+
+type session struct {
+	id     int
+	userID int
+	date   time.Time
+	score  *int
+}
+
+type result struct {
+	id        int
+	sessionID int
+	userID    int
+	testID    int
+	timeTaken float64
+	abrupt    int
+	riskLevel string
+	date      time.Time
+}
+
+var _ selfAssessment.SessionStore = (*Store)(nil)
+
+// New returns an empty store
+func New() *Store {
+	return &Store{Now: time.Now}
+}
+
+// AddTest adds an enabled test, returning its ID
+func (s *Store) AddTest(name string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := len(s.tests) + 1
+	s.tests = append(s.tests, selfAssessment.Test{TestID: id, TestName: name, Enabled: true})
+	return id
+}
+
+// AddSession adds a senior's session dated date, returning its ID. It is for arranging the data a test reads;
+// SetSessionScore scores it.
+func (s *Store) AddSession(userID int, date time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addSession(userID, date)
+}
+
+// AddResult adds a test result dated date, returning its ID. It is for arranging the data a test reads.
+func (s *Store) AddResult(r selfAssessment.NewTestResult, date time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addResult(r, date)
+}
+
+func (s *Store) addSession(userID int, date time.Time) int {
+	id := len(s.sessions) + 1
+	s.sessions = append(s.sessions, session{id: id, userID: userID, date: date})
+	return id
+}
+
+// addResult stores a result, checking it as the database's constraints do
+func (s *Store) addResult(r selfAssessment.NewTestResult, date time.Time) (int, error) {
+	if s.session(r.SessionID) == nil {
+		return 0, fmt.Errorf("session %d does not exist", r.SessionID)
+	}
+	if s.test(r.TestID) == nil {
+		return 0, fmt.Errorf("test %d does not exist", r.TestID)
+	}
+	if r.TimeTaken < 0 {
+		return 0, fmt.Errorf("time_taken %v is negative", r.TimeTaken)
+	}
+	abrupt := int(math.Round(r.AbruptPercentage))
+	if abrupt < 0 || abrupt > 100 {
+		return 0, fmt.Errorf("abrupt_percentage %v is not between 0 and 100", r.AbruptPercentage)
+	}
+	if !slices.Contains([]string{"low", "moderate", "high"}, r.RiskLevel) {
+		return 0, fmt.Errorf("risk_level %q is not low, moderate or high", r.RiskLevel)
+	}
+
+	id := len(s.results) + 1
+	s.results = append(s.results, result{
+		id:        id,
+		sessionID: r.SessionID,
+		userID:    r.UserID,
+		testID:    r.TestID,
+		timeTaken: math.Round(r.TimeTaken*1000) / 1000,
+		abrupt:    abrupt,
+		riskLevel: r.RiskLevel,
+		date:      date,
+	})
+	return id, nil
+}
+
+func (s *Store) StartSession(ctx context.Context, userID int, date time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return int64(s.addSession(userID, date)), nil
+}
+
+func (s *Store) Tests(ctx context.Context) ([]selfAssessment.Test, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.tests), nil
+}
+
+func (s *Store) SaveResult(ctx context.Context, r selfAssessment.NewTestResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.addResult(r, s.Now())
+	return err
+}
+
+func (s *Store) SessionResults(ctx context.Context, sessionID int) ([]selfAssessment.ScoredResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var results []selfAssessment.ScoredResult
+	for _, r := range s.results {
+		if r.sessionID == sessionID {
+			results = append(results, s.scored(r))
+		}
+	}
+	return results, nil
+}
+
+func (s *Store) SetSessionScore(ctx context.Context, sessionID int, score float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if score < 0 || score > math.MaxUint16 {
+		return fmt.Errorf("total_score %v is out of range", score)
+	}
+	// Updating a session that does not exist changes nothing, as in the database
+	if found := s.session(sessionID); found != nil {
+		rounded := int(math.Round(score))
+		found.score = &rounded
+	}
+	return nil
+}
+
+func (s *Store) UserSessions(ctx context.Context, userID int) ([]selfAssessment.TestSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sessions := []selfAssessment.TestSession{}
+	for _, found := range s.sessions {
+		if found.userID != userID {
+			continue
+		}
+		testSession := selfAssessment.TestSession{SessionID: found.id, UserID: found.userID, SessionDate: found.date}
+		if found.score != nil {
+			score := *found.score
+			testSession.TotalScore = &score
+		}
+		for _, r := range s.results {
+			if r.sessionID == found.id {
+				testSession.TestResults = append(testSession.TestResults, selfAssessment.UserTestResult{
+					ResultID:         r.id,
+					TestID:           r.testID,
+					TestName:         s.test(r.testID).TestName,
+					TimeTaken:        r.timeTaken,
+					AbruptPercentage: r.abrupt,
+					RiskLevel:        r.riskLevel,
+					TestDate:         r.date,
+				})
+			}
+		}
+		slices.SortStableFunc(testSession.TestResults, func(a, b selfAssessment.UserTestResult) int {
+			return cmp.Or(b.TestDate.Compare(a.TestDate), cmp.Compare(b.ResultID, a.ResultID))
+		})
+		sessions = append(sessions, testSession)
+	}
+	slices.SortStableFunc(sessions, func(a, b selfAssessment.TestSession) int {
+		return cmp.Or(b.SessionDate.Compare(a.SessionDate), cmp.Compare(b.SessionID, a.SessionID))
+	})
+	return sessions, nil
+}
+
+func (s *Store) SessionScores(ctx context.Context, page *pagination.Query) ([]selfAssessment.TestSessionUser, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var matching []session
+	for _, found := range s.sessions {
+		if page.Matches(found.date, found.userID) {
+			matching = append(matching, found)
+		}
+	}
+	slices.SortStableFunc(matching, func(a, b session) int {
+		var c int
+		switch page.Sort {
+		case "session_date":
+			c = a.date.Compare(b.date)
+		case "total_score":
+			c = cmp.Compare(scoreOrNone(a), scoreOrNone(b))
+		case "user_id":
+			c = cmp.Compare(a.userID, b.userID)
+		}
+		return direction(page, cmp.Or(c, cmp.Compare(a.id, b.id)))
+	})
+
+	rows := []selfAssessment.TestSessionUser{}
+	for _, found := range pagination.Page(page, matching) {
+		row := selfAssessment.TestSessionUser{SessionID: found.id, UserID: found.userID, SessionDate: found.date}
+		if found.score != nil {
+			row.TotalScore = int16(*found.score)
+		}
+		rows = append(rows, row)
+	}
+	return rows, len(matching), nil
+}
+
+func (s *Store) TestTimes(ctx context.Context, page *pagination.Query) ([]selfAssessment.FATestWithAvgTime, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	type row struct {
+		result      result
+		testName    string
+		sessionDate time.Time
+	}
+	var matching []row
+	for _, r := range s.results {
+		sessionDate := s.session(r.sessionID).date
+		if page.Matches(sessionDate, r.userID) {
+			matching = append(matching, row{result: r, testName: s.test(r.testID).TestName, sessionDate: sessionDate})
+		}
+	}
+	slices.SortStableFunc(matching, func(a, b row) int {
+		var c int
+		switch page.Sort {
+		case "test_name":
+			c = cmp.Compare(a.testName, b.testName)
+		case "time_taken":
+			c = cmp.Compare(a.result.timeTaken, b.result.timeTaken)
+		case "session_date":
+			c = a.sessionDate.Compare(b.sessionDate)
+		case "user_id":
+			c = cmp.Compare(a.result.userID, b.result.userID)
+		}
+		return direction(page, cmp.Or(c, cmp.Compare(a.result.id, b.result.id)))
+	})
+
+	rows := []selfAssessment.FATestWithAvgTime{}
+	for _, r := range pagination.Page(page, matching) {
+		rows = append(rows, selfAssessment.FATestWithAvgTime{
+			ResultID:    uint(r.result.id),
+			TestName:    r.testName,
+			UserID:      uint(r.result.userID),
+			TimeTaken:   r.result.timeTaken,
+			SessionDate: r.sessionDate,
+		})
+	}
+	return rows, len(matching), nil
+}
+
+func (s *Store) LatestResults(ctx context.Context) ([]selfAssessment.ScoredResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	latest := map[int]result{}
+	for _, r := range s.results {
+		current, ok := latest[r.userID]
+		if !ok || cmp.Or(r.date.Compare(current.date), cmp.Compare(r.id, current.id)) > 0 {
+			latest[r.userID] = r
+		}
+	}
+
+	var results []selfAssessment.ScoredResult
+	for _, r := range latest {
+		results = append(results, s.scored(r))
+	}
+	slices.SortFunc(results, func(a, b selfAssessment.ScoredResult) int { return cmp.Compare(a.UserID, b.UserID) })
+	return results, nil
+}
+
+func (s *Store) LatestScoredSessions(ctx context.Context) ([]selfAssessment.LastSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	latest := map[int]time.Time{}
+	for _, found := range s.sessions {
+		if found.score != nil && found.date.After(latest[found.userID]) {
+			latest[found.userID] = found.date
+		}
+	}
+
+	var sessions []selfAssessment.LastSession
+	for userID, date := range latest {
+		sessions = append(sessions, selfAssessment.LastSession{UserID: userID, SessionDate: date})
+	}
+	slices.SortFunc(sessions, func(a, b selfAssessment.LastSession) int { return cmp.Compare(a.UserID, b.UserID) })
+	return sessions, nil
+}
+
+func (s *Store) LatestSessionScores(ctx context.Context) ([]selfAssessment.TestSessionUser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	latest := map[int]session{}
+	for _, found := range s.sessions {
+		if found.score == nil {
+			continue
+		}
+		current, ok := latest[found.userID]
+		if !ok || cmp.Or(found.date.Compare(current.date), cmp.Compare(found.id, current.id)) > 0 {
+			latest[found.userID] = found
+		}
+	}
+
+	sessions := []selfAssessment.TestSessionUser{}
+	for _, found := range latest {
+		sessions = append(sessions, selfAssessment.TestSessionUser{SessionID: found.id, UserID: found.userID, SessionDate: found.date, TotalScore: int16(*found.score)})
+	}
+	slices.SortFunc(sessions, func(a, b selfAssessment.TestSessionUser) int { return cmp.Compare(a.UserID, b.UserID) })
+	return sessions, nil
+}
+
+func (s *Store) SessionScoreHistory(ctx context.Context, days int, userID *int) ([]selfAssessment.ScoredResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	since := s.Now().AddDate(0, 0, -days)
+	var points []selfAssessment.ScoredResult
+	for _, found := range s.sessions {
+		if found.score != nil && !found.date.Before(since) && (userID == nil || *userID == found.userID) {
+			points = append(points, selfAssessment.ScoredResult{UserID: found.userID, Score: float64(*found.score), Date: found.date})
+		}
+	}
+	slices.SortStableFunc(points, func(a, b selfAssessment.ScoredResult) int {
+		return cmp.Or(cmp.Compare(a.UserID, b.UserID), a.Date.Compare(b.Date))
+	})
+	return points, nil
+}
+
+func (s *Store) TestHistory(ctx context.Context, days int, userID *int) ([]selfAssessment.ScoredResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	since := s.Now().AddDate(0, 0, -days)
+	var matching []result
+	for _, r := range s.results {
+		if !r.date.Before(since) && (userID == nil || *userID == r.userID) {
+			matching = append(matching, r)
+		}
+	}
+	slices.SortStableFunc(matching, func(a, b result) int {
+		return cmp.Or(cmp.Compare(a.userID, b.userID), cmp.Compare(a.testID, b.testID), a.date.Compare(b.date))
+	})
+
+	points := make([]selfAssessment.ScoredResult, 0, len(matching))
+	for _, r := range matching {
+		points = append(points, s.scored(r))
+	}
+	return points, nil
+}
+
+func (s *Store) RiskDistribution(ctx context.Context, from, before time.Time) ([]selfAssessment.RiskPeriod, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Each senior's latest scored session in each month
+	type key struct {
+		userID int
+		period string
+	}
+	latest := map[key]session{}
+	for _, found := range s.sessions {
+		if found.score == nil || found.date.Before(from) || !found.date.Before(before) {
+			continue
+		}
+		k := key{userID: found.userID, period: found.date.Format("2006-01")}
+		current, ok := latest[k]
+		if !ok || cmp.Or(found.date.Compare(current.date), cmp.Compare(found.id, current.id)) > 0 {
+			latest[k] = found
+		}
+	}
+
+	byPeriod := map[string]*selfAssessment.RiskPeriod{}
+	for k, found := range latest {
+		period, ok := byPeriod[k.period]
+		if !ok {
+			period = &selfAssessment.RiskPeriod{Period: k.period}
+			byPeriod[k.period] = period
+		}
+		period.Participants++
+		switch {
+		case *found.score >= 60:
+			period.Low++
+		case *found.score >= 30:
+			period.Moderate++
+		default:
+			period.High++
+		}
+	}
+
+	periods := []selfAssessment.RiskPeriod{}
+	for _, period := range byPeriod {
+		periods = append(periods, *period)
+	}
+	slices.SortFunc(periods, func(a, b selfAssessment.RiskPeriod) int { return cmp.Compare(a.Period, b.Period) })
+	return periods, nil
+}
+
+func (s *Store) UserScoreTotals(ctx context.Context, from, before time.Time) ([]selfAssessment.UserScoreTotal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	type key struct {
+		userID int
+		metric string
+	}
+	byMetric := map[key]*selfAssessment.UserScoreTotal{}
+	add := func(userID int, metric string, value float64) {
+		k := key{userID: userID, metric: metric}
+		total, ok := byMetric[k]
+		if !ok {
+			total = &selfAssessment.UserScoreTotal{UserID: userID, Metric: metric}
+			byMetric[k] = total
+		}
+		total.Count++
+		total.Sum += value
+	}
+
+	for _, found := range s.sessions {
+		if found.score != nil && !found.date.Before(from) && found.date.Before(before) {
+			add(found.userID, selfAssessment.SessionScoreMetric, float64(*found.score))
+		}
+	}
+	for _, r := range s.results {
+		if !r.date.Before(from) && r.date.Before(before) {
+			testName := s.test(r.testID).TestName
+			add(r.userID, selfAssessment.TimeMetric(testName), r.timeTaken)
+			add(r.userID, selfAssessment.AbruptMetric(testName), float64(r.abrupt))
+		}
+	}
+
+	totals := []selfAssessment.UserScoreTotal{}
+	for _, total := range byMetric {
+		totals = append(totals, *total)
+	}
+	slices.SortFunc(totals, func(a, b selfAssessment.UserScoreTotal) int {
+		return cmp.Or(cmp.Compare(a.UserID, b.UserID), cmp.Compare(a.Metric, b.Metric))
+	})
+	return totals, nil
+}
+
+// session returns the session with an ID, or nil if there is none
+func (s *Store) session(id int) *session {
+	for i := range s.sessions {
+		if s.sessions[i].id == id {
+			return &s.sessions[i]
+		}
+	}
+	return nil
+}
+
+// test returns the test with an ID, or nil if there is none
+func (s *Store) test(id int) *selfAssessment.Test {
+	for i := range s.tests {
+		if s.tests[i].TestID == id {
+			return &s.tests[i]
+		}
+	}
+	return nil
+}
+
+// scored returns what scoring and trends need of a result
+func (s *Store) scored(r result) selfAssessment.ScoredResult {
+	return selfAssessment.ScoredResult{
+		UserID:           r.userID,
+		TestName:         s.test(r.testID).TestName,
+		TimeTaken:        r.timeTaken,
+		AbruptPercentage: float64(r.abrupt),
+		Date:             r.date,
+	}
+}
+
+// scoreOrNone returns a session's score, or -1 for an unscored session, which sorts first as NULL does
+func scoreOrNone(found session) int {
+	if found.score == nil {
+		return -1
+	}
+	return *found.score
+}
+
+// direction applies the sort direction of the page to a comparison
+func direction(page *pagination.Query, c int) int {
+	if page.Descending {
+		return -c
+	}
+	return c
+}
+
+// RiskRecorder is a selfAssessment.RiskRecorder that keeps the observations sent to it
+type RiskRecorder struct {
+	mu           sync.Mutex
+	observations []user.RiskObservation
+}
+
+var _ selfAssessment.RiskRecorder = (*RiskRecorder)(nil)
+
+func (r *RiskRecorder) RecordRiskObservation(ctx context.Context, observation user.RiskObservation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.observations = append(r.observations, observation)
+	return nil
+}
+
+// Observations returns the observations recorded so far. SaveUserTestResult sends them in the background,
+// so a test may need to wait for one to arrive.
+func (r *RiskRecorder) Observations() []user.RiskObservation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.observations)
+}
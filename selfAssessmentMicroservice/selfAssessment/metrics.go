@@ -0,0 +1,21 @@
+package selfAssessment
+
+import (
+	"shared/observability"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// mqttMessagesReceived counts the messages the wearable sensors published, as the service's MQTT
+// clients received them
+var mqttMessagesReceived = promauto.With(observability.Registry).NewCounterVec(prometheus.CounterOpts{
+	Name: "mqtt_messages_received_total",
+	Help: "MQTT messages received from the sensors, by topic.",
+}, []string{"topic"})
+
+// websocketSessionsActive counts the frontend's open movement capture sockets
+var websocketSessionsActive = promauto.With(observability.Registry).NewGauge(prometheus.GaugeOpts{
+	Name: "websocket_sessions_active",
+	Help: "WebSocket sessions currently open for movement capture.",
+})
@@ -0,0 +1,541 @@
+package selfAssessment
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"shared/observability"
+	"shared/pagination"
+
+	_ "github.com/go-sql-driver/mysql" // MySQL driver
+)
+
+// sqlDateLayout is the format of dates cast to text by MySQL
+const sqlDateLayout = "2006-01-02 15:04:05"
+
+// OpenDB connects to the database named by a DSN and checks the connection
+func OpenDB(dsn string) (*sql.DB, error) {
+	if dsn == "" {
+		return nil, fmt.Errorf("database connection string is not set")
+	}
+
+	slog.Debug("Initializing database connection")
+	db, err := observability.OpenDB("mysql", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to database: %v", err)
+	}
+
+	// Test the database connection
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("database connection test failed: %v", err)
+	}
+	slog.Info("Database connection successful")
+	return db, nil
+}
+
+// MySQLStore is the SessionStore kept in the self-assessment database
+type MySQLStore struct {
+	db *sql.DB
+}
+
+// NewMySQLStore returns a store reading and writing the given database
+func NewMySQLStore(db *sql.DB) *MySQLStore {
+	return &MySQLStore{db: db}
+}
+
+func (s *MySQLStore) StartSession(ctx context.Context, userID int, date time.Time) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO TestSession (user_id, session_date)
+		VALUES (?, ?)
+	`, userID, date)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create new test session: %v", err)
+	}
+
+	// Get the session ID of the newly created session
+	sessionID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to retrieve session ID: %v", err)
+	}
+	return sessionID, nil
+}
+
+func (s *MySQLStore) Tests(ctx context.Context) ([]Test, error) {
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT
+            test_id,
+            test_name,
+            description,
+            risk_metric,
+            video_url,
+            step_1,
+            step_2,
+            step_3,
+            step_4,
+            step_5,
+            enabled
+        FROM Test
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve tests: %v", err)
+	}
+	defer rows.Close()
+
+	tests := []Test{}
+	for rows.Next() {
+		var test Test
+		var step2, step3, step4, step5 sql.NullString
+		if err := rows.Scan(
+			&test.TestID, &test.TestName, &test.Description, &test.RiskMetric, &test.VideoURL,
+			&test.Step1, &step2, &step3, &step4, &step5, &test.Enabled,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan test row: %v", err)
+		}
+		test.Step2, test.Step3 = nullStringToString(step2), nullStringToString(step3)
+		test.Step4, test.Step5 = nullStringToString(step4), nullStringToString(step5)
+		tests = append(tests, test)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error occurred while iterating through rows: %v", err)
+	}
+	return tests, nil
+}
+
+func nullStringToString(ns sql.NullString) string {
+	if ns.Valid {
+		return ns.String
+	}
+	return ""
+}
+
+func (s *MySQLStore) SaveResult(ctx context.Context, result NewTestResult) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO UserTestResult (
+			user_id, session_id, test_id, time_taken, abrupt_percentage, risk_level
+		) VALUES (?, ?, ?, ?, ?, ?)
+	`, result.UserID, result.SessionID, result.TestID, result.TimeTaken, result.AbruptPercentage, result.RiskLevel)
+	if err != nil {
+		return fmt.Errorf("failed to save user test result: %v", err)
+	}
+	return nil
+}
+
+func (s *MySQLStore) SessionResults(ctx context.Context, sessionID int) ([]ScoredResult, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT utr.user_id, utr.time_taken, utr.abrupt_percentage, t.test_name
+		FROM UserTestResult utr
+		JOIN Test t ON utr.test_id = t.test_id
+		WHERE utr.session_id = ?`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query test results: %v", err)
+	}
+	defer rows.Close()
+
+	var results []ScoredResult
+	for rows.Next() {
+		var result ScoredResult
+		if err := rows.Scan(&result.UserID, &result.TimeTaken, &result.AbruptPercentage, &result.TestName); err != nil {
+			return nil, fmt.Errorf("failed to scan test result row: %v", err)
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+func (s *MySQLStore) SetSessionScore(ctx context.Context, sessionID int, score float64) error {
+	if _, err := s.db.ExecContext(ctx, `UPDATE TestSession SET total_score = ? WHERE session_id = ?`, score, sessionID); err != nil {
+		return fmt.Errorf("failed to update average score: %v", err)
+	}
+	return nil
+}
+
+// UserSessions reads the sessions and all their results with one query each and groups them in memory,
+// so the number of queries does not grow with the number of sessions
+func (s *MySQLStore) UserSessions(ctx context.Context, userID int) ([]TestSession, error) {
+	// Query to fetch test sessions
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT
+			session_id,
+			user_id,
+			CAST(session_date AS CHAR), -- Convert to string
+			total_score,
+			session_notes
+		FROM TestSession
+		WHERE user_id = ?
+		ORDER BY session_date DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []TestSession{}
+	for rows.Next() {
+		var session TestSession
+		var sessionDateStr string // Store as string before parsing
+		var totalScore sql.NullInt64
+		var sessionNotes sql.NullString
+		if err := rows.Scan(
+			&session.SessionID, &session.UserID, &sessionDateStr, &totalScore, &sessionNotes,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning session row: %v", err)
+		}
+		if totalScore.Valid {
+			score := int(totalScore.Int64)
+			session.TotalScore = &score
+		}
+		if sessionNotes.Valid {
+			session.SessionNotes = &sessionNotes.String
+		}
+
+		// Parse session_date using "YYYY-MM-DD HH:MM:SS" format
+		session.SessionDate, err = time.Parse(sqlDateLayout, sessionDateStr)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing session_date: %v", err)
+		}
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Query to fetch the test results of every one of the senior's sessions
+	testRows, err := s.db.QueryContext(ctx, `
+		SELECT
+			utr.session_id,
+			utr.result_id,
+			utr.test_id,
+			t.test_name,
+			utr.time_taken,
+			utr.abrupt_percentage,
+			utr.risk_level,
+			CAST(utr.test_date AS CHAR) -- Convert test_date to string
+		FROM UserTestResult utr
+		JOIN Test t ON utr.test_id = t.test_id
+		JOIN TestSession ts ON utr.session_id = ts.session_id
+		WHERE ts.user_id = ?
+		ORDER BY utr.session_id, utr.test_date DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying test results: %v", err)
+	}
+	defer testRows.Close()
+
+	// Group the results by session
+	resultsBySession := map[int][]UserTestResult{}
+	for testRows.Next() {
+		var sessionID int
+		var result UserTestResult
+		var testDateStr string
+		if err := testRows.Scan(
+			&sessionID, &result.ResultID, &result.TestID, &result.TestName,
+			&result.TimeTaken, &result.AbruptPercentage,
+			&result.RiskLevel, &testDateStr,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning test result row: %v", err)
+		}
+
+		// Parse test_date using "YYYY-MM-DD HH:MM:SS" format
+		result.TestDate, err = time.Parse(sqlDateLayout, testDateStr)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing test_date: %v", err)
+		}
+		resultsBySession[sessionID] = append(resultsBySession[sessionID], result)
+	}
+	if err := testRows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range sessions {
+		sessions[i].TestResults = resultsBySession[sessions[i].SessionID]
+	}
+	return sessions, nil
+}
+
+func (s *MySQLStore) SessionScores(ctx context.Context, page *pagination.Query) ([]TestSessionUser, int, error) {
+	where, args := page.Where()
+
+	// Count every matching session, for the total across pages
+	var total int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM TestSession`+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("error counting test sessions: %v", err)
+	}
+
+	clauses, pageArgs := page.Page()
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT session_id, user_id, session_date, total_score`+page.KeyColumns()+`
+		FROM TestSession`+clauses, pageArgs...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	sessions := []TestSessionUser{}
+	for rows.Next() {
+		var session TestSessionUser
+		if err := rows.Scan(append([]interface{}{&session.SessionID, &session.UserID, &session.SessionDate, &session.TotalScore}, page.Key()...)...); err != nil {
+			return nil, 0, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, total, rows.Err()
+}
+
+func (s *MySQLStore) TestTimes(ctx context.Context, page *pagination.Query) ([]FATestWithAvgTime, int, error) {
+	where, args := page.Where()
+	tables := `
+		FROM UserTestResult utr
+		JOIN Test t ON utr.test_id = t.test_id
+		JOIN TestSession ts ON utr.session_id = ts.session_id`
+
+	// Count every matching result, for the total across pages
+	var total int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*)`+tables+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("error counting user test results: %v", err)
+	}
+
+	clauses, pageArgs := page.Page()
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT utr.result_id, t.test_name, utr.user_id, utr.time_taken, ts.session_date`+page.KeyColumns()+tables+clauses, pageArgs...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	results := []FATestWithAvgTime{}
+	for rows.Next() {
+		var result FATestWithAvgTime
+		if err := rows.Scan(append([]interface{}{&result.ResultID, &result.TestName, &result.UserID, &result.TimeTaken, &result.SessionDate}, page.Key()...)...); err != nil {
+			return nil, 0, err
+		}
+		results = append(results, result)
+	}
+	return results, total, rows.Err()
+}
+
+func (s *MySQLStore) LatestResults(ctx context.Context) ([]ScoredResult, error) {
+	// For each user, their latest test result (by test_date) joined with the Test table to obtain the test name
+	rows, err := s.db.QueryContext(ctx, `
+		WITH LatestTest AS (
+			SELECT
+				utr.user_id,
+				utr.time_taken,
+				utr.abrupt_percentage,
+				t.test_name,
+				ROW_NUMBER() OVER (PARTITION BY utr.user_id ORDER BY utr.test_date DESC) AS rn
+			FROM UserTestResult utr
+			JOIN Test t ON utr.test_id = t.test_id
+		)
+		SELECT user_id, time_taken, abrupt_percentage, test_name
+		FROM LatestTest
+		WHERE rn = 1;
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []ScoredResult
+	for rows.Next() {
+		var result ScoredResult
+		if err := rows.Scan(&result.UserID, &result.TimeTaken, &result.AbruptPercentage, &result.TestName); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+func (s *MySQLStore) LatestScoredSessions(ctx context.Context) ([]LastSession, error) {
+	// Dont accept incomplete work
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT user_id, MAX(session_date) AS last_response_date
+		FROM TestSession
+		WHERE user_id IS NOT NULL
+			AND session_date IS NOT NULL
+			AND total_score IS NOT NULL
+		GROUP BY user_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []LastSession
+	for rows.Next() {
+		var session LastSession
+		if err := rows.Scan(&session.UserID, &session.SessionDate); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+func (s *MySQLStore) LatestSessionScores(ctx context.Context) ([]TestSessionUser, error) {
+	// Dont accept incomplete work
+	rows, err := s.db.QueryContext(ctx, `
+		WITH LatestSession AS (
+			SELECT
+				session_id,
+				user_id,
+				session_date,
+				total_score,
+				ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY session_date DESC, session_id DESC) AS rn
+			FROM TestSession
+			WHERE user_id IS NOT NULL
+				AND session_date IS NOT NULL
+				AND total_score IS NOT NULL
+		)
+		SELECT session_id, user_id, session_date, total_score
+		FROM LatestSession
+		WHERE rn = 1
+		ORDER BY user_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []TestSessionUser{}
+	for rows.Next() {
+		var session TestSessionUser
+		if err := rows.Scan(&session.SessionID, &session.UserID, &session.SessionDate, &session.TotalScore); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+func (s *MySQLStore) SessionScoreHistory(ctx context.Context, days int, userID *int) ([]ScoredResult, error) {
+	// Only sessions that have been scored
+	query := `
+		SELECT user_id, CAST(session_date AS CHAR), total_score
+		FROM TestSession
+		WHERE total_score IS NOT NULL AND session_date >= NOW() - INTERVAL ? DAY`
+	args := []interface{}{days}
+	if userID != nil {
+		query += ` AND user_id = ?`
+		args = append(args, *userID)
+	}
+	rows, err := s.db.QueryContext(ctx, query+` ORDER BY user_id, session_date`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []ScoredResult
+	for rows.Next() {
+		var point ScoredResult
+		var sessionDate string
+		if err := rows.Scan(&point.UserID, &sessionDate, &point.Score); err != nil {
+			return nil, err
+		}
+		if point.Date, err = time.Parse(sqlDateLayout, sessionDate); err != nil {
+			return nil, err
+		}
+		points = append(points, point)
+	}
+	return points, rows.Err()
+}
+
+func (s *MySQLStore) TestHistory(ctx context.Context, days int, userID *int) ([]ScoredResult, error) {
+	query := `
+		SELECT utr.user_id, t.test_name, CAST(utr.test_date AS CHAR), utr.time_taken, utr.abrupt_percentage
+		FROM UserTestResult utr
+		JOIN Test t ON utr.test_id = t.test_id
+		WHERE utr.test_date >= NOW() - INTERVAL ? DAY`
+	args := []interface{}{days}
+	if userID != nil {
+		query += ` AND utr.user_id = ?`
+		args = append(args, *userID)
+	}
+	rows, err := s.db.QueryContext(ctx, query+` ORDER BY utr.user_id, t.test_id, utr.test_date`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []ScoredResult
+	for rows.Next() {
+		var point ScoredResult
+		var testDate string
+		if err := rows.Scan(&point.UserID, &point.TestName, &testDate, &point.TimeTaken, &point.AbruptPercentage); err != nil {
+			return nil, err
+		}
+		if point.Date, err = time.Parse(sqlDateLayout, testDate); err != nil {
+			return nil, err
+		}
+		points = append(points, point)
+	}
+	return points, rows.Err()
+}
+
+func (s *MySQLStore) RiskDistribution(ctx context.Context, from, before time.Time) ([]RiskPeriod, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT period, COUNT(*),
+		       SUM(total_score >= 60), SUM(total_score >= 30 AND total_score < 60), SUM(total_score < 30)
+		FROM (
+			SELECT DATE_FORMAT(session_date, '%Y-%m') AS period, total_score,
+			       ROW_NUMBER() OVER (PARTITION BY user_id, DATE_FORMAT(session_date, '%Y-%m') ORDER BY session_date DESC, session_id DESC) AS latest
+			FROM TestSession
+			WHERE total_score IS NOT NULL AND session_date >= ? AND session_date < ?
+		) monthly
+		WHERE latest = 1
+		GROUP BY period
+		ORDER BY period`, from.Format(analyticsDateLayout), before.Format(analyticsDateLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	periods := []RiskPeriod{}
+	for rows.Next() {
+		var period RiskPeriod
+		if err := rows.Scan(&period.Period, &period.Participants, &period.Low, &period.Moderate, &period.High); err != nil {
+			return nil, err
+		}
+		periods = append(periods, period)
+	}
+	return periods, rows.Err()
+}
+
+func (s *MySQLStore) UserScoreTotals(ctx context.Context, from, before time.Time) ([]UserScoreTotal, error) {
+	fromDate, beforeDate := from.Format(analyticsDateLayout), before.Format(analyticsDateLayout)
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT user_id, ?, COUNT(*), SUM(total_score)
+		FROM TestSession
+		WHERE total_score IS NOT NULL AND session_date >= ? AND session_date < ?
+		GROUP BY user_id
+		UNION ALL
+		SELECT utr.user_id, CONCAT(t.test_name, ?), COUNT(*), SUM(utr.time_taken)
+		FROM UserTestResult utr
+		JOIN Test t ON utr.test_id = t.test_id
+		WHERE utr.test_date >= ? AND utr.test_date < ?
+		GROUP BY utr.user_id, t.test_name
+		UNION ALL
+		SELECT utr.user_id, CONCAT(t.test_name, ?), COUNT(*), SUM(utr.abrupt_percentage)
+		FROM UserTestResult utr
+		JOIN Test t ON utr.test_id = t.test_id
+		WHERE utr.test_date >= ? AND utr.test_date < ?
+		GROUP BY utr.user_id, t.test_name
+		ORDER BY 1, 2`,
+		SessionScoreMetric, fromDate, beforeDate,
+		TimeMetric(""), fromDate, beforeDate,
+		AbruptMetric(""), fromDate, beforeDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := []UserScoreTotal{}
+	for rows.Next() {
+		var total UserScoreTotal
+		if err := rows.Scan(&total.UserID, &total.Metric, &total.Count, &total.Sum); err != nil {
+			return nil, err
+		}
+		totals = append(totals, total)
+	}
+	return totals, rows.Err()
+}
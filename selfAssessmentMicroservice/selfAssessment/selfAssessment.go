@@ -1,121 +1,170 @@
 package selfAssessment
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
-	"database/sql"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"math"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"selfAssessmentMicroservice/client/user"
+	"selfAssessmentMicroservice/config"
+
+	"shared/apierror"
+	"shared/observability"
+	"shared/pagination"
+
 	mqtt "github.com/eclipse/paho.mqtt.golang"
-	_ "github.com/go-sql-driver/mysql" // MySQL driver
 	"github.com/gorilla/websocket"
-	"github.com/joho/godotenv"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-var db *sql.DB
+// testsPerSession is the number of tests in a complete self-assessment session
+const testsPerSession = 4
 
-func init() {
-	// Load environment variables
-	log.Println("Loading environment variables...")
-	err := godotenv.Load(".env")
-	if err != nil {
-		log.Fatalf("Error loading .env file: %v", err)
-	}
-	log.Println("Environment variables loaded successfully.")
+// RiskRecorder sends session scores to the combined risk model of the user microservice
+type RiskRecorder interface {
+	RecordRiskObservation(ctx context.Context, observation user.RiskObservation) error
+}
 
-	// Initialize database connection
-	log.Println("Initializing database connection...")
-	db, err = sql.Open("mysql", os.Getenv("SELF_DB_CONNECTION"))
-	if err != nil {
-		log.Fatalf("Error connecting to database: %v", err)
-	}
+// Handler serves the self-assessment endpoints from its store
+type Handler struct {
+	store        SessionStore
+	risk         RiskRecorder
+	observations sync.WaitGroup // Risk observations sent after their response, waited for by Drain
+}
 
-	// Test the database connection
-	err = db.Ping()
-	if err != nil {
-		log.Fatalf("Database connection test failed: %v", err)
-	}
-	log.Println("Database connection successful.")
+// NewHandler returns the self-assessment handlers, reading and writing store and sending new session scores to risk
+func NewHandler(store SessionStore, risk RiskRecorder) *Handler {
+	return &Handler{store: store, risk: risk}
 }
 
-// StartMQTTConnection initializes the MQTT connection and subscribes to a topic
+// StartMQTTConnection initializes the MQTT connection and subscribes to a topic, staying connected
+// until the service shuts down
 func StartMQTTConnection() {
-	log.Println("Starting MQTT connection...")
+	slog.Debug("Starting MQTT connection")
+	if !captures.hold() {
+		return
+	}
+	defer captures.release()
 
-	// Load AWS IoT credentials from environment variables
-	endpoint := os.Getenv("AWS_IOT_ENDPOINT")
-	clientID := os.Getenv("AWS_IOT_CLIENT_ID")
-	certFile := os.Getenv("AWS_IOT_CERT_FILE")
-	keyFile := os.Getenv("AWS_IOT_KEY_FILE")
-	caFile := os.Getenv("AWS_IOT_CA_FILE")
+	// Load the MQTT broker settings
+	broker := config.Current().Broker
 
-	log.Printf("AWS IoT Endpoint: %s", endpoint)
-	log.Printf("AWS IoT Client ID: %s", clientID)
+	slog.Debug("AWS IoT endpoint", "endpoint", broker.Endpoint)
+	slog.Debug("AWS IoT client ID", "client_id", broker.ClientID)
 
-	if endpoint == "" || clientID == "" || certFile == "" || keyFile == "" || caFile == "" {
-		log.Fatalf("AWS IoT credentials are not properly configured in the environment variables")
+	if missing := broker.Missing(); len(missing) > 0 {
+		slog.Error("MQTT broker is not configured", "missing", missing)
+		os.Exit(1)
 	}
 
 	// Configure MQTT options
 	opts := mqtt.NewClientOptions()
-	opts.AddBroker(fmt.Sprintf("ssl://%s:8883", endpoint))
-	opts.SetClientID(clientID)
-	opts.SetTLSConfig(createTLSConfig(certFile, keyFile, caFile))
+	configureBroker(opts, broker)
+	opts.SetClientID(broker.ClientID)
 	opts.SetDefaultPublishHandler(messageHandler)
 
 	// Create an MQTT client
-	log.Println("Creating MQTT client...")
+	slog.Debug("Creating MQTT client")
 	client := mqtt.NewClient(opts)
 
 	// Connect to AWS IoT Core
-	log.Println("Connecting to AWS IoT Core...")
+	slog.Debug("Connecting to AWS IoT Core")
 	if token := client.Connect(); token.Wait() && token.Error() != nil {
-		log.Fatalf("Failed to connect to AWS IoT Core: %v", token.Error())
+		slog.Error("Failed to connect to AWS IoT Core", "error", token.Error())
+		os.Exit(1)
 	}
-	log.Println("Connected to AWS IoT Core.")
+	slog.Info("Connected to AWS IoT Core")
 
 	// Subscribe to the topic
 	topic := "esp32s3/pub"
-	log.Printf("Subscribing to topic: %s", topic)
+	slog.Debug("Subscribing to topic", "topic", topic)
 	if token := client.Subscribe(topic, 1, nil); token.Wait() && token.Error() != nil {
-		log.Fatalf("Failed to subscribe to topic %s: %v", topic, token.Error())
+		slog.Error("Failed to subscribe to topic", "topic", topic, "error", token.Error())
+		os.Exit(1)
 	}
-	log.Printf("Successfully subscribed to topic: %s", topic)
+	slog.Info("Subscribed to topic", "topic", topic)
+
+	// Keep the MQTT client running until the service shuts down
+	<-captures.stopping
+	client.Disconnect(250)
+	slog.Info("Disconnected from AWS IoT Core")
+}
 
-	// Keep the MQTT client running
-	select {}
+// configureBroker points the MQTT options at MQTT_BROKER_URL when it is set, as for a local broker
+// reached without client certificates, and otherwise at AWS IoT Core over TLS
+func configureBroker(opts *mqtt.ClientOptions, broker config.Broker) {
+	if broker.URL != "" {
+		opts.AddBroker(broker.URL)
+		return
+	}
+	opts.AddBroker(fmt.Sprintf("ssl://%s:8883", broker.Endpoint))
+	opts.SetTLSConfig(createTLSConfig(broker.CertFile, broker.KeyFile, broker.CAFile))
+}
+
+// CheckBroker checks the MQTT broker the capture sessions subscribe through accepts connections,
+// for the readiness probe
+func CheckBroker(ctx context.Context) error {
+	broker := config.Current().Broker
+	if missing := broker.Missing(); len(missing) > 0 {
+		return fmt.Errorf("MQTT broker is not configured, missing %s", strings.Join(missing, ", "))
+	}
+
+	address := net.JoinHostPort(broker.Endpoint, "8883")
+	if broker.URL != "" {
+		brokerURL, err := url.Parse(broker.URL)
+		if err != nil {
+			return fmt.Errorf("invalid MQTT_BROKER_URL: %v", err)
+		}
+		address = brokerURL.Host
+		if brokerURL.Port() == "" {
+			port := "1883"
+			if brokerURL.Scheme == "ssl" || brokerURL.Scheme == "tls" || brokerURL.Scheme == "mqtts" {
+				port = "8883"
+			}
+			address = net.JoinHostPort(brokerURL.Hostname(), port)
+		}
+	}
+	return observability.Dial(address)(ctx)
 }
 
 // createTLSConfig creates a TLS configuration for the MQTT connection
 func createTLSConfig(certFile, keyFile, caFile string) *tls.Config {
-	log.Println("Loading TLS configuration...")
+	slog.Debug("Loading TLS configuration")
 
 	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
 	if err != nil {
-		log.Fatalf("Failed to load client certificate and key: %v", err)
+		slog.Error("Failed to load client certificate and key", "error", err)
+		os.Exit(1)
 	}
-	log.Println("Client certificate and key loaded successfully.")
+	slog.Info("Client certificate and key loaded successfully")
 
 	caCert, err := os.ReadFile(caFile)
 	if err != nil {
-		log.Fatalf("Failed to read CA certificate: %v", err)
+		slog.Error("Failed to read CA certificate", "error", err)
+		os.Exit(1)
 	}
-	log.Println("CA certificate loaded successfully.")
+	slog.Info("CA certificate loaded successfully")
 
 	caCertPool := x509.NewCertPool()
 	if !caCertPool.AppendCertsFromPEM(caCert) {
-		log.Fatalf("Failed to append CA certificate")
+		slog.Error("Failed to append CA certificate")
+		os.Exit(1)
 	}
 
-	log.Println("TLS configuration successfully created.")
+	slog.Info("TLS configuration successfully created")
 	return &tls.Config{
 		Certificates: []tls.Certificate{cert},
 		RootCAs:      caCertPool,
@@ -124,51 +173,49 @@ func createTLSConfig(certFile, keyFile, caFile string) *tls.Config {
 
 // messageHandler handles incoming MQTT messages and logs them
 func messageHandler(client mqtt.Client, msg mqtt.Message) {
-	log.Printf("Message received. Topic: %s, Payload: %s", msg.Topic(), string(msg.Payload()))
+	mqttMessagesReceived.WithLabelValues(msg.Topic()).Inc()
+	slog.Debug("Message received", "topic", msg.Topic(), "payload", string(msg.Payload()))
 
 	// Check if the payload is JSON
 	var payload map[string]interface{}
 	if err := json.Unmarshal(msg.Payload(), &payload); err != nil {
 		// If the payload is not JSON, just log it as a plain string
-		log.Printf("Payload is not JSON. Raw Payload: %s", string(msg.Payload()))
+		slog.Warn("Payload is not JSON", "payload", string(msg.Payload()))
 		return
 	}
 
 	// If the payload is JSON, log the parsed content
-	log.Printf("Parsed JSON Payload: %+v", payload)
+	slog.Debug("Parsed JSON payload", "payload", payload)
 }
 
 // TestReceiveMessages tests if the MQTT client can subscribe and receive three messages
 func TestReceiveMessages() bool {
-	log.Println("Starting TestReceiveMessages...")
+	slog.Debug("Starting TestReceiveMessages")
 
-	// Load AWS IoT credentials from environment variables
-	endpoint := os.Getenv("AWS_IOT_ENDPOINT")
-	clientID := os.Getenv("AWS_IOT_CLIENT_ID")
-	certFile := os.Getenv("AWS_IOT_CERT_FILE")
-	keyFile := os.Getenv("AWS_IOT_KEY_FILE")
-	caFile := os.Getenv("AWS_IOT_CA_FILE")
+	// Load the MQTT broker settings
+	broker := config.Current().Broker
 
-	if endpoint == "" || clientID == "" || certFile == "" || keyFile == "" || caFile == "" {
-		log.Fatalf("AWS IoT credentials are not properly configured in the environment variables")
+	if missing := broker.Missing(); len(missing) > 0 {
+		slog.Error("MQTT broker is not configured", "missing", missing)
+		os.Exit(1)
 	}
 
 	// Configure MQTT options
 	opts := mqtt.NewClientOptions()
-	opts.AddBroker(fmt.Sprintf("ssl://%s:8883", endpoint))
-	opts.SetClientID(clientID)
-	opts.SetTLSConfig(createTLSConfig(certFile, keyFile, caFile))
+	configureBroker(opts, broker)
+	opts.SetClientID(broker.ClientID)
 
 	// Create an MQTT client
-	log.Println("Creating MQTT client for test...")
+	slog.Debug("Creating MQTT client for test")
 	client := mqtt.NewClient(opts)
 
 	// Connect to AWS IoT Core
-	log.Println("Connecting to AWS IoT Core for test...")
+	slog.Debug("Connecting to AWS IoT Core for test")
 	if token := client.Connect(); token.Wait() && token.Error() != nil {
-		log.Fatalf("Failed to connect to AWS IoT Core: %v", token.Error())
+		slog.Error("Failed to connect to AWS IoT Core", "error", token.Error())
+		os.Exit(1)
 	}
-	log.Println("Connected to AWS IoT Core for test.")
+	slog.Info("Connected to AWS IoT Core for test")
 
 	// Test topic and message count
 	topic := "esp32s3/pub"
@@ -177,17 +224,19 @@ func TestReceiveMessages() bool {
 	wg.Add(1)
 
 	// Define a message handler
-	log.Printf("Subscribing to topic: %s", topic)
+	slog.Debug("Subscribing to topic", "topic", topic)
 	if token := client.Subscribe(topic, 1, func(client mqtt.Client, msg mqtt.Message) {
-		log.Printf("Test message received. Topic: %s, Payload: %s", msg.Topic(), string(msg.Payload()))
+		mqttMessagesReceived.WithLabelValues(msg.Topic()).Inc()
+		slog.Debug("Test message received", "topic", msg.Topic(), "payload", string(msg.Payload()))
 		messageCount++
 		if messageCount == 3 {
 			wg.Done()
 		}
 	}); token.Wait() && token.Error() != nil {
-		log.Fatalf("Failed to subscribe to topic %s: %v", topic, token.Error())
+		slog.Error("Failed to subscribe to topic", "topic", topic, "error", token.Error())
+		os.Exit(1)
 	}
-	log.Printf("Successfully subscribed to topic: %s", topic)
+	slog.Info("Subscribed to topic", "topic", topic)
 
 	// Wait for messages to be received
 	done := make(chan bool, 1)
@@ -198,11 +247,11 @@ func TestReceiveMessages() bool {
 
 	select {
 	case <-done:
-		log.Println("TestReceiveMessages: Successfully received 3 messages.")
+		slog.Info("TestReceiveMessages: Successfully received 3 messages")
 		client.Disconnect(250) // Disconnect the MQTT client immediately
 		return true
 	case <-time.After(5 * time.Second): // Timeout after 10 seconds
-		log.Println("TestReceiveMessages: Timed out waiting for messages.")
+		slog.Warn("TestReceiveMessages: Timed out waiting for messages")
 		client.Disconnect(250)
 		return false
 	}
@@ -238,15 +287,24 @@ var upgrader = websocket.Upgrader{
 }
 
 func StartWebSocketServer(w http.ResponseWriter, r *http.Request) {
-	log.Println("Upgrading connection to WebSocket...")
+	slog.DebugContext(r.Context(), "Upgrading connection to WebSocket")
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("Failed to upgrade to WebSocket: %v", err)
+		slog.ErrorContext(r.Context(), "Failed to upgrade to WebSocket", "error", err)
 		return
 	}
 	defer conn.Close()
 
-	log.Println("WebSocket connection established")
+	// Sessions opened while the service shuts down are turned away, the others are closed by Shutdown
+	if !captures.open(conn) {
+		conn.WriteControl(websocket.CloseMessage, goingAway, time.Now().Add(closeFrameTimeout))
+		return
+	}
+	defer captures.close(conn)
+
+	websocketSessionsActive.Inc()
+	defer websocketSessionsActive.Dec()
+	slog.InfoContext(r.Context(), "WebSocket connection established")
 
 	var movementData []MovementData
 	var capturing bool
@@ -255,119 +313,126 @@ func StartWebSocketServer(w http.ResponseWriter, r *http.Request) {
 	var mutex sync.Mutex
 
 	// MQTT Client Configuration
-	endpoint := os.Getenv("AWS_IOT_ENDPOINT")
-	clientID := os.Getenv("AWS_IOT_CLIENT_ID")
-	certFile := os.Getenv("AWS_IOT_CERT_FILE")
-	keyFile := os.Getenv("AWS_IOT_KEY_FILE")
-	caFile := os.Getenv("AWS_IOT_CA_FILE")
+	broker := config.Current().Broker
 
-	log.Println("Setting up MQTT options...")
+	slog.DebugContext(r.Context(), "Setting up MQTT options")
 	opts := mqtt.NewClientOptions()
-	opts.AddBroker(fmt.Sprintf("ssl://%s:8883", endpoint))
-	opts.SetClientID(clientID)
-	opts.SetTLSConfig(createTLSConfig(certFile, keyFile, caFile))
+	configureBroker(opts, broker)
+	opts.SetClientID(broker.ClientID)
 
 	client := mqtt.NewClient(opts)
 
 	// Connect to AWS IoT Core
-	log.Println("Connecting to AWS IoT Core for WebSocket...")
+	slog.DebugContext(r.Context(), "Connecting to AWS IoT Core for WebSocket")
 	if token := client.Connect(); token.Wait() && token.Error() != nil {
-		log.Fatalf("Failed to connect to AWS IoT Core: %v", token.Error())
+		slog.ErrorContext(r.Context(), "Failed to connect to AWS IoT Core", "error", token.Error())
+		os.Exit(1)
 	}
 	defer client.Disconnect(250)
-	log.Println("Connected to AWS IoT Core for WebSocket")
+	slog.InfoContext(r.Context(), "Connected to AWS IoT Core for WebSocket")
 
 	// Subscribe to the MQTT topic
 	topic := "esp32s3/pub"
-	log.Printf("Subscribing to MQTT topic: %s", topic)
+	slog.DebugContext(r.Context(), "Subscribing to MQTT topic", "topic", topic)
 	if token := client.Subscribe(topic, 1, func(client mqtt.Client, msg mqtt.Message) {
+		mqttMessagesReceived.WithLabelValues(msg.Topic()).Inc()
+		ctx, span := startMessageSpan(r.Context(), msg)
+		defer span.End()
 		if capturing {
-			log.Printf("Received MQTT message. Topic: %s, Payload: %s", msg.Topic(), string(msg.Payload()))
+			slog.DebugContext(ctx, "Received MQTT message", "topic", msg.Topic(), "payload", string(msg.Payload()))
 			var movement MovementData
 			if err := json.Unmarshal(msg.Payload(), &movement); err != nil {
-				log.Printf("Failed to parse MQTT message: %v", err)
+				slog.ErrorContext(ctx, "Failed to parse MQTT message", "error", err)
 				return
 			}
-			log.Printf("Parsed MQTT message: %+v", movement)
+			slog.DebugContext(ctx, "Parsed MQTT message", "movement", movement)
 			mutex.Lock()
 			movementData = append(movementData, movement)
 			totalCount++
 			if movement.AngleDifference > 5 { // Example threshold for abrupt movement
 				abruptCount++
 			}
-			log.Printf("Updated data. Abrupt Count: %d, Total Count: %d", abruptCount, totalCount)
+			slog.DebugContext(ctx, "Updated movement counts", "abrupt_count", abruptCount, "total_count", totalCount)
 			mutex.Unlock()
 		}
 	}); token.Wait() && token.Error() != nil {
-		log.Fatalf("Failed to subscribe to topic %s: %v", topic, token.Error())
+		slog.ErrorContext(r.Context(), "Failed to subscribe to topic", "topic", topic, "error", token.Error())
+		os.Exit(1)
 	}
-	log.Printf("Subscribed to MQTT topic: %s", topic)
+	slog.InfoContext(r.Context(), "Subscribed to MQTT topic", "topic", topic)
 
 	// Handle WebSocket Commands
 	for {
-		log.Println("Waiting for WebSocket commands...")
+		slog.DebugContext(r.Context(), "Waiting for WebSocket commands")
 		var msg WebSocketMessage
 		err := conn.ReadJSON(&msg)
+		if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+			slog.InfoContext(r.Context(), "WebSocket connection closed", "reason", err)
+			break
+		}
 		if err != nil {
-			log.Printf("WebSocket read error: %v", err)
+			slog.ErrorContext(r.Context(), "WebSocket read error", "error", err)
 			break
 		}
 
-		log.Printf("Received WebSocket command: %s", msg.Command)
+		// Each command is a span of the session, so the risk assessment returned on stop shows in its trace
+		ctx, span := tracer.Start(r.Context(), "websocket command", trace.WithAttributes(attribute.String("command", msg.Command)))
+		slog.DebugContext(ctx, "Received WebSocket command", "command", msg.Command)
 		switch msg.Command {
 		case "start":
-			log.Println("Starting data capture...")
+			slog.DebugContext(ctx, "Starting data capture")
 			mutex.Lock()
 			capturing = true
 			movementData = []MovementData{}
 			abruptCount = 0
 			totalCount = 0
 			mutex.Unlock()
-			log.Println("Data capture started.")
+			slog.InfoContext(ctx, "Data capture started")
 
 		case "stop":
-			log.Println("Stopping data capture...")
+			slog.DebugContext(ctx, "Stopping data capture")
 			mutex.Lock()
 			if capturing {
 				capturing = false
 				abruptPercentage := float64(abruptCount) / float64(totalCount) * 100
-				log.Printf("Calculated abrupt percentage: %f", abruptPercentage)
+				slog.DebugContext(ctx, "Calculated abrupt percentage", "abrupt_percentage", abruptPercentage)
 				riskLevel := determineRiskLevel(abruptPercentage)
 				riskAssessment := RiskAssessment{
 					AbruptPercentage: abruptPercentage,
 					RiskLevel:        riskLevel,
 				}
-				log.Printf("Generated risk assessment: %+v", riskAssessment)
+				slog.DebugContext(ctx, "Generated risk assessment", "assessment", riskAssessment)
 
 				// Convert riskAssessment to JSON manually and write it
 				riskAssessmentJSON, err := json.Marshal(riskAssessment)
 				if err != nil {
-					log.Printf("Error marshaling risk assessment: %v", err)
+					slog.ErrorContext(ctx, "Error marshaling risk assessment", "error", err)
 				} else {
 					if err := conn.WriteMessage(websocket.TextMessage, riskAssessmentJSON); err != nil {
-						log.Printf("WebSocket write error: %v", err)
+						slog.ErrorContext(ctx, "WebSocket write error", "error", err)
 					}
 				}
 			} else {
-				log.Println("Stop command received, but capturing was not active.")
+				slog.WarnContext(ctx, "Stop command received, but capturing was not active")
 			}
 			mutex.Unlock()
-			log.Println("Data capture stopped.")
+			slog.InfoContext(ctx, "Data capture stopped")
 			break
 
 		case "restart":
-			log.Println("Restarting data capture...")
+			slog.DebugContext(ctx, "Restarting data capture")
 			mutex.Lock()
 			capturing = true
 			movementData = []MovementData{}
 			abruptCount = 0
 			totalCount = 0
 			mutex.Unlock()
-			log.Println("Data capture restarted.")
+			slog.InfoContext(ctx, "Data capture restarted")
 
 		default:
-			log.Printf("Unknown command received: %s", msg.Command)
+			slog.WarnContext(ctx, "Unknown WebSocket command", "command", msg.Command)
 		}
+		span.End()
 	}
 }
 
@@ -381,189 +446,137 @@ func determineRiskLevel(abruptPercentage float64) string {
 }
 
 // StartTest creates a new test session for a given user ID and returns the session ID
-func StartTest(userID int) (int64, error) {
-	// Insert new test session
-	query := `
-		INSERT INTO TestSession (user_id, session_date)
-		VALUES (?, ?)
-	`
-	result, err := db.Exec(query, userID, time.Now())
-	if err != nil {
-		return 0, fmt.Errorf("failed to create new test session: %v", err)
-	}
-
-	// Get the session ID of the newly created session
-	sessionID, err := result.LastInsertId()
+func (h *Handler) StartTest(ctx context.Context, userID int) (int64, error) {
+	sessionID, err := h.store.StartSession(ctx, userID, time.Now())
 	if err != nil {
-		return 0, fmt.Errorf("failed to retrieve session ID: %v", err)
+		return 0, err
 	}
 
-	log.Printf("New test session created for user_id=%d with session_id=%d", userID, sessionID)
+	slog.InfoContext(ctx, "New test session created", "user_id", userID, "session_id", sessionID)
 	return sessionID, nil
 }
 
-func GetAllTests() ([]map[string]interface{}, error) {
-	log.Println("Retrieving all tests...")
-
-	query := `
-        SELECT 
-            test_id,
-            test_name,
-            description,
-            risk_metric,
-            video_url,
-            step_1,
-            step_2,
-            step_3,
-            step_4,
-            step_5,
-            enabled
-        FROM Test
-    `
-
-	rows, err := db.Query(query)
-	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve tests: %v", err)
-	}
-	defer rows.Close()
-
-	var tests []map[string]interface{}
-	for rows.Next() {
-		var testID int
-		var testName, description, riskMetric, videoURL, step1 string
-		var step2, step3, step4, step5 sql.NullString
-		var enabled bool
-
-		err := rows.Scan(
-			&testID, &testName, &description, &riskMetric, &videoURL,
-			&step1, &step2, &step3, &step4, &step5, &enabled,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan test row: %v", err)
-		}
-
-		test := map[string]interface{}{
-			"test_id":     testID,
-			"test_name":   testName,
-			"description": description,
-			"risk_metric": riskMetric,
-			"video_url":   videoURL,
-			"step_1":      step1,
-			"step_2":      nullStringToString(step2),
-			"step_3":      nullStringToString(step3),
-			"step_4":      nullStringToString(step4),
-			"step_5":      nullStringToString(step5),
-			"enabled":     enabled,
-		}
-		tests = append(tests, test)
-	}
+// GetAllTests returns every test
+func (h *Handler) GetAllTests(ctx context.Context) ([]Test, error) {
+	slog.DebugContext(ctx, "Retrieving all tests")
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error occurred while iterating through rows: %v", err)
+	tests, err := h.store.Tests(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	log.Println("All tests retrieved successfully.")
+	slog.InfoContext(ctx, "All tests retrieved successfully")
 	return tests, nil
 }
 
-func nullStringToString(ns sql.NullString) string {
-	if ns.Valid {
-		return ns.String
-	}
-	return ""
-}
-
 // SaveUserTestResult stores test result data into the UserTestResult table and updates the average score in TestSession
-func SaveUserTestResult(testSessionID int, userID int, testID int, timeTaken float64, websocketData string) error {
-	log.Println("Starting SaveUserTestResult function...")
+func (h *Handler) SaveUserTestResult(ctx context.Context, testSessionID int, userID int, testID int, timeTaken float64, websocketData string) error {
+	slog.DebugContext(ctx, "Starting SaveUserTestResult function")
 
 	// Parse the WebSocket data into a structured format
-	log.Println("Parsing WebSocket data...")
+	slog.DebugContext(ctx, "Parsing WebSocket data")
 	var resultData map[string]interface{}
 	err := json.Unmarshal([]byte(websocketData), &resultData)
 	if err != nil {
-		log.Printf("Error parsing WebSocket data: %v", err)
+		slog.WarnContext(ctx, "Error parsing WebSocket data", "error", err)
 		return fmt.Errorf("failed to parse WebSocket data: %v", err)
 	}
-	log.Println("WebSocket data parsed successfully.")
+	slog.InfoContext(ctx, "WebSocket data parsed successfully")
 
 	// Extract abrupt percentage and risk level from the WebSocket data
-	log.Println("Extracting abrupt_percentage and risk_level from WebSocket data...")
+	slog.DebugContext(ctx, "Extracting abrupt_percentage and risk_level from WebSocket data")
 	abruptPercentage, ok := resultData["abrupt_percentage"].(float64)
 	if !ok {
-		log.Println("Error: invalid or missing abrupt_percentage in WebSocket data")
+		slog.WarnContext(ctx, "Invalid or missing abrupt_percentage in WebSocket data")
 		return fmt.Errorf("invalid or missing abrupt_percentage in WebSocket data")
 	}
 
 	riskLevel, ok := resultData["risk_level"].(string)
 	if !ok {
-		log.Println("Error: invalid or missing risk_level in WebSocket data")
+		slog.WarnContext(ctx, "Invalid or missing risk_level in WebSocket data")
 		return fmt.Errorf("invalid or missing risk_level in WebSocket data")
 	}
-	log.Printf("Extracted values - abrupt_percentage: %f, risk_level: %s", abruptPercentage, riskLevel)
+	slog.DebugContext(ctx, "Extracted values", "abrupt_percentage", abruptPercentage, "risk_level", riskLevel)
 
-	// Save the test result into the UserTestResult table
-	query := `
-		INSERT INTO UserTestResult (
-			user_id, session_id, test_id, time_taken, abrupt_percentage, risk_level
-		) VALUES (?, ?, ?, ?, ?, ?)
-	`
-	log.Printf("Executing SQL query: %s", query)
-	_, err = db.Exec(query, userID, testSessionID, testID, timeTaken, abruptPercentage, riskLevel)
-	if err != nil {
-		log.Printf("Error executing SQL query: %v", err)
-		return fmt.Errorf("failed to save user test result: %v", err)
+	// Save the test result
+	result := NewTestResult{
+		SessionID:        testSessionID,
+		UserID:           userID,
+		TestID:           testID,
+		TimeTaken:        timeTaken,
+		AbruptPercentage: abruptPercentage,
+		RiskLevel:        riskLevel,
+	}
+	if err := h.store.SaveResult(ctx, result); err != nil {
+		slog.ErrorContext(ctx, "Error saving test result", "error", err)
+		return err
 	}
-	log.Printf("User test result saved successfully for user_id=%d, session_id=%d, test_id=%d", userID, testSessionID, testID)
+	slog.InfoContext(ctx, "User test result saved", "user_id", userID, "session_id", testSessionID, "test_id", testID)
 
 	// Recalculate the average score for the session
-	log.Println("Recalculating average score for the session...")
-	rows, err := db.Query(`
-		SELECT utr.time_taken, utr.abrupt_percentage, t.test_name 
-		FROM UserTestResult utr
-		JOIN Test t ON utr.test_id = t.test_id
-		WHERE utr.session_id = ?`, testSessionID)
+	slog.DebugContext(ctx, "Recalculating average score for the session")
+	results, err := h.store.SessionResults(ctx, testSessionID)
 	if err != nil {
-		log.Printf("Error querying test results for session: %v", err)
-		return fmt.Errorf("failed to query test results: %v", err)
+		slog.ErrorContext(ctx, "Error querying test results for session", "error", err)
+		return err
 	}
-	defer rows.Close()
 
 	var totalScore, count float64
-	for rows.Next() {
-		var timeTaken float64
-		var abruptPercentage float64
-		var testName string
-
-		if err := rows.Scan(&timeTaken, &abruptPercentage, &testName); err != nil {
-			log.Printf("Error scanning test result row: %v", err)
-			return fmt.Errorf("failed to scan test result row: %v", err)
-		}
-
+	for _, result := range results {
 		// Calculate the score using the provided logic
-		score := calculateScore(timeTaken, abruptPercentage, testName)
+		score := calculateScore(result.TimeTaken, result.AbruptPercentage, result.TestName)
 		totalScore += float64(score)
 		count++
 	}
 
 	if count == 0 {
-		log.Printf("No test results found for session_id=%d", testSessionID)
+		slog.WarnContext(ctx, "No test results found for session", "session_id", testSessionID)
 		return nil
 	}
 
 	// Update the TestSession with the calculated average score
-	updateQuery := `UPDATE TestSession SET total_score = ? WHERE session_id = ?`
-	_, err = db.Exec(updateQuery, totalScore/4, testSessionID)
-	if err != nil {
-		log.Printf("Error updating average score in TestSession: %v", err)
-		return fmt.Errorf("failed to update average score: %v", err)
+	if err := h.store.SetSessionScore(ctx, testSessionID, totalScore/testsPerSession); err != nil {
+		slog.ErrorContext(ctx, "Error updating average score in TestSession", "error", err)
+		return err
 	}
 
-	log.Printf("Average score updated successfully for session_id=%d", testSessionID)
-	log.Println("SaveUserTestResult function completed.")
+	slog.InfoContext(ctx, "Average score updated", "session_id", testSessionID)
+	// Partial sessions score low because of the missing tests, so only complete ones feed the risk model
+	if count >= testsPerSession {
+		h.observations.Add(1)
+		go h.recordRiskObservation(context.WithoutCancel(ctx), userID, testSessionID, totalScore/testsPerSession)
+	}
+	slog.InfoContext(ctx, "SaveUserTestResult function completed")
 	return nil
 }
 
+// recordRiskObservation sends a session's updated score to the combined risk model.
+// The result is already saved, so a failure is only logged. The context keeps the request ID but
+// not the request's cancellation, as this runs after the response is sent.
+func (h *Handler) recordRiskObservation(ctx context.Context, userID, testSessionID int, sessionScore float64) {
+	defer h.observations.Done()
+	observation := user.RiskObservation{UserID: userID, Source: user.SourceSelfAssessment, SourceID: testSessionID, Score: sessionScore}
+	if err := h.risk.RecordRiskObservation(ctx, observation); err != nil {
+		slog.ErrorContext(ctx, "Error recording risk observation", "user_id", userID, "error", err)
+	}
+}
+
+// Drain waits for the risk observations still being sent after their responses, until the context
+// ends. Serve runs it once the requests in flight are done, so no new ones start.
+func (h *Handler) Drain(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		h.observations.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("risk observations were still being sent at shutdown: %w", ctx.Err())
+	}
+}
+
 // calculateScore calculates the final score based on time taken and abrupt percentage
 func calculateScore(timeTaken, abruptPercentage float64, testName string) int {
 	// Define test-specific tolerances
@@ -605,7 +618,7 @@ func calculateScore(timeTaken, abruptPercentage float64, testName string) int {
 			timeScore = math.Min(100, (timeTaken/tolerance.TimeTolerance)*100)
 		}
 	default:
-		fmt.Println("Unknown test type:", testName)
+		slog.Warn("Unknown test type", "test", testName)
 		timeScore = 50 // Default score
 	}
 
@@ -620,9 +633,8 @@ func calculateScore(timeTaken, abruptPercentage float64, testName string) int {
 	// Weighted average (70% time, 30% abruptness)
 	finalScore := int(math.Round(timeScore*0.7 + abruptScore*0.3))
 
-	// Debugging output
-	fmt.Printf("Test: %s, Time Taken: %.2f, Abrupt Percentage: %.2f, Time Score: %.2f, Abrupt Score: %.2f, Final Score: %d\n",
-		testName, timeTaken, abruptPercentage, timeScore, abruptScore, finalScore)
+	slog.Debug("Scored test", "test", testName, "time_taken", timeTaken, "abrupt_percentage", abruptPercentage,
+		"time_score", timeScore, "abrupt_score", abruptScore, "final_score", finalScore)
 
 	return finalScore
 }
@@ -643,140 +655,57 @@ type TestSession struct {
 	SessionID    int              `json:"session_id"`
 	UserID       int              `json:"user_id"`
 	SessionDate  time.Time        `json:"session_date"`
-	TotalScore     sql.NullInt64    `json:"total_score,omitempty"`
-	SessionNotes sql.NullString   `json:"session_notes,omitempty"`
+	TotalScore   *int             `json:"total_score"`   // Null until the session is scored
+	SessionNotes *string          `json:"session_notes"` // Null when there are no notes
 	TestResults  []UserTestResult `json:"test_results"`
 }
 
 // GetTestSessions retrieves test sessions and results for a given userID
-func GetTestSessions(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetTestSessions(w http.ResponseWriter, r *http.Request) {
 	// Extract userID from query parameters
 	userIDStr := r.URL.Query().Get("user_id")
 	if userIDStr == "" {
-		http.Error(w, "user_id is required", http.StatusBadRequest)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "user_id is required")
 		return
 	}
 
 	userID, err := strconv.Atoi(userIDStr)
 	if err != nil {
-		http.Error(w, "Invalid user_id", http.StatusBadRequest)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidField, "Invalid user_id")
 		return
 	}
 
-	// Query to fetch test sessions
-	sessionQuery := `
-		SELECT 
-			session_id, 
-			user_id, 
-			CAST(session_date AS CHAR), -- Convert to string
-			total_score, 
-			session_notes
-		FROM TestSession
-		WHERE user_id = ?
-		ORDER BY session_date DESC;
-	`
-
-	rows, err := db.Query(sessionQuery, userID)
+	sessions, err := h.completeSessions(r.Context(), userID)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Database query failed: %v", err), http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error querying test sessions", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, fmt.Sprintf("Database query failed: %v", err))
 		return
 	}
-	defer rows.Close()
-
-	var sessions []TestSession
-
-	// Iterate through each session
-	for rows.Next() {
-		var session TestSession
-		var sessionDateStr string // Store as string before parsing
-
-		if err := rows.Scan(
-			&session.SessionID, &session.UserID, &sessionDateStr, &session.TotalScore, &session.SessionNotes,
-		); err != nil {
-			http.Error(w, fmt.Sprintf("Error scanning session row: %v", err), http.StatusInternalServerError)
-			return
-		}
-
-		// Debug: Print sessionDateStr before parsing
-		log.Printf("Raw session_date string: %s", sessionDateStr)
-
-		// Parse session_date using "YYYY-MM-DD HH:MM:SS" format
-		parsedSessionDate, err := time.Parse("2006-01-02 15:04:05", sessionDateStr)
-		if err != nil {
-			log.Printf("Error parsing session_date: %v", err)
-			http.Error(w, fmt.Sprintf("Error parsing session_date: %v", err), http.StatusInternalServerError)
-			return
-		}
-		session.SessionDate = parsedSessionDate
-
-		// Query to fetch test results for the session
-		testResultQuery := `
-			SELECT 
-				utr.result_id, 
-				utr.test_id, 
-				t.test_name,
-				utr.time_taken, 
-				utr.abrupt_percentage, 
-				utr.risk_level, 
-				CAST(utr.test_date AS CHAR) -- Convert test_date to string
-			FROM UserTestResult utr
-			JOIN Test t ON utr.test_id = t.test_id
-			WHERE utr.session_id = ?
-			ORDER BY utr.test_date DESC;
-		`
-
-		testRows, err := db.Query(testResultQuery, session.SessionID)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Error querying test results: %v", err), http.StatusInternalServerError)
-			return
-		}
-		defer testRows.Close()
-
-		var testResults []UserTestResult
-		for testRows.Next() {
-			var result UserTestResult
-			var testDateStr string
-
-			if err := testRows.Scan(
-				&result.ResultID, &result.TestID, &result.TestName,
-				&result.TimeTaken, &result.AbruptPercentage,
-				&result.RiskLevel, &testDateStr,
-			); err != nil {
-				http.Error(w, fmt.Sprintf("Error scanning test result row: %v", err), http.StatusInternalServerError)
-				return
-			}
 
-			// Debug: Print testDateStr before parsing
-			log.Printf("Raw test_date string: %s", testDateStr)
-
-			// Parse test_date using "YYYY-MM-DD HH:MM:SS" format
-			parsedTestDate, err := time.Parse("2006-01-02 15:04:05", testDateStr)
-			if err != nil {
-				log.Printf("Error parsing test_date: %v", err)
-				http.Error(w, fmt.Sprintf("Error parsing test_date: %v", err), http.StatusInternalServerError)
-				return
-			}
-			result.TestDate = parsedTestDate
+	// Encode results as JSON and send response
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(sessions); err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Failed to encode JSON")
+	}
+}
 
-			testResults = append(testResults, result)
-		}
+// completeSessions returns a senior's complete sessions, newest first, with their test results
+func (h *Handler) completeSessions(ctx context.Context, userID int) ([]TestSession, error) {
+	sessions, err := h.store.UserSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
 
-		// **Exclude sessions if they don't have exactly 4 test results**
-		if len(testResults) != 4 {
-			log.Printf("Skipping session %d (only %d test results)", session.SessionID, len(testResults))
+	// **Exclude sessions if they don't have exactly 4 test results**
+	complete := sessions[:0]
+	for _, session := range sessions {
+		if len(session.TestResults) != testsPerSession {
+			slog.DebugContext(ctx, "Skipping incomplete session", "session_id", session.SessionID, "test_results", len(session.TestResults))
 			continue
 		}
-
-		// Assign test results to the session
-		session.TestResults = testResults
-		sessions = append(sessions, session)
-	}
-
-	// Encode results as JSON and send response
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(sessions); err != nil {
-		http.Error(w, "Failed to encode JSON", http.StatusInternalServerError)
+		complete = append(complete, session)
 	}
+	return complete, nil
 }
 
 // Struct to hold user_id, avg score and session_date
@@ -784,57 +713,69 @@ type TestSessionUser struct {
 	SessionID   int       `json:"session_id"`   // Unique ID for the session
 	UserID      int       `json:"user_id"`      // Associated user ID
 	SessionDate time.Time `json:"session_date"` // Date and time of the session
-	TotalScore    int16     `json:"total_score"`    // Average score for the session
+	TotalScore  int16     `json:"total_score"`  // Average score for the session
 }
 
-// GetAllUserTotalScore fetches all test sessions with total scores
-func GetAllUserTotalScore(w http.ResponseWriter, r *http.Request) {
-	// Define a slice to store the list of test session user results
-	var sessionResults []TestSessionUser
+// sessionScoreListOptions are the sorts and filters of the list of session scores
+var sessionScoreListOptions = pagination.Options{
+	Sort: map[string]string{
+		"session_id":   "session_id",
+		"session_date": "session_date",
+		"total_score":  "total_score",
+		"user_id":      "user_id",
+	},
+	DefaultSort: "session_id",
+	IDColumn:    "session_id",
+	DateColumn:  "session_date",
+	UserColumn:  "user_id",
+}
 
-	// Query to fetch all test session details
-	rows, err := db.Query(`
-		SELECT session_id, user_id, session_date, total_score
-		FROM TestSession`)
+// GetAllUserTotalScore fetches a page of test sessions with total scores, see the pagination package for the query parameters
+func (h *Handler) GetAllUserTotalScore(w http.ResponseWriter, r *http.Request) {
+	page, err := pagination.Parse(r, sessionScoreListOptions)
 	if err != nil {
-		log.Printf("Error querying test session results: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidField, err.Error())
 		return
 	}
-	defer rows.Close()
-
-	// Iterate over the rows and scan the values into the sessionResults slice
-	for rows.Next() {
-		var session TestSessionUser
-		if err := rows.Scan(
-			&session.SessionID,
-			&session.UserID,
-			&session.SessionDate,
-			&session.TotalScore,
-		); err != nil {
-			log.Printf("Error scanning test session row: %v", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
-		sessionResults = append(sessionResults, session)
-	}
 
-	// Check if there was an error while iterating over the rows
-	if err := rows.Err(); err != nil {
-		log.Printf("Error iterating over rows: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	sessionResults, total, err := h.store.SessionScores(r.Context(), page)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying test session results", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
 		return
 	}
 
-	// Respond with the list of test session results as JSON
+	// Respond with the page of test session results as JSON
+	page.WriteHeaders(w, total, len(sessionResults))
 	w.Header().Set("Content-Type", "application/json")
 	err = json.NewEncoder(w).Encode(sessionResults)
 	if err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error encoding response", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+}
+
+// GetAllLatestTotalScore returns each senior's most recent scored session, so the admin dashboard
+// reads one row per senior instead of every session
+func (h *Handler) GetAllLatestTotalScore(w http.ResponseWriter, r *http.Request) {
+	sessions, err := h.store.LatestSessionScores(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying latest test sessions", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	// Respond with the latest test sessions as JSON
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(sessions)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error encoding response", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
 		return
 	}
 }
+
 // Struct to represent avg_time with test name Result
 type FATestWithAvgTime struct {
 	ResultID    uint      `json:"result_id"`
@@ -844,54 +785,43 @@ type FATestWithAvgTime struct {
 	SessionDate time.Time `json:"session_date"`
 }
 
-// Function to fetch the test name, as well as the userID and time taken.
-func GetAllFATestWithAvgTime(w http.ResponseWriter, r *http.Request) {
-	// Define a slice to store the list of test results
-	var testResults []FATestWithAvgTime
-
-	// Query to fetch test results with test name and session date
-	rows, err := db.Query(`
-		SELECT utr.result_id, t.test_name, utr.user_id, utr.time_taken, ts.session_date
-		FROM UserTestResult utr
-		JOIN Test t ON utr.test_id = t.test_id
-		JOIN TestSession ts ON utr.session_id = ts.session_id`)
+// testTimeListOptions are the sorts and filters of the list of test times
+var testTimeListOptions = pagination.Options{
+	Sort: map[string]string{
+		"result_id":    "utr.result_id",
+		"test_name":    "t.test_name",
+		"time_taken":   "utr.time_taken",
+		"session_date": "ts.session_date",
+		"user_id":      "utr.user_id",
+	},
+	DefaultSort: "result_id",
+	IDColumn:    "utr.result_id",
+	DateColumn:  "ts.session_date",
+	UserColumn:  "utr.user_id",
+}
+
+// Function to fetch a page of the test name, as well as the userID and time taken, see the pagination package for the query parameters
+func (h *Handler) GetAllFATestWithAvgTime(w http.ResponseWriter, r *http.Request) {
+	page, err := pagination.Parse(r, testTimeListOptions)
 	if err != nil {
-		log.Printf("Error querying user test results: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidField, err.Error())
 		return
 	}
-	defer rows.Close()
-
-	// Iterate over the rows and scan the values into the testResults slice
-	for rows.Next() {
-		var result FATestWithAvgTime
-		if err := rows.Scan(
-			&result.ResultID,
-			&result.TestName,
-			&result.UserID,
-			&result.TimeTaken,
-			&result.SessionDate,
-		); err != nil {
-			log.Printf("Error scanning test result row: %v", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
-		testResults = append(testResults, result)
-	}
 
-	// Check if there was an error while iterating over the rows
-	if err := rows.Err(); err != nil {
-		log.Printf("Error iterating over rows: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	testResults, total, err := h.store.TestTimes(r.Context(), page)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying user test results", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
 		return
 	}
 
-	// Respond with the list of test results as JSON
+	// Respond with the page of test results as JSON
+	page.WriteHeaders(w, total, len(testResults))
 	w.Header().Set("Content-Type", "application/json")
 	err = json.NewEncoder(w).Encode(testResults)
 	if err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error encoding response", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
 		return
 	}
 }
@@ -914,8 +844,8 @@ func calculateScoreAdmin(timeTaken, abruptPercentage float64, testName string) i
 	tolerancesMap := map[string]Tolerances{
 		"Timed Up and Go Test":         {20, 30},
 		"Five Times Sit to Stand Test": {25, 40},
-		"Dynamic Gait Index (DGI)":      {25, 20},
-		"4 Stage Balance Test":          {40, 15},
+		"Dynamic Gait Index (DGI)":     {25, 20},
+		"4 Stage Balance Test":         {40, 15},
 	}
 
 	// Use default values if the test name is not found.
@@ -952,7 +882,7 @@ func calculateScoreAdmin(timeTaken, abruptPercentage float64, testName string) i
 		}
 
 	default:
-		log.Printf("Unknown test type: %s", testName)
+		slog.Warn("Unknown test type", "test", testName)
 		timeScore = 50 // Default score for unknown tests.
 	}
 
@@ -971,8 +901,8 @@ func calculateScoreAdmin(timeTaken, abruptPercentage float64, testName string) i
 	finalScoreFloat := timeScore*0.7 + abruptScore*0.3
 	finalScore := int(math.Round(finalScoreFloat))
 
-	log.Printf("Test: %s | TimeTaken: %.2f | AbruptPercentage: %.2f | TimeScore: %.2f | AbruptScore: %.2f | FinalScore: %d",
-		testName, timeTaken, abruptPercentage, timeScore, abruptScore, finalScore)
+	slog.Debug("Scored test", "test", testName, "time_taken", timeTaken, "abrupt_percentage", abruptPercentage,
+		"time_score", timeScore, "abrupt_score", abruptScore, "final_score", finalScore)
 
 	return finalScore
 }
@@ -980,7 +910,7 @@ func calculateScoreAdmin(timeTaken, abruptPercentage float64, testName string) i
 // determineRiskLevel returns a risk level based on the final score.
 // In this mapping, a lower final score indicates a higher risk.
 func determineRiskLevelAdmin(totalScore int) string {
-	log.Printf("Final Score: %d", totalScore)
+	slog.Debug("Final score", "score", totalScore)
 	if totalScore < 30 {
 		return "high"
 	}
@@ -992,151 +922,70 @@ func determineRiskLevelAdmin(totalScore int) string {
 
 // GetUserOverallLatestRisk retrieves the latest test result per user,
 // calculates the test score using the new scoring logic, and determines the risk level.
-func GetUserOverallLatestRisk(w http.ResponseWriter, r *http.Request) {
-	var userRisks []UserRisk
-
-	// This query retrieves, for each user, their latest test result (by test_date)
-	// and joins with the Test table to obtain the test name.
-	query := `
-		WITH LatestTest AS (
-			SELECT
-				utr.user_id, 
-				utr.time_taken, 
-				utr.abrupt_percentage, 
-				t.test_name,
-				ROW_NUMBER() OVER (PARTITION BY utr.user_id ORDER BY utr.test_date DESC) AS rn
-			FROM UserTestResult utr
-			JOIN Test t ON utr.test_id = t.test_id
-		)
-		SELECT user_id, time_taken, abrupt_percentage, test_name
-		FROM LatestTest
-		WHERE rn = 1;
-	`
-
-	rows, err := db.Query(query)
+func (h *Handler) GetUserOverallLatestRisk(w http.ResponseWriter, r *http.Request) {
+	userRisks := []UserRisk{}
+
+	results, err := h.store.LatestResults(r.Context())
 	if err != nil {
-		log.Printf("Error executing query: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error executing query", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
 		return
 	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var userID int
-		var timeTaken float64
-		var abruptPercentage float64
-		var testName string
-
-		if err := rows.Scan(&userID, &timeTaken, &abruptPercentage, &testName); err != nil {
-			log.Printf("Error scanning row: %v", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
 
+	for _, result := range results {
 		// Calculate the final score for this test.
-		finalScore := calculateScoreAdmin(timeTaken, abruptPercentage, testName)
+		finalScore := calculateScoreAdmin(result.TimeTaken, result.AbruptPercentage, result.TestName)
 		// Determine the risk level from the final score.
 		riskLevel := determineRiskLevelAdmin(finalScore)
 
 		userRisks = append(userRisks, UserRisk{
-			UserID:           userID,
+			UserID:           result.UserID,
 			OverallRiskLevel: riskLevel,
 		})
 	}
 
-	if err := rows.Err(); err != nil {
-		log.Printf("Error iterating over rows: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
-	}
-
 	// Return the result as JSON.
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(userRisks); err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error encoding response", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
 		return
 	}
 }
 
 // struct to hold the user ID and days since last response
 type FallAssesLastRes struct {
-	UserID             int    `json:"user_id"`
-	DaysSinceResponse  int    `json:"days_since_last_fares"`
+	UserID            int `json:"user_id"`
+	DaysSinceResponse int `json:"days_since_last_fares"`
 }
+
 // Gets the user_id with days since last response for fall assessment
-func GetAllFallAssesLatestResDate(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetAllFallAssesLatestResDate(w http.ResponseWriter, r *http.Request) {
 	// Define a slice to store the list of user responses
-	var userResponseList []FallAssesLastRes
-
-	// Query to fetch the user_id and last response date from FallAssessment table. Dont accept incomplete work
-	rows, err := db.Query(`
-		WITH RankedSessions AS (
-			SELECT 
-				user_id, 
-				session_date, 
-				ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY session_date DESC) AS session_rank
-			FROM TestSession
-			WHERE user_id IS NOT NULL
-				AND session_date IS NOT NULL 
-				AND total_score IS NOT NULL
-		),
-		LatestSession AS (
-			SELECT user_id, session_date
-			FROM RankedSessions
-			WHERE session_rank = 1
-		)
-		SELECT user_id, MAX(session_date) AS last_response_date
-		FROM LatestSession
-		GROUP BY user_id;
-
-	`)
+	userResponseList := []FallAssesLastRes{}
+
+	// Fetch the last scored session of every user. Dont accept incomplete work
+	sessions, err := h.store.LatestScoredSessions(r.Context())
 	if err != nil {
-		log.Printf("Error querying fall assessment responses: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error querying fall assessment responses", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
 		return
 	}
-	defer rows.Close()
-
-	// Iterate over the rows and scan the values into the userResponseList slice
-	for rows.Next() {
-		var response FallAssesLastRes
-		var lastResponseDate string
-		if err := rows.Scan(&response.UserID, &lastResponseDate); err != nil {
-			log.Printf("Error scanning fall assessment row: %v", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
-
-		// Parse the last response date and calculate the number of days since that date
-		parsedDate, err := time.Parse("2006-01-02T15:04:05Z", lastResponseDate)
-		if err != nil {
-			log.Printf("Error parsing last response date: %v", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
 
+	for _, session := range sessions {
 		// Calculate the difference in days
-		daysSinceResponse := int(time.Since(parsedDate).Hours() / 24)
-		response.DaysSinceResponse = daysSinceResponse
+		daysSinceResponse := int(time.Since(session.SessionDate).Hours() / 24)
 
 		// Add the result to the response list
-		userResponseList = append(userResponseList, response)
-	}
-
-	// Check if there was an error while iterating over the rows
-	if err := rows.Err(); err != nil {
-		log.Printf("Error iterating over rows: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+		userResponseList = append(userResponseList, FallAssesLastRes{UserID: session.UserID, DaysSinceResponse: daysSinceResponse})
 	}
 
 	// Respond with the list of user responses as JSON
 	w.Header().Set("Content-Type", "application/json")
 	err = json.NewEncoder(w).Encode(userResponseList)
 	if err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error encoding response", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
 		return
 	}
-}
\ No newline at end of file
+}
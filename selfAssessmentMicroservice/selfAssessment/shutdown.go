@@ -0,0 +1,102 @@
+package selfAssessment
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// closeFrameTimeout bounds writing the close frame to a capture session's socket
+const closeFrameTimeout = time.Second
+
+// captures tracks the capture sessions' sockets and the background MQTT connections, which the HTTP
+// server no longer sees once they are running, so that shutting down closes them rather than cutting
+// them off
+var captures = &captureSet{sockets: map[*websocket.Conn]struct{}{}, stopping: make(chan struct{})}
+
+type captureSet struct {
+	mu       sync.Mutex
+	sockets  map[*websocket.Conn]struct{}
+	running  sync.WaitGroup
+	stopping chan struct{} // Closed when the service starts shutting down
+	stopped  bool
+}
+
+// open adds a capture session's socket, reporting false once the service is shutting down
+func (c *captureSet) open(conn *websocket.Conn) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stopped {
+		return false
+	}
+	c.sockets[conn] = struct{}{}
+	c.running.Add(1)
+	return true
+}
+
+// close removes a capture session's socket once its handler has disconnected from MQTT
+func (c *captureSet) close(conn *websocket.Conn) {
+	c.mu.Lock()
+	delete(c.sockets, conn)
+	c.mu.Unlock()
+	c.running.Done()
+}
+
+// hold adds a background MQTT connection, which runs until stopping is closed, reporting false
+// once the service is shutting down
+func (c *captureSet) hold() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stopped {
+		return false
+	}
+	c.running.Add(1)
+	return true
+}
+
+// release removes a background MQTT connection once it has disconnected
+func (c *captureSet) release() {
+	c.running.Done()
+}
+
+// goingAway is the close frame sent to the capture sessions when the service shuts down, so the
+// frontend can tell a rollout from a failure and reconnect
+var goingAway = websocket.FormatCloseMessage(websocket.CloseGoingAway, "service shutting down")
+
+// Shutdown sends every capture session a close frame, stops the background MQTT connections and
+// waits for their handlers to disconnect from MQTT, until the context ends. Sockets still open then
+// are closed.
+func Shutdown(ctx context.Context) error {
+	captures.mu.Lock()
+	if !captures.stopped {
+		captures.stopped = true
+		close(captures.stopping)
+	}
+	sockets := make([]*websocket.Conn, 0, len(captures.sockets))
+	for conn := range captures.sockets {
+		sockets = append(sockets, conn)
+	}
+	captures.mu.Unlock()
+
+	for _, conn := range sockets {
+		conn.WriteControl(websocket.CloseMessage, goingAway, time.Now().Add(closeFrameTimeout))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		captures.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		for _, conn := range sockets {
+			conn.Close()
+		}
+		return fmt.Errorf("capture sessions were still open at shutdown: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,88 @@
+package selfAssessment
+
+import (
+	"context"
+	"time"
+
+	"shared/pagination"
+)
+
+// SessionStore is the self-assessment data the handlers read and write. MySQLStore keeps it in the
+// self-assessment database, and the memstore package keeps it in memory for handler tests.
+type SessionStore interface {
+	// StartSession creates a senior's session dated date, returning its ID
+	StartSession(ctx context.Context, userID int, date time.Time) (int64, error)
+	// Tests returns every test
+	Tests(ctx context.Context) ([]Test, error)
+	// SaveResult stores the result of one test of a session
+	SaveResult(ctx context.Context, result NewTestResult) error
+	// SessionResults returns the results saved so far in a session, for scoring it
+	SessionResults(ctx context.Context, sessionID int) ([]ScoredResult, error)
+	// SetSessionScore sets the total score of a session
+	SetSessionScore(ctx context.Context, sessionID int, score float64) error
+	// UserSessions returns a senior's sessions newest first, with their results, whether complete or not
+	UserSessions(ctx context.Context, userID int) ([]TestSession, error)
+	// SessionScores returns a page of the sessions of every senior, and the number matching the filters
+	SessionScores(ctx context.Context, page *pagination.Query) ([]TestSessionUser, int, error)
+	// TestTimes returns a page of the results of every senior, and the number matching the filters
+	TestTimes(ctx context.Context, page *pagination.Query) ([]FATestWithAvgTime, int, error)
+	// LatestResults returns the most recent test result of every senior
+	LatestResults(ctx context.Context) ([]ScoredResult, error)
+	// LatestScoredSessions returns the date of every senior's most recent scored session
+	LatestScoredSessions(ctx context.Context) ([]LastSession, error)
+	// LatestSessionScores returns every senior's most recent scored session
+	LatestSessionScores(ctx context.Context) ([]TestSessionUser, error)
+	// SessionScoreHistory returns the scored sessions of the last days, of one senior or of every senior
+	// when userID is nil, ordered by senior then date
+	SessionScoreHistory(ctx context.Context, days int, userID *int) ([]ScoredResult, error)
+	// TestHistory returns the test results of the last days, of one senior or of every senior when
+	// userID is nil, ordered by senior, test then date
+	TestHistory(ctx context.Context, days int, userID *int) ([]ScoredResult, error)
+	// RiskDistribution returns the spread of risk levels per month of the sessions from from until before
+	RiskDistribution(ctx context.Context, from, before time.Time) ([]RiskPeriod, error)
+	// UserScoreTotals returns the count and sum of each senior's session scores, and of their time and
+	// abrupt movement on each test, from from until before
+	UserScoreTotals(ctx context.Context, from, before time.Time) ([]UserScoreTotal, error)
+}
+
+// Test is one of the self-assessment tests. Steps that are not set are empty.
+type Test struct {
+	TestID      int    `json:"test_id"`
+	TestName    string `json:"test_name"`
+	Description string `json:"description"`
+	RiskMetric  string `json:"risk_metric"`
+	VideoURL    string `json:"video_url"`
+	Step1       string `json:"step_1"`
+	Step2       string `json:"step_2"`
+	Step3       string `json:"step_3"`
+	Step4       string `json:"step_4"`
+	Step5       string `json:"step_5"`
+	Enabled     bool   `json:"enabled"`
+}
+
+// NewTestResult is a test result to be saved
+type NewTestResult struct {
+	SessionID        int
+	UserID           int
+	TestID           int
+	TimeTaken        float64
+	AbruptPercentage float64
+	RiskLevel        string
+}
+
+// ScoredResult is a test result or session score with what scoring and trends need of it.
+// Session scores leave the test fields empty and carry the score in Score.
+type ScoredResult struct {
+	UserID           int
+	TestName         string
+	TimeTaken        float64
+	AbruptPercentage float64
+	Score            float64
+	Date             time.Time
+}
+
+// LastSession is the date of a senior's most recent scored session
+type LastSession struct {
+	UserID      int
+	SessionDate time.Time
+}
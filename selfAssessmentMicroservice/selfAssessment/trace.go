@@ -0,0 +1,47 @@
+package selfAssessment
+
+import (
+	"context"
+	"encoding/json"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer records the spans of the movement capture sessions
+var tracer = otel.Tracer("selfAssessmentMicroservice/selfAssessment")
+
+// messageTrace is the W3C trace context a sensor may send with a reading. MQTT 3.1.1 messages
+// have no headers, so it travels in the JSON payload as the traceparent and tracestate fields.
+type messageTrace struct {
+	TraceParent string `json:"traceparent"`
+	TraceState  string `json:"tracestate"`
+}
+
+// startMessageSpan starts the span of an MQTT message received during a capture session. A message
+// carrying the sensor's trace continues it, linked to the session; any other message is part of the
+// session's trace. The returned context keeps the session's request ID either way.
+func startMessageSpan(session context.Context, msg mqtt.Message) (context.Context, trace.Span) {
+	options := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "mqtt"),
+			attribute.String("messaging.operation.type", "receive"),
+			attribute.String("messaging.destination.name", msg.Topic()),
+		),
+	}
+
+	parent := session
+	var carrier messageTrace
+	if err := json.Unmarshal(msg.Payload(), &carrier); err == nil && carrier.TraceParent != "" {
+		remote := otel.GetTextMapPropagator().Extract(context.Background(), propagation.MapCarrier{"traceparent": carrier.TraceParent, "tracestate": carrier.TraceState})
+		if sensor := trace.SpanContextFromContext(remote); sensor.IsValid() {
+			parent = trace.ContextWithRemoteSpanContext(session, sensor)
+			options = append(options, trace.WithLinks(trace.LinkFromContext(session)))
+		}
+	}
+	return tracer.Start(parent, "receive "+msg.Topic(), options...)
+}
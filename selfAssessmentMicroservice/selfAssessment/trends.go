@@ -0,0 +1,167 @@
+package selfAssessment
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"shared/apierror"
+	"shared/trend"
+)
+
+// SessionScoreMetric is the name of the session score trend
+const SessionScoreMetric = "Session score"
+
+// TimeMetric is the name of the trend of the time taken on a test
+func TimeMetric(testName string) string {
+	return testName + " time"
+}
+
+// AbruptMetric is the name of the trend of the abrupt movement during a test
+func AbruptMetric(testName string) string {
+	return testName + " abrupt movement"
+}
+
+// UserTrends is one senior's trend series
+type UserTrends struct {
+	UserID int            `json:"user_id"`
+	Series []trend.Series `json:"series"`
+}
+
+// trendPoints collects one senior's observations of each metric, keeping metrics in the order first seen
+type trendPoints struct {
+	metrics   []string
+	points    map[string][]trend.Point
+	unit      map[string]string
+	direction map[string]trend.Direction
+}
+
+// add records an observation of a metric
+func (t *trendPoints) add(metric, unit string, direction trend.Direction, point trend.Point) {
+	if _, seen := t.points[metric]; !seen {
+		t.metrics = append(t.metrics, metric)
+		t.unit[metric], t.direction[metric] = unit, direction
+	}
+	t.points[metric] = append(t.points[metric], point)
+}
+
+// GetSelfAssessmentTrends returns the trajectories of a senior's session score and of
+// each test's time and abrupt movement over the last days (default 180)
+func (h *Handler) GetSelfAssessmentTrends(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidField, "Invalid user_id")
+		return
+	}
+	days, ok := trendDays(w, r)
+	if !ok {
+		return
+	}
+
+	trends, err := h.selfAssessmentTrends(r.Context(), days, &userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying self-assessment trends", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Failed to fetch self-assessment trends")
+		return
+	}
+
+	result := UserTrends{UserID: userID, Series: []trend.Series{}}
+	if len(trends) > 0 {
+		result = trends[0]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// GetDecliningSelfAssessmentTrends returns every senior with a significantly worsening
+// self-assessment metric over the last days (default 180), with only the worsening series
+func (h *Handler) GetDecliningSelfAssessmentTrends(w http.ResponseWriter, r *http.Request) {
+	days, ok := trendDays(w, r)
+	if !ok {
+		return
+	}
+
+	trends, err := h.selfAssessmentTrends(r.Context(), days, nil)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying self-assessment trends", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Failed to fetch self-assessment trends")
+		return
+	}
+
+	declining := []UserTrends{}
+	for _, userTrends := range trends {
+		worsening := []trend.Series{}
+		for _, series := range userTrends.Series {
+			if series.Change != nil && series.Change.Significant && series.Change.Worsening {
+				worsening = append(worsening, series)
+			}
+		}
+		if len(worsening) > 0 {
+			declining = append(declining, UserTrends{UserID: userTrends.UserID, Series: worsening})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(declining)
+}
+
+// selfAssessmentTrends analyses the self-assessment results of one senior, or of every senior when userID is nil
+func (h *Handler) selfAssessmentTrends(ctx context.Context, days int, userID *int) ([]UserTrends, error) {
+	sessions, err := h.store.SessionScoreHistory(ctx, days, userID)
+	if err != nil {
+		return nil, err
+	}
+	tests, err := h.store.TestHistory(ctx, days, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var userIDs []int
+	byUser := map[int]*trendPoints{}
+	pointsFor := func(id int) *trendPoints {
+		if _, seen := byUser[id]; !seen {
+			userIDs = append(userIDs, id)
+			byUser[id] = &trendPoints{points: map[string][]trend.Point{}, unit: map[string]string{}, direction: map[string]trend.Direction{}}
+		}
+		return byUser[id]
+	}
+
+	// Session scores, only of sessions that have been scored
+	for _, session := range sessions {
+		pointsFor(session.UserID).add(SessionScoreMetric, "points", trend.LowerIsWorse, trend.Point{Time: session.Date, Value: session.Score})
+	}
+
+	// Time and abrupt movement of each test
+	for _, test := range tests {
+		points := pointsFor(test.UserID)
+		points.add(TimeMetric(test.TestName), "seconds", trend.HigherIsWorse, trend.Point{Time: test.Date, Value: test.TimeTaken})
+		points.add(AbruptMetric(test.TestName), "%", trend.HigherIsWorse, trend.Point{Time: test.Date, Value: test.AbruptPercentage})
+	}
+
+	trends := make([]UserTrends, 0, len(userIDs))
+	for _, id := range userIDs {
+		points := byUser[id]
+		series := make([]trend.Series, 0, len(points.metrics))
+		for _, metric := range points.metrics {
+			series = append(series, trend.Analyze(metric, points.unit[metric], points.direction[metric], points.points[metric]))
+		}
+		trends = append(trends, UserTrends{UserID: id, Series: series})
+	}
+	return trends, nil
+}
+
+// trendDays reads the days query parameter, writing an error response and returning false if it is invalid
+func trendDays(w http.ResponseWriter, r *http.Request) (int, bool) {
+	value := r.URL.Query().Get("days")
+	if value == "" {
+		return trend.DefaultDays, true
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 1 {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidField, "Invalid days")
+		return 0, false
+	}
+	return days, true
+}
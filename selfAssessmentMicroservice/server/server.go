@@ -0,0 +1,413 @@
+// Package server routes the self-assessment microservice's endpoints, so main and the integration
+// harness serve the same handlers behind the same middleware
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"selfAssessmentMicroservice/client/auth"
+	"selfAssessmentMicroservice/client/user"
+	"selfAssessmentMicroservice/config"
+	"selfAssessmentMicroservice/selfAssessment"
+
+	"shared/apierror"
+	"shared/client"
+	sharedconfig "shared/config"
+	"shared/logging"
+	"shared/observability"
+	"shared/pagination"
+	"shared/tracing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/gorilla/handlers"
+	"github.com/gorilla/mux"
+)
+
+// Authentication middleware to validate JWT
+// contextKey is used to store values on the request context
+type contextKey string
+
+// claimsContextKey holds the validated JWT claims for the permission middleware
+const claimsContextKey contextKey = "claims"
+
+// adminSessions reports whether an admin's token is still live. The authentication microservice
+// removes an admin's tokens when they are deactivated or reset.
+type adminSessions interface {
+	AdminTokenActive(ctx context.Context, token string) (bool, error)
+}
+
+// authenticator checks tokens for the routes, asking sessions about admin tokens on every request
+type authenticator struct {
+	sessions adminSessions
+}
+
+// JWT Authentication Middleware with Role Check for multiple roles
+func (a authenticator) authenticateMiddleware(allowedRoles []string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Get the Authorization header
+			authHeader := r.Header.Get("Authorization")
+			if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+				return
+			}
+
+			// Extract token
+			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+
+			// Get the JWT secret from the configuration
+			secretKey := config.Current().JWTSecret
+			if secretKey == "" {
+				slog.ErrorContext(r.Context(), "JWT_SECRET is not set")
+				apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+				return
+			}
+
+			// Parse and validate the token
+			token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+					return nil, http.ErrAbortHandler
+				}
+				return []byte(secretKey), nil
+			})
+
+			if err != nil || !token.Valid {
+				slog.WarnContext(r.Context(), "Invalid JWT token", "error", err)
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+				return
+			}
+
+			// Extract the claims from the token
+			claims, ok := token.Claims.(jwt.MapClaims)
+			if !ok {
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+				return
+			}
+
+			// Check if the role matches any of the allowed roles
+			role, ok := claims["role"].(string)
+			if !ok {
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+				return
+			}
+
+			// Check if the user's role is in the allowed roles
+			roleAllowed := false
+			for _, allowedRole := range allowedRoles {
+				if role == allowedRole {
+					roleAllowed = true
+					break
+				}
+			}
+
+			if !roleAllowed {
+				apierror.Write(w, r, http.StatusForbidden, apierror.Forbidden, "Forbidden")
+				return
+			}
+
+			// Admin tokens stop working as soon as they are revoked, rather than when they expire
+			if role == "Admin" {
+				active, err := a.sessions.AdminTokenActive(r.Context(), tokenString)
+				if err != nil {
+					client.WriteError(w, r, err)
+					return
+				}
+				if !active {
+					slog.WarnContext(r.Context(), "Admin token revoked")
+					apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+					return
+				}
+			}
+
+			// Proceed to the next handler if role matches, keeping the claims for permission checks
+			ctx := context.WithValue(r.Context(), claimsContextKey, claims)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Permission Middleware for admin tokens, must run after authenticateMiddleware.
+// Senior tokens carry no permissions and are only gated by their role.
+func requirePermission(permission string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := r.Context().Value(claimsContextKey).(jwt.MapClaims)
+			if !ok {
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+				return
+			}
+
+			if role, _ := claims["role"].(string); role != "Admin" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Check if the admin's role grants the permission
+			permissions, _ := claims["permissions"].([]interface{})
+			for _, granted := range permissions {
+				if granted == permission {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			slog.WarnContext(r.Context(), "Admin token lacks permission", "permission", permission)
+			apierror.Write(w, r, http.StatusForbidden, apierror.Forbidden, "Forbidden")
+		})
+	}
+}
+
+// Consent Middleware for senior and caregiver tokens, must run after authenticateMiddleware.
+// Seniors may only read their own data, and caregivers a senior's data if that senior granted them the scope.
+func requireSeniorConsent(userClient *user.Client, scope string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := r.Context().Value(claimsContextKey).(jwt.MapClaims)
+			if !ok {
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+				return
+			}
+
+			role, _ := claims["role"].(string)
+			if role != "User" && role != "Caregiver" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			seniorID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+			if err != nil {
+				apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "user_id is required")
+				return
+			}
+
+			// A senior's token is only good for their own data
+			tokenUserID, _ := claims["user_id"].(float64)
+			if role == "User" {
+				if int(tokenUserID) != seniorID {
+					slog.WarnContext(r.Context(), "Senior token used for another senior", "user_id", seniorID)
+					apierror.Write(w, r, http.StatusForbidden, apierror.Forbidden, "Forbidden")
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Ask the user microservice, which owns the consent records
+			granted, err := userClient.CaregiverAccess(r.Context(), int(tokenUserID), seniorID, scope)
+			if err != nil {
+				client.WriteError(w, r, err)
+				return
+			}
+			if !granted {
+				apierror.Write(w, r, http.StatusForbidden, apierror.Forbidden, "Forbidden")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// forbidOtherSenior answers 403 and reports true when a senior's token is used to write another
+// senior's results. Admin tokens are left to the routes' middleware.
+func forbidOtherSenior(w http.ResponseWriter, r *http.Request, userID int) bool {
+	claims, _ := r.Context().Value(claimsContextKey).(jwt.MapClaims)
+	if role, _ := claims["role"].(string); role != "User" {
+		return false
+	}
+
+	tokenUserID, _ := claims["user_id"].(float64)
+	if int(tokenUserID) == userID {
+		return false
+	}
+	slog.WarnContext(r.Context(), "Senior token used for another senior", "user_id", userID)
+	apierror.Write(w, r, http.StatusForbidden, apierror.Forbidden, "Forbidden")
+	return true
+}
+
+// NewRouter returns the self-assessment endpoints of Routes, with CORS for the frontend and request logging
+func NewRouter(sa *selfAssessment.Handler, userClient *user.Client, health *observability.Health) http.Handler {
+	// Add CORS support, inside the middleware that tags every request with an ID and logs it
+	return logging.Middleware(handlers.CORS(
+		handlers.AllowedOrigins(sharedconfig.AllowedOrigins()),
+		handlers.AllowedMethods([]string{"GET", "POST", "PUT", "OPTIONS"}),                                                   // Update for allowed HTTP methods
+		handlers.AllowedHeaders([]string{"Content-Type", "Authorization"}),                                                   // Include Authorization header
+		handlers.ExposedHeaders([]string{pagination.TotalCountHeader, pagination.NextCursorHeader, logging.RequestIDHeader}), // Let the frontend page through lists and report request IDs
+	)(Routes(sa, userClient, health)))
+}
+
+// Routes returns the self-assessment endpoints behind their authentication middleware, alongside the probe and
+// metrics endpoints of health. Every /api/v1 route is described in the service's OpenAPI spec, in the
+// api package, which the integration harness checks against these routes.
+func Routes(sa *selfAssessment.Handler, userClient *user.Client, health *observability.Health) *mux.Router {
+	// Initialize the router, tracing and timing every request by its route
+	router := mux.NewRouter()
+	router.Use(tracing.Middleware(sharedconfig.SelfAssessmentService), observability.Middleware)
+
+	// Unknown routes and methods get the same JSON errors as the endpoints
+	router.NotFoundHandler = apierror.NotFoundHandler()
+	router.MethodNotAllowedHandler = apierror.MethodNotAllowedHandler()
+
+	// Liveness, readiness and metrics, served without authentication
+	health.RegisterRoutes(router)
+
+	// Tokens are checked here, asking the authentication microservice about admin tokens on every request
+	authenticateMiddleware := authenticator{sessions: auth.New()}.authenticateMiddleware
+
+	// Authentication test endpoint
+	router.HandleFunc("/api/v1/selfAssessment/ws", selfAssessment.StartWebSocketServer)
+
+	// A senior's sessions, for the senior and the caregivers they shared their results with
+	router.Handle("/api/v1/selfAssessment/getUserResults", authenticateMiddleware([]string{"User", "Caregiver"})(requireSeniorConsent(userClient, "results")(http.HandlerFunc(sa.GetTestSessions)))).Methods("GET")
+
+	// Trend endpoints for the admin microservice, admins only
+	router.Handle("/api/v1/selfAssessment/trends", authenticateMiddleware([]string{"Admin"})(requirePermission("clinical:read")(http.HandlerFunc(sa.GetSelfAssessmentTrends)))).Methods("GET")
+	router.Handle("/api/v1/selfAssessment/trends/declining", authenticateMiddleware([]string{"Admin"})(requirePermission("clinical:read")(http.HandlerFunc(sa.GetDecliningSelfAssessmentTrends)))).Methods("GET")
+
+	// Each senior's latest score for the admin dashboard, admins only
+	router.Handle("/api/v1/selfAssessment/getAllLatestScore", authenticateMiddleware([]string{"Admin"})(requirePermission("clinical:read")(http.HandlerFunc(sa.GetAllLatestTotalScore)))).Methods("GET")
+
+	// Cohort analytics for the admin microservice, admins only
+	router.Handle("/api/v1/selfAssessment/analytics/riskDistribution", authenticateMiddleware([]string{"Admin"})(requirePermission("clinical:read")(http.HandlerFunc(sa.GetRiskDistribution)))).Methods("GET")
+	router.Handle("/api/v1/selfAssessment/analytics/userScoreTotals", authenticateMiddleware([]string{"Admin"})(requirePermission("clinical:read")(http.HandlerFunc(sa.GetUserScoreTotals)))).Methods("GET")
+
+	// JWT Authentication Logic
+	authenticated := router.NewRoute().Subrouter()
+	authenticated.Use(authenticateMiddleware([]string{"Admin", "User"}))
+
+	//Endpoints for Admin dashboard
+	authenticated.Handle("/api/v1/selfAssessment/getAllTotalScore", requirePermission("clinical:read")(http.HandlerFunc(sa.GetAllUserTotalScore))).Methods("GET")
+	authenticated.Handle("/api/v1/selfAssessment/getAllAvgTime", requirePermission("clinical:read")(http.HandlerFunc(sa.GetAllFATestWithAvgTime))).Methods("GET")
+	authenticated.Handle("/api/v1/selfAssessment/getAllUserRisk", requirePermission("clinical:read")(http.HandlerFunc(sa.GetUserOverallLatestRisk))).Methods("GET")
+	authenticated.Handle("/api/v1/selfAssessment/getAllLastResDay", requirePermission("seniors:read")(http.HandlerFunc(sa.GetAllFallAssesLatestResDate))).Methods("GET")
+
+	// Self-Assessment management endpoints
+	authenticated.HandleFunc("/api/v1/selfAssessment/startMQTT", func(w http.ResponseWriter, r *http.Request) {
+		go selfAssessment.StartMQTTConnection() // Start MQTT connection in a goroutine
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"message": "MQTT connection started and subscribed to topic"}`))
+	}).Methods("GET")
+
+	// Authentication test endpoint
+	authenticated.HandleFunc("/api/v1/selfAssessment/test", func(w http.ResponseWriter, r *http.Request) {
+		slog.DebugContext(r.Context(), "Starting self-assessment test")
+
+		// Call the TestReceiveMessages function
+		testStatus := selfAssessment.TestReceiveMessages()
+
+		// Respond based on the test status
+		if testStatus {
+			slog.InfoContext(r.Context(), "Successfully received raw data from FallSafe Device")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"message": "Successfully received raw data from FallSafe Device"}`))
+		} else {
+			slog.ErrorContext(r.Context(), "Failed to receive raw data from FallSafe Device within the timeout period")
+			apierror.Write(w, r, http.StatusGatewayTimeout, apierror.UpstreamTimeout, "Failed to receive raw data from FallSafe Device within the timeout period")
+		}
+	}).Methods("GET")
+
+	// Route to create a new test session
+	authenticated.HandleFunc("/api/v1/selfAssessment/startTest", func(w http.ResponseWriter, r *http.Request) {
+		// Extract user ID from query parameters
+		userIDStr := r.URL.Query().Get("userID")
+		if userIDStr == "" {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "Missing userID parameter")
+			return
+		}
+
+		// Convert userID from string to int
+		userID, err := strconv.Atoi(userIDStr)
+		if err != nil {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidField, "Invalid userID parameter, must be an integer")
+			return
+		}
+		if forbidOtherSenior(w, r, userID) {
+			return
+		}
+
+		// Call the StartTest function
+		sessionID, err := sa.StartTest(r.Context(), userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to start test session", "error", err)
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Failed to create test session")
+			return
+		}
+
+		// Respond with the session ID
+		response := map[string]interface{}{
+			"sessionID": sessionID,
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}).Methods("POST")
+
+	// Add the endpoint to get all tests
+	authenticated.HandleFunc("/api/v1/selfAssessment/getAllTests", func(w http.ResponseWriter, r *http.Request) {
+		slog.DebugContext(r.Context(), "Fetching all tests")
+
+		// Call the GetAllTests function
+		tests, err := sa.GetAllTests(r.Context())
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to fetch tests", "error", err)
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Failed to fetch tests")
+			return
+		}
+
+		// Respond with the list of tests
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tests)
+	}).Methods("GET")
+
+	// Endpoint to save user test results
+	authenticated.HandleFunc("/api/v1/selfAssessment/saveTestResult", func(w http.ResponseWriter, r *http.Request) {
+		slog.DebugContext(r.Context(), "Saving test result")
+
+		// Parse the request body
+		var requestData struct {
+			TestSessionID int     `json:"testSessionID"`
+			UserID        int     `json:"userID"`
+			TestID        int     `json:"testID"`
+			TimeTaken     float64 `json:"timeTaken"`
+			WebSocketData string  `json:"websocketData"`
+		}
+
+		err := json.NewDecoder(r.Body).Decode(&requestData)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to parse request body", "error", err)
+			apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidBody, "Invalid request body")
+			return
+		}
+		if forbidOtherSenior(w, r, requestData.UserID) {
+			return
+		}
+
+		// Call the SaveUserTestResult function with the additional TestID parameter
+		err = sa.SaveUserTestResult(
+			r.Context(),
+			requestData.TestSessionID,
+			requestData.UserID,
+			requestData.TestID,
+			requestData.TimeTaken,
+			requestData.WebSocketData,
+		)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to save test result", "error", err)
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Failed to save test result")
+			return
+		}
+
+		// Respond with success message
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"message": "Test result saved successfully"}`))
+	}).Methods("POST")
+
+	return router
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"selfAssessmentMicroservice/client/user"
+	"selfAssessmentMicroservice/config"
+	"selfAssessmentMicroservice/selfAssessment"
+	"selfAssessmentMicroservice/selfAssessment/memstore"
+
+	"shared/observability"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// testService serves the self-assessment routes from an in-memory store
+type testService struct {
+	store   *memstore.Store
+	risk    *memstore.RiskRecorder
+	sa      *selfAssessment.Handler
+	routes  http.Handler
+	testIDs []int
+}
+
+func newTestService(t *testing.T) *testService {
+	t.Helper()
+	config.Current().JWTSecret = "test-secret"
+
+	s := &testService{store: memstore.New(), risk: &memstore.RiskRecorder{}}
+	for _, name := range []string{"Timed Up and Go Test", "Five Times Sit to Stand Test", "Dynamic Gait Index (DGI)", "4 Stage Balance Test"} {
+		s.testIDs = append(s.testIDs, s.store.AddTest(name))
+	}
+	s.sa = selfAssessment.NewHandler(s.store, s.risk)
+	s.routes = Routes(s.sa, user.New(), observability.NewHealth())
+	return s
+}
+
+// serve sends a request to the routes, with a token for claims unless claims is nil
+func (s *testService) serve(t *testing.T, method, path string, claims jwt.MapClaims, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if claims != nil {
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(config.Current().JWTSecret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.routes.ServeHTTP(w, r)
+	return w
+}
+
+// senior returns the claims of a senior's token
+func senior(userID int) jwt.MapClaims {
+	return jwt.MapClaims{"user_id": userID, "role": "User"}
+}
+
+// result returns a saveTestResult body for a test of the session
+func result(sessionID, userID, testID int) string {
+	return fmt.Sprintf(`{"testSessionID":%d,"userID":%d,"testID":%d,"timeTaken":9.5,"websocketData":"{\"abrupt_percentage\": 10, \"risk_level\": \"low\"}"}`, sessionID, userID, testID)
+}
+
+func TestCompleteSession(t *testing.T) {
+	s := newTestService(t)
+
+	w := s.serve(t, "POST", "/api/v1/selfAssessment/startTest?userID=7", senior(7), "")
+	var started struct {
+		SessionID int `json:"sessionID"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &started); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("startTest = %d %s", w.Code, w.Body)
+	}
+	for _, testID := range s.testIDs {
+		if w := s.serve(t, "POST", "/api/v1/selfAssessment/saveTestResult", senior(7), result(started.SessionID, 7, testID)); w.Code != http.StatusOK {
+			t.Fatalf("saveTestResult = %d %s", w.Code, w.Body)
+		}
+	}
+
+	w = s.serve(t, "GET", "/api/v1/selfAssessment/getUserResults?user_id=7", senior(7), "")
+	var sessions []selfAssessment.TestSession
+	if err := json.Unmarshal(w.Body.Bytes(), &sessions); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("getUserResults = %d %s", w.Code, w.Body)
+	}
+	if len(sessions) != 1 || sessions[0].SessionID != started.SessionID || sessions[0].TotalScore == nil || len(sessions[0].TestResults) != len(s.testIDs) {
+		t.Fatalf("getUserResults = %+v, want the scored session %d with every test", sessions, started.SessionID)
+	}
+
+	// Only the complete session reaches the combined risk model, once the observations are drained
+	if err := s.sa.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	observations := s.risk.Observations()
+	if len(observations) != 1 {
+		t.Fatalf("risk observations = %+v, want one for the complete session", observations)
+	}
+	got := observations[0]
+	if got.UserID != 7 || got.Source != user.SourceSelfAssessment || got.SourceID != started.SessionID || int(math.Round(got.Score)) != *sessions[0].TotalScore {
+		t.Errorf("risk observation = %+v, want session %d scoring %d", got, started.SessionID, *sessions[0].TotalScore)
+	}
+}
+
+func TestSeniorTokensOnlyReachTheirOwnSessions(t *testing.T) {
+	s := newTestService(t)
+	sessionID := s.store.AddSession(8, time.Now())
+
+	tests := []struct {
+		name, method, path string
+		body               string
+	}{
+		{"start a test for another senior", "POST", "/api/v1/selfAssessment/startTest?userID=8", ""},
+		{"save a result for another senior", "POST", "/api/v1/selfAssessment/saveTestResult", result(sessionID, 8, s.testIDs[0])},
+		{"read another senior's results", "GET", "/api/v1/selfAssessment/getUserResults?user_id=8", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := s.serve(t, tt.method, tt.path, senior(7), tt.body); w.Code != http.StatusForbidden {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusForbidden)
+			}
+		})
+	}
+
+	// Nothing was saved for the other senior
+	if results, _ := s.store.SessionResults(context.Background(), sessionID); len(results) != 0 {
+		t.Errorf("session %d has %d results, want none", sessionID, len(results))
+	}
+}
+
+func TestRoutesCheckTheRole(t *testing.T) {
+	s := newTestService(t)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		claims jwt.MapClaims
+		want   int
+	}{
+		{"no token", "GET", "/api/v1/selfAssessment/getAllTests", nil, http.StatusUnauthorized},
+		{"caregiver on a senior route", "POST", "/api/v1/selfAssessment/startTest?userID=3", jwt.MapClaims{"user_id": 3, "role": "Caregiver"}, http.StatusForbidden},
+		{"senior on an admin route", "GET", "/api/v1/selfAssessment/trends", senior(7), http.StatusForbidden},
+		{"service on an admin route", "GET", "/api/v1/selfAssessment/getAllLatestScore", jwt.MapClaims{"role": "Service"}, http.StatusForbidden},
+		{"senior", "GET", "/api/v1/selfAssessment/getAllTests", senior(7), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := s.serve(t, tt.method, tt.path, tt.claims, ""); w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
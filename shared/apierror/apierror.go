@@ -0,0 +1,78 @@
+// Package apierror writes the error responses of every service in one JSON shape:
+//
+//	{"error": {"code": "not_found", "message": "Referral not found", "request_id": "4f1c..."}}
+//
+// The code is stable within /api/v1, so clients can act on it, while the message is meant for
+// people and may change. The request ID matches the X-Request-ID header and the service's logs.
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"shared/logging"
+)
+
+// Code says what went wrong, in a form clients can act on
+type Code string
+
+// Codes shared by every service. Each is used with one status code, apart from those relayed from
+// another service, which keep the status that service answered with.
+const (
+	InvalidBody         Code = "invalid_body"          // 400, the body is not the JSON the endpoint expects
+	MissingField        Code = "missing_field"         // 400, a required field or parameter is missing
+	InvalidField        Code = "invalid_field"         // 400, a field or parameter has an invalid value
+	Unauthorized        Code = "unauthorized"          // 401, no valid token was given
+	InvalidCredentials  Code = "invalid_credentials"   // 401, the email or password is wrong
+	InvalidCode         Code = "invalid_code"          // 401, a verification, login or two-factor code is wrong or expired
+	InvalidToken        Code = "invalid_token"         // 401, an invitation, magic link or login session is invalid or expired
+	Forbidden           Code = "forbidden"             // 403, the token does not allow the request
+	LoginMethodDisabled Code = "login_method_disabled" // 403, the login method is turned off
+	NotFound            Code = "not_found"             // 404, the resource or route does not exist
+	MethodNotAllowed    Code = "method_not_allowed"    // 405, the route does not accept the method
+	AlreadyExists       Code = "already_exists"        // 409, the resource already exists
+	EditConflict        Code = "edit_conflict"         // 409, the resource changed since it was read
+	InvalidTransition   Code = "invalid_transition"    // 409, the resource cannot move to the requested state
+	LastSuperAdmin      Code = "last_super_admin"      // 409, the change would leave no active super admin
+	RateLimited         Code = "rate_limited"          // 429, too many attempts, see Retry-After
+	Internal            Code = "internal_error"        // 500, the service failed
+	UpstreamFailed      Code = "upstream_failed"       // 502, a service or API this one depends on failed
+	Unavailable         Code = "unavailable"           // 503, a dependency is down, see Retry-After
+	AccountPending      Code = "account_pending"       // 503, the account is still being set up
+	UpstreamTimeout     Code = "upstream_timeout"      // 504, a service or API this one depends on timed out
+)
+
+// Body is the JSON body of every error response
+type Body struct {
+	Error Detail `json:"error"`
+}
+
+// Detail describes the error
+type Detail struct {
+	Code      Code   `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Write writes an error response for r, in place of http.Error
+func Write(w http.ResponseWriter, r *http.Request, status int, code Code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Body{Detail{Code: code, Message: message, RequestID: logging.RequestID(r.Context())}})
+}
+
+// NotFoundHandler answers requests for routes that do not exist, for mux.Router.NotFoundHandler
+func NotFoundHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Write(w, r, http.StatusNotFound, NotFound, "Not found")
+	})
+}
+
+// MethodNotAllowedHandler answers requests with a method the route does not accept, for
+// mux.Router.MethodNotAllowedHandler
+func MethodNotAllowedHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Write(w, r, http.StatusMethodNotAllowed, MethodNotAllowed, "Method not allowed")
+	})
+}
@@ -0,0 +1,80 @@
+// Package breaker holds the circuit breakers the services' clients share, one for each service
+// they call
+package breaker
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	Threshold = 5                // Consecutive failures that open the circuit
+	Cooldown  = 30 * time.Second // How long an open circuit rejects calls before letting a trial call through
+)
+
+// Breaker is a circuit breaker for one service. After Threshold consecutive failures it rejects
+// calls for Cooldown, then lets a single trial call through: success closes the circuit again and
+// failure keeps it open for another cooldown.
+type Breaker struct {
+	mu       sync.Mutex
+	failures int       // Consecutive failures since the last success
+	openedAt time.Time // When the circuit last opened
+	trial    bool      // Whether a trial call is in flight
+}
+
+var (
+	breakersMu sync.Mutex
+	breakers   = map[string]*Breaker{}
+)
+
+// For returns the breaker shared by every client of a service
+func For(service string) *Breaker {
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+
+	b, ok := breakers[service]
+	if !ok {
+		b = &Breaker{}
+		breakers[service] = b
+	}
+	return b
+}
+
+// Allow reports whether a call may be made now
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < Threshold {
+		return true
+	}
+	if b.trial || time.Since(b.openedAt) < Cooldown {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+// Record updates the breaker with the outcome of an allowed call
+func (b *Breaker) Record(ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+	if ok {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= Threshold {
+		b.openedAt = time.Now()
+	}
+}
+
+// Release ends an allowed call without counting it either way, e.g. when the caller cancelled it
+func (b *Breaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+}
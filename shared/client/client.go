@@ -0,0 +1,347 @@
+// Package client calls the other services' internal endpoints, retrying idempotent requests,
+// failing fast through a circuit breaker per service and passing the caller's token, request ID
+// and trace on. Each service wraps it in a package per service it calls.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"shared/apierror"
+	"shared/breaker"
+	"shared/config"
+	"shared/logging"
+	"shared/observability"
+	"shared/pagination"
+	"shared/tracing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	defaultTimeout   = 10 * time.Second       // Limit for a single attempt at a downstream call
+	defaultRetries   = 2                      // Extra attempts made for idempotent requests
+	retryBaseBackoff = 200 * time.Millisecond // Delay before the first retry, doubled on every retry
+	maxErrorBody     = 4096                   // Bytes of a downstream error body kept in an Error
+)
+
+// Error is returned when a downstream service answers with a non-2xx status code
+type Error struct {
+	Service    string
+	StatusCode int
+	Body       string
+	Code       apierror.Code // Code of the service's error body, empty if it sent none
+	Message    string        // Message of the service's error body, the whole body if it sent none
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s returned %d: %s", e.Service, e.StatusCode, e.Body)
+}
+
+// ErrCircuitOpen is returned without contacting a service that has been failing repeatedly
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// transportError is returned when no response was received from the service
+type transportError struct {
+	service string
+	err     error
+}
+
+func (e *transportError) Error() string {
+	return fmt.Sprintf("failed to contact %s: %v", e.service, e.err)
+}
+
+func (e *transportError) Unwrap() error {
+	return e.err
+}
+
+// Client calls one internal service. Clients for the same service share a circuit breaker.
+type Client struct {
+	Service string       // Service name, one of the service constants of shared/config
+	BaseURL string       // Base URL the request paths are appended to
+	HTTP    *http.Client // Underlying client, with a per-attempt timeout and tracing
+	Retries int          // Extra attempts made for idempotent requests
+
+	breaker *breaker.Breaker
+}
+
+// New returns a client for a service, resolving its address from config
+func New(service string) *Client {
+	return &Client{
+		Service: service,
+		BaseURL: config.ServiceURL(service),
+		HTTP:    &http.Client{Timeout: defaultTimeout, Transport: tracing.Transport(http.DefaultTransport)},
+		Retries: defaultRetries,
+		breaker: breaker.For(service),
+	}
+}
+
+type contextKey string
+
+const (
+	authorizationKey contextKey = "authorization"
+	serviceKey       contextKey = "service"
+)
+
+// serviceTokenTTL is how long a token sent by a call made AsService stays valid
+const serviceTokenTTL = time.Minute
+
+// WithAuthorization returns a context whose downstream calls forward the given Authorization header
+func WithAuthorization(ctx context.Context, authHeader string) context.Context {
+	return context.WithValue(ctx, authorizationKey, authHeader)
+}
+
+// AsService returns a context whose downstream calls authenticate as a service instead of forwarding
+// a user's Authorization header, for the internal endpoints that only other services may call
+func AsService(ctx context.Context) context.Context {
+	return context.WithValue(ctx, serviceKey, true)
+}
+
+// FromRequest returns the request's context, set up to forward its Authorization header
+func FromRequest(r *http.Request) context.Context {
+	return WithAuthorization(r.Context(), r.Header.Get("Authorization"))
+}
+
+// Get fetches path and decodes the JSON body into out.
+// GET requests are idempotent, so transient failures are retried with backoff.
+func (c *Client) Get(ctx context.Context, path string, out interface{}) error {
+	_, err := c.do(ctx, http.MethodGet, path, nil, out, true)
+	return err
+}
+
+// Post sends in as JSON and decodes the response into out, if out is not nil.
+// POST requests are not retried, as repeating them could repeat their effect.
+func (c *Client) Post(ctx context.Context, path string, in, out interface{}) error {
+	_, err := c.do(ctx, http.MethodPost, path, in, out, false)
+	return err
+}
+
+// Ping checks the service is alive, for readiness checks. It asks for liveness rather than
+// readiness, so one service's outage does not make every caller unready too, and makes a single
+// attempt that bypasses the circuit breaker, so it reports the service as it is now.
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.send(ctx, http.MethodGet, observability.LivenessPath, nil, nil)
+	return err
+}
+
+// Page is what a list endpoint says about the pages around the one returned
+type Page struct {
+	Total      int    // Number of rows matching the filters, across all pages
+	NextCursor string // Cursor of the next page, empty on the last page
+}
+
+// SetHeaders relays the page description to a response, for handlers that pass a downstream list on
+func (p Page) SetHeaders(w http.ResponseWriter) {
+	w.Header().Set(pagination.TotalCountHeader, strconv.Itoa(p.Total))
+	if p.NextCursor != "" {
+		w.Header().Set(pagination.NextCursorHeader, p.NextCursor)
+	}
+}
+
+// GetPage fetches one page of a list endpoint with the given query parameters, decoding the rows into out
+func (c *Client) GetPage(ctx context.Context, path string, params url.Values, out interface{}) (Page, error) {
+	if len(params) > 0 {
+		path += "?" + params.Encode()
+	}
+	header, err := c.do(ctx, http.MethodGet, path, nil, out, true)
+	if err != nil {
+		return Page{}, err
+	}
+	total, _ := strconv.Atoi(header.Get(pagination.TotalCountHeader))
+	return Page{Total: total, NextCursor: header.Get(pagination.NextCursorHeader)}, nil
+}
+
+// maxPageSize is the largest page the list endpoints serve, used when fetching every page
+const maxPageSize = 1000
+
+// GetAll fetches every page of a list endpoint with the given query parameters, following the cursors
+func GetAll[T any](ctx context.Context, c *Client, path string, params url.Values) ([]T, error) {
+	query := url.Values{}
+	for key, values := range params {
+		query[key] = values
+	}
+	query.Set("limit", strconv.Itoa(maxPageSize))
+	query.Del("cursor")
+
+	all := []T{}
+	for {
+		var rows []T
+		page, err := c.GetPage(ctx, path, query, &rows)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, rows...)
+		if page.NextCursor == "" {
+			return all, nil
+		}
+		query.Set("cursor", page.NextCursor)
+	}
+}
+
+// do sends the request, retrying idempotent requests while the failure is transient, and returns the response headers
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}, idempotent bool) (http.Header, error) {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return nil, fmt.Errorf("failed to marshal request to %s: %v", c.Service, err)
+		}
+	}
+
+	attempts := 1
+	if idempotent {
+		attempts += c.Retries
+	}
+
+	var header http.Header
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(retryBaseBackoff << (attempt - 1)):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		if !c.breaker.Allow() {
+			return nil, fmt.Errorf("%s: %w", c.Service, ErrCircuitOpen)
+		}
+		header, err = c.send(ctx, method, path, body, out)
+		if ctx.Err() != nil {
+			// The caller gave up, which says nothing about the service's health
+			c.breaker.Release()
+			return header, err
+		}
+		c.breaker.Record(!isServiceFailure(err))
+
+		if !isServiceFailure(err) {
+			return header, err
+		}
+	}
+	return header, err
+}
+
+// send makes a single attempt at the request, returning the response headers
+func (c *Client) send(ctx context.Context, method, path string, body []byte, out interface{}) (http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request to %s: %v", c.Service, err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if asService, _ := ctx.Value(serviceKey).(bool); asService {
+		token, err := c.serviceToken()
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign service token for %s: %v", c.Service, err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else if authHeader, _ := ctx.Value(authorizationKey).(string); authHeader != "" {
+		req.Header.Set("Authorization", authHeader)
+	}
+	if requestID := logging.RequestID(ctx); requestID != "" {
+		req.Header.Set(logging.RequestIDHeader, requestID)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, &transportError{service: c.Service, err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return resp.Header, newError(c.Service, resp.StatusCode, bytes.TrimSpace(respBody))
+	}
+
+	if out == nil {
+		return resp.Header, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return resp.Header, fmt.Errorf("failed to parse response from %s: %v", c.Service, err)
+	}
+	return resp.Header, nil
+}
+
+// serviceToken signs a short-lived token with the Service role, which services only accept on their
+// internal endpoints. Every service shares JWT_SECRET, so the callee can verify it like any other token.
+func (c *Client) serviceToken() (string, error) {
+	claims := jwt.MapClaims{
+		"role": "Service",
+		"aud":  c.Service,
+		"exp":  time.Now().Add(serviceTokenTTL).Unix(),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(config.Common().JWTSecret))
+}
+
+// newError reads the error body a service answered with, which is an apierror.Body unless the
+// request never reached the service's handlers
+func newError(service string, status int, body []byte) *Error {
+	err := &Error{Service: service, StatusCode: status, Body: string(body), Message: string(body)}
+	var envelope apierror.Body
+	if json.Unmarshal(body, &envelope) == nil && envelope.Error.Code != "" {
+		err.Code = envelope.Error.Code
+		err.Message = envelope.Error.Message
+	}
+	return err
+}
+
+// isServiceFailure reports whether an error means the service itself is unhealthy: it could not be
+// reached or answered with a 5xx. These failures are retried and counted by the circuit breaker.
+func isServiceFailure(err error) bool {
+	var statusErr *Error
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500
+	}
+	var transportErr *transportError
+	return errors.As(err, &transportErr)
+}
+
+// StatusCode returns the status a downstream service answered with, or 0 if it did not answer
+func StatusCode(err error) int {
+	var statusErr *Error
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode
+	}
+	return 0
+}
+
+// WriteError logs a failed downstream call made for r and writes the matching response. Client errors from
+// the service are relayed with their code and message; the service being down, slow or failing becomes a
+// 502, 503 or 504.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	slog.ErrorContext(r.Context(), "Error calling downstream service", "error", err)
+
+	var statusErr *Error
+	switch {
+	case errors.As(err, &statusErr) && statusErr.StatusCode < 500:
+		code := statusErr.Code
+		if code == "" {
+			code = apierror.UpstreamFailed
+		}
+		apierror.Write(w, r, statusErr.StatusCode, code, statusErr.Message)
+	case errors.Is(err, ErrCircuitOpen):
+		w.Header().Set("Retry-After", strconv.Itoa(int(breaker.Cooldown.Seconds())))
+		apierror.Write(w, r, http.StatusServiceUnavailable, apierror.Unavailable, "Service temporarily unavailable")
+	case errors.Is(err, context.DeadlineExceeded) || isTimeout(err):
+		apierror.Write(w, r, http.StatusGatewayTimeout, apierror.UpstreamTimeout, "Microservice timed out")
+	default:
+		apierror.Write(w, r, http.StatusBadGateway, apierror.UpstreamFailed, "Failed to contact microservice")
+	}
+}
+
+// isTimeout reports whether the error is a network timeout
+func isTimeout(err error) bool {
+	var timeoutErr interface{ Timeout() bool }
+	return errors.As(err, &timeoutErr) && timeoutErr.Timeout()
+}
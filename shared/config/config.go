@@ -0,0 +1,199 @@
+// Package config finds the other services and keeps each service's configuration. A service's
+// own config package declares its Config, embedding settings.Common, and reads it through a Loader,
+// which also makes its common settings the ones the shared code reads.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"shared/settings"
+)
+
+// Service names, matching the k8s Service names in k8s/services.yaml
+const (
+	AdminService          = "admin-service"
+	AuthService           = "auth-service"
+	FallsEfficacyService  = "fallsefficacy-service"
+	OpenAIService         = "openai-service"
+	SelfAssessmentService = "selfassessment-service"
+	UserService           = "user-service"
+	FrontendService       = "frontend-service"
+	GatewayService        = "gateway-service"
+)
+
+// servicePorts are the ports each service listens on, used for the in-cluster default URLs
+var servicePorts = map[string]int{
+	AdminService:          5200,
+	AuthService:           5050,
+	FallsEfficacyService:  5300,
+	OpenAIService:         5150,
+	SelfAssessmentService: 5250,
+	UserService:           5100,
+	FrontendService:       80,
+	GatewayService:        5000,
+}
+
+// fileConfig is the layout of the optional JSON file named by SERVICES_CONFIG
+type fileConfig struct {
+	Services       map[string]string `json:"services"`        // Service name to base URL
+	AllowedOrigins []string          `json:"allowed_origins"` // Browser origins allowed by CORS
+	FrontendURL    string            `json:"frontend_url"`    // Public address of the frontend
+}
+
+var (
+	commonMu sync.Mutex
+	common   *settings.Common
+
+	fileOnce sync.Once
+	file     fileConfig
+)
+
+// Common returns the settings every service reads, of the configuration the service loaded. Code
+// shared by the services, such as the internal client, reads them here rather than from a Config.
+// Without a Loader they are read from the environment and the config file on first use.
+func Common() *settings.Common {
+	commonMu.Lock()
+	defer commonMu.Unlock()
+	if common == nil {
+		common = &settings.Common{}
+		settings.Parse(common, common, nil)
+	}
+	return common
+}
+
+// setCommon makes the settings of a configuration loaded the ones Common returns
+func setCommon(c *settings.Common, replace bool) {
+	commonMu.Lock()
+	defer commonMu.Unlock()
+	if replace || common == nil {
+		common = c
+	}
+}
+
+// Configuration is a pointer to a service's Config, which embeds settings.Common
+type Configuration[C any] interface {
+	*C
+	CommonSettings() *settings.Common
+}
+
+// Loader reads a service's Config and keeps the one read. The zero value is ready to use.
+type Loader[C any, P Configuration[C]] struct {
+	mu      sync.Mutex
+	current P
+}
+
+// Load reads the configuration from the command-line arguments, the environment, secret files and
+// the config file, and makes it the one Current returns. It reports every missing or invalid
+// setting at once, alongside the configuration read.
+func (l *Loader[C, P]) Load(args []string) (P, error) {
+	cfg := P(new(C))
+	problems := settings.Parse(cfg, cfg.CommonSettings(), args)
+
+	l.mu.Lock()
+	l.current = cfg
+	l.mu.Unlock()
+	setCommon(cfg.CommonSettings(), true)
+
+	if len(problems) > 0 {
+		return cfg, &settings.Error{Problems: problems}
+	}
+	return cfg, nil
+}
+
+// Current returns the configuration Load read. Programs that do not call Load, such as the
+// integration harness, get the configuration read from the environment and the config file on first
+// use, without validation.
+func (l *Loader[C, P]) Current() P {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.current == nil {
+		l.current = P(new(C))
+		settings.Parse(l.current, l.current.CommonSettings(), nil)
+		setCommon(l.current.CommonSettings(), false)
+	}
+	return l.current
+}
+
+// load reads the SERVICES_CONFIG file once; a missing or invalid file is fatal so
+// a misconfigured deployment fails at startup rather than calling the wrong hosts
+func load() {
+	fileOnce.Do(func() {
+		path := Common().ServicesConfig
+		if path == "" {
+			return
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			slog.Error("Error reading services config", "path", path, "error", err)
+			os.Exit(1)
+		}
+		if err := json.Unmarshal(data, &file); err != nil {
+			slog.Error("Error parsing services config", "path", path, "error", err)
+			os.Exit(1)
+		}
+		slog.Info("Loaded services config", "path", path)
+	})
+}
+
+// ServiceURL returns the base URL of a service, without a trailing slash. It is resolved from
+// the <NAME>_URL variable (e.g. USER_SERVICE_URL) in the environment or the config file, then the
+// SERVICES_CONFIG file, and otherwise defaults to the service's in-cluster k8s DNS name.
+func ServiceURL(name string) string {
+	if url := Common().Lookup(envName(name) + "_URL"); url != "" {
+		return strings.TrimRight(url, "/")
+	}
+
+	load()
+	if url, ok := file.Services[name]; ok && url != "" {
+		return strings.TrimRight(url, "/")
+	}
+
+	port, ok := servicePorts[name]
+	if !ok {
+		slog.Error("Unknown service", "service_name", name)
+		os.Exit(1)
+	}
+	return fmt.Sprintf("http://%s:%d", name, port)
+}
+
+// AllowedOrigins returns the browser origins allowed by CORS, from the ALLOWED_ORIGINS setting
+// or the SERVICES_CONFIG file, defaulting to the frontend
+func AllowedOrigins() []string {
+	if origins := Common().AllowedOrigins; len(origins) > 0 {
+		allowed := make([]string, len(origins))
+		for i, origin := range origins {
+			allowed[i] = strings.TrimRight(origin, "/")
+		}
+		return allowed
+	}
+
+	load()
+	if len(file.AllowedOrigins) > 0 {
+		return file.AllowedOrigins
+	}
+	return []string{FrontendURL()}
+}
+
+// FrontendURL returns the public address of the frontend, used in links sent by email
+func FrontendURL() string {
+	if url := Common().FrontendURL; url != "" {
+		return strings.TrimRight(url, "/")
+	}
+
+	load()
+	if file.FrontendURL != "" {
+		return strings.TrimRight(file.FrontendURL, "/")
+	}
+	return ServiceURL(FrontendService)
+}
+
+// envName converts a service name such as "user-service" to "USER_SERVICE"
+func envName(name string) string {
+	return strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
@@ -0,0 +1,62 @@
+// Package dbtest gives tests and benchmarks that need a real MySQL server a database of their own,
+// so they never read or change a service's data.
+package dbtest
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"io/fs"
+	"os"
+	"testing"
+
+	"shared/migrate"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Database creates a database with a random name on the MySQL server named by the DSN in the
+// environment variable env, applies the migrations to it and returns its DSN. The database is
+// dropped when tb finishes.
+//
+// tb is skipped when env is not set: the service's own DB_CONNECTION is never used, so a server is
+// only touched when one is given for testing.
+func Database(tb testing.TB, env string, migrations fs.FS) string {
+	tb.Helper()
+	dsn := os.Getenv(env)
+	if dsn == "" {
+		tb.Skipf("set %s to the DSN of a MySQL server this may create databases on", env)
+	}
+	config, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		tb.Fatalf("invalid %s: %v", env, err)
+	}
+
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		tb.Fatal(err)
+	}
+	name := "dbtest_" + hex.EncodeToString(suffix)
+
+	config.DBName = ""
+	server, err := sql.Open("mysql", config.FormatDSN())
+	if err != nil {
+		tb.Fatalf("failed to connect to %s: %v", env, err)
+	}
+	if _, err := server.Exec("CREATE DATABASE " + name); err != nil {
+		server.Close()
+		tb.Fatalf("failed to create database %s: %v", name, err)
+	}
+	tb.Cleanup(func() {
+		if _, err := server.Exec("DROP DATABASE " + name); err != nil {
+			tb.Errorf("failed to drop database %s: %v", name, err)
+		}
+		server.Close()
+	})
+
+	config.DBName = name
+	if err := migrate.Command(config.FormatDSN(), migrations, []string{"up"}); err != nil {
+		tb.Fatalf("failed to migrate database %s: %v", name, err)
+	}
+	return config.FormatDSN()
+}
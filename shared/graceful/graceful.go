@@ -0,0 +1,112 @@
+// Package graceful serves a service's router until k8s stops the pod, then drains the requests in
+// flight and releases the service's resources within the grace period.
+package graceful
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const (
+	readHeaderTimeout = 5 * time.Second   // Limit for a client to send the request headers
+	readTimeout       = 30 * time.Second  // Limit for a client to send the whole request
+	writeTimeout      = 90 * time.Second  // Limit for a response, above the OpenAI calls some endpoints wait on
+	idleTimeout       = 120 * time.Second // Limit for a kept-alive connection to sit unused
+	shutdownTimeout   = 25 * time.Second  // Limit for shutting down, within the grace period k8s allows after SIGTERM
+)
+
+// Closer releases a resource once the server has stopped, giving up when the context ends
+type Closer func(ctx context.Context) error
+
+// CloseFunc makes a Closer of a Close method without a context, which is waited for until the context ends
+func CloseFunc(closeMethod func() error) Closer {
+	return func(ctx context.Context) error {
+		done := make(chan error, 1)
+		go func() { done <- closeMethod() }()
+		select {
+		case err := <-done:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// newServer returns a server for handler with the timeouts every service uses
+func newServer(handler http.Handler) *http.Server {
+	return &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+}
+
+// Serve serves handler on addr until the process receives SIGTERM or SIGINT. It then stops
+// accepting connections, waits for the requests in flight and runs the closers in order, all
+// within shutdownTimeout. A second signal stops the process at once.
+func Serve(addr string, handler http.Handler, closers ...Closer) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	signals, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	return run(signals, stop, listener, newServer(handler), shutdownTimeout, closers)
+}
+
+// run serves on listener until stopping ends, then shuts down within timeout. release is called
+// once stopping has ended, so that a second signal stops the process.
+func run(stopping context.Context, release func(), listener net.Listener, server *http.Server, timeout time.Duration, closers []Closer) error {
+	failed := make(chan error, 1)
+	go func() { failed <- server.Serve(listener) }()
+	select {
+	case err := <-failed:
+		return err
+	case <-stopping.Done():
+	}
+	release()
+
+	slog.Info("Shutting down", "timeout", timeout.String())
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var errs []error
+	if err := server.Shutdown(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	for _, closer := range closers {
+		if err := closer(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	slog.Info("Shut down")
+	return nil
+}
+
+// ServeInBackground serves handler on addr alongside Serve, such as metrics on a port of their own,
+// and returns a Closer that shuts it down for Serve to run. The process exits if it cannot listen.
+func ServeInBackground(addr string, handler http.Handler) Closer {
+	server := newServer(handler)
+	server.Addr = addr
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Server stopped", "addr", addr, "error", err)
+			os.Exit(1)
+		}
+	}()
+	return server.Shutdown
+}
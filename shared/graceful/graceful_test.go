@@ -0,0 +1,123 @@
+package graceful
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// start runs a server for handler until the returned cancel is called, sending run's result on done
+func start(t *testing.T, handler http.Handler, timeout time.Duration, closers ...Closer) (url string, stop context.CancelFunc, done <-chan error) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stopping, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() { result <- run(stopping, func() {}, listener, newServer(handler), timeout, closers) }()
+	return "http://" + listener.Addr().String(), cancel, result
+}
+
+func TestRunDrainsRequestsThenCloses(t *testing.T) {
+	started := make(chan struct{})
+	var finished atomic.Bool
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		io.WriteString(w, "done")
+		finished.Store(true)
+	})
+
+	// The closers run in order, once the request has been answered
+	var order []string
+	closer := func(name string) Closer {
+		return func(ctx context.Context) error {
+			if !finished.Load() {
+				t.Errorf("%s ran before the request in flight finished", name)
+			}
+			order = append(order, name)
+			return nil
+		}
+	}
+	url, stop, done := start(t, handler, shutdownTimeout, closer("outbox"), CloseFunc(func() error { return closer("database")(context.Background()) }))
+
+	answered := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			answered <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		answered <- string(body)
+	}()
+	<-started
+	began := time.Now()
+	stop()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("shutting down failed: %v", err)
+		}
+	case <-time.After(shutdownTimeout):
+		t.Fatalf("did not shut down within %s", shutdownTimeout)
+	}
+	if took := time.Since(began); took > shutdownTimeout {
+		t.Errorf("shutting down took %s, above %s", took, shutdownTimeout)
+	}
+	if body := <-answered; body != "done" {
+		t.Errorf("the request in flight got %q", body)
+	}
+	if len(order) != 2 || order[0] != "outbox" || order[1] != "database" {
+		t.Errorf("closers ran as %v", order)
+	}
+
+	// No connections are accepted once shut down
+	if _, err := http.Get(url); err == nil {
+		t.Error("the server still accepts connections")
+	}
+}
+
+func TestRunGivesUpAfterTimeout(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+
+	// A closer waiting on a Close method without a context gives up with the rest
+	var closed atomic.Bool
+	slowClose := CloseFunc(func() error {
+		<-release
+		closed.Store(true)
+		return nil
+	})
+	timeout := 100 * time.Millisecond
+	url, stop, done := start(t, handler, timeout, slowClose)
+
+	go http.Get(url)
+	<-started
+	stop()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("shutting down past the timeout returned %v", err)
+		}
+	case <-time.After(10 * timeout):
+		t.Fatalf("did not give up within %s", timeout)
+	}
+	if closed.Load() {
+		t.Error("the slow closer was waited for")
+	}
+}
@@ -0,0 +1,167 @@
+// Package logging writes the service's logs as JSON through log/slog. Every entry written for a
+// request carries the request's ID, which the services pass to each other in the X-Request-ID
+// header, and the trace and span it was written in, and credentials and personal details are
+// redacted before they are written.
+package logging
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries the ID of a request from the service that received it to the services it calls
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds the IDs accepted from callers, which are written to every log entry
+const maxRequestIDLength = 128
+
+// Setup makes a JSON logger writing to stdout the default for slog and for the log package,
+// tagging every entry with the service name. logLevel is the lowest level written: debug,
+// info (the default), warn or error.
+func Setup(service, logLevel string) {
+	level := new(slog.LevelVar)
+	if err := level.UnmarshalText([]byte(logLevel)); err != nil {
+		level.Set(slog.LevelInfo)
+	}
+	slog.SetDefault(slog.New(NewHandler(os.Stdout, level)).With("service", service))
+}
+
+// NewHandler returns a handler writing JSON entries to w at the given level or above, with the
+// request ID and span of the entry's context and sensitive values redacted
+func NewHandler(w io.Writer, level slog.Leveler) slog.Handler {
+	return &requestHandler{slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level, ReplaceAttr: redactAttr})}
+}
+
+// requestHandler adds the request ID and the span of the context an entry is logged with
+type requestHandler struct {
+	slog.Handler
+}
+
+func (h *requestHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		record.AddAttrs(slog.String("trace_id", span.TraceID().String()), slog.String("span_id", span.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *requestHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &requestHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h *requestHandler) WithGroup(name string) slog.Handler {
+	return &requestHandler{h.Handler.WithGroup(name)}
+}
+
+type contextKey string
+
+const requestIDKey contextKey = "request_id"
+
+// WithRequestID returns a context whose log entries and downstream calls carry the request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID returns the request ID the context carries, or "" outside a request
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// quietPaths are polled every few seconds by the k8s probes and Prometheus, so they are only
+// logged at debug level unless they fail
+var quietPaths = map[string]bool{"/healthz": true, "/readyz": true, "/metrics": true}
+
+// Middleware gives each request an ID, keeping the one the calling service sent in
+// X-Request-ID or generating one, returns it in the response header and logs the request
+// once it has been served
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		ctx := WithRequestID(r.Context(), id)
+		w.Header().Set(RequestIDHeader, id)
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		level := slog.LevelInfo
+		switch {
+		case recorder.status >= 500:
+			level = slog.LevelError
+		case recorder.status >= 400:
+			level = slog.LevelWarn
+		case quietPaths[r.URL.Path]:
+			level = slog.LevelDebug
+		}
+		// Only the path is logged, as query strings can hold tokens and email addresses
+		slog.Log(ctx, level, "Request served",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", recorder.status,
+			"duration_ms", time.Since(start).Milliseconds(),
+		)
+	})
+}
+
+// validRequestID reports whether a caller's request ID is safe to log and pass on
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random request ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// statusRecorder keeps the status code written to a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Hijack hands the connection over for WebSocket upgrades
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response does not support hijacking")
+	}
+	r.status = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
+
+// Unwrap lets http.ResponseController reach the underlying response
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,133 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// redactedValue replaces sensitive values in log entries
+const redactedValue = "[REDACTED]"
+
+// Attribute and field names, lowercased without separators, whose values are never logged
+var (
+	sensitiveNames = map[string]bool{
+		"authorization": true, "cookie": true, "jwt": true, "code": true, "otp": true,
+		"name": true, "fullname": true, "username": true, "seniorname": true, "adminname": true, "caregivername": true,
+	}
+	sensitiveSuffixes = []string{
+		"password", "passwd", "secret", "token", "apikey", "verificationcode", "logincode", "totpcode",
+		"email", "phone", "phonenumber", "address", "nric",
+	}
+)
+
+// Credentials and personal details that are redacted wherever they appear in text
+var (
+	jwtPattern        = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	bearerPattern     = regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/=-]+`)
+	apiKeyPattern     = regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{8,}`)
+	emailPattern      = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	assignmentPattern = regexp.MustCompile(`(?i)("?(?:password|passwd|secret|token|verification_?code|login_?code|otp)"?\s*[:=]\s*)("[^"]*"|[^\s,}&]+)`)
+)
+
+// isBuiltinKey reports whether an attribute is one slog adds itself, other than the message
+func isBuiltinKey(groups []string, key string) bool {
+	return len(groups) == 0 && (key == slog.TimeKey || key == slog.LevelKey || key == slog.SourceKey)
+}
+
+// redactAttr redacts attributes named after credentials or personal details, and credentials
+// and email addresses written into messages, errors and logged structs
+func redactAttr(groups []string, attr slog.Attr) slog.Attr {
+	if isBuiltinKey(groups, attr.Key) {
+		return attr
+	}
+	if sensitiveKey(attr.Key) {
+		return slog.String(attr.Key, redactedValue)
+	}
+
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, redactText(attr.Value.String()))
+	case slog.KindAny:
+		return slog.Any(attr.Key, redactAny(attr.Value.Any()))
+	}
+	return attr
+}
+
+// sensitiveKey reports whether an attribute or field name holds a credential or a personal detail
+func sensitiveKey(key string) bool {
+	normalized := strings.NewReplacer("_", "", "-", "", ".", "", " ", "").Replace(strings.ToLower(key))
+	if sensitiveNames[normalized] {
+		return true
+	}
+	for _, suffix := range sensitiveSuffixes {
+		if strings.HasSuffix(normalized, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactText removes tokens, API keys, email addresses and assigned credentials from free text
+func redactText(text string) string {
+	text = jwtPattern.ReplaceAllString(text, redactedValue)
+	text = bearerPattern.ReplaceAllString(text, "Bearer "+redactedValue)
+	text = apiKeyPattern.ReplaceAllString(text, redactedValue)
+	text = emailPattern.ReplaceAllString(text, redactedValue)
+	return assignmentPattern.ReplaceAllStringFunc(text, func(assignment string) string {
+		parts := assignmentPattern.FindStringSubmatch(assignment)
+		if strings.HasPrefix(parts[2], `"`) {
+			return parts[1] + `"` + redactedValue + `"`
+		}
+		return parts[1] + redactedValue
+	})
+}
+
+// redactAny redacts a logged value. Errors are redacted as text; structs, maps and slices are
+// logged as their JSON with the sensitive fields redacted.
+func redactAny(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case error:
+		return redactText(v.Error())
+	case fmt.Stringer:
+		return redactText(v.String())
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return redactText(fmt.Sprintf("%+v", value))
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return redactText(string(data))
+	}
+	return redactJSON(decoded)
+}
+
+// redactJSON redacts the sensitive fields of a decoded JSON value
+func redactJSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if sensitiveKey(key) {
+				v[key] = redactedValue
+			} else {
+				v[key] = redactJSON(field)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactJSON(item)
+		}
+	case string:
+		return redactText(v)
+	}
+	return value
+}
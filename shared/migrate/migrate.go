@@ -0,0 +1,449 @@
+// Package migrate applies the versioned schema migrations a service embeds to its database, and
+// records the applied versions in the schema_migrations table of that database.
+//
+// A migration is a pair of files named <version>_<name>.up.sql and <version>_<name>.down.sql, where
+// version is a positive number such as 0001. Migrations are applied in version order and never edited
+// once released: a schema change is a new migration. Each service applies its migrations when it
+// starts, unless MIGRATE_ON_START is false, and through its migrate subcommand:
+//
+//	go run . migrate up               apply every pending migration
+//	go run . migrate down [n]         revert the last n migrations, 1 by default
+//	go run . migrate status           list the migrations and whether they are applied
+//	go run . migrate baseline <v>     record migrations up to v as applied, for databases created before migrations
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+const (
+	versionTable = "schema_migrations"
+	lockTimeout  = 60 // Seconds to wait for another replica to finish migrating
+)
+
+// Migration is one versioned schema change
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string // Empty if the migration cannot be reverted
+}
+
+// String returns the file name of the migration without its suffix, e.g. 0001_create_tables
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// Load reads the migrations in the root of files, in version order
+func Load(files fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		fileName := entry.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(fileName, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(fileName, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		prefix, name, ok := strings.Cut(strings.TrimSuffix(fileName, "."+direction+".sql"), "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || version < 1 {
+			return nil, fmt.Errorf("migration %s is not named <version>_<name>.%s.sql", fileName, direction)
+		}
+		content, err := fs.ReadFile(files, fileName)
+		if err != nil {
+			return nil, err
+		}
+
+		migration, exists := byVersion[version]
+		if !exists {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		} else if migration.Name != name {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", migration, fileName, version)
+		}
+		if direction == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %s has no up file", migration)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// OnStart applies the pending migrations when a service starts, unless the MIGRATE_ON_START
+// setting, enabled, is false
+func OnStart(dsn string, files fs.FS, enabled bool) error {
+	if !enabled {
+		slog.Info("Skipping schema migrations, MIGRATE_ON_START is false")
+		return nil
+	}
+	return Command(dsn, files, []string{"up"})
+}
+
+// Command runs the migrate subcommand of a service with the arguments that follow it
+func Command(dsn string, files fs.FS, args []string) error {
+	migrations, err := Load(files)
+	if err != nil {
+		return err
+	}
+
+	command := "up"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	conn, err := lock(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer unlock(conn)
+
+	switch command {
+	case "up":
+		return Up(ctx, conn, migrations)
+	case "down":
+		steps := 1
+		if len(args) > 0 {
+			if steps, err = strconv.Atoi(args[0]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of migrations to revert: %s", args[0])
+			}
+		}
+		return Down(ctx, conn, migrations, steps)
+	case "status":
+		return Status(ctx, conn, migrations, os.Stdout)
+	case "baseline":
+		if len(args) == 0 {
+			return errors.New("usage: migrate baseline <version>")
+		}
+		version, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid version: %s", args[0])
+		}
+		return Baseline(ctx, conn, migrations, version)
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down, status or baseline", command)
+	}
+}
+
+// lock takes a connection holding the migration lock of the database, so replicas starting
+// together apply each migration once. The lock is released when the connection is.
+func lock(ctx context.Context, db *sql.DB) (*sql.Conn, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %v", err)
+	}
+
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(CONCAT(DATABASE(), '.', ?), ?)", versionTable, lockTimeout).Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to take the migration lock: %v", err)
+	}
+	if acquired.Int64 != 1 {
+		conn.Close()
+		return nil, fmt.Errorf("timed out after %d seconds waiting for another migration to finish", lockTimeout)
+	}
+
+	if _, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS `+versionTable+` (
+			version INT UNSIGNED NOT NULL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`); err != nil {
+		unlock(conn)
+		return nil, fmt.Errorf("failed to create %s: %v", versionTable, err)
+	}
+	return conn, nil
+}
+
+// unlock releases the migration lock and the connection holding it
+func unlock(conn *sql.Conn) {
+	var released sql.NullInt64
+	if err := conn.QueryRowContext(context.Background(), "SELECT RELEASE_LOCK(CONCAT(DATABASE(), '.', ?))", versionTable).Scan(&released); err != nil {
+		slog.Error("Error releasing migration lock", "error", err)
+	}
+	conn.Close()
+}
+
+// applied returns the applied versions with the time each was applied
+func applied(ctx context.Context, conn *sql.Conn) (map[int]string, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, CAST(applied_at AS CHAR) FROM "+versionTable)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", versionTable, err)
+	}
+	defer rows.Close()
+
+	versions := map[int]string{}
+	for rows.Next() {
+		var version int
+		var appliedAt sql.NullString
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		versions[version] = appliedAt.String
+	}
+	return versions, rows.Err()
+}
+
+// Up applies every pending migration in version order
+func Up(ctx context.Context, conn *sql.Conn, migrations []Migration) error {
+	versions, err := applied(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		if err := requireEmpty(ctx, conn); err != nil {
+			return err
+		}
+	}
+	warnUnknown(versions, migrations)
+
+	pending := 0
+	for _, migration := range migrations {
+		if _, done := versions[migration.Version]; done {
+			continue
+		}
+		start := time.Now()
+		if err := run(ctx, conn, migration.Up, "INSERT INTO "+versionTable+" (version, name) VALUES (?, ?)", migration.Version, migration.Name); err != nil {
+			return fmt.Errorf("migration %s failed: %v", migration, err)
+		}
+		slog.InfoContext(ctx, "Applied migration", "migration", migration, "duration_ms", time.Since(start).Milliseconds())
+		pending++
+	}
+	if pending == 0 {
+		slog.InfoContext(ctx, "Schema is up to date", "version", latest(versions))
+	}
+	return nil
+}
+
+// Down reverts the last steps applied migrations, newest first
+func Down(ctx context.Context, conn *sql.Conn, migrations []Migration, steps int) error {
+	versions, err := applied(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+		migration := migrations[i]
+		if _, done := versions[migration.Version]; !done {
+			continue
+		}
+		if migration.Down == "" {
+			return fmt.Errorf("migration %s cannot be reverted, it has no down file", migration)
+		}
+		if err := run(ctx, conn, migration.Down, "DELETE FROM "+versionTable+" WHERE version = ?", migration.Version); err != nil {
+			return fmt.Errorf("reverting migration %s failed: %v", migration, err)
+		}
+		slog.InfoContext(ctx, "Reverted migration", "migration", migration)
+		steps--
+	}
+	return nil
+}
+
+// Status writes each migration and when it was applied
+func Status(ctx context.Context, conn *sql.Conn, migrations []Migration, w io.Writer) error {
+	versions, err := applied(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range migrations {
+		appliedAt, done := versions[migration.Version]
+		if !done {
+			appliedAt = "pending"
+		}
+		fmt.Fprintf(w, "%-40s %s\n", migration, appliedAt)
+	}
+	warnUnknown(versions, migrations)
+	return nil
+}
+
+// Baseline records the migrations up to version as applied without running them. It is run once
+// on a database created before migrations existed, whose schema already matches that version.
+func Baseline(ctx context.Context, conn *sql.Conn, migrations []Migration, version int) error {
+	versions, err := applied(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if len(versions) > 0 {
+		return fmt.Errorf("database is already at version %d, baseline is only for databases without migrations", latest(versions))
+	}
+
+	for _, migration := range migrations {
+		if migration.Version > version {
+			break
+		}
+		if _, err := conn.ExecContext(ctx, "INSERT INTO "+versionTable+" (version, name) VALUES (?, ?)", migration.Version, migration.Name); err != nil {
+			return fmt.Errorf("failed to record migration %s: %v", migration, err)
+		}
+		slog.InfoContext(ctx, "Recorded migration as applied", "migration", migration)
+	}
+	return nil
+}
+
+// requireEmpty refuses to migrate a database that has tables but no migration history, which
+// would otherwise fail halfway through the first migration
+func requireEmpty(ctx context.Context, conn *sql.Conn) error {
+	var tables int
+	if err := conn.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM information_schema.tables
+		WHERE table_schema = DATABASE() AND table_name <> ?`, versionTable).Scan(&tables); err != nil {
+		return fmt.Errorf("failed to list tables: %v", err)
+	}
+	if tables > 0 {
+		return fmt.Errorf("database has %d tables but no migration history, run 'migrate baseline <version>' with the version its schema matches", tables)
+	}
+	return nil
+}
+
+// run executes the statements of a migration and the statement recording it in one transaction.
+// MySQL commits schema changes as they run, so a failed schema migration may be partly applied
+// and has to be repaired by hand; data changes are rolled back.
+func run(ctx context.Context, conn *sql.Conn, script, record string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range Split(script) {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// warnUnknown logs applied versions this build has no migration for, as when a newer release has
+// migrated the database and an older one is still running
+func warnUnknown(versions map[int]string, migrations []Migration) {
+	known := map[int]bool{}
+	for _, migration := range migrations {
+		known[migration.Version] = true
+	}
+	for version := range versions {
+		if !known[version] {
+			slog.Warn("Database has a migration applied which this build does not know", "version", version)
+		}
+	}
+}
+
+// latest returns the highest applied version, 0 if there is none
+func latest(versions map[int]string) int {
+	highest := 0
+	for version := range versions {
+		if version > highest {
+			highest = version
+		}
+	}
+	return highest
+}
+
+// Split separates a script into its statements, on semicolons outside quotes and comments.
+// Comments are dropped, as the driver sends one statement at a time.
+func Split(script string) []string {
+	var statements []string
+	var current strings.Builder
+
+	flush := func() {
+		if statement := strings.TrimSpace(current.String()); statement != "" {
+			statements = append(statements, statement)
+		}
+		current.Reset()
+	}
+
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			end := closingQuote(script, i)
+			current.WriteString(script[i:end])
+			i = end - 1
+		case c == '#' || (c == '-' && strings.HasPrefix(script[i:], "--") && (i+2 == len(script) || isSpace(script[i+2]))):
+			for i < len(script) && script[i] != '\n' {
+				i++
+			}
+			current.WriteByte('\n')
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				i = len(script)
+			} else {
+				i += end + 3
+			}
+			current.WriteByte(' ')
+		case c == ';':
+			flush()
+		default:
+			current.WriteByte(c)
+		}
+	}
+	flush()
+	return statements
+}
+
+// closingQuote returns the index just past the quoted string starting at start, allowing for
+// backslash escapes and doubled quotes
+func closingQuote(script string, start int) int {
+	quote := script[start]
+	for i := start + 1; i < len(script); i++ {
+		switch script[i] {
+		case '\\':
+			if quote != '`' {
+				i++
+			}
+		case quote:
+			if i+1 < len(script) && script[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(script)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
@@ -0,0 +1,45 @@
+package migrate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{"empty", "", nil},
+		{"only whitespace and semicolons", " ;\n;\t; ", nil},
+		{"one statement without a semicolon", "SELECT 1", []string{"SELECT 1"}},
+		{"statements on one line", "SELECT 1; SELECT 2;", []string{"SELECT 1", "SELECT 2"}},
+		{"blank trailing statements", "SELECT 1;\n\n;  ;\n", []string{"SELECT 1"}},
+
+		// Semicolons inside quotes do not end the statement
+		{"single quotes", "INSERT INTO t VALUES ('a;b'); SELECT 2", []string{"INSERT INTO t VALUES ('a;b')", "SELECT 2"}},
+		{"double quotes", `INSERT INTO t VALUES ("a;b");`, []string{`INSERT INTO t VALUES ("a;b")`}},
+		{"backticks", "CREATE TABLE `a;b` (id INT);", []string{"CREATE TABLE `a;b` (id INT)"}},
+		{"doubled quote", "SELECT 'it''s; fine'; SELECT 2", []string{"SELECT 'it''s; fine'", "SELECT 2"}},
+		{"backslash escape", `SELECT 'it\'s; fine'; SELECT 2`, []string{`SELECT 'it\'s; fine'`, "SELECT 2"}},
+		{"comment markers inside quotes", "SELECT '-- not; a comment', '#; nor this', '/* nor; this */';", []string{"SELECT '-- not; a comment', '#; nor this', '/* nor; this */'"}},
+		{"unterminated quote", "SELECT 'a;b", []string{"SELECT 'a;b"}},
+
+		// Comments are dropped, with the semicolons in them
+		{"dash comment", "-- first; statement\nSELECT 1;", []string{"SELECT 1"}},
+		{"hash comment", "SELECT 1; # trailing; comment\nSELECT 2", []string{"SELECT 1", "SELECT 2"}},
+		{"comment at the end of a line", "CREATE TABLE t (\n  id INT -- the key; unique\n);", []string{"CREATE TABLE t (\n  id INT \n)"}},
+		{"block comment", "SELECT /* one; */ 1; /* only a comment; */", []string{"SELECT   1"}},
+		{"unterminated block comment", "SELECT 1; /* never; closed", []string{"SELECT 1"}},
+		{"double dash without a space", "SELECT 1--1;", []string{"SELECT 1--1"}},
+		{"dash comment at the end", "SELECT 1; --", []string{"SELECT 1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Split(tt.script); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Split(%q) = %q, want %q", tt.script, got, tt.want)
+			}
+		})
+	}
+}
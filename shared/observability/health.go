@@ -0,0 +1,139 @@
+// Package observability exposes the service's liveness and readiness for the k8s probes and
+// its Prometheus metrics: request latency by route and database query timings, alongside
+// whatever metrics the service's own packages register.
+package observability
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Paths of the probe and scrape endpoints every service serves
+const (
+	LivenessPath  = "/healthz"
+	ReadinessPath = "/readyz"
+	MetricsPath   = "/metrics"
+)
+
+// checkTimeout bounds each readiness check, which must answer within the probe's own timeout
+const checkTimeout = 2 * time.Second
+
+// Check reports whether a dependency the service needs to serve requests is usable
+type Check func(ctx context.Context) error
+
+// Health serves liveness, which only shows the process is serving, and readiness, which runs
+// the checks of every dependency the service was given
+type Health struct {
+	names  []string
+	checks map[string]Check
+}
+
+// NewHealth returns a Health without checks, so the service is ready as soon as it serves
+func NewHealth() *Health {
+	return &Health{checks: map[string]Check{}}
+}
+
+// AddCheck adds a dependency readiness depends on. Checks are added while the service starts,
+// before it serves.
+func (h *Health) AddCheck(name string, check Check) {
+	if _, ok := h.checks[name]; !ok {
+		h.names = append(h.names, name)
+	}
+	h.checks[name] = check
+}
+
+// RegisterRoutes serves liveness, readiness and the metrics on the router, without authentication
+func (h *Health) RegisterRoutes(router *mux.Router) {
+	h.RegisterProbes(router)
+	RegisterMetrics(router)
+}
+
+// RegisterProbes serves liveness and readiness on the router, without authentication
+func (h *Health) RegisterProbes(router *mux.Router) {
+	router.HandleFunc(LivenessPath, h.Live).Methods("GET")
+	router.HandleFunc(ReadinessPath, h.Ready).Methods("GET")
+}
+
+// RegisterMetrics serves the metrics on the router, without authentication. A service reachable
+// from the internet serves them on a router of their own, on a port only the cluster reaches.
+func RegisterMetrics(router *mux.Router) {
+	router.Handle(MetricsPath, promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})).Methods("GET")
+}
+
+// Live answers the liveness probe. It checks no dependencies, so an outage elsewhere does not
+// get the service restarted.
+func (h *Health) Live(w http.ResponseWriter, r *http.Request) {
+	writeStatus(w, http.StatusOK, map[string]interface{}{"status": "ok"})
+}
+
+// Ready answers the readiness probe by running every check at once, and is unavailable while any
+// of them fails. Failures are logged, and only reported as failing, since the errors can name
+// internal hosts.
+func (h *Health) Ready(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+	defer cancel()
+
+	errs := make([]error, len(h.names))
+	var wg sync.WaitGroup
+	for i, name := range h.names {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			errs[i] = check(ctx)
+		}(i, h.checks[name])
+	}
+	wg.Wait()
+
+	status := http.StatusOK
+	results := map[string]string{}
+	for i, name := range h.names {
+		if errs[i] != nil {
+			slog.WarnContext(r.Context(), "Readiness check failed", "check", name, "error", errs[i])
+			results[name] = "failing"
+			status = http.StatusServiceUnavailable
+			continue
+		}
+		results[name] = "ok"
+	}
+
+	body := map[string]interface{}{"status": "ok", "checks": results}
+	if status != http.StatusOK {
+		body["status"] = "unavailable"
+	}
+	writeStatus(w, status, body)
+}
+
+// writeStatus writes a probe response as JSON
+func writeStatus(w http.ResponseWriter, status int, body map[string]interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// PingDB checks the database accepts connections
+func PingDB(db *sql.DB) Check {
+	return db.PingContext
+}
+
+// Dial checks a TCP address accepts connections
+func Dial(address string) Check {
+	return func(ctx context.Context) error {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err != nil {
+			return fmt.Errorf("failed to reach %s: %v", address, err)
+		}
+		return conn.Close()
+	}
+}
@@ -0,0 +1,299 @@
+// Package pagination implements the query contract shared by the list endpoints of every service:
+//
+//	limit    page size, default DefaultLimit, at most MaxLimit
+//	cursor   opaque position returned in the X-Next-Cursor header of the previous page
+//	sort     field to sort by, prefixed with - for descending order
+//	from, to inclusive date range (YYYY-MM-DD), on lists that have a date
+//	user_id  senior the rows belong to, on lists that have one
+//
+// The body of a list response stays a JSON array. The number of rows matching the filters is sent in
+// the X-Total-Count header, and X-Next-Cursor is set while there are more pages.
+//
+// Pages are read by keyset: the cursor holds the sort value and ID of the last row served, and the
+// next page starts after that row. Rows added or deleted meanwhile do not shift the rows of the
+// later pages, and the database seeks to the page through the index rather than skipping rows.
+package pagination
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultLimit = 100  // Page size when no limit is given
+	MaxLimit     = 1000 // Largest page size a caller may ask for
+
+	TotalCountHeader = "X-Total-Count" // Response header with the number of matching rows
+	NextCursorHeader = "X-Next-Cursor" // Response header with the cursor of the next page, if any
+
+	dateLayout = "2006-01-02"
+)
+
+// Options describes what a list endpoint can be sorted and filtered by
+type Options struct {
+	Sort        map[string]string // Sortable fields by query name, to the column or expression they sort on
+	DefaultSort string            // Sort used when none is given, - prefixed for descending
+	IDColumn    string            // Unique column that breaks ties, so pages never overlap
+	DateColumn  string            // Column the from and to dates filter on, empty if the list has no date
+	UserColumn  string            // Column the user_id filter matches, empty if the list has no senior
+}
+
+// Query is a parsed list request. Stores select KeyColumns alongside their own columns, scan them
+// into Key, and read the rows with the clauses of Where, for the count, and Page, for the rows.
+// The exported fields describe the same request to stores that filter and page in memory.
+type Query struct {
+	Limit int
+
+	Sort       string    // Field the rows are sorted by, a key of Options.Sort
+	Descending bool      // Whether the rows are sorted in descending order
+	From       time.Time // Start of the from date, zero if no from date was given
+	Before     time.Time // Start of the day after the to date, zero if no to date was given
+	UserID     *int      // Senior the rows must belong to, nil if no user_id was given
+
+	sortParam string // Sort as given, - prefixed for descending, which a cursor must match
+	column    string
+	idColumn  string
+	after     *cursor // Last row of the previous page, nil on the first page
+	last      key     // Key scanned from the last row read
+	where     []string
+	args      []interface{}
+}
+
+// cursor is the position a page ends at, with the number of rows served up to it
+type cursor struct {
+	Sort  string  `json:"sort"`
+	Value *string `json:"value"` // nil when the sort column is NULL
+	ID    string  `json:"id"`
+	Seen  int     `json:"seen"`
+}
+
+// key is the sort value and ID of a row, as the database writes them
+type key struct {
+	value sql.NullString
+	id    string
+}
+
+// Parse reads the list parameters of a request. The error is meant for the caller, as a 400 response.
+func Parse(r *http.Request, opts Options) (*Query, error) {
+	params := r.URL.Query()
+	q := &Query{Limit: DefaultLimit, idColumn: opts.IDColumn}
+
+	if value := params.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > MaxLimit {
+			return nil, fmt.Errorf("Invalid limit, expected 1 to %d", MaxLimit)
+		}
+		q.Limit = limit
+	}
+
+	q.sortParam = params.Get("sort")
+	if q.sortParam == "" {
+		q.sortParam = opts.DefaultSort
+	}
+	sortField, descending := strings.CutPrefix(q.sortParam, "-")
+	column, ok := opts.Sort[sortField]
+	if !ok {
+		return nil, fmt.Errorf("Invalid sort, expected one of %s", strings.Join(sortFields(opts), ", "))
+	}
+	q.Sort, q.Descending, q.column = sortField, descending, column
+
+	// A cursor only continues the sort it was made for
+	if value := params.Get("cursor"); value != "" {
+		after, err := decodeCursor(value)
+		if err != nil || after.Sort != q.sortParam {
+			return nil, errors.New("Invalid cursor")
+		}
+		q.after = after
+	}
+
+	from, to := params.Get("from"), params.Get("to")
+	if (from != "" || to != "") && opts.DateColumn == "" {
+		return nil, errors.New("This list cannot be filtered by date")
+	}
+	if from != "" {
+		date, err := time.Parse(dateLayout, from)
+		if err != nil {
+			return nil, errors.New("Invalid from date, expected YYYY-MM-DD")
+		}
+		q.From = date
+		q.Filter(opts.DateColumn+" >= ?", date.Format(dateLayout))
+	}
+	if to != "" {
+		date, err := time.Parse(dateLayout, to)
+		if err != nil {
+			return nil, errors.New("Invalid to date, expected YYYY-MM-DD")
+		}
+		q.Before = date.AddDate(0, 0, 1)
+		q.Filter(opts.DateColumn+" < ?", q.Before.Format(dateLayout))
+	}
+
+	if value := params.Get("user_id"); value != "" {
+		if opts.UserColumn == "" {
+			return nil, errors.New("This list cannot be filtered by user")
+		}
+		userID, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, errors.New("Invalid user_id")
+		}
+		q.UserID = &userID
+		q.Filter(opts.UserColumn+" = ?", userID)
+	}
+	return q, nil
+}
+
+// Filter adds a condition every row must meet
+func (q *Query) Filter(condition string, args ...interface{}) {
+	q.where = append(q.where, condition)
+	q.args = append(q.args, args...)
+}
+
+// Where returns the WHERE clause of the filters, or an empty string if there are none, with its
+// arguments. It selects every page, for counting the matching rows.
+func (q *Query) Where() (string, []interface{}) {
+	return where(q.where), q.args
+}
+
+// Page returns the WHERE, ORDER BY and LIMIT clauses that select the rows of the requested page,
+// with their arguments
+func (q *Query) Page() (string, []interface{}) {
+	conditions, args := q.where, q.args
+	if q.after != nil {
+		seek, seekArgs := q.seek()
+		conditions = append(conditions[:len(conditions):len(conditions)], seek)
+		args = append(args[:len(args):len(args)], seekArgs...)
+	}
+
+	direction := "ASC"
+	if q.Descending {
+		direction = "DESC"
+	}
+	return fmt.Sprintf("%s ORDER BY %s %s, %s %s LIMIT %d",
+		where(conditions), q.column, direction, q.idColumn, direction, q.Limit), args
+}
+
+// seek returns the condition of the rows after the cursor. MySQL sorts NULLs first, so they come
+// before every value in ascending order and after every value in descending order.
+func (q *Query) seek() (string, []interface{}) {
+	column, id, after := q.column, q.idColumn, q.after
+	switch {
+	case after.Value == nil && !q.Descending:
+		return fmt.Sprintf("(%s IS NOT NULL OR %s > ?)", column, id), []interface{}{after.ID}
+	case after.Value == nil:
+		return fmt.Sprintf("(%s IS NULL AND %s < ?)", column, id), []interface{}{after.ID}
+	case !q.Descending:
+		return fmt.Sprintf("(%s > ? OR (%s = ? AND %s > ?))", column, column, id),
+			[]interface{}{*after.Value, *after.Value, after.ID}
+	default:
+		return fmt.Sprintf("(%s < ? OR %s IS NULL OR (%s = ? AND %s < ?))", column, column, column, id),
+			[]interface{}{*after.Value, *after.Value, after.ID}
+	}
+}
+
+// KeyColumns returns the columns to add to the end of the SELECT list, which hold the sort value and
+// ID the next page starts after. They are read as text, so the cursor holds them as the database
+// compares them.
+func (q *Query) KeyColumns() string {
+	return fmt.Sprintf(", CAST(%s AS CHAR), CAST(%s AS CHAR)", q.column, q.idColumn)
+}
+
+// Key returns the destinations to scan the KeyColumns of each row into, after the row's own
+func (q *Query) Key() []interface{} {
+	return []interface{}{&q.last.value, &q.last.id}
+}
+
+// WriteHeaders sets the total count and, if there are more rows after this page, the cursor of the
+// page after the last row scanned
+func (q *Query) WriteHeaders(w http.ResponseWriter, total, returned int) {
+	w.Header().Set(TotalCountHeader, strconv.Itoa(total))
+
+	seen := returned
+	if q.after != nil {
+		seen += q.after.Seen
+	}
+	if returned == q.Limit && seen < total {
+		next := &cursor{Sort: q.sortParam, ID: q.last.id, Seen: seen}
+		if q.last.value.Valid {
+			next.Value = &q.last.value.String
+		}
+		w.Header().Set(NextCursorHeader, encodeCursor(next))
+	}
+}
+
+// Matches reports whether a row dated date that belongs to userID meets the date and user filters,
+// for stores that filter in memory
+func (q *Query) Matches(date time.Time, userID int) bool {
+	if !q.From.IsZero() && date.Before(q.From) {
+		return false
+	}
+	if !q.Before.IsZero() && !date.Before(q.Before) {
+		return false
+	}
+	return q.UserID == nil || *q.UserID == userID
+}
+
+// Page returns the rows of the requested page, from every matching row in sort order, for stores
+// that page in memory. Their cursors hold the number of rows served rather than a key, so rows
+// added meanwhile shift the later pages.
+func Page[T any](q *Query, rows []T) []T {
+	start := 0
+	if q.after != nil {
+		start = q.after.Seen
+	}
+	if start >= len(rows) {
+		return []T{}
+	}
+	end := start + q.Limit
+	if end > len(rows) {
+		end = len(rows)
+	}
+	q.last = key{id: strconv.Itoa(end)}
+	return rows[start:end]
+}
+
+// where joins conditions into a WHERE clause
+func where(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+// encodeCursor makes the cursor opaque, so callers only page with cursors they were given
+func encodeCursor(c *cursor) string {
+	encoded, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+// decodeCursor returns the position of a cursor
+func decodeCursor(value string) (*cursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	c := &cursor{}
+	if err := json.Unmarshal(decoded, c); err != nil {
+		return nil, err
+	}
+	if c.ID == "" || c.Seen < 0 {
+		return nil, errors.New("incomplete cursor")
+	}
+	return c, nil
+}
+
+// sortFields lists the sortable fields for the error message, in a stable order
+func sortFields(opts Options) []string {
+	fields := make([]string, 0, len(opts.Sort))
+	for field := range opts.Sort {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
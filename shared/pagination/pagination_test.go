@@ -0,0 +1,188 @@
+package pagination
+
+import (
+	"database/sql"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var testOptions = Options{
+	Sort:        map[string]string{"id": "t.id", "score": "t.score"},
+	DefaultSort: "id",
+	IDColumn:    "t.id",
+	DateColumn:  "t.date",
+	UserColumn:  "t.user_id",
+}
+
+// parse parses a list request with the given query string
+func parse(t *testing.T, query string) *Query {
+	t.Helper()
+	q, err := Parse(httptest.NewRequest("GET", "/list?"+query, nil), testOptions)
+	if err != nil {
+		t.Fatalf("Parse(%q) returned %v", query, err)
+	}
+	return q
+}
+
+// nextCursor serves a page ending at a row with the given sort value and ID, and returns the cursor
+// of the next page
+func nextCursor(t *testing.T, q *Query, value *string, id string, total int) string {
+	t.Helper()
+	q.last = key{id: id}
+	if value != nil {
+		q.last.value = sql.NullString{String: *value, Valid: true}
+	}
+	recorder := httptest.NewRecorder()
+	q.WriteHeaders(recorder, total, q.Limit)
+	return recorder.Header().Get(NextCursorHeader)
+}
+
+func TestFirstPage(t *testing.T) {
+	q := parse(t, "limit=10&sort=-score&from=2024-01-01&to=2024-01-31&user_id=7")
+
+	where, args := q.Where()
+	wantWhere := " WHERE t.date >= ? AND t.date < ? AND t.user_id = ?"
+	wantArgs := []interface{}{"2024-01-01", "2024-02-01", 7}
+	if where != wantWhere || !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("Where() = %q %v, want %q %v", where, args, wantWhere, wantArgs)
+	}
+
+	clauses, args := q.Page()
+	wantClauses := wantWhere + " ORDER BY t.score DESC, t.id DESC LIMIT 10"
+	if clauses != wantClauses || !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("Page() = %q %v, want %q %v", clauses, args, wantClauses, wantArgs)
+	}
+}
+
+func TestNextPageSeeksAfterTheLastRow(t *testing.T) {
+	twelve := "12"
+	tests := []struct {
+		sort      string
+		value     *string
+		wantSeek  string
+		wantArgs  []interface{}
+		wantOrder string
+	}{
+		{"score", &twelve, "(t.score > ? OR (t.score = ? AND t.id > ?))", []interface{}{"12", "12", "5"}, "ASC"},
+		{"-score", &twelve, "(t.score < ? OR t.score IS NULL OR (t.score = ? AND t.id < ?))", []interface{}{"12", "12", "5"}, "DESC"},
+		{"score", nil, "(t.score IS NOT NULL OR t.id > ?)", []interface{}{"5"}, "ASC"},
+		{"-score", nil, "(t.score IS NULL AND t.id < ?)", []interface{}{"5"}, "DESC"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			first := parse(t, "limit=2&user_id=7&sort="+tt.sort)
+			cursor := nextCursor(t, first, tt.value, "5", 10)
+			if cursor == "" {
+				t.Fatal("no cursor for the next page")
+			}
+
+			next := parse(t, "limit=2&user_id=7&sort="+tt.sort+"&cursor="+url.QueryEscape(cursor))
+			clauses, args := next.Page()
+			want := " WHERE t.user_id = ? AND " + tt.wantSeek + " ORDER BY t.score " + tt.wantOrder + ", t.id " + tt.wantOrder + " LIMIT 2"
+			if clauses != want {
+				t.Errorf("Page() = %q, want %q", clauses, want)
+			}
+			if wantArgs := append([]interface{}{7}, tt.wantArgs...); !reflect.DeepEqual(args, wantArgs) {
+				t.Errorf("Page() args = %v, want %v", args, wantArgs)
+			}
+
+			// The count still covers every page
+			if where, _ := next.Where(); where != " WHERE t.user_id = ?" {
+				t.Errorf("Where() = %q, want the filters alone", where)
+			}
+		})
+	}
+}
+
+func TestNoCursorAfterTheLastPage(t *testing.T) {
+	first := parse(t, "limit=2")
+	cursor := nextCursor(t, first, nil, "2", 4)
+	if cursor == "" {
+		t.Fatal("no cursor after the first of two pages")
+	}
+	second := parse(t, "limit=2&cursor="+url.QueryEscape(cursor))
+	if cursor := nextCursor(t, second, nil, "4", 4); cursor != "" {
+		t.Errorf("cursor %q after the last page", cursor)
+	}
+
+	// A short page is the last
+	recorder := httptest.NewRecorder()
+	parse(t, "limit=2").WriteHeaders(recorder, 1, 1)
+	if cursor := recorder.Header().Get(NextCursorHeader); cursor != "" {
+		t.Errorf("cursor %q after a short page", cursor)
+	}
+	if total := recorder.Header().Get(TotalCountHeader); total != "1" {
+		t.Errorf("%s = %q, want 1", TotalCountHeader, total)
+	}
+}
+
+func TestInvalidRequests(t *testing.T) {
+	cursor := nextCursor(t, parse(t, "limit=1&sort=score"), nil, "1", 5)
+	tests := map[string]string{
+		"limit too small":      "limit=0",
+		"limit too large":      "limit=1001",
+		"unknown sort":         "sort=name",
+		"malformed cursor":     "cursor=not-a-cursor",
+		"cursor of other sort": "sort=-score&cursor=" + url.QueryEscape(cursor),
+		"invalid date":         "from=01-02-2024",
+		"invalid user":         "user_id=me",
+	}
+	for name, query := range tests {
+		if _, err := Parse(httptest.NewRequest("GET", "/list?"+query, nil), testOptions); err == nil {
+			t.Errorf("%s: Parse(%q) accepted it", name, query)
+		}
+	}
+
+	undated := Options{Sort: map[string]string{"id": "id"}, DefaultSort: "id", IDColumn: "id"}
+	for _, query := range []string{"from=2024-01-01", "user_id=1"} {
+		if _, err := Parse(httptest.NewRequest("GET", "/list?"+query, nil), undated); err == nil {
+			t.Errorf("Parse(%q) accepted a filter the list does not have", query)
+		}
+	}
+}
+
+func TestPageInMemory(t *testing.T) {
+	q := parse(t, "limit=2&sort=-score&from=2024-01-01&to=2024-01-31&user_id=7")
+	if q.Sort != "score" || !q.Descending {
+		t.Errorf("Sort = %q descending %v, want score descending", q.Sort, q.Descending)
+	}
+
+	day := func(date string) time.Time {
+		parsed, _ := time.Parse("2006-01-02", date)
+		return parsed
+	}
+	matches := map[string]bool{
+		"first day":      q.Matches(day("2024-01-01"), 7),
+		"last day":       q.Matches(day("2024-01-31").Add(23*time.Hour), 7),
+		"day before":     q.Matches(day("2023-12-31"), 7),
+		"day after":      q.Matches(day("2024-02-01"), 7),
+		"another senior": q.Matches(day("2024-01-15"), 8),
+	}
+	want := map[string]bool{"first day": true, "last day": true, "day before": false, "day after": false, "another senior": false}
+	if !reflect.DeepEqual(matches, want) {
+		t.Errorf("Matches = %v, want %v", matches, want)
+	}
+
+	// Each page continues where the cursor of the previous one left off
+	rows := []int{1, 2, 3, 4, 5}
+	var pages [][]int
+	for query := "limit=2&sort=-score"; ; {
+		q := parse(t, query)
+		page := Page(q, rows)
+		pages = append(pages, page)
+
+		recorder := httptest.NewRecorder()
+		q.WriteHeaders(recorder, len(rows), len(page))
+		cursor := recorder.Header().Get(NextCursorHeader)
+		if cursor == "" {
+			break
+		}
+		query = "limit=2&sort=-score&cursor=" + url.QueryEscape(cursor)
+	}
+	if wantPages := [][]int{{1, 2}, {3, 4}, {5}}; !reflect.DeepEqual(pages, wantPages) {
+		t.Errorf("pages = %v, want %v", pages, wantPages)
+	}
+}
@@ -0,0 +1,266 @@
+// Package settings reads each service's configuration. A setting is a field of the service's
+// Config tagged with the environment variable it is read from:
+//
+//	JWTSecret string `env:"JWT_SECRET" required:"true" secret:"true" usage:"key tokens are signed with"`
+//
+// Each setting is read from, highest precedence first:
+//
+//  1. the command-line flag named after the variable, --jwt-secret
+//  2. the environment variable
+//  3. the file named by <NAME>_FILE, such as a key of a k8s Secret mounted as a volume
+//  4. the config file, in .env format, named by --config or CONFIG_FILE, or .env in the working
+//     directory when it exists
+//  5. the default tag
+//
+// Strings, ints, bools and comma-separated lists of strings are supported. Untagged struct fields
+// are searched for more settings, and fields tagged only with flag are read only from the flag.
+package settings
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+// Common holds the settings every service reads
+type Common struct {
+	PrintConfig    bool     `flag:"print-config" usage:"print the configuration, with secrets redacted, and exit"`
+	LogLevel       string   `env:"LOG_LEVEL" default:"info" usage:"lowest level logged: debug, info, warn or error"`
+	JWTSecret      string   `env:"JWT_SECRET" required:"true" secret:"true" usage:"key tokens are signed and verified with"`
+	AllowedOrigins []string `env:"ALLOWED_ORIGINS" usage:"comma-separated browser origins allowed by CORS, defaulting to the frontend"`
+	FrontendURL    string   `env:"FRONTEND_URL" usage:"public address of the frontend, used in links sent by email"`
+	ServicesConfig string   `env:"SERVICES_CONFIG" usage:"JSON file of service URLs, allowed origins and the frontend URL"`
+
+	// Args are the command-line arguments after the flags, such as the migrate subcommand
+	Args []string
+
+	// file holds the config file's variables, for settings named at runtime such as the service URLs
+	file map[string]string
+}
+
+// CommonSettings returns the settings every service reads, from the service's Config embedding them
+func (c *Common) CommonSettings() *Common {
+	return c
+}
+
+// Lookup returns a variable from the environment, or else from the config file, for settings
+// named at runtime such as the service URLs
+func (c *Common) Lookup(name string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return c.file[name]
+}
+
+// Error lists every setting that is missing or invalid, so a deployment can be fixed in one go
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return "invalid configuration: " + strings.Join(e.Problems, "; ")
+}
+
+// setting is a tagged field of a Config
+type setting struct {
+	field    reflect.Value
+	env      string
+	flag     string
+	def      string
+	usage    string
+	required bool
+	secret   bool
+}
+
+// settings returns the tagged fields of the struct v, searching untagged struct fields too
+func settings(v reflect.Value) []setting {
+	var found []setting
+	for i := 0; i < v.NumField(); i++ {
+		field, tag := v.Field(i), v.Type().Field(i).Tag
+		env, flagName := tag.Get("env"), tag.Get("flag")
+		if env == "" && flagName == "" {
+			if field.Kind() == reflect.Struct && field.CanSet() {
+				found = append(found, settings(field)...)
+			}
+			continue
+		}
+		if flagName == "" {
+			flagName = strings.ToLower(strings.ReplaceAll(env, "_", "-"))
+		}
+		found = append(found, setting{
+			field:    field,
+			env:      env,
+			flag:     flagName,
+			def:      tag.Get("default"),
+			usage:    tag.Get("usage"),
+			required: tag.Get("required") == "true",
+			secret:   tag.Get("secret") == "true",
+		})
+	}
+	return found
+}
+
+// flagValue records a flag's value as given, to be parsed with the other sources
+type flagValue struct {
+	value   string
+	set     bool
+	boolean bool
+}
+
+func (f *flagValue) String() string   { return f.value }
+func (f *flagValue) IsBoolFlag() bool { return f.boolean }
+func (f *flagValue) Set(value string) error {
+	f.value, f.set = value, true
+	return nil
+}
+
+// Parse fills the settings of the Config cfg points to from args and the other sources, and sets
+// the Args and config file of its Common. It returns every problem found rather than stopping at
+// the first.
+func Parse(cfg interface{}, common *Common, args []string) []string {
+	all := settings(reflect.ValueOf(cfg).Elem())
+
+	flags := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	configFile := flags.String("config", "", "config file in .env format (CONFIG_FILE)")
+	values := make([]*flagValue, len(all))
+	for i, s := range all {
+		values[i] = &flagValue{boolean: s.field.Kind() == reflect.Bool}
+		usage := s.usage
+		if s.env != "" {
+			usage += " (" + s.env + ")"
+		}
+		flags.Var(values[i], s.flag, usage)
+	}
+	var problems []string
+	if err := flags.Parse(args); err != nil {
+		problems = append(problems, err.Error())
+	}
+	common.Args = flags.Args()
+
+	// The config file is optional unless one is named
+	path := *configFile
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path != "" {
+		file, err := godotenv.Read(path)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("config file %s: %v", path, err))
+		}
+		common.file = file
+	} else if file, err := godotenv.Read(".env"); err == nil {
+		common.file = file
+	} else if !errors.Is(err, os.ErrNotExist) {
+		problems = append(problems, fmt.Sprintf("config file .env: %v", err))
+	}
+
+	for i, s := range all {
+		raw, err := s.read(values[i], common.file)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		if raw == "" && s.required {
+			problems = append(problems, s.env+" is not set")
+			continue
+		}
+		if err := s.set(raw); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	return problems
+}
+
+// read returns the setting's value from the highest precedence source that has one
+func (s setting) read(flagged *flagValue, file map[string]string) (string, error) {
+	if flagged.set {
+		return flagged.value, nil
+	}
+	if s.env == "" {
+		return s.def, nil
+	}
+	if value := os.Getenv(s.env); value != "" {
+		return value, nil
+	}
+	secretFile := os.Getenv(s.env + "_FILE")
+	if secretFile == "" {
+		secretFile = file[s.env+"_FILE"]
+	}
+	if secretFile != "" {
+		data, err := os.ReadFile(secretFile)
+		if err != nil {
+			return "", fmt.Errorf("%s_FILE: %v", s.env, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	if value := file[s.env]; value != "" {
+		return value, nil
+	}
+	return s.def, nil
+}
+
+// set parses raw into the setting's field
+func (s setting) set(raw string) error {
+	name := s.env
+	if name == "" {
+		name = "--" + s.flag
+	}
+	switch s.field.Kind() {
+	case reflect.String:
+		s.field.SetString(raw)
+	case reflect.Int:
+		if raw == "" {
+			return nil
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("%s must be a whole number, not %q", name, raw)
+		}
+		s.field.SetInt(int64(n))
+	case reflect.Bool:
+		if raw == "" {
+			return nil
+		}
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%s must be true or false, not %q", name, raw)
+		}
+		s.field.SetBool(b)
+	case reflect.Slice:
+		var list []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		s.field.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("%s has an unsupported type %s", name, s.field.Type())
+	}
+	return nil
+}
+
+// Print writes the settings of the Config cfg points to in .env format, with secrets redacted,
+// for --print-config
+func Print(w io.Writer, cfg interface{}) {
+	for _, s := range settings(reflect.ValueOf(cfg).Elem()) {
+		if s.env == "" {
+			continue
+		}
+		value := fmt.Sprint(s.field.Interface())
+		if s.field.Kind() == reflect.Slice {
+			value = strings.Join(s.field.Interface().([]string), ",")
+		}
+		if s.secret && value != "" {
+			value = "[redacted]"
+		}
+		fmt.Fprintf(w, "%s=%s\n", s.env, value)
+	}
+}
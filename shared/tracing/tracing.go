@@ -0,0 +1,85 @@
+// Package tracing records OpenTelemetry traces of the requests the service serves and the calls it
+// makes to the other services, passing the trace on in the W3C traceparent header so that one
+// request can be followed through every service it reaches. Spans are exported over OTLP/HTTP when
+// OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT is set, such as
+// http://localhost:4318 for a local collector, and the other OTEL_ variables of the SDK apply.
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"os"
+
+	"shared/logging"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// untracedPaths are the probe and scrape endpoints, which would otherwise fill the traces
+var untracedPaths = map[string]bool{"/healthz": true, "/readyz": true, "/metrics": true}
+
+// Setup makes the service's tracer provider and the W3C propagators the global ones, returning a
+// function that flushes the spans not yet exported. Spans are recorded even when nothing exports
+// them, so the logs still carry trace IDs.
+func Setup(ctx context.Context, service string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the service name
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(service)),
+		resource.WithTelemetrySDK(),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	options := []sdktrace.TracerProviderOption{sdktrace.WithResource(res)}
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" {
+		exporter, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, sdktrace.WithBatcher(exporter))
+	}
+
+	provider := sdktrace.NewTracerProvider(options...)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Middleware starts a span for each request the router matched, continuing the caller's trace and
+// named after the route. It is used on the router, with Use, since the route is only known once the
+// router has matched it.
+func Middleware(service string) mux.MiddlewareFunc {
+	traced := otelmux.Middleware(service,
+		otelmux.WithFilter(func(r *http.Request) bool { return !untracedPaths[r.URL.Path] }),
+		otelmux.WithSpanNameFormatter(func(route string, r *http.Request) string { return r.Method + " " + route }),
+	)
+	return func(next http.Handler) http.Handler {
+		return traced(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// The request ID ties the span to the request's log entries
+			if id := logging.RequestID(r.Context()); id != "" {
+				trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("request.id", id))
+			}
+			next.ServeHTTP(w, r)
+		}))
+	}
+}
+
+// Transport records a span for each request sent through base and passes the trace on with it
+func Transport(base http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(base,
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string { return r.Method + " " + r.URL.Path }),
+	)
+}
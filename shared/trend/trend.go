@@ -0,0 +1,226 @@
+package trend
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// DefaultDays is how far back a trend looks unless the caller asks otherwise
+const DefaultDays = 180
+
+// rollingWindow is the number of points in each rolling average
+const rollingWindow = 3
+
+// minChangePercent is the smallest change over the period that is reported as meaningful,
+// so a statistically clear but clinically trivial drift is not flagged
+const minChangePercent = 10
+
+// Direction says which way a metric gets worse
+type Direction int
+
+const (
+	HigherIsWorse Direction = iota // e.g. FES total, test time
+	LowerIsWorse                   // e.g. session score
+)
+
+// Point is one observation of a metric
+type Point struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+}
+
+// Change describes how a metric moved over the period, along the fitted trend line
+type Change struct {
+	From        float64  `json:"from"`
+	To          float64  `json:"to"`
+	Percent     float64  `json:"percent"`
+	Days        int      `json:"days"`
+	TStatistic  *float64 `json:"t_statistic"` // Null when the points lie exactly on the line
+	Significant bool     `json:"significant"` // Slope differs from zero at p < 0.05 and the change is at least 10%
+	Worsening   bool     `json:"worsening"`
+	Summary     string   `json:"summary"`
+}
+
+// Series is a senior's trajectory for one metric
+type Series struct {
+	Metric         string   `json:"metric"`
+	Unit           string   `json:"unit,omitempty"`
+	Points         []Point  `json:"points"`
+	RollingAverage []Point  `json:"rolling_average"`
+	SlopePer30Days *float64 `json:"slope_per_30_days"` // Null with fewer than two points
+	Change         *Change  `json:"change"`            // Null with fewer than three points
+}
+
+// Analyze computes the rolling average, slope and change of a metric.
+// Points must be in time order.
+func Analyze(metric, unit string, direction Direction, points []Point) Series {
+	series := Series{Metric: metric, Unit: unit, Points: points, RollingAverage: rollingAverage(points)}
+	if series.Points == nil {
+		series.Points = []Point{}
+	}
+	if len(points) < 2 {
+		return series
+	}
+
+	fit, ok := fitLine(points)
+	if !ok {
+		return series
+	}
+	slope := fit.slope * 30
+	series.SlopePer30Days = &slope
+
+	if len(points) < 3 {
+		return series
+	}
+
+	days := points[len(points)-1].Time.Sub(points[0].Time).Hours() / 24
+	change := &Change{
+		From: fit.at(0),
+		To:   fit.at(days),
+		Days: int(math.Round(days)),
+	}
+	if !fit.exact {
+		t := fit.t
+		change.TStatistic = &t
+	}
+	if change.From != 0 {
+		change.Percent = (change.To - change.From) / math.Abs(change.From) * 100
+	}
+	change.Worsening = (direction == HigherIsWorse) == (change.To > change.From)
+	clear := fit.exact || math.Abs(fit.t) >= tCritical(len(points)-2)
+	change.Significant = clear && fit.slope != 0 && math.Abs(change.Percent) >= minChangePercent
+
+	verb := "improved"
+	if change.Worsening {
+		verb = "worsened"
+	}
+	if change.Significant {
+		change.Summary = fmt.Sprintf("%s %s %.0f%% over %s", metric, verb, math.Abs(change.Percent), describeDays(change.Days))
+	} else {
+		change.Summary = fmt.Sprintf("%s stable over %s", metric, describeDays(change.Days))
+	}
+	series.Change = change
+	return series
+}
+
+// rollingAverage returns the trailing average of each point and the ones before it
+func rollingAverage(points []Point) []Point {
+	averages := make([]Point, 0, len(points))
+	sum := 0.0
+	for i, point := range points {
+		sum += point.Value
+		if i >= rollingWindow {
+			sum -= points[i-rollingWindow].Value
+		}
+		n := math.Min(float64(i+1), rollingWindow)
+		averages = append(averages, Point{Time: point.Time, Value: sum / n})
+	}
+	return averages
+}
+
+// line is a least-squares fit of value against days since the first point
+type line struct {
+	intercept float64
+	slope     float64 // Per day
+	t         float64 // Slope divided by its standard error
+	exact     bool    // Every point lies on the line, so the standard error is zero
+}
+
+// at returns the fitted value a number of days after the first point
+func (l line) at(days float64) float64 {
+	return l.intercept + l.slope*days
+}
+
+// fitLine fits a straight line through the points. It fails when every point is at the same time.
+func fitLine(points []Point) (line, bool) {
+	n := float64(len(points))
+	start := points[0].Time
+	var sumX, sumY float64
+	for _, point := range points {
+		sumX += point.Time.Sub(start).Hours() / 24
+		sumY += point.Value
+	}
+	meanX, meanY := sumX/n, sumY/n
+
+	var sxx, sxy float64
+	for _, point := range points {
+		dx := point.Time.Sub(start).Hours()/24 - meanX
+		sxx += dx * dx
+		sxy += dx * (point.Value - meanY)
+	}
+	if sxx == 0 {
+		return line{}, false
+	}
+
+	fit := line{slope: sxy / sxx}
+	fit.intercept = meanY - fit.slope*meanX
+	if len(points) < 3 {
+		return fit, true
+	}
+
+	var residuals float64
+	for _, point := range points {
+		r := point.Value - fit.at(point.Time.Sub(start).Hours()/24)
+		residuals += r * r
+	}
+	standardError := math.Sqrt(residuals / (n - 2) / sxx)
+	if standardError == 0 {
+		fit.exact = true
+	} else {
+		fit.t = fit.slope / standardError
+	}
+	return fit, true
+}
+
+// tTable holds the two-sided 5% critical values of Student's t for 1 to 30 degrees of freedom
+var tTable = []float64{
+	12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+}
+
+// tTail holds the critical values beyond tTable at the degrees of freedom printed tables list.
+// Between them the value is interpolated linearly in 1/df, which is accurate to about 0.001.
+var tTail = []struct {
+	degreesOfFreedom float64
+	value            float64
+}{
+	{30, 2.042}, {40, 2.021}, {60, 2.000}, {120, 1.980}, {math.Inf(1), 1.960},
+}
+
+// tCritical returns the value |t| must reach for a slope to be significant at p < 0.05
+func tCritical(degreesOfFreedom int) float64 {
+	if degreesOfFreedom <= len(tTable) {
+		return tTable[degreesOfFreedom-1]
+	}
+	df := float64(degreesOfFreedom)
+	for i := 1; i < len(tTail); i++ {
+		lower, upper := tTail[i-1], tTail[i]
+		if df <= upper.degreesOfFreedom {
+			fraction := (1/lower.degreesOfFreedom - 1/df) / (1/lower.degreesOfFreedom - 1/upper.degreesOfFreedom)
+			return lower.value + (upper.value-lower.value)*fraction
+		}
+	}
+	return tTail[len(tTail)-1].value
+}
+
+// describeDays turns a period into words, e.g. "3 months"
+func describeDays(days int) string {
+	switch {
+	case days < 14:
+		return plural(days, "day")
+	case days < 60:
+		return plural(int(math.Round(float64(days)/7)), "week")
+	default:
+		return plural(int(math.Round(float64(days)/30)), "month")
+	}
+}
+
+// plural formats a count with its unit, e.g. "2 weeks"
+func plural(n int, unit string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", unit)
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
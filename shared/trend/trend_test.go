@@ -0,0 +1,164 @@
+package trend
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// daily returns a point a day for each value, from start
+func daily(values ...float64) []Point {
+	points := make([]Point, len(values))
+	for i, value := range values {
+		points[i] = Point{Time: start.AddDate(0, 0, i), Value: value}
+	}
+	return points
+}
+
+func near(got, want, tolerance float64) bool {
+	return math.Abs(got-want) <= tolerance
+}
+
+func TestSlope(t *testing.T) {
+	// 10 + 0.5 a day, a point every ten days
+	var points []Point
+	for day := 0; day <= 90; day += 10 {
+		points = append(points, Point{Time: start.AddDate(0, 0, day), Value: 10 + 0.5*float64(day)})
+	}
+	series := Analyze("Test time", "s", HigherIsWorse, points)
+
+	if series.SlopePer30Days == nil || !near(*series.SlopePer30Days, 15, 1e-9) {
+		t.Fatalf("SlopePer30Days = %v, want 15", series.SlopePer30Days)
+	}
+	change := series.Change
+	if change == nil {
+		t.Fatal("no change for ten points")
+	}
+	if !near(change.From, 10, 1e-9) || !near(change.To, 55, 1e-9) || !near(change.Percent, 450, 1e-9) || change.Days != 90 {
+		t.Errorf("change = %+v, want from 10 to 55, 450%% over 90 days", change)
+	}
+	// Every point is on the line, so there is no t statistic but the slope is clear
+	if change.TStatistic != nil || !change.Significant || !change.Worsening {
+		t.Errorf("change = %+v, want a significant worsening with no t statistic", change)
+	}
+	if change.Summary != "Test time worsened 450% over 3 months" {
+		t.Errorf("Summary = %q", change.Summary)
+	}
+
+	// A falling score is worse when lower is worse
+	falling := Analyze("Session score", "", LowerIsWorse, daily(9, 8, 7, 6))
+	if falling.Change == nil || !falling.Change.Worsening || !near(*falling.SlopePer30Days, -30, 1e-9) {
+		t.Errorf("falling score = %+v, want worsening at -30 per 30 days", falling.Change)
+	}
+	rising := Analyze("Session score", "", LowerIsWorse, daily(6, 7, 8, 9))
+	if rising.Change == nil || rising.Change.Worsening {
+		t.Errorf("rising score = %+v, want improving", rising.Change)
+	}
+}
+
+func TestTooFewPoints(t *testing.T) {
+	empty := Analyze("FES total", "", HigherIsWorse, nil)
+	if empty.Points == nil || len(empty.RollingAverage) != 0 || empty.SlopePer30Days != nil || empty.Change != nil {
+		t.Errorf("no points = %+v, want empty points and no slope or change", empty)
+	}
+
+	two := Analyze("FES total", "", HigherIsWorse, daily(20, 30))
+	if two.SlopePer30Days == nil || two.Change != nil {
+		t.Errorf("two points = %+v, want a slope and no change", two)
+	}
+
+	sameTime := Analyze("FES total", "", HigherIsWorse, []Point{{Time: start, Value: 20}, {Time: start, Value: 30}, {Time: start, Value: 40}})
+	if sameTime.SlopePer30Days != nil || sameTime.Change != nil {
+		t.Errorf("points at one time = %+v, want no slope or change", sameTime)
+	}
+}
+
+func TestRollingAverage(t *testing.T) {
+	series := Analyze("FES total", "", HigherIsWorse, daily(3, 6, 9, 12, 0))
+	want := []float64{3, 4.5, 6, 9, 7}
+	for i, point := range series.RollingAverage {
+		if !near(point.Value, want[i], 1e-9) || !point.Time.Equal(series.Points[i].Time) {
+			t.Errorf("RollingAverage[%d] = %+v, want %v at the point's time", i, point, want[i])
+		}
+	}
+}
+
+// withT returns 33 daily points, 31 degrees of freedom, around 5 with alternating noise and a slope
+// chosen so the fit's t statistic is exactly want. Adding to the slope leaves the residuals, and so
+// the standard error, as they are.
+func withT(t *testing.T, want float64) []Point {
+	t.Helper()
+	values := make([]float64, 33)
+	for i := range values {
+		values[i] = 5 + float64(i%2*2-1) + 0.01*float64(i)
+	}
+	noise, _ := fitLine(daily(values...))
+	standardError := noise.slope / noise.t
+
+	extra := want*standardError - noise.slope
+	for i := range values {
+		values[i] += extra * float64(i)
+	}
+	points := daily(values...)
+	if fit, _ := fitLine(points); !near(fit.t, want, 1e-6) {
+		t.Fatalf("t = %v, want %v", fit.t, want)
+	}
+	return points
+}
+
+func TestSignificance(t *testing.T) {
+	// The critical value at 31 degrees of freedom is 2.040, above the 2.000 of 60
+	below := Analyze("Test time", "s", HigherIsWorse, withT(t, 2.02))
+	if below.Change != nil && math.Abs(below.Change.Percent) < minChangePercent {
+		t.Fatalf("change of %.1f%% is too small to test the t statistic", below.Change.Percent)
+	}
+	if below.Change == nil || below.Change.TStatistic == nil || below.Change.Significant {
+		t.Errorf("t = 2.02 with 31 degrees of freedom = %+v, want not significant", below.Change)
+	}
+	if below.Change != nil && below.Change.Summary != "Test time stable over 5 weeks" {
+		t.Errorf("Summary = %q", below.Change.Summary)
+	}
+
+	above := Analyze("Test time", "s", HigherIsWorse, withT(t, 2.06))
+	if above.Change == nil || !above.Change.Significant {
+		t.Errorf("t = 2.06 with 31 degrees of freedom = %+v, want significant", above.Change)
+	}
+
+	// A clear slope is still not reported when the change is under 10%
+	small := Analyze("FES total", "", HigherIsWorse, daily(40, 40.5, 41, 41.5))
+	if small.Change == nil || small.Change.Significant || !near(small.Change.Percent, 3.75, 1e-9) {
+		t.Errorf("3.75%% change = %+v, want not significant", small.Change)
+	}
+
+	// A flat series is not significant however long it is
+	flat := Analyze("FES total", "", HigherIsWorse, daily(30, 32, 30, 32, 30, 32))
+	if flat.Change == nil || flat.Change.Significant {
+		t.Errorf("flat series = %+v, want not significant", flat.Change)
+	}
+}
+
+func TestCriticalValues(t *testing.T) {
+	// Two-sided 5% critical values of Student's t
+	want := map[int]float64{
+		1: 12.706, 2: 4.303, 10: 2.228, 30: 2.042,
+		31: 2.040, 35: 2.030, 40: 2.021, 45: 2.014, 50: 2.009,
+		60: 2.000, 80: 1.990, 100: 1.984, 120: 1.980, 200: 1.972, 1000: 1.962,
+	}
+	for df, value := range want {
+		if got := tCritical(df); !near(got, value, 0.0015) {
+			t.Errorf("tCritical(%d) = %.4f, want %.3f", df, got, value)
+		}
+	}
+
+	// The value falls as the degrees of freedom grow, towards the normal distribution's 1.960
+	previous := tCritical(1)
+	for df := 2; df <= 10000; df++ {
+		got := tCritical(df)
+		if got > previous || got < 1.96 {
+			t.Fatalf("tCritical(%d) = %.4f after %.4f", df, got, previous)
+		}
+		previous = got
+	}
+}
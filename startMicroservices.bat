@@ -1,4 +1,7 @@
 @echo off
+:: Point every microservice at the others on localhost instead of the k8s service names
+set SERVICES_CONFIG=%cd%\services.local.json
+
 :: Open a new terminal for each microservice and run main.go
 
 start cmd /k "cd /d adminMicroservice && go run main.go"
@@ -7,6 +10,7 @@ start cmd /k "cd /d fallsEfficacyScaleMicroservice && go run main.go"
 start cmd /k "cd /d openAIMicroservice && go run main.go"
 start cmd /k "cd /d selfAssessmentMicroservice && go run main.go"
 start cmd /k "cd /d userMicroservice && go run main.go"
+start cmd /k "cd /d gatewayMicroservice && go run main.go"
 
 :: You can add more lines as needed for other directories.
 echo Microservices started. Close this window to stop the batch process.
@@ -0,0 +1,10 @@
+package config
+
+import "shared/settings"
+
+// Config is the template microservice's configuration
+type Config struct {
+	settings.Common
+	Port         int    `env:"PORT" default:"9000" usage:"port the service listens on"`
+	DBConnection string `env:"DB_CONNECTION" required:"true" secret:"true" usage:"MySQL DSN of the service's database"`
+}
@@ -1,113 +1,67 @@
 package main
 
 import (
-	"log"
-	"net/http"
+	"context"
+	"fmt"
+	"log/slog"
 	"os"
-	"strings"
 
+	"templateMicroservice/config"
+	"templateMicroservice/server"
 	"templateMicroservice/template"
 
-	"github.com/golang-jwt/jwt/v4"
-	"github.com/gorilla/handlers"
-	"github.com/gorilla/mux"
+	"shared/graceful"
+	"shared/logging"
+	"shared/observability"
+	"shared/settings"
+	"shared/tracing"
 )
 
-// Authentication middleware to validate JWT
-// JWT Authentication Middleware with Role Check for multiple roles
-func authenticateMiddleware(allowedRoles []string) mux.MiddlewareFunc {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Get the Authorization header
-			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return
-			}
-
-			// Extract token
-			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-
-			// Get the JWT secret from the environment variable
-			secretKey := os.Getenv("JWT_SECRET")
-			if secretKey == "" {
-				log.Println("JWT_SECRET is not set in the environment")
-				http.Error(w, "Internal server error", http.StatusInternalServerError)
-				return
-			}
-
-			// Parse and validate the token
-			token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, http.ErrAbortHandler
-				}
-				return []byte(secretKey), nil
-			})
-
-			if err != nil || !token.Valid {
-				log.Printf("Invalid JWT token: %v", err)
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return
-			}
-
-			// Extract the claims from the token
-			claims, ok := token.Claims.(jwt.MapClaims)
-			if !ok {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return
-			}
-
-			// Check if the role matches any of the allowed roles
-			role, ok := claims["role"].(string)
-			if !ok {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return
-			}
-
-			// Check if the user's role is in the allowed roles
-			roleAllowed := false
-			for _, allowedRole := range allowedRoles {
-				if role == allowedRole {
-					roleAllowed = true
-					break
-				}
-			}
-
-			if !roleAllowed {
-				http.Error(w, "Forbidden", http.StatusForbidden)
-				return
-			}
+func main() {
+	// Read the configuration from the flags, the environment, secret files and the config file
+	cfg, err := config.Load(os.Args[1:])
+	if cfg.PrintConfig {
+		settings.Print(os.Stdout, cfg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 
-			// Proceed to the next handler if role matches
-			next.ServeHTTP(w, r)
-		})
+	// Log as JSON from here on, at the level set by LOG_LEVEL
+	logging.Setup("template-service", cfg.LogLevel)
+	if err != nil {
+		slog.Error("Error loading configuration", "error", err)
+		os.Exit(1)
 	}
-}
 
+	// Trace requests across the services, exporting the spans if an OTLP collector is configured
+	shutdownTracing, err := tracing.Setup(context.Background(), "template-service")
+	if err != nil {
+		slog.Error("Error setting up tracing", "error", err)
+		os.Exit(1)
+	}
 
+	db, err := template.OpenDB(cfg.DBConnection)
+	if err != nil {
+		slog.Error("Error opening template database", "error", err)
+		os.Exit(1)
+	}
 
-func main() {
-	// Initialize the router
-	router := mux.NewRouter()
+	h := template.NewHandler(template.NewMySQLStore(db))
 
-	// Template management endpoints
-	router.HandleFunc("/api/v1/template/unprotectedGetUser", template.GetAllUsers).Methods("GET")
-	
-	// JWT Authentication Logic
-	authenticated := router.NewRoute().Subrouter()
-	authenticated.Use(authenticateMiddleware([]string{"Admin", "User"}))
-	
-	// Template management endpoints
-	authenticated.HandleFunc("/api/v1/template/protectedGetUser", template.GetAllUsers).Methods("GET")
+	// Ready while the database is reachable
+	health := observability.NewHealth()
+	health.AddCheck("database", observability.PingDB(db))
 
-	// Add CORS support
-	corsHandler := handlers.CORS(
-		handlers.AllowedOrigins([]string{"http://18.143.103.158:9000"}), // Update for allowed origins
-		handlers.AllowedMethods([]string{"GET", "POST", "PUT", "OPTIONS"}), // Update for allowed HTTP methods
-		handlers.AllowedHeaders([]string{"Content-Type", "Authorization"}), // Include Authorization header
-	)(router)
+	// Route the endpoints through their middleware
+	router := server.NewRouter(h, health)
 
-	// Start the server
-	log.Println("User Microservice is running on port 9000...")
-	log.Fatal(http.ListenAndServe(":9000", corsHandler))
+	// Serve until SIGTERM, then drain the requests in flight, close the database and flush the spans
+	slog.Info("User Microservice is running", "port", cfg.Port)
+	if err := graceful.Serve(fmt.Sprintf(":%d", cfg.Port), router, graceful.CloseFunc(db.Close), shutdownTracing); err != nil {
+		slog.Error("Server stopped", "error", err)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,44 @@
+// Package memstore keeps the template user records in memory, for testing the template handlers with
+// httptest without a database:
+//
+//	store := memstore.New()
+//	store.AddUser(template.User{UserID: 1, Name: "Tan Ah Kow"})
+//	handler := template.NewHandler(store)
+//	handler.GetAllUsers(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/template/unprotectedGetUser", nil))
+package memstore
+
+import (
+	"context"
+	"sync"
+
+	"templateMicroservice/template"
+)
+
+// Store is a template.UserStore held in memory
+type Store struct {
+	mu    sync.Mutex
+	users []template.User
+}
+
+var _ template.UserStore = (*Store)(nil)
+
+// New returns an empty store
+func New() *Store {
+	return &Store{}
+}
+
+// AddUser adds a user record
+func (s *Store) AddUser(user template.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users = append(s.users, user)
+}
+
+func (s *Store) User(ctx context.Context) (template.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.users) == 0 {
+		return template.User{}, template.ErrNotFound
+	}
+	return s.users[0], nil
+}
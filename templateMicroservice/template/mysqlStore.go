@@ -0,0 +1,56 @@
+package template
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	"shared/observability"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// OpenDB connects to the service database and checks the connection
+func OpenDB(dsn string) (*sql.DB, error) {
+	if dsn == "" {
+		return nil, fmt.Errorf("DB_CONNECTION environment variable is not set")
+	}
+
+	slog.Debug("Initializing database connection")
+	db, err := observability.OpenDB("mysql", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to database: %v", err)
+	}
+
+	// Test the database connection
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("database connection test failed: %v", err)
+	}
+	slog.Info("Database connection successful")
+	return db, nil
+}
+
+// MySQLStore is the UserStore kept in the service database
+type MySQLStore struct {
+	db *sql.DB
+}
+
+// NewMySQLStore returns a store reading the given database
+func NewMySQLStore(db *sql.DB) *MySQLStore {
+	return &MySQLStore{db: db}
+}
+
+func (s *MySQLStore) User(ctx context.Context) (User, error) {
+	var user User
+	err := s.db.QueryRowContext(ctx, `
+		SELECT user_id, name, email, phone_number, address, age
+		FROM User`).Scan(
+		&user.UserID, &user.Name, &user.Email, &user.PhoneNumber, &user.Address, &user.Age,
+	)
+	if err == sql.ErrNoRows {
+		return User{}, ErrNotFound
+	}
+	return user, err
+}
@@ -0,0 +1,16 @@
+package template
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotFound is returned by a store when the requested row does not exist
+var ErrNotFound = errors.New("not found")
+
+// UserStore is the user records the template handlers read.
+// MySQLStore keeps them in the service database, and the memstore package keeps them in memory for handler tests.
+type UserStore interface {
+	// User returns a user record, or ErrNotFound if there is none
+	User(ctx context.Context) (User, error)
+}
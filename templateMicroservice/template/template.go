@@ -1,45 +1,14 @@
 package template
 
 import (
-	"database/sql"
 	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
-	"os"
 
-	_ "github.com/go-sql-driver/mysql"
-	"github.com/joho/godotenv"
+	"shared/apierror"
 )
 
-var db *sql.DB
-
-func init() {
-	// Load environment variables
-	err := godotenv.Load(".env")
-	if err != nil {
-		log.Fatalf("Error loading .env file: %v", err)
-	}
-
-	// Initialize database connection
-	dbConnection := os.Getenv("DB_CONNECTION")
-	if dbConnection == "" {
-		log.Fatalf("DB_CONNECTION environment variable is not set")
-	}
-
-	log.Println("Initializing database connection...")
-	db, err = sql.Open("mysql", dbConnection)
-	if err != nil {
-		log.Fatalf("Error connecting to database: %v", err)
-	}
-
-	// Test the database connection
-	err = db.Ping()
-	if err != nil {
-		log.Fatalf("Database connection test failed: %v", err)
-	}
-	log.Println("Database connection successful.")
-}
-
 // User represents the structure of a user record
 type User struct {
 	UserID      int    `json:"user_id"`
@@ -50,21 +19,25 @@ type User struct {
 	Age         string `json:"age"`
 }
 
+// Handler serves the template endpoints from its store
+type Handler struct {
+	store UserStore
+}
+
+// NewHandler returns the template handlers, reading store
+func NewHandler(store UserStore) *Handler {
+	return &Handler{store: store}
+}
+
 // GetAllUsers handles retrieving a user record from the database by userID
-func GetAllUsers(w http.ResponseWriter, r *http.Request) {
-	// Prepare the query to fetch user details by userID
-	var user User
-	err := db.QueryRow(`
-		SELECT user_id, name, email, phone_number, address, age
-		FROM User`).Scan(
-		&user.UserID, &user.Name, &user.Email, &user.PhoneNumber, &user.Address, &user.Age,
-	)
-	if err == sql.ErrNoRows {
-		http.Error(w, "User not found", http.StatusNotFound)
+func (h *Handler) GetAllUsers(w http.ResponseWriter, r *http.Request) {
+	user, err := h.store.User(r.Context())
+	if errors.Is(err, ErrNotFound) {
+		apierror.Write(w, r, http.StatusNotFound, apierror.NotFound, "User not found")
 		return
 	} else if err != nil {
-		log.Printf("Error querying user record: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error querying user record", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
 		return
 	}
 
@@ -72,8 +45,8 @@ func GetAllUsers(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	err = json.NewEncoder(w).Encode(user)
 	if err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error encoding response", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
 		return
 	}
 }
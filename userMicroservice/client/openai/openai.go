@@ -0,0 +1,36 @@
+package openai
+
+import (
+	"context"
+	"time"
+
+	"shared/client"
+	"shared/config"
+)
+
+// generateTimeout allows for the language model, which is slower than the other services
+const generateTimeout = 60 * time.Second
+
+// Response is text generated by the language model
+type Response struct {
+	Response string `json:"response"`
+}
+
+// Client calls the OpenAI microservice
+type Client struct {
+	*client.Client
+}
+
+// New returns a client for the OpenAI microservice
+func New() *Client {
+	c := client.New(config.OpenAIService)
+	c.HTTP.Timeout = generateTimeout
+	return &Client{c}
+}
+
+// GenerateResponse asks the language model to respond to a prompt
+func (c *Client) GenerateResponse(ctx context.Context, prompt string) (Response, error) {
+	var response Response
+	err := c.Post(ctx, "/api/v1/generateResponse", map[string]string{"prompt": prompt}, &response)
+	return response, err
+}
@@ -0,0 +1,20 @@
+package config
+
+import "shared/settings"
+
+// Config is the user microservice's configuration
+type Config struct {
+	settings.Common
+	Port           int    `env:"PORT" default:"5100" usage:"port the service listens on"`
+	DBConnection   string `env:"USER_DB_CONNECTION" required:"true" secret:"true" usage:"MySQL DSN of the user database"`
+	MigrateOnStart bool   `env:"MIGRATE_ON_START" default:"true" usage:"apply pending schema migrations on start"`
+	SMTP           SMTP
+}
+
+// SMTP is the mail server emails are sent through
+type SMTP struct {
+	Host     string `env:"SMTP_HOST" default:"smtp.gmail.com" usage:"mail server host"`
+	Port     string `env:"SMTP_PORT" default:"587" usage:"mail server submission port"`
+	User     string `env:"SMTP_USER" required:"true" usage:"mail account emails are sent from"`
+	Password string `env:"SMTP_PASSWORD" required:"true" secret:"true" usage:"mail account password"`
+}
@@ -1,112 +1,87 @@
 package main
 
 import (
-	"log"
-	"net/http"
+	"context"
+	"fmt"
+	"log/slog"
 	"os"
-	"strings"
+	"userMicroservice/config"
+	"userMicroservice/migrations"
 	"userMicroservice/profile"
-
-	"github.com/golang-jwt/jwt/v4"
-	"github.com/gorilla/handlers"
-	"github.com/gorilla/mux"
+	"userMicroservice/server"
+
+	"shared/client"
+	sharedconfig "shared/config"
+	"shared/graceful"
+	"shared/logging"
+	"shared/migrate"
+	"shared/observability"
+	"shared/settings"
+	"shared/tracing"
 )
 
-// JWT Authentication Middleware with Role Check for multiple roles
-func authenticateMiddleware(allowedRoles []string) mux.MiddlewareFunc {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Get the Authorization header
-			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return
-			}
-
-			// Extract token
-			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-
-			// Get the JWT secret from the environment variable
-			secretKey := os.Getenv("JWT_SECRET")
-			if secretKey == "" {
-				log.Println("JWT_SECRET is not set in the environment")
-				http.Error(w, "Internal server error", http.StatusInternalServerError)
-				return
-			}
-
-			// Parse and validate the token
-			token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, http.ErrAbortHandler
-				}
-				return []byte(secretKey), nil
-			})
-
-			if err != nil || !token.Valid {
-				log.Printf("Invalid JWT token: %v", err)
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return
-			}
-
-			// Extract the claims from the token
-			claims, ok := token.Claims.(jwt.MapClaims)
-			if !ok {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return
-			}
-
-			// Check if the role matches any of the allowed roles
-			role, ok := claims["role"].(string)
-			if !ok {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return
-			}
-
-			// Check if the user's role is in the allowed roles
-			roleAllowed := false
-			for _, allowedRole := range allowedRoles {
-				if role == allowedRole {
-					roleAllowed = true
-					break
-				}
-			}
+func main() {
+	// Read the configuration from the flags, the environment, secret files and the config file
+	cfg, err := config.Load(os.Args[1:])
+	if cfg.PrintConfig {
+		settings.Print(os.Stdout, cfg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 
-			if !roleAllowed {
-				http.Error(w, "Forbidden", http.StatusForbidden)
-				return
-			}
+	// Log as JSON from here on, at the level set by LOG_LEVEL
+	logging.Setup(sharedconfig.UserService, cfg.LogLevel)
+	if err != nil {
+		slog.Error("Error loading configuration", "error", err)
+		os.Exit(1)
+	}
 
-			// Proceed to the next handler if role matches
-			next.ServeHTTP(w, r)
-		})
+	// Trace requests across the services, exporting the spans if an OTLP collector is configured
+	shutdownTracing, err := tracing.Setup(context.Background(), sharedconfig.UserService)
+	if err != nil {
+		slog.Error("Error setting up tracing", "error", err)
+		os.Exit(1)
 	}
-}
 
-func main() {
-	// Initialize the router
-	router := mux.NewRouter()
+	// Run the migrate subcommand, or bring the schema up to date before serving
+	if len(cfg.Args) > 0 && cfg.Args[0] == "migrate" {
+		if err := migrate.Command(cfg.DBConnection, migrations.Files, cfg.Args[1:]); err != nil {
+			slog.Error("Error running migrations", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if err := migrate.OnStart(cfg.DBConnection, migrations.Files, cfg.MigrateOnStart); err != nil {
+		slog.Error("Error applying schema migrations", "error", err)
+		os.Exit(1)
+	}
 
-	// Profile management endpoints
-	router.HandleFunc("/api/v1/user/create", profile.CreateUser).Methods("POST") // No auth needed
-	router.HandleFunc("/api/v1/user/getUser", profile.GetUserByID).Methods("GET")
+	db, err := profile.OpenDB(cfg.DBConnection)
+	if err != nil {
+		slog.Error("Error opening user database", "error", err)
+		os.Exit(1)
+	}
 
-	// JWT Authentication Logic
-	authenticated := router.NewRoute().Subrouter()
-	authenticated.HandleFunc("/api/v1/user/getAllUser", profile.GetAllUser).Methods("GET").Handler(authenticateMiddleware([]string{"Admin"})(http.HandlerFunc(profile.GetAllUser)))
-	authenticated.HandleFunc("/api/v1/user/getAUserFESResults", profile.CallFESForActionableInsights).Methods("GET").Handler(authenticateMiddleware([]string{"User"})(http.HandlerFunc(profile.CallFESForActionableInsights)))
-	authenticated.HandleFunc("/api/v1/user/getAUserTestResults", profile.CallSelfAssessmentForInsights).Methods("GET").Handler(authenticateMiddleware([]string{"User"})(http.HandlerFunc(profile.CallSelfAssessmentForInsights)))
-	authenticated.HandleFunc("/api/v1/user/sendVoucherEmail", profile.ProcessVoucherEmail).Methods("POST").Handler(authenticateMiddleware([]string{"User"})(http.HandlerFunc(profile.ProcessVoucherEmail)))
+	// The clients are created after the configuration is loaded, which may set the service URLs
+	h := profile.NewHandler(profile.NewMySQLStore(db), profile.NewClients())
 
-	//
+	// Ready while the database and the services called downstream are reachable
+	health := observability.NewHealth()
+	health.AddCheck("database", observability.PingDB(db))
+	for _, service := range []string{sharedconfig.AdminService, sharedconfig.FallsEfficacyService, sharedconfig.OpenAIService, sharedconfig.SelfAssessmentService} {
+		health.AddCheck(service, client.New(service).Ping)
+	}
 
-	// Add CORS support
-	corsHandler := handlers.CORS(
-		handlers.AllowedOrigins([]string{"http://18.143.103.158:5100"}),         // Update for allowed origins
-		handlers.AllowedMethods([]string{"GET", "POST", "PUT", "OPTIONS"}), // Update for allowed HTTP methods
-		handlers.AllowedHeaders([]string{"Content-Type", "Authorization"}), // Include Authorization header
-	)(router)
+	// Route the endpoints through their middleware
+	router := server.NewRouter(h, health)
 
-	// Start the server
-	log.Println("User Microservice is running on port 5100...")
-	log.Fatal(http.ListenAndServe(":5100", corsHandler))
+	// Serve until SIGTERM, then drain the requests in flight, close the database and flush the spans
+	slog.Info("User Microservice is running", "port", cfg.Port)
+	if err := graceful.Serve(fmt.Sprintf(":%d", cfg.Port), router, graceful.CloseFunc(db.Close), shutdownTracing); err != nil {
+		slog.Error("Server stopped", "error", err)
+		os.Exit(1)
+	}
 }
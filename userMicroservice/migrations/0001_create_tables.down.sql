@@ -0,0 +1,3 @@
+-- Drops the tables of FallSafe_UserDB
+
+DROP TABLE IF EXISTS User;
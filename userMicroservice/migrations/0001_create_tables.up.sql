@@ -0,0 +1,13 @@
+-- Creates the tables of FallSafe_UserDB
+
+-- Create the User table
+-- PURPOSE: Stores user profile details
+CREATE TABLE User (
+    user_id SMALLINT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT,  -- Unique ID for the user
+    name VARCHAR(100) NOT NULL,                                      -- User's name
+    email VARCHAR(100) NOT NULL UNIQUE,                              -- User's email address
+    age TINYINT UNSIGNED,                                            -- User's age
+    address TEXT,                                                    -- User's address
+    phone_number VARCHAR(15),                                        -- User's phone number
+    INDEX idx_email (email)                                          -- Index for email lookups
+);
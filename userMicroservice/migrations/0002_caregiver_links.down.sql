@@ -0,0 +1,3 @@
+-- Removes the links between seniors and caregivers
+
+DROP TABLE IF EXISTS CaregiverLink;
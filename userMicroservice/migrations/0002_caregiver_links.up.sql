@@ -0,0 +1,22 @@
+-- Adds the links between seniors and the caregivers they share their data with
+
+-- Create the CaregiverLink table
+-- PURPOSE: Records which caregivers a senior shares data with, and what they consented to share
+CREATE TABLE CaregiverLink (
+    link_id INT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT,        -- Unique ID for the link
+    senior_user_id SMALLINT UNSIGNED NOT NULL,                       -- Senior sharing their data
+    caregiver_email VARCHAR(100) NOT NULL,                           -- Email the invitation was sent to
+    caregiver_id SMALLINT UNSIGNED DEFAULT NULL,                     -- Caregiver account, set once the invitation is accepted
+    relationship VARCHAR(50) DEFAULT NULL,                           -- e.g. Daughter, Neighbour, Nurse
+    can_view_results BOOLEAN NOT NULL DEFAULT FALSE,                 -- Consent to share FES and self-assessment results
+    can_view_reminders BOOLEAN NOT NULL DEFAULT FALSE,               -- Consent to share assessment reminders
+    can_view_insights BOOLEAN NOT NULL DEFAULT FALSE,                -- Consent to share AI insights
+    status ENUM('Pending', 'Active', 'Revoked') NOT NULL DEFAULT 'Pending', -- Link lifecycle
+    invite_token VARCHAR(64) DEFAULT NULL,                           -- Invitation token (SHA-256)
+    invited_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,                  -- When the invitation was (last) sent
+    accepted_at TIMESTAMP NULL DEFAULT NULL,                         -- When the caregiver accepted
+    revoked_at TIMESTAMP NULL DEFAULT NULL,                          -- When the senior revoked access
+    UNIQUE KEY uq_senior_caregiver (senior_user_id, caregiver_email), -- One link per senior and caregiver
+    INDEX idx_caregiver_id (caregiver_id),                           -- Index for caregiver lookups
+    FOREIGN KEY (senior_user_id) REFERENCES User(user_id) ON DELETE CASCADE
+);
@@ -0,0 +1,4 @@
+-- Removes the combined fall-risk model
+
+DROP TABLE IF EXISTS CombinedRisk;
+DROP TABLE IF EXISTS RiskObservation;
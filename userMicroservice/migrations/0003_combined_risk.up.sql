@@ -0,0 +1,26 @@
+-- Adds the combined fall-risk model's observations and tiers
+
+-- Create the RiskObservation table
+-- PURPOSE: Stores the FES and self-assessment scores sent for the combined risk model
+CREATE TABLE RiskObservation (
+    observation_id INT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT, -- Unique ID for the observation
+    user_id SMALLINT UNSIGNED NOT NULL,                              -- Senior the score belongs to
+    source ENUM('FES', 'SelfAssessment') NOT NULL,                   -- Microservice the score came from
+    source_id INT UNSIGNED NOT NULL,                                 -- FES response or self-assessment session
+    score DECIMAL(5,2) NOT NULL,                                     -- FES total (16-64) or session score (0-100)
+    observed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,                 -- When the result was first received
+    UNIQUE KEY uq_source (source, source_id),                        -- A session scored again replaces its earlier score
+    INDEX idx_user_observed (user_id, observed_at),                  -- Index for a senior's latest scores
+    FOREIGN KEY (user_id) REFERENCES User(user_id) ON DELETE CASCADE
+);
+
+-- Create the CombinedRisk table
+-- PURPOSE: Stores each senior's overall fall-risk tier, recomputed on every new observation
+CREATE TABLE CombinedRisk (
+    user_id SMALLINT UNSIGNED NOT NULL PRIMARY KEY,                  -- Senior the risk belongs to
+    tier ENUM('Low', 'Moderate', 'High') NOT NULL,                   -- Combined risk tier
+    points TINYINT UNSIGNED NOT NULL,                                -- Sum of the contributing factors' points
+    factors JSON NOT NULL,                                           -- Contributing factors, as shown to users and admins
+    computed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,                 -- When the risk was last recomputed
+    FOREIGN KEY (user_id) REFERENCES User(user_id) ON DELETE CASCADE
+);
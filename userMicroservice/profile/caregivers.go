@@ -0,0 +1,576 @@
+package profile
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"userMicroservice/config"
+
+	"shared/apierror"
+	"shared/client"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// contextKey is used to store values on the request context
+type contextKey string
+
+// ClaimsContextKey holds the validated JWT claims set by the authentication middleware
+const ClaimsContextKey contextKey = "claims"
+
+// Consent scopes a senior can grant to a caregiver
+const (
+	ScopeResults   = "results"   // FES and self-assessment results
+	ScopeReminders = "reminders" // Assessment reminders and last assessment dates
+	ScopeInsights  = "insights"  // AI-generated actionable insights
+)
+
+// Caregiver link statuses
+const (
+	LinkPending = "Pending"
+	LinkActive  = "Active"
+	LinkRevoked = "Revoked"
+)
+
+// CaregiverLink represents a senior's consent for a caregiver to see their data
+type CaregiverLink struct {
+	LinkID         int        `json:"link_id"`
+	SeniorUserID   int        `json:"senior_user_id"`
+	SeniorName     string     `json:"senior_name,omitempty"`
+	CaregiverEmail string     `json:"caregiver_email"`
+	CaregiverID    *int       `json:"caregiver_id"`
+	Relationship   string     `json:"relationship"`
+	Scopes         []string   `json:"scopes"`
+	Status         string     `json:"status"`
+	InvitedAt      time.Time  `json:"invited_at"`
+	AcceptedAt     *time.Time `json:"accepted_at"`
+}
+
+// claimedID returns the user_id claim of the caller's token
+func claimedID(r *http.Request) (int, bool) {
+	claims, ok := r.Context().Value(ClaimsContextKey).(jwt.MapClaims)
+	if !ok {
+		return 0, false
+	}
+	id, ok := claims["user_id"].(float64)
+	return int(id), ok
+}
+
+// claimedEmail returns the email claim of the caller's token
+func claimedEmail(r *http.Request) string {
+	claims, _ := r.Context().Value(ClaimsContextKey).(jwt.MapClaims)
+	email, _ := claims["email"].(string)
+	return email
+}
+
+// scopeColumns maps each consent scope to its column in the CaregiverLink table
+var scopeColumns = map[string]string{
+	ScopeResults:   "can_view_results",
+	ScopeReminders: "can_view_reminders",
+	ScopeInsights:  "can_view_insights",
+}
+
+// scopeFlags converts a list of scopes into the three consent columns, rejecting unknown scopes
+func scopeFlags(scopes []string) (results, reminders, insights bool, err error) {
+	for _, scope := range scopes {
+		switch scope {
+		case ScopeResults:
+			results = true
+		case ScopeReminders:
+			reminders = true
+		case ScopeInsights:
+			insights = true
+		default:
+			return false, false, false, fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+	return results, reminders, insights, nil
+}
+
+// scopeList converts the three consent columns back into a list of scopes
+func scopeList(results, reminders, insights bool) []string {
+	scopes := []string{}
+	if results {
+		scopes = append(scopes, ScopeResults)
+	}
+	if reminders {
+		scopes = append(scopes, ScopeReminders)
+	}
+	if insights {
+		scopes = append(scopes, ScopeInsights)
+	}
+	return scopes
+}
+
+// InviteCaregiverRequest is the body a senior sends to invite a caregiver
+type InviteCaregiverRequest struct {
+	CaregiverEmail string   `json:"caregiver_email"`
+	Relationship   string   `json:"relationship"`
+	Scopes         []string `json:"scopes"`
+}
+
+// InviteCaregiver records the senior's consent and emails the caregiver an invitation token
+func (h *Handler) InviteCaregiver(w http.ResponseWriter, r *http.Request) {
+	seniorID, ok := claimedID(r)
+	if !ok {
+		apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+		return
+	}
+
+	var req InviteCaregiverRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidBody, "Invalid input")
+		return
+	}
+	req.CaregiverEmail = strings.ToLower(strings.TrimSpace(req.CaregiverEmail))
+	if req.CaregiverEmail == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "caregiver_email is required")
+		return
+	}
+	if strings.EqualFold(req.CaregiverEmail, claimedEmail(r)) {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidField, "You cannot invite yourself as a caregiver")
+		return
+	}
+	if _, _, _, err := scopeFlags(req.Scopes); err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidField, err.Error())
+		return
+	}
+
+	token, tokenHash, err := generateCaregiverToken()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error generating caregiver token", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	// Re-inviting the same caregiver refreshes the consent and the token
+	err = h.store.SaveCaregiverInvite(r.Context(), CaregiverInvite{
+		SeniorID:       seniorID,
+		CaregiverEmail: req.CaregiverEmail,
+		Relationship:   req.Relationship,
+		Scopes:         req.Scopes,
+		TokenHash:      tokenHash,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error saving caregiver link", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Failed to invite caregiver")
+		return
+	}
+
+	senior, err := h.store.User(r.Context(), seniorID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error fetching senior name", "error", err)
+	}
+
+	if err := deliverCaregiverInvite(req.CaregiverEmail, senior.Name, token); err != nil {
+		slog.ErrorContext(r.Context(), "Error sending caregiver invitation", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Failed to send caregiver invitation")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write([]byte(`{"message": "Caregiver invited successfully"}`))
+}
+
+// GetCaregivers lists the caregivers the calling senior has invited, including revoked ones
+func (h *Handler) GetCaregivers(w http.ResponseWriter, r *http.Request) {
+	seniorID, ok := claimedID(r)
+	if !ok {
+		apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+		return
+	}
+
+	links, err := h.store.SeniorCaregiverLinks(r.Context(), seniorID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying caregiver links", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(links)
+}
+
+// updateCaregiverLinkRequest is the body for revoking or changing consent on a link
+type updateCaregiverLinkRequest struct {
+	LinkID int      `json:"link_id"`
+	Scopes []string `json:"scopes"`
+}
+
+// RevokeCaregiver withdraws a caregiver's access immediately
+func (h *Handler) RevokeCaregiver(w http.ResponseWriter, r *http.Request) {
+	seniorID, ok := claimedID(r)
+	if !ok {
+		apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+		return
+	}
+
+	var req updateCaregiverLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.LinkID == 0 {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "link_id is required")
+		return
+	}
+
+	found, err := h.store.RevokeCaregiver(r.Context(), req.LinkID, seniorID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error revoking caregiver link", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+	if !found {
+		apierror.Write(w, r, http.StatusNotFound, apierror.NotFound, "Caregiver not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"message": "Caregiver access revoked"}`))
+}
+
+// UpdateCaregiverScopes changes what an invited or active caregiver may see
+func (h *Handler) UpdateCaregiverScopes(w http.ResponseWriter, r *http.Request) {
+	seniorID, ok := claimedID(r)
+	if !ok {
+		apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+		return
+	}
+
+	var req updateCaregiverLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.LinkID == 0 {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "link_id is required")
+		return
+	}
+	if _, _, _, err := scopeFlags(req.Scopes); err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidField, err.Error())
+		return
+	}
+
+	found, err := h.store.UpdateCaregiverScopes(r.Context(), req.LinkID, seniorID, req.Scopes)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error updating caregiver scopes", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+	if !found {
+		apierror.Write(w, r, http.StatusNotFound, apierror.NotFound, "Caregiver not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"message": "Caregiver access updated"}`))
+}
+
+// VerifyCaregiverInvite confirms that an invitation token was sent to the given email.
+// Called by the authentication microservice before creating a caregiver account.
+func (h *Handler) VerifyCaregiverInvite(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email string `json:"email"`
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidBody, "Invalid input")
+		return
+	}
+
+	valid, err := h.store.PendingInviteExists(r.Context(), strings.ToLower(strings.TrimSpace(req.Email)), hashCaregiverToken(req.Token))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error verifying caregiver invitation", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+	if !valid {
+		apierror.Write(w, r, http.StatusNotFound, apierror.NotFound, "Invalid invitation")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"message": "Invitation is valid"}`))
+}
+
+// AcceptCaregiverInvite links the calling caregiver account to the senior who invited it
+func (h *Handler) AcceptCaregiverInvite(w http.ResponseWriter, r *http.Request) {
+	caregiverID, ok := claimedID(r)
+	if !ok {
+		apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+		return
+	}
+
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "token is required")
+		return
+	}
+
+	// The invitation must have been sent to the email on the caregiver's account
+	accepted, err := h.store.AcceptCaregiverInvite(r.Context(), caregiverID, strings.ToLower(claimedEmail(r)), hashCaregiverToken(req.Token))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error accepting caregiver invitation", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+	if !accepted {
+		apierror.Write(w, r, http.StatusNotFound, apierror.NotFound, "Invalid invitation")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"message": "Invitation accepted"}`))
+}
+
+// GetCaregiverSeniors lists the seniors who currently share data with the calling caregiver
+func (h *Handler) GetCaregiverSeniors(w http.ResponseWriter, r *http.Request) {
+	caregiverID, ok := claimedID(r)
+	if !ok {
+		apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+		return
+	}
+
+	links, err := h.store.CaregiverSeniorLinks(r.Context(), caregiverID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying caregiver links", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(links)
+}
+
+// HasCaregiverAccess reports whether the caregiver holds an active link to the senior with the given scope
+func (h *Handler) HasCaregiverAccess(ctx context.Context, caregiverID, seniorID int, scope string) (bool, error) {
+	if _, ok := scopeColumns[scope]; !ok {
+		return false, fmt.Errorf("unknown scope %q", scope)
+	}
+
+	scopes, err := h.store.ActiveScopes(ctx, caregiverID, seniorID)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(scopes, scope), nil
+}
+
+// CheckCaregiverAccess answers consent checks from other microservices
+func (h *Handler) CheckCaregiverAccess(w http.ResponseWriter, r *http.Request) {
+	caregiverID, err1 := strconv.Atoi(r.URL.Query().Get("caregiver_id"))
+	seniorID, err2 := strconv.Atoi(r.URL.Query().Get("senior_id"))
+	scope := r.URL.Query().Get("scope")
+	if err1 != nil || err2 != nil || scope == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "caregiver_id, senior_id and scope are required")
+		return
+	}
+
+	granted, err := h.HasCaregiverAccess(r.Context(), caregiverID, seniorID, scope)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error checking caregiver access", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"granted": granted})
+}
+
+// GetReminderCaregivers returns the emails of caregivers who consented to receive a senior's reminders.
+// Called by the admin microservice when sending assessment reminders.
+func (h *Handler) GetReminderCaregivers(w http.ResponseWriter, r *http.Request) {
+	email := r.URL.Query().Get("email")
+	if email == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "email is required")
+		return
+	}
+
+	emails, err := h.store.ReminderCaregivers(r.Context(), email)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying reminder caregivers", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(emails)
+}
+
+// GetSeniorFESResults returns a linked senior's FES results to a caregiver,
+// with AI insights only if the senior has shared them
+func (h *Handler) GetSeniorFESResults(w http.ResponseWriter, r *http.Request) {
+	seniorID, ok := h.authorizeCaregiver(w, r, ScopeResults)
+	if !ok {
+		return
+	}
+
+	if allowed, _ := h.HasCaregiverAccessFromRequest(r, seniorID, ScopeInsights); allowed {
+		h.CallFESForActionableInsights(w, withUserID(r, seniorID))
+		return
+	}
+
+	fesResponses, err := h.clients.FES.Results(client.FromRequest(r), seniorID)
+	if err != nil {
+		client.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"fes_results": fesResponses})
+}
+
+// GetSeniorTestResults returns a linked senior's self-assessment results to a caregiver,
+// with AI insights only if the senior has shared them
+func (h *Handler) GetSeniorTestResults(w http.ResponseWriter, r *http.Request) {
+	seniorID, ok := h.authorizeCaregiver(w, r, ScopeResults)
+	if !ok {
+		return
+	}
+
+	if allowed, _ := h.HasCaregiverAccessFromRequest(r, seniorID, ScopeInsights); allowed {
+		h.CallSelfAssessmentForInsights(w, withUserID(r, seniorID))
+		return
+	}
+
+	sessions, err := h.clients.SelfAssessment.Sessions(client.FromRequest(r), seniorID)
+	if err != nil {
+		client.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"self_assessment_results": sessions})
+}
+
+// GetSeniorLastAssessment returns when a linked senior last completed the FES, for reminder follow-up
+func (h *Handler) GetSeniorLastAssessment(w http.ResponseWriter, r *http.Request) {
+	seniorID, ok := h.authorizeCaregiver(w, r, ScopeReminders)
+	if !ok {
+		return
+	}
+
+	lastAssessment, err := h.clients.FES.LastAssessment(client.FromRequest(r), seniorID)
+	if err != nil {
+		client.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lastAssessment)
+}
+
+// HasCaregiverAccessFromRequest checks the calling caregiver's consent for a senior and scope
+func (h *Handler) HasCaregiverAccessFromRequest(r *http.Request, seniorID int, scope string) (bool, error) {
+	caregiverID, ok := claimedID(r)
+	if !ok {
+		return false, nil
+	}
+	return h.HasCaregiverAccess(r.Context(), caregiverID, seniorID, scope)
+}
+
+// authorizeCaregiver reads senior_id and checks the caller's consent for the scope,
+// writing an error response and returning false when access is not granted
+func (h *Handler) authorizeCaregiver(w http.ResponseWriter, r *http.Request, scope string) (int, bool) {
+	seniorID, err := strconv.Atoi(r.URL.Query().Get("senior_id"))
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "senior_id is required")
+		return 0, false
+	}
+
+	allowed, err := h.HasCaregiverAccessFromRequest(r, seniorID, scope)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error checking caregiver access", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return 0, false
+	}
+	if !allowed {
+		apierror.Write(w, r, http.StatusForbidden, apierror.Forbidden, "Forbidden")
+		return 0, false
+	}
+	return seniorID, true
+}
+
+// withUserID returns a copy of the request whose user_id query parameter is the senior's ID,
+// so the senior-facing handlers can be reused for caregivers
+func withUserID(r *http.Request, seniorID int) *http.Request {
+	clone := r.Clone(r.Context())
+	query := url.Values{}
+	query.Set("user_id", strconv.Itoa(seniorID))
+	clone.URL.RawQuery = query.Encode()
+	return clone
+}
+
+// generateCaregiverToken returns a random invitation token and the hash stored in its place
+func generateCaregiverToken() (string, string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	token := hex.EncodeToString(raw)
+	return token, hashCaregiverToken(token), nil
+}
+
+// hashCaregiverToken hashes an invitation token for storage and lookup
+func hashCaregiverToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// deliverCaregiverInvite emails a caregiver the token they use to accept a senior's invitation
+func deliverCaregiverInvite(to, seniorName, token string) error {
+	mail := config.Current().SMTP
+	smtpHost, smtpPort := mail.Host, mail.Port
+	smtpUser, smtpPassword := mail.User, mail.Password
+
+	// Validate SMTP configuration
+	if smtpUser == "" || smtpPassword == "" {
+		slog.Error("SMTP credentials are missing")
+		return fmt.Errorf("SMTP credentials are missing")
+	}
+
+	if seniorName == "" {
+		seniorName = "A FallSafe user"
+	}
+
+	fromEmail := fmt.Sprintf("FallSafe <%s>", smtpUser)
+	body := fmt.Sprintf(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>FallSafe Caregiver Invitation</title>
+</head>
+<body style="font-family: Arial, sans-serif;">
+    <div style="padding: 20px; max-width: 600px; margin: auto; background-color: #f7f7f7; border-radius: 10px;">
+        <h1 style="color: #003399;">You have been invited as a caregiver</h1>
+        <p>%s would like to share their FallSafe progress with you.</p>
+        <p>Sign up or log in as a caregiver on FallSafe and enter this invitation token:</p>
+        <p style="font-weight: bold; color: #003399; word-break: break-all;">%s</p>
+        <p>If you were not expecting this email, please ignore it.</p>
+        <p>Best regards,</p>
+        <p>The FallSafe Team</p>
+    </div>
+</body>
+</html>`, seniorName, token)
+
+	message := fmt.Sprintf(
+		"From: %s\r\nTo: %s\r\nSubject: FallSafe Caregiver Invitation\r\nMIME-Version: 1.0\r\nContent-Type: text/html; charset=UTF-8\r\n\r\n%s",
+		fromEmail, to, body)
+
+	auth := smtp.PlainAuth("", smtpUser, smtpPassword, smtpHost)
+	err := smtp.SendMail(smtpHost+":"+smtpPort, auth, smtpUser, []string{to}, []byte(message))
+	if err != nil {
+		slog.Error("[ERROR] Failed to send caregiver invitation", "error", err)
+		return err
+	}
+
+	slog.Debug("Caregiver invitation sent successfully")
+	return nil
+}
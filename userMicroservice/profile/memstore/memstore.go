@@ -0,0 +1,432 @@
+// Package memstore keeps the profile data in memory, for testing the profile handlers
+// with httptest without a database:
+//
+//	store := memstore.New()
+//	store.SaveUser(ctx, profile.User{UserID: 1, Name: "Tan Ah Kow", Email: "ahkow@example.com", Age: "72"})
+//	referrals := &memstore.ReferralRecorder{}
+//	handler := profile.NewHandler(store, profile.Clients{Admin: referrals})
+//	handler.RecordRiskObservation(recorder, request)
+package memstore
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"userMicroservice/profile"
+
+	"shared/pagination"
+)
+
+// Store is a profile.UserStore held in memory. It enforces the unique keys and references the
+// database has, and rounds scores to the two decimals their column keeps.
+type Store struct {
+	mu           sync.Mutex
+	users        []profile.User
+	links        []link
+	observations []observation
+	risks        map[int]profile.CombinedRisk
+
+	// Now is the clock invitations and observations are dated with, time.Now unless a test replaces it
+	Now func() time.Time
+}
+
+type link struct {
+	profile.CaregiverLink
+	tokenHash string
+}
+
+type observation struct {
+	id int
+	profile.RiskObservation
+	observedAt time.Time
+}
+
+var _ profile.UserStore = (*Store)(nil)
+
+// New returns an empty store
+func New() *Store {
+	return &Store{risks: map[int]profile.CombinedRisk{}, Now: time.Now}
+}
+
+func (s *Store) SaveUser(ctx context.Context, user profile.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, found := range s.users {
+		if (found.Email == user.Email) != (found.UserID == user.UserID) {
+			return profile.ErrConflict
+		}
+		if found.UserID == user.UserID {
+			s.users[i] = user
+			return nil
+		}
+	}
+	s.users = append(s.users, user)
+	return nil
+}
+
+func (s *Store) User(ctx context.Context, userID int) (profile.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.user(userID)
+	if !ok {
+		return profile.User{}, profile.ErrNotFound
+	}
+	return user, nil
+}
+
+// user returns the profile with the ID. The caller holds the lock.
+func (s *Store) user(userID int) (profile.User, bool) {
+	for _, found := range s.users {
+		if found.UserID == userID {
+			return found, true
+		}
+	}
+	return profile.User{}, false
+}
+
+func (s *Store) Users(ctx context.Context, page *pagination.Query) ([]profile.UserNameAge, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var matching []profile.User
+	for _, found := range s.users {
+		if page.Matches(time.Time{}, found.UserID) {
+			matching = append(matching, found)
+		}
+	}
+	slices.SortStableFunc(matching, func(a, b profile.User) int {
+		var c int
+		switch page.Sort {
+		case "name":
+			c = strings.Compare(a.Name, b.Name)
+		case "email":
+			c = strings.Compare(a.Email, b.Email)
+		case "age":
+			c = cmp.Compare(ageOrNone(a), ageOrNone(b))
+		}
+		c = cmp.Or(c, cmp.Compare(a.UserID, b.UserID))
+		if page.Descending {
+			return -c
+		}
+		return c
+	})
+
+	users := []profile.UserNameAge{}
+	for _, found := range pagination.Page(page, matching) {
+		users = append(users, profile.UserNameAge{UserID: found.UserID, Name: found.Name, Email: found.Email, Age: found.Age})
+	}
+	return users, len(matching), nil
+}
+
+// ageOrNone returns a profile's age, sorting profiles without one first as NULL does in MySQL
+func ageOrNone(user profile.User) int {
+	age, err := strconv.Atoi(user.Age)
+	if err != nil {
+		return -1
+	}
+	return age
+}
+
+func (s *Store) SaveCaregiverInvite(ctx context.Context, invite profile.CaregiverInvite) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.user(invite.SeniorID); !ok {
+		return fmt.Errorf("senior %d does not exist", invite.SeniorID)
+	}
+	scopes, err := normalizeScopes(invite.Scopes)
+	if err != nil {
+		return err
+	}
+
+	for i := range s.links {
+		found := &s.links[i]
+		if found.SeniorUserID == invite.SeniorID && found.CaregiverEmail == invite.CaregiverEmail {
+			found.Relationship = invite.Relationship
+			found.Scopes = scopes
+			if found.Status != profile.LinkActive {
+				found.Status = profile.LinkPending
+			}
+			found.tokenHash = invite.TokenHash
+			found.InvitedAt = s.now()
+			return nil
+		}
+	}
+	s.links = append(s.links, link{
+		CaregiverLink: profile.CaregiverLink{
+			LinkID:         len(s.links) + 1,
+			SeniorUserID:   invite.SeniorID,
+			CaregiverEmail: invite.CaregiverEmail,
+			Relationship:   invite.Relationship,
+			Scopes:         scopes,
+			Status:         profile.LinkPending,
+			InvitedAt:      s.now(),
+		},
+		tokenHash: invite.TokenHash,
+	})
+	return nil
+}
+
+// normalizeScopes returns the scopes in the order the database returns them, rejecting unknown ones
+func normalizeScopes(scopes []string) ([]string, error) {
+	normalized := []string{}
+	for _, scope := range []string{profile.ScopeResults, profile.ScopeReminders, profile.ScopeInsights} {
+		if slices.Contains(scopes, scope) {
+			normalized = append(normalized, scope)
+		}
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(normalized, scope) {
+			return nil, fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+	return normalized, nil
+}
+
+func (s *Store) SeniorCaregiverLinks(ctx context.Context, seniorID int) ([]profile.CaregiverLink, error) {
+	return s.caregiverLinks(func(found link) bool {
+		return found.SeniorUserID == seniorID
+	}), nil
+}
+
+func (s *Store) CaregiverSeniorLinks(ctx context.Context, caregiverID int) ([]profile.CaregiverLink, error) {
+	return s.caregiverLinks(func(found link) bool {
+		return found.CaregiverID != nil && *found.CaregiverID == caregiverID && found.Status == profile.LinkActive
+	}), nil
+}
+
+// caregiverLinks returns copies of the links matching the condition, with the senior's current name
+func (s *Store) caregiverLinks(matches func(link) bool) []profile.CaregiverLink {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	links := []profile.CaregiverLink{}
+	for _, found := range s.links {
+		if !matches(found) {
+			continue
+		}
+		copied := found.CaregiverLink
+		senior, _ := s.user(found.SeniorUserID)
+		copied.SeniorName = senior.Name
+		copied.Scopes = slices.Clone(found.Scopes)
+		links = append(links, copied)
+	}
+	return links
+}
+
+func (s *Store) RevokeCaregiver(ctx context.Context, linkID, seniorID int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found := s.link(linkID, seniorID)
+	if found == nil {
+		return false, nil
+	}
+	found.Status = profile.LinkRevoked
+	found.tokenHash = ""
+	return true, nil
+}
+
+func (s *Store) UpdateCaregiverScopes(ctx context.Context, linkID, seniorID int, scopes []string) (bool, error) {
+	normalized, err := normalizeScopes(scopes)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found := s.link(linkID, seniorID)
+	if found == nil || found.Status == profile.LinkRevoked {
+		return false, nil
+	}
+	found.Scopes = normalized
+	return true, nil
+}
+
+// link returns the senior's link with the ID, or nil if they have none. The caller holds the lock.
+func (s *Store) link(linkID, seniorID int) *link {
+	for i := range s.links {
+		if s.links[i].LinkID == linkID && s.links[i].SeniorUserID == seniorID {
+			return &s.links[i]
+		}
+	}
+	return nil
+}
+
+func (s *Store) PendingInviteExists(ctx context.Context, email, tokenHash string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pendingInvite(email, tokenHash) != nil, nil
+}
+
+func (s *Store) AcceptCaregiverInvite(ctx context.Context, caregiverID int, email, tokenHash string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found := s.pendingInvite(email, tokenHash)
+	if found == nil {
+		return false, nil
+	}
+	acceptedAt := s.now()
+	found.CaregiverID = &caregiverID
+	found.Status = profile.LinkActive
+	found.tokenHash = ""
+	found.AcceptedAt = &acceptedAt
+	return true, nil
+}
+
+// pendingInvite returns the pending link with the token hash sent to the email, or nil. The caller holds the lock.
+func (s *Store) pendingInvite(email, tokenHash string) *link {
+	for i := range s.links {
+		found := &s.links[i]
+		if found.CaregiverEmail == email && found.tokenHash != "" && found.tokenHash == tokenHash && found.Status == profile.LinkPending {
+			return found
+		}
+	}
+	return nil
+}
+
+func (s *Store) ActiveScopes(ctx context.Context, caregiverID, seniorID int) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, found := range s.links {
+		if found.CaregiverID != nil && *found.CaregiverID == caregiverID && found.SeniorUserID == seniorID && found.Status == profile.LinkActive {
+			return slices.Clone(found.Scopes), nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *Store) ReminderCaregivers(ctx context.Context, seniorEmail string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	emails := []string{}
+	for _, found := range s.links {
+		senior, _ := s.user(found.SeniorUserID)
+		if senior.Email == seniorEmail && found.Status == profile.LinkActive && slices.Contains(found.Scopes, profile.ScopeReminders) {
+			emails = append(emails, found.CaregiverEmail)
+		}
+	}
+	return emails, nil
+}
+
+func (s *Store) SaveRiskObservation(ctx context.Context, saved profile.RiskObservation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.user(saved.UserID); !ok {
+		return fmt.Errorf("user %d does not exist", saved.UserID)
+	}
+	saved.Score = float64(int(saved.Score*100+0.5)) / 100
+
+	for i := range s.observations {
+		found := &s.observations[i]
+		if found.Source == saved.Source && found.SourceID == saved.SourceID {
+			found.Score = saved.Score
+			return nil
+		}
+	}
+	s.observations = append(s.observations, observation{id: len(s.observations) + 1, RiskObservation: saved, observedAt: s.now()})
+	return nil
+}
+
+func (s *Store) RiskInputs(ctx context.Context, userID int) (profile.RiskInputs, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var in profile.RiskInputs
+	if user, ok := s.user(userID); ok {
+		if age, err := strconv.Atoi(user.Age); err == nil {
+			in.Age.Int64, in.Age.Valid = int64(age), true
+		}
+	}
+
+	latest := slices.Clone(s.observations)
+	slices.SortFunc(latest, func(a, b observation) int {
+		return cmp.Or(b.observedAt.Compare(a.observedAt), cmp.Compare(b.id, a.id))
+	})
+	for _, found := range latest {
+		switch {
+		case found.UserID != userID:
+		case found.Source == profile.SourceFES && len(in.FESScores) < 2:
+			in.FESScores = append(in.FESScores, found.Score)
+		case found.Source == profile.SourceSelfAssessment && len(in.SelfAssessmentScores) < 2:
+			in.SelfAssessmentScores = append(in.SelfAssessmentScores, found.Score)
+		}
+	}
+	return in, nil
+}
+
+func (s *Store) SaveCombinedRisk(ctx context.Context, risk profile.CombinedRisk) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.user(risk.UserID); !ok {
+		return fmt.Errorf("user %d does not exist", risk.UserID)
+	}
+	risk.Factors = slices.Clone(risk.Factors)
+	s.risks[risk.UserID] = risk
+	return nil
+}
+
+func (s *Store) CombinedRisks(ctx context.Context, userID *int) ([]profile.CombinedRisk, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	risks := []profile.CombinedRisk{}
+	for _, risk := range s.risks {
+		if userID == nil || *userID == risk.UserID {
+			risk.Factors = slices.Clone(risk.Factors)
+			risks = append(risks, risk)
+		}
+	}
+	slices.SortFunc(risks, func(a, b profile.CombinedRisk) int {
+		return cmp.Or(cmp.Compare(b.Points, a.Points), cmp.Compare(a.UserID, b.UserID))
+	})
+	return risks, nil
+}
+
+// now returns the time on the store's clock, to the second as the TIMESTAMP columns keep it
+func (s *Store) now() time.Time {
+	return s.Now().UTC().Truncate(time.Second)
+}
+
+// Referral is a clinical referral opened with a ReferralRecorder
+type Referral struct {
+	UserID int
+	Source string
+	Reason string
+}
+
+// ReferralRecorder is a profile.ReferralOpener that keeps the referrals opened with it
+type ReferralRecorder struct {
+	mu        sync.Mutex
+	referrals []Referral
+}
+
+var _ profile.ReferralOpener = (*ReferralRecorder)(nil)
+
+func (r *ReferralRecorder) OpenReferral(ctx context.Context, userID int, source, reason string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.referrals = append(r.referrals, Referral{UserID: userID, Source: source, Reason: reason})
+	return nil
+}
+
+// Referrals returns the referrals opened so far
+func (r *ReferralRecorder) Referrals() []Referral {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.referrals)
+}
@@ -0,0 +1,388 @@
+package profile
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"shared/observability"
+	"shared/pagination"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// sqlDateLayout is the format of dates cast to text by MySQL
+const sqlDateLayout = "2006-01-02 15:04:05"
+
+// OpenDB connects to the database named by a DSN and checks the connection
+func OpenDB(dsn string) (*sql.DB, error) {
+	if dsn == "" {
+		return nil, fmt.Errorf("USER_DB_CONNECTION environment variable is not set")
+	}
+
+	slog.Debug("Initializing database connection")
+	db, err := observability.OpenDB("mysql", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to database: %v", err)
+	}
+
+	// Test the database connection
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("database connection test failed: %v", err)
+	}
+	slog.Info("Database connection successful")
+	return db, nil
+}
+
+// MySQLStore is the UserStore kept in the user database
+type MySQLStore struct {
+	db *sql.DB
+}
+
+// NewMySQLStore returns a store reading and writing the given database
+func NewMySQLStore(db *sql.DB) *MySQLStore {
+	return &MySQLStore{db: db}
+}
+
+func (s *MySQLStore) SaveUser(ctx context.Context, user User) error {
+	// The email and ID must not already belong to a different profile
+	var conflicts int
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM User
+		WHERE (email = ? AND user_id <> ?) OR (user_id = ? AND email <> ?)`,
+		user.Email, user.UserID, user.UserID, user.Email).Scan(&conflicts)
+	if err != nil {
+		return fmt.Errorf("error checking user record: %v", err)
+	}
+	if conflicts > 0 {
+		return ErrConflict
+	}
+
+	// Insert the new user record, or refresh it if a retried registration already created it
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO User (user_id, name, email, phone_number, address, age)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+		name = VALUES(name), phone_number = VALUES(phone_number), address = VALUES(address), age = VALUES(age)`,
+		user.UserID, user.Name, user.Email, user.PhoneNumber, user.Address, user.Age,
+	)
+	return err
+}
+
+func (s *MySQLStore) User(ctx context.Context, userID int) (User, error) {
+	var user User
+	err := s.db.QueryRowContext(ctx, `
+		SELECT user_id, name, email, phone_number, address, age
+		FROM User
+		WHERE user_id = ?`, userID).Scan(
+		&user.UserID, &user.Name, &user.Email, &user.PhoneNumber, &user.Address, &user.Age,
+	)
+	if err == sql.ErrNoRows {
+		return user, ErrNotFound
+	}
+	return user, err
+}
+
+func (s *MySQLStore) Users(ctx context.Context, page *pagination.Query) ([]UserNameAge, int, error) {
+	where, args := page.Where()
+
+	// Count every matching user, for the total across pages
+	var total int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM User`+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("error counting users: %v", err)
+	}
+
+	clauses, pageArgs := page.Page()
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT user_id, name, email, age`+page.KeyColumns()+`
+		FROM User`+clauses, pageArgs...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := []UserNameAge{}
+	for rows.Next() {
+		var user UserNameAge
+		if err := rows.Scan(append([]interface{}{&user.UserID, &user.Name, &user.Email, &user.Age}, page.Key()...)...); err != nil {
+			return nil, 0, err
+		}
+		users = append(users, user)
+	}
+	return users, total, rows.Err()
+}
+
+func (s *MySQLStore) SaveCaregiverInvite(ctx context.Context, invite CaregiverInvite) error {
+	canViewResults, canViewReminders, canViewInsights, err := scopeFlags(invite.Scopes)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO CaregiverLink (
+			senior_user_id, caregiver_email, relationship,
+			can_view_results, can_view_reminders, can_view_insights,
+			status, invite_token, invited_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, NOW())
+		ON DUPLICATE KEY UPDATE
+		relationship = VALUES(relationship),
+		can_view_results = VALUES(can_view_results),
+		can_view_reminders = VALUES(can_view_reminders),
+		can_view_insights = VALUES(can_view_insights),
+		status = IF(status = 'Active', 'Active', 'Pending'),
+		invite_token = VALUES(invite_token),
+		invited_at = VALUES(invited_at),
+		revoked_at = NULL`,
+		invite.SeniorID, invite.CaregiverEmail, invite.Relationship,
+		canViewResults, canViewReminders, canViewInsights,
+		LinkPending, invite.TokenHash)
+	return err
+}
+
+func (s *MySQLStore) SeniorCaregiverLinks(ctx context.Context, seniorID int) ([]CaregiverLink, error) {
+	return s.caregiverLinks(ctx, `WHERE cl.senior_user_id = ?`, seniorID)
+}
+
+func (s *MySQLStore) CaregiverSeniorLinks(ctx context.Context, caregiverID int) ([]CaregiverLink, error) {
+	return s.caregiverLinks(ctx, `WHERE cl.caregiver_id = ? AND cl.status = ?`, caregiverID, LinkActive)
+}
+
+// caregiverLinks selects caregiver links matching the given WHERE clause
+func (s *MySQLStore) caregiverLinks(ctx context.Context, where string, args ...interface{}) ([]CaregiverLink, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT cl.link_id, cl.senior_user_id, u.name, cl.caregiver_email, cl.caregiver_id,
+		       COALESCE(cl.relationship, ''), cl.can_view_results, cl.can_view_reminders, cl.can_view_insights,
+		       cl.status, CAST(cl.invited_at AS CHAR), CAST(cl.accepted_at AS CHAR)
+		FROM CaregiverLink cl
+		JOIN User u ON u.user_id = cl.senior_user_id
+		`+where+`
+		ORDER BY cl.link_id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []CaregiverLink{}
+	for rows.Next() {
+		var link CaregiverLink
+		var caregiverID sql.NullInt64
+		var canViewResults, canViewReminders, canViewInsights bool
+		var invitedAt string
+		var acceptedAt sql.NullString
+		if err := rows.Scan(
+			&link.LinkID, &link.SeniorUserID, &link.SeniorName, &link.CaregiverEmail, &caregiverID,
+			&link.Relationship, &canViewResults, &canViewReminders, &canViewInsights,
+			&link.Status, &invitedAt, &acceptedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		if caregiverID.Valid {
+			id := int(caregiverID.Int64)
+			link.CaregiverID = &id
+		}
+		link.Scopes = scopeList(canViewResults, canViewReminders, canViewInsights)
+		link.InvitedAt, _ = time.Parse(sqlDateLayout, invitedAt)
+		if acceptedAt.Valid {
+			accepted, err := time.Parse(sqlDateLayout, acceptedAt.String)
+			if err == nil {
+				link.AcceptedAt = &accepted
+			}
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+func (s *MySQLStore) RevokeCaregiver(ctx context.Context, linkID, seniorID int) (bool, error) {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE CaregiverLink
+		SET status = ?, invite_token = NULL, revoked_at = NOW()
+		WHERE link_id = ? AND senior_user_id = ?`,
+		LinkRevoked, linkID, seniorID)
+	if err != nil {
+		return false, err
+	}
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+func (s *MySQLStore) UpdateCaregiverScopes(ctx context.Context, linkID, seniorID int, scopes []string) (bool, error) {
+	canViewResults, canViewReminders, canViewInsights, err := scopeFlags(scopes)
+	if err != nil {
+		return false, err
+	}
+
+	// Matching rows count as affected even if their scopes are unchanged
+	var exists bool
+	err = s.db.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM CaregiverLink WHERE link_id = ? AND senior_user_id = ? AND status <> ?)`,
+		linkID, seniorID, LinkRevoked).Scan(&exists)
+	if err != nil || !exists {
+		return false, err
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		UPDATE CaregiverLink
+		SET can_view_results = ?, can_view_reminders = ?, can_view_insights = ?
+		WHERE link_id = ? AND senior_user_id = ? AND status <> ?`,
+		canViewResults, canViewReminders, canViewInsights, linkID, seniorID, LinkRevoked)
+	return err == nil, err
+}
+
+func (s *MySQLStore) PendingInviteExists(ctx context.Context, email, tokenHash string) (bool, error) {
+	var linkID int
+	err := s.db.QueryRowContext(ctx, `
+		SELECT link_id
+		FROM CaregiverLink
+		WHERE caregiver_email = ? AND invite_token = ? AND status = ?`,
+		email, tokenHash, LinkPending).Scan(&linkID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *MySQLStore) AcceptCaregiverInvite(ctx context.Context, caregiverID int, email, tokenHash string) (bool, error) {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE CaregiverLink
+		SET caregiver_id = ?, status = ?, invite_token = NULL, accepted_at = NOW()
+		WHERE invite_token = ? AND caregiver_email = ? AND status = ?`,
+		caregiverID, LinkActive, tokenHash, email, LinkPending)
+	if err != nil {
+		return false, err
+	}
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+func (s *MySQLStore) ActiveScopes(ctx context.Context, caregiverID, seniorID int) ([]string, error) {
+	var canViewResults, canViewReminders, canViewInsights bool
+	err := s.db.QueryRowContext(ctx, `
+		SELECT can_view_results, can_view_reminders, can_view_insights
+		FROM CaregiverLink
+		WHERE caregiver_id = ? AND senior_user_id = ? AND status = ?`,
+		caregiverID, seniorID, LinkActive).Scan(&canViewResults, &canViewReminders, &canViewInsights)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return scopeList(canViewResults, canViewReminders, canViewInsights), nil
+}
+
+func (s *MySQLStore) ReminderCaregivers(ctx context.Context, seniorEmail string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT cl.caregiver_email
+		FROM CaregiverLink cl
+		JOIN User u ON u.user_id = cl.senior_user_id
+		WHERE u.email = ? AND cl.status = ? AND cl.can_view_reminders = TRUE`, seniorEmail, LinkActive)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	emails := []string{}
+	for rows.Next() {
+		var caregiverEmail string
+		if err := rows.Scan(&caregiverEmail); err != nil {
+			return nil, err
+		}
+		emails = append(emails, caregiverEmail)
+	}
+	return emails, rows.Err()
+}
+
+func (s *MySQLStore) SaveRiskObservation(ctx context.Context, observation RiskObservation) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO RiskObservation (user_id, source, source_id, score)
+		VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE score = VALUES(score)`,
+		observation.UserID, observation.Source, observation.SourceID, observation.Score)
+	return err
+}
+
+func (s *MySQLStore) RiskInputs(ctx context.Context, userID int) (RiskInputs, error) {
+	var in RiskInputs
+	err := s.db.QueryRowContext(ctx, "SELECT age FROM User WHERE user_id = ?", userID).Scan(&in.Age)
+	if err != nil && err != sql.ErrNoRows {
+		return in, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT source, score
+		FROM RiskObservation
+		WHERE user_id = ?
+		ORDER BY observed_at DESC, observation_id DESC`, userID)
+	if err != nil {
+		return in, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var source string
+		var score float64
+		if err := rows.Scan(&source, &score); err != nil {
+			return in, err
+		}
+		switch {
+		case source == SourceFES && len(in.FESScores) < 2:
+			in.FESScores = append(in.FESScores, score)
+		case source == SourceSelfAssessment && len(in.SelfAssessmentScores) < 2:
+			in.SelfAssessmentScores = append(in.SelfAssessmentScores, score)
+		}
+	}
+	return in, rows.Err()
+}
+
+func (s *MySQLStore) SaveCombinedRisk(ctx context.Context, risk CombinedRisk) error {
+	factors, err := json.Marshal(risk.Factors)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO CombinedRisk (user_id, tier, points, factors, computed_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE tier = VALUES(tier), points = VALUES(points),
+			factors = VALUES(factors), computed_at = VALUES(computed_at)`,
+		risk.UserID, risk.Tier, risk.Points, factors, risk.ComputedAt)
+	return err
+}
+
+func (s *MySQLStore) CombinedRisks(ctx context.Context, userID *int) ([]CombinedRisk, error) {
+	query := `
+		SELECT user_id, tier, points, factors, CAST(computed_at AS CHAR)
+		FROM CombinedRisk`
+	var args []interface{}
+	if userID != nil {
+		query += ` WHERE user_id = ?`
+		args = append(args, *userID)
+	}
+	rows, err := s.db.QueryContext(ctx, query+` ORDER BY points DESC, user_id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	risks := []CombinedRisk{}
+	for rows.Next() {
+		var risk CombinedRisk
+		var factors []byte
+		var computedAt string
+		if err := rows.Scan(&risk.UserID, &risk.Tier, &risk.Points, &factors, &computedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(factors, &risk.Factors); err != nil {
+			return nil, err
+		}
+		risk.ComputedAt, _ = time.Parse(sqlDateLayout, computedAt)
+		risks = append(risks, risk)
+	}
+	return risks, rows.Err()
+}
@@ -2,53 +2,80 @@ package profile
 
 import (
 	"bytes"
-	"database/sql"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"mime/multipart"
 	"net/http"
 	"net/smtp"
-	"os"
-	"time"
-
-	_ "github.com/go-sql-driver/mysql"
-	"github.com/joho/godotenv"
+	"strconv"
+	"userMicroservice/client/admin"
+	"userMicroservice/client/fes"
+	"userMicroservice/client/openai"
+	"userMicroservice/client/selfassessment"
+	"userMicroservice/config"
+
+	"shared/apierror"
+	"shared/client"
+	"shared/pagination"
 )
 
-var db *sql.DB
+// FESClient reads a senior's results from the FES microservice
+type FESClient interface {
+	Results(ctx context.Context, userID int) ([]fes.Response, error)
+	LastAssessment(ctx context.Context, userID int) (fes.LastAssessment, error)
+}
 
-func init() {
-	// Load environment variables
-	err := godotenv.Load(".env")
-	if err != nil {
-		log.Fatalf("Error loading .env file: %v", err)
-	}
+// SelfAssessmentClient reads a senior's sessions from the self-assessment microservice
+type SelfAssessmentClient interface {
+	Sessions(ctx context.Context, userID int) ([]selfassessment.Session, error)
+}
 
-	// Initialize database connection
-	dbConnection := os.Getenv("USER_DB_CONNECTION")
-	if dbConnection == "" {
-		log.Fatalf("USER_DB_CONNECTION environment variable is not set")
-	}
+// InsightGenerator generates actionable insights with the OpenAI microservice
+type InsightGenerator interface {
+	GenerateResponse(ctx context.Context, prompt string) (openai.Response, error)
+}
 
-	log.Println("Initializing database connection...")
-	db, err = sql.Open("mysql", dbConnection)
-	if err != nil {
-		log.Fatalf("Error connecting to database: %v", err)
-	}
+// ReferralOpener opens clinical referrals for high-risk seniors with the admin microservice
+type ReferralOpener interface {
+	OpenReferral(ctx context.Context, userID int, source, reason string) error
+}
 
-	// Test the database connection
-	err = db.Ping()
-	if err != nil {
-		log.Fatalf("Database connection test failed: %v", err)
+// Clients are the microservices the insights and referrals are built with
+type Clients struct {
+	FES            FESClient
+	SelfAssessment SelfAssessmentClient
+	OpenAI         InsightGenerator
+	Admin          ReferralOpener
+}
+
+// NewClients returns the clients of the microservices at their configured URLs
+func NewClients() Clients {
+	return Clients{
+		FES:            fes.New(),
+		SelfAssessment: selfassessment.New(),
+		OpenAI:         openai.New(),
+		Admin:          admin.New(),
 	}
-	log.Println("Database connection successful.")
+}
+
+// Handler serves the profile, caregiver and combined risk endpoints from its store
+type Handler struct {
+	store   UserStore
+	clients Clients
+}
+
+// NewHandler returns the profile handlers, reading and writing store and calling the other microservices with clients
+func NewHandler(store UserStore, clients Clients) *Handler {
+	return &Handler{store: store, clients: clients}
 }
 
 // CreateUserRequest represents the structure of the request to create a new user
 type CreateUserRequest struct {
+	UserID      int    `json:"user_id"` // Matches the user_id in the authentication database
 	Name        string `json:"name"`
 	Email       string `json:"email"`
 	PhoneNumber string `json:"phone_number"`
@@ -57,29 +84,36 @@ type CreateUserRequest struct {
 }
 
 // CreateUser handles the creation of a new user record in the microservice database
-func CreateUser(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	var req CreateUserRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		log.Printf("Error decoding request body: %v", err)
-		http.Error(w, "Invalid input", http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "Error decoding request body", "error", err)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidBody, "Invalid input")
 		return
 	}
 
-	// Insert the new user record into the microservice database
-	_, err = db.Exec(`
-		INSERT INTO User (name, email, phone_number, address, age)
-		VALUES (?, ?, ?, ?, ?)`,
-		req.Name, req.Email, req.PhoneNumber, req.Address, req.Age,
-	)
-	if err != nil {
-		log.Printf("Error inserting user record: %v", err)
-		http.Error(w, "Failed to create user", http.StatusInternalServerError)
+	if req.UserID == 0 || req.Email == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "user_id and email are required")
 		return
 	}
 
+	// The email and ID must not already belong to a different profile
+	user := User{UserID: req.UserID, Name: req.Name, Email: req.Email, PhoneNumber: req.PhoneNumber, Address: req.Address, Age: req.Age}
+	err = h.store.SaveUser(r.Context(), user)
+	if err == ErrConflict {
+		slog.WarnContext(r.Context(), "Profile conflict creating user", "user_id", req.UserID)
+		apierror.Write(w, r, http.StatusConflict, apierror.AlreadyExists, "A different profile already uses this email or user ID")
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "Error saving user record", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Failed to create user")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	w.Write([]byte("User created successfully"))
+	w.Write([]byte(`{"message": "User created successfully"}`))
 }
 
 // User represents the structure of a user record
@@ -93,28 +127,27 @@ type User struct {
 }
 
 // GetUserByID handles retrieving a user record from the database by userID
-func GetUserByID(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetUserByID(w http.ResponseWriter, r *http.Request) {
 	// Extract userID from query parameters
-	userID := r.URL.Query().Get("userID")
-	if userID == "" {
-		http.Error(w, "userID is required", http.StatusBadRequest)
+	userIDStr := r.URL.Query().Get("userID")
+	if userIDStr == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "userID is required")
+		return
+	}
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidField, "Invalid userID")
 		return
 	}
 
-	// Prepare the query to fetch user details by userID
-	var user User
-	err := db.QueryRow(`
-		SELECT user_id, name, email, phone_number, address, age
-		FROM User
-		WHERE user_id = ?`, userID).Scan(
-		&user.UserID, &user.Name, &user.Email, &user.PhoneNumber, &user.Address, &user.Age,
-	)
-	if err == sql.ErrNoRows {
-		http.Error(w, "User not found", http.StatusNotFound)
+	// Fetch the user details by userID
+	user, err := h.store.User(r.Context(), userID)
+	if err == ErrNotFound {
+		apierror.Write(w, r, http.StatusNotFound, apierror.NotFound, "User not found")
 		return
 	} else if err != nil {
-		log.Printf("Error querying user record: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error querying user record", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
 		return
 	}
 
@@ -122,8 +155,8 @@ func GetUserByID(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	err = json.NewEncoder(w).Encode(user)
 	if err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error encoding response", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
 		return
 	}
 }
@@ -136,170 +169,81 @@ type UserNameAge struct {
 	Age    string `json:"age"`
 }
 
-// This function is used by admin in getting the whole list of elderly available
-func GetAllUser(w http.ResponseWriter, r *http.Request) {
-	var userList []UserNameAge
-	//Query to fetch all users (id, name, email and age)
-	rows, err := db.Query(`
-		SELECT user_id, name, email, age
-		FROM User`)
+// userListOptions are the sorts and filters of the list of seniors. Profiles have no date to filter on.
+var userListOptions = pagination.Options{
+	Sort: map[string]string{
+		"user_id": "user_id",
+		"name":    "name",
+		"email":   "email",
+		"age":     "age",
+	},
+	DefaultSort: "user_id",
+	IDColumn:    "user_id",
+	UserColumn:  "user_id",
+}
+
+// This function is used by admin in getting a page of the list of elderly available, see the pagination package for the query parameters
+func (h *Handler) GetAllUser(w http.ResponseWriter, r *http.Request) {
+	page, err := pagination.Parse(r, userListOptions)
 	if err != nil {
-		log.Printf("Error querying users: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidField, err.Error())
 		return
 	}
-	defer rows.Close()
-
-	//Iterate over the rows and scan the values into the users slice
-	for rows.Next() {
-		var user UserNameAge
-		if err := rows.Scan(&user.UserID, &user.Name, &user.Email, &user.Age); err != nil {
-			log.Printf("Error scanning user row: %v", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
-		userList = append(userList, user)
-	}
 
-	// Check if there was an error while iterating the rows
-	if err := rows.Err(); err != nil {
-		log.Printf("Error iterating over rows: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	userList, total, err := h.store.Users(r.Context(), page)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying users", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
 		return
 	}
 
-	// Respond with the list of users as JSON
+	// Respond with the page of users as JSON
+	page.WriteHeaders(w, total, len(userList))
 	w.Header().Set("Content-Type", "application/json")
 	err = json.NewEncoder(w).Encode(userList)
 	if err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error encoding response", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
 		return
 	}
 }
 
-// Struct representing the User Response attributes
-type UserResponse struct {
-	ResponseID      uint16               `json:"response_id"`                      // Unique ID for the response
-	UserID         uint16               `json:"user_id"`                          // Associated user ID
-	TotalScore     uint16               `json:"total_score"`                      // Total score across all questions
-	ResponseDate   time.Time            `json:"response_date" db:"response_date"` // Date of response submission
-	ResponseDetails []UserResponseDetail `json:"response_details"`                // List of question responses
-}
-
-type UserResponseDetail struct {
-	QuestionID    uint16 `json:"question_id"`    // ID of the question
-	ResponseScore uint8  `json:"response_score"` // Score given by the user
-}
-
-func CallFESForActionableInsights(w http.ResponseWriter, r *http.Request) {
+// CallFESForActionableInsights returns a senior's FES results with AI insights based on their latest score
+func (h *Handler) CallFESForActionableInsights(w http.ResponseWriter, r *http.Request) {
 	// Extract userID from the request query parameters
-	userID := r.URL.Query().Get("user_id")
-	if userID == "" {
-		log.Println("Error: user_id is missing in the request")
-		http.Error(w, "user_id is required", http.StatusBadRequest)
-		return
-	}
-
-	// Construct the API URL to fetch FES results
-	apiURL := fmt.Sprintf("http://18.143.103.158:5300/api/v1/fes/getFESResults?user_id=%s", userID)
-
-	// Extract the Authorization header from the incoming request
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		http.Error(w, "Authorization header missing", http.StatusUnauthorized)
-		return
-	}
-
-	// Create an HTTP GET request to fetch FES results
-	req, err := http.NewRequest("GET", apiURL, nil)
+	userID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
 	if err != nil {
-		log.Printf("Error creating request: %v", err)
-		http.Error(w, "Failed to create request", http.StatusInternalServerError)
+		slog.WarnContext(r.Context(), "Request is missing user_id")
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "user_id is required")
 		return
 	}
+	ctx := client.FromRequest(r)
 
-	// Set the Authorization header
-	req.Header.Set("Authorization", authHeader)
-
-	// Perform the request to the FES microservice
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	// Fetch the senior's FES results
+	fesResponses, err := h.clients.FES.Results(ctx, userID)
 	if err != nil {
-		log.Printf("Error making request: %v", err)
-		http.Error(w, "Failed to contact FES microservice", http.StatusInternalServerError)
+		client.WriteError(w, r, err)
 		return
 	}
-	defer resp.Body.Close()
-
-	// Check the status code of the response
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		http.Error(w, fmt.Sprintf("Microservice error: %s", string(body)), resp.StatusCode)
-		return
-	}
-
-	// Parse the response body
-	var fesResponses []UserResponse
-	err = json.NewDecoder(resp.Body).Decode(&fesResponses)
-	if err != nil {
-		log.Printf("Error decoding response: %v", err)
-		http.Error(w, "Failed to parse response from FES microservice", http.StatusInternalServerError)
+	if len(fesResponses) == 0 {
+		apierror.Write(w, r, http.StatusNotFound, apierror.NotFound, "No FES results found")
 		return
 	}
 
 	// Process FES results and generate a summary prompt
 	latestFES := fesResponses[len(fesResponses)-1] // Get the most recent response
 	summaryPrompt := fmt.Sprintf("Provide 5 actionable insights to reduce or maintain a good fall risk based on a total score of %d. Format the response exactly as: 1) ... <br> 2) ... <br> 3) ... <br> 4) ... <br> 5) ... Do not say 'certainly'. Use replace all ** with <strong> to bold key words and \\n with <br> for new lines. Be Concise", latestFES.TotalScore)
-	// Construct the AI request body
-	aiRequestBody, err := json.Marshal(map[string]string{
-		"prompt": summaryPrompt,
-	})
-	if err != nil {
-		log.Printf("Error encoding AI request: %v", err)
-		http.Error(w, "Failed to create AI request payload", http.StatusInternalServerError)
-		return
-	}
 
 	// Call the AI microservice for insights
-	aiAPIURL := "http://18.143.103.158:5150/api/v1/generateResponse"
-	aiReq, err := http.NewRequest("POST", aiAPIURL, bytes.NewBuffer(aiRequestBody))
-	if err != nil {
-		log.Printf("Error creating AI request: %v", err)
-		http.Error(w, "Failed to create AI request", http.StatusInternalServerError)
-		return
-	}
-	aiReq.Header.Set("Authorization", authHeader)
-	aiReq.Header.Set("Content-Type", "application/json")
-
-	// Execute the request to the AI service
-	aiResp, err := client.Do(aiReq)
-	if err != nil {
-		log.Printf("Error contacting AI service: %v", err)
-		http.Error(w, "Failed to contact AI service", http.StatusInternalServerError)
-		return
-	}
-	defer aiResp.Body.Close()
-
-	// Check AI response status
-	if aiResp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(aiResp.Body)
-		http.Error(w, fmt.Sprintf("AI service error: %s", string(body)), aiResp.StatusCode)
-		return
-	}
-
-	// Parse AI response
-	var aiResponse map[string]interface{}
-	err = json.NewDecoder(aiResp.Body).Decode(&aiResponse)
+	aiResponse, err := h.clients.OpenAI.GenerateResponse(ctx, summaryPrompt)
 	if err != nil {
-		log.Printf("Error decoding AI response: %v", err)
-		http.Error(w, "Failed to parse AI response", http.StatusInternalServerError)
+		client.WriteError(w, r, err)
 		return
 	}
 
 	// Prepare the combined response
 	responseData := map[string]interface{}{
-		"fes_results": fesResponses,
+		"fes_results":         fesResponses,
 		"actionable_insights": aiResponse,
 	}
 
@@ -309,90 +253,29 @@ func CallFESForActionableInsights(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(responseData)
 }
 
-
-// UserTestResult represents the test results of a user
-type UserTestResult struct {
-	ResultID         int       `json:"result_id"`
-	TestID           int       `json:"test_id"`
-	TestName         string    `json:"test_name"`
-	TimeTaken        float64   `json:"time_taken"`
-	AbruptPercentage int       `json:"abrupt_percentage"`
-	RiskLevel        string    `json:"risk_level"`
-	TestDate         time.Time `json:"test_date"`
-}
-
-
-func CallSelfAssessmentForInsights(w http.ResponseWriter, r *http.Request) {
+// CallSelfAssessmentForInsights returns a senior's self-assessment sessions with AI insights based on them
+func (h *Handler) CallSelfAssessmentForInsights(w http.ResponseWriter, r *http.Request) {
 	// Extract userID from the request query parameters
-	userID := r.URL.Query().Get("user_id")
-	if userID == "" {
-		log.Println("Error: user_id is missing in the request")
-		http.Error(w, "user_id is required", http.StatusBadRequest)
-		return
-	}
-
-	// Construct the API URL to fetch Self-Assessment results
-	apiURL := fmt.Sprintf("http://18.143.103.158:5250/api/v1/selfAssessment/getUserResults?user_id=%s", userID)
-
-	// Extract the Authorization header from the incoming request
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		http.Error(w, "Authorization header missing", http.StatusUnauthorized)
-		return
-	}
-
-	// Create an HTTP GET request to fetch Self-Assessment results
-	req, err := http.NewRequest("GET", apiURL, nil)
-	if err != nil {
-		log.Printf("Error creating request: %v", err)
-		http.Error(w, "Failed to create request", http.StatusInternalServerError)
-		return
-	}
-
-	// Set the Authorization header
-	req.Header.Set("Authorization", authHeader)
-
-	// Perform the request to the Self-Assessment microservice
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	userID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
 	if err != nil {
-		log.Printf("Error making request: %v", err)
-		http.Error(w, "Failed to contact Self-Assessment microservice", http.StatusInternalServerError)
-		return
-	}
-	defer resp.Body.Close()
-
-	// Check the status code of the response
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		http.Error(w, fmt.Sprintf("Microservice error: %s", string(body)), resp.StatusCode)
+		slog.WarnContext(r.Context(), "Request is missing user_id")
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "user_id is required")
 		return
 	}
+	ctx := client.FromRequest(r)
 
-	// Parse the response body
-	var sessions []struct {
-		SessionID    int       `json:"session_id"`
-		UserID      int       `json:"user_id"`
-		SessionDate time.Time `json:"session_date"`
-		TotalScore  struct {
-			Int64 int  `json:"Int64"`
-			Valid bool `json:"Valid"`
-		} `json:"total_score"`
-		TestResults []UserTestResult `json:"test_results"`
-	}
-
-	err = json.NewDecoder(resp.Body).Decode(&sessions)
+	// Fetch the senior's self-assessment sessions
+	sessions, err := h.clients.SelfAssessment.Sessions(ctx, userID)
 	if err != nil {
-		log.Printf("Error decoding response: %v", err)
-		http.Error(w, "Failed to parse response from Self-Assessment microservice", http.StatusInternalServerError)
+		client.WriteError(w, r, err)
 		return
 	}
 
 	// Convert full response into JSON string
 	fullResponse, err := json.Marshal(sessions)
 	if err != nil {
-		log.Printf("Error encoding full response: %v", err)
-		http.Error(w, "Failed to encode full response", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error encoding full response", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Failed to encode full response")
 		return
 	}
 
@@ -402,56 +285,17 @@ func CallSelfAssessmentForInsights(w http.ResponseWriter, r *http.Request) {
 		string(fullResponse),
 	)
 
-	// Construct the AI request body
-	aiRequestBody, err := json.Marshal(map[string]string{
-		"prompt": summaryPrompt,
-	})
-	if err != nil {
-		log.Printf("Error encoding AI request: %v", err)
-		http.Error(w, "Failed to create AI request payload", http.StatusInternalServerError)
-		return
-	}
-
 	// Call the AI microservice for insights
-	aiAPIURL := "http://18.143.103.158:5150/api/v1/generateResponse"
-	aiReq, err := http.NewRequest("POST", aiAPIURL, bytes.NewBuffer(aiRequestBody))
+	aiResponse, err := h.clients.OpenAI.GenerateResponse(ctx, summaryPrompt)
 	if err != nil {
-		log.Printf("Error creating AI request: %v", err)
-		http.Error(w, "Failed to create AI request", http.StatusInternalServerError)
-		return
-	}
-	aiReq.Header.Set("Authorization", authHeader)
-	aiReq.Header.Set("Content-Type", "application/json")
-
-	// Execute the request to the AI service
-	aiResp, err := client.Do(aiReq)
-	if err != nil {
-		log.Printf("Error contacting AI service: %v", err)
-		http.Error(w, "Failed to contact AI service", http.StatusInternalServerError)
-		return
-	}
-	defer aiResp.Body.Close()
-
-	// Check AI response status
-	if aiResp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(aiResp.Body)
-		http.Error(w, fmt.Sprintf("AI service error: %s", string(body)), aiResp.StatusCode)
-		return
-	}
-
-	// Parse AI response
-	var aiResponse map[string]interface{}
-	err = json.NewDecoder(aiResp.Body).Decode(&aiResponse)
-	if err != nil {
-		log.Printf("Error decoding AI response: %v", err)
-		http.Error(w, "Failed to parse AI response", http.StatusInternalServerError)
+		client.WriteError(w, r, err)
 		return
 	}
 
 	// Prepare the combined response
 	responseData := map[string]interface{}{
 		"self_assessment_results": sessions,
-		"actionable_insights":    aiResponse,
+		"actionable_insights":     aiResponse,
 	}
 
 	// Respond to the original client with both Self-Assessment results and AI-generated insights
@@ -460,10 +304,9 @@ func CallSelfAssessmentForInsights(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(responseData)
 }
 
-
 // ProcessVoucherEmail handles sending a voucher email when called.
-func ProcessVoucherEmail(w http.ResponseWriter, r *http.Request) {
-	log.Println("[DEBUG] Handling /process-voucher-email request...")
+func (h *Handler) ProcessVoucherEmail(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Handling /process-voucher-email request")
 
 	// Parse the incoming request
 	var request struct {
@@ -473,28 +316,28 @@ func ProcessVoucherEmail(w http.ResponseWriter, r *http.Request) {
 
 	err := json.NewDecoder(r.Body).Decode(&request)
 	if err != nil {
-		log.Printf("[ERROR] Error parsing request body: %v", err)
-		http.Error(w, "Invalid input", http.StatusBadRequest)
+		slog.ErrorContext(r.Context(), "[ERROR] Error parsing request body", "error", err)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidBody, "Invalid input")
 		return
 	}
-	log.Printf("[DEBUG] Parsed request - Email: %s, VoucherCount: %d", request.Email, request.VoucherCount)
+	slog.DebugContext(r.Context(), "Parsed voucher request", "email", request.Email, "voucher_count", request.VoucherCount)
 
 	// Validate input
 	if request.Email == "" || request.VoucherCount <= 0 {
-		log.Println("[ERROR] Invalid email or voucher count")
-		http.Error(w, "Invalid email or voucher count", http.StatusBadRequest)
+		slog.ErrorContext(r.Context(), "Invalid email or voucher count")
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidField, "Invalid email or voucher count")
 		return
 	}
 
 	// Send the voucher email
-	log.Printf("[DEBUG] Sending voucher email to %s...", request.Email)
+	slog.DebugContext(r.Context(), "Sending voucher email", "email", request.Email)
 	err = deliverVoucherEmail(request.Email, request.VoucherCount)
 	if err != nil {
-		log.Printf("[ERROR] Failed to send voucher email: %v", err)
-		http.Error(w, "Failed to send voucher email", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "[ERROR] Failed to send voucher email", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Failed to send voucher email")
 		return
 	}
-	log.Println("[DEBUG] Voucher email sent successfully.")
+	slog.DebugContext(r.Context(), "Voucher email sent successfully")
 
 	// Respond with success
 	w.Header().Set("Content-Type", "application/json")
@@ -504,15 +347,14 @@ func ProcessVoucherEmail(w http.ResponseWriter, r *http.Request) {
 
 // deliverVoucherEmail sends an email with a voucher image attachment.
 func deliverVoucherEmail(to string, voucherCount int) error {
-	// Hardcoded SMTP Configuration
-	smtpHost := "smtp.gmail.com"
-	smtpPort := "587"
-	smtpUser := os.Getenv("SMTP_USER")
-	smtpPassword := os.Getenv("SMTP_PASSWORD")
+	// SMTP configuration
+	mail := config.Current().SMTP
+	smtpHost, smtpPort := mail.Host, mail.Port
+	smtpUser, smtpPassword := mail.User, mail.Password
 
 	// Validate SMTP configuration
 	if smtpUser == "" || smtpPassword == "" {
-		log.Println("[ERROR] SMTP credentials are missing")
+		slog.Error("SMTP credentials are missing")
 		return fmt.Errorf("SMTP credentials are missing")
 	}
 
@@ -520,10 +362,10 @@ func deliverVoucherEmail(to string, voucherCount int) error {
 	imageURL := "https://fallsafe.s3.ap-southeast-1.amazonaws.com/misc/voucher.jpg"
 	imageData, err := fetchImage(imageURL)
 	if err != nil {
-		log.Printf("[ERROR] Failed to fetch voucher image: %v", err)
+		slog.Error("[ERROR] Failed to fetch voucher image", "error", err)
 		return err
 	}
-	log.Println("[DEBUG] Voucher image fetched successfully.")
+	slog.Debug("Voucher image fetched successfully")
 
 	// Sender Name
 	senderName := "FallSafe"
@@ -582,20 +424,20 @@ Content-Transfer-Encoding: 7bit
 	auth := smtp.PlainAuth("", smtpUser, smtpPassword, smtpHost)
 
 	// Send the email
-	log.Println("[DEBUG] Sending email via SMTP...")
+	slog.Debug("Sending email via SMTP")
 	err = smtp.SendMail(smtpHost+":"+smtpPort, auth, smtpUser, []string{to}, emailBuffer.Bytes())
 	if err != nil {
-		log.Printf("[ERROR] Failed to send email: %v", err)
+		slog.Error("[ERROR] Failed to send email", "error", err)
 		return err
 	}
 
-	log.Println("[DEBUG] Voucher email sent successfully.")
+	slog.Debug("Voucher email sent successfully")
 	return nil
 }
 
 // fetchImage retrieves the voucher image from the given URL and returns its byte data.
 func fetchImage(imageURL string) ([]byte, error) {
-	log.Printf("[DEBUG] Fetching voucher image from: %s", imageURL)
+	slog.Debug("Fetching voucher image", "url", imageURL)
 	resp, err := http.Get(imageURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch image: %v", err)
@@ -607,6 +449,6 @@ func fetchImage(imageURL string) ([]byte, error) {
 		return nil, fmt.Errorf("failed to read image data: %v", err)
 	}
 
-	log.Println("[DEBUG] Image fetched and read successfully.")
+	slog.Debug("Image fetched and read successfully")
 	return imageData, nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,277 @@
+package profile
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"shared/apierror"
+)
+
+// Risk observation sources
+const (
+	SourceFES            = "FES"
+	SourceSelfAssessment = "SelfAssessment"
+)
+
+// Combined risk tiers
+const (
+	TierLow      = "Low"
+	TierModerate = "Moderate"
+	TierHigh     = "High"
+)
+
+// Points at which the combined score moves up a tier
+const (
+	moderateTierPoints = 3
+	highTierPoints     = 6
+)
+
+// Changes between a senior's last two results large enough to count as a trend.
+// FES scores rise as fear of falling grows, self-assessment scores fall as performance worsens.
+const (
+	fesTrendThreshold            = 5
+	selfAssessmentTrendThreshold = 10
+)
+
+// RiskObservation is a new FES or self-assessment result, sent by those microservices after it is saved.
+// SourceID is the FES response or self-assessment session, so a session scored again replaces its earlier score.
+type RiskObservation struct {
+	UserID   int     `json:"user_id"`
+	Source   string  `json:"source"`
+	SourceID int     `json:"source_id"`
+	Score    float64 `json:"score"`
+}
+
+// RiskFactor is one contribution to a senior's combined risk, with the points it added
+type RiskFactor struct {
+	Factor string `json:"factor"`
+	Detail string `json:"detail"`
+	Points int    `json:"points"`
+}
+
+// CombinedRisk is a senior's overall fall-risk tier and the factors it was built from
+type CombinedRisk struct {
+	UserID     int          `json:"user_id"`
+	Tier       string       `json:"tier"`
+	Points     int          `json:"points"`
+	Factors    []RiskFactor `json:"factors"`
+	ComputedAt time.Time    `json:"computed_at"`
+}
+
+// RiskInputs holds what the combined risk is computed from. Scores are newest first
+// and only the latest two of each source are kept, which is all the trend needs.
+type RiskInputs struct {
+	Age                  sql.NullInt64
+	FESScores            []float64
+	SelfAssessmentScores []float64
+}
+
+// assessRisk merges the latest FES score, self-assessment score, age and trend into a tier.
+// The FES and self-assessment bands match the ones each microservice reports on its own.
+func assessRisk(in RiskInputs) (string, int, []RiskFactor) {
+	factors := []RiskFactor{}
+
+	if len(in.FESScores) == 0 {
+		factors = append(factors, RiskFactor{Factor: "Fear of falling", Detail: "No FES result yet"})
+	} else {
+		score := in.FESScores[0]
+		switch {
+		case fesHighRisk(score):
+			factors = append(factors, RiskFactor{Factor: "Fear of falling", Detail: fmt.Sprintf("High concern on the FES (%.0f/64)", score), Points: 4})
+		case score >= 37:
+			factors = append(factors, RiskFactor{Factor: "Fear of falling", Detail: fmt.Sprintf("Moderate concern on the FES (%.0f/64)", score), Points: 2})
+		default:
+			factors = append(factors, RiskFactor{Factor: "Fear of falling", Detail: fmt.Sprintf("Low concern on the FES (%.0f/64)", score)})
+		}
+		if len(in.FESScores) > 1 {
+			if change := score - in.FESScores[1]; change >= fesTrendThreshold {
+				factors = append(factors, RiskFactor{Factor: "FES trend", Detail: fmt.Sprintf("FES score rose by %.0f since the previous result", change), Points: 1})
+			} else if change <= -fesTrendThreshold {
+				factors = append(factors, RiskFactor{Factor: "FES trend", Detail: fmt.Sprintf("FES score fell by %.0f since the previous result", -change), Points: -1})
+			}
+		}
+	}
+
+	if len(in.SelfAssessmentScores) == 0 {
+		factors = append(factors, RiskFactor{Factor: "Physical assessment", Detail: "No self-assessment result yet"})
+	} else {
+		score := in.SelfAssessmentScores[0]
+		switch {
+		case selfAssessmentHighRisk(score):
+			factors = append(factors, RiskFactor{Factor: "Physical assessment", Detail: fmt.Sprintf("High risk on the self-assessment (%.0f/100)", score), Points: 4})
+		case score < 60:
+			factors = append(factors, RiskFactor{Factor: "Physical assessment", Detail: fmt.Sprintf("Moderate risk on the self-assessment (%.0f/100)", score), Points: 2})
+		default:
+			factors = append(factors, RiskFactor{Factor: "Physical assessment", Detail: fmt.Sprintf("Low risk on the self-assessment (%.0f/100)", score)})
+		}
+		if len(in.SelfAssessmentScores) > 1 {
+			if change := score - in.SelfAssessmentScores[1]; change <= -selfAssessmentTrendThreshold {
+				factors = append(factors, RiskFactor{Factor: "Physical trend", Detail: fmt.Sprintf("Self-assessment score fell by %.0f since the previous session", -change), Points: 1})
+			} else if change >= selfAssessmentTrendThreshold {
+				factors = append(factors, RiskFactor{Factor: "Physical trend", Detail: fmt.Sprintf("Self-assessment score rose by %.0f since the previous session", change), Points: -1})
+			}
+		}
+	}
+
+	if in.Age.Valid {
+		switch age := in.Age.Int64; {
+		case age >= 80:
+			factors = append(factors, RiskFactor{Factor: "Age", Detail: fmt.Sprintf("Aged %d", age), Points: 2})
+		case age >= 70:
+			factors = append(factors, RiskFactor{Factor: "Age", Detail: fmt.Sprintf("Aged %d", age), Points: 1})
+		}
+	}
+
+	points := 0
+	for _, factor := range factors {
+		points += factor.Points
+	}
+	if points < 0 {
+		points = 0
+	}
+
+	switch {
+	case points >= highTierPoints:
+		return TierHigh, points, factors
+	case points >= moderateTierPoints:
+		return TierModerate, points, factors
+	default:
+		return TierLow, points, factors
+	}
+}
+
+// RecordRiskObservation stores a new result and recomputes the senior's combined risk,
+// opening a clinical referral with the admin microservice if the result turned high risk.
+// Called by the FES and self-assessment microservices whenever a result is saved.
+func (h *Handler) RecordRiskObservation(w http.ResponseWriter, r *http.Request) {
+	var observation RiskObservation
+	if err := json.NewDecoder(r.Body).Decode(&observation); err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidBody, "Invalid request payload")
+		return
+	}
+	if observation.UserID == 0 || observation.SourceID == 0 {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.MissingField, "user_id and source_id are required")
+		return
+	}
+	if observation.Source != SourceFES && observation.Source != SourceSelfAssessment {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.InvalidField, "source must be FES or SelfAssessment")
+		return
+	}
+
+	err := h.store.SaveRiskObservation(r.Context(), observation)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error saving risk observation", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	in, err := h.store.RiskInputs(r.Context(), observation.UserID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading risk inputs", "user_id", observation.UserID, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+	risk, err := h.saveCombinedRisk(r.Context(), observation.UserID, in)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error saving combined risk", "user_id", observation.UserID, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	if reason, ok := turnedHigh(observation.Source, in); ok {
+		if err := h.clients.Admin.OpenReferral(r.Context(), observation.UserID, observation.Source, reason); err != nil {
+			slog.ErrorContext(r.Context(), "Error opening referral", "user_id", observation.UserID, "error", err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(risk)
+}
+
+// turnedHigh reports whether the source's latest score is high risk when the one before it was not,
+// returning the reason a clinical referral should be opened
+func turnedHigh(source string, in RiskInputs) (string, bool) {
+	switch source {
+	case SourceFES:
+		if len(in.FESScores) > 0 && fesHighRisk(in.FESScores[0]) && (len(in.FESScores) == 1 || !fesHighRisk(in.FESScores[1])) {
+			return fmt.Sprintf("FES score of %.0f/64 is high concern about falling", in.FESScores[0]), true
+		}
+	case SourceSelfAssessment:
+		scores := in.SelfAssessmentScores
+		if len(scores) > 0 && selfAssessmentHighRisk(scores[0]) && (len(scores) == 1 || !selfAssessmentHighRisk(scores[1])) {
+			return fmt.Sprintf("Self-assessment score of %.0f/100 is high fall risk", scores[0]), true
+		}
+	}
+	return "", false
+}
+
+// fesHighRisk reports whether an FES total is in the high concern band
+func fesHighRisk(score float64) bool {
+	return score >= 49
+}
+
+// selfAssessmentHighRisk reports whether a self-assessment session score is in the high risk band
+func selfAssessmentHighRisk(score float64) bool {
+	return score < 30
+}
+
+// saveCombinedRisk assesses a senior from their inputs and stores the result
+func (h *Handler) saveCombinedRisk(ctx context.Context, userID int, in RiskInputs) (CombinedRisk, error) {
+	risk := CombinedRisk{UserID: userID, ComputedAt: time.Now().UTC()}
+	risk.Tier, risk.Points, risk.Factors = assessRisk(in)
+	return risk, h.store.SaveCombinedRisk(ctx, risk)
+}
+
+// GetCombinedRisk returns the calling senior's combined risk
+func (h *Handler) GetCombinedRisk(w http.ResponseWriter, r *http.Request) {
+	userID, ok := claimedID(r)
+	if !ok {
+		apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+		return
+	}
+	h.writeCombinedRisk(w, r, userID)
+}
+
+// GetSeniorCombinedRisk returns a linked senior's combined risk to a caregiver
+func (h *Handler) GetSeniorCombinedRisk(w http.ResponseWriter, r *http.Request) {
+	seniorID, ok := h.authorizeCaregiver(w, r, ScopeResults)
+	if !ok {
+		return
+	}
+	h.writeCombinedRisk(w, r, seniorID)
+}
+
+// writeCombinedRisk responds with a senior's stored combined risk, or 404 if they have no results yet
+func (h *Handler) writeCombinedRisk(w http.ResponseWriter, r *http.Request, userID int) {
+	risks, err := h.store.CombinedRisks(r.Context(), &userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying combined risk", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+	if len(risks) == 0 {
+		apierror.Write(w, r, http.StatusNotFound, apierror.NotFound, "No assessment results yet")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(risks[0])
+}
+
+// GetAllCombinedRisks returns every senior's combined risk, highest points first, for admins
+func (h *Handler) GetAllCombinedRisks(w http.ResponseWriter, r *http.Request) {
+	risks, err := h.store.CombinedRisks(r.Context(), nil)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying combined risks", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(risks)
+}
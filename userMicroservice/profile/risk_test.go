@@ -0,0 +1,91 @@
+package profile
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestAssessRisk(t *testing.T) {
+	age := func(years int64) sql.NullInt64 { return sql.NullInt64{Int64: years, Valid: true} }
+
+	tests := []struct {
+		name    string
+		in      RiskInputs
+		tier    string
+		points  int
+		factors []string // Factor:Points of every factor, in order
+	}{
+		{"no results", RiskInputs{},
+			TierLow, 0, []string{"Fear of falling:0", "Physical assessment:0"}},
+		{"no results and aged 85", RiskInputs{Age: age(85)},
+			TierLow, 2, []string{"Fear of falling:0", "Physical assessment:0", "Age:2"}},
+
+		// Fear of falling bands
+		{"high FES", RiskInputs{FESScores: []float64{49}},
+			TierModerate, 4, []string{"Fear of falling:4", "Physical assessment:0"}},
+		{"moderate FES", RiskInputs{FESScores: []float64{37}},
+			TierLow, 2, []string{"Fear of falling:2", "Physical assessment:0"}},
+		{"low FES", RiskInputs{FESScores: []float64{36}},
+			TierLow, 0, []string{"Fear of falling:0", "Physical assessment:0"}},
+		{"FES rising", RiskInputs{FESScores: []float64{40, 35}},
+			TierModerate, 3, []string{"Fear of falling:2", "FES trend:1", "Physical assessment:0"}},
+		{"FES falling", RiskInputs{FESScores: []float64{40, 45}},
+			TierLow, 1, []string{"Fear of falling:2", "FES trend:-1", "Physical assessment:0"}},
+		{"FES change under the trend threshold", RiskInputs{FESScores: []float64{40, 36}},
+			TierLow, 2, []string{"Fear of falling:2", "Physical assessment:0"}},
+
+		// Physical assessment bands
+		{"high-risk self-assessment", RiskInputs{SelfAssessmentScores: []float64{29}},
+			TierModerate, 4, []string{"Fear of falling:0", "Physical assessment:4"}},
+		{"moderate self-assessment", RiskInputs{SelfAssessmentScores: []float64{30}},
+			TierLow, 2, []string{"Fear of falling:0", "Physical assessment:2"}},
+		{"low-risk self-assessment", RiskInputs{SelfAssessmentScores: []float64{60}},
+			TierLow, 0, []string{"Fear of falling:0", "Physical assessment:0"}},
+		{"self-assessment falling", RiskInputs{SelfAssessmentScores: []float64{50, 60}},
+			TierModerate, 3, []string{"Fear of falling:0", "Physical assessment:2", "Physical trend:1"}},
+		{"self-assessment improving", RiskInputs{SelfAssessmentScores: []float64{70, 55}},
+			TierLow, 0, []string{"Fear of falling:0", "Physical assessment:0", "Physical trend:-1"}},
+
+		// Only the latest result is banded, earlier ones only feed the trend
+		{"stale high FES", RiskInputs{FESScores: []float64{30, 55}},
+			TierLow, 0, []string{"Fear of falling:0", "FES trend:-1", "Physical assessment:0"}},
+		{"stale high-risk self-assessment", RiskInputs{SelfAssessmentScores: []float64{65, 20}},
+			TierLow, 0, []string{"Fear of falling:0", "Physical assessment:0", "Physical trend:-1"}},
+
+		// Age bands
+		{"aged 69", RiskInputs{Age: age(69)},
+			TierLow, 0, []string{"Fear of falling:0", "Physical assessment:0"}},
+		{"aged 70", RiskInputs{Age: age(70)},
+			TierLow, 1, []string{"Fear of falling:0", "Physical assessment:0", "Age:1"}},
+		{"aged 80", RiskInputs{Age: age(80)},
+			TierLow, 2, []string{"Fear of falling:0", "Physical assessment:0", "Age:2"}},
+
+		// Tier cutoffs
+		{"just under high", RiskInputs{FESScores: []float64{49}, Age: age(75)},
+			TierModerate, 5, []string{"Fear of falling:4", "Physical assessment:0", "Age:1"}},
+		{"high with the self-assessment missing", RiskInputs{FESScores: []float64{49}, Age: age(80)},
+			TierHigh, 6, []string{"Fear of falling:4", "Physical assessment:0", "Age:2"}},
+		{"high with the FES missing", RiskInputs{SelfAssessmentScores: []float64{29}, Age: age(80)},
+			TierHigh, 6, []string{"Fear of falling:0", "Physical assessment:4", "Age:2"}},
+		{"every factor", RiskInputs{FESScores: []float64{55, 45}, SelfAssessmentScores: []float64{25, 40}, Age: age(82)},
+			TierHigh, 12, []string{"Fear of falling:4", "FES trend:1", "Physical assessment:4", "Physical trend:1", "Age:2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tier, points, factors := assessRisk(tt.in)
+			if tier != tt.tier || points != tt.points {
+				t.Errorf("assessRisk = %s with %d points, want %s with %d", tier, points, tt.tier, tt.points)
+			}
+
+			got := []string{}
+			for _, factor := range factors {
+				got = append(got, fmt.Sprintf("%s:%d", factor.Factor, factor.Points))
+			}
+			if !reflect.DeepEqual(got, tt.factors) {
+				t.Errorf("factors = %v, want %v", got, tt.factors)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package profile
+
+import (
+	"context"
+	"errors"
+
+	"shared/pagination"
+)
+
+// ErrNotFound is returned by a store when the requested row does not exist
+var ErrNotFound = errors.New("not found")
+
+// ErrConflict is returned by SaveUser when a different profile already uses the email or user ID
+var ErrConflict = errors.New("a different profile already uses this email or user ID")
+
+// UserStore is the profile, caregiver and combined risk data the handlers read and write.
+// MySQLStore keeps it in the user database, and the memstore package keeps it in memory for handler tests.
+type UserStore interface {
+	// SaveUser creates a profile, or refreshes it if it already exists with the same ID and email
+	SaveUser(ctx context.Context, user User) error
+	// User returns a profile, or ErrNotFound if there is none
+	User(ctx context.Context, userID int) (User, error)
+	// Users returns a page of profiles, and the number matching the filters
+	Users(ctx context.Context, page *pagination.Query) ([]UserNameAge, int, error)
+
+	// SaveCaregiverInvite records a senior's invitation of a caregiver. Inviting the same caregiver again
+	// refreshes the consent and token, and keeps an active link active.
+	SaveCaregiverInvite(ctx context.Context, invite CaregiverInvite) error
+	// SeniorCaregiverLinks returns every link a senior has made, including revoked ones, in ID order
+	SeniorCaregiverLinks(ctx context.Context, seniorID int) ([]CaregiverLink, error)
+	// CaregiverSeniorLinks returns a caregiver's active links, in ID order
+	CaregiverSeniorLinks(ctx context.Context, caregiverID int) ([]CaregiverLink, error)
+	// RevokeCaregiver revokes a senior's link, reporting whether the senior has such a link
+	RevokeCaregiver(ctx context.Context, linkID, seniorID int) (bool, error)
+	// UpdateCaregiverScopes changes the scopes of a senior's link that is not revoked, reporting whether there is one
+	UpdateCaregiverScopes(ctx context.Context, linkID, seniorID int, scopes []string) (bool, error)
+	// PendingInviteExists reports whether a pending invitation with the token hash was sent to the email
+	PendingInviteExists(ctx context.Context, email, tokenHash string) (bool, error)
+	// AcceptCaregiverInvite activates the pending link with the token hash sent to the email, reporting whether there was one
+	AcceptCaregiverInvite(ctx context.Context, caregiverID int, email, tokenHash string) (bool, error)
+	// ActiveScopes returns the scopes of the caregiver's active link to the senior, or none if there is no such link
+	ActiveScopes(ctx context.Context, caregiverID, seniorID int) ([]string, error)
+	// ReminderCaregivers returns the emails of the active caregivers of the senior with the email who may see reminders
+	ReminderCaregivers(ctx context.Context, seniorEmail string) ([]string, error)
+
+	// SaveRiskObservation stores a result, replacing the earlier score of the same source and source ID
+	SaveRiskObservation(ctx context.Context, observation RiskObservation) error
+	// RiskInputs returns a senior's age and their latest two scores from each source
+	RiskInputs(ctx context.Context, userID int) (RiskInputs, error)
+	// SaveCombinedRisk stores a senior's combined risk, replacing the one before
+	SaveCombinedRisk(ctx context.Context, risk CombinedRisk) error
+	// CombinedRisks returns the combined risk of one senior, or of every senior when userID is nil,
+	// highest points first
+	CombinedRisks(ctx context.Context, userID *int) ([]CombinedRisk, error)
+}
+
+// CaregiverInvite is a senior's invitation of a caregiver, with the hash of the token sent to them
+type CaregiverInvite struct {
+	SeniorID       int
+	CaregiverEmail string
+	Relationship   string
+	Scopes         []string
+	TokenHash      string
+}
@@ -0,0 +1,219 @@
+// Package server routes the user microservice's endpoints, so main and the integration
+// harness serve the same handlers behind the same middleware
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"userMicroservice/client/auth"
+	"userMicroservice/config"
+	"userMicroservice/profile"
+
+	"shared/apierror"
+	"shared/client"
+	sharedconfig "shared/config"
+	"shared/logging"
+	"shared/observability"
+	"shared/pagination"
+	"shared/tracing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/gorilla/handlers"
+	"github.com/gorilla/mux"
+)
+
+// adminSessions reports whether an admin's token is still live. The authentication microservice
+// removes an admin's tokens when they are deactivated or reset.
+type adminSessions interface {
+	AdminTokenActive(ctx context.Context, token string) (bool, error)
+}
+
+// authenticator checks tokens for the routes, asking sessions about admin tokens on every request
+type authenticator struct {
+	sessions adminSessions
+}
+
+// JWT Authentication Middleware with Role Check for multiple roles
+func (a authenticator) authenticateMiddleware(allowedRoles []string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Get the Authorization header
+			authHeader := r.Header.Get("Authorization")
+			if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+				return
+			}
+
+			// Extract token
+			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+
+			// Get the JWT secret from the configuration
+			secretKey := config.Current().JWTSecret
+			if secretKey == "" {
+				slog.ErrorContext(r.Context(), "JWT_SECRET is not set")
+				apierror.Write(w, r, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+				return
+			}
+
+			// Parse and validate the token
+			token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+					return nil, http.ErrAbortHandler
+				}
+				return []byte(secretKey), nil
+			})
+
+			if err != nil || !token.Valid {
+				slog.WarnContext(r.Context(), "Invalid JWT token", "error", err)
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+				return
+			}
+
+			// Extract the claims from the token
+			claims, ok := token.Claims.(jwt.MapClaims)
+			if !ok {
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+				return
+			}
+
+			// Check if the role matches any of the allowed roles
+			role, ok := claims["role"].(string)
+			if !ok {
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+				return
+			}
+
+			// Check if the user's role is in the allowed roles
+			roleAllowed := false
+			for _, allowedRole := range allowedRoles {
+				if role == allowedRole {
+					roleAllowed = true
+					break
+				}
+			}
+
+			if !roleAllowed {
+				apierror.Write(w, r, http.StatusForbidden, apierror.Forbidden, "Forbidden")
+				return
+			}
+
+			// Admin tokens stop working as soon as they are revoked, rather than when they expire
+			if role == "Admin" {
+				active, err := a.sessions.AdminTokenActive(r.Context(), tokenString)
+				if err != nil {
+					client.WriteError(w, r, err)
+					return
+				}
+				if !active {
+					slog.WarnContext(r.Context(), "Admin token revoked")
+					apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+					return
+				}
+			}
+
+			// Proceed to the next handler if role matches, keeping the claims for permission checks
+			ctx := context.WithValue(r.Context(), profile.ClaimsContextKey, claims)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Permission Middleware for admin tokens, must run after authenticateMiddleware.
+// Senior tokens carry no permissions and are only gated by their role.
+func requirePermission(permission string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := r.Context().Value(profile.ClaimsContextKey).(jwt.MapClaims)
+			if !ok {
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.Unauthorized, "Unauthorized")
+				return
+			}
+
+			if role, _ := claims["role"].(string); role != "Admin" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Check if the admin's role grants the permission
+			permissions, _ := claims["permissions"].([]interface{})
+			for _, granted := range permissions {
+				if granted == permission {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			slog.WarnContext(r.Context(), "Admin token lacks permission", "permission", permission)
+			apierror.Write(w, r, http.StatusForbidden, apierror.Forbidden, "Forbidden")
+		})
+	}
+}
+
+// NewRouter returns the user endpoints of Routes, with CORS for the frontend and request logging
+func NewRouter(h *profile.Handler, health *observability.Health) http.Handler {
+	// Add CORS support, inside the middleware that tags every request with an ID and logs it
+	return logging.Middleware(handlers.CORS(
+		handlers.AllowedOrigins(sharedconfig.AllowedOrigins()),
+		handlers.AllowedMethods([]string{"GET", "POST", "PUT", "OPTIONS"}),                                                   // Update for allowed HTTP methods
+		handlers.AllowedHeaders([]string{"Content-Type", "Authorization"}),                                                   // Include Authorization header
+		handlers.ExposedHeaders([]string{pagination.TotalCountHeader, pagination.NextCursorHeader, logging.RequestIDHeader}), // Let the frontend page through lists and report request IDs
+	)(Routes(h, health)))
+}
+
+// Routes returns the user endpoints behind their authentication middleware, alongside the probe and
+// metrics endpoints of health. Every /api/v1 route is described in the service's OpenAPI spec, in the
+// api package, which the integration harness checks against these routes.
+func Routes(h *profile.Handler, health *observability.Health) *mux.Router {
+	// Initialize the router, tracing and timing every request by its route
+	router := mux.NewRouter()
+	router.Use(tracing.Middleware(sharedconfig.UserService), observability.Middleware)
+
+	// Unknown routes and methods get the same JSON errors as the endpoints
+	router.NotFoundHandler = apierror.NotFoundHandler()
+	router.MethodNotAllowedHandler = apierror.MethodNotAllowedHandler()
+
+	// Liveness, readiness and metrics, served without authentication
+	health.RegisterRoutes(router)
+
+	// Tokens are checked here, asking the authentication microservice about admin tokens on every request
+	authenticateMiddleware := authenticator{sessions: auth.New()}.authenticateMiddleware
+
+	// Profile management endpoints
+	router.HandleFunc("/api/v1/user/getUser", h.GetUserByID).Methods("GET")
+
+	// JWT Authentication Logic
+	authenticated := router.NewRoute().Subrouter()
+	authenticated.Handle("/api/v1/user/create", authenticateMiddleware([]string{"Service"})(http.HandlerFunc(h.CreateUser))).Methods("POST") // Called by authentication microservice
+
+	// Caregiver lookups for other microservices
+	authenticated.Handle("/api/v1/user/caregiver/verifyInvite", authenticateMiddleware([]string{"Service"})(http.HandlerFunc(h.VerifyCaregiverInvite))).Methods("POST")      // Called by authentication microservice
+	authenticated.Handle("/api/v1/user/caregiver/checkAccess", authenticateMiddleware([]string{"Service"})(http.HandlerFunc(h.CheckCaregiverAccess))).Methods("GET")         // Called by FES and self-assessment microservices
+	authenticated.Handle("/api/v1/user/caregiver/reminderRecipients", authenticateMiddleware([]string{"Service"})(http.HandlerFunc(h.GetReminderCaregivers))).Methods("GET") // Called by admin microservice
+
+	authenticated.Handle("/api/v1/user/risk/observations", authenticateMiddleware([]string{"Service"})(http.HandlerFunc(h.RecordRiskObservation))).Methods("POST") // Called by FES and self-assessment microservices
+	authenticated.Handle("/api/v1/user/getAllUser", authenticateMiddleware([]string{"Admin"})(requirePermission("seniors:read")(http.HandlerFunc(h.GetAllUser)))).Methods("GET")
+	authenticated.Handle("/api/v1/user/getAUserFESResults", authenticateMiddleware([]string{"User"})(http.HandlerFunc(h.CallFESForActionableInsights))).Methods("GET")
+	authenticated.Handle("/api/v1/user/getAUserTestResults", authenticateMiddleware([]string{"User"})(http.HandlerFunc(h.CallSelfAssessmentForInsights))).Methods("GET")
+	authenticated.Handle("/api/v1/user/sendVoucherEmail", authenticateMiddleware([]string{"User"})(http.HandlerFunc(h.ProcessVoucherEmail))).Methods("POST")
+	authenticated.Handle("/api/v1/user/risk", authenticateMiddleware([]string{"User"})(http.HandlerFunc(h.GetCombinedRisk))).Methods("GET")
+	authenticated.Handle("/api/v1/user/risk/all", authenticateMiddleware([]string{"Admin"})(requirePermission("clinical:read")(http.HandlerFunc(h.GetAllCombinedRisks)))).Methods("GET")
+
+	// Caregiver management endpoints for seniors
+	authenticated.Handle("/api/v1/user/caregivers", authenticateMiddleware([]string{"User"})(http.HandlerFunc(h.GetCaregivers))).Methods("GET")
+	authenticated.Handle("/api/v1/user/caregivers/invite", authenticateMiddleware([]string{"User"})(http.HandlerFunc(h.InviteCaregiver))).Methods("POST")
+	authenticated.Handle("/api/v1/user/caregivers/revoke", authenticateMiddleware([]string{"User"})(http.HandlerFunc(h.RevokeCaregiver))).Methods("POST")
+	authenticated.Handle("/api/v1/user/caregivers/scopes", authenticateMiddleware([]string{"User"})(http.HandlerFunc(h.UpdateCaregiverScopes))).Methods("POST")
+
+	// Caregiver endpoints, each senior's data is gated by the scopes they consented to
+	authenticated.Handle("/api/v1/user/caregiver/accept", authenticateMiddleware([]string{"Caregiver"})(http.HandlerFunc(h.AcceptCaregiverInvite))).Methods("POST")
+	authenticated.Handle("/api/v1/user/caregiver/seniors", authenticateMiddleware([]string{"Caregiver"})(http.HandlerFunc(h.GetCaregiverSeniors))).Methods("GET")
+	authenticated.Handle("/api/v1/user/caregiver/getSeniorFESResults", authenticateMiddleware([]string{"Caregiver"})(http.HandlerFunc(h.GetSeniorFESResults))).Methods("GET")
+	authenticated.Handle("/api/v1/user/caregiver/getSeniorTestResults", authenticateMiddleware([]string{"Caregiver"})(http.HandlerFunc(h.GetSeniorTestResults))).Methods("GET")
+	authenticated.Handle("/api/v1/user/caregiver/getSeniorLastAssessment", authenticateMiddleware([]string{"Caregiver"})(http.HandlerFunc(h.GetSeniorLastAssessment))).Methods("GET")
+	authenticated.Handle("/api/v1/user/caregiver/getSeniorRisk", authenticateMiddleware([]string{"Caregiver"})(http.HandlerFunc(h.GetSeniorCombinedRisk))).Methods("GET")
+
+	return router
+}
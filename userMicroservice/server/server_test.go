@@ -0,0 +1,179 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"userMicroservice/config"
+	"userMicroservice/profile"
+	"userMicroservice/profile/memstore"
+
+	"shared/observability"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// testService serves the user routes from an in-memory store
+type testService struct {
+	store     *memstore.Store
+	referrals *memstore.ReferralRecorder
+	routes    http.Handler
+}
+
+func newTestService(t *testing.T) *testService {
+	t.Helper()
+	config.Current().JWTSecret = "test-secret"
+
+	s := &testService{store: memstore.New(), referrals: &memstore.ReferralRecorder{}}
+	s.routes = Routes(profile.NewHandler(s.store, profile.Clients{Admin: s.referrals}), observability.NewHealth())
+	return s
+}
+
+// serve sends a request to the routes, with a token for claims unless claims is nil
+func (s *testService) serve(t *testing.T, method, path string, claims jwt.MapClaims, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if claims != nil {
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(config.Current().JWTSecret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.routes.ServeHTTP(w, r)
+	return w
+}
+
+// senior returns the claims of a senior's token
+func senior(userID int) jwt.MapClaims {
+	return jwt.MapClaims{"user_id": userID, "role": "User"}
+}
+
+// service returns the claims of another microservice's token
+func service() jwt.MapClaims {
+	return jwt.MapClaims{"role": "Service"}
+}
+
+func TestCreateUser(t *testing.T) {
+	s := newTestService(t)
+	body := `{"user_id":7,"name":"Tan Ah Kow","email":"ahkow@example.com","age":"72"}`
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		body   string
+		want   int
+	}{
+		{"no token", nil, body, http.StatusUnauthorized},
+		{"senior token", senior(7), body, http.StatusForbidden},
+		{"service token", service(), body, http.StatusCreated},
+		{"email taken by another user", service(), `{"user_id":8,"email":"ahkow@example.com"}`, http.StatusConflict},
+		{"missing email", service(), `{"user_id":9}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := s.serve(t, "POST", "/api/v1/user/create", tt.claims, tt.body); w.Code != tt.want {
+				t.Errorf("status = %d %s, want %d", w.Code, w.Body, tt.want)
+			}
+		})
+	}
+
+	if user, err := s.store.User(context.Background(), 7); err != nil || user.Email != "ahkow@example.com" {
+		t.Errorf("user 7 = %+v, %v, want the created profile", user, err)
+	}
+}
+
+func TestCheckCaregiverAccess(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	s.store.SaveUser(ctx, profile.User{UserID: 7, Name: "Tan Ah Kow", Email: "ahkow@example.com"})
+	err := s.store.SaveCaregiverInvite(ctx, profile.CaregiverInvite{SeniorID: 7, CaregiverEmail: "mei@example.com", Relationship: "Daughter", Scopes: []string{profile.ScopeResults}, TokenHash: "hash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(t *testing.T, claims jwt.MapClaims, scope string) *httptest.ResponseRecorder {
+		t.Helper()
+		return s.serve(t, "GET", "/api/v1/user/caregiver/checkAccess?caregiver_id=3&senior_id=7&scope="+scope, claims, "")
+	}
+	granted := func(t *testing.T, scope string) bool {
+		t.Helper()
+		w := check(t, service(), scope)
+		var access struct {
+			Granted bool `json:"granted"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &access); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("checkAccess = %d %s", w.Code, w.Body)
+		}
+		return access.Granted
+	}
+
+	// Nothing is granted until the caregiver accepts
+	if granted(t, profile.ScopeResults) {
+		t.Error("results granted before the invitation was accepted")
+	}
+	if ok, err := s.store.AcceptCaregiverInvite(ctx, 3, "mei@example.com", "hash"); err != nil || !ok {
+		t.Fatalf("AcceptCaregiverInvite = %v, %v", ok, err)
+	}
+	if !granted(t, profile.ScopeResults) {
+		t.Error("results not granted after the invitation was accepted")
+	}
+	if granted(t, profile.ScopeReminders) {
+		t.Error("reminders granted without the senior's consent")
+	}
+
+	// Only other microservices ask
+	if w := check(t, nil, profile.ScopeResults); w.Code != http.StatusUnauthorized {
+		t.Errorf("no token: status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if w := check(t, jwt.MapClaims{"user_id": 3, "role": "Caregiver"}, profile.ScopeResults); w.Code != http.StatusForbidden {
+		t.Errorf("caregiver token: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestRecordRiskObservation(t *testing.T) {
+	s := newTestService(t)
+	s.store.SaveUser(context.Background(), profile.User{UserID: 7, Name: "Tan Ah Kow", Email: "ahkow@example.com"})
+	observe := func(t *testing.T, sourceID, score int) profile.CombinedRisk {
+		t.Helper()
+		w := s.serve(t, "POST", "/api/v1/user/risk/observations", service(), fmt.Sprintf(`{"user_id":7,"source":"FES","source_id":%d,"score":%d}`, sourceID, score))
+		var risk profile.CombinedRisk
+		if err := json.Unmarshal(w.Body.Bytes(), &risk); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("risk/observations = %d %s", w.Code, w.Body)
+		}
+		return risk
+	}
+
+	if risk := observe(t, 1, 30); risk.Tier != profile.TierLow || len(s.referrals.Referrals()) != 0 {
+		t.Errorf("low FES: tier %s with %d referrals, want %s with none", risk.Tier, len(s.referrals.Referrals()), profile.TierLow)
+	}
+
+	// Turning high risk opens one referral, staying high risk opens no more
+	observe(t, 2, 55)
+	observe(t, 3, 60)
+	if referrals := s.referrals.Referrals(); len(referrals) != 1 || referrals[0].UserID != 7 || referrals[0].Source != profile.SourceFES {
+		t.Errorf("referrals = %+v, want one FES referral for senior 7", referrals)
+	}
+
+	// The senior reads the combined risk that was stored
+	w := s.serve(t, "GET", "/api/v1/user/risk", senior(7), "")
+	var risk profile.CombinedRisk
+	if err := json.Unmarshal(w.Body.Bytes(), &risk); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("risk = %d %s", w.Code, w.Body)
+	}
+	if risk.UserID != 7 || risk.Points < 4 {
+		t.Errorf("risk = %+v, want senior 7's risk with the high FES counted", risk)
+	}
+
+	if w := s.serve(t, "POST", "/api/v1/user/risk/observations", senior(7), `{"user_id":7,"source":"FES","source_id":4,"score":10}`); w.Code != http.StatusForbidden {
+		t.Errorf("senior token: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}